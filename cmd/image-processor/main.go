@@ -6,21 +6,69 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 	httpSwagger "github.com/swaggo/http-swagger"
 	"imageProcessor/internal/config"
+	"imageProcessor/internal/events"
+	"imageProcessor/internal/fetcher"
+	"imageProcessor/internal/http-server/handlers/apikey/createKey"
+	"imageProcessor/internal/http-server/handlers/apikey/listKeys"
+	"imageProcessor/internal/http-server/handlers/apikey/revokeKey"
+	"imageProcessor/internal/http-server/handlers/blob/putBlob"
+	"imageProcessor/internal/http-server/handlers/events/imageEvents"
+	"imageProcessor/internal/http-server/handlers/events/streamEvents"
 	"imageProcessor/internal/http-server/handlers/image/deleteImage"
+	"imageProcessor/internal/http-server/handlers/image/exportImages"
+	"imageProcessor/internal/http-server/handlers/image/findSimilar"
+	"imageProcessor/internal/http-server/handlers/image/getArchive"
+	"imageProcessor/internal/http-server/handlers/image/getBatch"
 	"imageProcessor/internal/http-server/handlers/image/getImage"
+	"imageProcessor/internal/http-server/handlers/image/getMetadata"
+	"imageProcessor/internal/http-server/handlers/image/getOriginal"
+	"imageProcessor/internal/http-server/handlers/image/getPicture"
+	"imageProcessor/internal/http-server/handlers/image/getVariant"
+	"imageProcessor/internal/http-server/handlers/image/saveBatch"
 	"imageProcessor/internal/http-server/handlers/image/saveImage"
+	"imageProcessor/internal/http-server/handlers/image/searchGeo"
+	"imageProcessor/internal/http-server/handlers/image/signTransform"
+	"imageProcessor/internal/http-server/handlers/image/transformImage"
+	"imageProcessor/internal/http-server/handlers/presign/completeUpload"
+	presignUpload "imageProcessor/internal/http-server/handlers/presign/createUpload"
+	"imageProcessor/internal/http-server/handlers/tus/createUpload"
+	"imageProcessor/internal/http-server/handlers/tus/deleteUpload"
+	"imageProcessor/internal/http-server/handlers/tus/getUpload"
+	"imageProcessor/internal/http-server/handlers/tus/patchUpload"
+	"imageProcessor/internal/http-server/handlers/usage/getUsage"
+	"imageProcessor/internal/http-server/handlers/webhook/createWebhook"
+	"imageProcessor/internal/http-server/handlers/webhook/deleteWebhook"
+	"imageProcessor/internal/http-server/handlers/webhook/listDeliveries"
+	"imageProcessor/internal/http-server/handlers/webhook/listWebhooks"
+	"imageProcessor/internal/http-server/handlers/webhook/redeliverWebhook"
+	"imageProcessor/internal/http-server/middleware/auth"
+	"imageProcessor/internal/http-server/middleware/deadline"
+	"imageProcessor/internal/http-server/middleware/idempotency"
 	"imageProcessor/internal/http-server/middleware/mwlogger"
+	"imageProcessor/internal/http-server/middleware/signature"
+	"imageProcessor/internal/http-server/middleware/throttle"
+	"imageProcessor/internal/http-server/middleware/tusprotocol"
 	"imageProcessor/internal/kafka/consumer"
 	"imageProcessor/internal/kafka/producer"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/lib/imageformat"
 	"imageProcessor/internal/lib/logger/handlers/slogpretty"
 	"imageProcessor/internal/lib/logger/sl"
+	"imageProcessor/internal/lib/quota"
+	"imageProcessor/internal/lib/ratelimit"
+	"imageProcessor/internal/lib/tenant"
+	"imageProcessor/internal/lib/urlsign"
 	"imageProcessor/internal/processor"
+	"imageProcessor/internal/storage/local"
 	"imageProcessor/internal/storage/postgres"
+	"imageProcessor/internal/transformer"
+	"imageProcessor/internal/webhook"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	_ "imageProcessor/docs"
 )
@@ -36,6 +84,9 @@ const (
 // @description     This is a sample image processing API.
 // @host            localhost:8075
 // @BasePath        /
+// @securityDefinitions.apikey  ApiKeyAuth
+// @in                          header
+// @name                        X-API-Key
 func main() {
 	cfg := config.MustLoad()
 
@@ -44,12 +95,66 @@ func main() {
 	log.Info("Starting image processor", slog.String("env", cfg.Env))
 	log.Debug("Debug messages are enabled")
 
-	storage, err := postgres.InitDB(&cfg.Database)
+	storage, err := postgres.InitDB(&cfg.Database, &cfg.Dedup)
 	if err != nil {
 		log.Error("failed to init storage", sl.Err(err))
 		os.Exit(1)
 	}
 
+	if err = tenant.Validate(cfg.Auth.OperatorTenant); err != nil {
+		log.Error("invalid operator tenant", sl.Err(err))
+		os.Exit(1)
+	}
+
+	if cfg.Auth.BootstrapAdminKey != "" {
+		token := cfg.Auth.BootstrapAdminKey
+		err = storage.EnsureAPIKey(context.Background(), cfg.Auth.OperatorTenant, "bootstrap-admin", apikey.Prefix(token), apikey.Hash(token), []string{apikey.ScopeAdmin})
+		if err != nil {
+			log.Error("failed to register bootstrap admin key", sl.Err(err))
+			os.Exit(1)
+		}
+	}
+
+	blobStorage, err := local.New(&cfg.BlobStorage)
+	if err != nil {
+		log.Error("failed to init blob storage", sl.Err(err))
+		os.Exit(1)
+	}
+
+	quotas, err := quota.New(&cfg.Quotas)
+	if err != nil {
+		log.Error("invalid quotas", sl.Err(err))
+		os.Exit(1)
+	}
+
+	rateLimits, err := ratelimit.New(&cfg.RateLimit)
+	if err != nil {
+		log.Error("invalid rate limits", sl.Err(err))
+		os.Exit(1)
+	}
+
+	var limiter throttle.Limiter = ratelimit.NewMemory()
+	if cfg.RateLimit.Backend == ratelimit.BackendPostgres {
+		limiter = storage
+		go pruneRateLimits(log, storage, rateLimits.MaxWindow())
+	}
+
+	limit := func(route string) func(http.Handler) http.Handler {
+		return throttle.New(log, limiter, route, rateLimits.For(route))
+	}
+	timeouts := func(route string) func(http.Handler) http.Handler {
+		return deadline.New(log, route, cfg.HTTPServer.Routes[route])
+	}
+	// An import extends its write deadline by the fetch, on top of the
+	// write timeout of the upload routes, or of the server if they have none.
+	uploadWriteTimeout := cfg.HTTPServer.Timeout
+	if t := cfg.HTTPServer.Routes["upload"].WriteTimeout; t > 0 {
+		uploadWriteTimeout = t
+	}
+
+	idempotent := idempotency.New(log, storage, &cfg.Idempotency)
+	go pruneIdempotencyKeys(log, storage, cfg.Idempotency.CleanupInterval)
+
 	kafkaProducer, err := producer.NewProducer(&cfg.Kafka, log)
 	if err != nil {
 		log.Error("failed to create kafka producer", sl.Err(err))
@@ -62,30 +167,161 @@ func main() {
 		os.Exit(1)
 	}
 
-	imageProcessor := processor.NewImageProcessor(log, storage)
+	formats, err := imageformat.ParseList(cfg.Processing.Formats)
+	if err != nil {
+		log.Error("invalid processing formats", sl.Err(err))
+		os.Exit(1)
+	}
+
+	imageTransformer, err := transformer.New(log, blobStorage, &cfg.Transform, formats[0])
+	if err != nil {
+		log.Error("failed to create image transformer", sl.Err(err))
+		os.Exit(1)
+	}
+
+	urlSigner, err := urlsign.New(&cfg.URLSigning)
+	if err != nil {
+		log.Error("failed to create url signer", sl.Err(err))
+		os.Exit(1)
+	}
+
+	presigner := local.NewPresigner(urlSigner.WithTTL(cfg.Presign.TTL), cfg.BlobStorage.PublicURL)
+
+	urlFetcher, err := fetcher.New(&cfg.Import)
+	if err != nil {
+		log.Error("failed to create url fetcher", sl.Err(err))
+		os.Exit(1)
+	}
+
+	webhookSigner, err := webhook.NewSigner(cfg.Webhooks.SigningKey)
+	if err != nil {
+		log.Error("failed to create webhook signer", sl.Err(err))
+		os.Exit(1)
+	}
+
+	webhookDispatcher, err := webhook.New(log, storage, webhookSigner, urlSigner, &cfg.Webhooks)
+	if err != nil {
+		log.Error("failed to create webhook dispatcher", sl.Err(err))
+		os.Exit(1)
+	}
+
+	imageProcessor, err := processor.NewImageProcessor(log, storage, blobStorage, formats, &cfg.Processing, &cfg.Privacy, webhookDispatcher)
+	if err != nil {
+		log.Error("invalid processing settings", sl.Err(err))
+		os.Exit(1)
+	}
 
 	go kafkaConsumer.ReadMessages(context.Background(), imageProcessor.ProcessMessage)
+	go webhookDispatcher.Run(context.Background())
+
+	hub := events.NewHub()
+	go listenImageEvents(log, storage, hub)
+	go expireTusUploads(log, storage, blobStorage, cfg.Tus.CleanupInterval)
+	go expirePendingUploads(log, storage, blobStorage, cfg.Presign.CleanupInterval)
 
 	router := chi.NewRouter()
 
 	router.Use(middleware.RequestID)
+	if cfg.RateLimit.TrustProxy {
+		router.Use(middleware.RealIP)
+	}
 	router.Use(mwlogger.New(log))
 	router.Use(middleware.Recoverer)
 	router.Use(middleware.URLFormat)
 
 	router.Handle("/", http.FileServer(http.Dir("./static")))
 
-	router.Handle("/processed/*", http.StripPrefix("/processed/", http.FileServer(http.Dir("./processed"))))
-
-	router.Handle("/uploads/*", http.StripPrefix("/uploads/", http.FileServer(http.Dir("./uploads"))))
-
 	router.Get("/swagger/*", httpSwagger.Handler(
 		httpSwagger.URL("http://localhost:8075/swagger/doc.json"),
 	))
 
-	router.Post("/upload", saveImage.New(log, storage, kafkaProducer))
-	router.Get("/image/{id}", getImage.New(log, storage))
-	router.Delete("/image/{id}", deleteImage.New(log, storage))
+	router.Group(func(r chi.Router) {
+		// Requests are throttled by IP address before their key is looked
+		// up, so that missing, wrong and revoked keys are limited too.
+		r.Use(limit("auth"))
+		r.Use(auth.New(log, storage))
+
+		r.With(auth.RequireScope(apikey.ScopeUpload), limit("upload"), timeouts("upload"), idempotent).Post("/upload", saveImage.New(log, storage, blobStorage, quotas, kafkaProducer, cfg.Upload.MaxBodySize))
+		r.With(auth.RequireScope(apikey.ScopeUpload), limit("upload"), timeouts("upload"), idempotent).Post("/upload/url", saveImage.NewFromURL(log, urlFetcher, storage, blobStorage, quotas, kafkaProducer, cfg.Import.Timeout, uploadWriteTimeout))
+		r.With(auth.RequireScope(apikey.ScopeUpload), limit("upload"), timeouts("upload"), idempotent).Post("/uploads/batch", saveBatch.New(log, storage, storage, blobStorage, quotas, kafkaProducer, &cfg.Batch))
+		r.With(auth.RequireScope(apikey.ScopeRead), limit("read")).Get("/uploads/batch/{id}", getBatch.New(log, storage))
+		r.With(auth.RequireScope(apikey.ScopeUpload), limit("upload"), idempotent).Post("/uploads/presign", presignUpload.New(log, storage, presigner, quotas, cfg.Presign.MaxSize))
+		r.With(auth.RequireScope(apikey.ScopeUpload), limit("upload"), timeouts("upload"), idempotent).Post("/uploads/{id}/complete", completeUpload.New(log, storage, blobStorage, kafkaProducer))
+		r.With(auth.RequireScope(apikey.ScopeRead), limit("read")).Get("/image/{id}", getImage.New(log, storage, urlSigner, hub, cfg.Responsive.Sizes, cfg.HTTPServer.MaxWait, cfg.HTTPServer.Timeout))
+		r.With(auth.RequireScope(apikey.ScopeRead), limit("read")).Get("/image/{id}/archive", getArchive.New(log, storage, blobStorage))
+		r.With(auth.RequireScope(apikey.ScopeRead), limit("read")).Get("/image/{id}/similar", findSimilar.New(log, storage))
+		r.With(auth.RequireScope(apikey.ScopeRead), limit("read")).Get("/image/{id}/metadata", getMetadata.New(log, storage))
+		r.With(auth.RequireScope(apikey.ScopeRead), limit("read")).Post("/images/archive", exportImages.New(log, storage, blobStorage))
+		r.With(auth.RequireScope(apikey.ScopeRead), limit("read")).Get("/images/search/geo", searchGeo.New(log, storage))
+		r.With(auth.RequireScope(apikey.ScopeRead), limit("read")).Get("/image/{id}/picture", getPicture.New(log, storage, urlSigner, formats, &cfg.Responsive))
+		r.With(auth.RequireScope(apikey.ScopeRead), limit("read")).Get("/image/{id}/transform/url", signTransform.New(log, storage, imageTransformer, urlSigner))
+		r.With(auth.RequireScope(apikey.ScopeRead), limit("read")).Get("/image/{id}/events", imageEvents.New(log, storage, hub))
+		r.With(auth.RequireScope(apikey.ScopeRead), limit("read")).Get("/events", streamEvents.New(log, hub))
+		r.With(auth.RequireScope(apikey.ScopeDelete), limit("delete"), idempotent).Delete("/image/{id}", deleteImage.New(log, storage, blobStorage))
+		r.With(limit("read")).Get("/usage", getUsage.New(log, storage, quotas))
+
+		// Resumable uploads speak the tus protocol. Chunks are only rate
+		// limited by address, their number being bounded by the upload's
+		// length.
+		r.Route("/uploads/tus", func(r chi.Router) {
+			r.Use(auth.RequireScope(apikey.ScopeUpload))
+			r.Use(tusprotocol.New(log, cfg.Tus.MaxSize))
+			r.Use(idempotent)
+
+			r.With(limit("upload")).Post("/", createUpload.New(log, storage, quotas, cfg.Tus.MaxSize, cfg.Tus.Expiration))
+			r.With(limit("read")).Head("/{id}", getUpload.New(log, storage))
+			r.Patch("/{id}", patchUpload.New(log, storage, blobStorage, quotas, kafkaProducer, cfg.Tus.Expiration, cfg.Tus.ChunkTimeout))
+			r.With(limit("delete")).Delete("/{id}", deleteUpload.New(log, storage, blobStorage))
+		})
+
+		r.Route("/webhooks", func(r chi.Router) {
+			r.Use(auth.RequireScope(apikey.ScopeUpload))
+			r.Use(limit("read"))
+			r.Use(idempotent)
+
+			r.Post("/", createWebhook.New(log, storage, webhookSigner))
+			r.Get("/", listWebhooks.New(log, storage, webhookSigner))
+			r.Delete("/{id}", deleteWebhook.New(log, storage))
+			r.Get("/deliveries", listDeliveries.New(log, storage))
+			r.Post("/deliveries/{id}/redeliver", redeliverWebhook.New(log, storage))
+		})
+
+		// Admin responses aren't made idempotent, as the new keys'
+		// secrets would be kept along with them.
+		r.Route("/admin/keys", func(r chi.Router) {
+			r.Use(auth.RequireScope(apikey.ScopeAdmin))
+			r.Use(limit("admin"))
+
+			r.Post("/", createKey.New(log, storage, cfg.Auth.OperatorTenant))
+			r.Get("/", listKeys.New(log, storage))
+			r.Delete("/{id}", revokeKey.New(log, storage))
+		})
+	})
+
+	// Media is only reachable through signed links handed out by the API.
+	router.Group(func(r chi.Router) {
+		r.Use(signature.New(log, urlSigner))
+		r.Use(limit("media"))
+
+		// The upload itself is left to the archive once a sanitized copy
+		// of it is published.
+		if cfg.Privacy.SanitizeOriginal {
+			r.Get("/image/{id}/original", getOriginal.NewSanitized(log, storage, blobStorage))
+		} else {
+			r.Get("/image/{id}/original", getOriginal.New(log, storage, blobStorage))
+		}
+		r.Get("/image/{id}/variants/{name}", getVariant.New(log, storage, blobStorage))
+		r.Get("/image/{id}/transform", transformImage.New(log, storage, imageTransformer))
+	})
+
+	// Presigned uploads of the local backend, which take the place of an
+	// object store's own URLs.
+	router.Group(func(r chi.Router) {
+		r.Use(signature.New(log, urlSigner))
+		r.Use(timeouts("upload"))
+
+		r.Put(local.BlobPath+"*", putBlob.New(log, blobStorage))
+	})
 
 	log.Info("starting server", slog.String("address", cfg.HTTPServer.Address))
 
@@ -127,6 +363,112 @@ func main() {
 	log.Info("kafka connection closed")
 }
 
+// pruneRateLimits periodically deletes the buckets that have been idle long
+// enough to have refilled.
+func pruneRateLimits(log *slog.Logger, storage *postgres.Storage, idle time.Duration) {
+	if idle == 0 {
+		return
+	}
+
+	for range time.Tick(idle) {
+		n, err := storage.PruneRateLimits(context.Background(), idle)
+		if err != nil {
+			log.Error("failed to prune rate limit buckets", sl.Err(err))
+			continue
+		}
+		log.Debug("pruned rate limit buckets", slog.Int64("count", n))
+	}
+}
+
+// pruneIdempotencyKeys periodically deletes the idempotency keys that have
+// expired.
+func pruneIdempotencyKeys(log *slog.Logger, storage *postgres.Storage, interval time.Duration) {
+	if interval == 0 {
+		return
+	}
+
+	for range time.Tick(interval) {
+		n, err := storage.PruneIdempotencyKeys(context.Background())
+		if err != nil {
+			log.Error("failed to prune idempotency keys", sl.Err(err))
+			continue
+		}
+		log.Debug("pruned idempotency keys", slog.Int64("count", n))
+	}
+}
+
+// listenImageEvents hands the image events of every instance to the hub,
+// starting over whenever listening fails.
+func listenImageEvents(log *slog.Logger, storage *postgres.Storage, hub *events.Hub) {
+	for {
+		err := storage.ListenImageEvents(context.Background(), hub.Publish)
+		if err != nil {
+			log.Error("failed to listen for image events", sl.Err(err))
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// expireTusUploads removes resumable uploads that have expired, together
+// with the chunks received for them.
+func expireTusUploads(log *slog.Logger, storage *postgres.Storage, blobStorage *local.Storage, interval time.Duration) {
+	const batchSize = 100
+
+	for range time.Tick(interval) {
+		for {
+			uploads, err := storage.DeleteExpiredTusUploads(context.Background(), batchSize)
+			if err != nil {
+				log.Error("failed to delete expired uploads", sl.Err(err))
+				break
+			}
+
+			for _, upload := range uploads {
+				for _, chunk := range upload.Chunks {
+					if err := blobStorage.Delete(context.Background(), chunk); err != nil {
+						log.Error("failed to remove chunk of expired upload", slog.String("key", chunk), sl.Err(err))
+					}
+				}
+			}
+
+			if len(uploads) > 0 {
+				log.Info("expired uploads removed", slog.Int("uploads", len(uploads)))
+			}
+			if len(uploads) < batchSize {
+				break
+			}
+		}
+	}
+}
+
+// expirePendingUploads removes presigned uploads that were not completed in
+// time, together with whatever was sent for them.
+func expirePendingUploads(log *slog.Logger, storage *postgres.Storage, blobStorage *local.Storage, interval time.Duration) {
+	const batchSize = 100
+
+	for range time.Tick(interval) {
+		for {
+			keys, err := storage.DeleteExpiredPendingUploads(context.Background(), batchSize)
+			if err != nil {
+				log.Error("failed to delete expired presigned uploads", sl.Err(err))
+				break
+			}
+
+			for _, key := range keys {
+				if err := blobStorage.Delete(context.Background(), key); err != nil {
+					log.Error("failed to remove file of expired presigned upload", slog.String("key", key), sl.Err(err))
+				}
+			}
+
+			if len(keys) > 0 {
+				log.Info("expired presigned uploads removed", slog.Int("uploads", len(keys)))
+			}
+			if len(keys) < batchSize {
+				break
+			}
+		}
+	}
+}
+
 func setupLogger(env string) *slog.Logger {
 	var log *slog.Logger
 
@@ -8,10 +8,27 @@ import (
 )
 
 type Config struct {
-	Env        string     `yaml:"env" env-default:"local"`
-	Database   Database   `yaml:"database"`
-	HTTPServer HTTPServer `yaml:"http_server"`
-	Kafka      Kafka      `yaml:"kafka"`
+	Env         string      `yaml:"env" env-default:"local"`
+	Database    Database    `yaml:"database"`
+	HTTPServer  HTTPServer  `yaml:"http_server"`
+	Kafka       Kafka       `yaml:"kafka"`
+	BlobStorage BlobStorage `yaml:"blob_storage"`
+	Processing  Processing  `yaml:"processing"`
+	Transform   Transform   `yaml:"transform"`
+	URLSigning  URLSigning  `yaml:"url_signing"`
+	Auth        Auth        `yaml:"auth"`
+	Quotas      Quotas      `yaml:"quotas"`
+	RateLimit   RateLimit   `yaml:"rate_limit"`
+	Webhooks    Webhooks    `yaml:"webhooks"`
+	Batch       Batch       `yaml:"batch"`
+	Import      Import      `yaml:"import"`
+	Tus         Tus         `yaml:"tus"`
+	Upload      Upload      `yaml:"upload"`
+	Presign     Presign     `yaml:"presign"`
+	Dedup       Dedup       `yaml:"dedup"`
+	Idempotency Idempotency `yaml:"idempotency"`
+	Privacy     Privacy     `yaml:"privacy"`
+	Responsive  Responsive  `yaml:"responsive"`
 }
 
 type Database struct {
@@ -27,6 +44,19 @@ type HTTPServer struct {
 	Address     string        `yaml:"address" env-default:"localhost:8075"`
 	Timeout     time.Duration `yaml:"timeout" env-default:"4s"`
 	IdleTimeout time.Duration `yaml:"idle_timeout" env-default:"60s"`
+	// MaxWait caps how long GET /image/{id}?wait= may block. Such requests
+	// are exempt from Timeout for the time they wait.
+	MaxWait time.Duration `yaml:"max_wait" env-default:"60s"`
+	// Routes gives route groups, such as upload, their own read and write
+	// timeouts in place of Timeout.
+	Routes map[string]RouteTimeouts `yaml:"routes"`
+}
+
+// RouteTimeouts start when the handler does. A timeout left at zero keeps
+// the server's.
+type RouteTimeouts struct {
+	ReadTimeout  time.Duration `yaml:"read_timeout"`
+	WriteTimeout time.Duration `yaml:"write_timeout"`
 }
 
 type Kafka struct {
@@ -37,6 +67,214 @@ type Kafka struct {
 	MaxPollRecords  int      `yaml:"max_poll_records" env-default:"1"`
 }
 
+type BlobStorage struct {
+	Root string `yaml:"root" env-default:"./data"`
+	// PublicURL is the address presigned uploads are sent to. The local
+	// backend is served by the API itself under /blobs.
+	PublicURL string `yaml:"public_url" env-default:"http://localhost:8075"`
+}
+
+type Processing struct {
+	// Formats every variant is encoded in. The first one is the default
+	// served to clients that don't express a preference.
+	Formats []string `yaml:"formats" env-default:"jpeg"`
+	// KeepOrientation lists the variants (resize, thumbnail, watermark)
+	// rendered from the original as stored, without turning it upright by
+	// its EXIF orientation first.
+	KeepOrientation []string `yaml:"keep_orientation"`
+	// Widths is the ladder of widths, in pixels, images are also rendered
+	// at for srcset attributes. Widths beyond that of the original are
+	// left out, the original's own width taking their place.
+	Widths []int `yaml:"widths" env-default:"320,640,960,1280,1920"`
+}
+
+type Transform struct {
+	// AllowedSizes lists the "WxH" boxes clients may ask for, 0 standing for
+	// an unconstrained side. An empty list allows any size up to MaxDimension.
+	AllowedSizes   []string `yaml:"allowed_sizes"`
+	MaxDimension   int      `yaml:"max_dimension" env-default:"4096"`
+	DefaultQuality int      `yaml:"default_quality" env-default:"85"`
+	// MaxCacheSize caps the bytes of renditions cached per image. Renditions
+	// beyond it are rendered on every request; 0 turns the cache off.
+	MaxCacheSize int64 `yaml:"max_cache_size" env-default:"104857600"`
+}
+
+type URLSigning struct {
+	// Keys maps key IDs to secrets. URLs are signed with ActiveKey, while any
+	// listed key is accepted, so a key can be rotated out once the URLs it
+	// signed have expired.
+	Keys      map[string]string `yaml:"keys" env-required:"true"`
+	ActiveKey string            `yaml:"active_key" env-required:"true"`
+	TTL       time.Duration     `yaml:"ttl" env-default:"1h"`
+}
+
+type Auth struct {
+	// BootstrapAdminKey, when set, is registered as an admin API key on
+	// startup so that the first keys can be created through the API.
+	BootstrapAdminKey string `yaml:"bootstrap_admin_key" env:"AUTH_BOOTSTRAP_ADMIN_KEY"`
+	// OperatorTenant owns the bootstrap key. Its admins may create keys for
+	// other tenants; everything else stays confined to a single tenant.
+	OperatorTenant string `yaml:"operator_tenant" env-default:"default"`
+}
+
+// Quotas limit what a tenant, and optionally a single API key, may store and
+// how fast it may upload. Zero means unlimited.
+type Quotas struct {
+	Default QuotaLimits            `yaml:"default"`
+	Tenants map[string]QuotaLimits `yaml:"tenants"`
+	// Keys are indexed by API key ID.
+	Keys map[string]QuotaLimits `yaml:"keys"`
+}
+
+type QuotaLimits struct {
+	MaxBytes       int64 `yaml:"max_bytes"`
+	MaxImages      int64 `yaml:"max_images"`
+	UploadsPerHour int   `yaml:"uploads_per_hour"`
+}
+
+// RateLimit throttles each client, identified by its API key or, on routes
+// without one, by its IP address, with a token bucket per route group.
+type RateLimit struct {
+	// Backend keeps the buckets in "memory" or in "postgres", where they are
+	// shared by every replica.
+	Backend string `yaml:"backend" env-default:"memory"`
+	// TrustProxy takes the client IP from X-Forwarded-For and X-Real-IP.
+	// Only enable it behind a proxy that sets them.
+	TrustProxy bool `yaml:"trust_proxy"`
+	// Routes are indexed by route group: upload, read, delete, admin and
+	// media, and auth, which limits every request to the API by IP address
+	// before its key is checked. A group without an entry is not limited.
+	Routes map[string]RateLimitRule `yaml:"routes"`
+}
+
+// RateLimitRule allows bursts of Limit requests, refilled evenly over Window.
+type RateLimitRule struct {
+	Limit  int           `yaml:"limit"`
+	Window time.Duration `yaml:"window"`
+}
+
+// Webhooks notify clients when their images have been processed or failed.
+type Webhooks struct {
+	// SigningKey derives the secret the events of each API key are signed
+	// with.
+	SigningKey string `yaml:"signing_key" env-required:"true"`
+	// PublicURL is the address of the API the media links in events start
+	// with.
+	PublicURL string        `yaml:"public_url" env-default:"http://localhost:8075"`
+	Timeout   time.Duration `yaml:"timeout" env-default:"10s"`
+	// A failed delivery is retried up to MaxAttempts in total, waiting twice
+	// as long each time, from InitialBackoff up to MaxBackoff.
+	MaxAttempts    int           `yaml:"max_attempts" env-default:"8"`
+	InitialBackoff time.Duration `yaml:"initial_backoff" env-default:"10s"`
+	MaxBackoff     time.Duration `yaml:"max_backoff" env-default:"1h"`
+	// Due deliveries are picked up every PollInterval, BatchSize at a time.
+	PollInterval time.Duration `yaml:"poll_interval" env-default:"2s"`
+	BatchSize    int           `yaml:"batch_size" env-default:"20"`
+	// AllowedNetworks lists CIDRs that receivers may be reached in even
+	// though they are loopback, private or otherwise internal, which are
+	// refused by default.
+	AllowedNetworks []string `yaml:"allowed_networks"`
+}
+
+// Upload bounds POST /upload, which streams the image to the blob store as
+// it arrives.
+type Upload struct {
+	// MaxBodySize caps the whole request body, form fields included.
+	MaxBodySize int64 `yaml:"max_body_size" env-default:"52428800"`
+}
+
+// Presign configures uploads sent straight to the blob backend through
+// POST /uploads/presign.
+type Presign struct {
+	MaxSize int64 `yaml:"max_size" env-default:"5368709120"`
+	// TTL is how long the signed URL is valid, and how long the upload may
+	// take to complete before it is removed. Expired uploads are looked for
+	// every CleanupInterval.
+	TTL             time.Duration `yaml:"ttl" env-default:"15m"`
+	CleanupInterval time.Duration `yaml:"cleanup_interval" env-default:"5m"`
+}
+
+// Dedup says what becomes of an upload whose content the tenant has stored
+// already: "existing" answers with the uploader's image of it, "share"
+// records a new image sharing its blobs, "reject" refuses the upload.
+type Dedup struct {
+	Mode string `yaml:"mode" env-default:"share"`
+}
+
+// Idempotency configures the Idempotency-Key header of POST and DELETE
+// requests.
+type Idempotency struct {
+	// TTL is how long a response is kept to be replayed.
+	TTL time.Duration `yaml:"ttl" env-default:"24h"`
+	// LockTimeout is how long a request may stay in progress before its key
+	// is taken to have been abandoned. It should outlast the longest route
+	// timeout.
+	LockTimeout time.Duration `yaml:"lock_timeout" env-default:"15m"`
+	// MaxResponseSize caps the responses kept; larger ones, such as
+	// archives, are not replayed.
+	MaxResponseSize int           `yaml:"max_response_size" env-default:"1048576"`
+	CleanupInterval time.Duration `yaml:"cleanup_interval" env-default:"1h"`
+}
+
+// Batch bounds POST /uploads/batch. The limits apply to the files sent and
+// to the entries of ZIP archives alike.
+type Batch struct {
+	MaxFiles     int   `yaml:"max_files" env-default:"500"`
+	MaxFileSize  int64 `yaml:"max_file_size" env-default:"52428800"`
+	MaxTotalSize int64 `yaml:"max_total_size" env-default:"1073741824"`
+}
+
+// Import bounds POST /upload/url, which fetches images from remote origins.
+type Import struct {
+	// Timeout covers the whole download, redirects included.
+	Timeout      time.Duration `yaml:"timeout" env-default:"30s"`
+	MaxSize      int64         `yaml:"max_size" env-default:"52428800"`
+	MaxRedirects int           `yaml:"max_redirects" env-default:"3"`
+	// ContentTypes lists the media types an origin may answer with.
+	ContentTypes []string `yaml:"content_types" env-default:"image/jpeg,image/png,image/gif,image/tiff,image/bmp"`
+	// AllowedNetworks lists CIDRs that may be fetched from even though they
+	// are loopback, private or otherwise internal, which are refused by
+	// default.
+	AllowedNetworks []string `yaml:"allowed_networks"`
+}
+
+// Tus configures the resumable uploads under /uploads/tus.
+type Tus struct {
+	MaxSize int64 `yaml:"max_size" env-default:"1073741824"`
+	// An upload that sees no chunk for Expiration is removed along with the
+	// chunks received so far. Expired uploads are looked for every
+	// CleanupInterval.
+	Expiration      time.Duration `yaml:"expiration" env-default:"24h"`
+	CleanupInterval time.Duration `yaml:"cleanup_interval" env-default:"10m"`
+	// ChunkTimeout replaces the server's timeouts for PATCH requests, which
+	// may take long to send on slow links.
+	ChunkTimeout time.Duration `yaml:"chunk_timeout" env-default:"10m"`
+}
+
+// Privacy decides how much of what uploads carry about where and by whom
+// they were taken is published.
+type Privacy struct {
+	// Metadata is what metadata of the original the variants carry:
+	// "strip" none, "copyright" the author and copyright notice, "keep" all
+	// of it, the GPS position included.
+	Metadata string `yaml:"metadata" env-default:"strip"`
+	// SanitizeOriginal publishes a copy of the original encoded anew
+	// without metadata at the link of the original, in place of the upload
+	// itself.
+	SanitizeOriginal bool `yaml:"sanitize_original"`
+}
+
+// Responsive configures the srcset attributes and <picture> elements built
+// from the width variants.
+type Responsive struct {
+	// Sizes is the sizes attribute suggested along with srcset, unless a
+	// request for a <picture> element gives its own.
+	Sizes string `yaml:"sizes" env-default:"100vw"`
+	// PublicURL is the address of the API the links in <picture> elements
+	// start with.
+	PublicURL string `yaml:"public_url" env-default:"http://localhost:8075"`
+}
+
 func MustLoad() *Config {
 	path := fetchConfigPath()
 
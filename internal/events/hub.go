@@ -0,0 +1,109 @@
+package events
+
+import (
+	"github.com/google/uuid"
+	"imageProcessor/internal/models"
+	"sync"
+)
+
+// bufferSize is how many events a subscriber may fall behind by before it
+// is dropped.
+const bufferSize = 64
+
+// Filter selects the events a subscriber receives. The tenant must always
+// match; ImageID and OwnerKeyID narrow the events down further when set.
+type Filter struct {
+	TenantID   string
+	ImageID    *uuid.UUID
+	OwnerKeyID *uuid.UUID
+}
+
+func (f Filter) matches(e models.ImageEvent) bool {
+	if e.TenantID != f.TenantID {
+		return false
+	}
+	if f.ImageID != nil && e.ImageID != *f.ImageID {
+		return false
+	}
+	if f.OwnerKeyID != nil && (e.OwnerKeyID == nil || *e.OwnerKeyID != *f.OwnerKeyID) {
+		return false
+	}
+
+	return true
+}
+
+// Hub fans the image events heard by this instance out to its subscribers,
+// the event streams of the clients connected to it.
+type Hub struct {
+	mu   sync.Mutex
+	subs map[*Subscription]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{subs: make(map[*Subscription]struct{})}
+}
+
+// Subscription receives the events matching its filter on C until it is
+// closed. C is also closed when the subscriber falls too far behind, since
+// skipping events silently would leave it with a wrong picture.
+type Subscription struct {
+	C <-chan models.ImageEvent
+
+	c      chan models.ImageEvent
+	filter Filter
+	hub    *Hub
+}
+
+func (h *Hub) Subscribe(f Filter) *Subscription {
+	c := make(chan models.ImageEvent, bufferSize)
+	sub := &Subscription{C: c, c: c, filter: f, hub: h}
+
+	h.mu.Lock()
+	h.subs[sub] = struct{}{}
+	h.mu.Unlock()
+
+	return sub
+}
+
+// Close stops the subscription. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+
+	s.hub.remove(s)
+}
+
+// Publish hands e to every subscriber it matches without waiting for any.
+func (h *Hub) Publish(e models.ImageEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.subs {
+		if !sub.filter.matches(e) {
+			continue
+		}
+
+		select {
+		case sub.c <- e:
+		default:
+			h.remove(sub)
+		}
+	}
+}
+
+// Subscribers returns the number of open subscriptions.
+func (h *Hub) Subscribers() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return len(h.subs)
+}
+
+func (h *Hub) remove(sub *Subscription) {
+	if _, ok := h.subs[sub]; !ok {
+		return
+	}
+
+	delete(h.subs, sub)
+	close(sub.c)
+}
@@ -0,0 +1,127 @@
+package events_test
+
+import (
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"imageProcessor/internal/events"
+	"imageProcessor/internal/models"
+	"testing"
+)
+
+func TestHubFilters(t *testing.T) {
+	imageID := uuid.New()
+	ownerID := uuid.New()
+
+	tests := []struct {
+		name     string
+		filter   events.Filter
+		event    models.ImageEvent
+		expected bool
+	}{
+		{
+			name:     "Same Image",
+			filter:   events.Filter{TenantID: "shop", ImageID: &imageID},
+			event:    models.ImageEvent{TenantID: "shop", ImageID: imageID},
+			expected: true,
+		},
+		{
+			name:     "Other Image",
+			filter:   events.Filter{TenantID: "shop", ImageID: &imageID},
+			event:    models.ImageEvent{TenantID: "shop", ImageID: uuid.New()},
+			expected: false,
+		},
+		{
+			name:     "Other Tenant",
+			filter:   events.Filter{TenantID: "shop", ImageID: &imageID},
+			event:    models.ImageEvent{TenantID: "other", ImageID: imageID},
+			expected: false,
+		},
+		{
+			name:     "Whole Tenant",
+			filter:   events.Filter{TenantID: "shop"},
+			event:    models.ImageEvent{TenantID: "shop", ImageID: uuid.New()},
+			expected: true,
+		},
+		{
+			name:     "Same Owner",
+			filter:   events.Filter{TenantID: "shop", OwnerKeyID: &ownerID},
+			event:    models.ImageEvent{TenantID: "shop", ImageID: imageID, OwnerKeyID: &ownerID},
+			expected: true,
+		},
+		{
+			name:     "Other Owner",
+			filter:   events.Filter{TenantID: "shop", OwnerKeyID: &ownerID},
+			event:    models.ImageEvent{TenantID: "shop", ImageID: imageID, OwnerKeyID: new(uuid.UUID)},
+			expected: false,
+		},
+		{
+			name:     "No Owner",
+			filter:   events.Filter{TenantID: "shop", OwnerKeyID: &ownerID},
+			event:    models.ImageEvent{TenantID: "shop", ImageID: imageID},
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := events.NewHub()
+			sub := hub.Subscribe(tt.filter)
+			defer sub.Close()
+
+			hub.Publish(tt.event)
+
+			select {
+			case e := <-sub.C:
+				require.True(t, tt.expected, "unexpected event")
+				require.Equal(t, tt.event, e)
+			default:
+				require.False(t, tt.expected, "event not delivered")
+			}
+		})
+	}
+}
+
+func TestHubDropsSlowSubscribers(t *testing.T) {
+	hub := events.NewHub()
+
+	slow := hub.Subscribe(events.Filter{TenantID: "shop"})
+	fast := hub.Subscribe(events.Filter{TenantID: "shop"})
+	defer fast.Close()
+
+	received := 0
+	for i := 0; i < 100; i++ {
+		hub.Publish(models.ImageEvent{TenantID: "shop", ImageID: uuid.New()})
+
+		<-fast.C
+		received++
+	}
+	require.Equal(t, 100, received)
+
+	// The slow subscriber is closed once its buffer is full, after getting
+	// every event up to that point.
+	buffered := 0
+	for range slow.C {
+		buffered++
+	}
+	require.Less(t, buffered, 100)
+	require.Equal(t, 1, hub.Subscribers())
+
+	slow.Close()
+	require.Equal(t, 1, hub.Subscribers())
+}
+
+func TestSubscriptionClose(t *testing.T) {
+	hub := events.NewHub()
+
+	sub := hub.Subscribe(events.Filter{TenantID: "shop"})
+	require.Equal(t, 1, hub.Subscribers())
+
+	sub.Close()
+	sub.Close()
+	require.Equal(t, 0, hub.Subscribers())
+
+	_, ok := <-sub.C
+	require.False(t, ok)
+
+	hub.Publish(models.ImageEvent{TenantID: "shop"})
+}
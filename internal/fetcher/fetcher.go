@@ -0,0 +1,183 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"imageProcessor/internal/config"
+	"imageProcessor/internal/lib/netguard"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidURL       = errors.New("invalid url")
+	ErrForbiddenAddress = netguard.ErrForbiddenAddress
+	ErrTooManyRedirects = errors.New("too many redirects")
+	ErrBadStatus        = errors.New("unexpected response status")
+	ErrContentType      = errors.New("unsupported content type")
+	ErrTooLarge         = errors.New("image too large")
+)
+
+// extensions name the files of origins whose URL doesn't.
+var extensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/tiff": ".tiff",
+	"image/bmp":  ".bmp",
+}
+
+// Image is a download in progress.
+type Image struct {
+	// Body fails with ErrTooLarge once more than the configured maximum has
+	// been read from it. It must be closed.
+	Body        io.ReadCloser
+	Filename    string
+	ContentType string
+	// Size is the length announced by the origin, or -1 if it didn't.
+	Size int64
+}
+
+// Fetcher downloads images from remote origins on behalf of clients. Every
+// connection, redirects included, is checked after the host name has been
+// resolved, so that the service can't be pointed at the networks behind it.
+type Fetcher struct {
+	client       *http.Client
+	maxSize      int64
+	contentTypes []string
+}
+
+func New(cfg *config.Import) (*Fetcher, error) {
+	const op = "fetcher.New"
+
+	allowed, err := netguard.ParseNetworks(cfg.AllowedNetworks)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	contentTypes := make([]string, len(cfg.ContentTypes))
+	for i, contentType := range cfg.ContentTypes {
+		contentTypes[i] = strings.ToLower(strings.TrimSpace(contentType))
+	}
+
+	return &Fetcher{
+		client: &http.Client{
+			Timeout: cfg.Timeout,
+			// No Proxy: a proxy would make the connections the dialer checks.
+			Transport: &http.Transport{
+				DialContext:           netguard.Dialer(10*time.Second, allowed).DialContext,
+				ForceAttemptHTTP2:     true,
+				MaxIdleConns:          10,
+				IdleConnTimeout:       90 * time.Second,
+				TLSHandshakeTimeout:   10 * time.Second,
+				ResponseHeaderTimeout: cfg.Timeout,
+			},
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) > cfg.MaxRedirects {
+					return ErrTooManyRedirects
+				}
+				if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+					return ErrInvalidURL
+				}
+				return nil
+			},
+		},
+		maxSize:      cfg.MaxSize,
+		contentTypes: contentTypes,
+	}, nil
+}
+
+// Fetch requests rawURL and checks the response before any of the body is
+// read. The download itself happens as Body is read, within the configured
+// timeout.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (*Image, error) {
+	const op = "fetcher.Fetch"
+
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidURL)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidURL)
+	}
+	req.Header.Set("Accept", strings.Join(f.contentTypes, ", "))
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	image, err := f.check(resp)
+	if err != nil {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return image, nil
+}
+
+func (f *Fetcher) check(resp *http.Response) (*Image, error) {
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %d", ErrBadStatus, resp.StatusCode)
+	}
+
+	contentType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || !slices.Contains(f.contentTypes, contentType) {
+		return nil, fmt.Errorf("%w: %q", ErrContentType, resp.Header.Get("Content-Type"))
+	}
+
+	if resp.ContentLength > f.maxSize {
+		return nil, ErrTooLarge
+	}
+
+	return &Image{
+		Body:        &limitedBody{ReadCloser: resp.Body, remaining: f.maxSize},
+		Filename:    filename(resp.Request.URL, contentType),
+		ContentType: contentType,
+		Size:        resp.ContentLength,
+	}, nil
+}
+
+// filename takes the last segment of the URL the image was served from,
+// falling back to a name derived from its type.
+func filename(u *url.URL, contentType string) string {
+	name := path.Base(u.Path)
+	if name == "." || name == "/" {
+		name = "image"
+	}
+	if path.Ext(name) == "" {
+		name += extensions[contentType]
+	}
+
+	return name
+}
+
+// limitedBody fails instead of quietly stopping once the limit has been
+// passed, so that a truncated image is never stored.
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		return n + int(b.remaining), ErrTooLarge
+	}
+
+	return n, err
+}
@@ -0,0 +1,195 @@
+package fetcher_test
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/require"
+	"imageProcessor/internal/config"
+	"imageProcessor/internal/fetcher"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFetch(t *testing.T) {
+	image := []byte("image content")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/photos/cat.jpg", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/jpeg")
+		_, _ = w.Write(image)
+	})
+	mux.HandleFunc("/render", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png; charset=binary")
+		_, _ = w.Write(image)
+	})
+	mux.HandleFunc("/page.html", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte("<html></html>"))
+	})
+	mux.HandleFunc("/big.jpg", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/jpeg")
+		_, _ = w.Write(bytes.Repeat([]byte("x"), 101))
+	})
+	mux.HandleFunc("/chunked.jpg", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/jpeg")
+		for range 11 {
+			_, _ = w.Write(bytes.Repeat([]byte("x"), 10))
+			w.(http.Flusher).Flush()
+		}
+	})
+	mux.HandleFunc("/missing.jpg", func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/photos/cat.jpg", http.StatusFound)
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	mux.HandleFunc("/internal", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://10.255.255.1/cat.jpg", http.StatusFound)
+	})
+	mux.HandleFunc("/slow.jpg", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		w.Header().Set("Content-Type", "image/jpeg")
+		_, _ = w.Write(image)
+	})
+
+	origin := httptest.NewServer(mux)
+	defer origin.Close()
+
+	cfg := config.Import{
+		Timeout:         100 * time.Millisecond,
+		MaxSize:         100,
+		MaxRedirects:    3,
+		ContentTypes:    []string{"image/jpeg", "image/png"},
+		AllowedNetworks: []string{"127.0.0.0/8"},
+	}
+
+	tests := []struct {
+		name             string
+		url              string
+		allowedNetworks  []string
+		expectedErr      error
+		expectedFilename string
+		expectedBodyErr  error
+	}{
+		{
+			name:             "Success",
+			url:              origin.URL + "/photos/cat.jpg",
+			expectedFilename: "cat.jpg",
+		},
+		{
+			name:             "Name From Content Type",
+			url:              origin.URL + "/render",
+			expectedFilename: "render.png",
+		},
+		{
+			name:             "Redirect",
+			url:              origin.URL + "/redirect",
+			expectedFilename: "cat.jpg",
+		},
+		{
+			name:        "Too Many Redirects",
+			url:         origin.URL + "/loop",
+			expectedErr: fetcher.ErrTooManyRedirects,
+		},
+		{
+			name:            "Loopback Not Allowed",
+			url:             origin.URL + "/photos/cat.jpg",
+			allowedNetworks: []string{},
+			expectedErr:     fetcher.ErrForbiddenAddress,
+		},
+		{
+			name:            "Network Not Allowed",
+			url:             origin.URL + "/photos/cat.jpg",
+			allowedNetworks: []string{"127.0.0.2/32"},
+			expectedErr:     fetcher.ErrForbiddenAddress,
+		},
+		{
+			name:        "Redirect to Internal Address",
+			url:         origin.URL + "/internal",
+			expectedErr: fetcher.ErrForbiddenAddress,
+		},
+		{
+			name:        "Metadata Endpoint",
+			url:         "http://169.254.169.254/latest/meta-data/",
+			expectedErr: fetcher.ErrForbiddenAddress,
+		},
+		{
+			name:        "Unspecified Address",
+			url:         "http://0.0.0.0/cat.jpg",
+			expectedErr: fetcher.ErrForbiddenAddress,
+		},
+		{
+			name:        "Invalid Scheme",
+			url:         "file:///etc/passwd",
+			expectedErr: fetcher.ErrInvalidURL,
+		},
+		{
+			name:        "Not an Image",
+			url:         origin.URL + "/page.html",
+			expectedErr: fetcher.ErrContentType,
+		},
+		{
+			name:        "Not Found",
+			url:         origin.URL + "/missing.jpg",
+			expectedErr: fetcher.ErrBadStatus,
+		},
+		{
+			name:        "Announced Too Large",
+			url:         origin.URL + "/big.jpg",
+			expectedErr: fetcher.ErrTooLarge,
+		},
+		{
+			name:             "Streamed Too Large",
+			url:              origin.URL + "/chunked.jpg",
+			expectedFilename: "chunked.jpg",
+			expectedBodyErr:  fetcher.ErrTooLarge,
+		},
+		{
+			name:        "Timeout",
+			url:         origin.URL + "/slow.jpg",
+			expectedErr: context.DeadlineExceeded,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := cfg
+			if tt.allowedNetworks != nil {
+				cfg.AllowedNetworks = tt.allowedNetworks
+			}
+
+			f, err := fetcher.New(&cfg)
+			require.NoError(t, err)
+
+			result, err := f.Fetch(context.Background(), tt.url)
+			if tt.expectedErr != nil {
+				require.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			defer result.Body.Close()
+
+			require.Equal(t, tt.expectedFilename, result.Filename)
+
+			data, err := io.ReadAll(result.Body)
+			if tt.expectedBodyErr != nil {
+				require.ErrorIs(t, err, tt.expectedBodyErr)
+				require.LessOrEqual(t, len(data), 100)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, image, data)
+		})
+	}
+}
+
+func TestNewInvalidNetwork(t *testing.T) {
+	_, err := fetcher.New(&config.Import{AllowedNetworks: []string{"not a network"}})
+	require.Error(t, err)
+}
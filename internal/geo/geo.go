@@ -0,0 +1,111 @@
+package geo
+
+import "math"
+
+// EarthRadiusKm is the mean radius of the Earth. Distances are measured on
+// a sphere of that radius, which is off by at most half a percent.
+const EarthRadiusKm = 6371.0088
+
+// Point is a position in degrees, north and east being positive.
+type Point struct {
+	Lat float64
+	Lon float64
+}
+
+func (p Point) Valid() bool {
+	return p.Lat >= -90 && p.Lat <= 90 && p.Lon >= -180 && p.Lon <= 180
+}
+
+// Box spans the latitudes from MinLat to MaxLat and the longitudes east of
+// MinLon up to MaxLon. A box with MinLon greater than MaxLon crosses the
+// antimeridian.
+type Box struct {
+	MinLat float64
+	MinLon float64
+	MaxLat float64
+	MaxLon float64
+}
+
+func (b Box) Valid() bool {
+	return Point{b.MinLat, b.MinLon}.Valid() && Point{b.MaxLat, b.MaxLon}.Valid() && b.MinLat <= b.MaxLat
+}
+
+// CrossesAntimeridian tells whether the box spans longitude 180.
+func (b Box) CrossesAntimeridian() bool {
+	return b.MinLon > b.MaxLon
+}
+
+func (b Box) Contains(p Point) bool {
+	if p.Lat < b.MinLat || p.Lat > b.MaxLat {
+		return false
+	}
+	if b.CrossesAntimeridian() {
+		return p.Lon >= b.MinLon || p.Lon <= b.MaxLon
+	}
+
+	return p.Lon >= b.MinLon && p.Lon <= b.MaxLon
+}
+
+func (b Box) Center() Point {
+	lon := (b.MinLon + b.MaxLon) / 2
+	if b.CrossesAntimeridian() {
+		lon += 180
+		if lon > 180 {
+			lon -= 360
+		}
+	}
+
+	return Point{Lat: (b.MinLat + b.MaxLat) / 2, Lon: lon}
+}
+
+// Around returns the smallest box holding every point at most radiusKm from
+// center. It spans all longitudes if the circle holds a pole.
+func Around(center Point, radiusKm float64) Box {
+	angle := radiusKm / EarthRadiusKm
+	dLat := degrees(angle)
+
+	box := Box{MinLat: center.Lat - dLat, MaxLat: center.Lat + dLat, MinLon: -180, MaxLon: 180}
+	if box.MinLat <= -90 || box.MaxLat >= 90 {
+		box.MinLat = max(box.MinLat, -90)
+		box.MaxLat = min(box.MaxLat, 90)
+		return box
+	}
+
+	// The meridians the circle touches are nearer to each other than its
+	// center's parallel suggests: asin(sin(angle) / cos(lat)) rather than
+	// angle / cos(lat).
+	ratio := math.Sin(angle) / math.Cos(radians(center.Lat))
+	if ratio >= 1 {
+		return box
+	}
+
+	dLon := degrees(math.Asin(ratio))
+	box.MinLon = center.Lon - dLon
+	box.MaxLon = center.Lon + dLon
+	if box.MinLon < -180 {
+		box.MinLon += 360
+	}
+	if box.MaxLon > 180 {
+		box.MaxLon -= 360
+	}
+
+	return box
+}
+
+// Distance returns the great-circle distance between a and b in
+// kilometres, by the haversine formula.
+func Distance(a, b Point) float64 {
+	sinLat := math.Sin(radians(b.Lat-a.Lat) / 2)
+	sinLon := math.Sin(radians(b.Lon-a.Lon) / 2)
+	h := sinLat*sinLat + math.Cos(radians(a.Lat))*math.Cos(radians(b.Lat))*sinLon*sinLon
+
+	return 2 * EarthRadiusKm * math.Asin(math.Sqrt(min(h, 1)))
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func degrees(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
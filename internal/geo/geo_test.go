@@ -0,0 +1,201 @@
+package geo_test
+
+import (
+	"github.com/stretchr/testify/require"
+	"imageProcessor/internal/geo"
+	"math"
+	"testing"
+)
+
+// destination returns the point distanceKm from start heading bearing
+// degrees clockwise from north.
+func destination(start geo.Point, bearing, distanceKm float64) geo.Point {
+	lat, lon := start.Lat*math.Pi/180, start.Lon*math.Pi/180
+	angle, theta := distanceKm/geo.EarthRadiusKm, bearing*math.Pi/180
+
+	lat2 := math.Asin(math.Sin(lat)*math.Cos(angle) + math.Cos(lat)*math.Sin(angle)*math.Cos(theta))
+	lon2 := lon + math.Atan2(math.Sin(theta)*math.Sin(angle)*math.Cos(lat), math.Cos(angle)-math.Sin(lat)*math.Sin(lat2))
+
+	lon2 = math.Mod(lon2+3*math.Pi, 2*math.Pi) - math.Pi
+
+	return geo.Point{Lat: lat2 * 180 / math.Pi, Lon: lon2 * 180 / math.Pi}
+}
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     geo.Point
+		expected float64
+	}{
+		{
+			name:     "Same Point",
+			a:        geo.Point{Lat: 48.8566, Lon: 2.3522},
+			b:        geo.Point{Lat: 48.8566, Lon: 2.3522},
+			expected: 0,
+		},
+		{
+			name:     "Paris London",
+			a:        geo.Point{Lat: 48.8566, Lon: 2.3522},
+			b:        geo.Point{Lat: 51.5074, Lon: -0.1278},
+			expected: 343.6,
+		},
+		{
+			name:     "Across The Antimeridian",
+			a:        geo.Point{Lat: 0, Lon: 179.5},
+			b:        geo.Point{Lat: 0, Lon: -179.5},
+			expected: 111.2,
+		},
+		{
+			name:     "Antipodes",
+			a:        geo.Point{Lat: 90, Lon: 0},
+			b:        geo.Point{Lat: -90, Lon: 0},
+			expected: math.Pi * geo.EarthRadiusKm,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.InDelta(t, tt.expected, geo.Distance(tt.a, tt.b), 0.1)
+			require.InDelta(t, tt.expected, geo.Distance(tt.b, tt.a), 0.1)
+		})
+	}
+}
+
+func TestAround(t *testing.T) {
+	tests := []struct {
+		name          string
+		center        geo.Point
+		radiusKm      float64
+		allLongitudes bool
+		crosses       bool
+	}{
+		{
+			name:     "City",
+			center:   geo.Point{Lat: 48.8566, Lon: 2.3522},
+			radiusKm: 10,
+		},
+		{
+			name:     "Equator",
+			center:   geo.Point{Lat: 0, Lon: 0},
+			radiusKm: 1000,
+		},
+		{
+			name:     "Antimeridian",
+			center:   geo.Point{Lat: -17.7, Lon: 178.9},
+			radiusKm: 300,
+			crosses:  true,
+		},
+		{
+			name:     "Antimeridian From The West",
+			center:   geo.Point{Lat: 65, Lon: -179},
+			radiusKm: 200,
+			crosses:  true,
+		},
+		{
+			name:          "Pole",
+			center:        geo.Point{Lat: 89, Lon: 30},
+			radiusKm:      200,
+			allLongitudes: true,
+		},
+		{
+			name:     "Far North",
+			center:   geo.Point{Lat: 80, Lon: 30},
+			radiusKm: 1100,
+		},
+		{
+			name:          "Half The Earth",
+			center:        geo.Point{Lat: 10, Lon: 10},
+			radiusKm:      15000,
+			allLongitudes: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			box := geo.Around(tt.center, tt.radiusKm)
+
+			require.True(t, box.Valid())
+			require.Equal(t, tt.crosses, box.CrossesAntimeridian())
+			if tt.allLongitudes {
+				require.Equal(t, -180.0, box.MinLon)
+				require.Equal(t, 180.0, box.MaxLon)
+			}
+			require.True(t, box.Contains(tt.center))
+
+			// The circle touches the box without leaving it.
+			for bearing := 0.0; bearing < 360; bearing += 1 {
+				p := destination(tt.center, bearing, tt.radiusKm*0.9999)
+				require.True(t, box.Contains(p), "bearing %v: %v outside %v", bearing, p, box)
+			}
+			if !tt.allLongitudes {
+				lat := (box.MinLat + box.MaxLat) / 2
+				require.Greater(t, geo.Distance(tt.center, geo.Point{Lat: tt.center.Lat, Lon: box.MinLon - 0.01}), tt.radiusKm)
+				require.Greater(t, geo.Distance(tt.center, geo.Point{Lat: lat + (box.MaxLat-lat)*1.01, Lon: tt.center.Lon}), tt.radiusKm)
+			}
+		})
+	}
+}
+
+func TestBox(t *testing.T) {
+	tests := []struct {
+		name    string
+		box     geo.Box
+		valid   bool
+		center  geo.Point
+		inside  []geo.Point
+		outside []geo.Point
+	}{
+		{
+			name:    "Plain",
+			box:     geo.Box{MinLat: 48, MinLon: 2, MaxLat: 49, MaxLon: 3},
+			valid:   true,
+			center:  geo.Point{Lat: 48.5, Lon: 2.5},
+			inside:  []geo.Point{{Lat: 48.8566, Lon: 2.3522}, {Lat: 48, Lon: 3}},
+			outside: []geo.Point{{Lat: 51.5074, Lon: -0.1278}, {Lat: 48.5, Lon: 3.01}},
+		},
+		{
+			name:    "Crossing The Antimeridian",
+			box:     geo.Box{MinLat: -20, MinLon: 170, MaxLat: -10, MaxLon: -170},
+			valid:   true,
+			center:  geo.Point{Lat: -15, Lon: 180},
+			inside:  []geo.Point{{Lat: -17.7, Lon: 178.9}, {Lat: -15, Lon: -175}},
+			outside: []geo.Point{{Lat: -15, Lon: 0}, {Lat: -15, Lon: 169}},
+		},
+		{
+			name:   "Centered West Of The Antimeridian",
+			box:    geo.Box{MinLat: 0, MinLon: 160, MaxLat: 10, MaxLon: -140},
+			valid:  true,
+			center: geo.Point{Lat: 5, Lon: -170},
+		},
+		{
+			name:  "Upside Down",
+			box:   geo.Box{MinLat: 49, MinLon: 2, MaxLat: 48, MaxLon: 3},
+			valid: false,
+		},
+		{
+			name:  "Out Of Range",
+			box:   geo.Box{MinLat: 48, MinLon: 2, MaxLat: 91, MaxLon: 3},
+			valid: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.valid, tt.box.Valid())
+			if !tt.valid {
+				return
+			}
+
+			center := tt.box.Center()
+			require.InDelta(t, tt.center.Lat, center.Lat, 1e-9)
+			require.InDelta(t, tt.center.Lon, center.Lon, 1e-9)
+
+			for _, p := range tt.inside {
+				require.True(t, tt.box.Contains(p), "%v", p)
+			}
+			for _, p := range tt.outside {
+				require.False(t, tt.box.Contains(p), "%v", p)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package createKey
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	"imageProcessor/internal/lib/api/response"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/lib/logger/sl"
+	"imageProcessor/internal/lib/tenant"
+	"imageProcessor/internal/models"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+type Request struct {
+	Name   string   `json:"name" validate:"required"`
+	Scopes []string `json:"scopes" validate:"required,min=1,dive,oneof=upload read delete admin"`
+	// Tenant defaults to the tenant of the calling key. Only keys of the
+	// operator tenant may set it to another one.
+	Tenant string `json:"tenant,omitempty"`
+}
+
+type Response struct {
+	response.Response
+	Key models.APIKey `json:"key"`
+	// Token is only ever returned here; the service keeps just its hash.
+	Token string `json:"token"`
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=KeyCreator
+type KeyCreator interface {
+	CreateAPIKey(ctx context.Context, tenantID, name, prefix, hash string, scopes []string) (*models.APIKey, error)
+}
+
+// CreateKey issues a new API key.
+// @Summary      Create an API key
+// @Description  Creates an API key with the given scopes in the caller's tenant, or in any tenant when called by the operator tenant. The token is shown only once.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        request  body      createKey.Request  true  "Key name and scopes"
+// @Success      200  {object}  createKey.Response
+// @Failure      400  {object}  response.Response
+// @Failure      401  {object}  response.Response
+// @Failure      403  {object}  response.Response
+// @Failure      500  {object}  response.Response
+// @Router       /admin/keys [post]
+func New(log *slog.Logger, keyCreator KeyCreator, operatorTenant string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.apikey.createKey.New"
+
+		log := log.With(slog.String("op", op))
+
+		var req Request
+
+		err := render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			log.Error("request body is empty")
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error("empty request"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error("failed to decode request"))
+			return
+		}
+
+		if err = validator.New().Struct(req); err != nil {
+			var validateErr validator.ValidationErrors
+			errors.As(err, &validateErr)
+
+			log.Error("invalid request", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.ValidationError(validateErr))
+			return
+		}
+
+		callerTenant, err := tenant.FromContext(r.Context())
+		if err != nil {
+			log.Error("request has no tenant", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to create api key"))
+			return
+		}
+
+		tenantID := req.Tenant
+		if tenantID == "" {
+			tenantID = callerTenant
+		}
+
+		if err = tenant.Validate(tenantID); err != nil {
+			log.Error("invalid tenant", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error("invalid tenant"))
+			return
+		}
+
+		if tenantID != callerTenant && callerTenant != operatorTenant {
+			log.Warn("refused to create a key for another tenant", slog.String("tenant_id", callerTenant), slog.String("target_tenant_id", tenantID))
+			render.Status(r, http.StatusForbidden)
+			render.JSON(w, r, response.Error("cannot create keys for another tenant"))
+			return
+		}
+
+		token, prefix, hash, err := apikey.Generate()
+		if err != nil {
+			log.Error("failed to generate api key", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to create api key"))
+			return
+		}
+
+		key, err := keyCreator.CreateAPIKey(r.Context(), tenantID, req.Name, prefix, hash, req.Scopes)
+		if err != nil {
+			log.Error("failed to save api key", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to create api key"))
+			return
+		}
+
+		log.Info("api key created", slog.String("key_id", key.ID.String()), slog.String("tenant_id", key.TenantID), slog.String("prefix", prefix))
+
+		render.JSON(w, r, Response{
+			Response: response.OK(),
+			Key:      *key,
+			Token:    token,
+		})
+	}
+}
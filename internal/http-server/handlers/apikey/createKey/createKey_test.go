@@ -0,0 +1,131 @@
+package createKey_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"imageProcessor/internal/http-server/handlers/apikey/createKey"
+	"imageProcessor/internal/http-server/handlers/apikey/createKey/mocks"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/lib/tenant"
+	"imageProcessor/internal/models"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCreateKey(t *testing.T) {
+	log := slog.New(slog.NewJSONHandler(bytes.NewBuffer(nil), nil))
+
+	testKey := &models.APIKey{ID: uuid.New(), TenantID: "shop", Name: "cms", Scopes: []string{"upload", "read"}}
+
+	tests := []struct {
+		name           string
+		body           string
+		callerTenant   string
+		expectedTenant string
+		mockErr        error
+		expectedStatus int
+		expectedError  string
+	}{
+		{
+			name:           "Success",
+			body:           `{"name":"cms","scopes":["upload","read"]}`,
+			callerTenant:   "shop",
+			expectedTenant: "shop",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Operator Creates Key For Tenant",
+			body:           `{"name":"cms","scopes":["admin"],"tenant":"shop"}`,
+			callerTenant:   "ops",
+			expectedTenant: "shop",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Other Tenant",
+			body:           `{"name":"cms","scopes":["read"],"tenant":"blog"}`,
+			callerTenant:   "shop",
+			expectedStatus: http.StatusForbidden,
+			expectedError:  "cannot create keys for another tenant",
+		},
+		{
+			name:           "Invalid Tenant",
+			body:           `{"name":"cms","scopes":["read"],"tenant":"../shop"}`,
+			callerTenant:   "ops",
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "invalid tenant",
+		},
+		{
+			name:           "Empty Body",
+			body:           ``,
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "empty request",
+		},
+		{
+			name:           "Missing Name",
+			body:           `{"scopes":["read"]}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "field Name is a required field",
+		},
+		{
+			name:           "Unknown Scope",
+			body:           `{"name":"cms","scopes":["everything"]}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "field Scopes[0] is not valid",
+		},
+		{
+			name:           "Storage Error",
+			body:           `{"name":"cms","scopes":["read"]}`,
+			callerTenant:   "shop",
+			expectedTenant: "shop",
+			mockErr:        errors.New("db error"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedError:  "failed to create api key",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyCreatorMock := mocks.NewKeyCreator(t)
+
+			var storedHash string
+			if tt.expectedTenant != "" {
+				call := keyCreatorMock.On("CreateAPIKey", mock.Anything, tt.expectedTenant, "cms", mock.Anything, mock.Anything, mock.Anything).Once()
+				call.Run(func(args mock.Arguments) { storedHash = args.String(4) })
+				if tt.mockErr != nil {
+					call.Return(nil, tt.mockErr)
+				} else {
+					call.Return(testKey, nil)
+				}
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/admin/keys", strings.NewReader(tt.body))
+			req = req.WithContext(tenant.WithID(req.Context(), tt.callerTenant))
+			rr := httptest.NewRecorder()
+
+			handler := createKey.New(log, keyCreatorMock, "ops")
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+
+			var resp struct {
+				Status string `json:"status"`
+				Error  string `json:"error"`
+				Token  string `json:"token"`
+			}
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			require.Equal(t, tt.expectedError, resp.Error)
+
+			if tt.expectedStatus == http.StatusOK {
+				require.True(t, strings.HasPrefix(resp.Token, "ipk_"))
+				require.Equal(t, apikey.Hash(resp.Token), storedHash)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+// Code generated by mockery v2.51.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+
+	models "imageProcessor/internal/models"
+)
+
+// KeyCreator is an autogenerated mock type for the KeyCreator type
+type KeyCreator struct {
+	mock.Mock
+}
+
+// CreateAPIKey provides a mock function with given fields: ctx, tenantID, name, prefix, hash, scopes
+func (_m *KeyCreator) CreateAPIKey(ctx context.Context, tenantID string, name string, prefix string, hash string, scopes []string) (*models.APIKey, error) {
+	ret := _m.Called(ctx, tenantID, name, prefix, hash, scopes)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateAPIKey")
+	}
+
+	var r0 *models.APIKey
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string, string, []string) (*models.APIKey, error)); ok {
+		return rf(ctx, tenantID, name, prefix, hash, scopes)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string, string, []string) *models.APIKey); ok {
+		r0 = rf(ctx, tenantID, name, prefix, hash, scopes)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.APIKey)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, string, string, []string) error); ok {
+		r1 = rf(ctx, tenantID, name, prefix, hash, scopes)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewKeyCreator creates a new instance of KeyCreator. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewKeyCreator(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *KeyCreator {
+	mock := &KeyCreator{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,53 @@
+package listKeys
+
+import (
+	"context"
+	"github.com/go-chi/render"
+	"imageProcessor/internal/lib/api/response"
+	"imageProcessor/internal/lib/logger/sl"
+	"imageProcessor/internal/models"
+	"log/slog"
+	"net/http"
+)
+
+type Response struct {
+	response.Response
+	Keys []models.APIKey `json:"keys"`
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=KeyLister
+type KeyLister interface {
+	ListAPIKeys(ctx context.Context) ([]models.APIKey, error)
+}
+
+// ListKeys lists all API keys.
+// @Summary      List API keys
+// @Description  Lists all API keys, including revoked ones. Tokens are never returned.
+// @Tags         admin
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {object}  listKeys.Response
+// @Failure      401  {object}  response.Response
+// @Failure      403  {object}  response.Response
+// @Failure      500  {object}  response.Response
+// @Router       /admin/keys [get]
+func New(log *slog.Logger, keyLister KeyLister) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.apikey.listKeys.New"
+
+		log := log.With(slog.String("op", op))
+
+		keys, err := keyLister.ListAPIKeys(r.Context())
+		if err != nil {
+			log.Error("failed to list api keys", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to list api keys"))
+			return
+		}
+
+		render.JSON(w, r, Response{
+			Response: response.OK(),
+			Keys:     keys,
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package listKeys_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"imageProcessor/internal/http-server/handlers/apikey/listKeys"
+	"imageProcessor/internal/http-server/handlers/apikey/listKeys/mocks"
+	"imageProcessor/internal/models"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestListKeys(t *testing.T) {
+	log := slog.New(slog.NewJSONHandler(bytes.NewBuffer(nil), nil))
+
+	keys := []models.APIKey{
+		{ID: uuid.New(), Name: "cms", Prefix: "ipk_abcdefgh", Scopes: []string{"read"}},
+		{ID: uuid.New(), Name: "ops", Prefix: "ipk_ijklmnop", Scopes: []string{"admin"}},
+	}
+
+	tests := []struct {
+		name           string
+		mockKeys       []models.APIKey
+		mockErr        error
+		expectedStatus int
+		expectedCount  int
+	}{
+		{
+			name:           "Success",
+			mockKeys:       keys,
+			expectedStatus: http.StatusOK,
+			expectedCount:  2,
+		},
+		{
+			name:           "Storage Error",
+			mockErr:        errors.New("db error"),
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyListerMock := mocks.NewKeyLister(t)
+			keyListerMock.On("ListAPIKeys", mock.Anything).Return(tt.mockKeys, tt.mockErr).Once()
+
+			rr := httptest.NewRecorder()
+
+			handler := listKeys.New(log, keyListerMock)
+			handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/admin/keys", nil))
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+
+			var resp listKeys.Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			require.Len(t, resp.Keys, tt.expectedCount)
+			require.NotContains(t, rr.Body.String(), "hash")
+		})
+	}
+}
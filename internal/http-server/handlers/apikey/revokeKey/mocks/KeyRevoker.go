@@ -0,0 +1,48 @@
+// Code generated by mockery v2.51.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+
+	uuid "github.com/google/uuid"
+)
+
+// KeyRevoker is an autogenerated mock type for the KeyRevoker type
+type KeyRevoker struct {
+	mock.Mock
+}
+
+// RevokeAPIKey provides a mock function with given fields: ctx, id
+func (_m *KeyRevoker) RevokeAPIKey(ctx context.Context, id uuid.UUID) error {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RevokeAPIKey")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewKeyRevoker creates a new instance of KeyRevoker. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewKeyRevoker(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *KeyRevoker {
+	mock := &KeyRevoker{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,68 @@
+package revokeKey
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"imageProcessor/internal/lib/api/response"
+	"imageProcessor/internal/lib/logger/sl"
+	"log/slog"
+	"net/http"
+)
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=KeyRevoker
+type KeyRevoker interface {
+	RevokeAPIKey(ctx context.Context, id uuid.UUID) error
+}
+
+// RevokeKey revokes an API key.
+// @Summary      Revoke an API key
+// @Description  Revokes an API key. Requests made with it are rejected from then on.
+// @Tags         admin
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      string  true  "Key ID"
+// @Success      200  {object}  response.Response
+// @Failure      400  {object}  response.Response
+// @Failure      401  {object}  response.Response
+// @Failure      403  {object}  response.Response
+// @Failure      404  {object}  response.Response
+// @Failure      500  {object}  response.Response
+// @Router       /admin/keys/{id} [delete]
+func New(log *slog.Logger, keyRevoker KeyRevoker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.apikey.revokeKey.New"
+
+		log := log.With(slog.String("op", op))
+
+		keyID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			log.Error("failed to parse key ID", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error("invalid key ID"))
+			return
+		}
+
+		err = keyRevoker.RevokeAPIKey(r.Context(), keyID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				log.Warn("api key not found", slog.String("key_id", keyID.String()))
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, response.Error("api key not found"))
+				return
+			}
+
+			log.Error("failed to revoke api key", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to revoke api key"))
+			return
+		}
+
+		log.Info("api key revoked", slog.String("key_id", keyID.String()))
+
+		render.JSON(w, r, response.OK())
+	}
+}
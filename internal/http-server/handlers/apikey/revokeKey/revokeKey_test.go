@@ -0,0 +1,89 @@
+package revokeKey_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"imageProcessor/internal/http-server/handlers/apikey/revokeKey"
+	"imageProcessor/internal/http-server/handlers/apikey/revokeKey/mocks"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRevokeKey(t *testing.T) {
+	log := slog.New(slog.NewJSONHandler(bytes.NewBuffer(nil), nil))
+
+	keyID := uuid.New()
+
+	tests := []struct {
+		name           string
+		keyID          string
+		mockErr        error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Success",
+			keyID:          keyID.String(),
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"status":"OK"}`,
+		},
+		{
+			name:           "Invalid UUID",
+			keyID:          "invalid-uuid",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"Error","error":"invalid key ID"}`,
+		},
+		{
+			name:           "Not Found",
+			keyID:          keyID.String(),
+			mockErr:        sql.ErrNoRows,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"status":"Error","error":"api key not found"}`,
+		},
+		{
+			name:           "Internal Error",
+			keyID:          keyID.String(),
+			mockErr:        errors.New("db error"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"status":"Error","error":"failed to revoke api key"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyRevokerMock := mocks.NewKeyRevoker(t)
+
+			if tt.name != "Invalid UUID" {
+				keyRevokerMock.On("RevokeAPIKey", mock.Anything, keyID).Return(tt.mockErr).Once()
+			}
+
+			req := httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/admin/keys/%s", tt.keyID), nil)
+
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", tt.keyID)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			rr := httptest.NewRecorder()
+
+			handler := revokeKey.New(log, keyRevokerMock)
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+
+			var actualMap, expectedMap map[string]interface{}
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &actualMap))
+			require.NoError(t, json.Unmarshal([]byte(tt.expectedBody), &expectedMap))
+			require.Equal(t, expectedMap, actualMap)
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package putBlob
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/render"
+	"imageProcessor/internal/lib/api/response"
+	"imageProcessor/internal/lib/logger/sl"
+	"imageProcessor/internal/lib/tenant"
+	"imageProcessor/internal/storage"
+	"imageProcessor/internal/storage/local"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=BlobStorage
+type BlobStorage interface {
+	Put(ctx context.Context, key string, r io.Reader) (*storage.BlobInfo, error)
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, *storage.BlobInfo, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// PutBlob stores a blob sent to a presigned URL.
+// @Summary      Upload to a presigned URL
+// @Description  Stores the body as the blob the URL was signed for, as returned by POST /uploads/presign. The body must be exactly the size signed for, and a blob is written only once. The URL carries its own authorization and needs no API key.
+// @Tags         uploads
+// @Accept       application/octet-stream
+// @Produce      json
+// @Param        key   path   string  true  "Blob key"
+// @Param        size  query  int     true  "Size signed for"
+// @Success      200  {object}  response.Response
+// @Failure      400  {object}  response.Response
+// @Failure      403  {object}  response.Response
+// @Failure      409  {object}  response.Response
+// @Failure      411  {object}  response.Response
+// @Failure      413  {object}  response.Response
+// @Failure      500  {object}  response.Response
+// @Router       /blobs/{key} [put]
+func New(log *slog.Logger, blobStorage BlobStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.blob.putBlob.New"
+
+		log := log.With(slog.String("op", op))
+
+		// The key is the path the signature covers. chi's wildcard would
+		// lose the extension to URLFormat.
+		key := strings.TrimPrefix(r.URL.Path, local.BlobPath)
+
+		// The key is signed, so this only guards against URLs signed for
+		// the wrong tenant by mistake.
+		tenantID, err := tenant.FromContext(r.Context())
+		if err != nil || !strings.HasPrefix(key, tenantID+"/") {
+			log.Warn("rejected blob of another tenant", slog.String("key", key))
+			render.Status(r, http.StatusForbidden)
+			render.JSON(w, r, response.Error("invalid blob key"))
+			return
+		}
+
+		size, err := strconv.ParseInt(r.URL.Query().Get(local.ParamSize), 10, 64)
+		if err != nil || size <= 0 {
+			log.Warn("rejected presigned url without a size", slog.String("key", key))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error("invalid size"))
+			return
+		}
+
+		if r.ContentLength < 0 {
+			render.Status(r, http.StatusLengthRequired)
+			render.JSON(w, r, response.Error("content length required"))
+			return
+		}
+		if r.ContentLength != size {
+			log.Warn("content length does not match the presigned size",
+				slog.String("key", key),
+				slog.Int64("content_length", r.ContentLength),
+				slog.Int64("size", size),
+			)
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error("content length does not match the presigned size"))
+			return
+		}
+
+		// A blob is written once, so that an upload verified on completion
+		// can't be replaced while the URL is still valid.
+		existing, _, err := blobStorage.Open(r.Context(), key)
+		switch {
+		case err == nil:
+			_ = existing.Close()
+			log.Warn("blob already exists", slog.String("key", key))
+			render.Status(r, http.StatusConflict)
+			render.JSON(w, r, response.Error("blob already exists"))
+			return
+		case !errors.Is(err, storage.ErrBlobNotFound):
+			log.Error("failed to look up blob", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to store blob"))
+			return
+		}
+
+		info, err := blobStorage.Put(r.Context(), key, http.MaxBytesReader(w, r.Body, size))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				render.Status(r, http.StatusRequestEntityTooLarge)
+				render.JSON(w, r, response.Error("blob too large"))
+				return
+			}
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				log.Warn("client sent less than announced", slog.String("key", key), sl.Err(err))
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, response.Error("incomplete upload"))
+				return
+			}
+
+			log.Error("failed to store blob", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to store blob"))
+			return
+		}
+
+		// A client that hangs up early leaves a short blob behind, which
+		// would block it from trying again.
+		if info.Size != size {
+			log.Warn("incomplete blob", slog.String("key", key), slog.Int64("size", info.Size))
+			if err := blobStorage.Delete(r.Context(), key); err != nil {
+				log.Error("failed to remove incomplete blob", slog.String("key", key), sl.Err(err))
+			}
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error("incomplete upload"))
+			return
+		}
+
+		log.Info("blob stored", slog.String("key", key), slog.Int64("size", info.Size))
+
+		if info.Checksum != "" {
+			w.Header().Set("ETag", `"`+info.Checksum+`"`)
+		}
+		render.JSON(w, r, response.OK())
+	}
+}
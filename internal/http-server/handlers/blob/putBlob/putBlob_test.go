@@ -0,0 +1,177 @@
+package putBlob_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"imageProcessor/internal/http-server/handlers/blob/putBlob"
+	"imageProcessor/internal/http-server/handlers/blob/putBlob/mocks"
+	"imageProcessor/internal/lib/tenant"
+	"imageProcessor/internal/storage"
+	"imageProcessor/internal/storage/local"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type blob struct {
+	*bytes.Reader
+}
+
+func (blob) Close() error { return nil }
+
+func TestPutBlob(t *testing.T) {
+	log := slog.New(slog.NewJSONHandler(bytes.NewBuffer(nil), nil))
+
+	const key = "shop/uploads/3f1c.jpg"
+	content := "\xff\xd8\xff\xe0presigned image"
+
+	tests := []struct {
+		name           string
+		key            string
+		size           string
+		body           string
+		contentLength  int64
+		exists         bool
+		stored         bool
+		mockPutErr     error
+		deleted        bool
+		expectedStatus int
+		expectedBody   string
+		expectedETag   string
+	}{
+		{
+			name:           "Success",
+			key:            key,
+			size:           fmt.Sprint(len(content)),
+			body:           content,
+			contentLength:  int64(len(content)),
+			stored:         true,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"status":"OK"}`,
+			expectedETag:   `"abc123"`,
+		},
+		{
+			name:           "Another Tenant",
+			key:            "other/uploads/3f1c.jpg",
+			size:           fmt.Sprint(len(content)),
+			body:           content,
+			contentLength:  int64(len(content)),
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"status":"Error","error":"invalid blob key"}`,
+		},
+		{
+			name:           "Invalid Size",
+			key:            key,
+			size:           "zero",
+			body:           content,
+			contentLength:  int64(len(content)),
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"Error","error":"invalid size"}`,
+		},
+		{
+			name:           "No Content Length",
+			key:            key,
+			size:           fmt.Sprint(len(content)),
+			body:           content,
+			contentLength:  -1,
+			expectedStatus: http.StatusLengthRequired,
+			expectedBody:   `{"status":"Error","error":"content length required"}`,
+		},
+		{
+			name:           "Content Length Mismatch",
+			key:            key,
+			size:           "1000",
+			body:           content,
+			contentLength:  int64(len(content)),
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"Error","error":"content length does not match the presigned size"}`,
+		},
+		{
+			name:           "Already Exists",
+			key:            key,
+			size:           fmt.Sprint(len(content)),
+			body:           content,
+			contentLength:  int64(len(content)),
+			exists:         true,
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"status":"Error","error":"blob already exists"}`,
+		},
+		{
+			name:           "Incomplete Body",
+			key:            key,
+			size:           fmt.Sprint(len(content)),
+			body:           content[:5],
+			contentLength:  int64(len(content)),
+			stored:         true,
+			deleted:        true,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"Error","error":"incomplete upload"}`,
+		},
+		{
+			name:           "Failed to Store",
+			key:            key,
+			size:           fmt.Sprint(len(content)),
+			body:           content,
+			contentLength:  int64(len(content)),
+			mockPutErr:     errors.New("disk full"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"status":"Error","error":"failed to store blob"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blobStorageMock := mocks.NewBlobStorage(t)
+
+			looksUp := tt.stored || tt.exists || tt.mockPutErr != nil
+			if looksUp && tt.exists {
+				blobStorageMock.On("Open", mock.Anything, key).
+					Return(blob{bytes.NewReader([]byte(content))}, &storage.BlobInfo{Key: key}, nil).Once()
+			} else if looksUp {
+				blobStorageMock.On("Open", mock.Anything, key).Return(nil, nil, storage.ErrBlobNotFound).Once()
+			}
+			if tt.mockPutErr != nil {
+				blobStorageMock.On("Put", mock.Anything, key, mock.Anything).Return(nil, tt.mockPutErr).Once()
+			}
+			if tt.stored {
+				blobStorageMock.On("Put", mock.Anything, key, mock.Anything).
+					Return(func(_ context.Context, key string, r io.Reader) (*storage.BlobInfo, error) {
+						data, err := io.ReadAll(r)
+						if err != nil {
+							return nil, err
+						}
+						return &storage.BlobInfo{Key: key, Size: int64(len(data)), Checksum: "abc123"}, nil
+					}).Once()
+			}
+			if tt.deleted {
+				blobStorageMock.On("Delete", mock.Anything, key).Return(nil).Once()
+			}
+
+			req := httptest.NewRequest(http.MethodPut, "/blobs/"+tt.key+"?size="+tt.size, strings.NewReader(tt.body))
+			req.ContentLength = tt.contentLength
+			req = req.WithContext(tenant.WithID(req.Context(), "shop"))
+
+			rr := httptest.NewRecorder()
+
+			// Routed as the server does, where URLFormat takes the extension
+			// off the path chi matches.
+			router := chi.NewRouter()
+			router.Use(middleware.URLFormat)
+			router.Put(local.BlobPath+"*", putBlob.New(log, blobStorageMock))
+			router.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+			require.JSONEq(t, tt.expectedBody, rr.Body.String())
+			require.Equal(t, tt.expectedETag, rr.Header().Get("ETag"))
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package imageEvents
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"imageProcessor/internal/events"
+	"imageProcessor/internal/lib/api/response"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/lib/logger/sl"
+	"imageProcessor/internal/lib/sse"
+	"imageProcessor/internal/lib/tenant"
+	"imageProcessor/internal/models"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// heartbeatInterval is how often an idle stream is pinged.
+const heartbeatInterval = 15 * time.Second
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=ImageGetter
+type ImageGetter interface {
+	GetImage(ctx context.Context, id uuid.UUID) (*models.Image, error)
+}
+
+type Subscriber interface {
+	Subscribe(f events.Filter) *events.Subscription
+}
+
+// ImageEvents streams the progress of an image as Server-Sent Events.
+// @Summary      Stream image events
+// @Description  Streams status changes and stored variants of an image as Server-Sent Events. The first event carries the current status; the stream ends once the image is processed or has failed.
+// @Tags         events
+// @Produce      text/event-stream
+// @Security     ApiKeyAuth
+// @Param        id   path      string  true  "Image ID"
+// @Success      200  {object}  models.ImageEvent
+// @Failure      400  {object}  response.Response
+// @Failure      401  {object}  response.Response
+// @Failure      403  {object}  response.Response
+// @Failure      404  {object}  response.Response
+// @Failure      500  {object}  response.Response
+// @Router       /image/{id}/events [get]
+func New(log *slog.Logger, imageGetter ImageGetter, subscriber Subscriber) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.events.imageEvents.New"
+
+		log := log.With(slog.String("op", op))
+
+		idStr := chi.URLParam(r, "id")
+		imageID, err := uuid.Parse(idStr)
+		if err != nil {
+			log.Error("failed to parse image ID", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error("invalid image ID"))
+			return
+		}
+
+		tenantID, err := tenant.FromContext(r.Context())
+		if err != nil {
+			log.Error("failed to get tenant", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("internal error"))
+			return
+		}
+
+		// Subscribe before reading the image so that no change made in
+		// between is missed.
+		sub := subscriber.Subscribe(events.Filter{TenantID: tenantID, ImageID: &imageID})
+		defer sub.Close()
+
+		image, err := imageGetter.GetImage(r.Context(), imageID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				log.Warn("image not found", slog.String("image_id", imageID.String()))
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, response.Error("image not found"))
+				return
+			}
+
+			log.Error("failed to get image from storage", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to get image"))
+			return
+		}
+
+		if !apikey.CanAccess(r.Context(), image.OwnerKeyID) {
+			log.Warn("image belongs to another api key", slog.String("image_id", imageID.String()))
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, response.Error("image not found"))
+			return
+		}
+
+		stream := sse.Start(w)
+
+		current := models.ImageEvent{
+			Type:       models.ImageEventStatus,
+			TenantID:   image.TenantID,
+			ImageID:    image.ID,
+			OwnerKeyID: image.OwnerKeyID,
+			Status:     image.Status,
+			At:         image.UpdatedAt,
+		}
+		if err := stream.Send(current.Type, current); err != nil || done(current) {
+			return
+		}
+
+		log.Info("streaming image events", slog.String("image_id", imageID.String()))
+
+		heartbeat := time.NewTicker(heartbeatInterval)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-heartbeat.C:
+				if err := stream.Ping(); err != nil {
+					return
+				}
+			case event, ok := <-sub.C:
+				if !ok {
+					log.Warn("event stream fell behind", slog.String("image_id", imageID.String()))
+					return
+				}
+				if err := stream.Send(event.Type, event); err != nil || done(event) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// done reports whether e is the last event of an image.
+func done(e models.ImageEvent) bool {
+	return e.Type == models.ImageEventStatus && (e.Status == "processed" || e.Status == "failed")
+}
@@ -0,0 +1,176 @@
+package imageEvents_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"imageProcessor/internal/events"
+	"imageProcessor/internal/http-server/handlers/events/imageEvents"
+	"imageProcessor/internal/http-server/handlers/events/imageEvents/mocks"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/lib/tenant"
+	"imageProcessor/internal/models"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestImageEvents(t *testing.T) {
+	log := slog.New(slog.NewJSONHandler(bytes.NewBuffer(nil), nil))
+
+	testUUID := uuid.New()
+	updatedAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	ownerKey := &models.APIKey{ID: uuid.New(), TenantID: "shop", Scopes: []string{apikey.ScopeRead}}
+	otherKey := &models.APIKey{ID: uuid.New(), TenantID: "shop", Scopes: []string{apikey.ScopeRead}}
+
+	image := func(status string) *models.Image {
+		return &models.Image{ID: testUUID, TenantID: "shop", Status: status, OwnerKeyID: &ownerKey.ID, UpdatedAt: updatedAt}
+	}
+	event := func(typ, status, variant string) models.ImageEvent {
+		return models.ImageEvent{Type: typ, TenantID: "shop", ImageID: testUUID, OwnerKeyID: &ownerKey.ID, Status: status, Variant: variant, At: updatedAt}
+	}
+
+	tests := []struct {
+		name           string
+		imageID        string
+		key            *models.APIKey
+		mockImage      *models.Image
+		mockErr        error
+		published      []models.ImageEvent
+		expectedStatus int
+		expectedBody   string
+		expectedEvents []models.ImageEvent
+	}{
+		{
+			name:      "Stream Until Processed",
+			imageID:   testUUID.String(),
+			key:       ownerKey,
+			mockImage: image("processing"),
+			published: []models.ImageEvent{
+				event(models.ImageEventVariant, "", "resize"),
+				{Type: models.ImageEventStatus, TenantID: "shop", ImageID: uuid.New(), Status: "processed"},
+				event(models.ImageEventStatus, "processed", ""),
+				event(models.ImageEventVariant, "", "thumbnail"),
+			},
+			expectedStatus: http.StatusOK,
+			expectedEvents: []models.ImageEvent{
+				event(models.ImageEventStatus, "processing", ""),
+				event(models.ImageEventVariant, "", "resize"),
+				event(models.ImageEventStatus, "processed", ""),
+			},
+		},
+		{
+			name:           "Already Failed",
+			imageID:        testUUID.String(),
+			key:            ownerKey,
+			mockImage:      image("failed"),
+			expectedStatus: http.StatusOK,
+			expectedEvents: []models.ImageEvent{
+				event(models.ImageEventStatus, "failed", ""),
+			},
+		},
+		{
+			name:           "Other Owner",
+			imageID:        testUUID.String(),
+			key:            otherKey,
+			mockImage:      image("processing"),
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"status":"Error","error":"image not found"}`,
+		},
+		{
+			name:           "Invalid UUID",
+			imageID:        "invalid-uuid",
+			key:            ownerKey,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"Error","error":"invalid image ID"}`,
+		},
+		{
+			name:           "Not Found",
+			imageID:        testUUID.String(),
+			key:            ownerKey,
+			mockErr:        sql.ErrNoRows,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"status":"Error","error":"image not found"}`,
+		},
+		{
+			name:           "Internal Error",
+			imageID:        testUUID.String(),
+			key:            ownerKey,
+			mockErr:        errors.New("db error"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"status":"Error","error":"failed to get image"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			imageGetterMock := mocks.NewImageGetter(t)
+			hub := events.NewHub()
+
+			if tt.mockImage != nil || tt.mockErr != nil {
+				// Events published while the image is read must not be lost.
+				imageGetterMock.On("GetImage", mock.Anything, testUUID).
+					Run(func(mock.Arguments) {
+						for _, e := range tt.published {
+							hub.Publish(e)
+						}
+					}).
+					Return(tt.mockImage, tt.mockErr).Once()
+			}
+
+			req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/image/%s/events", tt.imageID), nil)
+
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", tt.imageID)
+			ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+			ctx = tenant.WithID(apikey.WithKey(ctx, tt.key), tt.key.TenantID)
+			req = req.WithContext(ctx)
+
+			rr := httptest.NewRecorder()
+
+			handler := imageEvents.New(log, imageGetterMock, hub)
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+			require.Equal(t, 0, hub.Subscribers())
+
+			if tt.expectedBody != "" {
+				require.JSONEq(t, tt.expectedBody, rr.Body.String())
+				return
+			}
+
+			require.Equal(t, "text/event-stream", rr.Header().Get("Content-Type"))
+			require.Equal(t, tt.expectedEvents, parseEvents(t, rr.Body.String()))
+		})
+	}
+}
+
+// parseEvents decodes an event stream, checking that every event is named
+// after its type.
+func parseEvents(t *testing.T, body string) []models.ImageEvent {
+	var result []models.ImageEvent
+
+	for _, block := range strings.Split(strings.TrimSpace(body), "\n\n") {
+		lines := strings.Split(block, "\n")
+		require.Len(t, lines, 2)
+
+		var e models.ImageEvent
+		require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(lines[1], "data: ")), &e))
+		require.Equal(t, "event: "+e.Type, lines[0])
+
+		result = append(result, e)
+	}
+
+	return result
+}
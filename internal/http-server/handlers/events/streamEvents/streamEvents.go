@@ -0,0 +1,92 @@
+package streamEvents
+
+import (
+	"github.com/go-chi/render"
+	"imageProcessor/internal/events"
+	"imageProcessor/internal/lib/api/response"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/lib/logger/sl"
+	"imageProcessor/internal/lib/sse"
+	"imageProcessor/internal/lib/tenant"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// heartbeatInterval is how often an idle stream is pinged.
+const heartbeatInterval = 15 * time.Second
+
+type Subscriber interface {
+	Subscribe(f events.Filter) *events.Subscription
+}
+
+// StreamEvents streams the progress of all images of the client as
+// Server-Sent Events.
+// @Summary      Stream events of all images
+// @Description  Streams status changes and stored variants of every image uploaded with the API key as Server-Sent Events; admin keys receive the events of the whole tenant. The stream stays open until the client disconnects.
+// @Tags         events
+// @Produce      text/event-stream
+// @Security     ApiKeyAuth
+// @Success      200  {object}  models.ImageEvent
+// @Failure      401  {object}  response.Response
+// @Failure      403  {object}  response.Response
+// @Failure      500  {object}  response.Response
+// @Router       /events [get]
+func New(log *slog.Logger, subscriber Subscriber) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.events.streamEvents.New"
+
+		log := log.With(slog.String("op", op))
+
+		tenantID, err := tenant.FromContext(r.Context())
+		if err != nil {
+			log.Error("failed to get tenant", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("internal error"))
+			return
+		}
+
+		key, ok := apikey.FromContext(r.Context())
+		if !ok {
+			log.Error("no api key in context")
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("internal error"))
+			return
+		}
+
+		filter := events.Filter{TenantID: tenantID}
+		if !slices.Contains(key.Scopes, apikey.ScopeAdmin) {
+			filter.OwnerKeyID = &key.ID
+		}
+
+		sub := subscriber.Subscribe(filter)
+		defer sub.Close()
+
+		stream := sse.Start(w)
+
+		log.Info("streaming events", slog.String("key_id", key.ID.String()))
+
+		heartbeat := time.NewTicker(heartbeatInterval)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-heartbeat.C:
+				if err := stream.Ping(); err != nil {
+					return
+				}
+			case event, ok := <-sub.C:
+				if !ok {
+					log.Warn("event stream fell behind", slog.String("key_id", key.ID.String()))
+					return
+				}
+				if err := stream.Send(event.Type, event); err != nil {
+					return
+				}
+			}
+		}
+	}
+}
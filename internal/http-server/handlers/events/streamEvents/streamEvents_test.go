@@ -0,0 +1,97 @@
+package streamEvents_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"imageProcessor/internal/events"
+	"imageProcessor/internal/http-server/handlers/events/streamEvents"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/lib/tenant"
+	"imageProcessor/internal/models"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamEvents(t *testing.T) {
+	log := slog.New(slog.NewJSONHandler(bytes.NewBuffer(nil), nil))
+
+	userKey := &models.APIKey{ID: uuid.New(), TenantID: "shop", Scopes: []string{apikey.ScopeRead}}
+	adminKey := &models.APIKey{ID: uuid.New(), TenantID: "shop", Scopes: []string{apikey.ScopeAdmin}}
+
+	own := models.ImageEvent{Type: models.ImageEventStatus, TenantID: "shop", ImageID: uuid.New(), OwnerKeyID: &userKey.ID, Status: "processing"}
+	ownVariant := models.ImageEvent{Type: models.ImageEventVariant, TenantID: "shop", ImageID: own.ImageID, OwnerKeyID: &userKey.ID, Variant: "resize", Format: "webp"}
+	colleague := models.ImageEvent{Type: models.ImageEventStatus, TenantID: "shop", ImageID: uuid.New(), OwnerKeyID: &adminKey.ID, Status: "processed"}
+	otherTenant := models.ImageEvent{Type: models.ImageEventStatus, TenantID: "other", ImageID: uuid.New(), OwnerKeyID: &userKey.ID, Status: "processed"}
+
+	tests := []struct {
+		name           string
+		key            *models.APIKey
+		expectedEvents []models.ImageEvent
+	}{
+		{
+			name:           "Own Images",
+			key:            userKey,
+			expectedEvents: []models.ImageEvent{own, ownVariant},
+		},
+		{
+			name:           "Admin Sees Tenant",
+			key:            adminKey,
+			expectedEvents: []models.ImageEvent{own, ownVariant, colleague},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := events.NewHub()
+
+			handler := streamEvents.New(log, hub)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ctx := tenant.WithID(apikey.WithKey(r.Context(), tt.key), tt.key.TenantID)
+				handler.ServeHTTP(w, r.WithContext(ctx))
+			}))
+			defer server.Close()
+
+			resp, err := http.Get(server.URL + "/events")
+			require.NoError(t, err)
+
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+			// Headers are only sent after subscribing.
+			require.Equal(t, 1, hub.Subscribers())
+			for _, e := range []models.ImageEvent{own, otherTenant, ownVariant, colleague} {
+				hub.Publish(e)
+			}
+
+			reader := bufio.NewReader(resp.Body)
+			for _, expected := range tt.expectedEvents {
+				require.Equal(t, expected, readEvent(t, reader))
+			}
+
+			resp.Body.Close()
+			require.Eventually(t, func() bool { return hub.Subscribers() == 0 }, time.Second, 10*time.Millisecond)
+		})
+	}
+}
+
+func readEvent(t *testing.T, r *bufio.Reader) models.ImageEvent {
+	name, err := r.ReadString('\n')
+	require.NoError(t, err)
+	data, err := r.ReadString('\n')
+	require.NoError(t, err)
+	_, err = r.ReadString('\n')
+	require.NoError(t, err)
+
+	var e models.ImageEvent
+	require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(strings.TrimSpace(data), "data: ")), &e))
+	require.Equal(t, "event: "+e.Type, strings.TrimSpace(name))
+
+	return e
+}
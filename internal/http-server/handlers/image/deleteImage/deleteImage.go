@@ -8,14 +8,24 @@ import (
 	"github.com/go-chi/render"
 	"github.com/google/uuid"
 	"imageProcessor/internal/lib/api/response"
+	"imageProcessor/internal/lib/apikey"
 	"imageProcessor/internal/lib/logger/sl"
+	"imageProcessor/internal/models"
+	"imageProcessor/internal/transformer"
 	"log/slog"
 	"net/http"
 )
 
 //go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=ImageDeleter
 type ImageDeleter interface {
-	DeleteImage(ctx context.Context, id uuid.UUID) error
+	GetImage(ctx context.Context, id uuid.UUID) (*models.Image, error)
+	DeleteImage(ctx context.Context, id uuid.UUID) ([]string, error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=BlobDeleter
+type BlobDeleter interface {
+	Delete(ctx context.Context, key string) error
+	DeletePrefix(ctx context.Context, prefix string) error
 }
 
 type Response struct {
@@ -24,20 +34,24 @@ type Response struct {
 
 // DeleteImage deletes an image by its ID.
 // @Summary      Delete an image
-// @Description  Deletes an image and all its processed versions from the storage
+// @Description  Deletes an image, all its processed versions and its cached transforms from the storage and releases them from the usage quota
 // @Tags         images
 // @Produce      json
+// @Security     ApiKeyAuth
 // @Param        id   path      string  true  "Image ID"
+// @Param        Idempotency-Key  header  string  false  "Key making retries of the request safe"
 // @Success      200  {object}  response.Response
 // @Failure      400  {object}  response.Response
+// @Failure      401  {object}  response.Response
+// @Failure      403  {object}  response.Response
 // @Failure      404  {object}  response.Response
 // @Failure      500  {object}  response.Response
 // @Router       /image/{id} [delete]
-func New(log *slog.Logger, imageDeleter ImageDeleter) http.HandlerFunc {
+func New(log *slog.Logger, imageDeleter ImageDeleter, blobDeleter BlobDeleter) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.image.deleteImage.New"
 
-		log = log.With(slog.String("op", op))
+		log := log.With(slog.String("op", op))
 
 		idStr := chi.URLParam(r, "id")
 		imageID, err := uuid.Parse(idStr)
@@ -50,7 +64,29 @@ func New(log *slog.Logger, imageDeleter ImageDeleter) http.HandlerFunc {
 
 		log.Info("attempting to delete image", slog.String("image_id", imageID.String()))
 
-		err = imageDeleter.DeleteImage(r.Context(), imageID)
+		image, err := imageDeleter.GetImage(r.Context(), imageID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				log.Warn("image not found for deletion", slog.String("image_id", imageID.String()))
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, response.Error("image not found"))
+				return
+			}
+
+			log.Error("failed to get image from storage", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to delete image"))
+			return
+		}
+
+		if !apikey.CanAccess(r.Context(), image.OwnerKeyID) {
+			log.Warn("image belongs to another api key", slog.String("image_id", imageID.String()))
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, response.Error("image not found"))
+			return
+		}
+
+		keys, err := imageDeleter.DeleteImage(r.Context(), imageID)
 		if err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
 				log.Warn("image not found for deletion", slog.String("image_id", imageID.String()))
@@ -65,6 +101,18 @@ func New(log *slog.Logger, imageDeleter ImageDeleter) http.HandlerFunc {
 			return
 		}
 
+		// The records are gone, so a blob left behind is only wasted space
+		// and does not fail the request.
+		for _, key := range keys {
+			if err = blobDeleter.Delete(r.Context(), key); err != nil {
+				log.Error("failed to delete blob", slog.String("key", key), sl.Err(err))
+			}
+		}
+		cachePrefix := transformer.CachePrefix(image.TenantID, image.ID)
+		if err = blobDeleter.DeletePrefix(r.Context(), cachePrefix); err != nil {
+			log.Error("failed to delete cached transforms", slog.String("prefix", cachePrefix), sl.Err(err))
+		}
+
 		log.Info("image deleted successfully", slog.String("image_id", imageID.String()))
 
 		render.JSON(w, r, Response{
@@ -13,6 +13,8 @@ import (
 	"github.com/stretchr/testify/require"
 	"imageProcessor/internal/http-server/handlers/image/deleteImage"
 	"imageProcessor/internal/http-server/handlers/image/deleteImage/mocks"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/models"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
@@ -24,9 +26,19 @@ func TestDeleteImage(t *testing.T) {
 
 	testUUID, _ := uuid.NewRandom()
 
+	ownerKey := &models.APIKey{ID: uuid.New(), Scopes: []string{apikey.ScopeDelete}}
+	otherKey := &models.APIKey{ID: uuid.New(), Scopes: []string{apikey.ScopeDelete}}
+	adminKey := &models.APIKey{ID: uuid.New(), Scopes: []string{apikey.ScopeAdmin}}
+
+	testImage := &models.Image{ID: testUUID, TenantID: "shop", OwnerKeyID: &ownerKey.ID}
+	// A blob that cannot be removed does not fail the request.
+	testKeys := []string{"shop/uploads/test.jpg", "shop/processed/test_resized.jpg"}
+
 	tests := []struct {
 		name           string
 		imageID        string
+		key            *models.APIKey
+		mockGetErr     error
 		mockErr        error
 		expectedStatus int
 		expectedBody   string
@@ -34,10 +46,27 @@ func TestDeleteImage(t *testing.T) {
 		{
 			name:           "Success",
 			imageID:        testUUID.String(),
+			key:            ownerKey,
 			mockErr:        nil,
 			expectedStatus: http.StatusOK,
 			expectedBody:   `{"status":"OK"}`,
 		},
+		{
+			name:           "Admin",
+			imageID:        testUUID.String(),
+			key:            adminKey,
+			mockErr:        nil,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"status":"OK"}`,
+		},
+		{
+			name:           "Other Owner",
+			imageID:        testUUID.String(),
+			key:            otherKey,
+			mockErr:        nil,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"status":"Error","error":"image not found"}`,
+		},
 		{
 			name:           "Invalid UUID",
 			imageID:        "invalid-uuid",
@@ -48,13 +77,15 @@ func TestDeleteImage(t *testing.T) {
 		{
 			name:           "Not Found",
 			imageID:        testUUID.String(),
-			mockErr:        sql.ErrNoRows,
+			key:            ownerKey,
+			mockGetErr:     sql.ErrNoRows,
 			expectedStatus: http.StatusNotFound,
 			expectedBody:   `{"status":"Error","error":"image not found"}`,
 		},
 		{
 			name:           "Internal Error",
 			imageID:        testUUID.String(),
+			key:            ownerKey,
 			mockErr:        errors.New("db error"),
 			expectedStatus: http.StatusInternalServerError,
 			expectedBody:   `{"status":"Error","error":"failed to delete image"}`,
@@ -64,14 +95,22 @@ func TestDeleteImage(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			imageDeleterMock := mocks.NewImageDeleter(t)
+			blobDeleterMock := mocks.NewBlobDeleter(t)
 
-			if tt.mockErr != nil {
-				if tt.name == "Invalid UUID" {
+			if tt.name != "Invalid UUID" {
+				if tt.mockGetErr != nil {
+					imageDeleterMock.On("GetImage", mock.Anything, testUUID).Return(nil, tt.mockGetErr).Once()
 				} else {
-					imageDeleterMock.On("DeleteImage", mock.Anything, testUUID).Return(tt.mockErr).Once()
+					imageDeleterMock.On("GetImage", mock.Anything, testUUID).Return(testImage, nil).Once()
 				}
-			} else if tt.name == "Success" {
-				imageDeleterMock.On("DeleteImage", mock.Anything, testUUID).Return(nil).Once()
+			}
+			if tt.mockErr != nil {
+				imageDeleterMock.On("DeleteImage", mock.Anything, testUUID).Return(nil, tt.mockErr).Once()
+			} else if tt.name == "Success" || tt.name == "Admin" {
+				imageDeleterMock.On("DeleteImage", mock.Anything, testUUID).Return(testKeys, nil).Once()
+				blobDeleterMock.On("Delete", mock.Anything, testKeys[0]).Return(nil).Once()
+				blobDeleterMock.On("Delete", mock.Anything, testKeys[1]).Return(errors.New("disk error")).Once()
+				blobDeleterMock.On("DeletePrefix", mock.Anything, "shop/transforms/"+testUUID.String()).Return(nil).Once()
 			}
 
 			req := httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/image/%s", tt.imageID), nil)
@@ -79,10 +118,13 @@ func TestDeleteImage(t *testing.T) {
 			rctx := chi.NewRouteContext()
 			rctx.URLParams.Add("id", tt.imageID)
 			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			if tt.key != nil {
+				req = req.WithContext(apikey.WithKey(req.Context(), tt.key))
+			}
 
 			rr := httptest.NewRecorder()
 
-			handler := deleteImage.New(log, imageDeleterMock)
+			handler := deleteImage.New(log, imageDeleterMock, blobDeleterMock)
 			handler.ServeHTTP(rr, req)
 
 			require.Equal(t, tt.expectedStatus, rr.Code)
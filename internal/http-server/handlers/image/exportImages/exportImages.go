@@ -0,0 +1,200 @@
+package exportImages
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"imageProcessor/internal/lib/api/response"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/lib/imagezip"
+	"imageProcessor/internal/lib/logger/sl"
+	"imageProcessor/internal/lib/tenant"
+	"imageProcessor/internal/models"
+	"imageProcessor/internal/storage"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// maxImages is the most images one archive may hold.
+const maxImages = 1000
+
+// Request selects the images to export: the listed IDs, or the images
+// matching the filter fields. Both may be combined.
+type Request struct {
+	IDs           []uuid.UUID `json:"ids" validate:"max=1000"`
+	BatchID       *uuid.UUID  `json:"batch_id"`
+	Status        string      `json:"status" validate:"omitempty,oneof=pending processed failed"`
+	CreatedAfter  *time.Time  `json:"created_after"`
+	CreatedBefore *time.Time  `json:"created_before"`
+	TakenAfter    *time.Time  `json:"taken_after"`
+	TakenBefore   *time.Time  `json:"taken_before"`
+	CameraMake    string      `json:"camera_make" validate:"max=255"`
+	CameraModel   string      `json:"camera_model" validate:"max=255"`
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=ImageLister
+type ImageLister interface {
+	ListImages(ctx context.Context, filter models.ImageFilter) ([]models.Image, error)
+	ListVariants(ctx context.Context, imageIDs []uuid.UUID) ([]models.Variant, error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=BlobOpener
+type BlobOpener interface {
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, *storage.BlobInfo, error)
+}
+
+// ExportImages downloads many images with their variants as one ZIP archive.
+// @Summary      Download images as ZIP
+// @Description  Streams a ZIP archive with a directory per image, named by its ID, holding the original, every stored variant and a metadata.json. The images are given by ids, by a filter (batch_id, status, created_after, created_before, and taken_after, taken_before, camera_make, camera_model matching the metadata of the original) or both; keys without the admin scope only get their own images. Up to 1000 images fit in one archive.
+// @Tags         images
+// @Accept       json
+// @Produce      application/zip
+// @Security     ApiKeyAuth
+// @Param        request  body      exportImages.Request  true  "Images to export"
+// @Success      200      {file}    file
+// @Failure      400      {object}  response.Response
+// @Failure      401      {object}  response.Response
+// @Failure      403      {object}  response.Response
+// @Failure      404      {object}  response.Response
+// @Failure      500      {object}  response.Response
+// @Router       /images/archive [post]
+func New(log *slog.Logger, imageLister ImageLister, blobOpener BlobOpener) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.image.exportImages.New"
+
+		log := log.With(slog.String("op", op))
+
+		key, ok := apikey.FromContext(r.Context())
+		if !ok {
+			render.Status(r, http.StatusUnauthorized)
+			render.JSON(w, r, response.Error("missing api key"))
+			return
+		}
+
+		var req Request
+
+		err := render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			log.Error("request body is empty")
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error("empty request"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error("failed to decode request"))
+			return
+		}
+
+		if err = validator.New().Struct(req); err != nil {
+			var validateErr validator.ValidationErrors
+			errors.As(err, &validateErr)
+
+			log.Error("invalid request", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.ValidationError(validateErr))
+			return
+		}
+
+		filter := models.ImageFilter{
+			BatchID:       req.BatchID,
+			Status:        req.Status,
+			CreatedAfter:  req.CreatedAfter,
+			CreatedBefore: req.CreatedBefore,
+			TakenAfter:    req.TakenAfter,
+			TakenBefore:   req.TakenBefore,
+			CameraMake:    req.CameraMake,
+			CameraModel:   req.CameraModel,
+			Limit:         maxImages + 1,
+		}
+		if len(req.IDs) > 0 {
+			filter.IDs = slices.Compact(slices.SortedFunc(slices.Values(req.IDs), func(a, b uuid.UUID) int {
+				return slices.Compare(a[:], b[:])
+			}))
+		}
+
+		// Exporting everything at once is never what was meant.
+		if filter.IDs == nil && filter.BatchID == nil && filter.Status == "" && filter.CreatedAfter == nil && filter.CreatedBefore == nil &&
+			filter.TakenAfter == nil && filter.TakenBefore == nil && filter.CameraMake == "" && filter.CameraModel == "" {
+			log.Error("no images selected")
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error("ids or a filter is required"))
+			return
+		}
+
+		if !slices.Contains(key.Scopes, apikey.ScopeAdmin) {
+			filter.OwnerKeyID = &key.ID
+		}
+
+		images, err := imageLister.ListImages(r.Context(), filter)
+		if err != nil {
+			log.Error("failed to list images", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to get images"))
+			return
+		}
+
+		switch {
+		case len(images) > maxImages:
+			log.Warn("too many images to export", slog.Int("images", len(images)))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error("too many images"))
+			return
+		case len(images) < len(filter.IDs):
+			// Images of other keys are left out by the filter, so they are
+			// reported missing just like images that do not exist.
+			log.Warn("requested images not found", slog.Int("requested", len(filter.IDs)), slog.Int("found", len(images)))
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, response.Error("image not found"))
+			return
+		case len(images) == 0:
+			log.Warn("no images match the filter")
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, response.Error("no images found"))
+			return
+		}
+
+		ids := make([]uuid.UUID, len(images))
+		for i, image := range images {
+			ids[i] = image.ID
+		}
+
+		variants, err := imageLister.ListVariants(r.Context(), ids)
+		if err != nil {
+			log.Error("failed to list variants", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to get images"))
+			return
+		}
+
+		byImage := make(map[uuid.UUID][]models.Variant, len(images))
+		for _, variant := range variants {
+			byImage[variant.ImageID] = append(byImage[variant.ImageID], variant)
+		}
+
+		tenantID, _ := tenant.FromContext(r.Context())
+		zw := imagezip.Start(w, tenantID+"-images.zip")
+
+		// Once the archive has started, a failure can only cut it short,
+		// which leaves the client with an archive it cannot open.
+		for i := range images {
+			image := &images[i]
+			if err = imagezip.Add(r.Context(), zw, blobOpener, image.ID.String(), image, byImage[image.ID]); err != nil {
+				log.Error("failed to write archive", slog.String("image_id", image.ID.String()), sl.Err(err))
+				return
+			}
+		}
+		if err = zw.Close(); err != nil {
+			log.Error("failed to finish archive", sl.Err(err))
+			return
+		}
+
+		log.Info("images archive sent", slog.Int("images", len(images)))
+	}
+}
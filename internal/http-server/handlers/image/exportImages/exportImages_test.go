@@ -0,0 +1,224 @@
+package exportImages_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"imageProcessor/internal/http-server/handlers/image/exportImages"
+	"imageProcessor/internal/http-server/handlers/image/exportImages/mocks"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/lib/tenant"
+	"imageProcessor/internal/models"
+	"imageProcessor/internal/storage"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+)
+
+type nopSeekCloser struct {
+	io.ReadSeeker
+}
+
+func (nopSeekCloser) Close() error { return nil }
+
+func TestExportImages(t *testing.T) {
+	log := slog.New(slog.NewJSONHandler(bytes.NewBuffer(nil), nil))
+
+	userKey := &models.APIKey{ID: uuid.New(), TenantID: "shop", Scopes: []string{apikey.ScopeRead}}
+	adminKey := &models.APIKey{ID: uuid.New(), TenantID: "shop", Scopes: []string{apikey.ScopeAdmin}}
+
+	batchID := uuid.New()
+	first := models.Image{ID: uuid.New(), TenantID: "shop", Status: "processed", OriginalPath: "shop/uploads/first.jpg"}
+	second := models.Image{ID: uuid.New(), TenantID: "shop", Status: "processed", OriginalPath: "shop/uploads/second.png"}
+	variants := []models.Variant{
+		{ImageID: first.ID, Name: "resize", Format: "jpeg", BlobKey: "shop/processed/first_resize.jpg"},
+		{ImageID: second.ID, Name: "thumbnail", Format: "jpeg", BlobKey: "shop/processed/second_thumbnail.jpg"},
+	}
+
+	tests := []struct {
+		name           string
+		key            *models.APIKey
+		body           string
+		expectedFilter func(f models.ImageFilter) bool
+		mockImages     []models.Image
+		mockListErr    error
+		expectedStatus int
+		expectedFiles  []string
+		expectedError  string
+	}{
+		{
+			name: "By IDs",
+			key:  userKey,
+			body: `{"ids":["` + first.ID.String() + `","` + second.ID.String() + `","` + first.ID.String() + `"]}`,
+			expectedFilter: func(f models.ImageFilter) bool {
+				return len(f.IDs) == 2 && *f.OwnerKeyID == userKey.ID
+			},
+			mockImages:     []models.Image{first, second},
+			expectedStatus: http.StatusOK,
+			expectedFiles: []string{
+				first.ID.String() + "/metadata.json",
+				first.ID.String() + "/original.jpg",
+				first.ID.String() + "/variants/resize.jpg",
+				second.ID.String() + "/metadata.json",
+				second.ID.String() + "/original.png",
+				second.ID.String() + "/variants/thumbnail.jpg",
+			},
+		},
+		{
+			name: "By Filter As Admin",
+			key:  adminKey,
+			body: `{"batch_id":"` + batchID.String() + `","status":"processed"}`,
+			expectedFilter: func(f models.ImageFilter) bool {
+				return f.IDs == nil && *f.BatchID == batchID && f.Status == "processed" && f.OwnerKeyID == nil && f.Limit == 1001
+			},
+			mockImages:     []models.Image{first},
+			expectedStatus: http.StatusOK,
+			expectedFiles: []string{
+				first.ID.String() + "/metadata.json",
+				first.ID.String() + "/original.jpg",
+				first.ID.String() + "/variants/resize.jpg",
+			},
+		},
+		{
+			name: "By Metadata",
+			key:  userKey,
+			body: `{"taken_after":"2021-01-01T00:00:00Z","taken_before":"2022-01-01T00:00:00Z","camera_make":"Canon"}`,
+			expectedFilter: func(f models.ImageFilter) bool {
+				return f.TakenAfter.Equal(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)) &&
+					f.TakenBefore.Equal(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)) &&
+					f.CameraMake == "Canon" && f.CameraModel == "" && *f.OwnerKeyID == userKey.ID
+			},
+			mockImages:     []models.Image{second},
+			expectedStatus: http.StatusOK,
+			expectedFiles: []string{
+				second.ID.String() + "/metadata.json",
+				second.ID.String() + "/original.png",
+				second.ID.String() + "/variants/thumbnail.jpg",
+			},
+		},
+		{
+			name: "Missing ID",
+			key:  userKey,
+			body: `{"ids":["` + first.ID.String() + `","` + second.ID.String() + `"]}`,
+			expectedFilter: func(f models.ImageFilter) bool {
+				return len(f.IDs) == 2
+			},
+			mockImages:     []models.Image{first},
+			expectedStatus: http.StatusNotFound,
+			expectedError:  "image not found",
+		},
+		{
+			name: "Nothing Matches",
+			key:  userKey,
+			body: `{"status":"failed"}`,
+			expectedFilter: func(f models.ImageFilter) bool {
+				return f.Status == "failed"
+			},
+			mockImages:     []models.Image{},
+			expectedStatus: http.StatusNotFound,
+			expectedError:  "no images found",
+		},
+		{
+			name: "Too Many Images",
+			key:  userKey,
+			body: `{"status":"processed"}`,
+			expectedFilter: func(f models.ImageFilter) bool {
+				return true
+			},
+			mockImages:     make([]models.Image, 1001),
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "too many images",
+		},
+		{
+			name:           "No Filter",
+			key:            userKey,
+			body:           `{}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "ids or a filter is required",
+		},
+		{
+			name:           "Invalid Status",
+			key:            userKey,
+			body:           `{"status":"unknown"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "field Status is not valid",
+		},
+		{
+			name:           "Empty Body",
+			key:            userKey,
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "empty request",
+		},
+		{
+			name: "Storage Error",
+			key:  userKey,
+			body: `{"status":"processed"}`,
+			expectedFilter: func(f models.ImageFilter) bool {
+				return true
+			},
+			mockListErr:    errors.New("db error"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedError:  "failed to get images",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			imageListerMock := mocks.NewImageLister(t)
+			blobOpenerMock := mocks.NewBlobOpener(t)
+
+			if tt.expectedFilter != nil {
+				imageListerMock.On("ListImages", mock.Anything, mock.MatchedBy(tt.expectedFilter)).
+					Return(tt.mockImages, tt.mockListErr).Once()
+			}
+			if tt.expectedFiles != nil {
+				imageListerMock.On("ListVariants", mock.Anything, mock.Anything).Return(variants, nil).Once()
+				blobOpenerMock.On("Open", mock.Anything, mock.Anything).
+					Return(func(_ context.Context, key string) (io.ReadSeekCloser, *storage.BlobInfo, error) {
+						return nopSeekCloser{bytes.NewReader([]byte(key))}, &storage.BlobInfo{Key: key}, nil
+					})
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/images/archive", bytes.NewReader([]byte(tt.body)))
+			req = req.WithContext(tenant.WithID(apikey.WithKey(req.Context(), tt.key), tt.key.TenantID))
+
+			rr := httptest.NewRecorder()
+
+			handler := exportImages.New(log, imageListerMock, blobOpenerMock)
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+
+			if tt.expectedFiles == nil {
+				var resp struct {
+					Error string `json:"error"`
+				}
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+				require.Contains(t, resp.Error, tt.expectedError)
+				return
+			}
+
+			require.Equal(t, `attachment; filename="shop-images.zip"`, rr.Header().Get("Content-Disposition"))
+
+			zr, err := zip.NewReader(bytes.NewReader(rr.Body.Bytes()), int64(rr.Body.Len()))
+			require.NoError(t, err)
+
+			var files []string
+			for _, f := range zr.File {
+				files = append(files, f.Name)
+			}
+			sort.Strings(files)
+			sort.Strings(tt.expectedFiles)
+			require.Equal(t, tt.expectedFiles, files)
+		})
+	}
+}
@@ -0,0 +1,92 @@
+// Code generated by mockery v2.51.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+
+	models "imageProcessor/internal/models"
+
+	uuid "github.com/google/uuid"
+)
+
+// ImageLister is an autogenerated mock type for the ImageLister type
+type ImageLister struct {
+	mock.Mock
+}
+
+// ListImages provides a mock function with given fields: ctx, filter
+func (_m *ImageLister) ListImages(ctx context.Context, filter models.ImageFilter) ([]models.Image, error) {
+	ret := _m.Called(ctx, filter)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListImages")
+	}
+
+	var r0 []models.Image
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.ImageFilter) ([]models.Image, error)); ok {
+		return rf(ctx, filter)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, models.ImageFilter) []models.Image); ok {
+		r0 = rf(ctx, filter)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Image)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, models.ImageFilter) error); ok {
+		r1 = rf(ctx, filter)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListVariants provides a mock function with given fields: ctx, imageIDs
+func (_m *ImageLister) ListVariants(ctx context.Context, imageIDs []uuid.UUID) ([]models.Variant, error) {
+	ret := _m.Called(ctx, imageIDs)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListVariants")
+	}
+
+	var r0 []models.Variant
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []uuid.UUID) ([]models.Variant, error)); ok {
+		return rf(ctx, imageIDs)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []uuid.UUID) []models.Variant); ok {
+		r0 = rf(ctx, imageIDs)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Variant)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []uuid.UUID) error); ok {
+		r1 = rf(ctx, imageIDs)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewImageLister creates a new instance of ImageLister. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewImageLister(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *ImageLister {
+	mock := &ImageLister{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
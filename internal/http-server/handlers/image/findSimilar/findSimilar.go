@@ -0,0 +1,149 @@
+package findSimilar
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"imageProcessor/internal/lib/api/response"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/lib/logger/sl"
+	"imageProcessor/internal/models"
+	"imageProcessor/internal/storage"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+)
+
+const (
+	defaultMaxDistance = 10
+	// maxMaxDistance bounds the search: past it the chunk indexes would
+	// have to be probed for too many values to stay fast.
+	maxMaxDistance = 12
+	defaultLimit   = 20
+	maxLimit       = 100
+)
+
+type Response struct {
+	response.Response
+	MaxDistance int                   `json:"max_distance"`
+	Images      []models.SimilarImage `json:"images"`
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=SimilarFinder
+type SimilarFinder interface {
+	GetImage(ctx context.Context, id uuid.UUID) (*models.Image, error)
+	FindSimilarImages(ctx context.Context, id uuid.UUID, filter models.SimilarFilter) ([]models.SimilarImage, error)
+}
+
+// FindSimilar lists the near-duplicates of an image.
+// @Summary      Find similar images
+// @Description  Lists the images whose perceptual hash (pHash) is at most max_distance bits from that of the image, closest first, which finds resized, recompressed or slightly edited copies of it. Other keys' images are only listed for admin keys. The image has to be processed first.
+// @Tags         images
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id            path      string  true   "Image ID"
+// @Param        max_distance  query     int     false  "Hamming distance of the hashes (0-12, default 10)"
+// @Param        limit         query     int     false  "Number of images (1-100, default 20)"
+// @Success      200  {object}  findSimilar.Response
+// @Failure      400  {object}  response.Response
+// @Failure      401  {object}  response.Response
+// @Failure      403  {object}  response.Response
+// @Failure      404  {object}  response.Response
+// @Failure      409  {object}  response.Response
+// @Failure      500  {object}  response.Response
+// @Router       /image/{id}/similar [get]
+func New(log *slog.Logger, similarFinder SimilarFinder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.image.findSimilar.New"
+
+		log := log.With(slog.String("op", op))
+
+		key, ok := apikey.FromContext(r.Context())
+		if !ok {
+			render.Status(r, http.StatusUnauthorized)
+			render.JSON(w, r, response.Error("missing api key"))
+			return
+		}
+
+		imageID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			log.Error("failed to parse image ID", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error("invalid image ID"))
+			return
+		}
+
+		filter := models.SimilarFilter{MaxDistance: defaultMaxDistance, Limit: defaultLimit}
+		if s := r.URL.Query().Get("max_distance"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 0 || n > maxMaxDistance {
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, response.Error("invalid max_distance"))
+				return
+			}
+			filter.MaxDistance = n
+		}
+		if s := r.URL.Query().Get("limit"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 1 || n > maxLimit {
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, response.Error("invalid limit"))
+				return
+			}
+			filter.Limit = n
+		}
+
+		image, err := similarFinder.GetImage(r.Context(), imageID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				log.Warn("image not found", slog.String("image_id", imageID.String()))
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, response.Error("image not found"))
+				return
+			}
+
+			log.Error("failed to get image from storage", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to get image"))
+			return
+		}
+
+		if !apikey.CanAccess(r.Context(), image.OwnerKeyID) {
+			log.Warn("image belongs to another api key", slog.String("image_id", imageID.String()))
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, response.Error("image not found"))
+			return
+		}
+
+		if !slices.Contains(key.Scopes, apikey.ScopeAdmin) {
+			filter.OwnerKeyID = &key.ID
+		}
+
+		images, err := similarFinder.FindSimilarImages(r.Context(), imageID, filter)
+		if err != nil {
+			if errors.Is(err, storage.ErrNotHashed) {
+				log.Warn("image is not hashed yet", slog.String("image_id", imageID.String()), slog.String("status", image.Status))
+				render.Status(r, http.StatusConflict)
+				render.JSON(w, r, response.Error("image is not processed yet"))
+				return
+			}
+
+			log.Error("failed to find similar images", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to find similar images"))
+			return
+		}
+
+		log.Info("similar images found", slog.String("image_id", imageID.String()), slog.Int("count", len(images)))
+
+		render.JSON(w, r, Response{
+			Response:    response.OK(),
+			MaxDistance: filter.MaxDistance,
+			Images:      images,
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package findSimilar_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"imageProcessor/internal/http-server/handlers/image/findSimilar"
+	"imageProcessor/internal/http-server/handlers/image/findSimilar/mocks"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/models"
+	"imageProcessor/internal/storage"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFindSimilar(t *testing.T) {
+	log := slog.New(slog.NewJSONHandler(bytes.NewBuffer(nil), nil))
+
+	imageID := uuid.New()
+	copyID := uuid.New()
+	createdAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	ownerKey := &models.APIKey{ID: uuid.New(), Scopes: []string{apikey.ScopeRead}}
+	otherKey := &models.APIKey{ID: uuid.New(), Scopes: []string{apikey.ScopeRead}}
+	adminKey := &models.APIKey{ID: uuid.New(), Scopes: []string{apikey.ScopeAdmin}}
+
+	image := &models.Image{ID: imageID, TenantID: "shop", Status: "processed", OwnerKeyID: &ownerKey.ID, CreatedAt: createdAt, UpdatedAt: createdAt}
+	similar := []models.SimilarImage{
+		{Image: models.Image{ID: copyID, TenantID: "shop", Status: "processed", OwnerKeyID: &ownerKey.ID, CreatedAt: createdAt, UpdatedAt: createdAt}, Distance: 3},
+	}
+	similarBody := fmt.Sprintf(`{"image":{"ID":"%s","TenantID":"shop","Filename":"","Status":"processed","OriginalPath":"","Size":0,"ProcessedPathResize":null,"ProcessedPathThumbnail":null,"ProcessedPathWatermark":null,"OwnerKeyID":"%s","CallbackURL":null,"BatchID":null,"SHA256":null,"UploadExpiresAt":null,"CreatedAt":"2030-01-01T00:00:00Z","UpdatedAt":"2030-01-01T00:00:00Z"},"distance":3}`, copyID, ownerKey.ID)
+
+	tests := []struct {
+		name           string
+		imageID        string
+		query          string
+		key            *models.APIKey
+		mockImage      *models.Image
+		mockImageErr   error
+		expectedFilter *models.SimilarFilter
+		mockSimilar    []models.SimilarImage
+		mockErr        error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Defaults",
+			imageID:        imageID.String(),
+			key:            ownerKey,
+			mockImage:      image,
+			expectedFilter: &models.SimilarFilter{MaxDistance: 10, OwnerKeyID: &ownerKey.ID, Limit: 20},
+			mockSimilar:    similar,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"status":"OK","max_distance":10,"images":[` + similarBody + `]}`,
+		},
+		{
+			name:           "Admin Sees Every Key",
+			imageID:        imageID.String(),
+			query:          "?max_distance=4&limit=5",
+			key:            adminKey,
+			mockImage:      image,
+			expectedFilter: &models.SimilarFilter{MaxDistance: 4, Limit: 5},
+			mockSimilar:    []models.SimilarImage{},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"status":"OK","max_distance":4,"images":[]}`,
+		},
+		{
+			name:           "Exact Copies Only",
+			imageID:        imageID.String(),
+			query:          "?max_distance=0",
+			key:            ownerKey,
+			mockImage:      image,
+			expectedFilter: &models.SimilarFilter{MaxDistance: 0, OwnerKeyID: &ownerKey.ID, Limit: 20},
+			mockSimilar:    []models.SimilarImage{},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"status":"OK","max_distance":0,"images":[]}`,
+		},
+		{
+			name:           "Distance Too Large",
+			imageID:        imageID.String(),
+			query:          "?max_distance=13",
+			key:            ownerKey,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"Error","error":"invalid max_distance"}`,
+		},
+		{
+			name:           "Invalid Limit",
+			imageID:        imageID.String(),
+			query:          "?limit=0",
+			key:            ownerKey,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"Error","error":"invalid limit"}`,
+		},
+		{
+			name:           "Invalid UUID",
+			imageID:        "invalid-uuid",
+			key:            ownerKey,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"Error","error":"invalid image ID"}`,
+		},
+		{
+			name:           "Not Found",
+			imageID:        imageID.String(),
+			key:            ownerKey,
+			mockImageErr:   sql.ErrNoRows,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"status":"Error","error":"image not found"}`,
+		},
+		{
+			name:           "Other Owner",
+			imageID:        imageID.String(),
+			key:            otherKey,
+			mockImage:      image,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"status":"Error","error":"image not found"}`,
+		},
+		{
+			name:           "Not Processed",
+			imageID:        imageID.String(),
+			key:            ownerKey,
+			mockImage:      image,
+			expectedFilter: &models.SimilarFilter{MaxDistance: 10, OwnerKeyID: &ownerKey.ID, Limit: 20},
+			mockErr:        fmt.Errorf("storage: %w", storage.ErrNotHashed),
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"status":"Error","error":"image is not processed yet"}`,
+		},
+		{
+			name:           "Internal Error",
+			imageID:        imageID.String(),
+			key:            ownerKey,
+			mockImage:      image,
+			expectedFilter: &models.SimilarFilter{MaxDistance: 10, OwnerKeyID: &ownerKey.ID, Limit: 20},
+			mockErr:        errors.New("db error"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"status":"Error","error":"failed to find similar images"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			similarFinderMock := mocks.NewSimilarFinder(t)
+
+			if tt.mockImage != nil || tt.mockImageErr != nil {
+				similarFinderMock.On("GetImage", mock.Anything, imageID).Return(tt.mockImage, tt.mockImageErr).Once()
+			}
+			if tt.expectedFilter != nil {
+				similarFinderMock.On("FindSimilarImages", mock.Anything, imageID, *tt.expectedFilter).Return(tt.mockSimilar, tt.mockErr).Once()
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/image/"+tt.imageID+"/similar"+tt.query, nil)
+
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", tt.imageID)
+			req = req.WithContext(apikey.WithKey(context.WithValue(req.Context(), chi.RouteCtxKey, rctx), tt.key))
+
+			rr := httptest.NewRecorder()
+
+			handler := findSimilar.New(log, similarFinderMock)
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+
+			var actualMap, expectedMap map[string]interface{}
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &actualMap))
+			require.NoError(t, json.Unmarshal([]byte(tt.expectedBody), &expectedMap))
+			require.Equal(t, expectedMap, actualMap)
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package getArchive
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"imageProcessor/internal/lib/api/response"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/lib/imagezip"
+	"imageProcessor/internal/lib/logger/sl"
+	"imageProcessor/internal/models"
+	"imageProcessor/internal/storage"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=ImageGetter
+type ImageGetter interface {
+	GetImage(ctx context.Context, id uuid.UUID) (*models.Image, error)
+	ListVariants(ctx context.Context, imageIDs []uuid.UUID) ([]models.Variant, error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=BlobOpener
+type BlobOpener interface {
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, *storage.BlobInfo, error)
+}
+
+// GetArchive downloads an image with all its variants as a ZIP archive.
+// @Summary      Download an image as ZIP
+// @Description  Streams a ZIP archive holding the original of an image, every stored variant in every format (variants/<name>.<ext>) and a metadata.json with the image and its variants.
+// @Tags         images
+// @Produce      application/zip
+// @Security     ApiKeyAuth
+// @Param        id   path      string  true  "Image ID"
+// @Success      200  {file}    file
+// @Failure      400  {object}  response.Response
+// @Failure      401  {object}  response.Response
+// @Failure      403  {object}  response.Response
+// @Failure      404  {object}  response.Response
+// @Failure      500  {object}  response.Response
+// @Router       /image/{id}/archive [get]
+func New(log *slog.Logger, imageGetter ImageGetter, blobOpener BlobOpener) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.image.getArchive.New"
+
+		log := log.With(slog.String("op", op))
+
+		imageID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			log.Error("failed to parse image ID", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error("invalid image ID"))
+			return
+		}
+
+		image, err := imageGetter.GetImage(r.Context(), imageID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				log.Warn("image not found", slog.String("image_id", imageID.String()))
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, response.Error("image not found"))
+				return
+			}
+
+			log.Error("failed to get image from storage", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to get image"))
+			return
+		}
+
+		if !apikey.CanAccess(r.Context(), image.OwnerKeyID) {
+			log.Warn("image belongs to another api key", slog.String("image_id", imageID.String()))
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, response.Error("image not found"))
+			return
+		}
+
+		variants, err := imageGetter.ListVariants(r.Context(), []uuid.UUID{image.ID})
+		if err != nil {
+			log.Error("failed to list variants", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to get image"))
+			return
+		}
+
+		zw := imagezip.Start(w, image.ID.String()+".zip")
+
+		// Once the archive has started, a failure can only cut it short,
+		// which leaves the client with an archive it cannot open.
+		if err = imagezip.Add(r.Context(), zw, blobOpener, "", image, variants); err != nil {
+			log.Error("failed to write archive", slog.String("image_id", imageID.String()), sl.Err(err))
+			return
+		}
+		if err = zw.Close(); err != nil {
+			log.Error("failed to finish archive", slog.String("image_id", imageID.String()), sl.Err(err))
+			return
+		}
+
+		log.Info("image archive sent", slog.String("image_id", imageID.String()))
+	}
+}
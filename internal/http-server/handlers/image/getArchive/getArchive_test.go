@@ -0,0 +1,175 @@
+package getArchive_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"imageProcessor/internal/http-server/handlers/image/getArchive"
+	"imageProcessor/internal/http-server/handlers/image/getArchive/mocks"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/lib/imagezip"
+	"imageProcessor/internal/models"
+	"imageProcessor/internal/storage"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type nopSeekCloser struct {
+	io.ReadSeeker
+}
+
+func (nopSeekCloser) Close() error { return nil }
+
+func TestGetArchive(t *testing.T) {
+	log := slog.New(slog.NewJSONHandler(bytes.NewBuffer(nil), nil))
+
+	testUUID := uuid.New()
+	ownerKey := &models.APIKey{ID: uuid.New(), Scopes: []string{apikey.ScopeRead}}
+	otherKey := &models.APIKey{ID: uuid.New(), Scopes: []string{apikey.ScopeRead}}
+
+	testImage := &models.Image{ID: testUUID, TenantID: "shop", Filename: "cat.JPG", Status: "processed", OriginalPath: "shop/uploads/abc.jpg", OwnerKeyID: &ownerKey.ID}
+	variants := []models.Variant{
+		{ImageID: testUUID, Name: "resize", Format: "jpeg", BlobKey: "shop/processed/abc_resize.jpg"},
+		{ImageID: testUUID, Name: "resize", Format: "png", BlobKey: "shop/processed/abc_resize.png"},
+		{ImageID: testUUID, Name: "thumbnail", Format: "jpeg", BlobKey: "shop/processed/abc_thumbnail.jpg"},
+	}
+	blobs := map[string]string{
+		"shop/uploads/abc.jpg":             "original",
+		"shop/processed/abc_resize.jpg":    "resize jpeg",
+		"shop/processed/abc_resize.png":    "resize png",
+		"shop/processed/abc_thumbnail.jpg": "thumbnail jpeg",
+	}
+
+	tests := []struct {
+		name           string
+		imageID        string
+		key            *models.APIKey
+		mockImageErr   error
+		mockListErr    error
+		expectedStatus int
+		expectedFiles  map[string]string
+		expectedBody   string
+	}{
+		{
+			name:           "Success",
+			imageID:        testUUID.String(),
+			key:            ownerKey,
+			expectedStatus: http.StatusOK,
+			expectedFiles: map[string]string{
+				"original.jpg":           "original",
+				"variants/resize.jpg":    "resize jpeg",
+				"variants/resize.png":    "resize png",
+				"variants/thumbnail.jpg": "thumbnail jpeg",
+			},
+		},
+		{
+			name:           "Other Owner",
+			imageID:        testUUID.String(),
+			key:            otherKey,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"status":"Error","error":"image not found"}`,
+		},
+		{
+			name:           "Invalid UUID",
+			imageID:        "invalid-uuid",
+			key:            ownerKey,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"Error","error":"invalid image ID"}`,
+		},
+		{
+			name:           "Not Found",
+			imageID:        testUUID.String(),
+			key:            ownerKey,
+			mockImageErr:   sql.ErrNoRows,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"status":"Error","error":"image not found"}`,
+		},
+		{
+			name:           "Failed to List Variants",
+			imageID:        testUUID.String(),
+			key:            ownerKey,
+			mockListErr:    errors.New("db error"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"status":"Error","error":"failed to get image"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			imageGetterMock := mocks.NewImageGetter(t)
+			blobOpenerMock := mocks.NewBlobOpener(t)
+
+			if tt.imageID == testUUID.String() {
+				if tt.mockImageErr != nil {
+					imageGetterMock.On("GetImage", mock.Anything, testUUID).Return(nil, tt.mockImageErr).Once()
+				} else {
+					imageGetterMock.On("GetImage", mock.Anything, testUUID).Return(testImage, nil).Once()
+				}
+			}
+			if tt.key == ownerKey && tt.mockImageErr == nil && tt.imageID == testUUID.String() {
+				imageGetterMock.On("ListVariants", mock.Anything, []uuid.UUID{testUUID}).Return(variants, tt.mockListErr).Once()
+			}
+			if tt.expectedFiles != nil {
+				for key, content := range blobs {
+					blobOpenerMock.On("Open", mock.Anything, key).
+						Return(nopSeekCloser{bytes.NewReader([]byte(content))}, &storage.BlobInfo{Key: key}, nil).Once()
+				}
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/image/"+tt.imageID+"/archive", nil)
+
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", tt.imageID)
+			req = req.WithContext(apikey.WithKey(context.WithValue(req.Context(), chi.RouteCtxKey, rctx), tt.key))
+
+			rr := httptest.NewRecorder()
+
+			handler := getArchive.New(log, imageGetterMock, blobOpenerMock)
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+
+			if tt.expectedFiles == nil {
+				require.JSONEq(t, tt.expectedBody, rr.Body.String())
+				return
+			}
+
+			require.Equal(t, "application/zip", rr.Header().Get("Content-Type"))
+			require.Equal(t, `attachment; filename="`+testUUID.String()+`.zip"`, rr.Header().Get("Content-Disposition"))
+
+			zr, err := zip.NewReader(bytes.NewReader(rr.Body.Bytes()), int64(rr.Body.Len()))
+			require.NoError(t, err)
+
+			files := make(map[string]string)
+			var metadata imagezip.Metadata
+			for _, f := range zr.File {
+				rc, err := f.Open()
+				require.NoError(t, err)
+				data, err := io.ReadAll(rc)
+				require.NoError(t, err)
+				rc.Close()
+
+				if f.Name == imagezip.MetadataFile {
+					require.NoError(t, json.Unmarshal(data, &metadata))
+					continue
+				}
+				files[f.Name] = string(data)
+			}
+
+			require.Equal(t, tt.expectedFiles, files)
+			require.Equal(t, testUUID, metadata.Image.ID)
+			require.Equal(t, "cat.JPG", metadata.Image.Filename)
+			require.Len(t, metadata.Variants, len(variants))
+		})
+	}
+}
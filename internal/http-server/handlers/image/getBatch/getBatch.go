@@ -0,0 +1,100 @@
+package getBatch
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"imageProcessor/internal/lib/api/response"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/lib/logger/sl"
+	"imageProcessor/internal/models"
+	"log/slog"
+	"net/http"
+)
+
+type Response struct {
+	response.Response
+	Batch models.Batch `json:"batch"`
+	// Counts holds the number of images in each status.
+	Counts map[string]int `json:"counts"`
+	// Done is set once every image is processed or has failed.
+	Done   bool                `json:"done"`
+	Images []models.BatchImage `json:"images"`
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=BatchGetter
+type BatchGetter interface {
+	GetBatch(ctx context.Context, id uuid.UUID) (*models.Batch, []models.BatchImage, error)
+}
+
+// GetBatch reports the progress of a batch upload.
+// @Summary      Get batch status
+// @Description  Returns the images of a batch upload with their statuses, the number of images in each status and whether all of them are done. Images deleted since the upload are not listed.
+// @Tags         images
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      string  true  "Batch ID"
+// @Success      200  {object}  getBatch.Response
+// @Failure      400  {object}  response.Response
+// @Failure      401  {object}  response.Response
+// @Failure      403  {object}  response.Response
+// @Failure      404  {object}  response.Response
+// @Failure      500  {object}  response.Response
+// @Router       /uploads/batch/{id} [get]
+func New(log *slog.Logger, batchGetter BatchGetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.image.getBatch.New"
+
+		log := log.With(slog.String("op", op))
+
+		batchID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			log.Error("failed to parse batch ID", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error("invalid batch ID"))
+			return
+		}
+
+		batch, images, err := batchGetter.GetBatch(r.Context(), batchID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				log.Warn("batch not found", slog.String("batch_id", batchID.String()))
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, response.Error("batch not found"))
+				return
+			}
+
+			log.Error("failed to get batch", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to get batch"))
+			return
+		}
+
+		if !apikey.CanAccess(r.Context(), batch.OwnerKeyID) {
+			log.Warn("batch belongs to another api key", slog.String("batch_id", batchID.String()))
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, response.Error("batch not found"))
+			return
+		}
+
+		counts := make(map[string]int)
+		done := true
+		for _, image := range images {
+			counts[image.Status]++
+			if image.Status != "processed" && image.Status != "failed" {
+				done = false
+			}
+		}
+
+		render.JSON(w, r, Response{
+			Response: response.OK(),
+			Batch:    *batch,
+			Counts:   counts,
+			Done:     done,
+			Images:   images,
+		})
+	}
+}
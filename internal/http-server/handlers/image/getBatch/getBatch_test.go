@@ -0,0 +1,132 @@
+package getBatch_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"imageProcessor/internal/http-server/handlers/image/getBatch"
+	"imageProcessor/internal/http-server/handlers/image/getBatch/mocks"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/models"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGetBatch(t *testing.T) {
+	log := slog.New(slog.NewJSONHandler(bytes.NewBuffer(nil), nil))
+
+	batchID := uuid.New()
+	firstID := uuid.New()
+	secondID := uuid.New()
+	createdAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	ownerKey := &models.APIKey{ID: uuid.New(), Scopes: []string{apikey.ScopeRead}}
+	otherKey := &models.APIKey{ID: uuid.New(), Scopes: []string{apikey.ScopeRead}}
+	adminKey := &models.APIKey{ID: uuid.New(), Scopes: []string{apikey.ScopeAdmin}}
+
+	batch := &models.Batch{ID: batchID, TenantID: "shop", OwnerKeyID: &ownerKey.ID, CreatedAt: createdAt}
+
+	tests := []struct {
+		name           string
+		batchID        string
+		key            *models.APIKey
+		mockImages     []models.BatchImage
+		mockErr        error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:    "In Progress",
+			batchID: batchID.String(),
+			key:     ownerKey,
+			mockImages: []models.BatchImage{
+				{ID: firstID, Filename: "a.jpg", Status: "processed"},
+				{ID: secondID, Filename: "b.jpg", Status: "processing"},
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   fmt.Sprintf(`{"status":"OK","batch":{"ID":"%s","TenantID":"shop","OwnerKeyID":"%s","CreatedAt":"2030-01-01T00:00:00Z"},"counts":{"processed":1,"processing":1},"done":false,"images":[{"ID":"%s","Filename":"a.jpg","Status":"processed"},{"ID":"%s","Filename":"b.jpg","Status":"processing"}]}`, batchID, ownerKey.ID, firstID, secondID),
+		},
+		{
+			name:    "Done",
+			batchID: batchID.String(),
+			key:     adminKey,
+			mockImages: []models.BatchImage{
+				{ID: firstID, Filename: "a.jpg", Status: "processed"},
+				{ID: secondID, Filename: "b.jpg", Status: "failed"},
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   fmt.Sprintf(`{"status":"OK","batch":{"ID":"%s","TenantID":"shop","OwnerKeyID":"%s","CreatedAt":"2030-01-01T00:00:00Z"},"counts":{"processed":1,"failed":1},"done":true,"images":[{"ID":"%s","Filename":"a.jpg","Status":"processed"},{"ID":"%s","Filename":"b.jpg","Status":"failed"}]}`, batchID, ownerKey.ID, firstID, secondID),
+		},
+		{
+			name:           "Other Owner",
+			batchID:        batchID.String(),
+			key:            otherKey,
+			mockImages:     []models.BatchImage{},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"status":"Error","error":"batch not found"}`,
+		},
+		{
+			name:           "Invalid UUID",
+			batchID:        "invalid-uuid",
+			key:            ownerKey,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"Error","error":"invalid batch ID"}`,
+		},
+		{
+			name:           "Not Found",
+			batchID:        batchID.String(),
+			key:            ownerKey,
+			mockErr:        sql.ErrNoRows,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"status":"Error","error":"batch not found"}`,
+		},
+		{
+			name:           "Internal Error",
+			batchID:        batchID.String(),
+			key:            ownerKey,
+			mockErr:        errors.New("db error"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"status":"Error","error":"failed to get batch"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			batchGetterMock := mocks.NewBatchGetter(t)
+
+			if tt.mockImages != nil {
+				batchGetterMock.On("GetBatch", mock.Anything, batchID).Return(batch, tt.mockImages, nil).Once()
+			} else if tt.mockErr != nil {
+				batchGetterMock.On("GetBatch", mock.Anything, batchID).Return(nil, nil, tt.mockErr).Once()
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/uploads/batch/"+tt.batchID, nil)
+
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", tt.batchID)
+			req = req.WithContext(apikey.WithKey(context.WithValue(req.Context(), chi.RouteCtxKey, rctx), tt.key))
+
+			rr := httptest.NewRecorder()
+
+			handler := getBatch.New(log, batchGetterMock)
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+
+			var actualMap, expectedMap map[string]interface{}
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &actualMap))
+			require.NoError(t, json.Unmarshal([]byte(tt.expectedBody), &expectedMap))
+			require.Equal(t, expectedMap, actualMap)
+		})
+	}
+}
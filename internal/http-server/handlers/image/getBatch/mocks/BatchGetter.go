@@ -0,0 +1,71 @@
+// Code generated by mockery v2.51.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+
+	models "imageProcessor/internal/models"
+
+	uuid "github.com/google/uuid"
+)
+
+// BatchGetter is an autogenerated mock type for the BatchGetter type
+type BatchGetter struct {
+	mock.Mock
+}
+
+// GetBatch provides a mock function with given fields: ctx, id
+func (_m *BatchGetter) GetBatch(ctx context.Context, id uuid.UUID) (*models.Batch, []models.BatchImage, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetBatch")
+	}
+
+	var r0 *models.Batch
+	var r1 []models.BatchImage
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) (*models.Batch, []models.BatchImage, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) *models.Batch); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.Batch)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID) []models.BatchImage); ok {
+		r1 = rf(ctx, id)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).([]models.BatchImage)
+		}
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, uuid.UUID) error); ok {
+		r2 = rf(ctx, id)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// NewBatchGetter creates a new instance of BatchGetter. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewBatchGetter(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *BatchGetter {
+	mock := &BatchGetter{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -7,39 +7,63 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
 	"github.com/google/uuid"
+	"imageProcessor/internal/events"
 	"imageProcessor/internal/lib/api/response"
+	"imageProcessor/internal/lib/apikey"
 	"imageProcessor/internal/lib/logger/sl"
+	"imageProcessor/internal/lib/mediaurl"
+	"imageProcessor/internal/lib/tenant"
 	"imageProcessor/internal/models"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 )
 
 type Response struct {
 	response.Response
-	Image models.Image `json:"image"`
+	Image models.Image  `json:"image"`
+	URLs  mediaurl.URLs `json:"urls"`
 }
 
 //go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=ImageGetter
 type ImageGetter interface {
 	GetImage(ctx context.Context, id uuid.UUID) (*models.Image, error)
+	ListVariants(ctx context.Context, imageIDs []uuid.UUID) ([]models.Variant, error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=URLSigner
+type URLSigner interface {
+	Sign(path string, params url.Values) string
+	ExpiresAt() time.Time
+}
+
+type Subscriber interface {
+	Subscribe(f events.Filter) *events.Subscription
 }
 
 // GetImage retrieves an image metadata by ID.
 // @Summary      Get image metadata
-// @Description  Retrieves an image's metadata (status, paths) by its ID.
+// @Description  Retrieves an image's metadata (status, paths) by its ID together with signed URLs of the original and its variants, and, once it is processed, a srcset of its width variants with a sizes hint. With wait set, the request blocks until the image is processed or has failed (or reaches the status given by until), or the wait runs out; the image is returned as it is at that point either way.
 // @Tags         images
 // @Produce      json
-// @Param        id   path      string  true  "Image ID"
+// @Security     ApiKeyAuth
+// @Param        id     path      string  true   "Image ID"
+// @Param        wait   query     string  false  "How long to wait, e.g. 30s; capped by the server"
+// @Param        until  query     string  false  "Status to wait for: processed or failed; any final status by default"
 // @Success      200  {object}  getImage.Response
 // @Failure      400  {object}  response.Response
+// @Failure      401  {object}  response.Response
+// @Failure      403  {object}  response.Response
 // @Failure      404  {object}  response.Response
 // @Failure      500  {object}  response.Response
 // @Router       /image/{id} [get]
-func New(log *slog.Logger, imageGetter ImageGetter) http.HandlerFunc {
+func New(log *slog.Logger, imageGetter ImageGetter, urlSigner URLSigner, subscriber Subscriber, sizes string, maxWait, writeTimeout time.Duration) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.image.getImage.New"
 
-		log = log.With(slog.String("op", op))
+		log := log.With(slog.String("op", op))
 
 		idStr := chi.URLParam(r, "id")
 		imageID, err := uuid.Parse(idStr)
@@ -50,6 +74,38 @@ func New(log *slog.Logger, imageGetter ImageGetter) http.HandlerFunc {
 			return
 		}
 
+		wait, err := parseWait(r.URL.Query().Get("wait"), maxWait)
+		if err != nil {
+			log.Error("failed to parse wait", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error("invalid wait"))
+			return
+		}
+
+		until := r.URL.Query().Get("until")
+		if until != "" && until != statusProcessed && until != statusFailed {
+			log.Error("invalid until", slog.String("until", until))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error("invalid until"))
+			return
+		}
+
+		var sub *events.Subscription
+		if wait > 0 {
+			tenantID, err := tenant.FromContext(r.Context())
+			if err != nil {
+				log.Error("failed to get tenant", sl.Err(err))
+				render.Status(r, http.StatusInternalServerError)
+				render.JSON(w, r, response.Error("internal error"))
+				return
+			}
+
+			// Subscribe before reading the image so that a change made in
+			// between is not missed.
+			sub = subscriber.Subscribe(events.Filter{TenantID: tenantID, ImageID: &imageID})
+			defer sub.Close()
+		}
+
 		image, err := imageGetter.GetImage(r.Context(), imageID)
 		if err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
@@ -65,11 +121,110 @@ func New(log *slog.Logger, imageGetter ImageGetter) http.HandlerFunc {
 			return
 		}
 
+		if !apikey.CanAccess(r.Context(), image.OwnerKeyID) {
+			log.Warn("image belongs to another api key", slog.String("image_id", imageID.String()))
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, response.Error("image not found"))
+			return
+		}
+
+		if sub != nil && !reached(image.Status, until) {
+			// The server's write timeout would cut the wait short.
+			_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(wait + writeTimeout))
+
+			if !waitFor(r.Context(), sub, until, wait) {
+				return
+			}
+
+			image, err = imageGetter.GetImage(r.Context(), imageID)
+			if err != nil {
+				log.Error("failed to get image from storage", sl.Err(err))
+				render.Status(r, http.StatusInternalServerError)
+				render.JSON(w, r, response.Error("failed to get image"))
+				return
+			}
+		}
+
+		urls := mediaurl.For(image, urlSigner)
+
+		if image.Status == statusProcessed {
+			variants, err := imageGetter.ListVariants(r.Context(), []uuid.UUID{image.ID})
+			if err != nil {
+				log.Error("failed to list variants", sl.Err(err))
+				render.Status(r, http.StatusInternalServerError)
+				render.JSON(w, r, response.Error("failed to get image"))
+				return
+			}
+
+			// Images processed before the width ladder have no srcset.
+			if widths := models.Widths(variants); len(widths) > 0 {
+				urls.Srcset = mediaurl.Srcset(image, widths, "", urlSigner, "")
+				urls.Sizes = sizes
+			}
+		}
+
 		log.Info("image retrieved successfully", slog.String("image_id", imageID.String()))
 
 		render.JSON(w, r, Response{
 			Response: response.OK(),
 			Image:    *image,
+			URLs:     urls,
 		})
 	}
 }
+
+const (
+	statusProcessed = "processed"
+	statusFailed    = "failed"
+)
+
+// parseWait reads a wait given as a duration or in seconds, capped at
+// maxWait.
+func parseWait(s string, maxWait time.Duration) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	wait, err := time.ParseDuration(s)
+	if err != nil {
+		seconds, err := strconv.Atoi(s)
+		if err != nil {
+			return 0, err
+		}
+		wait = time.Duration(seconds) * time.Second
+	}
+
+	if wait < 0 {
+		return 0, errors.New("negative wait")
+	}
+
+	return min(wait, maxWait), nil
+}
+
+// reached reports whether an image in status needs no more waiting for: it
+// has the status asked for or can no longer change.
+func reached(status, until string) bool {
+	return status == until || status == statusProcessed || status == statusFailed
+}
+
+// waitFor blocks until sub announces a status that is reached or wait runs
+// out. It returns false if the client went away meanwhile.
+func waitFor(ctx context.Context, sub *events.Subscription, until string, wait time.Duration) bool {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-timer.C:
+			return true
+		case event, ok := <-sub.C:
+			// A subscription closed for falling behind may have missed the
+			// status; the image is read again either way.
+			if !ok || event.Type == models.ImageEventStatus && reached(event.Status, until) {
+				return true
+			}
+		}
+	}
+}
@@ -11,12 +11,16 @@ import (
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"imageProcessor/internal/events"
 	"imageProcessor/internal/http-server/handlers/image/getImage"
 	"imageProcessor/internal/http-server/handlers/image/getImage/mocks"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/lib/tenant"
 	"imageProcessor/internal/models"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
 	"time"
 )
@@ -26,14 +30,26 @@ func TestGetImage(t *testing.T) {
 
 	testUUID, _ := uuid.NewRandom()
 
+	resizePath := "processed/test_resized.jpg"
+	thumbnailPath := "processed/test_thumbnail.jpg"
+	watermarkPath := "processed/test_watermarked.jpg"
+
+	expiresAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	ownerKey := &models.APIKey{ID: uuid.New(), Scopes: []string{apikey.ScopeRead}}
+	otherKey := &models.APIKey{ID: uuid.New(), Scopes: []string{apikey.ScopeRead}}
+
 	testImage := &models.Image{
 		ID:                     testUUID,
+		TenantID:               "shop",
 		Filename:               "test.jpg",
 		Status:                 "processed",
-		OriginalPath:           "uploads/test.jpg",
-		ProcessedPathResize:    sql.NullString{String: "processed/test_resized.jpg", Valid: true},
-		ProcessedPathThumbnail: sql.NullString{String: "processed/test_thumbnail.jpg", Valid: true},
-		ProcessedPathWatermark: sql.NullString{String: "processed/test_watermarked.jpg", Valid: true},
+		OriginalPath:           "shop/uploads/test.jpg",
+		Size:                   2048,
+		ProcessedPathResize:    &resizePath,
+		ProcessedPathThumbnail: &thumbnailPath,
+		ProcessedPathWatermark: &watermarkPath,
+		OwnerKeyID:             &ownerKey.ID,
 		CreatedAt:              time.Now(),
 		UpdatedAt:              time.Now(),
 	}
@@ -41,18 +57,49 @@ func TestGetImage(t *testing.T) {
 	tests := []struct {
 		name           string
 		imageID        string
+		key            *models.APIKey
 		mockImage      *models.Image
 		mockErr        error
+		mockVariants   []models.Variant
+		mockVariantErr error
 		expectedStatus int
 		expectedBody   string
 	}{
 		{
 			name:           "Success",
 			imageID:        testUUID.String(),
+			key:            ownerKey,
 			mockImage:      testImage,
 			mockErr:        nil,
 			expectedStatus: http.StatusOK,
-			expectedBody:   fmt.Sprintf(`{"status":"OK","image":{"ID":"%s","Filename":"test.jpg","Status":"processed","OriginalPath":"uploads/test.jpg","ProcessedPathResize":{"String":"processed/test_resized.jpg","Valid":true},"ProcessedPathThumbnail":{"String":"processed/test_thumbnail.jpg","Valid":true},"ProcessedPathWatermark":{"String":"processed/test_watermarked.jpg","Valid":true},"CreatedAt":"%s","UpdatedAt":"%s"}}`, testUUID, testImage.CreatedAt.Format(time.RFC3339Nano), testImage.UpdatedAt.Format(time.RFC3339Nano)),
+			expectedBody:   fmt.Sprintf(`{"status":"OK","image":{"ID":"%s","TenantID":"shop","Filename":"test.jpg","Status":"processed","OriginalPath":"shop/uploads/test.jpg","Size":2048,"ProcessedPathResize":"processed/test_resized.jpg","ProcessedPathThumbnail":"processed/test_thumbnail.jpg","ProcessedPathWatermark":"processed/test_watermarked.jpg","OwnerKeyID":"%[5]s","CallbackURL":null,"BatchID":null,"SHA256":null,"UploadExpiresAt":null,"CreatedAt":"%[2]s","UpdatedAt":"%[3]s"},"urls":{"original":"signed:/image/%[1]s/original?tenant=shop","variants":{"resize":"signed:/image/%[1]s/variants/resize?tenant=shop","thumbnail":"signed:/image/%[1]s/variants/thumbnail?tenant=shop","watermark":"signed:/image/%[1]s/variants/watermark?tenant=shop"},"expires_at":"%[4]s"}}`, testUUID, testImage.CreatedAt.Format(time.RFC3339Nano), testImage.UpdatedAt.Format(time.RFC3339Nano), expiresAt.Format(time.RFC3339), ownerKey.ID),
+		},
+		{
+			name:           "Srcset",
+			imageID:        testUUID.String(),
+			key:            ownerKey,
+			mockImage:      testImage,
+			mockVariants:   []models.Variant{{Name: "resize"}, {Name: "w640", Format: "png"}, {Name: "w320"}, {Name: "w640"}},
+			expectedStatus: http.StatusOK,
+			expectedBody:   fmt.Sprintf(`{"status":"OK","image":{"ID":"%s","TenantID":"shop","Filename":"test.jpg","Status":"processed","OriginalPath":"shop/uploads/test.jpg","Size":2048,"ProcessedPathResize":"processed/test_resized.jpg","ProcessedPathThumbnail":"processed/test_thumbnail.jpg","ProcessedPathWatermark":"processed/test_watermarked.jpg","OwnerKeyID":"%[5]s","CallbackURL":null,"BatchID":null,"SHA256":null,"UploadExpiresAt":null,"CreatedAt":"%[2]s","UpdatedAt":"%[3]s"},"urls":{"original":"signed:/image/%[1]s/original?tenant=shop","variants":{"resize":"signed:/image/%[1]s/variants/resize?tenant=shop","thumbnail":"signed:/image/%[1]s/variants/thumbnail?tenant=shop","watermark":"signed:/image/%[1]s/variants/watermark?tenant=shop"},"srcset":"signed:/image/%[1]s/variants/w320?tenant=shop 320w, signed:/image/%[1]s/variants/w640?tenant=shop 640w","sizes":"(max-width: 600px) 100vw, 50vw","expires_at":"%[4]s"}}`, testUUID, testImage.CreatedAt.Format(time.RFC3339Nano), testImage.UpdatedAt.Format(time.RFC3339Nano), expiresAt.Format(time.RFC3339), ownerKey.ID),
+		},
+		{
+			name:           "Variants Error",
+			imageID:        testUUID.String(),
+			key:            ownerKey,
+			mockImage:      testImage,
+			mockVariantErr: errors.New("db error"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"status":"Error","error":"failed to get image"}`,
+		},
+		{
+			name:           "Other Owner",
+			imageID:        testUUID.String(),
+			key:            otherKey,
+			mockImage:      testImage,
+			mockErr:        nil,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"status":"Error","error":"image not found"}`,
 		},
 		{
 			name:           "Invalid UUID",
@@ -83,8 +130,16 @@ func TestGetImage(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			imageGetterMock := mocks.NewImageGetter(t)
+			urlSignerMock := mocks.NewURLSigner(t)
 
-			if tt.name == "Success" {
+			if tt.name == "Success" || tt.name == "Srcset" || tt.name == "Variants Error" {
+				imageGetterMock.On("GetImage", mock.Anything, testUUID).Return(tt.mockImage, tt.mockErr).Once()
+				imageGetterMock.On("ListVariants", mock.Anything, []uuid.UUID{testUUID}).Return(tt.mockVariants, tt.mockVariantErr).Once()
+				urlSignerMock.On("Sign", mock.Anything, mock.Anything).Return(func(path string, params url.Values) string {
+					return "signed:" + path + "?" + params.Encode()
+				})
+				urlSignerMock.On("ExpiresAt").Return(expiresAt).Once()
+			} else if tt.name == "Other Owner" {
 				imageGetterMock.On("GetImage", mock.Anything, testUUID).Return(tt.mockImage, tt.mockErr).Once()
 			} else if tt.name == "Not Found" {
 				imageGetterMock.On("GetImage", mock.Anything, testUUID).Return(tt.mockImage, tt.mockErr).Once()
@@ -97,10 +152,13 @@ func TestGetImage(t *testing.T) {
 			rctx := chi.NewRouteContext()
 			rctx.URLParams.Add("id", tt.imageID)
 			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			if tt.key != nil {
+				req = req.WithContext(apikey.WithKey(req.Context(), tt.key))
+			}
 
 			rr := httptest.NewRecorder()
 
-			handler := getImage.New(log, imageGetterMock)
+			handler := getImage.New(log, imageGetterMock, urlSignerMock, events.NewHub(), "(max-width: 600px) 100vw, 50vw", time.Minute, time.Second)
 			handler.ServeHTTP(rr, req)
 
 			require.Equal(t, tt.expectedStatus, rr.Code)
@@ -115,3 +173,136 @@ func TestGetImage(t *testing.T) {
 		})
 	}
 }
+
+func TestGetImageWait(t *testing.T) {
+	log := slog.New(slog.NewJSONHandler(bytes.NewBuffer(nil), nil))
+
+	testUUID := uuid.New()
+	key := &models.APIKey{ID: uuid.New(), TenantID: "shop", Scopes: []string{apikey.ScopeRead}}
+
+	image := func(status string) *models.Image {
+		return &models.Image{ID: testUUID, TenantID: "shop", Status: status, OwnerKeyID: &key.ID}
+	}
+	statusEvent := func(status string) models.ImageEvent {
+		return models.ImageEvent{Type: models.ImageEventStatus, TenantID: "shop", ImageID: testUUID, Status: status}
+	}
+
+	tests := []struct {
+		name           string
+		query          string
+		images         []*models.Image
+		published      []models.ImageEvent
+		expectedStatus int
+		expectedImage  string
+		expectedError  string
+	}{
+		{
+			name:           "Processed While Waiting",
+			query:          "wait=30s",
+			images:         []*models.Image{image("processing"), image("processed")},
+			published:      []models.ImageEvent{{Type: models.ImageEventVariant, TenantID: "shop", ImageID: testUUID}, statusEvent("processed")},
+			expectedStatus: http.StatusOK,
+			expectedImage:  "processed",
+		},
+		{
+			name:           "Failed While Waiting For Processed",
+			query:          "wait=30&until=processed",
+			images:         []*models.Image{image("pending"), image("failed")},
+			published:      []models.ImageEvent{statusEvent("processing"), statusEvent("failed")},
+			expectedStatus: http.StatusOK,
+			expectedImage:  "failed",
+		},
+		{
+			name:           "Already Processed",
+			query:          "wait=30s",
+			images:         []*models.Image{image("processed")},
+			expectedStatus: http.StatusOK,
+			expectedImage:  "processed",
+		},
+		{
+			name:           "Timed Out",
+			query:          "wait=10ms",
+			images:         []*models.Image{image("processing"), image("processing")},
+			published:      []models.ImageEvent{statusEvent("processing")},
+			expectedStatus: http.StatusOK,
+			expectedImage:  "processing",
+		},
+		{
+			name:           "Capped Wait",
+			query:          "wait=1h",
+			images:         []*models.Image{image("processing"), image("processing")},
+			expectedStatus: http.StatusOK,
+			expectedImage:  "processing",
+		},
+		{
+			name:           "Invalid Wait",
+			query:          "wait=soon",
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "invalid wait",
+		},
+		{
+			name:           "Negative Wait",
+			query:          "wait=-5s",
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "invalid wait",
+		},
+		{
+			name:           "Invalid Until",
+			query:          "wait=30s&until=deleted",
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "invalid until",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			imageGetterMock := mocks.NewImageGetter(t)
+			urlSignerMock := mocks.NewURLSigner(t)
+			hub := events.NewHub()
+
+			for i, img := range tt.images {
+				call := imageGetterMock.On("GetImage", mock.Anything, testUUID).Return(img, nil).Once()
+				if i == 0 {
+					// Events published while the image is read must not be lost.
+					call.Run(func(mock.Arguments) {
+						for _, e := range tt.published {
+							hub.Publish(e)
+						}
+					})
+				}
+			}
+			if tt.expectedImage != "" {
+				imageGetterMock.On("ListVariants", mock.Anything, []uuid.UUID{testUUID}).Return(nil, nil).Maybe()
+				urlSignerMock.On("Sign", mock.Anything, mock.Anything).Return("signed").Maybe()
+				urlSignerMock.On("ExpiresAt").Return(time.Now()).Once()
+			}
+
+			req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/image/%s?%s", testUUID, tt.query), nil)
+
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", testUUID.String())
+			ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+			req = req.WithContext(tenant.WithID(apikey.WithKey(ctx, key), key.TenantID))
+
+			rr := httptest.NewRecorder()
+
+			handler := getImage.New(log, imageGetterMock, urlSignerMock, hub, "100vw", 50*time.Millisecond, time.Second)
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+			require.Equal(t, 0, hub.Subscribers())
+
+			var resp struct {
+				Error string        `json:"error"`
+				Image *models.Image `json:"image"`
+			}
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+
+			if tt.expectedError != "" {
+				require.Equal(t, tt.expectedError, resp.Error)
+				return
+			}
+			require.Equal(t, tt.expectedImage, resp.Image.Status)
+		})
+	}
+}
@@ -47,6 +47,36 @@ func (_m *ImageGetter) GetImage(ctx context.Context, id uuid.UUID) (*models.Imag
 	return r0, r1
 }
 
+// ListVariants provides a mock function with given fields: ctx, imageIDs
+func (_m *ImageGetter) ListVariants(ctx context.Context, imageIDs []uuid.UUID) ([]models.Variant, error) {
+	ret := _m.Called(ctx, imageIDs)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListVariants")
+	}
+
+	var r0 []models.Variant
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []uuid.UUID) ([]models.Variant, error)); ok {
+		return rf(ctx, imageIDs)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []uuid.UUID) []models.Variant); ok {
+		r0 = rf(ctx, imageIDs)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Variant)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []uuid.UUID) error); ok {
+		r1 = rf(ctx, imageIDs)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // NewImageGetter creates a new instance of ImageGetter. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewImageGetter(t interface {
@@ -0,0 +1,103 @@
+package getMetadata
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"imageProcessor/internal/lib/api/response"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/lib/logger/sl"
+	"imageProcessor/internal/models"
+	"imageProcessor/internal/storage"
+	"log/slog"
+	"net/http"
+)
+
+type Response struct {
+	response.Response
+	Metadata *models.ImageMetadata `json:"metadata"`
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=MetadataGetter
+type MetadataGetter interface {
+	GetImage(ctx context.Context, id uuid.UUID) (*models.Image, error)
+	GetImageMetadata(ctx context.Context, imageID uuid.UUID) (*models.ImageMetadata, error)
+}
+
+// New returns the EXIF, IPTC and XMP metadata of an image.
+// @Summary      Get image metadata
+// @Description  Returns the metadata read from the original of the image: camera, lens, exposure, when and where it was taken, and every EXIF, IPTC and XMP property found. The summary fields come from EXIF first, then XMP, then IPTC. The image has to be processed first.
+// @Tags         images
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      string  true  "Image ID"
+// @Success      200  {object}  getMetadata.Response
+// @Failure      400  {object}  response.Response
+// @Failure      401  {object}  response.Response
+// @Failure      403  {object}  response.Response
+// @Failure      404  {object}  response.Response
+// @Failure      409  {object}  response.Response
+// @Failure      500  {object}  response.Response
+// @Router       /image/{id}/metadata [get]
+func New(log *slog.Logger, metadataGetter MetadataGetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.image.getMetadata.New"
+
+		log := log.With(slog.String("op", op))
+
+		imageID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			log.Error("failed to parse image ID", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error("invalid image ID"))
+			return
+		}
+
+		image, err := metadataGetter.GetImage(r.Context(), imageID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				log.Warn("image not found", slog.String("image_id", imageID.String()))
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, response.Error("image not found"))
+				return
+			}
+
+			log.Error("failed to get image from storage", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to get image"))
+			return
+		}
+
+		if !apikey.CanAccess(r.Context(), image.OwnerKeyID) {
+			log.Warn("image belongs to another api key", slog.String("image_id", imageID.String()))
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, response.Error("image not found"))
+			return
+		}
+
+		metadata, err := metadataGetter.GetImageMetadata(r.Context(), imageID)
+		if err != nil {
+			if errors.Is(err, storage.ErrNoMetadata) {
+				log.Warn("image metadata is not extracted yet", slog.String("image_id", imageID.String()), slog.String("status", image.Status))
+				render.Status(r, http.StatusConflict)
+				render.JSON(w, r, response.Error("image is not processed yet"))
+				return
+			}
+
+			log.Error("failed to get image metadata", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to get image metadata"))
+			return
+		}
+
+		log.Info("image metadata retrieved", slog.String("image_id", imageID.String()))
+
+		render.JSON(w, r, Response{
+			Response: response.OK(),
+			Metadata: metadata,
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package getMetadata_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"imageProcessor/internal/http-server/handlers/image/getMetadata"
+	"imageProcessor/internal/http-server/handlers/image/getMetadata/mocks"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/models"
+	"imageProcessor/internal/storage"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGetMetadata(t *testing.T) {
+	log := slog.New(slog.NewJSONHandler(bytes.NewBuffer(nil), nil))
+
+	imageID := uuid.New()
+	createdAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	takenAt := time.Date(2021, 6, 15, 14, 30, 0, 0, time.FixedZone("", 2*60*60))
+
+	ownerKey := &models.APIKey{ID: uuid.New(), Scopes: []string{apikey.ScopeRead}}
+	otherKey := &models.APIKey{ID: uuid.New(), Scopes: []string{apikey.ScopeRead}}
+
+	image := &models.Image{ID: imageID, TenantID: "shop", Status: "processed", OwnerKeyID: &ownerKey.ID, CreatedAt: createdAt, UpdatedAt: createdAt}
+	metadata := &models.ImageMetadata{
+		ImageID:     imageID,
+		Camera:      &models.Camera{Make: "Canon", Model: "Canon EOS 5D Mark IV"},
+		Exposure:    &models.Exposure{Time: "1/250", FNumber: 2.8, ISO: 400},
+		TakenAt:     &takenAt,
+		Orientation: 6,
+		GPS:         &models.GPS{Latitude: 55.756, Longitude: 37.617},
+		EXIF:        map[string]any{"Make": "Canon"},
+		IPTC:        map[string][]string{"Keywords": {"cat", "sofa"}},
+		ExtractedAt: createdAt,
+	}
+
+	tests := []struct {
+		name            string
+		imageID         string
+		key             *models.APIKey
+		mockImage       *models.Image
+		mockImageErr    error
+		mockMetadata    *models.ImageMetadata
+		mockMetadataErr error
+		expectedStatus  int
+		expectedBody    string
+	}{
+		{
+			name:           "Success",
+			imageID:        imageID.String(),
+			key:            ownerKey,
+			mockImage:      image,
+			mockMetadata:   metadata,
+			expectedStatus: http.StatusOK,
+			expectedBody: fmt.Sprintf(`{"status":"OK","metadata":{"image_id":"%s","camera":{"make":"Canon","model":"Canon EOS 5D Mark IV"},`+
+				`"exposure":{"time":"1/250","f_number":2.8,"iso":400},"taken_at":"2021-06-15T14:30:00+02:00","orientation":6,`+
+				`"gps":{"latitude":55.756,"longitude":37.617},"exif":{"Make":"Canon"},"iptc":{"Keywords":["cat","sofa"]},`+
+				`"extracted_at":"2030-01-01T00:00:00Z"}}`, imageID),
+		},
+		{
+			name:           "Invalid UUID",
+			imageID:        "invalid-uuid",
+			key:            ownerKey,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"Error","error":"invalid image ID"}`,
+		},
+		{
+			name:           "Not Found",
+			imageID:        imageID.String(),
+			key:            ownerKey,
+			mockImageErr:   fmt.Errorf("storage: %w", sql.ErrNoRows),
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"status":"Error","error":"image not found"}`,
+		},
+		{
+			name:           "Other Owner",
+			imageID:        imageID.String(),
+			key:            otherKey,
+			mockImage:      image,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"status":"Error","error":"image not found"}`,
+		},
+		{
+			name:            "Not Processed",
+			imageID:         imageID.String(),
+			key:             ownerKey,
+			mockImage:       image,
+			mockMetadataErr: fmt.Errorf("storage: %w", storage.ErrNoMetadata),
+			expectedStatus:  http.StatusConflict,
+			expectedBody:    `{"status":"Error","error":"image is not processed yet"}`,
+		},
+		{
+			name:           "Get Image Error",
+			imageID:        imageID.String(),
+			key:            ownerKey,
+			mockImageErr:   errors.New("db error"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"status":"Error","error":"failed to get image"}`,
+		},
+		{
+			name:            "Internal Error",
+			imageID:         imageID.String(),
+			key:             ownerKey,
+			mockImage:       image,
+			mockMetadataErr: errors.New("db error"),
+			expectedStatus:  http.StatusInternalServerError,
+			expectedBody:    `{"status":"Error","error":"failed to get image metadata"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metadataGetterMock := mocks.NewMetadataGetter(t)
+
+			if tt.mockImage != nil || tt.mockImageErr != nil {
+				metadataGetterMock.On("GetImage", mock.Anything, imageID).Return(tt.mockImage, tt.mockImageErr).Once()
+			}
+			if tt.mockMetadata != nil || tt.mockMetadataErr != nil {
+				metadataGetterMock.On("GetImageMetadata", mock.Anything, imageID).Return(tt.mockMetadata, tt.mockMetadataErr).Once()
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/image/"+tt.imageID+"/metadata", nil)
+
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", tt.imageID)
+			req = req.WithContext(apikey.WithKey(context.WithValue(req.Context(), chi.RouteCtxKey, rctx), tt.key))
+
+			rr := httptest.NewRecorder()
+
+			handler := getMetadata.New(log, metadataGetterMock)
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+
+			var actualMap, expectedMap map[string]interface{}
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &actualMap))
+			require.NoError(t, json.Unmarshal([]byte(tt.expectedBody), &expectedMap))
+			require.Equal(t, expectedMap, actualMap)
+		})
+	}
+}
@@ -0,0 +1,92 @@
+// Code generated by mockery v2.51.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+
+	models "imageProcessor/internal/models"
+
+	uuid "github.com/google/uuid"
+)
+
+// MetadataGetter is an autogenerated mock type for the MetadataGetter type
+type MetadataGetter struct {
+	mock.Mock
+}
+
+// GetImage provides a mock function with given fields: ctx, id
+func (_m *MetadataGetter) GetImage(ctx context.Context, id uuid.UUID) (*models.Image, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetImage")
+	}
+
+	var r0 *models.Image
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) (*models.Image, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) *models.Image); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.Image)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetImageMetadata provides a mock function with given fields: ctx, imageID
+func (_m *MetadataGetter) GetImageMetadata(ctx context.Context, imageID uuid.UUID) (*models.ImageMetadata, error) {
+	ret := _m.Called(ctx, imageID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetImageMetadata")
+	}
+
+	var r0 *models.ImageMetadata
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) (*models.ImageMetadata, error)); ok {
+		return rf(ctx, imageID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) *models.ImageMetadata); ok {
+		r0 = rf(ctx, imageID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.ImageMetadata)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID) error); ok {
+		r1 = rf(ctx, imageID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewMetadataGetter creates a new instance of MetadataGetter. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMetadataGetter(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MetadataGetter {
+	mock := &MetadataGetter{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
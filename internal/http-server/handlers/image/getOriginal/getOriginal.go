@@ -0,0 +1,163 @@
+package getOriginal
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"imageProcessor/internal/lib/api/response"
+	"imageProcessor/internal/lib/logger/sl"
+	"imageProcessor/internal/models"
+	"imageProcessor/internal/storage"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+// Originals are stored under a fresh name per upload and never rewritten.
+const cacheControl = "public, max-age=31536000, immutable"
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=ImageGetter
+type ImageGetter interface {
+	GetImage(ctx context.Context, id uuid.UUID) (*models.Image, error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=VariantGetter
+type VariantGetter interface {
+	GetVariants(ctx context.Context, imageID uuid.UUID, name string) ([]models.Variant, error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=BlobOpener
+type BlobOpener interface {
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, *storage.BlobInfo, error)
+}
+
+// GetOriginal downloads the uploaded original of an image.
+// @Summary      Download the original
+// @Description  Streams the original file of an image. Supports ETag/If-None-Match, If-Modified-Since and byte ranges. With privacy.sanitize_original set, the copy re-encoded without metadata is served instead, once the image is processed.
+// @Tags         images
+// @Produce      image/jpeg,image/png,image/gif,image/tiff,image/bmp
+// @Param        id     path      string  true  "Image ID"
+// @Param        Range  header    string  false "Byte range"
+// @Success      200  {file}    file
+// @Success      206  {file}    file
+// @Success      304  "Not Modified"
+// @Failure      400  {object}  response.Response
+// @Failure      403  {object}  response.Response
+// @Failure      404  {object}  response.Response
+// @Failure      416  "Range Not Satisfiable"
+// @Failure      500  {object}  response.Response
+// @Router       /image/{id}/original [get]
+func New(log *slog.Logger, imageGetter ImageGetter, blobOpener BlobOpener) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.image.getOriginal.New"
+
+		log := log.With(slog.String("op", op))
+
+		idStr := chi.URLParam(r, "id")
+		imageID, err := uuid.Parse(idStr)
+		if err != nil {
+			log.Error("failed to parse image ID", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error("invalid image ID"))
+			return
+		}
+
+		image, err := imageGetter.GetImage(r.Context(), imageID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				log.Warn("image not found", slog.String("image_id", imageID.String()))
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, response.Error("image not found"))
+				return
+			}
+
+			log.Error("failed to get image from storage", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to get image"))
+			return
+		}
+
+		content, info, err := blobOpener.Open(r.Context(), image.OriginalPath)
+		if err != nil {
+			if errors.Is(err, storage.ErrBlobNotFound) {
+				log.Warn("original blob is missing", slog.String("image_id", imageID.String()), slog.String("key", image.OriginalPath))
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, response.Error("image not found"))
+				return
+			}
+
+			log.Error("failed to open original blob", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to get image"))
+			return
+		}
+		defer content.Close()
+
+		w.Header().Set("ETag", `"`+image.ID.String()+`"`)
+		w.Header().Set("Cache-Control", cacheControl)
+
+		// Without a Content-Type set, ServeContent sniffs it from the file.
+		http.ServeContent(w, r, "", info.ModTime, content)
+	}
+}
+
+// NewSanitized serves, in place of the original, the copy of it the
+// processor encoded anew without metadata, so that what the upload carries
+// (the GPS position above all) is never published. Images are without one
+// until they are processed.
+func NewSanitized(log *slog.Logger, variantGetter VariantGetter, blobOpener BlobOpener) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.image.getOriginal.NewSanitized"
+
+		log := log.With(slog.String("op", op))
+
+		imageID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			log.Error("failed to parse image ID", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error("invalid image ID"))
+			return
+		}
+
+		variants, err := variantGetter.GetVariants(r.Context(), imageID, models.OriginalVariant)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				log.Warn("sanitized original not found", slog.String("image_id", imageID.String()))
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, response.Error("image not found"))
+				return
+			}
+
+			log.Error("failed to get sanitized original from storage", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to get image"))
+			return
+		}
+		variant := variants[0]
+
+		content, info, err := blobOpener.Open(r.Context(), variant.BlobKey)
+		if err != nil {
+			if errors.Is(err, storage.ErrBlobNotFound) {
+				log.Warn("sanitized original blob is missing", slog.String("image_id", imageID.String()), slog.String("key", variant.BlobKey))
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, response.Error("image not found"))
+				return
+			}
+
+			log.Error("failed to open sanitized original blob", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to get image"))
+			return
+		}
+		defer content.Close()
+
+		w.Header().Set("Content-Type", variant.ContentType)
+		w.Header().Set("ETag", `"`+variant.Checksum+`"`)
+		w.Header().Set("Cache-Control", cacheControl)
+
+		http.ServeContent(w, r, "", info.ModTime, content)
+	}
+}
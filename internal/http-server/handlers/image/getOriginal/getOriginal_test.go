@@ -0,0 +1,231 @@
+package getOriginal_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"imageProcessor/internal/http-server/handlers/image/getOriginal"
+	"imageProcessor/internal/http-server/handlers/image/getOriginal/mocks"
+	"imageProcessor/internal/models"
+	"imageProcessor/internal/storage"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type nopSeekCloser struct {
+	io.ReadSeeker
+}
+
+func (nopSeekCloser) Close() error { return nil }
+
+func TestGetOriginal(t *testing.T) {
+	log := slog.New(slog.NewJSONHandler(bytes.NewBuffer(nil), nil))
+
+	testUUID, _ := uuid.NewRandom()
+	testImage := &models.Image{ID: testUUID, TenantID: "shop", OriginalPath: "shop/uploads/test.png"}
+	// A PNG signature is enough for the content type to be sniffed.
+	content := []byte("\x89PNG\r\n\x1a\n0123456789")
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		imageID        string
+		headers        map[string]string
+		mockImageErr   error
+		mockBlobErr    error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Success",
+			imageID:        testUUID.String(),
+			expectedStatus: http.StatusOK,
+			expectedBody:   string(content),
+		},
+		{
+			name:           "Not Modified",
+			imageID:        testUUID.String(),
+			headers:        map[string]string{"If-None-Match": `"` + testUUID.String() + `"`},
+			expectedStatus: http.StatusNotModified,
+		},
+		{
+			name:           "Range",
+			imageID:        testUUID.String(),
+			headers:        map[string]string{"Range": "bytes=0-3"},
+			expectedStatus: http.StatusPartialContent,
+			expectedBody:   string(content[:4]),
+		},
+		{
+			name:           "Invalid UUID",
+			imageID:        "invalid-uuid",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"Error","error":"invalid image ID"}` + "\n",
+		},
+		{
+			name:           "Not Found",
+			imageID:        testUUID.String(),
+			mockImageErr:   sql.ErrNoRows,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"status":"Error","error":"image not found"}` + "\n",
+		},
+		{
+			name:           "Blob Missing",
+			imageID:        testUUID.String(),
+			mockBlobErr:    storage.ErrBlobNotFound,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"status":"Error","error":"image not found"}` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			imageGetterMock := mocks.NewImageGetter(t)
+			blobOpenerMock := mocks.NewBlobOpener(t)
+
+			if tt.name != "Invalid UUID" {
+				if tt.mockImageErr != nil {
+					imageGetterMock.On("GetImage", mock.Anything, testUUID).Return(nil, tt.mockImageErr).Once()
+				} else {
+					imageGetterMock.On("GetImage", mock.Anything, testUUID).Return(testImage, nil).Once()
+				}
+			}
+			if tt.mockBlobErr != nil {
+				blobOpenerMock.On("Open", mock.Anything, testImage.OriginalPath).Return(nil, nil, tt.mockBlobErr).Once()
+			} else if tt.name != "Invalid UUID" && tt.mockImageErr == nil {
+				blobOpenerMock.On("Open", mock.Anything, testImage.OriginalPath).
+					Return(nopSeekCloser{bytes.NewReader(content)}, &storage.BlobInfo{Key: testImage.OriginalPath, ModTime: modTime}, nil).Once()
+			}
+
+			req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/image/%s/original", tt.imageID), nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", tt.imageID)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			rr := httptest.NewRecorder()
+
+			handler := getOriginal.New(log, imageGetterMock, blobOpenerMock)
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+			require.Equal(t, tt.expectedBody, rr.Body.String())
+
+			if tt.name == "Success" {
+				require.Equal(t, "image/png", rr.Header().Get("Content-Type"))
+				require.Equal(t, `"`+testUUID.String()+`"`, rr.Header().Get("ETag"))
+			}
+		})
+	}
+}
+
+func TestGetSanitizedOriginal(t *testing.T) {
+	log := slog.New(slog.NewJSONHandler(bytes.NewBuffer(nil), nil))
+
+	testUUID := uuid.New()
+	variant := models.Variant{
+		ImageID:     testUUID,
+		Name:        models.OriginalVariant,
+		Format:      "jpeg",
+		BlobKey:     "shop/processed/" + testUUID.String() + "_public.jpg",
+		ContentType: "image/jpeg",
+		Checksum:    "abc123",
+	}
+	content := []byte("\xff\xd8\xff0123456789")
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		imageID        string
+		mockVariantErr error
+		mockBlobErr    error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Success",
+			imageID:        testUUID.String(),
+			expectedStatus: http.StatusOK,
+			expectedBody:   string(content),
+		},
+		{
+			name:           "Invalid UUID",
+			imageID:        "invalid-uuid",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"Error","error":"invalid image ID"}` + "\n",
+		},
+		{
+			name:           "Not Processed",
+			imageID:        testUUID.String(),
+			mockVariantErr: fmt.Errorf("storage: %w", sql.ErrNoRows),
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"status":"Error","error":"image not found"}` + "\n",
+		},
+		{
+			name:           "Storage Error",
+			imageID:        testUUID.String(),
+			mockVariantErr: errors.New("db error"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"status":"Error","error":"failed to get image"}` + "\n",
+		},
+		{
+			name:           "Blob Missing",
+			imageID:        testUUID.String(),
+			mockBlobErr:    storage.ErrBlobNotFound,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"status":"Error","error":"image not found"}` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			variantGetterMock := mocks.NewVariantGetter(t)
+			blobOpenerMock := mocks.NewBlobOpener(t)
+
+			if tt.name != "Invalid UUID" {
+				if tt.mockVariantErr != nil {
+					variantGetterMock.On("GetVariants", mock.Anything, testUUID, models.OriginalVariant).Return(nil, tt.mockVariantErr).Once()
+				} else {
+					variantGetterMock.On("GetVariants", mock.Anything, testUUID, models.OriginalVariant).Return([]models.Variant{variant}, nil).Once()
+				}
+			}
+			if tt.mockBlobErr != nil {
+				blobOpenerMock.On("Open", mock.Anything, variant.BlobKey).Return(nil, nil, tt.mockBlobErr).Once()
+			} else if tt.name != "Invalid UUID" && tt.mockVariantErr == nil {
+				blobOpenerMock.On("Open", mock.Anything, variant.BlobKey).
+					Return(nopSeekCloser{bytes.NewReader(content)}, &storage.BlobInfo{Key: variant.BlobKey, ModTime: modTime}, nil).Once()
+			}
+
+			req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/image/%s/original", tt.imageID), nil)
+
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", tt.imageID)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			rr := httptest.NewRecorder()
+
+			handler := getOriginal.NewSanitized(log, variantGetterMock, blobOpenerMock)
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+			require.Equal(t, tt.expectedBody, rr.Body.String())
+
+			if tt.name == "Success" {
+				require.Equal(t, "image/jpeg", rr.Header().Get("Content-Type"))
+				require.Equal(t, `"abc123"`, rr.Header().Get("ETag"))
+			}
+		})
+	}
+}
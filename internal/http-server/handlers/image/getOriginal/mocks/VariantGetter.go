@@ -0,0 +1,62 @@
+// Code generated by mockery v2.51.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+
+	models "imageProcessor/internal/models"
+
+	uuid "github.com/google/uuid"
+)
+
+// VariantGetter is an autogenerated mock type for the VariantGetter type
+type VariantGetter struct {
+	mock.Mock
+}
+
+// GetVariants provides a mock function with given fields: ctx, imageID, name
+func (_m *VariantGetter) GetVariants(ctx context.Context, imageID uuid.UUID, name string) ([]models.Variant, error) {
+	ret := _m.Called(ctx, imageID, name)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetVariants")
+	}
+
+	var r0 []models.Variant
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, string) ([]models.Variant, error)); ok {
+		return rf(ctx, imageID, name)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, string) []models.Variant); ok {
+		r0 = rf(ctx, imageID, name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Variant)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID, string) error); ok {
+		r1 = rf(ctx, imageID, name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewVariantGetter creates a new instance of VariantGetter. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewVariantGetter(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *VariantGetter {
+	mock := &VariantGetter{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,189 @@
+package getPicture
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"html/template"
+	"imageProcessor/internal/config"
+	"imageProcessor/internal/lib/api/response"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/lib/imageformat"
+	"imageProcessor/internal/lib/logger/sl"
+	"imageProcessor/internal/lib/mediaurl"
+	"imageProcessor/internal/models"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+)
+
+// fallbackWidth is the width of the resize variant. The img element of a
+// picture falls back to the narrowest width variant at least as wide, for
+// browsers that don't read srcset.
+const fallbackWidth = 800
+
+var picture = template.Must(template.New("picture").Parse(`<picture>
+{{- range .Sources}}
+  <source type="{{.Type}}" srcset="{{.Srcset}}" sizes="{{$.Sizes}}">
+{{- end}}
+  <img src="{{.Src}}" srcset="{{.Srcset}}" sizes="{{.Sizes}}" alt="{{.Alt}}">
+</picture>
+`))
+
+type source struct {
+	Type   string
+	Srcset string
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=ImageGetter
+type ImageGetter interface {
+	GetImage(ctx context.Context, id uuid.UUID) (*models.Image, error)
+	ListVariants(ctx context.Context, imageIDs []uuid.UUID) ([]models.Variant, error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=URLSigner
+type URLSigner interface {
+	Sign(path string, params url.Values) string
+	ExpiresAt() time.Time
+}
+
+// GetPicture renders a <picture> element showing an image.
+// @Summary      Get a <picture> element
+// @Description  Renders an HTML <picture> element for the width variants of an image: a <source> with a srcset for every configured format after the first, which browsers supporting it prefer, and an img element with the first format as the fallback. Its links are signed and expire like the other media links. The image has to be processed first.
+// @Tags         images
+// @Produce      html
+// @Security     ApiKeyAuth
+// @Param        id     path      string  true   "Image ID"
+// @Param        sizes  query     string  false  "sizes attribute, the configured one by default"
+// @Param        alt    query     string  false  "alt text of the img element"
+// @Success      200  {string}  string
+// @Failure      400  {object}  response.Response
+// @Failure      401  {object}  response.Response
+// @Failure      403  {object}  response.Response
+// @Failure      404  {object}  response.Response
+// @Failure      409  {object}  response.Response
+// @Failure      500  {object}  response.Response
+// @Router       /image/{id}/picture [get]
+func New(log *slog.Logger, imageGetter ImageGetter, urlSigner URLSigner, formats []imageformat.Format, cfg *config.Responsive) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.image.getPicture.New"
+
+		log := log.With(slog.String("op", op))
+
+		imageID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			log.Error("failed to parse image ID", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error("invalid image ID"))
+			return
+		}
+
+		sizes := cfg.Sizes
+		if s := r.URL.Query().Get("sizes"); s != "" {
+			sizes = s
+		}
+
+		image, err := imageGetter.GetImage(r.Context(), imageID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				log.Warn("image not found", slog.String("image_id", imageID.String()))
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, response.Error("image not found"))
+				return
+			}
+
+			log.Error("failed to get image from storage", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to get image"))
+			return
+		}
+
+		if !apikey.CanAccess(r.Context(), image.OwnerKeyID) {
+			log.Warn("image belongs to another api key", slog.String("image_id", imageID.String()))
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, response.Error("image not found"))
+			return
+		}
+
+		if image.Status != "processed" {
+			log.Warn("image is not processed yet", slog.String("image_id", imageID.String()), slog.String("status", image.Status))
+			render.Status(r, http.StatusConflict)
+			render.JSON(w, r, response.Error("image is not processed yet"))
+			return
+		}
+
+		variants, err := imageGetter.ListVariants(r.Context(), []uuid.UUID{image.ID})
+		if err != nil {
+			log.Error("failed to list variants", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to get image"))
+			return
+		}
+
+		byFormat := make(map[string][]models.Variant)
+		for _, v := range variants {
+			byFormat[v.Format] = append(byFormat[v.Format], v)
+		}
+
+		// The formats offered are the configured ones the image was
+		// rendered in. The first of them is the fallback, the others are
+		// sources in the order of preference.
+		var data struct {
+			Sources []source
+			Src     string
+			Srcset  string
+			Sizes   string
+			Alt     string
+		}
+		data.Sizes = sizes
+		data.Alt = r.URL.Query().Get("alt")
+
+		for _, format := range formats {
+			widths := models.Widths(byFormat[format.Name])
+			if len(widths) == 0 {
+				continue
+			}
+
+			if data.Src == "" {
+				width := widths[len(widths)-1]
+				if i := slices.IndexFunc(widths, func(w int) bool { return w >= fallbackWidth }); i >= 0 {
+					width = widths[i]
+				}
+				data.Src = cfg.PublicURL + mediaurl.Variant(image, models.WidthVariant(width), format.Name, urlSigner)
+				data.Srcset = mediaurl.Srcset(image, widths, format.Name, urlSigner, cfg.PublicURL)
+				continue
+			}
+
+			data.Sources = append(data.Sources, source{
+				Type:   format.ContentType,
+				Srcset: mediaurl.Srcset(image, widths, format.Name, urlSigner, cfg.PublicURL),
+			})
+		}
+
+		if data.Src == "" {
+			log.Warn("image has no srcset variants", slog.String("image_id", imageID.String()))
+			render.Status(r, http.StatusConflict)
+			render.JSON(w, r, response.Error("image has no srcset variants"))
+			return
+		}
+
+		var buf bytes.Buffer
+		if err = picture.Execute(&buf, data); err != nil {
+			log.Error("failed to render picture", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to render picture"))
+			return
+		}
+
+		log.Info("picture rendered", slog.String("image_id", imageID.String()), slog.Int("sources", len(data.Sources)))
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write(buf.Bytes())
+	}
+}
@@ -0,0 +1,190 @@
+package getPicture_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"imageProcessor/internal/config"
+	"imageProcessor/internal/http-server/handlers/image/getPicture"
+	"imageProcessor/internal/http-server/handlers/image/getPicture/mocks"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/lib/imageformat"
+	"imageProcessor/internal/models"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestGetPicture(t *testing.T) {
+	log := slog.New(slog.NewJSONHandler(bytes.NewBuffer(nil), nil))
+
+	imageID := uuid.New()
+	ownerKey := &models.APIKey{ID: uuid.New(), Scopes: []string{apikey.ScopeRead}}
+	otherKey := &models.APIKey{ID: uuid.New(), Scopes: []string{apikey.ScopeRead}}
+
+	processed := &models.Image{ID: imageID, TenantID: "shop", Status: "processed", OwnerKeyID: &ownerKey.ID}
+	pending := &models.Image{ID: imageID, TenantID: "shop", Status: "pending", OwnerKeyID: &ownerKey.ID}
+
+	variants := func(formats []string, widths ...int) []models.Variant {
+		list := []models.Variant{{ImageID: imageID, Name: "resize", Format: "jpeg"}}
+		for _, format := range formats {
+			for _, width := range widths {
+				list = append(list, models.Variant{ImageID: imageID, Name: models.WidthVariant(width), Format: format})
+			}
+		}
+		return list
+	}
+
+	link := func(width int, format string) string {
+		return fmt.Sprintf("https://img.example.com/image/%s/variants/w%d?format=%s&amp;tenant=shop", imageID, width, format)
+	}
+	srcset := func(format string, widths ...int) string {
+		var candidates []string
+		for _, width := range widths {
+			candidates = append(candidates, fmt.Sprintf("%s %dw", link(width, format), width))
+		}
+		return strings.Join(candidates, ", ")
+	}
+
+	tests := []struct {
+		name           string
+		imageID        string
+		query          string
+		key            *models.APIKey
+		mockImage      *models.Image
+		mockImageErr   error
+		mockVariants   []models.Variant
+		mockErr        error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Format Alternatives",
+			imageID:        imageID.String(),
+			key:            ownerKey,
+			mockImage:      processed,
+			mockVariants:   variants([]string{"jpeg", "png"}, 320, 640, 960, 1000),
+			expectedStatus: http.StatusOK,
+			expectedBody: "<picture>\n" +
+				`  <source type="image/png" srcset="` + srcset("png", 320, 640, 960, 1000) + `" sizes="100vw">` + "\n" +
+				`  <img src="` + link(960, "jpeg") + `" srcset="` + srcset("jpeg", 320, 640, 960, 1000) + `" sizes="100vw" alt="">` + "\n" +
+				"</picture>\n",
+		},
+		{
+			name:           "Small Original",
+			imageID:        imageID.String(),
+			query:          "?sizes=" + url.QueryEscape("(max-width: 600px) 100vw, 50vw") + "&alt=" + url.QueryEscape(`Tom & "Jerry"`),
+			key:            ownerKey,
+			mockImage:      processed,
+			mockVariants:   variants([]string{"jpeg"}, 320, 500),
+			expectedStatus: http.StatusOK,
+			expectedBody: "<picture>\n" +
+				`  <img src="` + link(500, "jpeg") + `" srcset="` + srcset("jpeg", 320, 500) + `" sizes="(max-width: 600px) 100vw, 50vw" alt="Tom &amp; &#34;Jerry&#34;">` + "\n" +
+				"</picture>\n",
+		},
+		{
+			name:           "Default Format Missing",
+			imageID:        imageID.String(),
+			key:            ownerKey,
+			mockImage:      processed,
+			mockVariants:   variants([]string{"png", "gif"}, 320),
+			expectedStatus: http.StatusOK,
+			expectedBody: "<picture>\n" +
+				`  <img src="` + link(320, "png") + `" srcset="` + srcset("png", 320) + `" sizes="100vw" alt="">` + "\n" +
+				"</picture>\n",
+		},
+		{
+			name:           "No Srcset Variants",
+			imageID:        imageID.String(),
+			key:            ownerKey,
+			mockImage:      processed,
+			mockVariants:   variants([]string{"jpeg"}),
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"status":"Error","error":"image has no srcset variants"}` + "\n",
+		},
+		{
+			name:           "Not Processed",
+			imageID:        imageID.String(),
+			key:            ownerKey,
+			mockImage:      pending,
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"status":"Error","error":"image is not processed yet"}` + "\n",
+		},
+		{
+			name:           "Invalid UUID",
+			imageID:        "invalid-uuid",
+			key:            ownerKey,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"Error","error":"invalid image ID"}` + "\n",
+		},
+		{
+			name:           "Not Found",
+			imageID:        imageID.String(),
+			key:            ownerKey,
+			mockImageErr:   fmt.Errorf("storage: %w", sql.ErrNoRows),
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"status":"Error","error":"image not found"}` + "\n",
+		},
+		{
+			name:           "Other Owner",
+			imageID:        imageID.String(),
+			key:            otherKey,
+			mockImage:      processed,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"status":"Error","error":"image not found"}` + "\n",
+		},
+		{
+			name:           "Internal Error",
+			imageID:        imageID.String(),
+			key:            ownerKey,
+			mockImage:      processed,
+			mockErr:        errors.New("db error"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"status":"Error","error":"failed to get image"}` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			imageGetterMock := mocks.NewImageGetter(t)
+			urlSignerMock := mocks.NewURLSigner(t)
+
+			if tt.mockImage != nil || tt.mockImageErr != nil {
+				imageGetterMock.On("GetImage", mock.Anything, imageID).Return(tt.mockImage, tt.mockImageErr).Once()
+			}
+			if tt.mockVariants != nil || tt.mockErr != nil {
+				imageGetterMock.On("ListVariants", mock.Anything, []uuid.UUID{imageID}).Return(tt.mockVariants, tt.mockErr).Once()
+			}
+			urlSignerMock.On("Sign", mock.Anything, mock.Anything).Return(func(path string, params url.Values) string {
+				return path + "?" + params.Encode()
+			}).Maybe()
+
+			req := httptest.NewRequest(http.MethodGet, "/image/"+tt.imageID+"/picture"+tt.query, nil)
+
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", tt.imageID)
+			req = req.WithContext(apikey.WithKey(context.WithValue(req.Context(), chi.RouteCtxKey, rctx), tt.key))
+
+			rr := httptest.NewRecorder()
+
+			formats := []imageformat.Format{imageformat.JPEG, imageformat.PNG}
+			handler := getPicture.New(log, imageGetterMock, urlSignerMock, formats, &config.Responsive{Sizes: "100vw", PublicURL: "https://img.example.com"})
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+			require.Equal(t, tt.expectedBody, rr.Body.String())
+			if tt.expectedStatus == http.StatusOK {
+				require.Equal(t, "text/html; charset=utf-8", rr.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
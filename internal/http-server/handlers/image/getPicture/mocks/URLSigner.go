@@ -0,0 +1,66 @@
+// Code generated by mockery v2.51.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	time "time"
+
+	mock "github.com/stretchr/testify/mock"
+
+	url "net/url"
+)
+
+// URLSigner is an autogenerated mock type for the URLSigner type
+type URLSigner struct {
+	mock.Mock
+}
+
+// ExpiresAt provides a mock function with no fields
+func (_m *URLSigner) ExpiresAt() time.Time {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for ExpiresAt")
+	}
+
+	var r0 time.Time
+	if rf, ok := ret.Get(0).(func() time.Time); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(time.Time)
+	}
+
+	return r0
+}
+
+// Sign provides a mock function with given fields: path, params
+func (_m *URLSigner) Sign(path string, params url.Values) string {
+	ret := _m.Called(path, params)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Sign")
+	}
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(string, url.Values) string); ok {
+		r0 = rf(path, params)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	return r0
+}
+
+// NewURLSigner creates a new instance of URLSigner. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewURLSigner(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *URLSigner {
+	mock := &URLSigner{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,171 @@
+package getVariant
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"imageProcessor/internal/lib/api/response"
+	"imageProcessor/internal/lib/imageformat"
+	"imageProcessor/internal/lib/logger/sl"
+	"imageProcessor/internal/models"
+	"imageProcessor/internal/storage"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+// Variants never change once they are written, so clients and proxies may
+// keep them for as long as they like.
+const cacheControl = "public, max-age=31536000, immutable"
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=VariantGetter
+type VariantGetter interface {
+	GetVariants(ctx context.Context, imageID uuid.UUID, name string) ([]models.Variant, error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=BlobOpener
+type BlobOpener interface {
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, *storage.BlobInfo, error)
+}
+
+// GetVariant downloads a processed variant of an image.
+// @Summary      Download an image variant
+// @Description  Streams a processed variant (resize, thumbnail, watermark or a srcset width such as w640) in the format given, or else in the one that best matches the Accept header. Supports ETag/If-None-Match, If-Modified-Since and byte ranges.
+// @Tags         images
+// @Produce      image/jpeg,image/png,image/gif,image/tiff,image/bmp
+// @Param        id     path      string  true  "Image ID"
+// @Param        name   path      string  true  "Variant name"
+// @Param        format query     string  false "Format to serve, in place of negotiating it"
+// @Param        Accept header    string  false "Preferred image formats"
+// @Param        Range  header    string  false "Byte range"
+// @Success      200  {file}    file
+// @Success      206  {file}    file
+// @Success      304  "Not Modified"
+// @Failure      400  {object}  response.Response
+// @Failure      404  {object}  response.Response
+// @Failure      406  {object}  response.Response
+// @Failure      416  "Range Not Satisfiable"
+// @Failure      500  {object}  response.Response
+// @Router       /image/{id}/variants/{name} [get]
+func New(log *slog.Logger, variantGetter VariantGetter, blobOpener BlobOpener) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.image.getVariant.New"
+
+		log := log.With(slog.String("op", op))
+
+		// The representation depends on Accept, so caches must key on it
+		// whatever the outcome.
+		w.Header().Add("Vary", "Accept")
+
+		idStr := chi.URLParam(r, "id")
+		imageID, err := uuid.Parse(idStr)
+		if err != nil {
+			log.Error("failed to parse image ID", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error("invalid image ID"))
+			return
+		}
+
+		name := chi.URLParam(r, "name")
+
+		// Links of <picture> sources name their format, which takes the
+		// place of Accept.
+		var format *imageformat.Format
+		if s := r.URL.Query().Get("format"); s != "" {
+			f, err := imageformat.Parse(s)
+			if err != nil {
+				log.Warn("invalid variant format", slog.String("format", s))
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, response.Error("invalid format"))
+				return
+			}
+			format = &f
+		}
+
+		variants, err := variantGetter.GetVariants(r.Context(), imageID, name)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				log.Warn("variant not found", slog.String("image_id", imageID.String()), slog.String("variant", name))
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, response.Error("variant not found"))
+				return
+			}
+
+			log.Error("failed to get variant from storage", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to get variant"))
+			return
+		}
+
+		var variant *models.Variant
+		var ok bool
+		if format != nil {
+			variant, ok = pick(*format, variants)
+		} else {
+			variant, ok = negotiate(r.Header.Get("Accept"), variants)
+		}
+		if !ok {
+			log.Warn("no acceptable variant format", slog.String("image_id", imageID.String()), slog.String("accept", r.Header.Get("Accept")))
+			render.Status(r, http.StatusNotAcceptable)
+			render.JSON(w, r, response.Error("no acceptable variant format"))
+			return
+		}
+
+		content, info, err := blobOpener.Open(r.Context(), variant.BlobKey)
+		if err != nil {
+			if errors.Is(err, storage.ErrBlobNotFound) {
+				log.Warn("variant blob is missing", slog.String("image_id", imageID.String()), slog.String("key", variant.BlobKey))
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, response.Error("variant not found"))
+				return
+			}
+
+			log.Error("failed to open variant blob", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to get variant"))
+			return
+		}
+		defer content.Close()
+
+		w.Header().Set("Content-Type", variant.ContentType)
+		w.Header().Set("ETag", `"`+variant.Checksum+`"`)
+		w.Header().Set("Cache-Control", cacheControl)
+
+		// ServeContent takes care of conditional requests and ranges based on
+		// the ETag and modification time set above.
+		http.ServeContent(w, r, "", info.ModTime, content)
+	}
+}
+
+func pick(format imageformat.Format, variants []models.Variant) (*models.Variant, bool) {
+	for i := range variants {
+		if variants[i].Format == format.Name {
+			return &variants[i], true
+		}
+	}
+
+	return nil, false
+}
+
+func negotiate(accept string, variants []models.Variant) (*models.Variant, bool) {
+	offered := make([]string, 0, len(variants))
+	for _, v := range variants {
+		offered = append(offered, v.ContentType)
+	}
+
+	contentType, ok := imageformat.Negotiate(accept, offered)
+	if !ok {
+		return nil, false
+	}
+
+	for i := range variants {
+		if variants[i].ContentType == contentType {
+			return &variants[i], true
+		}
+	}
+
+	return nil, false
+}
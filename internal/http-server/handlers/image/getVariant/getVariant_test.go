@@ -0,0 +1,245 @@
+package getVariant_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"imageProcessor/internal/http-server/handlers/image/getVariant"
+	"imageProcessor/internal/http-server/handlers/image/getVariant/mocks"
+	"imageProcessor/internal/models"
+	"imageProcessor/internal/storage"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type nopSeekCloser struct {
+	io.ReadSeeker
+}
+
+func (nopSeekCloser) Close() error { return nil }
+
+func TestGetVariant(t *testing.T) {
+	log := slog.New(slog.NewJSONHandler(bytes.NewBuffer(nil), nil))
+
+	testUUID, _ := uuid.NewRandom()
+	content := []byte("0123456789")
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	pngContent := []byte("png-content")
+
+	testVariants := []models.Variant{
+		{
+			ImageID:     testUUID,
+			Name:        "resize",
+			Format:      "jpeg",
+			BlobKey:     "processed/test_resized.jpg",
+			ContentType: "image/jpeg",
+			Size:        int64(len(content)),
+			Checksum:    "abc123",
+		},
+		{
+			ImageID:     testUUID,
+			Name:        "resize",
+			Format:      "png",
+			BlobKey:     "processed/test_resized.png",
+			ContentType: "image/png",
+			Size:        int64(len(pngContent)),
+			Checksum:    "def456",
+		},
+	}
+	blobs := map[string][]byte{
+		testVariants[0].BlobKey: content,
+		testVariants[1].BlobKey: pngContent,
+	}
+
+	tests := []struct {
+		name           string
+		imageID        string
+		query          string
+		headers        map[string]string
+		mockVariants   []models.Variant
+		mockVariantErr error
+		mockBlobErr    error
+		expectedStatus int
+		expectedBody   string
+		expectedType   string
+		expectedETag   string
+	}{
+		{
+			name:           "Success",
+			imageID:        testUUID.String(),
+			mockVariants:   testVariants,
+			expectedStatus: http.StatusOK,
+			expectedBody:   string(content),
+			expectedType:   "image/jpeg",
+			expectedETag:   `"abc123"`,
+		},
+		{
+			name:           "Accept PNG",
+			imageID:        testUUID.String(),
+			headers:        map[string]string{"Accept": "image/jpeg;q=0.5, image/png"},
+			mockVariants:   testVariants,
+			expectedStatus: http.StatusOK,
+			expectedBody:   string(pngContent),
+			expectedType:   "image/png",
+			expectedETag:   `"def456"`,
+		},
+		{
+			name:           "Accept Wildcard",
+			imageID:        testUUID.String(),
+			headers:        map[string]string{"Accept": "image/webp, image/*;q=0.8, */*;q=0.5"},
+			mockVariants:   testVariants,
+			expectedStatus: http.StatusOK,
+			expectedBody:   string(content),
+			expectedType:   "image/jpeg",
+			expectedETag:   `"abc123"`,
+		},
+		{
+			name:           "Not Acceptable",
+			imageID:        testUUID.String(),
+			headers:        map[string]string{"Accept": "image/webp, image/jpeg;q=0"},
+			mockVariants:   testVariants,
+			expectedStatus: http.StatusNotAcceptable,
+			expectedBody:   `{"status":"Error","error":"no acceptable variant format"}` + "\n",
+		},
+		{
+			name:           "Format Given",
+			imageID:        testUUID.String(),
+			query:          "?format=png",
+			headers:        map[string]string{"Accept": "image/jpeg"},
+			mockVariants:   testVariants,
+			expectedStatus: http.StatusOK,
+			expectedBody:   string(pngContent),
+			expectedType:   "image/png",
+			expectedETag:   `"def456"`,
+		},
+		{
+			name:           "Format Not Stored",
+			imageID:        testUUID.String(),
+			query:          "?format=gif",
+			mockVariants:   testVariants,
+			expectedStatus: http.StatusNotAcceptable,
+			expectedBody:   `{"status":"Error","error":"no acceptable variant format"}` + "\n",
+		},
+		{
+			name:           "Invalid Format",
+			imageID:        testUUID.String(),
+			query:          "?format=webp",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"Error","error":"invalid format"}` + "\n",
+		},
+		{
+			name:           "Not Modified By ETag",
+			imageID:        testUUID.String(),
+			headers:        map[string]string{"If-None-Match": `"abc123"`},
+			mockVariants:   testVariants,
+			expectedStatus: http.StatusNotModified,
+			expectedBody:   "",
+			expectedETag:   `"abc123"`,
+		},
+		{
+			name:           "Not Modified By Date",
+			imageID:        testUUID.String(),
+			headers:        map[string]string{"If-Modified-Since": modTime.Add(time.Hour).Format(http.TimeFormat)},
+			mockVariants:   testVariants,
+			expectedStatus: http.StatusNotModified,
+			expectedBody:   "",
+			expectedETag:   `"abc123"`,
+		},
+		{
+			name:           "Range",
+			imageID:        testUUID.String(),
+			headers:        map[string]string{"Range": "bytes=2-5"},
+			mockVariants:   testVariants,
+			expectedStatus: http.StatusPartialContent,
+			expectedBody:   "2345",
+			expectedType:   "image/jpeg",
+			expectedETag:   `"abc123"`,
+		},
+		{
+			name:           "Invalid UUID",
+			imageID:        "invalid-uuid",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"Error","error":"invalid image ID"}` + "\n",
+		},
+		{
+			name:           "Variant Not Found",
+			imageID:        testUUID.String(),
+			mockVariantErr: sql.ErrNoRows,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"status":"Error","error":"variant not found"}` + "\n",
+		},
+		{
+			name:           "Blob Missing",
+			imageID:        testUUID.String(),
+			mockVariants:   testVariants,
+			mockBlobErr:    storage.ErrBlobNotFound,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"status":"Error","error":"variant not found"}` + "\n",
+		},
+		{
+			name:           "Internal Error",
+			imageID:        testUUID.String(),
+			mockVariantErr: errors.New("db error"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"status":"Error","error":"failed to get variant"}` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			variantGetterMock := mocks.NewVariantGetter(t)
+			blobOpenerMock := mocks.NewBlobOpener(t)
+
+			if tt.name != "Invalid UUID" && tt.name != "Invalid Format" {
+				variantGetterMock.On("GetVariants", mock.Anything, testUUID, "resize").Return(tt.mockVariants, tt.mockVariantErr).Once()
+			}
+			if tt.mockBlobErr != nil {
+				blobOpenerMock.On("Open", mock.Anything, mock.Anything).Return(nil, nil, tt.mockBlobErr).Once()
+			} else if tt.expectedETag != "" {
+				blobOpenerMock.On("Open", mock.Anything, mock.Anything).Return(
+					func(_ context.Context, key string) (io.ReadSeekCloser, *storage.BlobInfo, error) {
+						return nopSeekCloser{bytes.NewReader(blobs[key])}, &storage.BlobInfo{Key: key, Size: int64(len(blobs[key])), ModTime: modTime}, nil
+					},
+				).Once()
+			}
+
+			req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/image/%s/variants/resize%s", tt.imageID, tt.query), nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", tt.imageID)
+			rctx.URLParams.Add("name", "resize")
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			rr := httptest.NewRecorder()
+
+			handler := getVariant.New(log, variantGetterMock, blobOpenerMock)
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+			require.Equal(t, tt.expectedBody, rr.Body.String())
+
+			require.Equal(t, "Accept", rr.Header().Get("Vary"))
+			if tt.expectedETag != "" {
+				require.Equal(t, tt.expectedETag, rr.Header().Get("ETag"))
+				require.Equal(t, "public, max-age=31536000, immutable", rr.Header().Get("Cache-Control"))
+			}
+			if tt.expectedType != "" {
+				require.Equal(t, tt.expectedType, rr.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+// Code generated by mockery v2.51.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	io "io"
+
+	mock "github.com/stretchr/testify/mock"
+
+	storage "imageProcessor/internal/storage"
+)
+
+// BlobOpener is an autogenerated mock type for the BlobOpener type
+type BlobOpener struct {
+	mock.Mock
+}
+
+// Open provides a mock function with given fields: ctx, key
+func (_m *BlobOpener) Open(ctx context.Context, key string) (io.ReadSeekCloser, *storage.BlobInfo, error) {
+	ret := _m.Called(ctx, key)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Open")
+	}
+
+	var r0 io.ReadSeekCloser
+	var r1 *storage.BlobInfo
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (io.ReadSeekCloser, *storage.BlobInfo, error)); ok {
+		return rf(ctx, key)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) io.ReadSeekCloser); ok {
+		r0 = rf(ctx, key)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(io.ReadSeekCloser)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) *storage.BlobInfo); ok {
+		r1 = rf(ctx, key)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(*storage.BlobInfo)
+		}
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, string) error); ok {
+		r2 = rf(ctx, key)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// NewBlobOpener creates a new instance of BlobOpener. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewBlobOpener(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *BlobOpener {
+	mock := &BlobOpener{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
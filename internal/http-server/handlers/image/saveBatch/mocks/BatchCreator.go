@@ -0,0 +1,61 @@
+// Code generated by mockery v2.51.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	models "imageProcessor/internal/models"
+
+	mock "github.com/stretchr/testify/mock"
+
+	uuid "github.com/google/uuid"
+)
+
+// BatchCreator is an autogenerated mock type for the BatchCreator type
+type BatchCreator struct {
+	mock.Mock
+}
+
+// CreateBatch provides a mock function with given fields: ctx, ownerKeyID
+func (_m *BatchCreator) CreateBatch(ctx context.Context, ownerKeyID *uuid.UUID) (*models.Batch, error) {
+	ret := _m.Called(ctx, ownerKeyID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateBatch")
+	}
+
+	var r0 *models.Batch
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *uuid.UUID) (*models.Batch, error)); ok {
+		return rf(ctx, ownerKeyID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *uuid.UUID) *models.Batch); ok {
+		r0 = rf(ctx, ownerKeyID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.Batch)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *uuid.UUID) error); ok {
+		r1 = rf(ctx, ownerKeyID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewBatchCreator creates a new instance of BatchCreator. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewBatchCreator(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *BatchCreator {
+	mock := &BatchCreator{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,79 @@
+// Code generated by mockery v2.51.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	io "io"
+
+	mock "github.com/stretchr/testify/mock"
+
+	storage "imageProcessor/internal/storage"
+)
+
+// BlobStorage is an autogenerated mock type for the BlobStorage type
+type BlobStorage struct {
+	mock.Mock
+}
+
+// Delete provides a mock function with given fields: ctx, key
+func (_m *BlobStorage) Delete(ctx context.Context, key string) error {
+	ret := _m.Called(ctx, key)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Delete")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, key)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Put provides a mock function with given fields: ctx, key, r
+func (_m *BlobStorage) Put(ctx context.Context, key string, r io.Reader) (*storage.BlobInfo, error) {
+	ret := _m.Called(ctx, key, r)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Put")
+	}
+
+	var r0 *storage.BlobInfo
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, io.Reader) (*storage.BlobInfo, error)); ok {
+		return rf(ctx, key, r)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, io.Reader) *storage.BlobInfo); ok {
+		r0 = rf(ctx, key, r)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*storage.BlobInfo)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, io.Reader) error); ok {
+		r1 = rf(ctx, key, r)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewBlobStorage creates a new instance of BlobStorage. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewBlobStorage(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *BlobStorage {
+	mock := &BlobStorage{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,523 @@
+package saveBatch
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"imageProcessor/internal/config"
+	"imageProcessor/internal/kafka/producer"
+	"imageProcessor/internal/lib/api/response"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/lib/imageformat"
+	"imageProcessor/internal/lib/logger/sl"
+	"imageProcessor/internal/lib/quota"
+	"imageProcessor/internal/lib/tenant"
+	"imageProcessor/internal/models"
+	"imageProcessor/internal/storage"
+	"imageProcessor/internal/webhook"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	uploadDir       = "uploads"
+	statusProcessed = "processed"
+	// maxMemory is how much of the form is kept in memory; the rest of the
+	// files is spooled to disk.
+	maxMemory = 32 << 20
+	// formOverhead allows for the multipart framing around the files.
+	formOverhead = 1 << 20
+)
+
+var (
+	errFileTooLarge  = errors.New("file too large")
+	errTotalTooLarge = errors.New("batch too large")
+)
+
+// FileResult is the outcome of one file of the batch.
+type FileResult struct {
+	response.Response
+	Filename string     `json:"filename"`
+	ImageID  *uuid.UUID `json:"image_id,omitempty"`
+	// Duplicate is set when the file was uploaded before and its image is
+	// returned in place of a new one, outside the batch.
+	Duplicate bool `json:"duplicate,omitempty"`
+}
+
+type Response struct {
+	response.Response
+	BatchID   uuid.UUID    `json:"batch_id"`
+	Succeeded int          `json:"succeeded"`
+	Failed    int          `json:"failed"`
+	Files     []FileResult `json:"files"`
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=ImageSaver
+type ImageSaver interface {
+	SaveImage(ctx context.Context, upload models.Upload, limits quota.Set) (*models.Image, error)
+	GetUsage(ctx context.Context, keyID *uuid.UUID) (models.Usage, *models.Usage, error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=BatchCreator
+type BatchCreator interface {
+	CreateBatch(ctx context.Context, ownerKeyID *uuid.UUID) (*models.Batch, error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=BlobStorage
+type BlobStorage interface {
+	Put(ctx context.Context, key string, r io.Reader) (*storage.BlobInfo, error)
+	Delete(ctx context.Context, key string) error
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=QuotaResolver
+type QuotaResolver interface {
+	For(tenantID string, keyID *uuid.UUID) quota.Set
+}
+
+// entry is a file to be saved, sent as is or found in a ZIP archive. Entries
+// that cannot be saved carry the reason in err.
+type entry struct {
+	name string
+	size int64
+	open func() (io.ReadCloser, error)
+	err  string
+}
+
+// SaveBatch uploads many images in one request.
+// @Summary      Upload a batch of images
+// @Description  Uploads the files sent as images and creates one image per file; ZIP archives are expanded and every file in them becomes an image. The type of every file is told from its leading bytes: files that are not JPEG, PNG, GIF, TIFF or BMP fail with "unsupported image type". Each file is saved on its own, so some may fail while the others are processed; the result of every file is listed in files. The images are grouped in a batch whose progress can be queried with GET /uploads/batch/{id}. Quotas are checked per file and reported in X-Quota-* headers. Files are deduplicated like single uploads; a file refused as a duplicate fails with the ID of the image it duplicates.
+// @Tags         images
+// @Accept       multipart/form-data
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        images        formData  file    true   "Image files or ZIP archives; the field may be repeated"
+// @Param        callback_url  formData  string  false  "URL to notify when processing of each image is done"
+// @Param        Idempotency-Key  header  string  false  "Key making retries of the request safe"
+// @Success      200  {object}  saveBatch.Response
+// @Failure      400  {object}  response.Response
+// @Failure      401  {object}  response.Response
+// @Failure      403  {object}  response.Response
+// @Failure      413  {object}  response.Response
+// @Failure      500  {object}  response.Response
+// @Router       /uploads/batch [post]
+func New(log *slog.Logger, imageSaver ImageSaver, batchCreator BatchCreator, blobStorage BlobStorage, quotas QuotaResolver, kafkaProducer producer.ProducerIface, cfg *config.Batch) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.image.saveBatch.New"
+
+		log := log.With(slog.String("op", op))
+
+		r.Body = http.MaxBytesReader(w, r.Body, cfg.MaxTotalSize+formOverhead)
+
+		if err := r.ParseMultipartForm(maxMemory); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				log.Warn("batch request too large", sl.Err(err))
+				render.Status(r, http.StatusRequestEntityTooLarge)
+				render.JSON(w, r, response.Error("batch too large"))
+				return
+			}
+
+			log.Error("failed to parse form", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error("failed to get files from request"))
+			return
+		}
+		defer r.MultipartForm.RemoveAll()
+
+		headers := r.MultipartForm.File["images"]
+		if len(headers) == 0 {
+			log.Error("no files in request")
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error("failed to get files from request"))
+			return
+		}
+
+		callbackURL := r.FormValue("callback_url")
+		if callbackURL != "" {
+			if err := webhook.ValidateURL(callbackURL); err != nil {
+				log.Error("invalid callback url", sl.Err(err))
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, response.Error("invalid callback_url"))
+				return
+			}
+		}
+
+		entries, closeArchives := collectEntries(headers, cfg)
+		defer closeArchives()
+
+		if len(entries) > cfg.MaxFiles {
+			log.Warn("too many files in batch", slog.Int("files", len(entries)))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error("too many files"))
+			return
+		}
+
+		tenantID, err := tenant.FromContext(r.Context())
+		if err != nil {
+			log.Error("request has no tenant", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to save files"))
+			return
+		}
+
+		ownerKeyID := apikey.OwnerID(r.Context())
+		limits := quotas.For(tenantID, ownerKeyID)
+
+		tenantUsage, keyUsage, err := imageSaver.GetUsage(r.Context(), ownerKeyID)
+		if err != nil {
+			log.Error("failed to get usage", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to save files"))
+			return
+		}
+
+		scopes := []quota.Scope{{Limits: limits.Tenant, Usage: tenantUsage}}
+		if keyUsage != nil {
+			scopes = append(scopes, quota.Scope{Limits: limits.Key, Usage: *keyUsage})
+		}
+
+		batch, err := batchCreator.CreateBatch(r.Context(), ownerKeyID)
+		if err != nil {
+			log.Error("failed to create batch", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to save files"))
+			return
+		}
+
+		log = log.With(slog.String("batch_id", batch.ID.String()))
+
+		resp := Response{Response: response.OK(), BatchID: batch.ID, Files: make([]FileResult, 0, len(entries))}
+
+		saver := &fileSaver{
+			log:           log,
+			imageSaver:    imageSaver,
+			blobStorage:   blobStorage,
+			kafkaProducer: kafkaProducer,
+			tenantID:      tenantID,
+			limits:        limits,
+			upload:        models.Upload{OwnerKeyID: ownerKeyID, CallbackURL: callbackURL, BatchID: &batch.ID},
+			maxFileSize:   cfg.MaxFileSize,
+			budget:        cfg.MaxTotalSize,
+		}
+
+		for _, e := range entries {
+			result := FileResult{Response: response.OK(), Filename: e.name}
+
+			now := time.Now()
+			if e.err == "" {
+				if err = quota.Check(scopes, e.size, now); err != nil {
+					e.err = quotaError(err)
+				}
+			}
+
+			if e.err == "" {
+				var image *models.Image
+				image, result.Duplicate, e.err = saver.save(r.Context(), e)
+				if image != nil {
+					result.ImageID = &image.ID
+				}
+				if image != nil && !result.Duplicate {
+					for i := range scopes {
+						scopes[i] = scopes[i].Add(image.Size, now)
+					}
+				}
+			}
+
+			if e.err != "" {
+				result.Response = response.Error(e.err)
+				resp.Failed++
+			} else {
+				resp.Succeeded++
+			}
+			resp.Files = append(resp.Files, result)
+		}
+
+		log.Info("batch saved", slog.Int("succeeded", resp.Succeeded), slog.Int("failed", resp.Failed))
+
+		now := time.Now()
+		quota.SetHeaders(w.Header(), quota.NewStatus(scopes, now), now)
+
+		render.JSON(w, r, resp)
+	}
+}
+
+// fileSaver saves the files of one batch.
+type fileSaver struct {
+	log           *slog.Logger
+	imageSaver    ImageSaver
+	blobStorage   BlobStorage
+	kafkaProducer producer.ProducerIface
+	tenantID      string
+	limits        quota.Set
+	// upload holds what the images of the batch have in common.
+	upload      models.Upload
+	maxFileSize int64
+	// budget is how many more bytes the batch may store.
+	budget int64
+}
+
+// save stores one entry and starts its processing, returning the reason
+// for the client if it fails. The image is returned whenever it was
+// recorded, even if its processing could not be started, and so is the
+// image the entry duplicates, with duplicate set.
+func (s *fileSaver) save(ctx context.Context, e entry) (image *models.Image, duplicate bool, reason string) {
+	log := s.log.With(slog.String("filename", e.name))
+
+	rc, err := e.open()
+	if err != nil {
+		log.Error("failed to open file", sl.Err(err))
+		return nil, false, "failed to read file"
+	}
+	defer rc.Close()
+
+	// Sizes declared by an archive are not trusted: the data is cut off
+	// once it outgrows the file or the batch limit.
+	reader := &limitedReader{r: rc, fileLeft: s.maxFileSize, budget: &s.budget}
+
+	// The file is read once, straight into storage, so its leading bytes
+	// are peeked at rather than consumed.
+	body := bufio.NewReaderSize(reader, imageformat.SniffLen)
+	header, err := body.Peek(imageformat.SniffLen)
+	if len(header) == 0 {
+		if errors.Is(err, io.EOF) {
+			return nil, false, "received empty file"
+		}
+		return nil, false, readFailed(log, err)
+	}
+	if _, ok := imageformat.Sniff(header); !ok {
+		log.Warn("rejected file of unknown type")
+		return nil, false, "unsupported image type"
+	}
+
+	key := tenant.BlobKey(s.tenantID, uploadDir, uuid.NewString()+strings.ToLower(filepath.Ext(e.name)))
+
+	info, err := s.blobStorage.Put(ctx, key, body)
+	if err != nil {
+		return nil, false, readFailed(log, err)
+	}
+
+	upload := s.upload
+	upload.Filename = e.name
+	upload.OriginalPath = info.Key
+	upload.Size = info.Size
+	upload.SHA256 = info.Checksum
+
+	image, err = s.imageSaver.SaveImage(ctx, upload, s.limits)
+	if err != nil {
+		s.remove(ctx, info.Key)
+
+		var dup *storage.DuplicateError
+		if errors.As(err, &dup) {
+			log.Info("file duplicates an image", slog.String("image_id", dup.Image.ID.String()), slog.Bool("rejected", dup.Rejected))
+			if dup.Rejected {
+				return dup.Image, true, "duplicate image"
+			}
+			return dup.Image, true, ""
+		}
+
+		if errors.Is(err, quota.ErrStorageExceeded) || errors.Is(err, quota.ErrRateExceeded) {
+			return nil, false, quotaError(err)
+		}
+
+		log.Error("failed to save image metadata", sl.Err(err))
+		return nil, false, "failed to save image metadata"
+	}
+
+	// The image shares the original of an identical upload, and if that
+	// one is processed, its variants too.
+	if image.OriginalPath != info.Key {
+		s.remove(ctx, info.Key)
+	}
+	if image.Status == statusProcessed {
+		return image, false, ""
+	}
+
+	message, err := json.Marshal(models.ProcessingJob{
+		ImageID:      image.ID,
+		TenantID:     image.TenantID,
+		OriginalPath: image.OriginalPath,
+	})
+	if err != nil {
+		log.Error("failed to marshal kafka message", sl.Err(err))
+		return image, false, "failed to prepare message"
+	}
+
+	if err = s.kafkaProducer.SendMessage(ctx, message); err != nil {
+		log.Error("failed to publish message to kafka", slog.String("image_id", image.ID.String()), sl.Err(err))
+		return image, false, "failed to start image processing"
+	}
+
+	return image, false, ""
+}
+
+// readFailed tells the client why the file could not be read or stored.
+func readFailed(log *slog.Logger, err error) string {
+	switch {
+	case errors.Is(err, errFileTooLarge):
+		return "file too large"
+	case errors.Is(err, errTotalTooLarge):
+		return "batch too large"
+	case errors.Is(err, zip.ErrChecksum), errors.Is(err, zip.ErrFormat):
+		return "invalid zip entry"
+	}
+
+	log.Error("failed to store file", sl.Err(err))
+	return "failed to save file"
+}
+
+func (s *fileSaver) remove(ctx context.Context, key string) {
+	if err := s.blobStorage.Delete(ctx, key); err != nil {
+		s.log.Error("failed to remove rejected upload", slog.String("key", key), sl.Err(err))
+	}
+}
+
+// collectEntries lists the files of the request, expanding ZIP archives.
+// The returned function closes the archives opened.
+func collectEntries(headers []*multipart.FileHeader, cfg *config.Batch) ([]entry, func()) {
+	var entries []entry
+	var archives []multipart.File
+
+	closeArchives := func() {
+		for _, f := range archives {
+			f.Close()
+		}
+	}
+
+	for _, header := range headers {
+		if !isZip(header) {
+			e := entry{name: header.Filename, size: header.Size, open: openFile(header)}
+			if header.Size == 0 {
+				e.err = "received empty file"
+			} else if header.Size > cfg.MaxFileSize {
+				e.err = "file too large"
+			}
+			entries = append(entries, e)
+			continue
+		}
+
+		file, err := header.Open()
+		if err != nil {
+			entries = append(entries, entry{name: header.Filename, err: "failed to read file"})
+			continue
+		}
+		archives = append(archives, file)
+
+		archive, err := zip.NewReader(file, header.Size)
+		if err != nil {
+			entries = append(entries, entry{name: header.Filename, err: "invalid zip archive"})
+			continue
+		}
+
+		for _, f := range archive.File {
+			if skipZipEntry(f) {
+				continue
+			}
+
+			name, ok := zipEntryName(f.Name)
+			if !ok {
+				entries = append(entries, entry{name: f.Name, err: "invalid file name"})
+				continue
+			}
+
+			e := entry{name: name, size: int64(f.UncompressedSize64), open: f.Open}
+			if f.UncompressedSize64 > uint64(cfg.MaxFileSize) {
+				e.err = "file too large"
+			}
+			entries = append(entries, e)
+
+			// Stop early rather than list the rest of a huge archive; the
+			// batch is rejected either way.
+			if len(entries) > cfg.MaxFiles {
+				return entries, closeArchives
+			}
+		}
+	}
+
+	return entries, closeArchives
+}
+
+func openFile(header *multipart.FileHeader) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		return header.Open()
+	}
+}
+
+func isZip(header *multipart.FileHeader) bool {
+	contentType := header.Header.Get("Content-Type")
+
+	return strings.EqualFold(filepath.Ext(header.Filename), ".zip") ||
+		contentType == "application/zip" ||
+		contentType == "application/x-zip-compressed"
+}
+
+// skipZipEntry reports whether an entry is not a file of its own: a
+// directory, or metadata left by the archiver such as __MACOSX.
+func skipZipEntry(f *zip.File) bool {
+	if f.FileInfo().IsDir() || strings.HasSuffix(f.Name, "/") {
+		return true
+	}
+
+	name := strings.ReplaceAll(f.Name, `\`, "/")
+
+	return strings.HasPrefix(name, "__MACOSX/") || strings.HasPrefix(path.Base(name), ".")
+}
+
+// zipEntryName returns the name an entry is saved under. Names that point
+// outside the archive, like "../x" or "/etc/x", are refused: they are never
+// used as paths here, but they only come from crafted archives.
+func zipEntryName(name string) (string, bool) {
+	name = strings.ReplaceAll(name, `\`, "/")
+	if name == "" || path.IsAbs(name) || (len(name) > 1 && name[1] == ':') {
+		return "", false
+	}
+
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return "", false
+		}
+	}
+
+	return path.Base(name), true
+}
+
+func quotaError(err error) string {
+	if errors.Is(err, quota.ErrRateExceeded) {
+		return "upload rate exceeded"
+	}
+
+	return "storage quota exceeded"
+}
+
+// limitedReader fails once more than fileLeft bytes are read or the batch
+// budget, shared by all the files, is spent.
+type limitedReader struct {
+	r        io.Reader
+	fileLeft int64
+	budget   *int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+
+	l.fileLeft -= int64(n)
+	*l.budget -= int64(n)
+
+	if l.fileLeft < 0 {
+		return n, errFileTooLarge
+	}
+	if *l.budget < 0 {
+		return n, errTotalTooLarge
+	}
+
+	return n, err
+}
@@ -0,0 +1,332 @@
+package saveBatch_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"imageProcessor/internal/config"
+	"imageProcessor/internal/http-server/handlers/image/saveBatch"
+	"imageProcessor/internal/http-server/handlers/image/saveBatch/mocks"
+	kafkaMocks "imageProcessor/internal/kafka/producer/mocks"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/lib/quota"
+	"imageProcessor/internal/lib/tenant"
+	"imageProcessor/internal/models"
+	"imageProcessor/internal/storage"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testFile struct {
+	name    string
+	content []byte
+}
+
+type fileResult struct {
+	Status    string `json:"status"`
+	Error     string `json:"error"`
+	Filename  string `json:"filename"`
+	Duplicate bool   `json:"duplicate"`
+}
+
+func zipArchive(t *testing.T, files ...testFile) []byte {
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	for _, f := range files {
+		w, err := zw.Create(f.name)
+		require.NoError(t, err)
+		_, err = w.Write(f.content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+
+	return buf.Bytes()
+}
+
+func TestSaveBatch(t *testing.T) {
+	log := slog.New(slog.NewJSONHandler(bytes.NewBuffer(nil), nil))
+
+	testKey := &models.APIKey{ID: uuid.New(), TenantID: "shop", Scopes: []string{apikey.ScopeUpload}}
+	batchID := uuid.New()
+
+	cfg := config.Batch{MaxFiles: 10, MaxFileSize: 100, MaxTotalSize: 1000}
+	image := []byte("\xff\xd8\xff\xe0image content")
+	// jpeg is n bytes starting like a JPEG.
+	jpeg := func(n int) []byte {
+		return append([]byte("\xff\xd8\xff\xe0"), bytes.Repeat([]byte("x"), n-4)...)
+	}
+
+	tests := []struct {
+		name           string
+		files          []testFile
+		cfg            config.Batch
+		limits         quota.Set
+		dedup          storage.DedupMode
+		mockBatchErr   error
+		mockKafkaErr   error
+		expectedStatus int
+		expectedError  string
+		expectedFiles  []fileResult
+	}{
+		{
+			name:           "Multiple Files",
+			files:          []testFile{{"a.jpg", image}, {"b.png", image}},
+			cfg:            cfg,
+			expectedStatus: http.StatusOK,
+			expectedFiles: []fileResult{
+				{Status: "OK", Filename: "a.jpg"},
+				{Status: "OK", Filename: "b.png"},
+			},
+		},
+		{
+			name: "ZIP Archive",
+			files: []testFile{{"photos.zip", zipArchive(t,
+				testFile{"a.jpg", image},
+				testFile{"dir/", nil},
+				testFile{"__MACOSX/._a.jpg", image},
+				testFile{"nested/b.png", image},
+				testFile{"../evil.jpg", image},
+				testFile{"/etc/evil.jpg", image},
+				testFile{"empty.jpg", nil},
+			)}},
+			cfg:            cfg,
+			expectedStatus: http.StatusOK,
+			expectedFiles: []fileResult{
+				{Status: "OK", Filename: "a.jpg"},
+				{Status: "OK", Filename: "b.png"},
+				{Status: "Error", Error: "invalid file name", Filename: "../evil.jpg"},
+				{Status: "Error", Error: "invalid file name", Filename: "/etc/evil.jpg"},
+				{Status: "Error", Error: "received empty file", Filename: "empty.jpg"},
+			},
+		},
+		{
+			name: "Oversized Files",
+			files: []testFile{
+				{"big.jpg", jpeg(101)},
+				{"big.zip", zipArchive(t, testFile{"big.png", jpeg(101)})},
+				{"ok.jpg", image},
+			},
+			cfg:            cfg,
+			expectedStatus: http.StatusOK,
+			expectedFiles: []fileResult{
+				{Status: "Error", Error: "file too large", Filename: "big.jpg"},
+				{Status: "Error", Error: "file too large", Filename: "big.png"},
+				{Status: "OK", Filename: "ok.jpg"},
+			},
+		},
+		{
+			name: "Archive Over Batch Limit",
+			files: []testFile{{"bomb.zip", zipArchive(t,
+				testFile{"a.jpg", jpeg(90)},
+				testFile{"b.jpg", jpeg(90)},
+			)}},
+			cfg:            config.Batch{MaxFiles: 10, MaxFileSize: 100, MaxTotalSize: 150},
+			expectedStatus: http.StatusOK,
+			expectedFiles: []fileResult{
+				{Status: "OK", Filename: "a.jpg"},
+				{Status: "Error", Error: "batch too large", Filename: "b.jpg"},
+			},
+		},
+		{
+			name: "Not Images",
+			files: []testFile{
+				{"notes.jpg", []byte("just some text")},
+				{"docs.zip", zipArchive(t,
+					testFile{"readme.txt", []byte("read me")},
+					testFile{"a.jpg", image},
+				)},
+			},
+			cfg:            cfg,
+			expectedStatus: http.StatusOK,
+			expectedFiles: []fileResult{
+				{Status: "Error", Error: "unsupported image type", Filename: "notes.jpg"},
+				{Status: "Error", Error: "unsupported image type", Filename: "readme.txt"},
+				{Status: "OK", Filename: "a.jpg"},
+			},
+		},
+		{
+			name:           "Invalid ZIP",
+			files:          []testFile{{"broken.zip", []byte("not a zip")}, {"a.jpg", image}},
+			cfg:            cfg,
+			expectedStatus: http.StatusOK,
+			expectedFiles: []fileResult{
+				{Status: "Error", Error: "invalid zip archive", Filename: "broken.zip"},
+				{Status: "OK", Filename: "a.jpg"},
+			},
+		},
+		{
+			name:           "Quota Exceeded Midway",
+			files:          []testFile{{"a.jpg", image}, {"b.jpg", image}},
+			cfg:            cfg,
+			limits:         quota.Set{Tenant: quota.Limits{MaxImages: 1}},
+			expectedStatus: http.StatusOK,
+			expectedFiles: []fileResult{
+				{Status: "OK", Filename: "a.jpg"},
+				{Status: "Error", Error: "storage quota exceeded", Filename: "b.jpg"},
+			},
+		},
+		{
+			name:           "Duplicates Returned",
+			files:          []testFile{{"a.jpg", image}, {"b.jpg", image}},
+			cfg:            cfg,
+			dedup:          storage.DedupExisting,
+			expectedStatus: http.StatusOK,
+			expectedFiles: []fileResult{
+				{Status: "OK", Filename: "a.jpg"},
+				{Status: "OK", Filename: "b.jpg", Duplicate: true},
+			},
+		},
+		{
+			name:           "Duplicates Rejected",
+			files:          []testFile{{"a.jpg", image}, {"b.jpg", image}},
+			cfg:            cfg,
+			dedup:          storage.DedupReject,
+			expectedStatus: http.StatusOK,
+			expectedFiles: []fileResult{
+				{Status: "OK", Filename: "a.jpg"},
+				{Status: "Error", Error: "duplicate image", Filename: "b.jpg", Duplicate: true},
+			},
+		},
+		{
+			name:           "Processing Not Started",
+			files:          []testFile{{"a.jpg", image}},
+			cfg:            cfg,
+			mockKafkaErr:   errors.New("kafka error"),
+			expectedStatus: http.StatusOK,
+			expectedFiles: []fileResult{
+				{Status: "Error", Error: "failed to start image processing", Filename: "a.jpg"},
+			},
+		},
+		{
+			name:           "Too Many Files",
+			files:          []testFile{{"a.zip", zipArchive(t, testFile{"a.jpg", image}, testFile{"b.jpg", image}, testFile{"c.jpg", image})}},
+			cfg:            config.Batch{MaxFiles: 2, MaxFileSize: 100, MaxTotalSize: 1000},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "too many files",
+		},
+		{
+			name:           "Request Too Large",
+			files:          []testFile{{"a.jpg", jpeg(2 << 20)}},
+			cfg:            cfg,
+			expectedStatus: http.StatusRequestEntityTooLarge,
+			expectedError:  "batch too large",
+		},
+		{
+			name:           "No Files",
+			cfg:            cfg,
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "failed to get files from request",
+		},
+		{
+			name:           "Failed to Create Batch",
+			files:          []testFile{{"a.jpg", image}},
+			cfg:            cfg,
+			mockBatchErr:   errors.New("db error"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedError:  "failed to save files",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			imageSaverMock := mocks.NewImageSaver(t)
+			batchCreatorMock := mocks.NewBatchCreator(t)
+			blobStorageMock := mocks.NewBlobStorage(t)
+			quotaResolverMock := mocks.NewQuotaResolver(t)
+			kafkaProducerMock := kafkaMocks.NewProducerIface(t)
+
+			quotaResolverMock.On("For", "shop", &testKey.ID).Return(tt.limits).Maybe()
+			imageSaverMock.On("GetUsage", mock.Anything, &testKey.ID).Return(models.Usage{}, &models.Usage{}, nil).Maybe()
+			batchCreatorMock.On("CreateBatch", mock.Anything, &testKey.ID).
+				Return(&models.Batch{ID: batchID, TenantID: "shop", OwnerKeyID: &testKey.ID}, tt.mockBatchErr).Maybe()
+
+			blobStorageMock.On("Put", mock.Anything, mock.Anything, mock.Anything).
+				Return(func(_ context.Context, key string, r io.Reader) (*storage.BlobInfo, error) {
+					require.True(t, strings.HasPrefix(key, "shop/uploads/"), key)
+					data, err := io.ReadAll(r)
+					if err != nil {
+						return nil, err
+					}
+					sum := sha256.Sum256(data)
+					return &storage.BlobInfo{Key: key, Size: int64(len(data)), Checksum: hex.EncodeToString(sum[:])}, nil
+				}).Maybe()
+			blobStorageMock.On("Delete", mock.Anything, mock.Anything).Return(nil).Maybe()
+
+			saved := make(map[string]*models.Image)
+			imageSaverMock.On("SaveImage", mock.Anything, mock.Anything, tt.limits).
+				Return(func(_ context.Context, upload models.Upload, _ quota.Set) (*models.Image, error) {
+					require.Equal(t, batchID, *upload.BatchID)
+					require.Equal(t, testKey.ID, *upload.OwnerKeyID)
+					require.Len(t, upload.SHA256, 64)
+					if existing, ok := saved[upload.SHA256]; ok && tt.dedup != "" {
+						return nil, &storage.DuplicateError{Image: existing, Rejected: tt.dedup == storage.DedupReject}
+					}
+					image := &models.Image{ID: uuid.New(), TenantID: "shop", Filename: upload.Filename, OriginalPath: upload.OriginalPath, Size: upload.Size}
+					saved[upload.SHA256] = image
+					return image, nil
+				}).Maybe()
+			kafkaProducerMock.On("SendMessage", mock.Anything, mock.Anything).Return(tt.mockKafkaErr).Maybe()
+
+			body := new(bytes.Buffer)
+			writer := multipart.NewWriter(body)
+			for _, f := range tt.files {
+				part, err := writer.CreateFormFile("images", f.name)
+				require.NoError(t, err)
+				_, err = part.Write(f.content)
+				require.NoError(t, err)
+			}
+			require.NoError(t, writer.Close())
+
+			req := httptest.NewRequest(http.MethodPost, "/uploads/batch", body)
+			req.Header.Set("Content-Type", writer.FormDataContentType())
+			req = req.WithContext(tenant.WithID(apikey.WithKey(req.Context(), testKey), testKey.TenantID))
+
+			rr := httptest.NewRecorder()
+
+			handler := saveBatch.New(log, imageSaverMock, batchCreatorMock, blobStorageMock, quotaResolverMock, kafkaProducerMock, &tt.cfg)
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+
+			var resp struct {
+				Status    string       `json:"status"`
+				Error     string       `json:"error"`
+				BatchID   uuid.UUID    `json:"batch_id"`
+				Succeeded int          `json:"succeeded"`
+				Failed    int          `json:"failed"`
+				Files     []fileResult `json:"files"`
+			}
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+
+			if tt.expectedError != "" {
+				require.Equal(t, tt.expectedError, resp.Error)
+				blobStorageMock.AssertNotCalled(t, "Put", mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+
+			require.Equal(t, batchID, resp.BatchID)
+			require.Equal(t, tt.expectedFiles, resp.Files)
+
+			succeeded := 0
+			for _, f := range tt.expectedFiles {
+				if f.Status == "OK" {
+					succeeded++
+				}
+			}
+			require.Equal(t, succeeded, resp.Succeeded)
+			require.Equal(t, len(tt.expectedFiles)-succeeded, resp.Failed)
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package saveImage
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-chi/render"
+	"imageProcessor/internal/lib/api/response"
+	"imageProcessor/internal/webhook"
+	"io"
+	"mime/multipart"
+	"net/http"
+)
+
+// maxFieldSize bounds the form fields other than the image, which are read
+// into memory.
+const maxFieldSize = 4 << 10
+
+var (
+	errNoImage         = errors.New("form has no image")
+	errFieldTooLarge   = errors.New("form field too large")
+	errInvalidCallback = errors.New("invalid callback_url")
+)
+
+// uploadForm walks a multipart upload part by part, so that the image can be
+// streamed to storage without the form being parsed first. Fields may come
+// before or after the image.
+type uploadForm struct {
+	reader      *multipart.Reader
+	callbackURL string
+}
+
+// image reads the fields up to the image part and returns it.
+func (f *uploadForm) image() (*multipart.Part, error) {
+	for {
+		part, err := f.reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil, errNoImage
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if part.FormName() == "image" && part.FileName() != "" {
+			return part, nil
+		}
+
+		if err = f.field(part); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// rest reads the fields that follow the image.
+func (f *uploadForm) rest() (string, error) {
+	for {
+		part, err := f.reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return f.callbackURL, nil
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if err = f.field(part); err != nil {
+			return "", err
+		}
+	}
+}
+
+// field keeps the fields the upload knows and skips the others.
+func (f *uploadForm) field(part *multipart.Part) error {
+	defer func() {
+		_ = part.Close()
+	}()
+
+	if part.FormName() != "callback_url" {
+		_, err := io.Copy(io.Discard, part)
+		return err
+	}
+
+	value, err := io.ReadAll(io.LimitReader(part, maxFieldSize+1))
+	if err != nil {
+		return err
+	}
+	if len(value) > maxFieldSize {
+		return errFieldTooLarge
+	}
+
+	callbackURL := string(value)
+	if callbackURL != "" {
+		if err = webhook.ValidateURL(callbackURL); err != nil {
+			return fmt.Errorf("%w: %w", errInvalidCallback, err)
+		}
+	}
+	f.callbackURL = callbackURL
+
+	return nil
+}
+
+// formFailed answers for a form that could not be read.
+func formFailed(w http.ResponseWriter, r *http.Request, err error) {
+	var tooLarge *http.MaxBytesError
+
+	switch {
+	case errors.As(err, &tooLarge):
+		render.Status(r, http.StatusRequestEntityTooLarge)
+		render.JSON(w, r, response.Error("request body too large"))
+	case errors.Is(err, errInvalidCallback):
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, response.Error("invalid callback_url"))
+	default:
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, response.Error("failed to get file from request"))
+	}
+}
@@ -0,0 +1,155 @@
+package saveImage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	"imageProcessor/internal/fetcher"
+	"imageProcessor/internal/ingest"
+	"imageProcessor/internal/kafka/producer"
+	"imageProcessor/internal/lib/api/response"
+	"imageProcessor/internal/lib/logger/sl"
+	"imageProcessor/internal/webhook"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// errFetch marks a failure to read the image from the origin, as opposed to
+// one to store it.
+var errFetch = errors.New("failed to fetch image")
+
+type URLRequest struct {
+	URL         string `json:"url" validate:"required,url"`
+	CallbackURL string `json:"callback_url,omitempty"`
+}
+
+type URLFetcher interface {
+	Fetch(ctx context.Context, rawURL string) (*fetcher.Image, error)
+}
+
+// NewFromURL imports an image from a remote origin.
+// @Summary      Imports an image from a URL
+// @Description  Downloads the image at url and processes it as if it had been uploaded. Only http and https URLs of public addresses are fetched, within the size, time, redirect and content type limits configured. The Content-Type of the origin is not trusted: the image's type is told from its leading bytes as for an upload, and anything else is refused with 415. The import counts against the quotas and is deduplicated like an upload.
+// @Tags         images
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        request  body  saveImage.URLRequest  true  "Image URL"
+// @Param        Idempotency-Key  header  string  false  "Key making retries of the request safe"
+// @Success      200  {object}  saveImage.ImageResponse
+// @Failure      400  {object}  response.Response
+// @Failure      401  {object}  response.Response
+// @Failure      403  {object}  response.Response
+// @Failure      409  {object}  saveImage.ImageResponse
+// @Failure      413  {object}  response.Response
+// @Failure      415  {object}  response.Response
+// @Failure      429  {object}  response.Response
+// @Failure      500  {object}  response.Response
+// @Failure      502  {object}  response.Response
+// @Failure      507  {object}  response.Response
+// @Router       /upload/url [post]
+func NewFromURL(log *slog.Logger, urlFetcher URLFetcher, imageSaver ImageSaver, blobStorage BlobStorage, quotas QuotaResolver, kafkaProducer producer.ProducerIface, fetchTimeout, writeTimeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.image.saveImage.NewFromURL"
+
+		log := log.With(slog.String("op", op))
+
+		var req URLRequest
+
+		err := render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			log.Error("request body is empty")
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error("empty request"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error("failed to decode request"))
+			return
+		}
+
+		if err = validator.New().Struct(req); err != nil {
+			var validateErr validator.ValidationErrors
+			errors.As(err, &validateErr)
+
+			log.Error("invalid request", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.ValidationError(validateErr))
+			return
+		}
+
+		if req.CallbackURL != "" {
+			if err = webhook.ValidateURL(req.CallbackURL); err != nil {
+				log.Error("invalid callback url", sl.Err(err))
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, response.Error("invalid callback_url"))
+				return
+			}
+		}
+
+		// The server's write timeout would cut the download short.
+		_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(fetchTimeout + writeTimeout))
+
+		image, err := urlFetcher.Fetch(r.Context(), req.URL)
+		if err != nil {
+			log.Warn("failed to fetch image", slog.String("url", req.URL), sl.Err(err))
+			fetchFailed(w, r, err)
+			return
+		}
+		defer func() {
+			_ = image.Body.Close()
+		}()
+
+		log.Info("fetching image", slog.String("url", req.URL), slog.Int64("size", image.Size))
+
+		s := saver{ingester: ingest.New(imageSaver, blobStorage, quotas, kafkaProducer)}
+		s.save(w, r, log, ingest.Source{
+			Filename:    image.Filename,
+			Size:        image.Size,
+			Body:        &remoteBody{r: image.Body},
+			CallbackURL: req.CallbackURL,
+		})
+	}
+}
+
+// fetchFailed answers for the origin: what the client could fix is a 4xx,
+// what went wrong on the other end a 502.
+func fetchFailed(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, fetcher.ErrInvalidURL):
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, response.Error("invalid url"))
+	case errors.Is(err, fetcher.ErrForbiddenAddress):
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, response.Error("url not allowed"))
+	case errors.Is(err, fetcher.ErrTooLarge):
+		render.Status(r, http.StatusRequestEntityTooLarge)
+		render.JSON(w, r, response.Error("image too large"))
+	case errors.Is(err, fetcher.ErrContentType):
+		render.Status(r, http.StatusUnsupportedMediaType)
+		render.JSON(w, r, response.Error("unsupported content type"))
+	default:
+		render.Status(r, http.StatusBadGateway)
+		render.JSON(w, r, response.Error("failed to fetch image"))
+	}
+}
+
+// remoteBody tags the errors of the origin, which Put passes on as its own.
+type remoteBody struct {
+	r io.Reader
+}
+
+func (b *remoteBody) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	if err != nil && err != io.EOF && !errors.Is(err, fetcher.ErrTooLarge) {
+		err = fmt.Errorf("%w: %w", errFetch, err)
+	}
+
+	return n, err
+}
@@ -0,0 +1,236 @@
+package saveImage_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"imageProcessor/internal/config"
+	"imageProcessor/internal/fetcher"
+	"imageProcessor/internal/http-server/handlers/image/saveImage"
+	saverMocks "imageProcessor/internal/http-server/handlers/image/saveImage/mocks"
+	kafkaMocks "imageProcessor/internal/kafka/producer/mocks"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/lib/quota"
+	"imageProcessor/internal/lib/tenant"
+	"imageProcessor/internal/models"
+	"imageProcessor/internal/storage"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSaveImageFromURL(t *testing.T) {
+	log := slog.New(slog.NewJSONHandler(bytes.NewBuffer(nil), nil))
+
+	testUUID := uuid.New()
+	testKey := &models.APIKey{ID: uuid.New(), TenantID: "shop", Scopes: []string{apikey.ScopeUpload}}
+	content := []byte("\xff\xd8\xff\xe0remote image content")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/photos/cat.jpg", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/jpeg")
+		_, _ = w.Write(content)
+	})
+	mux.HandleFunc("/empty.jpg", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/jpeg")
+	})
+	mux.HandleFunc("/page.html", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte("<html></html>"))
+	})
+	mux.HandleFunc("/fake.jpg", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/jpeg")
+		_, _ = w.Write([]byte("<html></html>"))
+	})
+	mux.HandleFunc("/big.jpg", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/jpeg")
+		_, _ = w.Write(append([]byte("\xff\xd8\xff\xe0"), bytes.Repeat([]byte("x"), 97)...))
+	})
+	mux.HandleFunc("/chunked.jpg", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/jpeg")
+		_, _ = w.Write([]byte("\xff\xd8\xff\xe0"))
+		for range 10 {
+			_, _ = w.Write(bytes.Repeat([]byte("x"), 10))
+			w.(http.Flusher).Flush()
+		}
+	})
+	mux.HandleFunc("/missing.jpg", func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+
+	origin := httptest.NewServer(mux)
+	defer origin.Close()
+
+	cfg := config.Import{
+		Timeout:         time.Second,
+		MaxSize:         100,
+		MaxRedirects:    3,
+		ContentTypes:    []string{"image/jpeg"},
+		AllowedNetworks: []string{"127.0.0.0/8"},
+	}
+
+	tests := []struct {
+		name            string
+		body            string
+		allowedNetworks []string
+		limits          quota.Set
+		stored          bool
+		expectedStatus  int
+		expectedBody    string
+	}{
+		{
+			name:           "Success",
+			body:           fmt.Sprintf(`{"url":%q,"callback_url":"https://cms.example.com/hooks"}`, origin.URL+"/photos/cat.jpg"),
+			stored:         true,
+			expectedStatus: http.StatusOK,
+			expectedBody:   fmt.Sprintf(`{"status":"OK","image_id":"%s"}`, testUUID),
+		},
+		{
+			name:           "Empty Request",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"Error","error":"empty request"}`,
+		},
+		{
+			name:           "Missing URL",
+			body:           `{}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"Error","error":"field URL is a required field"}`,
+		},
+		{
+			name:           "Invalid Scheme",
+			body:           `{"url":"ftp://cdn.example.com/cat.jpg"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"Error","error":"invalid url"}`,
+		},
+		{
+			name:           "Invalid Callback URL",
+			body:           fmt.Sprintf(`{"url":%q,"callback_url":"ftp://cms.example.com"}`, origin.URL+"/photos/cat.jpg"),
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"Error","error":"invalid callback_url"}`,
+		},
+		{
+			name:            "Loopback Not Allowed",
+			body:            fmt.Sprintf(`{"url":%q}`, origin.URL+"/photos/cat.jpg"),
+			allowedNetworks: []string{},
+			expectedStatus:  http.StatusBadRequest,
+			expectedBody:    `{"status":"Error","error":"url not allowed"}`,
+		},
+		{
+			name:           "Metadata Endpoint",
+			body:           `{"url":"http://169.254.169.254/latest/meta-data/"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"Error","error":"url not allowed"}`,
+		},
+		{
+			name:           "Not an Image",
+			body:           fmt.Sprintf(`{"url":%q}`, origin.URL+"/page.html"),
+			expectedStatus: http.StatusUnsupportedMediaType,
+			expectedBody:   `{"status":"Error","error":"unsupported content type"}`,
+		},
+		{
+			name:           "Content Not an Image",
+			body:           fmt.Sprintf(`{"url":%q}`, origin.URL+"/fake.jpg"),
+			expectedStatus: http.StatusUnsupportedMediaType,
+			expectedBody:   `{"status":"Error","error":"unsupported image type"}`,
+		},
+		{
+			name:           "Announced Too Large",
+			body:           fmt.Sprintf(`{"url":%q}`, origin.URL+"/big.jpg"),
+			expectedStatus: http.StatusRequestEntityTooLarge,
+			expectedBody:   `{"status":"Error","error":"image too large"}`,
+		},
+		{
+			name:           "Streamed Too Large",
+			body:           fmt.Sprintf(`{"url":%q}`, origin.URL+"/chunked.jpg"),
+			stored:         true,
+			expectedStatus: http.StatusRequestEntityTooLarge,
+			expectedBody:   `{"status":"Error","error":"image too large"}`,
+		},
+		{
+			name:           "Empty Image",
+			body:           fmt.Sprintf(`{"url":%q}`, origin.URL+"/empty.jpg"),
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"Error","error":"received empty file"}`,
+		},
+		{
+			name:           "Origin Not Found",
+			body:           fmt.Sprintf(`{"url":%q}`, origin.URL+"/missing.jpg"),
+			expectedStatus: http.StatusBadGateway,
+			expectedBody:   `{"status":"Error","error":"failed to fetch image"}`,
+		},
+		{
+			name:           "Storage Quota Exceeded",
+			body:           fmt.Sprintf(`{"url":%q}`, origin.URL+"/photos/cat.jpg"),
+			limits:         quota.Set{Tenant: quota.Limits{MaxBytes: 10}},
+			expectedStatus: http.StatusInsufficientStorage,
+			expectedBody:   `{"status":"Error","error":"storage quota exceeded"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			imageSaverMock := saverMocks.NewImageSaver(t)
+			blobStorageMock := saverMocks.NewBlobStorage(t)
+			quotaResolverMock := saverMocks.NewQuotaResolver(t)
+			kafkaProducerMock := kafkaMocks.NewProducerIface(t)
+
+			cfg := cfg
+			if tt.allowedNetworks != nil {
+				cfg.AllowedNetworks = tt.allowedNetworks
+			}
+			urlFetcher, err := fetcher.New(&cfg)
+			require.NoError(t, err)
+
+			quotaResolverMock.On("For", "shop", &testKey.ID).Return(tt.limits).Maybe()
+			imageSaverMock.On("GetUsage", mock.Anything, &testKey.ID).Return(models.Usage{}, &models.Usage{}, nil).Maybe()
+
+			if tt.stored {
+				blobStorageMock.On("Put", mock.Anything, mock.Anything, mock.Anything).
+					Return(func(_ context.Context, key string, r io.Reader) (*storage.BlobInfo, error) {
+						require.True(t, strings.HasPrefix(key, "shop/uploads/") && strings.HasSuffix(key, ".jpg"), key)
+						data, err := io.ReadAll(r)
+						if err != nil {
+							return nil, fmt.Errorf("storage.local.Put: %w", err)
+						}
+						return &storage.BlobInfo{Key: key, Size: int64(len(data))}, nil
+					}).Once()
+			}
+			if tt.expectedStatus == http.StatusOK {
+				isUpload := mock.MatchedBy(func(upload models.Upload) bool {
+					return upload.Filename == "cat.jpg" &&
+						upload.Size == int64(len(content)) &&
+						*upload.OwnerKeyID == testKey.ID &&
+						upload.CallbackURL == "https://cms.example.com/hooks"
+				})
+				imageSaverMock.On("SaveImage", mock.Anything, isUpload, tt.limits).
+					Return(func(_ context.Context, upload models.Upload, _ quota.Set) (*models.Image, error) {
+						return &models.Image{ID: testUUID, TenantID: "shop", Filename: "cat.jpg", OriginalPath: upload.OriginalPath, Size: upload.Size}, nil
+					}).Once()
+				kafkaProducerMock.On("SendMessage", mock.Anything, mock.MatchedBy(func(message []byte) bool {
+					var job models.ProcessingJob
+					return json.Unmarshal(message, &job) == nil && job.ImageID == testUUID
+				})).Return(nil).Once()
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/upload/url", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req = req.WithContext(tenant.WithID(apikey.WithKey(req.Context(), testKey), testKey.TenantID))
+
+			rr := httptest.NewRecorder()
+
+			handler := saveImage.NewFromURL(log, urlFetcher, imageSaverMock, blobStorageMock, quotaResolverMock, kafkaProducerMock, cfg.Timeout, 4*time.Second)
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+			require.JSONEq(t, tt.expectedBody, rr.Body.String())
+		})
+	}
+}
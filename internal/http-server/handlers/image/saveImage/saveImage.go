@@ -2,140 +2,221 @@ package saveImage
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"github.com/go-chi/render"
 	"github.com/google/uuid"
+	"imageProcessor/internal/fetcher"
+	"imageProcessor/internal/ingest"
 	"imageProcessor/internal/kafka/producer"
 	"imageProcessor/internal/lib/api/response"
+	"imageProcessor/internal/lib/apikey"
 	"imageProcessor/internal/lib/logger/sl"
+	"imageProcessor/internal/lib/quota"
+	"imageProcessor/internal/lib/tenant"
 	"imageProcessor/internal/models"
+	"imageProcessor/internal/storage"
 	"io"
 	"log/slog"
-	"mime/multipart"
 	"net/http"
-	"os"
-	"path/filepath"
 )
 
 type ImageResponse struct {
 	response.Response
 	ImageID uuid.UUID `json:"image_id"`
+	// Duplicate is set when the image was uploaded before and is returned
+	// in place of a new one.
+	Duplicate bool `json:"duplicate,omitempty"`
 }
 
 //go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=ImageSaver
 type ImageSaver interface {
-	SaveImage(ctx context.Context, filename string, originalPath string) (*models.Image, error)
+	SaveImage(ctx context.Context, upload models.Upload, limits quota.Set) (*models.Image, error)
+	GetUsage(ctx context.Context, keyID *uuid.UUID) (models.Usage, *models.Usage, error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=BlobStorage
+type BlobStorage interface {
+	Put(ctx context.Context, key string, r io.Reader) (*storage.BlobInfo, error)
+	Delete(ctx context.Context, key string) error
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=QuotaResolver
+type QuotaResolver interface {
+	For(tenantID string, keyID *uuid.UUID) quota.Set
 }
 
 // SaveImage uploads an image for processing.
 // @Summary      Uploads an image
-// @Description  Uploads an image file and returns its ID
+// @Description  Uploads an image file and returns its ID. The file is streamed to storage as it arrives, so the request body is capped by upload.max_body_size rather than by memory. Its type is told from its leading bytes: JPEG, PNG, GIF, TIFF and BMP are accepted. The upload counts against the storage and rate quotas of the tenant and the key, which are reported in X-Quota-* headers. If callback_url is given, an event is POSTed to it once processing has finished or failed, in addition to the webhooks of the key. Content already stored by the tenant is deduplicated by its SHA-256 as dedup.mode says: the key's image of it is returned with duplicate set, the new image shares its blobs and variants, or the upload is refused with 409.
 // @Tags         images
 // @Accept       multipart/form-data
 // @Produce      json
+// @Security     ApiKeyAuth
 // @Param        image  formData  file  true  "Image file to upload"
+// @Param        callback_url  formData  string  false  "URL to notify when processing is done"
+// @Param        Idempotency-Key  header  string  false  "Key making retries of the request safe"
 // @Success      200  {object}  saveImage.ImageResponse
 // @Failure      400  {object}  response.Response
+// @Failure      401  {object}  response.Response
+// @Failure      403  {object}  response.Response
+// @Failure      409  {object}  saveImage.ImageResponse
+// @Failure      413  {object}  response.Response
+// @Failure      415  {object}  response.Response
+// @Failure      429  {object}  response.Response
 // @Failure      500  {object}  response.Response
+// @Failure      507  {object}  response.Response
 // @Router       /upload [post]
-func New(log *slog.Logger, imageSaver ImageSaver, kafkaProducer producer.ProducerIface) http.HandlerFunc {
+func New(log *slog.Logger, imageSaver ImageSaver, blobStorage BlobStorage, quotas QuotaResolver, kafkaProducer producer.ProducerIface, maxBodySize int64) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.image.saveImage.New"
 
-		log = log.With(
+		log := log.With(
 			slog.String("op", op),
 		)
 
-		file, header, err := r.FormFile("image")
+		r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+
+		mr, err := r.MultipartReader()
 		if err != nil {
-			log.Error("failed to get file from request", sl.Err(err))
+			log.Error("failed to read multipart form", sl.Err(err))
 			render.Status(r, http.StatusBadRequest)
 			render.JSON(w, r, response.Error("failed to get file from request"))
 			return
 		}
-		defer func(file multipart.File) {
-			err = file.Close()
-			if err != nil {
-				return
-			}
-		}(file)
-
-		if header.Size == 0 {
-			log.Error("received empty file")
-			render.Status(r, http.StatusBadRequest)
-			render.JSON(w, r, response.Error("received empty file"))
-			return
-		}
 
-		uploadPath := "./uploads"
-		if _, err = os.Stat(uploadPath); os.IsNotExist(err) {
-			err = os.Mkdir(uploadPath, os.ModePerm)
-			if err != nil {
-				return
-			}
-		}
+		form := &uploadForm{reader: mr}
 
-		filePath := filepath.Join(uploadPath, header.Filename)
-		dst, err := os.Create(filePath)
+		part, err := form.image()
 		if err != nil {
-			log.Error("failed to create file on disk", sl.Err(err))
-			render.Status(r, http.StatusInternalServerError)
-			render.JSON(w, r, response.Error("failed to save file"))
-			return
-		}
-		defer func(dst *os.File) {
-			err = dst.Close()
-			if err != nil {
-				return
-			}
-		}(dst)
-
-		if _, err = io.Copy(dst, file); err != nil {
-			log.Error("failed to copy file content", sl.Err(err))
-			render.Status(r, http.StatusInternalServerError)
-			render.JSON(w, r, response.Error("failed to save file"))
+			log.Error("failed to get file from request", sl.Err(err))
+			formFailed(w, r, err)
 			return
 		}
+		defer func() {
+			_ = part.Close()
+		}()
+
+		log.Info("receiving image", slog.String("filename", part.FileName()))
+
+		s := saver{ingester: ingest.New(imageSaver, blobStorage, quotas, kafkaProducer)}
+		s.save(w, r, log, ingest.Source{
+			Filename:    part.FileName(),
+			Size:        -1,
+			Body:        part,
+			CallbackURL: form.callbackURL,
+			Trailer:     form.rest,
+		})
+	}
+}
 
-		image, err := imageSaver.SaveImage(r.Context(), header.Filename, filePath)
-		if err != nil {
-			log.Error("failed to save image metadata", sl.Err(err))
-			render.Status(r, http.StatusInternalServerError)
-			render.JSON(w, r, response.Error("failed to save image metadata"))
-			return
-		}
+// saver ingests an image received from the client or from a remote origin,
+// answering the request either way.
+type saver struct {
+	ingester *ingest.Ingester
+}
 
-		log.Info("image saved successfully", slog.String("image_id", image.ID.String()))
+func (s *saver) save(w http.ResponseWriter, r *http.Request, log *slog.Logger, src ingest.Source) {
+	tenantID, err := tenant.FromContext(r.Context())
+	if err != nil {
+		log.Error("request has no tenant", sl.Err(err))
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, response.Error("failed to save file"))
+		return
+	}
 
-		kafkaMessage := struct {
-			ImageID      uuid.UUID `json:"image_id"`
-			OriginalPath string    `json:"original_path"`
-		}{
-			ImageID:      image.ID,
-			OriginalPath: image.OriginalPath,
-		}
+	src.TenantID = tenantID
+	src.OwnerKeyID = apikey.OwnerID(r.Context())
 
-		message, err := json.Marshal(kafkaMessage)
-		if err != nil {
-			log.Error("failed to marshal kafka message", sl.Err(err))
-			render.Status(r, http.StatusInternalServerError)
-			render.JSON(w, r, response.Error("failed to prepare message"))
-			return
-		}
+	res, err := s.ingester.Ingest(r.Context(), src)
+	if err != nil {
+		saveFailed(w, r, log, res, err)
+		return
+	}
 
-		err = kafkaProducer.SendMessage(r.Context(), message)
-		if err != nil {
-			log.Error("failed to publish message to kafka", sl.Err(err))
-			render.Status(r, http.StatusInternalServerError)
-			render.JSON(w, r, response.Error("failed to start image processing"))
-			return
-		}
+	if res.Duplicate != nil {
+		log.Info("upload duplicates an image",
+			slog.String("image_id", res.Duplicate.Image.ID.String()),
+			slog.Bool("rejected", res.Duplicate.Rejected),
+		)
+		quota.SetHeaders(w.Header(), quota.NewStatus(res.Scopes, res.Now), res.Now)
+		duplicateFound(w, r, res.Duplicate)
+		return
+	}
+
+	log.Info("image saved successfully",
+		slog.String("image_id", res.Image.ID.String()),
+		slog.String("format", res.Format.Name),
+	)
 
-		log.Info("image saved successfully and message published to kafka", slog.String("image_id", image.ID.String()))
+	quota.SetHeaders(w.Header(), quota.NewStatus(res.Scopes, res.Now), res.Now)
+
+	render.JSON(w, r, ImageResponse{
+		Response: response.OK(),
+		ImageID:  res.Image.ID,
+	})
+}
 
+// saveFailed answers an image that could not be ingested.
+func saveFailed(w http.ResponseWriter, r *http.Request, log *slog.Logger, res *ingest.Result, err error) {
+	var tooLarge *http.MaxBytesError
+
+	switch {
+	case errors.Is(err, ingest.ErrEmpty):
+		log.Error("received empty file")
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, response.Error("received empty file"))
+	case errors.Is(err, ingest.ErrUnsupportedType):
+		log.Warn("rejected file of unknown type")
+		render.Status(r, http.StatusUnsupportedMediaType)
+		render.JSON(w, r, response.Error("unsupported image type"))
+	case errors.As(err, &tooLarge):
+		log.Error("request body too large", sl.Err(err))
+		render.Status(r, http.StatusRequestEntityTooLarge)
+		render.JSON(w, r, response.Error("request body too large"))
+	case errors.Is(err, quota.ErrStorageExceeded) || errors.Is(err, quota.ErrRateExceeded):
+		log.Warn("upload rejected by quota", sl.Err(err))
+		quota.WriteExceeded(w, r, err, res.Scopes, res.Now)
+	case errors.Is(err, fetcher.ErrTooLarge):
+		log.Error("remote image too large", sl.Err(err))
+		render.Status(r, http.StatusRequestEntityTooLarge)
+		render.JSON(w, r, response.Error("image too large"))
+	case errors.Is(err, errFetch):
+		log.Error("failed to fetch remote image", sl.Err(err))
+		render.Status(r, http.StatusBadGateway)
+		render.JSON(w, r, response.Error("failed to fetch image"))
+	case errors.Is(err, ingest.ErrRead) || errors.Is(err, ingest.ErrTrailer):
+		log.Error("failed to read the form", sl.Err(err))
+		formFailed(w, r, err)
+	case errors.Is(err, ingest.ErrSave):
+		log.Error("failed to save image metadata", sl.Err(err))
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, response.Error("failed to save image metadata"))
+	case errors.Is(err, ingest.ErrEnqueue):
+		log.Error("failed to publish message to kafka", slog.String("image_id", res.Image.ID.String()), sl.Err(err))
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, response.Error("failed to start image processing"))
+	default:
+		log.Error("failed to store file", sl.Err(err))
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, response.Error("failed to save file"))
+	}
+}
+
+// duplicateFound answers an upload whose content the uploader has as an
+// image already: with that image, or with 409 if the upload is rejected.
+func duplicateFound(w http.ResponseWriter, r *http.Request, duplicate *storage.DuplicateError) {
+	if duplicate.Rejected {
+		render.Status(r, http.StatusConflict)
 		render.JSON(w, r, ImageResponse{
-			Response: response.OK(),
-			ImageID:  image.ID,
+			Response: response.Error("duplicate image"),
+			ImageID:  duplicate.Image.ID,
 		})
+		return
 	}
+
+	render.JSON(w, r, ImageResponse{
+		Response:  response.OK(),
+		ImageID:   duplicate.Image.ID,
+		Duplicate: true,
+	})
 }
@@ -2,6 +2,9 @@ package saveImage_test
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,71 +14,245 @@ import (
 	"imageProcessor/internal/http-server/handlers/image/saveImage"
 	saverMocks "imageProcessor/internal/http-server/handlers/image/saveImage/mocks"
 	kafkaMocks "imageProcessor/internal/kafka/producer/mocks"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/lib/quota"
+	"imageProcessor/internal/lib/tenant"
 	"imageProcessor/internal/models"
+	"imageProcessor/internal/storage"
+	"io"
 	"log/slog"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
-	"os"
-	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestSaveImage(t *testing.T) {
 	log := slog.New(slog.NewJSONHandler(bytes.NewBuffer(nil), nil))
 
-	tempDir := t.TempDir()
-	uploadsDir := filepath.Join(tempDir, "uploads")
-	os.Mkdir(uploadsDir, os.ModePerm)
-
 	testUUID, _ := uuid.NewRandom()
+	testKey := &models.APIKey{ID: uuid.New(), TenantID: "shop", Scopes: []string{apikey.ScopeUpload}}
+	// Uploads are told apart by their leading bytes, a JPEG's here.
+	content := []byte("\xff\xd8\xff\xe0test file content")
+	large := append([]byte("\xff\xd8\xff\xe0"), bytes.Repeat([]byte("x"), 4096)...)
+
+	limits := quota.Set{Tenant: quota.Limits{MaxBytes: 1000, MaxImages: 10, UploadsPerHour: 5}}
+	recentWindow := time.Now().Add(-10 * time.Minute)
+	usage := models.Usage{Bytes: 100, Images: 2, WindowStart: recentWindow, WindowUploads: 1}
+
+	sum := sha256.Sum256(content)
+	checksum := hex.EncodeToString(sum[:])
+	existingID := uuid.New()
 
 	tests := []struct {
-		name           string
-		fileContent    []byte
-		fileName       string
-		mockImage      *models.Image
-		mockSaveErr    error
-		mockKafkaErr   error
-		expectedStatus int
-		expectedBody   string
+		name            string
+		fileContent     []byte
+		noFile          bool
+		callbackURL     string
+		callbackFirst   bool
+		maxBodySize     int64
+		limits          quota.Set
+		tenantUsage     models.Usage
+		keyUsage        models.Usage
+		mockImage       *models.Image
+		mockPutErr      error
+		mockSaveErr     error
+		mockKafkaErr    error
+		expectedStatus  int
+		expectedBody    string
+		expectedHeaders map[string]string
 	}{
 		{
 			name:           "Success",
-			fileContent:    []byte("test file content"),
-			fileName:       "test.jpg",
-			mockImage:      &models.Image{ID: testUUID, Filename: "test.jpg", OriginalPath: "uploads/test.jpg"},
-			mockSaveErr:    nil,
-			mockKafkaErr:   nil,
+			fileContent:    content,
+			limits:         limits,
+			tenantUsage:    usage,
+			mockImage:      &models.Image{ID: testUUID, TenantID: "shop", Filename: "test.jpg", Size: int64(len(content))},
+			expectedStatus: http.StatusOK,
+			expectedBody:   fmt.Sprintf(`{"status":"OK","image_id":"%s"}`, testUUID),
+			expectedHeaders: map[string]string{
+				quota.HeaderBytesLimit:       "1000",
+				quota.HeaderBytesRemaining:   "879",
+				quota.HeaderImagesLimit:      "10",
+				quota.HeaderImagesRemaining:  "7",
+				quota.HeaderUploadsLimit:     "5",
+				quota.HeaderUploadsRemaining: "3",
+			},
+		},
+		{
+			name:           "With Callback URL",
+			fileContent:    content,
+			callbackURL:    "https://hooks.example.com/images",
+			limits:         limits,
+			tenantUsage:    usage,
+			mockImage:      &models.Image{ID: testUUID, TenantID: "shop", Filename: "test.jpg", Size: int64(len(content))},
+			expectedStatus: http.StatusOK,
+			expectedBody:   fmt.Sprintf(`{"status":"OK","image_id":"%s"}`, testUUID),
+		},
+		{
+			name:           "Callback URL Before Image",
+			fileContent:    content,
+			callbackURL:    "https://hooks.example.com/images",
+			callbackFirst:  true,
+			limits:         limits,
+			tenantUsage:    usage,
+			mockImage:      &models.Image{ID: testUUID, TenantID: "shop", Filename: "test.jpg", Size: int64(len(content))},
 			expectedStatus: http.StatusOK,
 			expectedBody:   fmt.Sprintf(`{"status":"OK","image_id":"%s"}`, testUUID),
 		},
+		{
+			name:           "Invalid Callback URL",
+			fileContent:    content,
+			callbackURL:    "ftp://hooks.example.com/images",
+			callbackFirst:  true,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"Error","error":"invalid callback_url"}`,
+		},
+		{
+			name:           "Invalid Callback URL After Image",
+			fileContent:    content,
+			callbackURL:    "ftp://hooks.example.com/images",
+			limits:         limits,
+			tenantUsage:    usage,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"Error","error":"invalid callback_url"}`,
+		},
+		{
+			name:           "Missing Image",
+			noFile:         true,
+			callbackURL:    "https://hooks.example.com/images",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"Error","error":"failed to get file from request"}`,
+		},
+		{
+			name:           "Unsupported Type",
+			fileContent:    []byte("<html>not an image</html>"),
+			expectedStatus: http.StatusUnsupportedMediaType,
+			expectedBody:   `{"status":"Error","error":"unsupported image type"}`,
+		},
+		{
+			name:           "Body Too Large",
+			fileContent:    large,
+			maxBodySize:    1024,
+			limits:         limits,
+			tenantUsage:    usage,
+			expectedStatus: http.StatusRequestEntityTooLarge,
+			expectedBody:   `{"status":"Error","error":"request body too large"}`,
+		},
 		{
 			name:           "Empty File",
 			fileContent:    []byte(""),
-			fileName:       "empty.jpg",
-			mockImage:      nil,
-			mockSaveErr:    nil,
-			mockKafkaErr:   nil,
 			expectedStatus: http.StatusBadRequest,
 			expectedBody:   `{"status":"Error","error":"received empty file"}`,
 		},
+		{
+			name:           "Storage Quota Exceeded",
+			fileContent:    content,
+			limits:         limits,
+			tenantUsage:    models.Usage{Bytes: 990, Images: 2},
+			expectedStatus: http.StatusInsufficientStorage,
+			expectedBody:   `{"status":"Error","error":"storage quota exceeded"}`,
+			expectedHeaders: map[string]string{
+				quota.HeaderBytesRemaining: "10",
+			},
+		},
+		{
+			name:           "Image Count Exceeded",
+			fileContent:    content,
+			limits:         limits,
+			tenantUsage:    models.Usage{Bytes: 100, Images: 10},
+			expectedStatus: http.StatusInsufficientStorage,
+			expectedBody:   `{"status":"Error","error":"storage quota exceeded"}`,
+			expectedHeaders: map[string]string{
+				quota.HeaderImagesRemaining: "0",
+			},
+		},
+		{
+			name:           "Upload Rate Exceeded",
+			fileContent:    content,
+			limits:         limits,
+			tenantUsage:    models.Usage{Bytes: 100, Images: 2, WindowStart: recentWindow, WindowUploads: 5},
+			expectedStatus: http.StatusTooManyRequests,
+			expectedBody:   `{"status":"Error","error":"upload rate exceeded"}`,
+			expectedHeaders: map[string]string{
+				quota.HeaderUploadsRemaining: "0",
+				"Retry-After":                "3000",
+			},
+		},
+		{
+			name:           "Key Quota Exceeded",
+			fileContent:    content,
+			limits:         quota.Set{Key: quota.Limits{MaxImages: 1}},
+			keyUsage:       models.Usage{Images: 1},
+			expectedStatus: http.StatusInsufficientStorage,
+			expectedBody:   `{"status":"Error","error":"storage quota exceeded"}`,
+		},
+		{
+			name:           "Quota Exceeded Concurrently",
+			fileContent:    content,
+			limits:         limits,
+			tenantUsage:    usage,
+			mockSaveErr:    fmt.Errorf("storage.postgres.SaveImage: %w", quota.ErrStorageExceeded),
+			expectedStatus: http.StatusInsufficientStorage,
+			expectedBody:   `{"status":"Error","error":"storage quota exceeded"}`,
+		},
+		{
+			name:           "Failed to Store File",
+			fileContent:    content,
+			mockPutErr:     errors.New("disk full"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"status":"Error","error":"failed to save file"}`,
+		},
 		{
 			name:           "Failed to Save Metadata",
-			fileContent:    []byte("test file content"),
-			fileName:       "test.jpg",
-			mockImage:      nil,
+			fileContent:    content,
 			mockSaveErr:    errors.New("db error"),
-			mockKafkaErr:   nil,
 			expectedStatus: http.StatusInternalServerError,
 			expectedBody:   `{"status":"Error","error":"failed to save image metadata"}`,
 		},
+		{
+			name:        "Shares Processed Image",
+			fileContent: content,
+			limits:      limits,
+			tenantUsage: usage,
+			mockImage: &models.Image{
+				ID:           testUUID,
+				TenantID:     "shop",
+				Filename:     "test.jpg",
+				Status:       "processed",
+				OriginalPath: "shop/uploads/" + uuid.NewString() + ".jpg",
+				Size:         int64(len(content)),
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   fmt.Sprintf(`{"status":"OK","image_id":"%s"}`, testUUID),
+		},
+		{
+			name:           "Duplicate Returned",
+			fileContent:    content,
+			limits:         limits,
+			tenantUsage:    usage,
+			mockSaveErr:    fmt.Errorf("storage.postgres.SaveImage: %w", &storage.DuplicateError{Image: &models.Image{ID: existingID}}),
+			expectedStatus: http.StatusOK,
+			expectedBody:   fmt.Sprintf(`{"status":"OK","image_id":"%s","duplicate":true}`, existingID),
+			expectedHeaders: map[string]string{
+				quota.HeaderBytesRemaining: "900",
+			},
+		},
+		{
+			name:           "Duplicate Rejected",
+			fileContent:    content,
+			limits:         limits,
+			tenantUsage:    usage,
+			mockSaveErr:    fmt.Errorf("storage.postgres.SaveImage: %w", &storage.DuplicateError{Image: &models.Image{ID: existingID}, Rejected: true}),
+			expectedStatus: http.StatusConflict,
+			expectedBody:   fmt.Sprintf(`{"status":"Error","error":"duplicate image","image_id":"%s"}`, existingID),
+		},
 		{
 			name:           "Failed to Publish to Kafka",
-			fileContent:    []byte("test file content"),
-			fileName:       "test.jpg",
-			mockImage:      &models.Image{ID: testUUID, Filename: "test.jpg", OriginalPath: "uploads/test.jpg"},
-			mockSaveErr:    nil,
+			fileContent:    content,
+			mockImage:      &models.Image{ID: testUUID, TenantID: "shop", Filename: "test.jpg", Size: int64(len(content))},
 			mockKafkaErr:   errors.New("kafka error"),
 			expectedStatus: http.StatusInternalServerError,
 			expectedBody:   `{"status":"Error","error":"failed to start image processing"}`,
@@ -85,47 +262,125 @@ func TestSaveImage(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			imageSaverMock := saverMocks.NewImageSaver(t)
+			blobStorageMock := saverMocks.NewBlobStorage(t)
+			quotaResolverMock := saverMocks.NewQuotaResolver(t)
 			kafkaProducerMock := kafkaMocks.NewProducerIface(t)
 
-			if tt.name == "Success" || tt.name == "Failed to Publish to Kafka" || tt.name == "Failed to Save Metadata" {
-				imageSaverMock.On("SaveImage", mock.Anything, mock.Anything, mock.Anything).Return(tt.mockImage, tt.mockSaveErr).Once()
+			// Uploads land in the tenant's namespace under a generated name.
+			isTenantKey := mock.MatchedBy(func(key string) bool {
+				return strings.HasPrefix(key, "shop/uploads/") && strings.HasSuffix(key, ".jpg") && !strings.Contains(key, "test")
+			})
+
+			// Counts and rates are checked before the upload is read, bytes
+			// as it is streamed.
+			rejectedEarly := tt.expectedStatus == http.StatusTooManyRequests ||
+				tt.expectedStatus == http.StatusInsufficientStorage && tt.mockSaveErr == nil && tt.name != "Storage Quota Exceeded"
+
+			rejectedRequest := tt.name == "Empty File" || tt.name == "Invalid Callback URL" ||
+				tt.name == "Missing Image" || tt.name == "Unsupported Type"
+
+			if !rejectedRequest {
+				quotaResolverMock.On("For", "shop", &testKey.ID).Return(tt.limits).Once()
+				imageSaverMock.On("GetUsage", mock.Anything, &testKey.ID).Return(tt.tenantUsage, &tt.keyUsage, nil).Once()
+			}
+			if !rejectedRequest && !rejectedEarly {
+				if tt.mockPutErr != nil {
+					blobStorageMock.On("Put", mock.Anything, isTenantKey, mock.Anything).Return(nil, tt.mockPutErr).Once()
+				} else {
+					blobStorageMock.On("Put", mock.Anything, isTenantKey, mock.Anything).
+						Return(func(_ context.Context, key string, r io.Reader) (*storage.BlobInfo, error) {
+							data, err := io.ReadAll(r)
+							if err != nil {
+								return nil, err
+							}
+							require.Equal(t, tt.fileContent, data)
+							return &storage.BlobInfo{Key: key, Size: int64(len(data))}, nil
+						}).Once()
+				}
+			}
+			if tt.mockImage != nil || tt.mockSaveErr != nil {
+				isUpload := mock.MatchedBy(func(upload models.Upload) bool {
+					return upload.Filename == "test.jpg" &&
+						strings.HasPrefix(upload.OriginalPath, "shop/uploads/") &&
+						upload.Size == int64(len(content)) &&
+						*upload.OwnerKeyID == testKey.ID &&
+						upload.CallbackURL == tt.callbackURL &&
+						upload.BatchID == nil &&
+						upload.SHA256 == checksum
+				})
+				imageSaverMock.On("SaveImage", mock.Anything, isUpload, tt.limits).
+					Return(func(_ context.Context, upload models.Upload, _ quota.Set) (*models.Image, error) {
+						if tt.mockImage == nil {
+							return nil, tt.mockSaveErr
+						}
+						// The image is stored under the upload's key unless it
+						// shares the original of another.
+						image := *tt.mockImage
+						if image.OriginalPath == "" {
+							image.OriginalPath = upload.OriginalPath
+						}
+						return &image, nil
+					}).Once()
+			}
+
+			if tt.mockSaveErr != nil || tt.name == "Invalid Callback URL After Image" || tt.name == "Shares Processed Image" {
+				blobStorageMock.On("Delete", mock.Anything, isTenantKey).Return(nil).Once()
 			}
-			if tt.mockSaveErr == nil && tt.name != "Empty File" {
-				kafkaProducerMock.On("SendMessage", mock.Anything, mock.Anything).Return(tt.mockKafkaErr).Once()
+			if tt.mockImage != nil && tt.mockImage.Status != "processed" {
+				kafkaProducerMock.On("SendMessage", mock.Anything, mock.MatchedBy(func(message []byte) bool {
+					var job models.ProcessingJob
+					return json.Unmarshal(message, &job) == nil && job.ImageID == testUUID && job.TenantID == "shop"
+				})).Return(tt.mockKafkaErr).Once()
 			}
 
 			body := new(bytes.Buffer)
 			writer := multipart.NewWriter(body)
-			part, err := writer.CreateFormFile("image", tt.fileName)
-			require.NoError(t, err)
-			part.Write(tt.fileContent)
+			if tt.callbackURL != "" && tt.callbackFirst {
+				require.NoError(t, writer.WriteField("callback_url", tt.callbackURL))
+			}
+			if !tt.noFile {
+				part, err := writer.CreateFormFile("image", "test.jpg")
+				require.NoError(t, err)
+				part.Write(tt.fileContent)
+			}
+			if tt.callbackURL != "" && !tt.callbackFirst {
+				require.NoError(t, writer.WriteField("callback_url", tt.callbackURL))
+			}
 			writer.Close()
 
+			maxBodySize := tt.maxBodySize
+			if maxBodySize == 0 {
+				maxBodySize = 1 << 20
+			}
+
 			req := httptest.NewRequest(http.MethodPost, "/upload", body)
 			req.Header.Set("Content-Type", writer.FormDataContentType())
+			req = req.WithContext(tenant.WithID(apikey.WithKey(req.Context(), testKey), testKey.TenantID))
 
 			rr := httptest.NewRecorder()
 
-			originalCreate := osCreate
-			osCreate = func(path string) (*os.File, error) {
-				return originalCreate(filepath.Join(uploadsDir, filepath.Base(path)))
-			}
-			defer func() { osCreate = originalCreate }()
-
-			handler := saveImage.New(log, imageSaverMock, kafkaProducerMock)
+			handler := saveImage.New(log, imageSaverMock, blobStorageMock, quotaResolverMock, kafkaProducerMock, maxBodySize)
 			handler.ServeHTTP(rr, req)
 
 			require.Equal(t, tt.expectedStatus, rr.Code)
 
 			actualBody := rr.Body.String()
 			var actualMap, expectedMap map[string]interface{}
-			err = json.Unmarshal([]byte(actualBody), &actualMap)
+			err := json.Unmarshal([]byte(actualBody), &actualMap)
 			require.NoError(t, err)
 			err = json.Unmarshal([]byte(tt.expectedBody), &expectedMap)
 			require.NoError(t, err)
 			require.Equal(t, expectedMap, actualMap)
+
+			for k, v := range tt.expectedHeaders {
+				if k == "Retry-After" {
+					// The window has 50 minutes left, give or take the test run.
+					retryAfter := rr.Header().Get(k)
+					require.True(t, retryAfter == v || retryAfter == "2999", "unexpected Retry-After %q", retryAfter)
+					continue
+				}
+				require.Equal(t, v, rr.Header().Get(k), k)
+			}
 		})
 	}
 }
-
-var osCreate = os.Create
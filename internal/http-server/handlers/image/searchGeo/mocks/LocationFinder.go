@@ -0,0 +1,59 @@
+// Code generated by mockery v2.51.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	models "imageProcessor/internal/models"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// LocationFinder is an autogenerated mock type for the LocationFinder type
+type LocationFinder struct {
+	mock.Mock
+}
+
+// FindImagesByLocation provides a mock function with given fields: ctx, filter
+func (_m *LocationFinder) FindImagesByLocation(ctx context.Context, filter models.LocationFilter) ([]models.LocatedImage, error) {
+	ret := _m.Called(ctx, filter)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindImagesByLocation")
+	}
+
+	var r0 []models.LocatedImage
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.LocationFilter) ([]models.LocatedImage, error)); ok {
+		return rf(ctx, filter)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, models.LocationFilter) []models.LocatedImage); ok {
+		r0 = rf(ctx, filter)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.LocatedImage)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, models.LocationFilter) error); ok {
+		r1 = rf(ctx, filter)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewLocationFinder creates a new instance of LocationFinder. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewLocationFinder(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *LocationFinder {
+	mock := &LocationFinder{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,178 @@
+package searchGeo
+
+import (
+	"context"
+	"github.com/go-chi/render"
+	"imageProcessor/internal/geo"
+	"imageProcessor/internal/lib/api/response"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/lib/logger/sl"
+	"imageProcessor/internal/models"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxRadiusKm is half the circumference of the Earth: a circle that
+	// large covers all of it.
+	maxRadiusKm  = 20016
+	defaultLimit = 20
+	maxLimit     = 100
+)
+
+type Response struct {
+	response.Response
+	Images []models.LocatedImage `json:"images"`
+	// NextOffset is the offset of the next page, left out on the last one.
+	NextOffset *int `json:"next_offset,omitempty"`
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=LocationFinder
+type LocationFinder interface {
+	FindImagesByLocation(ctx context.Context, filter models.LocationFilter) ([]models.LocatedImage, error)
+}
+
+// SearchGeo lists the images taken within a bounding box or a radius.
+// @Summary      Search images by location
+// @Description  Lists the images whose EXIF GPS position lies within bbox, or at most radius_km from lat and lon, closest to the center of the search first. Either bbox or all of lat, lon and radius_km have to be given. A bbox whose min_lon is greater than its max_lon crosses the antimeridian. Other keys' images are only listed for admin keys.
+// @Tags         images
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        bbox       query     string  false  "min_lon,min_lat,max_lon,max_lat in degrees"
+// @Param        lat        query     number  false  "Latitude of the center in degrees"
+// @Param        lon        query     number  false  "Longitude of the center in degrees"
+// @Param        radius_km  query     number  false  "Radius in kilometres (up to 20016)"
+// @Param        limit      query     int     false  "Number of images (1-100, default 20)"
+// @Param        offset     query     int     false  "Number of images to skip"
+// @Success      200  {object}  searchGeo.Response
+// @Failure      400  {object}  response.Response
+// @Failure      401  {object}  response.Response
+// @Failure      403  {object}  response.Response
+// @Failure      500  {object}  response.Response
+// @Router       /images/search/geo [get]
+func New(log *slog.Logger, locationFinder LocationFinder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.image.searchGeo.New"
+
+		log := log.With(slog.String("op", op))
+
+		key, ok := apikey.FromContext(r.Context())
+		if !ok {
+			render.Status(r, http.StatusUnauthorized)
+			render.JSON(w, r, response.Error("missing api key"))
+			return
+		}
+
+		query := r.URL.Query()
+
+		filter := models.LocationFilter{Limit: defaultLimit}
+		if msg := parseArea(query, &filter); msg != "" {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error(msg))
+			return
+		}
+		if s := query.Get("limit"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 1 || n > maxLimit {
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, response.Error("invalid limit"))
+				return
+			}
+			filter.Limit = n
+		}
+		if s := query.Get("offset"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 0 {
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, response.Error("invalid offset"))
+				return
+			}
+			filter.Offset = n
+		}
+
+		if !slices.Contains(key.Scopes, apikey.ScopeAdmin) {
+			filter.OwnerKeyID = &key.ID
+		}
+
+		// One image more than asked for tells whether there is another page.
+		limit := filter.Limit
+		filter.Limit++
+
+		images, err := locationFinder.FindImagesByLocation(r.Context(), filter)
+		if err != nil {
+			log.Error("failed to find images by location", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to search images"))
+			return
+		}
+
+		resp := Response{Response: response.OK(), Images: images}
+		if len(images) > limit {
+			resp.Images = images[:limit]
+			next := filter.Offset + limit
+			resp.NextOffset = &next
+		}
+
+		log.Info("images found by location", slog.Int("count", len(resp.Images)))
+
+		render.JSON(w, r, resp)
+	}
+}
+
+// parseArea reads the area searched into filter. It returns what is wrong
+// with the query, if anything.
+func parseArea(query url.Values, filter *models.LocationFilter) string {
+	bbox := query.Get("bbox")
+	circle := query.Has("lat") || query.Has("lon") || query.Has("radius_km")
+
+	switch {
+	case bbox != "" && circle:
+		return "bbox cannot be combined with lat, lon and radius_km"
+	case bbox != "":
+		parts := strings.Split(bbox, ",")
+		if len(parts) != 4 {
+			return "invalid bbox"
+		}
+		values := make([]float64, 4)
+		for i, part := range parts {
+			v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+			if err != nil {
+				return "invalid bbox"
+			}
+			values[i] = v
+		}
+
+		box := geo.Box{MinLon: values[0], MinLat: values[1], MaxLon: values[2], MaxLat: values[3]}
+		if !box.Valid() {
+			return "invalid bbox"
+		}
+
+		filter.Box = box
+		filter.Center = box.Center()
+	case circle:
+		lat, err := strconv.ParseFloat(query.Get("lat"), 64)
+		if err != nil || !(lat >= -90 && lat <= 90) {
+			return "invalid lat"
+		}
+		lon, err := strconv.ParseFloat(query.Get("lon"), 64)
+		if err != nil || !(lon >= -180 && lon <= 180) {
+			return "invalid lon"
+		}
+		radius, err := strconv.ParseFloat(query.Get("radius_km"), 64)
+		if err != nil || !(radius > 0 && radius <= maxRadiusKm) {
+			return "invalid radius_km"
+		}
+
+		filter.Center = geo.Point{Lat: lat, Lon: lon}
+		filter.Box = geo.Around(filter.Center, radius)
+		filter.RadiusKm = radius
+	default:
+		return "either bbox or lat, lon and radius_km are required"
+	}
+
+	return ""
+}
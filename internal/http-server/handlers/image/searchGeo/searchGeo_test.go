@@ -0,0 +1,200 @@
+package searchGeo_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"imageProcessor/internal/geo"
+	"imageProcessor/internal/http-server/handlers/image/searchGeo"
+	"imageProcessor/internal/http-server/handlers/image/searchGeo/mocks"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/models"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSearchGeo(t *testing.T) {
+	log := slog.New(slog.NewJSONHandler(bytes.NewBuffer(nil), nil))
+
+	imageID := uuid.New()
+	nextID := uuid.New()
+	createdAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	ownerKey := &models.APIKey{ID: uuid.New(), Scopes: []string{apikey.ScopeRead}}
+	adminKey := &models.APIKey{ID: uuid.New(), Scopes: []string{apikey.ScopeAdmin}}
+
+	located := models.LocatedImage{
+		Image:      models.Image{ID: imageID, TenantID: "shop", Status: "processed", OwnerKeyID: &ownerKey.ID, CreatedAt: createdAt, UpdatedAt: createdAt},
+		Latitude:   48.8584,
+		Longitude:  2.2945,
+		DistanceKm: 4.2,
+	}
+	next := located
+	next.Image.ID = nextID
+	next.DistanceKm = 5
+
+	locatedBody := fmt.Sprintf(`{"image":{"ID":"%s","TenantID":"shop","Filename":"","Status":"processed","OriginalPath":"","Size":0,"ProcessedPathResize":null,"ProcessedPathThumbnail":null,"ProcessedPathWatermark":null,"OwnerKeyID":"%s","CallbackURL":null,"BatchID":null,"SHA256":null,"UploadExpiresAt":null,"CreatedAt":"2030-01-01T00:00:00Z","UpdatedAt":"2030-01-01T00:00:00Z"},"latitude":48.8584,"longitude":2.2945,"distance_km":4.2}`, imageID, ownerKey.ID)
+
+	paris := geo.Point{Lat: 48.8566, Lon: 2.3522}
+	parisBox := geo.Box{MinLat: 48.8, MinLon: 2.2, MaxLat: 48.9, MaxLon: 2.5}
+	fijiBox := geo.Box{MinLat: -20, MinLon: 170, MaxLat: -10, MaxLon: -170}
+
+	tests := []struct {
+		name           string
+		query          string
+		key            *models.APIKey
+		expectedFilter *models.LocationFilter
+		mockImages     []models.LocatedImage
+		mockErr        error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Radius",
+			query:          "?lat=48.8566&lon=2.3522&radius_km=10",
+			key:            ownerKey,
+			expectedFilter: &models.LocationFilter{Box: geo.Around(paris, 10), Center: paris, RadiusKm: 10, OwnerKeyID: &ownerKey.ID, Limit: 21},
+			mockImages:     []models.LocatedImage{located},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"status":"OK","images":[` + locatedBody + `]}`,
+		},
+		{
+			name:           "Bounding Box",
+			query:          "?bbox=2.2,48.8,2.5,48.9",
+			key:            ownerKey,
+			expectedFilter: &models.LocationFilter{Box: parisBox, Center: parisBox.Center(), OwnerKeyID: &ownerKey.ID, Limit: 21},
+			mockImages:     []models.LocatedImage{},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"status":"OK","images":[]}`,
+		},
+		{
+			name:           "Across The Antimeridian",
+			query:          "?bbox=170,-20,-170,-10",
+			key:            adminKey,
+			expectedFilter: &models.LocationFilter{Box: fijiBox, Center: geo.Point{Lat: -15, Lon: 180}, Limit: 21},
+			mockImages:     []models.LocatedImage{},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"status":"OK","images":[]}`,
+		},
+		{
+			name:           "Next Page",
+			query:          "?lat=48.8566&lon=2.3522&radius_km=10&limit=1&offset=3",
+			key:            ownerKey,
+			expectedFilter: &models.LocationFilter{Box: geo.Around(paris, 10), Center: paris, RadiusKm: 10, OwnerKeyID: &ownerKey.ID, Limit: 2, Offset: 3},
+			mockImages:     []models.LocatedImage{located, next},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"status":"OK","images":[` + locatedBody + `],"next_offset":4}`,
+		},
+		{
+			name:           "No Area",
+			query:          "?limit=5",
+			key:            ownerKey,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"Error","error":"either bbox or lat, lon and radius_km are required"}`,
+		},
+		{
+			name:           "Both Areas",
+			query:          "?bbox=2.2,48.8,2.5,48.9&lat=48.8566",
+			key:            ownerKey,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"Error","error":"bbox cannot be combined with lat, lon and radius_km"}`,
+		},
+		{
+			name:           "Short Bounding Box",
+			query:          "?bbox=2.2,48.8,2.5",
+			key:            ownerKey,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"Error","error":"invalid bbox"}`,
+		},
+		{
+			name:           "Upside Down Bounding Box",
+			query:          "?bbox=2.2,48.9,2.5,48.8",
+			key:            ownerKey,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"Error","error":"invalid bbox"}`,
+		},
+		{
+			name:           "Latitude Out Of Range",
+			query:          "?lat=91&lon=2.3522&radius_km=10",
+			key:            ownerKey,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"Error","error":"invalid lat"}`,
+		},
+		{
+			name:           "Longitude Not A Number",
+			query:          "?lat=48.8566&lon=NaN&radius_km=10",
+			key:            ownerKey,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"Error","error":"invalid lon"}`,
+		},
+		{
+			name:           "Missing Radius",
+			query:          "?lat=48.8566&lon=2.3522",
+			key:            ownerKey,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"Error","error":"invalid radius_km"}`,
+		},
+		{
+			name:           "Zero Radius",
+			query:          "?lat=48.8566&lon=2.3522&radius_km=0",
+			key:            ownerKey,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"Error","error":"invalid radius_km"}`,
+		},
+		{
+			name:           "Invalid Limit",
+			query:          "?lat=48.8566&lon=2.3522&radius_km=10&limit=101",
+			key:            ownerKey,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"Error","error":"invalid limit"}`,
+		},
+		{
+			name:           "Negative Offset",
+			query:          "?lat=48.8566&lon=2.3522&radius_km=10&offset=-1",
+			key:            ownerKey,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"Error","error":"invalid offset"}`,
+		},
+		{
+			name:           "Internal Error",
+			query:          "?lat=48.8566&lon=2.3522&radius_km=10",
+			key:            ownerKey,
+			expectedFilter: &models.LocationFilter{Box: geo.Around(paris, 10), Center: paris, RadiusKm: 10, OwnerKeyID: &ownerKey.ID, Limit: 21},
+			mockErr:        errors.New("db error"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"status":"Error","error":"failed to search images"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			locationFinderMock := mocks.NewLocationFinder(t)
+
+			if tt.expectedFilter != nil {
+				locationFinderMock.On("FindImagesByLocation", mock.Anything, *tt.expectedFilter).Return(tt.mockImages, tt.mockErr).Once()
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/images/search/geo"+tt.query, nil)
+			req = req.WithContext(apikey.WithKey(req.Context(), tt.key))
+
+			rr := httptest.NewRecorder()
+
+			handler := searchGeo.New(log, locationFinderMock)
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+
+			var actualMap, expectedMap map[string]interface{}
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &actualMap))
+			require.NoError(t, json.Unmarshal([]byte(tt.expectedBody), &expectedMap))
+			require.Equal(t, expectedMap, actualMap)
+		})
+	}
+}
@@ -0,0 +1,58 @@
+// Code generated by mockery v2.51.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	mock "github.com/stretchr/testify/mock"
+
+	transformer "imageProcessor/internal/transformer"
+
+	url "net/url"
+)
+
+// ParamsParser is an autogenerated mock type for the ParamsParser type
+type ParamsParser struct {
+	mock.Mock
+}
+
+// Parse provides a mock function with given fields: query
+func (_m *ParamsParser) Parse(query url.Values) (transformer.Params, error) {
+	ret := _m.Called(query)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Parse")
+	}
+
+	var r0 transformer.Params
+	var r1 error
+	if rf, ok := ret.Get(0).(func(url.Values) (transformer.Params, error)); ok {
+		return rf(query)
+	}
+	if rf, ok := ret.Get(0).(func(url.Values) transformer.Params); ok {
+		r0 = rf(query)
+	} else {
+		r0 = ret.Get(0).(transformer.Params)
+	}
+
+	if rf, ok := ret.Get(1).(func(url.Values) error); ok {
+		r1 = rf(query)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewParamsParser creates a new instance of ParamsParser. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewParamsParser(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *ParamsParser {
+	mock := &ParamsParser{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
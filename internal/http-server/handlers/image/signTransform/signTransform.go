@@ -0,0 +1,135 @@
+package signTransform
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"imageProcessor/internal/lib/api/response"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/lib/logger/sl"
+	"imageProcessor/internal/lib/tenant"
+	"imageProcessor/internal/models"
+	"imageProcessor/internal/transformer"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// transformParams are the query parameters understood by the transform
+// endpoint. Anything else is left out of the signed URL.
+var transformParams = []string{"w", "h", "fit", "gravity", "format", "q"}
+
+type Response struct {
+	response.Response
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=ImageGetter
+type ImageGetter interface {
+	GetImage(ctx context.Context, id uuid.UUID) (*models.Image, error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=ParamsParser
+type ParamsParser interface {
+	Parse(query url.Values) (transformer.Params, error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=URLSigner
+type URLSigner interface {
+	Sign(path string, params url.Values) string
+	ExpiresAt() time.Time
+}
+
+// SignTransform returns a signed URL for an on-the-fly transform.
+// @Summary      Get a signed transform URL
+// @Description  Validates transform parameters and returns a signed, expiring URL for GET /image/{id}/transform.
+// @Tags         images
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id       path      string  true   "Image ID"
+// @Param        w        query     int     false  "Target width"
+// @Param        h        query     int     false  "Target height"
+// @Param        fit      query     string  false  "How the image fits the box"  Enums(cover, contain, fill, inside)
+// @Param        gravity  query     string  false  "Anchor for cover and contain"
+// @Param        format   query     string  false  "Output format"  Enums(jpeg, png, gif, tiff, bmp)
+// @Param        q        query     int     false  "JPEG quality (1-100)"
+// @Success      200  {object}  signTransform.Response
+// @Failure      400  {object}  response.Response
+// @Failure      401  {object}  response.Response
+// @Failure      403  {object}  response.Response
+// @Failure      404  {object}  response.Response
+// @Failure      500  {object}  response.Response
+// @Router       /image/{id}/transform/url [get]
+func New(log *slog.Logger, imageGetter ImageGetter, paramsParser ParamsParser, urlSigner URLSigner) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.image.signTransform.New"
+
+		log := log.With(slog.String("op", op))
+
+		idStr := chi.URLParam(r, "id")
+		imageID, err := uuid.Parse(idStr)
+		if err != nil {
+			log.Error("failed to parse image ID", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error("invalid image ID"))
+			return
+		}
+
+		query := r.URL.Query()
+
+		// Refuse to sign what the transform endpoint would reject anyway.
+		if _, err = paramsParser.Parse(query); err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, transformer.ErrSizeNotAllowed) {
+				status = http.StatusForbidden
+			}
+
+			log.Warn("invalid transform parameters", sl.Err(err))
+			render.Status(r, status)
+			render.JSON(w, r, response.Error(err.Error()))
+			return
+		}
+
+		image, err := imageGetter.GetImage(r.Context(), imageID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				log.Warn("image not found", slog.String("image_id", imageID.String()))
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, response.Error("image not found"))
+				return
+			}
+
+			log.Error("failed to get image from storage", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to get image"))
+			return
+		}
+
+		if !apikey.CanAccess(r.Context(), image.OwnerKeyID) {
+			log.Warn("image belongs to another api key", slog.String("image_id", imageID.String()))
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, response.Error("image not found"))
+			return
+		}
+
+		params := url.Values{}
+		for _, key := range transformParams {
+			if value := query.Get(key); value != "" {
+				params.Set(key, value)
+			}
+		}
+		params.Set(tenant.QueryParam, image.TenantID)
+
+		render.JSON(w, r, Response{
+			Response:  response.OK(),
+			URL:       urlSigner.Sign(fmt.Sprintf("/image/%s/transform", imageID), params),
+			ExpiresAt: urlSigner.ExpiresAt(),
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package signTransform_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"imageProcessor/internal/http-server/handlers/image/signTransform"
+	"imageProcessor/internal/http-server/handlers/image/signTransform/mocks"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/models"
+	"imageProcessor/internal/transformer"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestSignTransform(t *testing.T) {
+	log := slog.New(slog.NewJSONHandler(bytes.NewBuffer(nil), nil))
+
+	testUUID, _ := uuid.NewRandom()
+	expiresAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	testKey := &models.APIKey{ID: uuid.New(), Scopes: []string{apikey.ScopeRead}}
+
+	tests := []struct {
+		name           string
+		imageID        string
+		mockParseErr   error
+		mockImageErr   error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Success",
+			imageID:        testUUID.String(),
+			expectedStatus: http.StatusOK,
+			expectedBody:   fmt.Sprintf(`{"status":"OK","url":"signed:/image/%s/transform?fit=cover&tenant=shop&w=320","expires_at":"2030-01-01T00:00:00Z"}`, testUUID),
+		},
+		{
+			name:           "Invalid UUID",
+			imageID:        "invalid-uuid",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"Error","error":"invalid image ID"}`,
+		},
+		{
+			name:           "Size Not Allowed",
+			imageID:        testUUID.String(),
+			mockParseErr:   fmt.Errorf("%w: 320x0", transformer.ErrSizeNotAllowed),
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"status":"Error","error":"size is not allowed: 320x0"}`,
+		},
+		{
+			name:           "Not Found",
+			imageID:        testUUID.String(),
+			mockImageErr:   sql.ErrNoRows,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"status":"Error","error":"image not found"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			imageGetterMock := mocks.NewImageGetter(t)
+			paramsParserMock := mocks.NewParamsParser(t)
+			urlSignerMock := mocks.NewURLSigner(t)
+
+			if tt.name != "Invalid UUID" {
+				paramsParserMock.On("Parse", mock.Anything).Return(transformer.Params{}, tt.mockParseErr).Once()
+			}
+			if tt.name != "Invalid UUID" && tt.mockParseErr == nil {
+				imageGetterMock.On("GetImage", mock.Anything, testUUID).Return(&models.Image{ID: testUUID, TenantID: "shop", OwnerKeyID: &testKey.ID}, tt.mockImageErr).Once()
+			}
+			if tt.name == "Success" {
+				urlSignerMock.On("Sign", fmt.Sprintf("/image/%s/transform", testUUID), url.Values{"w": {"320"}, "fit": {"cover"}, "tenant": {"shop"}}).
+					Return(func(path string, params url.Values) string {
+						return "signed:" + path + "?" + params.Encode()
+					}).Once()
+				urlSignerMock.On("ExpiresAt").Return(expiresAt).Once()
+			}
+
+			req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/image/%s/transform/url?w=320&fit=cover&debug=1", tt.imageID), nil)
+
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", tt.imageID)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			req = req.WithContext(apikey.WithKey(req.Context(), testKey))
+
+			rr := httptest.NewRecorder()
+
+			handler := signTransform.New(log, imageGetterMock, paramsParserMock, urlSignerMock)
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+
+			var actualMap, expectedMap map[string]interface{}
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &actualMap))
+			require.NoError(t, json.Unmarshal([]byte(tt.expectedBody), &expectedMap))
+			require.Equal(t, expectedMap, actualMap)
+		})
+	}
+}
@@ -0,0 +1,104 @@
+// Code generated by mockery v2.51.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	io "io"
+
+	mock "github.com/stretchr/testify/mock"
+
+	models "imageProcessor/internal/models"
+
+	storage "imageProcessor/internal/storage"
+
+	transformer "imageProcessor/internal/transformer"
+
+	url "net/url"
+)
+
+// Transformer is an autogenerated mock type for the Transformer type
+type Transformer struct {
+	mock.Mock
+}
+
+// Parse provides a mock function with given fields: query
+func (_m *Transformer) Parse(query url.Values) (transformer.Params, error) {
+	ret := _m.Called(query)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Parse")
+	}
+
+	var r0 transformer.Params
+	var r1 error
+	if rf, ok := ret.Get(0).(func(url.Values) (transformer.Params, error)); ok {
+		return rf(query)
+	}
+	if rf, ok := ret.Get(0).(func(url.Values) transformer.Params); ok {
+		r0 = rf(query)
+	} else {
+		r0 = ret.Get(0).(transformer.Params)
+	}
+
+	if rf, ok := ret.Get(1).(func(url.Values) error); ok {
+		r1 = rf(query)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Transform provides a mock function with given fields: ctx, image, params
+func (_m *Transformer) Transform(ctx context.Context, image *models.Image, params transformer.Params) (io.ReadSeekCloser, *storage.BlobInfo, error) {
+	ret := _m.Called(ctx, image, params)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Transform")
+	}
+
+	var r0 io.ReadSeekCloser
+	var r1 *storage.BlobInfo
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.Image, transformer.Params) (io.ReadSeekCloser, *storage.BlobInfo, error)); ok {
+		return rf(ctx, image, params)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *models.Image, transformer.Params) io.ReadSeekCloser); ok {
+		r0 = rf(ctx, image, params)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(io.ReadSeekCloser)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *models.Image, transformer.Params) *storage.BlobInfo); ok {
+		r1 = rf(ctx, image, params)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(*storage.BlobInfo)
+		}
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, *models.Image, transformer.Params) error); ok {
+		r2 = rf(ctx, image, params)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// NewTransformer creates a new instance of Transformer. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewTransformer(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *Transformer {
+	mock := &Transformer{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
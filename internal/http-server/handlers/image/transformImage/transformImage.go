@@ -0,0 +1,122 @@
+package transformImage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"imageProcessor/internal/lib/api/response"
+	"imageProcessor/internal/lib/logger/sl"
+	"imageProcessor/internal/models"
+	"imageProcessor/internal/storage"
+	"imageProcessor/internal/transformer"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+)
+
+// A rendition is fully determined by the original and the parameters, both
+// of which are part of the cache key, so it never changes.
+const cacheControl = "public, max-age=31536000, immutable"
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=ImageGetter
+type ImageGetter interface {
+	GetImage(ctx context.Context, id uuid.UUID) (*models.Image, error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=Transformer
+type Transformer interface {
+	Parse(query url.Values) (transformer.Params, error)
+	Transform(ctx context.Context, image *models.Image, params transformer.Params) (io.ReadSeekCloser, *storage.BlobInfo, error)
+}
+
+// TransformImage renders the original image with the requested parameters.
+// @Summary      Transform an image on the fly
+// @Description  Resizes and crops the original image. Results are cached, so repeated requests with the same parameters are cheap. Only configured sizes are allowed.
+// @Tags         images
+// @Produce      image/jpeg,image/png,image/gif,image/tiff,image/bmp
+// @Param        id       path      string  true   "Image ID"
+// @Param        w        query     int     false  "Target width"
+// @Param        h        query     int     false  "Target height"
+// @Param        fit      query     string  false  "How the image fits the box"  Enums(cover, contain, fill, inside)
+// @Param        gravity  query     string  false  "Anchor for cover and contain"  Enums(center, north, south, east, west, northeast, northwest, southeast, southwest)
+// @Param        format   query     string  false  "Output format"  Enums(jpeg, png, gif, tiff, bmp)
+// @Param        q        query     int     false  "JPEG quality (1-100)"
+// @Success      200  {file}    file
+// @Success      304  "Not Modified"
+// @Failure      400  {object}  response.Response
+// @Failure      403  {object}  response.Response
+// @Failure      404  {object}  response.Response
+// @Failure      500  {object}  response.Response
+// @Router       /image/{id}/transform [get]
+func New(log *slog.Logger, imageGetter ImageGetter, imageTransformer Transformer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.image.transformImage.New"
+
+		log := log.With(slog.String("op", op))
+
+		idStr := chi.URLParam(r, "id")
+		imageID, err := uuid.Parse(idStr)
+		if err != nil {
+			log.Error("failed to parse image ID", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error("invalid image ID"))
+			return
+		}
+
+		params, err := imageTransformer.Parse(r.URL.Query())
+		if err != nil {
+			if errors.Is(err, transformer.ErrSizeNotAllowed) {
+				log.Warn("transform size not allowed", sl.Err(err))
+				render.Status(r, http.StatusForbidden)
+				render.JSON(w, r, response.Error(err.Error()))
+				return
+			}
+
+			log.Warn("invalid transform parameters", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error(err.Error()))
+			return
+		}
+
+		image, err := imageGetter.GetImage(r.Context(), imageID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				log.Warn("image not found", slog.String("image_id", imageID.String()))
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, response.Error("image not found"))
+				return
+			}
+
+			log.Error("failed to get image from storage", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to get image"))
+			return
+		}
+
+		content, info, err := imageTransformer.Transform(r.Context(), image, params)
+		if err != nil {
+			if errors.Is(err, storage.ErrBlobNotFound) {
+				log.Warn("original image is missing", slog.String("image_id", imageID.String()))
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, response.Error("image not found"))
+				return
+			}
+
+			log.Error("failed to transform image", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to transform image"))
+			return
+		}
+		defer content.Close()
+
+		w.Header().Set("Content-Type", params.Format.ContentType)
+		w.Header().Set("ETag", `"`+params.Key(imageID)+`"`)
+		w.Header().Set("Cache-Control", cacheControl)
+
+		http.ServeContent(w, r, "", info.ModTime, content)
+	}
+}
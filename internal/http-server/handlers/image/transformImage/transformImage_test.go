@@ -0,0 +1,135 @@
+package transformImage_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"imageProcessor/internal/http-server/handlers/image/transformImage"
+	"imageProcessor/internal/http-server/handlers/image/transformImage/mocks"
+	"imageProcessor/internal/lib/imageformat"
+	"imageProcessor/internal/models"
+	"imageProcessor/internal/storage"
+	"imageProcessor/internal/transformer"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type nopSeekCloser struct {
+	io.ReadSeeker
+}
+
+func (nopSeekCloser) Close() error { return nil }
+
+func TestTransformImage(t *testing.T) {
+	log := slog.New(slog.NewJSONHandler(bytes.NewBuffer(nil), nil))
+
+	testUUID, _ := uuid.NewRandom()
+	testImage := &models.Image{ID: testUUID, Filename: "test.jpg", OriginalPath: "uploads/test.jpg"}
+	testParams := transformer.Params{Width: 320, Height: 240, Fit: transformer.FitCover, Gravity: "center", Format: imageformat.PNG}
+	content := []byte("rendered")
+
+	tests := []struct {
+		name           string
+		imageID        string
+		mockParseErr   error
+		mockImageErr   error
+		mockRenderErr  error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Success",
+			imageID:        testUUID.String(),
+			expectedStatus: http.StatusOK,
+			expectedBody:   string(content),
+		},
+		{
+			name:           "Invalid UUID",
+			imageID:        "invalid-uuid",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"Error","error":"invalid image ID"}` + "\n",
+		},
+		{
+			name:           "Invalid Params",
+			imageID:        testUUID.String(),
+			mockParseErr:   fmt.Errorf("%w: unknown fit \"zoom\"", transformer.ErrInvalidParams),
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"Error","error":"invalid transform parameters: unknown fit \"zoom\""}` + "\n",
+		},
+		{
+			name:           "Size Not Allowed",
+			imageID:        testUUID.String(),
+			mockParseErr:   fmt.Errorf("%w: 321x240", transformer.ErrSizeNotAllowed),
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"status":"Error","error":"size is not allowed: 321x240"}` + "\n",
+		},
+		{
+			name:           "Not Found",
+			imageID:        testUUID.String(),
+			mockImageErr:   sql.ErrNoRows,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"status":"Error","error":"image not found"}` + "\n",
+		},
+		{
+			name:           "Render Error",
+			imageID:        testUUID.String(),
+			mockRenderErr:  errors.New("decode error"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"status":"Error","error":"failed to transform image"}` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			imageGetterMock := mocks.NewImageGetter(t)
+			transformerMock := mocks.NewTransformer(t)
+
+			if tt.name != "Invalid UUID" {
+				transformerMock.On("Parse", mock.Anything).Return(testParams, tt.mockParseErr).Once()
+			}
+			if tt.name != "Invalid UUID" && tt.mockParseErr == nil {
+				if tt.mockImageErr != nil {
+					imageGetterMock.On("GetImage", mock.Anything, testUUID).Return(nil, tt.mockImageErr).Once()
+				} else {
+					imageGetterMock.On("GetImage", mock.Anything, testUUID).Return(testImage, nil).Once()
+				}
+			}
+			if tt.name == "Success" {
+				transformerMock.On("Transform", mock.Anything, testImage, testParams).
+					Return(nopSeekCloser{bytes.NewReader(content)}, &storage.BlobInfo{ModTime: time.Now()}, nil).Once()
+			}
+			if tt.mockRenderErr != nil {
+				transformerMock.On("Transform", mock.Anything, testImage, testParams).Return(nil, nil, tt.mockRenderErr).Once()
+			}
+
+			req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/image/%s/transform?w=320&h=240&format=png", tt.imageID), nil)
+
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", tt.imageID)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			rr := httptest.NewRecorder()
+
+			handler := transformImage.New(log, imageGetterMock, transformerMock)
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+			require.Equal(t, tt.expectedBody, rr.Body.String())
+
+			if tt.expectedStatus == http.StatusOK {
+				require.Equal(t, "image/png", rr.Header().Get("Content-Type"))
+				require.Equal(t, `"`+testParams.Key(testUUID)+`"`, rr.Header().Get("ETag"))
+			}
+		})
+	}
+}
@@ -0,0 +1,275 @@
+package completeUpload
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"imageProcessor/internal/kafka/producer"
+	"imageProcessor/internal/lib/api/response"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/lib/imageformat"
+	"imageProcessor/internal/lib/logger/sl"
+	"imageProcessor/internal/models"
+	"imageProcessor/internal/storage"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const (
+	statusPendingUpload = "pending_upload"
+	statusProcessed     = "processed"
+)
+
+var (
+	errSizeMismatch     = errors.New("size mismatch")
+	errChecksumMismatch = errors.New("checksum mismatch")
+	errUnsupportedType  = errors.New("unsupported image type")
+)
+
+type Response struct {
+	response.Response
+	ImageID uuid.UUID `json:"image_id"`
+	// Duplicate is set when the file was uploaded before and its image is
+	// returned in place of the one presigned, which is removed.
+	Duplicate bool `json:"duplicate,omitempty"`
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=UploadCompleter
+type UploadCompleter interface {
+	GetImage(ctx context.Context, id uuid.UUID) (*models.Image, error)
+	CompletePendingUpload(ctx context.Context, id uuid.UUID) (*models.Image, error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=BlobStorage
+type BlobStorage interface {
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, *storage.BlobInfo, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// CompleteUpload verifies a presigned upload and starts processing it.
+// @Summary      Complete a presigned upload
+// @Description  Checks the file PUT to the URL returned by POST /uploads/presign against the size and SHA-256 declared there and that it is an image, then queues it for processing. A file that fails the checks is removed, so that it may be sent again while the URL is valid. The file is then deduplicated like an upload to POST /upload: if the key has an image of the same content, the presigned image is removed and that image is returned with duplicate set, or sent with 409 if duplicates are rejected.
+// @Tags         uploads
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      string  true  "Image ID"
+// @Param        Idempotency-Key  header  string  false  "Key making retries of the request safe"
+// @Success      200  {object}  completeUpload.Response
+// @Failure      400  {object}  response.Response
+// @Failure      401  {object}  response.Response
+// @Failure      403  {object}  response.Response
+// @Failure      404  {object}  response.Response
+// @Failure      409  {object}  completeUpload.Response
+// @Failure      410  {object}  response.Response
+// @Failure      415  {object}  response.Response
+// @Failure      422  {object}  response.Response
+// @Failure      500  {object}  response.Response
+// @Router       /uploads/{id}/complete [post]
+func New(log *slog.Logger, uploadCompleter UploadCompleter, blobStorage BlobStorage, kafkaProducer producer.ProducerIface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.presign.completeUpload.New"
+
+		log := log.With(slog.String("op", op))
+
+		imageID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			log.Error("failed to parse image ID", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error("invalid image ID"))
+			return
+		}
+
+		log = log.With(slog.String("image_id", imageID.String()))
+
+		image, err := uploadCompleter.GetImage(r.Context(), imageID)
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warn("image not found")
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, response.Error("image not found"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to get image", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to complete upload"))
+			return
+		}
+
+		if !apikey.CanAccess(r.Context(), image.OwnerKeyID) {
+			log.Warn("image belongs to another api key")
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, response.Error("image not found"))
+			return
+		}
+
+		if image.Status != statusPendingUpload {
+			log.Warn("upload completed already", slog.String("status", image.Status))
+			render.Status(r, http.StatusConflict)
+			render.JSON(w, r, response.Error("upload already completed"))
+			return
+		}
+
+		// Expired uploads are only removed every so often.
+		if image.UploadExpiresAt != nil && time.Now().After(*image.UploadExpiresAt) {
+			log.Warn("upload expired")
+			render.Status(r, http.StatusGone)
+			render.JSON(w, r, response.Error("upload expired"))
+			return
+		}
+
+		err = verify(r.Context(), blobStorage, image)
+		if errors.Is(err, storage.ErrBlobNotFound) {
+			log.Warn("file not uploaded yet")
+			render.Status(r, http.StatusConflict)
+			render.JSON(w, r, response.Error("file not uploaded"))
+			return
+		}
+		if status, msg, rejected := verificationFailed(err); rejected {
+			log.Warn("uploaded file failed verification", sl.Err(err))
+			if err := blobStorage.Delete(r.Context(), image.OriginalPath); err != nil {
+				log.Error("failed to remove rejected file", slog.String("key", image.OriginalPath), sl.Err(err))
+			}
+			render.Status(r, status)
+			render.JSON(w, r, response.Error(msg))
+			return
+		}
+		if err != nil {
+			log.Error("failed to verify uploaded file", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to complete upload"))
+			return
+		}
+
+		completed, err := uploadCompleter.CompletePendingUpload(r.Context(), imageID)
+		var duplicate *storage.DuplicateError
+		switch {
+		case errors.Is(err, storage.ErrNotPendingUpload):
+			log.Warn("upload completed concurrently")
+			render.Status(r, http.StatusConflict)
+			render.JSON(w, r, response.Error("upload already completed"))
+			return
+		case errors.As(err, &duplicate):
+			log.Info("upload duplicates an image",
+				slog.String("duplicate_of", duplicate.Image.ID.String()),
+				slog.Bool("rejected", duplicate.Rejected),
+			)
+			if err := blobStorage.Delete(r.Context(), image.OriginalPath); err != nil {
+				log.Error("failed to remove duplicate file", slog.String("key", image.OriginalPath), sl.Err(err))
+			}
+			if duplicate.Rejected {
+				render.Status(r, http.StatusConflict)
+				render.JSON(w, r, Response{Response: response.Error("duplicate image"), ImageID: duplicate.Image.ID})
+				return
+			}
+			render.JSON(w, r, Response{Response: response.OK(), ImageID: duplicate.Image.ID, Duplicate: true})
+			return
+		case err != nil:
+			log.Error("failed to complete upload", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to complete upload"))
+			return
+		}
+
+		// The image shares the original of an identical upload, and if that
+		// one is processed, its variants too.
+		if completed.OriginalPath != image.OriginalPath {
+			if err := blobStorage.Delete(r.Context(), image.OriginalPath); err != nil {
+				log.Error("failed to remove duplicate file", slog.String("key", image.OriginalPath), sl.Err(err))
+			}
+		}
+		if completed.Status == statusProcessed {
+			log.Info("upload completed with the variants of an identical image")
+			render.JSON(w, r, Response{Response: response.OK(), ImageID: completed.ID})
+			return
+		}
+
+		message, err := json.Marshal(models.ProcessingJob{
+			ImageID:      completed.ID,
+			TenantID:     completed.TenantID,
+			OriginalPath: completed.OriginalPath,
+		})
+		if err != nil {
+			log.Error("failed to marshal kafka message", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to prepare message"))
+			return
+		}
+
+		if err = kafkaProducer.SendMessage(r.Context(), message); err != nil {
+			log.Error("failed to publish message to kafka", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to start image processing"))
+			return
+		}
+
+		log.Info("upload completed and message published to kafka")
+
+		render.JSON(w, r, Response{
+			Response: response.OK(),
+			ImageID:  image.ID,
+		})
+	}
+}
+
+// verificationFailed tells the answer to a file that is not what was
+// declared.
+func verificationFailed(err error) (int, string, bool) {
+	switch {
+	case errors.Is(err, errSizeMismatch):
+		return http.StatusUnprocessableEntity, "size mismatch", true
+	case errors.Is(err, errChecksumMismatch):
+		return http.StatusUnprocessableEntity, "checksum mismatch", true
+	case errors.Is(err, errUnsupportedType):
+		return http.StatusUnsupportedMediaType, "unsupported image type", true
+	default:
+		return 0, "", false
+	}
+}
+
+// verify checks the stored original against what was declared when the
+// upload was presigned. The whole file is read to hash it.
+func verify(ctx context.Context, blobStorage BlobStorage, image *models.Image) error {
+	const op = "handlers.presign.completeUpload.verify"
+
+	blob, info, err := blobStorage.Open(ctx, image.OriginalPath)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		_ = blob.Close()
+	}()
+
+	if info.Size != image.Size {
+		return fmt.Errorf("%s: %w: got %d bytes, want %d", op, errSizeMismatch, info.Size, image.Size)
+	}
+
+	body := bufio.NewReader(blob)
+	header, err := body.Peek(imageformat.SniffLen)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if _, ok := imageformat.Sniff(header); !ok {
+		return fmt.Errorf("%s: %w", op, errUnsupportedType)
+	}
+
+	hash := sha256.New()
+	if _, err = io.Copy(hash, body); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if image.SHA256 == nil || hex.EncodeToString(hash.Sum(nil)) != *image.SHA256 {
+		return fmt.Errorf("%s: %w", op, errChecksumMismatch)
+	}
+
+	return nil
+}
@@ -0,0 +1,262 @@
+package completeUpload_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"imageProcessor/internal/http-server/handlers/presign/completeUpload"
+	"imageProcessor/internal/http-server/handlers/presign/completeUpload/mocks"
+	kafkaMocks "imageProcessor/internal/kafka/producer/mocks"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/models"
+	"imageProcessor/internal/storage"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type blob struct {
+	*bytes.Reader
+}
+
+func (blob) Close() error { return nil }
+
+func TestCompleteUpload(t *testing.T) {
+	log := slog.New(slog.NewJSONHandler(bytes.NewBuffer(nil), nil))
+
+	owner := &models.APIKey{ID: uuid.New(), TenantID: "shop", Scopes: []string{apikey.ScopeUpload}}
+	other := &models.APIKey{ID: uuid.New(), TenantID: "shop", Scopes: []string{apikey.ScopeUpload}}
+
+	content := []byte("\xff\xd8\xff\xe0presigned image")
+	sum := sha256.Sum256(content)
+	checksum := hex.EncodeToString(sum[:])
+
+	imageID := uuid.New()
+	key := "shop/uploads/" + uuid.NewString() + ".jpg"
+	expiresAt := time.Now().Add(10 * time.Minute)
+
+	pending := func(edit func(image *models.Image)) *models.Image {
+		image := &models.Image{
+			ID:              imageID,
+			TenantID:        "shop",
+			Status:          "pending_upload",
+			OriginalPath:    key,
+			Size:            int64(len(content)),
+			OwnerKeyID:      &owner.ID,
+			SHA256:          &checksum,
+			UploadExpiresAt: &expiresAt,
+		}
+		if edit != nil {
+			edit(image)
+		}
+		return image
+	}
+
+	completed := pending(func(image *models.Image) { image.Status = "pending"; image.UploadExpiresAt = nil })
+	shared := pending(func(image *models.Image) {
+		image.Status = "processed"
+		image.OriginalPath = "shop/uploads/" + uuid.NewString() + ".jpg"
+		image.UploadExpiresAt = nil
+	})
+	existingID := uuid.New()
+
+	tests := []struct {
+		name           string
+		key            *models.APIKey
+		image          *models.Image
+		mockGetErr     error
+		blob           []byte
+		mockOpenErr    error
+		deleted        bool
+		done           *models.Image
+		mockDoneErr    error
+		mockKafkaErr   error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Success",
+			key:            owner,
+			image:          pending(nil),
+			blob:           content,
+			done:           completed,
+			expectedStatus: http.StatusOK,
+			expectedBody:   fmt.Sprintf(`{"status":"OK","image_id":"%s"}`, imageID),
+		},
+		{
+			name:           "Shares Processed Image",
+			key:            owner,
+			image:          pending(nil),
+			blob:           content,
+			deleted:        true,
+			done:           shared,
+			expectedStatus: http.StatusOK,
+			expectedBody:   fmt.Sprintf(`{"status":"OK","image_id":"%s"}`, imageID),
+		},
+		{
+			name:           "Duplicate Returned",
+			key:            owner,
+			image:          pending(nil),
+			blob:           content,
+			deleted:        true,
+			mockDoneErr:    fmt.Errorf("storage.postgres.CompletePendingUpload: %w", &storage.DuplicateError{Image: &models.Image{ID: existingID}}),
+			expectedStatus: http.StatusOK,
+			expectedBody:   fmt.Sprintf(`{"status":"OK","image_id":"%s","duplicate":true}`, existingID),
+		},
+		{
+			name:           "Duplicate Rejected",
+			key:            owner,
+			image:          pending(nil),
+			blob:           content,
+			deleted:        true,
+			mockDoneErr:    fmt.Errorf("storage.postgres.CompletePendingUpload: %w", &storage.DuplicateError{Image: &models.Image{ID: existingID}, Rejected: true}),
+			expectedStatus: http.StatusConflict,
+			expectedBody:   fmt.Sprintf(`{"status":"Error","error":"duplicate image","image_id":"%s"}`, existingID),
+		},
+		{
+			name:           "Another Key",
+			key:            other,
+			image:          pending(nil),
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"status":"Error","error":"image not found"}`,
+		},
+		{
+			name:           "Not Found",
+			key:            owner,
+			mockGetErr:     fmt.Errorf("storage.postgres.GetImage: %w", sql.ErrNoRows),
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"status":"Error","error":"image not found"}`,
+		},
+		{
+			name:           "Already Completed",
+			key:            owner,
+			image:          pending(func(image *models.Image) { image.Status = "pending"; image.UploadExpiresAt = nil }),
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"status":"Error","error":"upload already completed"}`,
+		},
+		{
+			name: "Expired",
+			key:  owner,
+			image: pending(func(image *models.Image) {
+				expired := time.Now().Add(-time.Minute)
+				image.UploadExpiresAt = &expired
+			}),
+			expectedStatus: http.StatusGone,
+			expectedBody:   `{"status":"Error","error":"upload expired"}`,
+		},
+		{
+			name:           "Not Uploaded",
+			key:            owner,
+			image:          pending(nil),
+			mockOpenErr:    fmt.Errorf("storage.local.Open: %w", storage.ErrBlobNotFound),
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"status":"Error","error":"file not uploaded"}`,
+		},
+		{
+			name:           "Size Mismatch",
+			key:            owner,
+			image:          pending(nil),
+			blob:           content[:len(content)-1],
+			deleted:        true,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedBody:   `{"status":"Error","error":"size mismatch"}`,
+		},
+		{
+			name:           "Checksum Mismatch",
+			key:            owner,
+			image:          pending(nil),
+			blob:           append(content[:len(content)-1:len(content)-1], '!'),
+			deleted:        true,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedBody:   `{"status":"Error","error":"checksum mismatch"}`,
+		},
+		{
+			name:           "Not An Image",
+			key:            owner,
+			image:          pending(nil),
+			blob:           bytes.Repeat([]byte("x"), len(content)),
+			deleted:        true,
+			expectedStatus: http.StatusUnsupportedMediaType,
+			expectedBody:   `{"status":"Error","error":"unsupported image type"}`,
+		},
+		{
+			name:           "Completed Concurrently",
+			key:            owner,
+			image:          pending(nil),
+			blob:           content,
+			mockDoneErr:    fmt.Errorf("storage.postgres.CompletePendingUpload: %w", storage.ErrNotPendingUpload),
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"status":"Error","error":"upload already completed"}`,
+		},
+		{
+			name:           "Failed to Publish to Kafka",
+			key:            owner,
+			image:          pending(nil),
+			blob:           content,
+			done:           completed,
+			mockKafkaErr:   errors.New("kafka error"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"status":"Error","error":"failed to start image processing"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uploadCompleterMock := mocks.NewUploadCompleter(t)
+			blobStorageMock := mocks.NewBlobStorage(t)
+			kafkaProducerMock := kafkaMocks.NewProducerIface(t)
+
+			if tt.mockGetErr != nil {
+				uploadCompleterMock.On("GetImage", mock.Anything, imageID).Return(nil, tt.mockGetErr).Once()
+			} else {
+				uploadCompleterMock.On("GetImage", mock.Anything, imageID).Return(tt.image, nil).Once()
+			}
+			if tt.mockOpenErr != nil {
+				blobStorageMock.On("Open", mock.Anything, key).Return(nil, nil, tt.mockOpenErr).Once()
+			}
+			if tt.blob != nil {
+				blobStorageMock.On("Open", mock.Anything, key).
+					Return(func(context.Context, string) (io.ReadSeekCloser, *storage.BlobInfo, error) {
+						return blob{bytes.NewReader(tt.blob)}, &storage.BlobInfo{Key: key, Size: int64(len(tt.blob))}, nil
+					}).Once()
+			}
+			if tt.deleted {
+				blobStorageMock.On("Delete", mock.Anything, key).Return(nil).Once()
+			}
+			if tt.done != nil || tt.mockDoneErr != nil {
+				uploadCompleterMock.On("CompletePendingUpload", mock.Anything, imageID).Return(tt.done, tt.mockDoneErr).Once()
+			}
+			if tt.done != nil && tt.done.Status == "pending" {
+				kafkaProducerMock.On("SendMessage", mock.Anything, mock.MatchedBy(func(message []byte) bool {
+					var job models.ProcessingJob
+					return json.Unmarshal(message, &job) == nil && job.ImageID == imageID && job.OriginalPath == key
+				})).Return(tt.mockKafkaErr).Once()
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/uploads/"+imageID.String()+"/complete", nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", imageID.String())
+			req = req.WithContext(apikey.WithKey(context.WithValue(req.Context(), chi.RouteCtxKey, rctx), tt.key))
+
+			rr := httptest.NewRecorder()
+
+			handler := completeUpload.New(log, uploadCompleterMock, blobStorageMock, kafkaProducerMock)
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+			require.JSONEq(t, tt.expectedBody, rr.Body.String())
+		})
+	}
+}
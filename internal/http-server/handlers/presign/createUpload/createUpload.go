@@ -0,0 +1,207 @@
+package createUpload
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"imageProcessor/internal/lib/api/response"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/lib/logger/sl"
+	"imageProcessor/internal/lib/quota"
+	"imageProcessor/internal/lib/tenant"
+	"imageProcessor/internal/models"
+	"imageProcessor/internal/webhook"
+	"io"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const uploadDir = "uploads"
+
+type Request struct {
+	Filename string `json:"filename" validate:"required"`
+	Size     int64  `json:"size" validate:"required,min=1"`
+	// SHA256 is the hex encoded checksum of the file, which the upload is
+	// verified against on completion.
+	SHA256      string `json:"sha256" validate:"required,len=64,hexadecimal"`
+	CallbackURL string `json:"callback_url,omitempty"`
+}
+
+type Response struct {
+	response.Response
+	ImageID uuid.UUID `json:"image_id"`
+	// UploadURL takes the file in a PUT of exactly Size bytes until
+	// ExpiresAt, without an API key.
+	UploadURL string    `json:"upload_url"`
+	Method    string    `json:"method"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=UploadCreator
+type UploadCreator interface {
+	SaveImage(ctx context.Context, upload models.Upload, limits quota.Set) (*models.Image, error)
+	GetUsage(ctx context.Context, keyID *uuid.UUID) (models.Usage, *models.Usage, error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=Presigner
+type Presigner interface {
+	PresignPut(ctx context.Context, key string, size int64) (string, time.Time, error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=QuotaResolver
+type QuotaResolver interface {
+	For(tenantID string, keyID *uuid.UUID) quota.Set
+}
+
+// CreateUpload starts an upload that goes straight to the blob backend.
+// @Summary      Presign an upload
+// @Description  Records an image as pending_upload and returns a short-lived URL the file is PUT to, bypassing the API. The size and SHA-256 of the file are declared up front and checked by POST /uploads/{id}/complete, which starts processing. The declared size counts against the quotas right away; uploads not completed before expires_at are removed and their quota released.
+// @Tags         uploads
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        request  body  createUpload.Request  true  "File to upload"
+// @Param        Idempotency-Key  header  string  false  "Key making retries of the request safe"
+// @Success      201  {object}  createUpload.Response
+// @Failure      400  {object}  response.Response
+// @Failure      401  {object}  response.Response
+// @Failure      403  {object}  response.Response
+// @Failure      413  {object}  response.Response
+// @Failure      429  {object}  response.Response
+// @Failure      500  {object}  response.Response
+// @Failure      507  {object}  response.Response
+// @Router       /uploads/presign [post]
+func New(log *slog.Logger, uploadCreator UploadCreator, presigner Presigner, quotas QuotaResolver, maxSize int64) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.presign.createUpload.New"
+
+		log := log.With(slog.String("op", op))
+
+		var req Request
+
+		err := render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			log.Error("request body is empty")
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error("empty request"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error("failed to decode request"))
+			return
+		}
+
+		if err = validator.New().Struct(req); err != nil {
+			var validateErr validator.ValidationErrors
+			errors.As(err, &validateErr)
+
+			log.Error("invalid request", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.ValidationError(validateErr))
+			return
+		}
+
+		if req.Size > maxSize {
+			log.Error("upload too large", slog.Int64("size", req.Size))
+			render.Status(r, http.StatusRequestEntityTooLarge)
+			render.JSON(w, r, response.Error("upload too large"))
+			return
+		}
+
+		if req.CallbackURL != "" {
+			if err = webhook.ValidateURL(req.CallbackURL); err != nil {
+				log.Error("invalid callback url", sl.Err(err))
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, response.Error("invalid callback_url"))
+				return
+			}
+		}
+
+		tenantID, err := tenant.FromContext(r.Context())
+		if err != nil {
+			log.Error("request has no tenant", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to create upload"))
+			return
+		}
+
+		ownerKeyID := apikey.OwnerID(r.Context())
+		limits := quotas.For(tenantID, ownerKeyID)
+
+		tenantUsage, keyUsage, err := uploadCreator.GetUsage(r.Context(), ownerKeyID)
+		if err != nil {
+			log.Error("failed to get usage", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to create upload"))
+			return
+		}
+
+		scopes := []quota.Scope{{Limits: limits.Tenant, Usage: tenantUsage}}
+		if keyUsage != nil {
+			scopes = append(scopes, quota.Scope{Limits: limits.Key, Usage: *keyUsage})
+		}
+
+		now := time.Now()
+		if err = quota.Check(scopes, req.Size, now); err != nil {
+			log.Warn("upload rejected by quota", slog.String("tenant_id", tenantID), sl.Err(err))
+			quota.WriteExceeded(w, r, err, scopes, now)
+			return
+		}
+
+		key := tenant.BlobKey(tenantID, uploadDir, uuid.NewString()+strings.ToLower(filepath.Ext(req.Filename)))
+
+		uploadURL, expiresAt, err := presigner.PresignPut(r.Context(), key, req.Size)
+		if err != nil {
+			log.Error("failed to presign upload", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to create upload"))
+			return
+		}
+
+		// The declared size is reserved now, so that the quota can't be
+		// overrun by uploads in flight.
+		image, err := uploadCreator.SaveImage(r.Context(), models.Upload{
+			Filename:     req.Filename,
+			OriginalPath: key,
+			Size:         req.Size,
+			OwnerKeyID:   ownerKeyID,
+			CallbackURL:  req.CallbackURL,
+			SHA256:       strings.ToLower(req.SHA256),
+			ExpiresAt:    &expiresAt,
+		}, limits)
+		if errors.Is(err, quota.ErrStorageExceeded) || errors.Is(err, quota.ErrRateExceeded) {
+			log.Warn("upload rejected by quota", slog.String("tenant_id", tenantID), sl.Err(err))
+			quota.WriteExceeded(w, r, err, scopes, time.Now())
+			return
+		}
+		if err != nil {
+			log.Error("failed to save image metadata", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to create upload"))
+			return
+		}
+
+		log.Info("upload presigned", slog.String("image_id", image.ID.String()), slog.Int64("size", req.Size))
+
+		for i := range scopes {
+			scopes[i] = scopes[i].Add(req.Size, now)
+		}
+		quota.SetHeaders(w.Header(), quota.NewStatus(scopes, now), now)
+
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, Response{
+			Response:  response.OK(),
+			ImageID:   image.ID,
+			UploadURL: uploadURL,
+			Method:    http.MethodPut,
+			ExpiresAt: expiresAt,
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package createUpload_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"imageProcessor/internal/http-server/handlers/presign/createUpload"
+	"imageProcessor/internal/http-server/handlers/presign/createUpload/mocks"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/lib/quota"
+	"imageProcessor/internal/lib/tenant"
+	"imageProcessor/internal/models"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCreateUpload(t *testing.T) {
+	log := slog.New(slog.NewJSONHandler(bytes.NewBuffer(nil), nil))
+
+	const maxSize = 10000
+	const checksum = "9F86D081884C7D659A2FEAA0C55AD015A3BF4F1B2B0B822CD15D6C15B0F00A08"
+
+	testKey := &models.APIKey{ID: uuid.New(), TenantID: "shop", Scopes: []string{apikey.ScopeUpload}}
+	imageID := uuid.New()
+	uploadURL := "http://localhost:8075/blobs/shop/uploads/x.jpg?sig=abc"
+	expiresAt := time.Now().Add(15 * time.Minute).Truncate(time.Second)
+
+	limits := quota.Set{Tenant: quota.Limits{MaxBytes: 5000, MaxImages: 10}}
+
+	tests := []struct {
+		name            string
+		body            string
+		tenantUsage     models.Usage
+		presigned       bool
+		mockPresignErr  error
+		saved           bool
+		mockSaveErr     error
+		expectedStatus  int
+		expectedBody    string
+		expectedHeaders map[string]string
+	}{
+		{
+			name:           "Success",
+			body:           `{"filename":"photo.JPG","size":1000,"sha256":"` + checksum + `","callback_url":"https://hooks.example.com/images"}`,
+			tenantUsage:    models.Usage{Bytes: 1000, Images: 2},
+			presigned:      true,
+			saved:          true,
+			expectedStatus: http.StatusCreated,
+			expectedHeaders: map[string]string{
+				quota.HeaderBytesRemaining:  "3000",
+				quota.HeaderImagesRemaining: "7",
+			},
+		},
+		{
+			name:           "Empty Request",
+			body:           "",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"Error","error":"empty request"}`,
+		},
+		{
+			name:           "Missing Checksum",
+			body:           `{"filename":"photo.jpg","size":1000}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"Error","error":"field SHA256 is a required field"}`,
+		},
+		{
+			name:           "Invalid Checksum",
+			body:           `{"filename":"photo.jpg","size":1000,"sha256":"abc"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"Error","error":"field SHA256 is not valid"}`,
+		},
+		{
+			name:           "Too Large",
+			body:           `{"filename":"photo.jpg","size":10001,"sha256":"` + checksum + `"}`,
+			expectedStatus: http.StatusRequestEntityTooLarge,
+			expectedBody:   `{"status":"Error","error":"upload too large"}`,
+		},
+		{
+			name:           "Invalid Callback URL",
+			body:           `{"filename":"photo.jpg","size":1000,"sha256":"` + checksum + `","callback_url":"ftp://hooks.example.com"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"Error","error":"invalid callback_url"}`,
+		},
+		{
+			name:           "Storage Quota Exceeded",
+			body:           `{"filename":"photo.jpg","size":1000,"sha256":"` + checksum + `"}`,
+			tenantUsage:    models.Usage{Bytes: 4500},
+			expectedStatus: http.StatusInsufficientStorage,
+			expectedBody:   `{"status":"Error","error":"storage quota exceeded"}`,
+		},
+		{
+			name:           "Quota Exceeded Concurrently",
+			body:           `{"filename":"photo.jpg","size":1000,"sha256":"` + checksum + `"}`,
+			presigned:      true,
+			mockSaveErr:    fmt.Errorf("storage.postgres.SaveImage: %w", quota.ErrStorageExceeded),
+			expectedStatus: http.StatusInsufficientStorage,
+			expectedBody:   `{"status":"Error","error":"storage quota exceeded"}`,
+		},
+		{
+			name:           "Failed to Presign",
+			body:           `{"filename":"photo.jpg","size":1000,"sha256":"` + checksum + `"}`,
+			mockPresignErr: errors.New("no tenant"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"status":"Error","error":"failed to create upload"}`,
+		},
+		{
+			name:           "Failed to Save",
+			body:           `{"filename":"photo.jpg","size":1000,"sha256":"` + checksum + `"}`,
+			presigned:      true,
+			mockSaveErr:    errors.New("db error"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"status":"Error","error":"failed to create upload"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uploadCreatorMock := mocks.NewUploadCreator(t)
+			presignerMock := mocks.NewPresigner(t)
+			quotaResolverMock := mocks.NewQuotaResolver(t)
+
+			// The original gets a fresh name in the tenant's namespace.
+			var signedKey string
+			isTenantKey := mock.MatchedBy(func(key string) bool {
+				signedKey = key
+				return strings.HasPrefix(key, "shop/uploads/") && strings.HasSuffix(key, ".jpg") && !strings.Contains(key, "photo")
+			})
+
+			validRequest := tt.expectedStatus != http.StatusBadRequest && tt.expectedStatus != http.StatusRequestEntityTooLarge
+			if validRequest {
+				quotaResolverMock.On("For", "shop", &testKey.ID).Return(limits).Once()
+				uploadCreatorMock.On("GetUsage", mock.Anything, &testKey.ID).Return(tt.tenantUsage, &models.Usage{}, nil).Once()
+			}
+			if tt.mockPresignErr != nil {
+				presignerMock.On("PresignPut", mock.Anything, isTenantKey, int64(1000)).Return("", time.Time{}, tt.mockPresignErr).Once()
+			}
+			if tt.presigned {
+				presignerMock.On("PresignPut", mock.Anything, isTenantKey, int64(1000)).Return(uploadURL, expiresAt, nil).Once()
+
+				isUpload := mock.MatchedBy(func(upload models.Upload) bool {
+					return strings.EqualFold(upload.Filename, "photo.jpg") &&
+						upload.OriginalPath == signedKey &&
+						upload.Size == 1000 &&
+						upload.SHA256 == strings.ToLower(checksum) &&
+						*upload.OwnerKeyID == testKey.ID &&
+						upload.ExpiresAt != nil && upload.ExpiresAt.Equal(expiresAt)
+				})
+				if tt.saved {
+					uploadCreatorMock.On("SaveImage", mock.Anything, isUpload, limits).
+						Return(&models.Image{ID: imageID, TenantID: "shop", Status: "pending_upload"}, nil).Once()
+				} else {
+					uploadCreatorMock.On("SaveImage", mock.Anything, isUpload, limits).Return(nil, tt.mockSaveErr).Once()
+				}
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/uploads/presign", strings.NewReader(tt.body))
+			req = req.WithContext(tenant.WithID(apikey.WithKey(req.Context(), testKey), testKey.TenantID))
+
+			rr := httptest.NewRecorder()
+
+			handler := createUpload.New(log, uploadCreatorMock, presignerMock, quotaResolverMock, maxSize)
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+
+			if tt.saved {
+				var resp createUpload.Response
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+				require.Equal(t, "OK", resp.Status)
+				require.Equal(t, imageID, resp.ImageID)
+				require.Equal(t, uploadURL, resp.UploadURL)
+				require.Equal(t, http.MethodPut, resp.Method)
+				require.True(t, expiresAt.Equal(resp.ExpiresAt))
+			} else {
+				require.JSONEq(t, tt.expectedBody, rr.Body.String())
+			}
+
+			for k, v := range tt.expectedHeaders {
+				require.Equal(t, v, rr.Header().Get(k), k)
+			}
+		})
+	}
+}
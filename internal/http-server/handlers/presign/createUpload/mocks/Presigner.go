@@ -0,0 +1,65 @@
+// Code generated by mockery v2.51.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+
+	time "time"
+)
+
+// Presigner is an autogenerated mock type for the Presigner type
+type Presigner struct {
+	mock.Mock
+}
+
+// PresignPut provides a mock function with given fields: ctx, key, size
+func (_m *Presigner) PresignPut(ctx context.Context, key string, size int64) (string, time.Time, error) {
+	ret := _m.Called(ctx, key, size)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PresignPut")
+	}
+
+	var r0 string
+	var r1 time.Time
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, int64) (string, time.Time, error)); ok {
+		return rf(ctx, key, size)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, int64) string); ok {
+		r0 = rf(ctx, key, size)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, int64) time.Time); ok {
+		r1 = rf(ctx, key, size)
+	} else {
+		r1 = ret.Get(1).(time.Time)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, string, int64) error); ok {
+		r2 = rf(ctx, key, size)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// NewPresigner creates a new instance of Presigner. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewPresigner(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *Presigner {
+	mock := &Presigner{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,165 @@
+package createUpload
+
+import (
+	"context"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"imageProcessor/internal/lib/api/response"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/lib/logger/sl"
+	"imageProcessor/internal/lib/quota"
+	"imageProcessor/internal/lib/tenant"
+	"imageProcessor/internal/lib/tus"
+	"imageProcessor/internal/models"
+	"imageProcessor/internal/webhook"
+	"log/slog"
+	"net/http"
+	"path"
+	"time"
+)
+
+// defaultFilename is used when the metadata names no file.
+const defaultFilename = "image"
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=UploadCreator
+type UploadCreator interface {
+	CreateTusUpload(ctx context.Context, upload models.TusUpload) (*models.TusUpload, error)
+	GetUsage(ctx context.Context, keyID *uuid.UUID) (models.Usage, *models.Usage, error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=QuotaResolver
+type QuotaResolver interface {
+	For(tenantID string, keyID *uuid.UUID) quota.Set
+}
+
+// CreateUpload starts a resumable upload.
+// @Summary      Create a resumable upload
+// @Description  Creates a tus 1.0 upload of Upload-Length bytes and returns its URL in Location. The file name and callback_url may be passed in Upload-Metadata as "filename" and "callback_url". Quotas are checked against the announced length up front, and again once the upload is complete.
+// @Tags         tus
+// @Security     ApiKeyAuth
+// @Param        Tus-Resumable    header  string  true   "1.0.0"
+// @Param        Upload-Length    header  int     true   "Size of the file in bytes"
+// @Param        Upload-Metadata  header  string  false  "Comma-separated keys with base64 encoded values"
+// @Success      201
+// @Failure      400  {object}  response.Response
+// @Failure      401  {object}  response.Response
+// @Failure      403  {object}  response.Response
+// @Failure      412  {object}  response.Response
+// @Failure      413  {object}  response.Response
+// @Failure      429  {object}  response.Response
+// @Failure      500  {object}  response.Response
+// @Failure      507  {object}  response.Response
+// @Router       /uploads/tus [post]
+func New(log *slog.Logger, uploadCreator UploadCreator, quotas QuotaResolver, maxSize int64, expiration time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.tus.createUpload.New"
+
+		log := log.With(slog.String("op", op))
+
+		if r.Header.Get(tus.HeaderUploadDeferLength) != "" {
+			log.Error("deferred upload length requested")
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error("deferred length is not supported"))
+			return
+		}
+
+		length, ok := tus.ParseSize(r.Header.Get(tus.HeaderUploadLength))
+		if !ok {
+			log.Error("invalid upload length", slog.String("length", r.Header.Get(tus.HeaderUploadLength)))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error("invalid Upload-Length"))
+			return
+		}
+		if length == 0 {
+			log.Error("received empty file")
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error("received empty file"))
+			return
+		}
+		if length > maxSize {
+			log.Error("upload too large", slog.Int64("length", length))
+			render.Status(r, http.StatusRequestEntityTooLarge)
+			render.JSON(w, r, response.Error("upload too large"))
+			return
+		}
+
+		metadata, err := tus.ParseMetadata(r.Header.Get(tus.HeaderUploadMetadata))
+		if err != nil {
+			log.Error("invalid upload metadata", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error("invalid Upload-Metadata"))
+			return
+		}
+
+		filename := metadata["filename"]
+		if filename == "" {
+			filename = metadata["name"]
+		}
+		if filename == "" {
+			filename = defaultFilename
+		}
+
+		callbackURL := metadata["callback_url"]
+		if callbackURL != "" {
+			if err = webhook.ValidateURL(callbackURL); err != nil {
+				log.Error("invalid callback url", sl.Err(err))
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, response.Error("invalid callback_url"))
+				return
+			}
+		}
+
+		tenantID, err := tenant.FromContext(r.Context())
+		if err != nil {
+			log.Error("request has no tenant", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to create upload"))
+			return
+		}
+
+		ownerKeyID := apikey.OwnerID(r.Context())
+		limits := quotas.For(tenantID, ownerKeyID)
+
+		tenantUsage, keyUsage, err := uploadCreator.GetUsage(r.Context(), ownerKeyID)
+		if err != nil {
+			log.Error("failed to get usage", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to create upload"))
+			return
+		}
+
+		scopes := []quota.Scope{{Limits: limits.Tenant, Usage: tenantUsage}}
+		if keyUsage != nil {
+			scopes = append(scopes, quota.Scope{Limits: limits.Key, Usage: *keyUsage})
+		}
+
+		// Better to refuse now than after the client has sent it all.
+		now := time.Now()
+		if err = quota.Check(scopes, length, now); err != nil {
+			log.Warn("upload rejected by quota", slog.String("tenant_id", tenantID), sl.Err(err))
+			quota.WriteExceeded(w, r, err, scopes, now)
+			return
+		}
+
+		upload, err := uploadCreator.CreateTusUpload(r.Context(), models.TusUpload{
+			OwnerKeyID:  ownerKeyID,
+			Length:      length,
+			Metadata:    r.Header.Get(tus.HeaderUploadMetadata),
+			Filename:    filename,
+			CallbackURL: callbackURL,
+			ExpiresAt:   now.Add(expiration),
+		})
+		if err != nil {
+			log.Error("failed to create upload", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to create upload"))
+			return
+		}
+
+		log.Info("upload created", slog.String("upload_id", upload.ID.String()), slog.Int64("length", length))
+
+		w.Header().Set("Location", path.Join(r.URL.Path, upload.ID.String()))
+		w.Header().Set(tus.HeaderUploadExpires, upload.ExpiresAt.UTC().Format(http.TimeFormat))
+		w.WriteHeader(http.StatusCreated)
+	}
+}
@@ -0,0 +1,162 @@
+package createUpload_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"imageProcessor/internal/http-server/handlers/tus/createUpload"
+	"imageProcessor/internal/http-server/handlers/tus/createUpload/mocks"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/lib/quota"
+	"imageProcessor/internal/lib/tenant"
+	"imageProcessor/internal/lib/tus"
+	"imageProcessor/internal/models"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCreateUpload(t *testing.T) {
+	log := slog.New(slog.NewJSONHandler(bytes.NewBuffer(nil), nil))
+
+	testKey := &models.APIKey{ID: uuid.New(), TenantID: "shop", Scopes: []string{apikey.ScopeUpload}}
+	uploadID := uuid.New()
+	expiresAt := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	encode := func(s string) string {
+		return base64.StdEncoding.EncodeToString([]byte(s))
+	}
+
+	tests := []struct {
+		name             string
+		headers          map[string]string
+		limits           quota.Set
+		mockErr          error
+		expectedStatus   int
+		expectedError    string
+		expectedFilename string
+		expectedCallback string
+	}{
+		{
+			name: "Success",
+			headers: map[string]string{
+				tus.HeaderUploadLength:   "1000",
+				tus.HeaderUploadMetadata: "filename " + encode("cat.jpg") + ",callback_url " + encode("https://cms.example.com/hooks") + ",is_confidential",
+			},
+			expectedStatus:   http.StatusCreated,
+			expectedFilename: "cat.jpg",
+			expectedCallback: "https://cms.example.com/hooks",
+		},
+		{
+			name:             "Without Metadata",
+			headers:          map[string]string{tus.HeaderUploadLength: "1000"},
+			expectedStatus:   http.StatusCreated,
+			expectedFilename: "image",
+		},
+		{
+			name:             "Name Instead of Filename",
+			headers:          map[string]string{tus.HeaderUploadLength: "1000", tus.HeaderUploadMetadata: "name " + encode("dog.png")},
+			expectedStatus:   http.StatusCreated,
+			expectedFilename: "dog.png",
+		},
+		{
+			name:           "Missing Length",
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "invalid Upload-Length",
+		},
+		{
+			name:           "Deferred Length",
+			headers:        map[string]string{tus.HeaderUploadDeferLength: "1"},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "deferred length is not supported",
+		},
+		{
+			name:           "Empty File",
+			headers:        map[string]string{tus.HeaderUploadLength: "0"},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "received empty file",
+		},
+		{
+			name:           "Too Large",
+			headers:        map[string]string{tus.HeaderUploadLength: "10001"},
+			expectedStatus: http.StatusRequestEntityTooLarge,
+			expectedError:  "upload too large",
+		},
+		{
+			name:           "Invalid Metadata",
+			headers:        map[string]string{tus.HeaderUploadLength: "1000", tus.HeaderUploadMetadata: "filename not-base64!"},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "invalid Upload-Metadata",
+		},
+		{
+			name:           "Invalid Callback URL",
+			headers:        map[string]string{tus.HeaderUploadLength: "1000", tus.HeaderUploadMetadata: "callback_url " + encode("ftp://cms.example.com")},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "invalid callback_url",
+		},
+		{
+			name:           "Storage Quota Exceeded",
+			headers:        map[string]string{tus.HeaderUploadLength: "1000"},
+			limits:         quota.Set{Tenant: quota.Limits{MaxBytes: 999}},
+			expectedStatus: http.StatusInsufficientStorage,
+			expectedError:  "storage quota exceeded",
+		},
+		{
+			name:             "Failed to Create",
+			headers:          map[string]string{tus.HeaderUploadLength: "1000"},
+			mockErr:          errors.New("db error"),
+			expectedStatus:   http.StatusInternalServerError,
+			expectedError:    "failed to create upload",
+			expectedFilename: "image",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uploadCreatorMock := mocks.NewUploadCreator(t)
+			quotaResolverMock := mocks.NewQuotaResolver(t)
+
+			quotaResolverMock.On("For", "shop", &testKey.ID).Return(tt.limits).Maybe()
+			uploadCreatorMock.On("GetUsage", mock.Anything, &testKey.ID).Return(models.Usage{}, &models.Usage{}, nil).Maybe()
+
+			if tt.expectedFilename != "" {
+				isUpload := mock.MatchedBy(func(upload models.TusUpload) bool {
+					return upload.Length == 1000 &&
+						upload.Filename == tt.expectedFilename &&
+						upload.CallbackURL == tt.expectedCallback &&
+						upload.Metadata == tt.headers[tus.HeaderUploadMetadata] &&
+						*upload.OwnerKeyID == testKey.ID &&
+						upload.ExpiresAt.After(time.Now())
+				})
+				uploadCreatorMock.On("CreateTusUpload", mock.Anything, isUpload).
+					Return(&models.TusUpload{ID: uploadID, TenantID: "shop", Length: 1000, ExpiresAt: expiresAt}, tt.mockErr).Once()
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/uploads/tus", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			req = req.WithContext(tenant.WithID(apikey.WithKey(req.Context(), testKey), testKey.TenantID))
+
+			rr := httptest.NewRecorder()
+
+			handler := createUpload.New(log, uploadCreatorMock, quotaResolverMock, 10000, 24*time.Hour)
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+
+			if tt.expectedError != "" {
+				require.JSONEq(t, `{"status":"Error","error":"`+tt.expectedError+`"}`, rr.Body.String())
+				return
+			}
+
+			require.Equal(t, "/uploads/tus/"+uploadID.String(), rr.Header().Get("Location"))
+			require.Equal(t, "Wed, 02 Jan 2030 03:04:05 GMT", rr.Header().Get(tus.HeaderUploadExpires))
+		})
+	}
+}
@@ -0,0 +1,99 @@
+// Code generated by mockery v2.51.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+
+	models "imageProcessor/internal/models"
+
+	uuid "github.com/google/uuid"
+)
+
+// UploadCreator is an autogenerated mock type for the UploadCreator type
+type UploadCreator struct {
+	mock.Mock
+}
+
+// CreateTusUpload provides a mock function with given fields: ctx, upload
+func (_m *UploadCreator) CreateTusUpload(ctx context.Context, upload models.TusUpload) (*models.TusUpload, error) {
+	ret := _m.Called(ctx, upload)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateTusUpload")
+	}
+
+	var r0 *models.TusUpload
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.TusUpload) (*models.TusUpload, error)); ok {
+		return rf(ctx, upload)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, models.TusUpload) *models.TusUpload); ok {
+		r0 = rf(ctx, upload)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.TusUpload)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, models.TusUpload) error); ok {
+		r1 = rf(ctx, upload)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUsage provides a mock function with given fields: ctx, keyID
+func (_m *UploadCreator) GetUsage(ctx context.Context, keyID *uuid.UUID) (models.Usage, *models.Usage, error) {
+	ret := _m.Called(ctx, keyID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetUsage")
+	}
+
+	var r0 models.Usage
+	var r1 *models.Usage
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, *uuid.UUID) (models.Usage, *models.Usage, error)); ok {
+		return rf(ctx, keyID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *uuid.UUID) models.Usage); ok {
+		r0 = rf(ctx, keyID)
+	} else {
+		r0 = ret.Get(0).(models.Usage)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *uuid.UUID) *models.Usage); ok {
+		r1 = rf(ctx, keyID)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(*models.Usage)
+		}
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, *uuid.UUID) error); ok {
+		r2 = rf(ctx, keyID)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// NewUploadCreator creates a new instance of UploadCreator. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewUploadCreator(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *UploadCreator {
+	mock := &UploadCreator{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
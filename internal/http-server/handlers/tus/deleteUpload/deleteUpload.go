@@ -0,0 +1,108 @@
+package deleteUpload
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"imageProcessor/internal/lib/api/response"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/lib/logger/sl"
+	"imageProcessor/internal/models"
+	"log/slog"
+	"net/http"
+)
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=UploadDeleter
+type UploadDeleter interface {
+	GetTusUpload(ctx context.Context, id uuid.UUID) (*models.TusUpload, error)
+	DeleteTusUpload(ctx context.Context, id uuid.UUID) (*models.TusUpload, error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=BlobDeleter
+type BlobDeleter interface {
+	Delete(ctx context.Context, key string) error
+}
+
+// DeleteUpload terminates a resumable upload.
+// @Summary      Terminate a resumable upload
+// @Description  Cancels a tus upload and removes the chunks received so far. An image already created from a complete upload is kept.
+// @Tags         tus
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        Tus-Resumable  header  string  true  "1.0.0"
+// @Param        id             path    string  true  "Upload ID"
+// @Success      204
+// @Failure      400  {object}  response.Response
+// @Failure      401  {object}  response.Response
+// @Failure      403  {object}  response.Response
+// @Failure      404  {object}  response.Response
+// @Failure      412  {object}  response.Response
+// @Failure      500  {object}  response.Response
+// @Router       /uploads/tus/{id} [delete]
+func New(log *slog.Logger, uploadDeleter UploadDeleter, blobDeleter BlobDeleter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.tus.deleteUpload.New"
+
+		log := log.With(slog.String("op", op))
+
+		uploadID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			log.Error("failed to parse upload ID", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error("invalid upload ID"))
+			return
+		}
+
+		upload, err := uploadDeleter.GetTusUpload(r.Context(), uploadID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				log.Warn("upload not found", slog.String("upload_id", uploadID.String()))
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, response.Error("upload not found"))
+				return
+			}
+
+			log.Error("failed to get upload", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to delete upload"))
+			return
+		}
+
+		if !apikey.CanAccess(r.Context(), upload.OwnerKeyID) {
+			log.Warn("upload belongs to another api key", slog.String("upload_id", uploadID.String()))
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, response.Error("upload not found"))
+			return
+		}
+
+		// The chunks are taken from the deleted row, which may have gained
+		// some since it was read.
+		upload, err = uploadDeleter.DeleteTusUpload(r.Context(), uploadID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				log.Warn("upload already deleted", slog.String("upload_id", uploadID.String()))
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, response.Error("upload not found"))
+				return
+			}
+
+			log.Error("failed to delete upload", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to delete upload"))
+			return
+		}
+
+		for _, chunk := range upload.Chunks {
+			if err := blobDeleter.Delete(r.Context(), chunk); err != nil {
+				log.Error("failed to remove chunk", slog.String("key", chunk), sl.Err(err))
+			}
+		}
+
+		log.Info("upload terminated", slog.String("upload_id", uploadID.String()))
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
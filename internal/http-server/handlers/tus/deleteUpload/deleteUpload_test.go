@@ -0,0 +1,134 @@
+package deleteUpload_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"imageProcessor/internal/http-server/handlers/tus/deleteUpload"
+	"imageProcessor/internal/http-server/handlers/tus/deleteUpload/mocks"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/models"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDeleteUpload(t *testing.T) {
+	log := slog.New(slog.NewJSONHandler(bytes.NewBuffer(nil), nil))
+
+	owner := &models.APIKey{ID: uuid.New(), TenantID: "shop", Scopes: []string{apikey.ScopeUpload}}
+	other := &models.APIKey{ID: uuid.New(), TenantID: "shop", Scopes: []string{apikey.ScopeUpload}}
+
+	uploadID := uuid.New()
+	chunks := []string{"shop/tus/" + uploadID.String() + "/a", "shop/tus/" + uploadID.String() + "/b"}
+	upload := &models.TusUpload{ID: uploadID, TenantID: "shop", OwnerKeyID: &owner.ID, Length: 1000, Offset: 400, Chunks: chunks[:1]}
+	deleted := &models.TusUpload{ID: uploadID, TenantID: "shop", OwnerKeyID: &owner.ID, Length: 1000, Offset: 700, Chunks: chunks}
+
+	tests := []struct {
+		name           string
+		uploadID       string
+		key            *models.APIKey
+		mockGetErr     error
+		mockDeleteErr  error
+		deleted        bool
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Success",
+			uploadID:       uploadID.String(),
+			key:            owner,
+			deleted:        true,
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "Another Key",
+			uploadID:       uploadID.String(),
+			key:            other,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"status":"Error","error":"upload not found"}`,
+		},
+		{
+			name:           "Not Found",
+			uploadID:       uploadID.String(),
+			key:            owner,
+			mockGetErr:     fmt.Errorf("storage.postgres.GetTusUpload: %w", sql.ErrNoRows),
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"status":"Error","error":"upload not found"}`,
+		},
+		{
+			name:           "Deleted Concurrently",
+			uploadID:       uploadID.String(),
+			key:            owner,
+			mockDeleteErr:  fmt.Errorf("storage.postgres.DeleteTusUpload: %w", sql.ErrNoRows),
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"status":"Error","error":"upload not found"}`,
+		},
+		{
+			name:           "Invalid ID",
+			uploadID:       "not-a-uuid",
+			key:            owner,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"Error","error":"invalid upload ID"}`,
+		},
+		{
+			name:           "Storage Error",
+			uploadID:       uploadID.String(),
+			key:            owner,
+			mockDeleteErr:  errors.New("db error"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"status":"Error","error":"failed to delete upload"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uploadDeleterMock := mocks.NewUploadDeleter(t)
+			blobDeleterMock := mocks.NewBlobDeleter(t)
+
+			if tt.uploadID == uploadID.String() {
+				if tt.mockGetErr != nil {
+					uploadDeleterMock.On("GetTusUpload", mock.Anything, uploadID).Return(nil, tt.mockGetErr).Once()
+				} else {
+					uploadDeleterMock.On("GetTusUpload", mock.Anything, uploadID).Return(upload, nil).Once()
+				}
+			}
+			if tt.deleted || tt.mockDeleteErr != nil {
+				if tt.mockDeleteErr != nil {
+					uploadDeleterMock.On("DeleteTusUpload", mock.Anything, uploadID).Return(nil, tt.mockDeleteErr).Once()
+				} else {
+					uploadDeleterMock.On("DeleteTusUpload", mock.Anything, uploadID).Return(deleted, nil).Once()
+				}
+			}
+			if tt.deleted {
+				// Every chunk of the deleted row goes, including those
+				// received after the upload was read.
+				for _, chunk := range chunks {
+					blobDeleterMock.On("Delete", mock.Anything, chunk).Return(nil).Once()
+				}
+			}
+
+			req := httptest.NewRequest(http.MethodDelete, "/uploads/tus/"+tt.uploadID, nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", tt.uploadID)
+			req = req.WithContext(apikey.WithKey(context.WithValue(req.Context(), chi.RouteCtxKey, rctx), tt.key))
+
+			rr := httptest.NewRecorder()
+
+			handler := deleteUpload.New(log, uploadDeleterMock, blobDeleterMock)
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedBody != "" {
+				require.JSONEq(t, tt.expectedBody, rr.Body.String())
+			}
+		})
+	}
+}
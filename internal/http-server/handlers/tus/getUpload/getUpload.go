@@ -0,0 +1,86 @@
+package getUpload
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/lib/logger/sl"
+	"imageProcessor/internal/lib/tus"
+	"imageProcessor/internal/models"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=UploadGetter
+type UploadGetter interface {
+	GetTusUpload(ctx context.Context, id uuid.UUID) (*models.TusUpload, error)
+}
+
+// GetUpload reports how much of a resumable upload has been received. As
+// the response to a HEAD request it carries headers only.
+// @Summary      Get the offset of a resumable upload
+// @Description  Returns the offset to resume a tus upload from in Upload-Offset, with Upload-Length, Upload-Metadata and Upload-Expires. Once the upload is complete, X-Image-ID holds the ID of the image created from it.
+// @Tags         tus
+// @Security     ApiKeyAuth
+// @Param        Tus-Resumable  header  string  true  "1.0.0"
+// @Param        id             path    string  true  "Upload ID"
+// @Success      200
+// @Failure      400
+// @Failure      401
+// @Failure      403
+// @Failure      404
+// @Failure      412
+// @Failure      500
+// @Router       /uploads/tus/{id} [head]
+func New(log *slog.Logger, uploadGetter UploadGetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.tus.getUpload.New"
+
+		log := log.With(slog.String("op", op))
+
+		// Responses must not be cached, or a client would resume from a
+		// stale offset.
+		w.Header().Set("Cache-Control", "no-store")
+
+		uploadID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			log.Error("failed to parse upload ID", sl.Err(err))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		upload, err := uploadGetter.GetTusUpload(r.Context(), uploadID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				log.Warn("upload not found", slog.String("upload_id", uploadID.String()))
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+
+			log.Error("failed to get upload", sl.Err(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if !apikey.CanAccess(r.Context(), upload.OwnerKeyID) {
+			log.Warn("upload belongs to another api key", slog.String("upload_id", uploadID.String()))
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		tus.SetProgress(w.Header(), upload.Offset, upload.ExpiresAt)
+		w.Header().Set(tus.HeaderUploadLength, strconv.FormatInt(upload.Length, 10))
+		if upload.Metadata != "" {
+			w.Header().Set(tus.HeaderUploadMetadata, upload.Metadata)
+		}
+		if upload.ImageID != nil {
+			w.Header().Set(tus.HeaderImageID, upload.ImageID.String())
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
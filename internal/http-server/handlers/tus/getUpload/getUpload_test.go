@@ -0,0 +1,135 @@
+package getUpload_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"imageProcessor/internal/http-server/handlers/tus/getUpload"
+	"imageProcessor/internal/http-server/handlers/tus/getUpload/mocks"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/lib/tus"
+	"imageProcessor/internal/models"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGetUpload(t *testing.T) {
+	log := slog.New(slog.NewJSONHandler(bytes.NewBuffer(nil), nil))
+
+	owner := &models.APIKey{ID: uuid.New(), TenantID: "shop", Scopes: []string{apikey.ScopeUpload}}
+	other := &models.APIKey{ID: uuid.New(), TenantID: "shop", Scopes: []string{apikey.ScopeUpload}}
+	admin := &models.APIKey{ID: uuid.New(), TenantID: "shop", Scopes: []string{apikey.ScopeAdmin}}
+
+	uploadID := uuid.New()
+	imageID := uuid.New()
+	expiresAt := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	upload := &models.TusUpload{ID: uploadID, TenantID: "shop", OwnerKeyID: &owner.ID, Length: 1000, Offset: 400, Metadata: "filename Y2F0LmpwZw==", ExpiresAt: expiresAt}
+	completed := &models.TusUpload{ID: uploadID, TenantID: "shop", OwnerKeyID: &owner.ID, Length: 1000, Offset: 1000, ImageID: &imageID, ExpiresAt: expiresAt}
+
+	tests := []struct {
+		name            string
+		uploadID        string
+		key             *models.APIKey
+		mockUpload      *models.TusUpload
+		mockErr         error
+		expectedStatus  int
+		expectedHeaders map[string]string
+	}{
+		{
+			name:           "In Progress",
+			uploadID:       uploadID.String(),
+			key:            owner,
+			mockUpload:     upload,
+			expectedStatus: http.StatusOK,
+			expectedHeaders: map[string]string{
+				tus.HeaderUploadOffset:   "400",
+				tus.HeaderUploadLength:   "1000",
+				tus.HeaderUploadMetadata: "filename Y2F0LmpwZw==",
+				tus.HeaderUploadExpires:  "Wed, 02 Jan 2030 03:04:05 GMT",
+				tus.HeaderImageID:        "",
+				"Cache-Control":          "no-store",
+			},
+		},
+		{
+			name:           "Completed",
+			uploadID:       uploadID.String(),
+			key:            owner,
+			mockUpload:     completed,
+			expectedStatus: http.StatusOK,
+			expectedHeaders: map[string]string{
+				tus.HeaderUploadOffset:   "1000",
+				tus.HeaderUploadMetadata: "",
+				tus.HeaderImageID:        imageID.String(),
+			},
+		},
+		{
+			name:           "Admin",
+			uploadID:       uploadID.String(),
+			key:            admin,
+			mockUpload:     upload,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Another Key",
+			uploadID:       uploadID.String(),
+			key:            other,
+			mockUpload:     upload,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Not Found",
+			uploadID:       uploadID.String(),
+			key:            owner,
+			mockErr:        fmt.Errorf("storage.postgres.GetTusUpload: %w", sql.ErrNoRows),
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Invalid ID",
+			uploadID:       "not-a-uuid",
+			key:            owner,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Storage Error",
+			uploadID:       uploadID.String(),
+			key:            owner,
+			mockErr:        errors.New("db error"),
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uploadGetterMock := mocks.NewUploadGetter(t)
+
+			if tt.mockUpload != nil || tt.mockErr != nil {
+				uploadGetterMock.On("GetTusUpload", mock.Anything, uploadID).Return(tt.mockUpload, tt.mockErr).Once()
+			}
+
+			req := httptest.NewRequest(http.MethodHead, "/uploads/tus/"+tt.uploadID, nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", tt.uploadID)
+			req = req.WithContext(apikey.WithKey(context.WithValue(req.Context(), chi.RouteCtxKey, rctx), tt.key))
+
+			rr := httptest.NewRecorder()
+
+			handler := getUpload.New(log, uploadGetterMock)
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+			require.Empty(t, rr.Body.String())
+			for k, v := range tt.expectedHeaders {
+				require.Equal(t, v, rr.Header().Get(k), k)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+// Code generated by mockery v2.51.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	mock "github.com/stretchr/testify/mock"
+
+	quota "imageProcessor/internal/lib/quota"
+
+	uuid "github.com/google/uuid"
+)
+
+// QuotaResolver is an autogenerated mock type for the QuotaResolver type
+type QuotaResolver struct {
+	mock.Mock
+}
+
+// For provides a mock function with given fields: tenantID, keyID
+func (_m *QuotaResolver) For(tenantID string, keyID *uuid.UUID) quota.Set {
+	ret := _m.Called(tenantID, keyID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for For")
+	}
+
+	var r0 quota.Set
+	if rf, ok := ret.Get(0).(func(string, *uuid.UUID) quota.Set); ok {
+		r0 = rf(tenantID, keyID)
+	} else {
+		r0 = ret.Get(0).(quota.Set)
+	}
+
+	return r0
+}
+
+// NewQuotaResolver creates a new instance of QuotaResolver. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewQuotaResolver(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *QuotaResolver {
+	mock := &QuotaResolver{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
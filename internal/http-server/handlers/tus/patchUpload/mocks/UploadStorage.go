@@ -0,0 +1,228 @@
+// Code generated by mockery v2.51.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	models "imageProcessor/internal/models"
+
+	mock "github.com/stretchr/testify/mock"
+
+	quota "imageProcessor/internal/lib/quota"
+
+	time "time"
+
+	uuid "github.com/google/uuid"
+)
+
+// UploadStorage is an autogenerated mock type for the UploadStorage type
+type UploadStorage struct {
+	mock.Mock
+}
+
+// AppendTusChunk provides a mock function with given fields: ctx, id, offset, chunk, size, expiresAt
+func (_m *UploadStorage) AppendTusChunk(ctx context.Context, id uuid.UUID, offset int64, chunk string, size int64, expiresAt time.Time) (*models.TusUpload, error) {
+	ret := _m.Called(ctx, id, offset, chunk, size, expiresAt)
+
+	if len(ret) == 0 {
+		panic("no return value specified for AppendTusChunk")
+	}
+
+	var r0 *models.TusUpload
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, int64, string, int64, time.Time) (*models.TusUpload, error)); ok {
+		return rf(ctx, id, offset, chunk, size, expiresAt)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, int64, string, int64, time.Time) *models.TusUpload); ok {
+		r0 = rf(ctx, id, offset, chunk, size, expiresAt)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.TusUpload)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID, int64, string, int64, time.Time) error); ok {
+		r1 = rf(ctx, id, offset, chunk, size, expiresAt)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CompleteTusUpload provides a mock function with given fields: ctx, id, imageID
+func (_m *UploadStorage) CompleteTusUpload(ctx context.Context, id uuid.UUID, imageID uuid.UUID) error {
+	ret := _m.Called(ctx, id, imageID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CompleteTusUpload")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, uuid.UUID) error); ok {
+		r0 = rf(ctx, id, imageID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteTusUpload provides a mock function with given fields: ctx, id
+func (_m *UploadStorage) DeleteTusUpload(ctx context.Context, id uuid.UUID) (*models.TusUpload, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteTusUpload")
+	}
+
+	var r0 *models.TusUpload
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) (*models.TusUpload, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) *models.TusUpload); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.TusUpload)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetTusUpload provides a mock function with given fields: ctx, id
+func (_m *UploadStorage) GetTusUpload(ctx context.Context, id uuid.UUID) (*models.TusUpload, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetTusUpload")
+	}
+
+	var r0 *models.TusUpload
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) (*models.TusUpload, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) *models.TusUpload); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.TusUpload)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUsage provides a mock function with given fields: ctx, keyID
+func (_m *UploadStorage) GetUsage(ctx context.Context, keyID *uuid.UUID) (models.Usage, *models.Usage, error) {
+	ret := _m.Called(ctx, keyID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetUsage")
+	}
+
+	var r0 models.Usage
+	var r1 *models.Usage
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, *uuid.UUID) (models.Usage, *models.Usage, error)); ok {
+		return rf(ctx, keyID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *uuid.UUID) models.Usage); ok {
+		r0 = rf(ctx, keyID)
+	} else {
+		r0 = ret.Get(0).(models.Usage)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *uuid.UUID) *models.Usage); ok {
+		r1 = rf(ctx, keyID)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(*models.Usage)
+		}
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, *uuid.UUID) error); ok {
+		r2 = rf(ctx, keyID)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// RevertTusChunk provides a mock function with given fields: ctx, id, chunk, size
+func (_m *UploadStorage) RevertTusChunk(ctx context.Context, id uuid.UUID, chunk string, size int64) error {
+	ret := _m.Called(ctx, id, chunk, size)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RevertTusChunk")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, string, int64) error); ok {
+		r0 = rf(ctx, id, chunk, size)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SaveImage provides a mock function with given fields: ctx, upload, limits
+func (_m *UploadStorage) SaveImage(ctx context.Context, upload models.Upload, limits quota.Set) (*models.Image, error) {
+	ret := _m.Called(ctx, upload, limits)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SaveImage")
+	}
+
+	var r0 *models.Image
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.Upload, quota.Set) (*models.Image, error)); ok {
+		return rf(ctx, upload, limits)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, models.Upload, quota.Set) *models.Image); ok {
+		r0 = rf(ctx, upload, limits)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.Image)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, models.Upload, quota.Set) error); ok {
+		r1 = rf(ctx, upload, limits)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewUploadStorage creates a new instance of UploadStorage. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewUploadStorage(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *UploadStorage {
+	mock := &UploadStorage{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
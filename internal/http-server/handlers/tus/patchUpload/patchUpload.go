@@ -0,0 +1,407 @@
+package patchUpload
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"imageProcessor/internal/ingest"
+	"imageProcessor/internal/kafka/producer"
+	"imageProcessor/internal/lib/api/response"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/lib/logger/sl"
+	"imageProcessor/internal/lib/quota"
+	"imageProcessor/internal/lib/tenant"
+	"imageProcessor/internal/lib/tus"
+	"imageProcessor/internal/models"
+	"imageProcessor/internal/storage"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"time"
+)
+
+const chunkDir = "tus"
+
+var errChunkTooLarge = errors.New("chunk exceeds upload length")
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=UploadStorage
+type UploadStorage interface {
+	GetTusUpload(ctx context.Context, id uuid.UUID) (*models.TusUpload, error)
+	AppendTusChunk(ctx context.Context, id uuid.UUID, offset int64, chunk string, size int64, expiresAt time.Time) (*models.TusUpload, error)
+	RevertTusChunk(ctx context.Context, id uuid.UUID, chunk string, size int64) error
+	CompleteTusUpload(ctx context.Context, id uuid.UUID, imageID uuid.UUID) error
+	DeleteTusUpload(ctx context.Context, id uuid.UUID) (*models.TusUpload, error)
+	SaveImage(ctx context.Context, upload models.Upload, limits quota.Set) (*models.Image, error)
+	GetUsage(ctx context.Context, keyID *uuid.UUID) (models.Usage, *models.Usage, error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=BlobStorage
+type BlobStorage interface {
+	Put(ctx context.Context, key string, r io.Reader) (*storage.BlobInfo, error)
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, *storage.BlobInfo, error)
+	Delete(ctx context.Context, key string) error
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=QuotaResolver
+type QuotaResolver interface {
+	For(tenantID string, keyID *uuid.UUID) quota.Set
+}
+
+// PatchUpload receives a chunk of a resumable upload.
+// @Summary      Send a chunk of a resumable upload
+// @Description  Appends the body to a tus upload at Upload-Offset, which must be where the upload currently ends. If the connection breaks, the bytes received are kept and the upload can be resumed from the offset HEAD reports. The chunk completing the upload turns it into an image, which is processed and deduplicated like an upload to POST /upload; its ID is returned in X-Image-ID. An upload whose leading bytes are not those of a supported image is deleted with its chunks and answered with 415. If the key has an image of the same content, that image's ID is returned instead, or, if duplicates are rejected, sent with 409.
+// @Tags         tus
+// @Accept       application/offset+octet-stream
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        Tus-Resumable  header  string  true  "1.0.0"
+// @Param        Upload-Offset  header  int     true  "Offset the chunk starts at"
+// @Param        id             path    string  true  "Upload ID"
+// @Success      204
+// @Failure      400  {object}  response.Response
+// @Failure      401  {object}  response.Response
+// @Failure      403  {object}  response.Response
+// @Failure      404  {object}  response.Response
+// @Failure      409  {object}  response.Response
+// @Failure      412  {object}  response.Response
+// @Failure      413  {object}  response.Response
+// @Failure      415  {object}  response.Response
+// @Failure      429  {object}  response.Response
+// @Failure      500  {object}  response.Response
+// @Failure      507  {object}  response.Response
+// @Router       /uploads/tus/{id} [patch]
+func New(log *slog.Logger, uploadStorage UploadStorage, blobStorage BlobStorage, quotas QuotaResolver, kafkaProducer producer.ProducerIface, expiration, chunkTimeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.tus.patchUpload.New"
+
+		log := log.With(slog.String("op", op))
+
+		if contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); contentType != tus.ContentTypeChunk {
+			log.Error("invalid content type", slog.String("content_type", r.Header.Get("Content-Type")))
+			render.Status(r, http.StatusUnsupportedMediaType)
+			render.JSON(w, r, response.Error("content type must be "+tus.ContentTypeChunk))
+			return
+		}
+
+		offset, ok := tus.ParseSize(r.Header.Get(tus.HeaderUploadOffset))
+		if !ok {
+			log.Error("invalid upload offset", slog.String("offset", r.Header.Get(tus.HeaderUploadOffset)))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error("invalid Upload-Offset"))
+			return
+		}
+
+		uploadID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			log.Error("failed to parse upload ID", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error("invalid upload ID"))
+			return
+		}
+
+		upload, err := uploadStorage.GetTusUpload(r.Context(), uploadID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				log.Warn("upload not found", slog.String("upload_id", uploadID.String()))
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, response.Error("upload not found"))
+				return
+			}
+
+			log.Error("failed to get upload", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to save chunk"))
+			return
+		}
+
+		if !apikey.CanAccess(r.Context(), upload.OwnerKeyID) {
+			log.Warn("upload belongs to another api key", slog.String("upload_id", uploadID.String()))
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, response.Error("upload not found"))
+			return
+		}
+
+		if upload.ImageID != nil || offset != upload.Offset {
+			log.Warn("chunk at wrong offset", slog.Int64("offset", offset), slog.Int64("upload_offset", upload.Offset))
+			render.Status(r, http.StatusConflict)
+			render.JSON(w, r, response.Error("upload offset mismatch"))
+			return
+		}
+
+		remaining := upload.Length - offset
+		if r.ContentLength > remaining {
+			log.Error("chunk exceeds upload length", slog.Int64("size", r.ContentLength), slog.Int64("remaining", remaining))
+			render.Status(r, http.StatusRequestEntityTooLarge)
+			render.JSON(w, r, response.Error(errChunkTooLarge.Error()))
+			return
+		}
+
+		// The server's timeouts are meant for short requests, not for
+		// chunks trickling in over a slow link.
+		rc := http.NewResponseController(w)
+		_ = rc.SetReadDeadline(time.Now().Add(chunkTimeout))
+		_ = rc.SetWriteDeadline(time.Now().Add(chunkTimeout))
+
+		// Whatever arrives is kept even if the client goes away meanwhile.
+		ctx := context.WithoutCancel(r.Context())
+
+		body := &chunkReader{r: r.Body, remaining: remaining}
+		chunk, err := blobStorage.Put(ctx, tenant.BlobKey(upload.TenantID, chunkDir, upload.ID.String(), uuid.NewString()), body)
+		if err != nil {
+			if errors.Is(err, errChunkTooLarge) {
+				log.Error("chunk exceeds upload length", slog.Int64("remaining", remaining))
+				render.Status(r, http.StatusRequestEntityTooLarge)
+				render.JSON(w, r, response.Error(errChunkTooLarge.Error()))
+				return
+			}
+
+			log.Error("failed to store chunk", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to save chunk"))
+			return
+		}
+		if body.err != nil {
+			log.Warn("chunk broke off", slog.Int64("received", chunk.Size), sl.Err(body.err))
+		}
+
+		if chunk.Size == 0 {
+			if err := blobStorage.Delete(ctx, chunk.Key); err != nil {
+				log.Error("failed to remove empty chunk", slog.String("key", chunk.Key), sl.Err(err))
+			}
+			tus.SetProgress(w.Header(), upload.Offset, upload.ExpiresAt)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		upload, err = uploadStorage.AppendTusChunk(ctx, upload.ID, offset, chunk.Key, chunk.Size, time.Now().Add(expiration))
+		if err != nil {
+			if err := blobStorage.Delete(ctx, chunk.Key); err != nil {
+				log.Error("failed to remove chunk", slog.String("key", chunk.Key), sl.Err(err))
+			}
+
+			if errors.Is(err, storage.ErrOffsetMismatch) {
+				log.Warn("concurrent chunk at the same offset", slog.Int64("offset", offset))
+				render.Status(r, http.StatusConflict)
+				render.JSON(w, r, response.Error("upload offset mismatch"))
+				return
+			}
+
+			log.Error("failed to record chunk", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to save chunk"))
+			return
+		}
+
+		if upload.Offset < upload.Length {
+			tus.SetProgress(w.Header(), upload.Offset, upload.ExpiresAt)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		c := completer{
+			log:           log,
+			uploadStorage: uploadStorage,
+			blobStorage:   blobStorage,
+			ingester:      ingest.New(uploadStorage, blobStorage, quotas, kafkaProducer),
+		}
+		c.complete(ctx, w, r, upload, chunk)
+	}
+}
+
+// completer turns a complete upload into an image, as POST /upload would.
+type completer struct {
+	log           *slog.Logger
+	uploadStorage UploadStorage
+	blobStorage   BlobStorage
+	ingester      *ingest.Ingester
+}
+
+func (c *completer) complete(ctx context.Context, w http.ResponseWriter, r *http.Request, upload *models.TusUpload, last *storage.BlobInfo) {
+	log := c.log.With(slog.String("upload_id", upload.ID.String()))
+
+	chunks := &joinedChunks{ctx: ctx, blobs: c.blobStorage, keys: upload.Chunks}
+	res, err := c.ingester.Ingest(ctx, ingest.Source{
+		TenantID:    upload.TenantID,
+		OwnerKeyID:  upload.OwnerKeyID,
+		Filename:    upload.Filename,
+		Size:        upload.Length,
+		Body:        chunks,
+		CallbackURL: upload.CallbackURL,
+	})
+	chunks.Close()
+
+	// Nothing sent again can make an upload of another type an image, so
+	// it is dropped rather than taken back a chunk.
+	if errors.Is(err, ingest.ErrUnsupportedType) {
+		log.Warn("rejected upload of unknown type")
+		c.discard(ctx, upload)
+		render.Status(r, http.StatusUnsupportedMediaType)
+		render.JSON(w, r, response.Error("unsupported image type"))
+		return
+	}
+
+	if err != nil && res.Image == nil {
+		c.revert(ctx, upload, last)
+
+		switch {
+		case errors.Is(err, quota.ErrStorageExceeded) || errors.Is(err, quota.ErrRateExceeded):
+			log.Warn("upload rejected by quota", slog.String("tenant_id", upload.TenantID), sl.Err(err))
+			quota.WriteExceeded(w, r, err, res.Scopes, res.Now)
+		case errors.Is(err, ingest.ErrSave):
+			log.Error("failed to save image metadata", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to save image metadata"))
+		default:
+			log.Error("failed to join chunks", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to save file"))
+		}
+		return
+	}
+
+	// The key's image of the same content stands for the upload, unless
+	// duplicates are rejected.
+	if res.Duplicate != nil {
+		if res.Duplicate.Rejected {
+			log.Warn("duplicate upload rejected", slog.String("image_id", res.Image.ID.String()))
+			c.revert(ctx, upload, last)
+			w.Header().Set(tus.HeaderImageID, res.Image.ID.String())
+			render.Status(r, http.StatusConflict)
+			render.JSON(w, r, response.Error("duplicate image"))
+			return
+		}
+
+		log.Info("upload duplicates an image", slog.String("image_id", res.Image.ID.String()))
+	}
+
+	log.Info("upload complete", slog.String("image_id", res.Image.ID.String()), slog.String("format", res.Format.Name))
+
+	if err := c.uploadStorage.CompleteTusUpload(ctx, upload.ID, res.Image.ID); err != nil {
+		log.Error("failed to mark upload complete", sl.Err(err))
+	} else {
+		c.removeChunks(ctx, upload.Chunks)
+	}
+
+	w.Header().Set(tus.HeaderImageID, res.Image.ID.String())
+	tus.SetProgress(w.Header(), upload.Offset, upload.ExpiresAt)
+
+	if errors.Is(err, ingest.ErrEnqueue) {
+		log.Error("failed to publish message to kafka", sl.Err(err))
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, response.Error("failed to start image processing"))
+		return
+	}
+
+	quota.SetHeaders(w.Header(), quota.NewStatus(res.Scopes, res.Now), res.Now)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// discard deletes an upload that can't become an image, with its chunks.
+// The chunks are taken from the deleted row, which is the last word on them.
+func (c *completer) discard(ctx context.Context, upload *models.TusUpload) {
+	deleted, err := c.uploadStorage.DeleteTusUpload(ctx, upload.ID)
+	if err != nil {
+		c.log.Error("failed to delete upload", slog.String("upload_id", upload.ID.String()), sl.Err(err))
+		return
+	}
+
+	c.removeChunks(ctx, deleted.Chunks)
+}
+
+func (c *completer) removeChunks(ctx context.Context, chunks []string) {
+	for _, chunk := range chunks {
+		if err := c.blobStorage.Delete(ctx, chunk); err != nil {
+			c.log.Error("failed to remove chunk", slog.String("key", chunk), sl.Err(err))
+		}
+	}
+}
+
+// revert takes back the last chunk of an upload that could not be turned
+// into an image, so that the client can send it again.
+func (c *completer) revert(ctx context.Context, upload *models.TusUpload, last *storage.BlobInfo) {
+	if err := c.uploadStorage.RevertTusChunk(ctx, upload.ID, last.Key, last.Size); err != nil {
+		c.log.Error("failed to revert last chunk", slog.String("upload_id", upload.ID.String()), sl.Err(err))
+		return
+	}
+
+	if err := c.blobStorage.Delete(ctx, last.Key); err != nil {
+		c.log.Error("failed to remove chunk", slog.String("key", last.Key), sl.Err(err))
+	}
+}
+
+// chunkReader passes on at most remaining bytes of a PATCH body. A body that
+// breaks off ends the chunk early rather than failing it, so that the upload
+// can be resumed from the bytes that got through.
+type chunkReader struct {
+	r         io.Reader
+	remaining int64
+	err       error
+}
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > c.remaining+1 {
+		p = p[:c.remaining+1]
+	}
+
+	n, err := c.r.Read(p)
+	c.remaining -= int64(n)
+	if c.remaining < 0 {
+		return n + int(c.remaining), errChunkTooLarge
+	}
+	if err != nil && err != io.EOF {
+		c.err = err
+		return n, io.EOF
+	}
+
+	return n, err
+}
+
+// joinedChunks reads the chunks of an upload one after another, opening
+// each only once the previous one is done.
+type joinedChunks struct {
+	ctx     context.Context
+	blobs   BlobStorage
+	keys    []string
+	current io.ReadCloser
+}
+
+func (j *joinedChunks) Read(p []byte) (int, error) {
+	for {
+		if j.current == nil {
+			if len(j.keys) == 0 {
+				return 0, io.EOF
+			}
+
+			rc, _, err := j.blobs.Open(j.ctx, j.keys[0])
+			if err != nil {
+				return 0, err
+			}
+			j.current, j.keys = rc, j.keys[1:]
+		}
+
+		n, err := j.current.Read(p)
+		if err == io.EOF {
+			_ = j.current.Close()
+			j.current = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+
+		return n, err
+	}
+}
+
+// Close releases the chunk being read, if Put gave up in the middle of it.
+func (j *joinedChunks) Close() {
+	if j.current != nil {
+		_ = j.current.Close()
+		j.current = nil
+	}
+}
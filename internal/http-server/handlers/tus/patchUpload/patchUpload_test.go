@@ -0,0 +1,454 @@
+package patchUpload_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"imageProcessor/internal/http-server/handlers/tus/patchUpload"
+	"imageProcessor/internal/http-server/handlers/tus/patchUpload/mocks"
+	kafkaMocks "imageProcessor/internal/kafka/producer/mocks"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/lib/quota"
+	"imageProcessor/internal/lib/tenant"
+	"imageProcessor/internal/lib/tus"
+	"imageProcessor/internal/models"
+	"imageProcessor/internal/storage"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryBlobs keeps blobs in memory.
+type memoryBlobs struct {
+	mu    sync.Mutex
+	blobs map[string][]byte
+}
+
+func (m *memoryBlobs) Put(_ context.Context, key string, r io.Reader) (*storage.BlobInfo, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("storage.memory.Put: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.blobs[key] = data
+
+	sum := sha256.Sum256(data)
+	return &storage.BlobInfo{Key: key, Size: int64(len(data)), Checksum: hex.EncodeToString(sum[:])}, nil
+}
+
+func (m *memoryBlobs) Open(_ context.Context, key string) (io.ReadSeekCloser, *storage.BlobInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	data, ok := m.blobs[key]
+	if !ok {
+		return nil, nil, storage.ErrBlobNotFound
+	}
+
+	return nopCloser{bytes.NewReader(data)}, &storage.BlobInfo{Key: key, Size: int64(len(data))}, nil
+}
+
+func (m *memoryBlobs) Delete(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.blobs, key)
+
+	return nil
+}
+
+// keys returns the keys of the blobs with the given prefix.
+func (m *memoryBlobs) keys(prefix string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var keys []string
+	for key := range m.blobs {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+type nopCloser struct {
+	io.ReadSeeker
+}
+
+func (nopCloser) Close() error { return nil }
+
+// brokenBody sends data, then fails as a dropped connection would.
+type brokenBody struct {
+	data []byte
+}
+
+func (b *brokenBody) Read(p []byte) (int, error) {
+	if len(b.data) == 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	n := copy(p, b.data)
+	b.data = b.data[n:]
+
+	return n, nil
+}
+
+func TestPatchUpload(t *testing.T) {
+	log := slog.New(slog.NewJSONHandler(bytes.NewBuffer(nil), nil))
+
+	testKey := &models.APIKey{ID: uuid.New(), TenantID: "shop", Scopes: []string{apikey.ScopeUpload}}
+	uploadID := uuid.New()
+	imageID := uuid.New()
+	firstChunk := "shop/tus/" + uploadID.String() + "/first"
+	chunkPrefix := "shop/tus/" + uploadID.String() + "/"
+	// Uploads are told apart by their leading bytes, a JPEG's here.
+	jpeg := "\xff\xd8\xff\xe0"
+	sum := sha256.Sum256([]byte(jpeg + "efghij"))
+	checksum := hex.EncodeToString(sum[:])
+
+	started := func() *models.TusUpload {
+		return &models.TusUpload{
+			ID:         uploadID,
+			TenantID:   "shop",
+			OwnerKeyID: &testKey.ID,
+			Length:     10,
+			Offset:     4,
+			Filename:   "cat.JPG",
+			Chunks:     []string{firstChunk},
+			ExpiresAt:  time.Now().Add(time.Hour),
+		}
+	}
+
+	tests := []struct {
+		name            string
+		contentType     string
+		offset          string
+		body            io.Reader
+		upload          *models.TusUpload
+		mockGetErr      error
+		mockAppendErr   error
+		limits          quota.Set
+		duplicate       *storage.DuplicateError
+		mockKafkaErr    error
+		first           string
+		expectedChunk   string
+		last            bool
+		completes       bool
+		expectedStatus  int
+		expectedOffset  string
+		expectedError   string
+		expectedChunks  int
+		expectedStored  string
+		expectedImageID bool
+	}{
+		{
+			name:           "Chunk",
+			offset:         "4",
+			body:           strings.NewReader("efg"),
+			upload:         started(),
+			expectedChunk:  "efg",
+			expectedStatus: http.StatusNoContent,
+			expectedOffset: "7",
+			expectedChunks: 2,
+		},
+		{
+			name:            "Last Chunk",
+			offset:          "4",
+			body:            strings.NewReader("efghij"),
+			upload:          started(),
+			expectedChunk:   "efghij",
+			last:            true,
+			completes:       true,
+			expectedStatus:  http.StatusNoContent,
+			expectedOffset:  "10",
+			expectedStored:  jpeg + "efghij",
+			expectedImageID: true,
+		},
+		{
+			name:           "Broken Off",
+			offset:         "4",
+			body:           &brokenBody{data: []byte("ef")},
+			upload:         started(),
+			expectedChunk:  "ef",
+			expectedStatus: http.StatusNoContent,
+			expectedOffset: "6",
+			expectedChunks: 2,
+		},
+		{
+			name:           "Empty Chunk",
+			offset:         "4",
+			body:           strings.NewReader(""),
+			upload:         started(),
+			expectedStatus: http.StatusNoContent,
+			expectedOffset: "4",
+			expectedChunks: 1,
+		},
+		{
+			name:           "Announced Too Large",
+			offset:         "4",
+			body:           strings.NewReader("efghijk"),
+			upload:         started(),
+			expectedStatus: http.StatusRequestEntityTooLarge,
+			expectedError:  "chunk exceeds upload length",
+			expectedChunks: 1,
+		},
+		{
+			name:           "Streamed Too Large",
+			offset:         "4",
+			body:           io.MultiReader(strings.NewReader("efghijk")),
+			upload:         started(),
+			expectedStatus: http.StatusRequestEntityTooLarge,
+			expectedError:  "chunk exceeds upload length",
+			expectedChunks: 1,
+		},
+		{
+			name:           "Wrong Offset",
+			offset:         "3",
+			body:           strings.NewReader("defg"),
+			upload:         started(),
+			expectedStatus: http.StatusConflict,
+			expectedError:  "upload offset mismatch",
+			expectedChunks: 1,
+		},
+		{
+			name:           "Concurrent Chunk",
+			offset:         "4",
+			body:           strings.NewReader("efg"),
+			upload:         started(),
+			mockAppendErr:  fmt.Errorf("storage.postgres.AppendTusChunk: %w", storage.ErrOffsetMismatch),
+			expectedChunk:  "efg",
+			expectedStatus: http.StatusConflict,
+			expectedError:  "upload offset mismatch",
+			expectedChunks: 1,
+		},
+		{
+			name:           "Already Complete",
+			offset:         "10",
+			body:           strings.NewReader(""),
+			upload:         &models.TusUpload{ID: uploadID, TenantID: "shop", OwnerKeyID: &testKey.ID, Length: 10, Offset: 10, ImageID: &imageID},
+			expectedStatus: http.StatusConflict,
+			expectedError:  "upload offset mismatch",
+			expectedChunks: 1,
+		},
+		{
+			name:           "Quota Exceeded on Completion",
+			offset:         "4",
+			body:           strings.NewReader("efghij"),
+			upload:         started(),
+			limits:         quota.Set{Tenant: quota.Limits{MaxImages: 1}},
+			expectedChunk:  "efghij",
+			last:           true,
+			expectedStatus: http.StatusInsufficientStorage,
+			expectedError:  "storage quota exceeded",
+			expectedChunks: 1,
+		},
+		{
+			name:            "Duplicate Returned",
+			offset:          "4",
+			body:            strings.NewReader("efghij"),
+			upload:          started(),
+			duplicate:       &storage.DuplicateError{Image: &models.Image{ID: imageID, TenantID: "shop", OriginalPath: "shop/uploads/existing.jpg"}},
+			expectedChunk:   "efghij",
+			last:            true,
+			completes:       true,
+			expectedStatus:  http.StatusNoContent,
+			expectedOffset:  "10",
+			expectedStored:  jpeg + "efghij",
+			expectedImageID: true,
+		},
+		{
+			name:            "Duplicate Rejected",
+			offset:          "4",
+			body:            strings.NewReader("efghij"),
+			upload:          started(),
+			duplicate:       &storage.DuplicateError{Image: &models.Image{ID: imageID, TenantID: "shop", OriginalPath: "shop/uploads/existing.jpg"}, Rejected: true},
+			expectedChunk:   "efghij",
+			last:            true,
+			completes:       true,
+			expectedStatus:  http.StatusConflict,
+			expectedError:   "duplicate image",
+			expectedChunks:  1,
+			expectedStored:  jpeg + "efghij",
+			expectedImageID: true,
+		},
+		{
+			name:            "Processing Not Started",
+			offset:          "4",
+			body:            strings.NewReader("efghij"),
+			upload:          started(),
+			mockKafkaErr:    errors.New("kafka error"),
+			expectedChunk:   "efghij",
+			last:            true,
+			completes:       true,
+			expectedStatus:  http.StatusInternalServerError,
+			expectedError:   "failed to start image processing",
+			expectedStored:  jpeg + "efghij",
+			expectedImageID: true,
+		},
+		{
+			name:           "Not an Image",
+			offset:         "4",
+			body:           strings.NewReader("efghij"),
+			upload:         started(),
+			first:          "abcd",
+			expectedChunk:  "efghij",
+			expectedStatus: http.StatusUnsupportedMediaType,
+			expectedError:  "unsupported image type",
+		},
+		{
+			name:           "Wrong Content Type",
+			contentType:    "application/octet-stream",
+			offset:         "4",
+			body:           strings.NewReader("efg"),
+			expectedStatus: http.StatusUnsupportedMediaType,
+			expectedError:  "content type must be application/offset+octet-stream",
+			expectedChunks: 1,
+		},
+		{
+			name:           "Invalid Offset",
+			offset:         "-1",
+			body:           strings.NewReader("efg"),
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "invalid Upload-Offset",
+			expectedChunks: 1,
+		},
+		{
+			name:           "Not Found",
+			offset:         "4",
+			body:           strings.NewReader("efg"),
+			mockGetErr:     fmt.Errorf("storage.postgres.GetTusUpload: %w", sql.ErrNoRows),
+			expectedStatus: http.StatusNotFound,
+			expectedError:  "upload not found",
+			expectedChunks: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uploadStorageMock := mocks.NewUploadStorage(t)
+			quotaResolverMock := mocks.NewQuotaResolver(t)
+			kafkaProducerMock := kafkaMocks.NewProducerIface(t)
+			first := tt.first
+			if first == "" {
+				first = jpeg
+			}
+			blobs := &memoryBlobs{blobs: map[string][]byte{firstChunk: []byte(first)}}
+			var appended []string
+
+			if tt.upload != nil || tt.mockGetErr != nil {
+				uploadStorageMock.On("GetTusUpload", mock.Anything, uploadID).Return(tt.upload, tt.mockGetErr).Once()
+			}
+
+			if tt.expectedChunk != "" {
+				uploadStorageMock.On("AppendTusChunk", mock.Anything, uploadID, int64(4), mock.Anything, int64(len(tt.expectedChunk)), mock.Anything).
+					Return(func(_ context.Context, _ uuid.UUID, offset int64, chunk string, size int64, expiresAt time.Time) (*models.TusUpload, error) {
+						if tt.mockAppendErr != nil {
+							return nil, tt.mockAppendErr
+						}
+						require.True(t, strings.HasPrefix(chunk, chunkPrefix), chunk)
+						require.Equal(t, tt.expectedChunk, string(blobs.blobs[chunk]))
+
+						appended = append(appended, chunk)
+						upload := started()
+						upload.Offset += size
+						upload.Chunks = append(upload.Chunks, chunk)
+						upload.ExpiresAt = expiresAt
+						return upload, nil
+					}).Once()
+			}
+
+			if tt.last {
+				quotaResolverMock.On("For", "shop", &testKey.ID).Return(tt.limits).Once()
+				uploadStorageMock.On("GetUsage", mock.Anything, &testKey.ID).Return(models.Usage{Images: 1}, &models.Usage{}, nil).Once()
+			}
+			if tt.expectedStatus == http.StatusInsufficientStorage || tt.duplicate != nil && tt.duplicate.Rejected {
+				uploadStorageMock.On("RevertTusChunk", mock.Anything, uploadID, mock.Anything, int64(len(tt.expectedChunk))).Return(nil).Once()
+			}
+
+			if tt.expectedStatus == http.StatusUnsupportedMediaType && tt.expectedChunk != "" {
+				uploadStorageMock.On("DeleteTusUpload", mock.Anything, uploadID).
+					Return(func(context.Context, uuid.UUID) (*models.TusUpload, error) {
+						return &models.TusUpload{ID: uploadID, Chunks: append([]string{firstChunk}, appended...)}, nil
+					}).Once()
+			}
+
+			if tt.completes {
+				isUpload := mock.MatchedBy(func(upload models.Upload) bool {
+					return upload.Filename == "cat.JPG" &&
+						strings.HasPrefix(upload.OriginalPath, "shop/uploads/") &&
+						strings.HasSuffix(upload.OriginalPath, ".jpg") &&
+						upload.Size == 10 &&
+						*upload.OwnerKeyID == testKey.ID &&
+						upload.SHA256 == checksum
+				})
+				uploadStorageMock.On("SaveImage", mock.Anything, isUpload, tt.limits).
+					Return(func(_ context.Context, upload models.Upload, _ quota.Set) (*models.Image, error) {
+						require.Equal(t, tt.expectedStored, string(blobs.blobs[upload.OriginalPath]))
+						if tt.duplicate != nil {
+							return nil, fmt.Errorf("storage.postgres.SaveImage: %w", tt.duplicate)
+						}
+						return &models.Image{ID: imageID, TenantID: "shop", OriginalPath: upload.OriginalPath, Size: upload.Size}, nil
+					}).Once()
+			}
+			if tt.completes && (tt.duplicate == nil || !tt.duplicate.Rejected) {
+				uploadStorageMock.On("CompleteTusUpload", mock.Anything, uploadID, imageID).Return(nil).Once()
+			}
+			if tt.completes && tt.duplicate == nil {
+				kafkaProducerMock.On("SendMessage", mock.Anything, mock.Anything).Return(tt.mockKafkaErr).Once()
+			}
+
+			req := httptest.NewRequest(http.MethodPatch, "/uploads/tus/"+uploadID.String(), tt.body)
+			contentType := tt.contentType
+			if contentType == "" {
+				contentType = tus.ContentTypeChunk
+			}
+			req.Header.Set("Content-Type", contentType)
+			req.Header.Set(tus.HeaderUploadOffset, tt.offset)
+
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", uploadID.String())
+			ctx := apikey.WithKey(context.WithValue(req.Context(), chi.RouteCtxKey, rctx), testKey)
+			req = req.WithContext(tenant.WithID(ctx, testKey.TenantID))
+
+			rr := httptest.NewRecorder()
+
+			handler := patchUpload.New(log, uploadStorageMock, blobs, quotaResolverMock, kafkaProducerMock, time.Hour, time.Minute)
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedError != "" {
+				require.JSONEq(t, `{"status":"Error","error":"`+tt.expectedError+`"}`, rr.Body.String())
+			}
+			if tt.expectedOffset != "" {
+				require.Equal(t, tt.expectedOffset, rr.Header().Get(tus.HeaderUploadOffset))
+			}
+			if tt.expectedImageID {
+				require.Equal(t, imageID.String(), rr.Header().Get(tus.HeaderImageID))
+			}
+			// The original of a duplicate is not kept.
+			if tt.expectedImageID && tt.duplicate == nil {
+				require.Len(t, blobs.keys("shop/uploads/"), 1)
+			} else {
+				require.Empty(t, blobs.keys("shop/uploads/"))
+			}
+
+			// Completion removes the chunks; a chunk that wasn't recorded
+			// doesn't stay behind either.
+			require.Len(t, blobs.keys(chunkPrefix), tt.expectedChunks)
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package getUsage
+
+import (
+	"context"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"imageProcessor/internal/lib/api/response"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/lib/logger/sl"
+	"imageProcessor/internal/lib/quota"
+	"imageProcessor/internal/lib/tenant"
+	"imageProcessor/internal/models"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+type Response struct {
+	response.Response
+	Tenant Scope  `json:"tenant"`
+	Key    *Scope `json:"key,omitempty"`
+}
+
+// Scope is the usage of a tenant or an API key next to its limits. A limit
+// of zero means unlimited.
+type Scope struct {
+	ID     string `json:"id"`
+	Usage  Usage  `json:"usage"`
+	Limits Limits `json:"limits"`
+}
+
+type Usage struct {
+	Bytes           int64 `json:"bytes"`
+	Images          int64 `json:"images"`
+	UploadsThisHour int   `json:"uploads_this_hour"`
+}
+
+type Limits struct {
+	MaxBytes       int64 `json:"max_bytes"`
+	MaxImages      int64 `json:"max_images"`
+	UploadsPerHour int   `json:"uploads_per_hour"`
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=UsageGetter
+type UsageGetter interface {
+	GetUsage(ctx context.Context, keyID *uuid.UUID) (models.Usage, *models.Usage, error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=QuotaResolver
+type QuotaResolver interface {
+	For(tenantID string, keyID *uuid.UUID) quota.Set
+}
+
+// GetUsage reports the usage and quotas of the caller.
+// @Summary      Get usage
+// @Description  Returns what the tenant and the calling API key store and upload, next to their quotas. The effective quota is also reported in X-Quota-* headers.
+// @Tags         usage
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {object}  getUsage.Response
+// @Failure      401  {object}  response.Response
+// @Failure      500  {object}  response.Response
+// @Router       /usage [get]
+func New(log *slog.Logger, usageGetter UsageGetter, quotas QuotaResolver) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.usage.getUsage.New"
+
+		log := log.With(slog.String("op", op))
+
+		tenantID, err := tenant.FromContext(r.Context())
+		if err != nil {
+			log.Error("request has no tenant", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to get usage"))
+			return
+		}
+
+		keyID := apikey.OwnerID(r.Context())
+		limits := quotas.For(tenantID, keyID)
+
+		tenantUsage, keyUsage, err := usageGetter.GetUsage(r.Context(), keyID)
+		if err != nil {
+			log.Error("failed to get usage", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to get usage"))
+			return
+		}
+
+		now := time.Now()
+
+		scopes := []quota.Scope{{Limits: limits.Tenant, Usage: tenantUsage}}
+		resp := Response{
+			Response: response.OK(),
+			Tenant:   newScope(tenantID, scopes[0], now),
+		}
+
+		if keyID != nil && keyUsage != nil {
+			scopes = append(scopes, quota.Scope{Limits: limits.Key, Usage: *keyUsage})
+			key := newScope(keyID.String(), scopes[1], now)
+			resp.Key = &key
+		}
+
+		quota.SetHeaders(w.Header(), quota.NewStatus(scopes, now), now)
+
+		render.JSON(w, r, resp)
+	}
+}
+
+func newScope(id string, s quota.Scope, now time.Time) Scope {
+	return Scope{
+		ID: id,
+		Usage: Usage{
+			Bytes:           s.Usage.Bytes,
+			Images:          s.Usage.Images,
+			UploadsThisHour: s.Uploads(now),
+		},
+		Limits: Limits{
+			MaxBytes:       s.Limits.MaxBytes,
+			MaxImages:      s.Limits.MaxImages,
+			UploadsPerHour: s.Limits.UploadsPerHour,
+		},
+	}
+}
@@ -0,0 +1,96 @@
+package getUsage_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"imageProcessor/internal/http-server/handlers/usage/getUsage"
+	"imageProcessor/internal/http-server/handlers/usage/getUsage/mocks"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/lib/quota"
+	"imageProcessor/internal/lib/tenant"
+	"imageProcessor/internal/models"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGetUsage(t *testing.T) {
+	log := slog.New(slog.NewJSONHandler(bytes.NewBuffer(nil), nil))
+
+	testKey := &models.APIKey{ID: uuid.New(), TenantID: "shop", Scopes: []string{apikey.ScopeRead}}
+	limits := quota.Set{
+		Tenant: quota.Limits{MaxBytes: 1000, MaxImages: 10, UploadsPerHour: 5},
+		Key:    quota.Limits{MaxBytes: 500},
+	}
+	tenantUsage := models.Usage{Bytes: 300, Images: 3, WindowStart: time.Now().Add(-time.Minute), WindowUploads: 2}
+	// The key's window is over, so its uploads no longer count.
+	keyUsage := &models.Usage{Bytes: 450, Images: 1, WindowStart: time.Now().Add(-2 * time.Hour), WindowUploads: 4}
+
+	tests := []struct {
+		name            string
+		mockErr         error
+		expectedStatus  int
+		expectedBody    string
+		expectedHeaders map[string]string
+	}{
+		{
+			name:           "Success",
+			expectedStatus: http.StatusOK,
+			expectedBody: fmt.Sprintf(`{"status":"OK",`+
+				`"tenant":{"id":"shop","usage":{"bytes":300,"images":3,"uploads_this_hour":2},"limits":{"max_bytes":1000,"max_images":10,"uploads_per_hour":5}},`+
+				`"key":{"id":"%s","usage":{"bytes":450,"images":1,"uploads_this_hour":0},"limits":{"max_bytes":500,"max_images":0,"uploads_per_hour":0}}}`, testKey.ID),
+			expectedHeaders: map[string]string{
+				quota.HeaderBytesLimit:       "500",
+				quota.HeaderBytesRemaining:   "50",
+				quota.HeaderImagesRemaining:  "7",
+				quota.HeaderUploadsRemaining: "3",
+			},
+		},
+		{
+			name:           "Storage Error",
+			mockErr:        errors.New("db error"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"status":"Error","error":"failed to get usage"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usageGetterMock := mocks.NewUsageGetter(t)
+			quotaResolverMock := mocks.NewQuotaResolver(t)
+
+			quotaResolverMock.On("For", "shop", &testKey.ID).Return(limits).Once()
+			if tt.mockErr != nil {
+				usageGetterMock.On("GetUsage", mock.Anything, &testKey.ID).Return(models.Usage{}, nil, tt.mockErr).Once()
+			} else {
+				usageGetterMock.On("GetUsage", mock.Anything, &testKey.ID).Return(tenantUsage, keyUsage, nil).Once()
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/usage", nil)
+			req = req.WithContext(tenant.WithID(apikey.WithKey(req.Context(), testKey), testKey.TenantID))
+
+			rr := httptest.NewRecorder()
+
+			handler := getUsage.New(log, usageGetterMock, quotaResolverMock)
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+
+			var actualMap, expectedMap map[string]interface{}
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &actualMap))
+			require.NoError(t, json.Unmarshal([]byte(tt.expectedBody), &expectedMap))
+			require.Equal(t, expectedMap, actualMap)
+
+			for k, v := range tt.expectedHeaders {
+				require.Equal(t, v, rr.Header().Get(k), k)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package createWebhook
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"imageProcessor/internal/lib/api/response"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/lib/logger/sl"
+	"imageProcessor/internal/models"
+	"imageProcessor/internal/webhook"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+type Request struct {
+	URL string `json:"url" validate:"required"`
+}
+
+type Response struct {
+	response.Response
+	Webhook models.Webhook `json:"webhook"`
+	// Secret verifies the X-Webhook-Signature of events sent to the key.
+	Secret string `json:"secret"`
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=WebhookCreator
+type WebhookCreator interface {
+	CreateWebhook(ctx context.Context, keyID uuid.UUID, url string) (*models.Webhook, error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=SecretDeriver
+type SecretDeriver interface {
+	Secret(keyID uuid.UUID) string
+}
+
+// CreateWebhook registers a webhook for the calling key.
+// @Summary      Register a webhook
+// @Description  Registers a URL that is POSTed a signed event whenever an image uploaded with the calling key has been processed or has failed.
+// @Tags         webhooks
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        request  body      createWebhook.Request  true  "Webhook URL"
+// @Success      200  {object}  createWebhook.Response
+// @Failure      400  {object}  response.Response
+// @Failure      401  {object}  response.Response
+// @Failure      403  {object}  response.Response
+// @Failure      500  {object}  response.Response
+// @Router       /webhooks [post]
+func New(log *slog.Logger, webhookCreator WebhookCreator, secrets SecretDeriver) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.webhook.createWebhook.New"
+
+		log := log.With(slog.String("op", op))
+
+		key, ok := apikey.FromContext(r.Context())
+		if !ok {
+			render.Status(r, http.StatusUnauthorized)
+			render.JSON(w, r, response.Error("missing api key"))
+			return
+		}
+
+		var req Request
+
+		err := render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			log.Error("request body is empty")
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error("empty request"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error("failed to decode request"))
+			return
+		}
+
+		if err = validator.New().Struct(req); err != nil {
+			var validateErr validator.ValidationErrors
+			errors.As(err, &validateErr)
+
+			log.Error("invalid request", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.ValidationError(validateErr))
+			return
+		}
+
+		if err = webhook.ValidateURL(req.URL); err != nil {
+			log.Error("invalid webhook url", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error("invalid url"))
+			return
+		}
+
+		hook, err := webhookCreator.CreateWebhook(r.Context(), key.ID, req.URL)
+		if err != nil {
+			log.Error("failed to save webhook", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to create webhook"))
+			return
+		}
+
+		log.Info("webhook created", slog.String("webhook_id", hook.ID.String()), slog.String("key_id", key.ID.String()))
+
+		render.JSON(w, r, Response{
+			Response: response.OK(),
+			Webhook:  *hook,
+			Secret:   secrets.Secret(key.ID),
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package createWebhook_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"imageProcessor/internal/http-server/handlers/webhook/createWebhook"
+	"imageProcessor/internal/http-server/handlers/webhook/createWebhook/mocks"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/models"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCreateWebhook(t *testing.T) {
+	log := slog.New(slog.NewJSONHandler(bytes.NewBuffer(nil), nil))
+
+	key := &models.APIKey{ID: uuid.New(), TenantID: "shop", Scopes: []string{apikey.ScopeUpload}}
+	hook := &models.Webhook{ID: uuid.New(), TenantID: "shop", APIKeyID: key.ID, URL: "https://hooks.example.com/images", CreatedAt: time.Now()}
+
+	tests := []struct {
+		name           string
+		body           string
+		mockWebhook    *models.Webhook
+		mockErr        error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Success",
+			body:           `{"url":"https://hooks.example.com/images"}`,
+			mockWebhook:    hook,
+			expectedStatus: http.StatusOK,
+			expectedBody: fmt.Sprintf(`{"status":"OK","webhook":{"ID":"%s","TenantID":"shop","APIKeyID":"%s","URL":"https://hooks.example.com/images","CreatedAt":"%s"},"secret":"s3cret"}`,
+				hook.ID, key.ID, hook.CreatedAt.Format(time.RFC3339Nano)),
+		},
+		{
+			name:           "Empty Body",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"Error","error":"empty request"}`,
+		},
+		{
+			name:           "Missing URL",
+			body:           `{}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"Error","error":"field URL is a required field"}`,
+		},
+		{
+			name:           "Invalid URL",
+			body:           `{"url":"file:///etc/passwd"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"Error","error":"invalid url"}`,
+		},
+		{
+			name:           "Internal Address",
+			body:           `{"url":"http://169.254.169.254/latest/meta-data/"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"Error","error":"invalid url"}`,
+		},
+		{
+			name:           "Storage Error",
+			body:           `{"url":"https://hooks.example.com/images"}`,
+			mockErr:        errors.New("db error"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"status":"Error","error":"failed to create webhook"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			webhookCreatorMock := mocks.NewWebhookCreator(t)
+			secretDeriverMock := mocks.NewSecretDeriver(t)
+
+			if tt.mockWebhook != nil || tt.mockErr != nil {
+				webhookCreatorMock.On("CreateWebhook", mock.Anything, key.ID, "https://hooks.example.com/images").Return(tt.mockWebhook, tt.mockErr).Once()
+			}
+			if tt.mockWebhook != nil {
+				secretDeriverMock.On("Secret", key.ID).Return("s3cret").Once()
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/webhooks", bytes.NewBufferString(tt.body))
+			req = req.WithContext(apikey.WithKey(req.Context(), key))
+
+			rr := httptest.NewRecorder()
+
+			handler := createWebhook.New(log, webhookCreatorMock, secretDeriverMock)
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+
+			var actualMap, expectedMap map[string]interface{}
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &actualMap))
+			require.NoError(t, json.Unmarshal([]byte(tt.expectedBody), &expectedMap))
+			require.Equal(t, expectedMap, actualMap)
+		})
+	}
+}
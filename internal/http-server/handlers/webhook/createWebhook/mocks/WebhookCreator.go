@@ -0,0 +1,62 @@
+// Code generated by mockery v2.51.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+
+	models "imageProcessor/internal/models"
+
+	uuid "github.com/google/uuid"
+)
+
+// WebhookCreator is an autogenerated mock type for the WebhookCreator type
+type WebhookCreator struct {
+	mock.Mock
+}
+
+// CreateWebhook provides a mock function with given fields: ctx, keyID, url
+func (_m *WebhookCreator) CreateWebhook(ctx context.Context, keyID uuid.UUID, url string) (*models.Webhook, error) {
+	ret := _m.Called(ctx, keyID, url)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateWebhook")
+	}
+
+	var r0 *models.Webhook
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, string) (*models.Webhook, error)); ok {
+		return rf(ctx, keyID, url)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, string) *models.Webhook); ok {
+		r0 = rf(ctx, keyID, url)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.Webhook)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID, string) error); ok {
+		r1 = rf(ctx, keyID, url)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewWebhookCreator creates a new instance of WebhookCreator. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewWebhookCreator(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *WebhookCreator {
+	mock := &WebhookCreator{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
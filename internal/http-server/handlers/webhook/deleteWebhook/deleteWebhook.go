@@ -0,0 +1,76 @@
+package deleteWebhook
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"imageProcessor/internal/lib/api/response"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/lib/logger/sl"
+	"log/slog"
+	"net/http"
+)
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=WebhookDeleter
+type WebhookDeleter interface {
+	DeleteWebhook(ctx context.Context, keyID uuid.UUID, id uuid.UUID) error
+}
+
+// DeleteWebhook removes a webhook of the calling key.
+// @Summary      Delete a webhook
+// @Description  Removes a webhook of the calling key. Events already scheduled for it are still delivered.
+// @Tags         webhooks
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      string  true  "Webhook ID"
+// @Success      200  {object}  response.Response
+// @Failure      400  {object}  response.Response
+// @Failure      401  {object}  response.Response
+// @Failure      403  {object}  response.Response
+// @Failure      404  {object}  response.Response
+// @Failure      500  {object}  response.Response
+// @Router       /webhooks/{id} [delete]
+func New(log *slog.Logger, webhookDeleter WebhookDeleter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.webhook.deleteWebhook.New"
+
+		log := log.With(slog.String("op", op))
+
+		key, ok := apikey.FromContext(r.Context())
+		if !ok {
+			render.Status(r, http.StatusUnauthorized)
+			render.JSON(w, r, response.Error("missing api key"))
+			return
+		}
+
+		webhookID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			log.Error("failed to parse webhook ID", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error("invalid webhook ID"))
+			return
+		}
+
+		err = webhookDeleter.DeleteWebhook(r.Context(), key.ID, webhookID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				log.Warn("webhook not found", slog.String("webhook_id", webhookID.String()))
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, response.Error("webhook not found"))
+				return
+			}
+
+			log.Error("failed to delete webhook", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to delete webhook"))
+			return
+		}
+
+		log.Info("webhook deleted", slog.String("webhook_id", webhookID.String()))
+
+		render.JSON(w, r, response.OK())
+	}
+}
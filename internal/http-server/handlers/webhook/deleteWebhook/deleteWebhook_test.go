@@ -0,0 +1,93 @@
+package deleteWebhook_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"imageProcessor/internal/http-server/handlers/webhook/deleteWebhook"
+	"imageProcessor/internal/http-server/handlers/webhook/deleteWebhook/mocks"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/models"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDeleteWebhook(t *testing.T) {
+	log := slog.New(slog.NewJSONHandler(bytes.NewBuffer(nil), nil))
+
+	key := &models.APIKey{ID: uuid.New(), TenantID: "shop", Scopes: []string{apikey.ScopeUpload}}
+	webhookID := uuid.New()
+
+	tests := []struct {
+		name           string
+		webhookID      string
+		mockErr        error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Success",
+			webhookID:      webhookID.String(),
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"status":"OK"}`,
+		},
+		{
+			name:           "Invalid UUID",
+			webhookID:      "invalid-uuid",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"Error","error":"invalid webhook ID"}`,
+		},
+		{
+			name:           "Not Found",
+			webhookID:      webhookID.String(),
+			mockErr:        sql.ErrNoRows,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"status":"Error","error":"webhook not found"}`,
+		},
+		{
+			name:           "Internal Error",
+			webhookID:      webhookID.String(),
+			mockErr:        errors.New("db error"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"status":"Error","error":"failed to delete webhook"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			webhookDeleterMock := mocks.NewWebhookDeleter(t)
+
+			if tt.name != "Invalid UUID" {
+				webhookDeleterMock.On("DeleteWebhook", mock.Anything, key.ID, webhookID).Return(tt.mockErr).Once()
+			}
+
+			req := httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/webhooks/%s", tt.webhookID), nil)
+
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", tt.webhookID)
+			ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+			req = req.WithContext(apikey.WithKey(ctx, key))
+
+			rr := httptest.NewRecorder()
+
+			handler := deleteWebhook.New(log, webhookDeleterMock)
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+
+			var actualMap, expectedMap map[string]interface{}
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &actualMap))
+			require.NoError(t, json.Unmarshal([]byte(tt.expectedBody), &expectedMap))
+			require.Equal(t, expectedMap, actualMap)
+		})
+	}
+}
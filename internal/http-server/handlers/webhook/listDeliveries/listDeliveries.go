@@ -0,0 +1,81 @@
+package listDeliveries
+
+import (
+	"context"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"imageProcessor/internal/lib/api/response"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/lib/logger/sl"
+	"imageProcessor/internal/models"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultLimit = 50
+	maxLimit     = 500
+)
+
+type Response struct {
+	response.Response
+	Deliveries []models.WebhookDelivery `json:"deliveries"`
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=DeliveryLister
+type DeliveryLister interface {
+	ListDeliveries(ctx context.Context, keyID uuid.UUID, limit int) ([]models.WebhookDelivery, error)
+}
+
+// ListDeliveries lists the latest webhook deliveries of the calling key.
+// @Summary      List webhook deliveries
+// @Description  Lists the latest deliveries of events to the calling key, newest first, with their status, attempts and the last error.
+// @Tags         webhooks
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        limit  query     int  false  "Number of deliveries (1-500, default 50)"
+// @Success      200  {object}  listDeliveries.Response
+// @Failure      400  {object}  response.Response
+// @Failure      401  {object}  response.Response
+// @Failure      403  {object}  response.Response
+// @Failure      500  {object}  response.Response
+// @Router       /webhooks/deliveries [get]
+func New(log *slog.Logger, deliveryLister DeliveryLister) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.webhook.listDeliveries.New"
+
+		log := log.With(slog.String("op", op))
+
+		key, ok := apikey.FromContext(r.Context())
+		if !ok {
+			render.Status(r, http.StatusUnauthorized)
+			render.JSON(w, r, response.Error("missing api key"))
+			return
+		}
+
+		limit := defaultLimit
+		if s := r.URL.Query().Get("limit"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 1 || n > maxLimit {
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, response.Error("invalid limit"))
+				return
+			}
+			limit = n
+		}
+
+		deliveries, err := deliveryLister.ListDeliveries(r.Context(), key.ID, limit)
+		if err != nil {
+			log.Error("failed to list webhook deliveries", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to list deliveries"))
+			return
+		}
+
+		render.JSON(w, r, Response{
+			Response:   response.OK(),
+			Deliveries: deliveries,
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package listDeliveries_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"imageProcessor/internal/http-server/handlers/webhook/listDeliveries"
+	"imageProcessor/internal/http-server/handlers/webhook/listDeliveries/mocks"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/models"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestListDeliveries(t *testing.T) {
+	log := slog.New(slog.NewJSONHandler(bytes.NewBuffer(nil), nil))
+
+	key := &models.APIKey{ID: uuid.New(), TenantID: "shop", Scopes: []string{apikey.ScopeUpload}}
+	lastError := "receiver answered 500"
+	statusCode := http.StatusInternalServerError
+	delivery := models.WebhookDelivery{
+		ID:             uuid.New(),
+		TenantID:       "shop",
+		APIKeyID:       key.ID,
+		ImageID:        uuid.New(),
+		Event:          "image.processed",
+		URL:            "https://hooks.example.com/images",
+		Payload:        json.RawMessage(`{"type":"image.processed"}`),
+		Status:         "failed",
+		Attempts:       8,
+		NextAttemptAt:  time.Now(),
+		LastStatusCode: &statusCode,
+		LastError:      &lastError,
+		CreatedAt:      time.Now(),
+	}
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedLimit  int
+		mockErr        error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Success",
+			expectedLimit:  50,
+			expectedStatus: http.StatusOK,
+			expectedBody: fmt.Sprintf(`{"status":"OK","deliveries":[{"ID":"%s","TenantID":"shop","APIKeyID":"%s","WebhookID":null,"ImageID":"%s","Event":"image.processed","URL":"https://hooks.example.com/images","Payload":{"type":"image.processed"},"Status":"failed","Attempts":8,"NextAttemptAt":"%s","LastStatusCode":500,"LastError":"receiver answered 500","CreatedAt":"%s","DeliveredAt":null}]}`,
+				delivery.ID, key.ID, delivery.ImageID, delivery.NextAttemptAt.Format(time.RFC3339Nano), delivery.CreatedAt.Format(time.RFC3339Nano)),
+		},
+		{
+			name:           "Custom Limit",
+			query:          "?limit=5",
+			expectedLimit:  5,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"status":"OK","deliveries":[]}`,
+		},
+		{
+			name:           "Invalid Limit",
+			query:          "?limit=1000",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"Error","error":"invalid limit"}`,
+		},
+		{
+			name:           "Storage Error",
+			expectedLimit:  50,
+			mockErr:        errors.New("db error"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"status":"Error","error":"failed to list deliveries"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deliveryListerMock := mocks.NewDeliveryLister(t)
+
+			switch {
+			case tt.expectedLimit == 0:
+			case tt.mockErr != nil:
+				deliveryListerMock.On("ListDeliveries", mock.Anything, key.ID, tt.expectedLimit).Return(nil, tt.mockErr).Once()
+			case tt.query == "":
+				deliveryListerMock.On("ListDeliveries", mock.Anything, key.ID, tt.expectedLimit).Return([]models.WebhookDelivery{delivery}, nil).Once()
+			default:
+				deliveryListerMock.On("ListDeliveries", mock.Anything, key.ID, tt.expectedLimit).Return([]models.WebhookDelivery{}, nil).Once()
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/webhooks/deliveries"+tt.query, nil)
+			req = req.WithContext(apikey.WithKey(req.Context(), key))
+
+			rr := httptest.NewRecorder()
+
+			handler := listDeliveries.New(log, deliveryListerMock)
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+
+			var actualMap, expectedMap map[string]interface{}
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &actualMap))
+			require.NoError(t, json.Unmarshal([]byte(tt.expectedBody), &expectedMap))
+			require.Equal(t, expectedMap, actualMap)
+		})
+	}
+}
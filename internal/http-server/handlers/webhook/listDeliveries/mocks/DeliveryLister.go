@@ -0,0 +1,62 @@
+// Code generated by mockery v2.51.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+
+	models "imageProcessor/internal/models"
+
+	uuid "github.com/google/uuid"
+)
+
+// DeliveryLister is an autogenerated mock type for the DeliveryLister type
+type DeliveryLister struct {
+	mock.Mock
+}
+
+// ListDeliveries provides a mock function with given fields: ctx, keyID, limit
+func (_m *DeliveryLister) ListDeliveries(ctx context.Context, keyID uuid.UUID, limit int) ([]models.WebhookDelivery, error) {
+	ret := _m.Called(ctx, keyID, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListDeliveries")
+	}
+
+	var r0 []models.WebhookDelivery
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, int) ([]models.WebhookDelivery, error)); ok {
+		return rf(ctx, keyID, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, int) []models.WebhookDelivery); ok {
+		r0 = rf(ctx, keyID, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.WebhookDelivery)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID, int) error); ok {
+		r1 = rf(ctx, keyID, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewDeliveryLister creates a new instance of DeliveryLister. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewDeliveryLister(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *DeliveryLister {
+	mock := &DeliveryLister{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
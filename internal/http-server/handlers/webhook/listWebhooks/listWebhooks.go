@@ -0,0 +1,71 @@
+package listWebhooks
+
+import (
+	"context"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"imageProcessor/internal/lib/api/response"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/lib/logger/sl"
+	"imageProcessor/internal/models"
+	"log/slog"
+	"net/http"
+)
+
+type Response struct {
+	response.Response
+	Webhooks []models.Webhook `json:"webhooks"`
+	// Secret verifies the X-Webhook-Signature of events sent to the key,
+	// including those sent to the callback_url of an upload.
+	Secret string `json:"secret"`
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=WebhookLister
+type WebhookLister interface {
+	ListWebhooks(ctx context.Context, keyID uuid.UUID) ([]models.Webhook, error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=SecretDeriver
+type SecretDeriver interface {
+	Secret(keyID uuid.UUID) string
+}
+
+// ListWebhooks lists the webhooks of the calling key.
+// @Summary      List webhooks
+// @Description  Lists the webhooks registered by the calling key together with the secret its events are signed with.
+// @Tags         webhooks
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {object}  listWebhooks.Response
+// @Failure      401  {object}  response.Response
+// @Failure      403  {object}  response.Response
+// @Failure      500  {object}  response.Response
+// @Router       /webhooks [get]
+func New(log *slog.Logger, webhookLister WebhookLister, secrets SecretDeriver) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.webhook.listWebhooks.New"
+
+		log := log.With(slog.String("op", op))
+
+		key, ok := apikey.FromContext(r.Context())
+		if !ok {
+			render.Status(r, http.StatusUnauthorized)
+			render.JSON(w, r, response.Error("missing api key"))
+			return
+		}
+
+		webhooks, err := webhookLister.ListWebhooks(r.Context(), key.ID)
+		if err != nil {
+			log.Error("failed to list webhooks", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to list webhooks"))
+			return
+		}
+
+		render.JSON(w, r, Response{
+			Response: response.OK(),
+			Webhooks: webhooks,
+			Secret:   secrets.Secret(key.ID),
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package listWebhooks_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"imageProcessor/internal/http-server/handlers/webhook/listWebhooks"
+	"imageProcessor/internal/http-server/handlers/webhook/listWebhooks/mocks"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/models"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestListWebhooks(t *testing.T) {
+	log := slog.New(slog.NewJSONHandler(bytes.NewBuffer(nil), nil))
+
+	key := &models.APIKey{ID: uuid.New(), TenantID: "shop", Scopes: []string{apikey.ScopeUpload}}
+	hook := models.Webhook{ID: uuid.New(), TenantID: "shop", APIKeyID: key.ID, URL: "https://hooks.example.com/images", CreatedAt: time.Now()}
+
+	tests := []struct {
+		name           string
+		mockWebhooks   []models.Webhook
+		mockErr        error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Success",
+			mockWebhooks:   []models.Webhook{hook},
+			expectedStatus: http.StatusOK,
+			expectedBody: fmt.Sprintf(`{"status":"OK","webhooks":[{"ID":"%s","TenantID":"shop","APIKeyID":"%s","URL":"https://hooks.example.com/images","CreatedAt":"%s"}],"secret":"s3cret"}`,
+				hook.ID, key.ID, hook.CreatedAt.Format(time.RFC3339Nano)),
+		},
+		{
+			name:           "None Registered",
+			mockWebhooks:   []models.Webhook{},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"status":"OK","webhooks":[],"secret":"s3cret"}`,
+		},
+		{
+			name:           "Storage Error",
+			mockErr:        errors.New("db error"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"status":"Error","error":"failed to list webhooks"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			webhookListerMock := mocks.NewWebhookLister(t)
+			secretDeriverMock := mocks.NewSecretDeriver(t)
+
+			webhookListerMock.On("ListWebhooks", mock.Anything, key.ID).Return(tt.mockWebhooks, tt.mockErr).Once()
+			if tt.mockErr == nil {
+				secretDeriverMock.On("Secret", key.ID).Return("s3cret").Once()
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/webhooks", nil)
+			req = req.WithContext(apikey.WithKey(req.Context(), key))
+
+			rr := httptest.NewRecorder()
+
+			handler := listWebhooks.New(log, webhookListerMock, secretDeriverMock)
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+
+			var actualMap, expectedMap map[string]interface{}
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &actualMap))
+			require.NoError(t, json.Unmarshal([]byte(tt.expectedBody), &expectedMap))
+			require.Equal(t, expectedMap, actualMap)
+		})
+	}
+}
@@ -0,0 +1,45 @@
+// Code generated by mockery v2.51.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	uuid "github.com/google/uuid"
+	mock "github.com/stretchr/testify/mock"
+)
+
+// SecretDeriver is an autogenerated mock type for the SecretDeriver type
+type SecretDeriver struct {
+	mock.Mock
+}
+
+// Secret provides a mock function with given fields: keyID
+func (_m *SecretDeriver) Secret(keyID uuid.UUID) string {
+	ret := _m.Called(keyID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Secret")
+	}
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(uuid.UUID) string); ok {
+		r0 = rf(keyID)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	return r0
+}
+
+// NewSecretDeriver creates a new instance of SecretDeriver. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewSecretDeriver(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *SecretDeriver {
+	mock := &SecretDeriver{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,48 @@
+// Code generated by mockery v2.51.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+
+	uuid "github.com/google/uuid"
+)
+
+// Redeliverer is an autogenerated mock type for the Redeliverer type
+type Redeliverer struct {
+	mock.Mock
+}
+
+// RedeliverWebhook provides a mock function with given fields: ctx, keyID, id
+func (_m *Redeliverer) RedeliverWebhook(ctx context.Context, keyID uuid.UUID, id uuid.UUID) error {
+	ret := _m.Called(ctx, keyID, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RedeliverWebhook")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, uuid.UUID) error); ok {
+		r0 = rf(ctx, keyID, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewRedeliverer creates a new instance of Redeliverer. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewRedeliverer(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *Redeliverer {
+	mock := &Redeliverer{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
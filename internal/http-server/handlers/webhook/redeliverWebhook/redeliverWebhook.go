@@ -0,0 +1,76 @@
+package redeliverWebhook
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"imageProcessor/internal/lib/api/response"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/lib/logger/sl"
+	"log/slog"
+	"net/http"
+)
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=Redeliverer
+type Redeliverer interface {
+	RedeliverWebhook(ctx context.Context, keyID uuid.UUID, id uuid.UUID) error
+}
+
+// RedeliverWebhook schedules a delivery to be sent again.
+// @Summary      Redeliver a webhook event
+// @Description  Sends a delivery of the calling key again right away, with a fresh budget of attempts, whether it failed or already succeeded.
+// @Tags         webhooks
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      string  true  "Delivery ID"
+// @Success      200  {object}  response.Response
+// @Failure      400  {object}  response.Response
+// @Failure      401  {object}  response.Response
+// @Failure      403  {object}  response.Response
+// @Failure      404  {object}  response.Response
+// @Failure      500  {object}  response.Response
+// @Router       /webhooks/deliveries/{id}/redeliver [post]
+func New(log *slog.Logger, redeliverer Redeliverer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.webhook.redeliverWebhook.New"
+
+		log := log.With(slog.String("op", op))
+
+		key, ok := apikey.FromContext(r.Context())
+		if !ok {
+			render.Status(r, http.StatusUnauthorized)
+			render.JSON(w, r, response.Error("missing api key"))
+			return
+		}
+
+		deliveryID, err := uuid.Parse(chi.URLParam(r, "id"))
+		if err != nil {
+			log.Error("failed to parse delivery ID", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, response.Error("invalid delivery ID"))
+			return
+		}
+
+		err = redeliverer.RedeliverWebhook(r.Context(), key.ID, deliveryID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				log.Warn("delivery not found", slog.String("delivery_id", deliveryID.String()))
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, response.Error("delivery not found"))
+				return
+			}
+
+			log.Error("failed to schedule redelivery", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, response.Error("failed to redeliver"))
+			return
+		}
+
+		log.Info("delivery rescheduled", slog.String("delivery_id", deliveryID.String()))
+
+		render.JSON(w, r, response.OK())
+	}
+}
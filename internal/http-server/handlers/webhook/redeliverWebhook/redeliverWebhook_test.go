@@ -0,0 +1,93 @@
+package redeliverWebhook_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"imageProcessor/internal/http-server/handlers/webhook/redeliverWebhook"
+	"imageProcessor/internal/http-server/handlers/webhook/redeliverWebhook/mocks"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/models"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRedeliverWebhook(t *testing.T) {
+	log := slog.New(slog.NewJSONHandler(bytes.NewBuffer(nil), nil))
+
+	key := &models.APIKey{ID: uuid.New(), TenantID: "shop", Scopes: []string{apikey.ScopeUpload}}
+	deliveryID := uuid.New()
+
+	tests := []struct {
+		name           string
+		deliveryID     string
+		mockErr        error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Success",
+			deliveryID:     deliveryID.String(),
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"status":"OK"}`,
+		},
+		{
+			name:           "Invalid UUID",
+			deliveryID:     "invalid-uuid",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"Error","error":"invalid delivery ID"}`,
+		},
+		{
+			name:           "Not Found",
+			deliveryID:     deliveryID.String(),
+			mockErr:        sql.ErrNoRows,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"status":"Error","error":"delivery not found"}`,
+		},
+		{
+			name:           "Internal Error",
+			deliveryID:     deliveryID.String(),
+			mockErr:        errors.New("db error"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"status":"Error","error":"failed to redeliver"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redelivererMock := mocks.NewRedeliverer(t)
+
+			if tt.name != "Invalid UUID" {
+				redelivererMock.On("RedeliverWebhook", mock.Anything, key.ID, deliveryID).Return(tt.mockErr).Once()
+			}
+
+			req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/webhooks/deliveries/%s/redeliver", tt.deliveryID), nil)
+
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", tt.deliveryID)
+			ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+			req = req.WithContext(apikey.WithKey(ctx, key))
+
+			rr := httptest.NewRecorder()
+
+			handler := redeliverWebhook.New(log, redelivererMock)
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+
+			var actualMap, expectedMap map[string]interface{}
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &actualMap))
+			require.NoError(t, json.Unmarshal([]byte(tt.expectedBody), &expectedMap))
+			require.Equal(t, expectedMap, actualMap)
+		})
+	}
+}
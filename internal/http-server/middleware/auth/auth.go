@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/go-chi/render"
+	"imageProcessor/internal/lib/api/response"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/lib/logger/sl"
+	"imageProcessor/internal/lib/tenant"
+	"imageProcessor/internal/models"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+const headerAPIKey = "X-API-Key"
+
+type KeyGetter interface {
+	GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error)
+}
+
+// New authenticates requests by the API key passed in the X-API-Key header or
+// as a bearer token and stores the key and its tenant in the request context.
+func New(log *slog.Logger, keyGetter KeyGetter) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		log := log.With(slog.String("component", "middleware/auth"))
+
+		log.Info("auth middleware enabled")
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			token := tokenFromRequest(r)
+			if token == "" {
+				render.Status(r, http.StatusUnauthorized)
+				render.JSON(w, r, response.Error("missing api key"))
+				return
+			}
+
+			key, err := keyGetter.GetAPIKeyByHash(r.Context(), apikey.Hash(token))
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					log.Warn("rejected unknown api key", slog.String("prefix", apikey.Prefix(token)))
+					render.Status(r, http.StatusUnauthorized)
+					render.JSON(w, r, response.Error("invalid api key"))
+					return
+				}
+
+				log.Error("failed to look up api key", sl.Err(err))
+				render.Status(r, http.StatusInternalServerError)
+				render.JSON(w, r, response.Error("failed to authenticate"))
+				return
+			}
+
+			ctx := apikey.WithKey(r.Context(), key)
+			ctx = tenant.WithID(ctx, key.TenantID)
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// RequireScope lets the request through only if the authenticated key grants
+// scope. It must run after New.
+func RequireScope(scope string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			key, ok := apikey.FromContext(r.Context())
+			if !ok {
+				render.Status(r, http.StatusUnauthorized)
+				render.JSON(w, r, response.Error("missing api key"))
+				return
+			}
+
+			if !apikey.HasScope(key, scope) {
+				render.Status(r, http.StatusForbidden)
+				render.JSON(w, r, response.Error("api key lacks scope "+scope))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+func tokenFromRequest(r *http.Request) string {
+	if token := r.Header.Get(headerAPIKey); token != "" {
+		return token
+	}
+
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+
+	return ""
+}
@@ -0,0 +1,74 @@
+package auth_test
+
+import (
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"imageProcessor/internal/http-server/middleware/auth"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/lib/logger/handlers/slogdiscard"
+	"imageProcessor/internal/lib/tenant"
+	"imageProcessor/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type keyGetter map[string]*models.APIKey
+
+func (g keyGetter) GetAPIKeyByHash(_ context.Context, hash string) (*models.APIKey, error) {
+	if key, ok := g[hash]; ok {
+		return key, nil
+	}
+
+	return nil, sql.ErrNoRows
+}
+
+func TestAuth(t *testing.T) {
+	readKey := &models.APIKey{ID: uuid.New(), TenantID: "shop", Scopes: []string{apikey.ScopeRead}}
+	adminKey := &models.APIKey{ID: uuid.New(), TenantID: "cms", Scopes: []string{apikey.ScopeAdmin}}
+
+	keys := keyGetter{
+		apikey.Hash("read-token"):  readKey,
+		apikey.Hash("admin-token"): adminKey,
+	}
+
+	tests := []struct {
+		name           string
+		headers        map[string]string
+		scope          string
+		expectedStatus int
+	}{
+		{name: "Header", headers: map[string]string{"X-API-Key": "read-token"}, scope: apikey.ScopeRead, expectedStatus: http.StatusOK},
+		{name: "Bearer", headers: map[string]string{"Authorization": "Bearer read-token"}, scope: apikey.ScopeRead, expectedStatus: http.StatusOK},
+		{name: "Admin Has Every Scope", headers: map[string]string{"X-API-Key": "admin-token"}, scope: apikey.ScopeDelete, expectedStatus: http.StatusOK},
+		{name: "Missing Key", scope: apikey.ScopeRead, expectedStatus: http.StatusUnauthorized},
+		{name: "Unknown Key", headers: map[string]string{"X-API-Key": "nope"}, scope: apikey.ScopeRead, expectedStatus: http.StatusUnauthorized},
+		{name: "Missing Scope", headers: map[string]string{"X-API-Key": "read-token"}, scope: apikey.ScopeUpload, expectedStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				key, ok := apikey.FromContext(r.Context())
+				require.True(t, ok)
+				tenantID, err := tenant.FromContext(r.Context())
+				require.NoError(t, err)
+				require.Equal(t, key.TenantID, tenantID)
+				w.WriteHeader(http.StatusOK)
+			})
+			handler := auth.New(slogdiscard.NewDiscardLogger(), keys)(auth.RequireScope(tt.scope)(next))
+
+			req := httptest.NewRequest(http.MethodGet, "/image/123", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+		})
+	}
+}
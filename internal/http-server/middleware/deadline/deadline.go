@@ -0,0 +1,44 @@
+package deadline
+
+import (
+	"imageProcessor/internal/config"
+	"imageProcessor/internal/lib/logger/sl"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// New gives the requests of the route group the read and write timeouts of
+// t in place of the server's, counted from when the request reaches it.
+// Slow uploads then need not raise the timeouts of every other route.
+func New(log *slog.Logger, route string, t config.RouteTimeouts) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if t.ReadTimeout == 0 && t.WriteTimeout == 0 {
+			return next
+		}
+
+		log := log.With(slog.String("component", "middleware/deadline"), slog.String("route", route))
+
+		log.Info("route timeouts enabled", slog.Duration("read_timeout", t.ReadTimeout), slog.Duration("write_timeout", t.WriteTimeout))
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			rc := http.NewResponseController(w)
+			now := time.Now()
+
+			if t.ReadTimeout > 0 {
+				if err := rc.SetReadDeadline(now.Add(t.ReadTimeout)); err != nil {
+					log.Warn("failed to set read deadline", sl.Err(err))
+				}
+			}
+			if t.WriteTimeout > 0 {
+				if err := rc.SetWriteDeadline(now.Add(t.WriteTimeout)); err != nil {
+					log.Warn("failed to set write deadline", sl.Err(err))
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,101 @@
+package deadline_test
+
+import (
+	"github.com/stretchr/testify/require"
+	"imageProcessor/internal/config"
+	"imageProcessor/internal/http-server/middleware/deadline"
+	"imageProcessor/internal/lib/logger/handlers/slogdiscard"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeadline(t *testing.T) {
+	const serverTimeout = 100 * time.Millisecond
+
+	tests := []struct {
+		name     string
+		timeouts config.RouteTimeouts
+		// slowBody sends the body only after the server's read timeout,
+		// slowHandler answers only after its write timeout.
+		slowBody    bool
+		slowHandler bool
+		expectOK    bool
+	}{
+		{
+			name:     "Fast Request",
+			expectOK: true,
+		},
+		{
+			name:     "Slow Body Within Route Timeout",
+			timeouts: config.RouteTimeouts{ReadTimeout: 5 * time.Second, WriteTimeout: 5 * time.Second},
+			slowBody: true,
+			expectOK: true,
+		},
+		{
+			name:     "Slow Body Without Route Timeout",
+			slowBody: true,
+		},
+		{
+			name:        "Slow Handler Within Route Timeout",
+			timeouts:    config.RouteTimeouts{WriteTimeout: 5 * time.Second},
+			slowHandler: true,
+			expectOK:    true,
+		},
+		{
+			name:        "Slow Handler Without Route Timeout",
+			slowHandler: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if _, err := io.ReadAll(r.Body); err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				if tt.slowHandler {
+					time.Sleep(3 * serverTimeout)
+				}
+				_, _ = io.WriteString(w, "ok")
+			})
+
+			srv := httptest.NewUnstartedServer(deadline.New(slogdiscard.NewDiscardLogger(), "upload", tt.timeouts)(next))
+			srv.Config.ReadTimeout = serverTimeout
+			srv.Config.WriteTimeout = serverTimeout
+			srv.Start()
+			defer srv.Close()
+
+			body, pw := io.Pipe()
+			go func() {
+				if tt.slowBody {
+					time.Sleep(3 * serverTimeout)
+				}
+				_, _ = pw.Write([]byte("image"))
+				_ = pw.Close()
+			}()
+
+			resp, err := http.Post(srv.URL, "application/octet-stream", body)
+			if !tt.expectOK {
+				if err == nil {
+					defer resp.Body.Close()
+					data, _ := io.ReadAll(resp.Body)
+					require.False(t, resp.StatusCode == http.StatusOK && strings.TrimSpace(string(data)) == "ok", "request should have timed out")
+				}
+				return
+			}
+
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			data, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Equal(t, "ok", string(data))
+		})
+	}
+}
@@ -0,0 +1,335 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"hash"
+	"imageProcessor/internal/config"
+	"imageProcessor/internal/lib/api/response"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/lib/logger/sl"
+	"imageProcessor/internal/models"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	HeaderKey = "Idempotency-Key"
+	// HeaderReplayed is set on responses replayed for a repeated key.
+	HeaderReplayed = "Idempotent-Replayed"
+
+	maxKeyLen = 255
+	// maxDrain is how much of a body the handler left unread is read to
+	// complete its fingerprint. Requests with more are not recorded.
+	maxDrain = 64 << 10
+)
+
+type Store interface {
+	ClaimIdempotencyKey(ctx context.Context, keyID uuid.UUID, key string, ttl, lockTimeout time.Duration) (*models.IdempotentRequest, error)
+	SaveIdempotentResponse(ctx context.Context, keyID uuid.UUID, request models.IdempotentRequest) error
+	ReleaseIdempotencyKey(ctx context.Context, keyID uuid.UUID, key string) error
+}
+
+// New makes POST and DELETE requests sent with an Idempotency-Key header
+// safe to retry. The first request with a key is served and its response
+// kept for cfg.TTL; requests repeating the key get that response again,
+// marked with Idempotent-Replayed, without reaching the handler. A key
+// reused for a different request is refused with 422, and one whose
+// request is still in progress with 409. Keys belong to the API key, so
+// New must run after auth.New, and after the route's timeouts, as the body
+// of a repeated request is read in full to be compared.
+//
+// Responses are not kept if they are server errors or 429, are larger than
+// cfg.MaxResponseSize, or answer a request whose body was left unread, so
+// that such requests are served again when retried.
+func New(log *slog.Logger, store Store, cfg *config.Idempotency) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		log := log.With(slog.String("component", "middleware/idempotency"))
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(HeaderKey)
+			apiKey, ok := apikey.FromContext(r.Context())
+			if key == "" || !ok || r.Method != http.MethodPost && r.Method != http.MethodDelete {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			log := log.With(slog.String("idempotency_key", key), slog.String("key_id", apiKey.ID.String()))
+
+			if !valid(key) {
+				log.Warn("invalid idempotency key")
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, response.Error("invalid "+HeaderKey))
+				return
+			}
+
+			previous, err := store.ClaimIdempotencyKey(r.Context(), apiKey.ID, key, cfg.TTL, cfg.LockTimeout)
+			if err != nil {
+				log.Error("failed to claim idempotency key", sl.Err(err))
+				render.Status(r, http.StatusInternalServerError)
+				render.JSON(w, r, response.Error("failed to check "+HeaderKey))
+				return
+			}
+			if previous != nil {
+				replay(w, r, log, previous)
+				return
+			}
+
+			// The key is freed again unless the response is kept, even if
+			// the handler panics.
+			kept := false
+			defer func() {
+				if kept {
+					return
+				}
+				if err := store.ReleaseIdempotencyKey(context.WithoutCancel(r.Context()), apiKey.ID, key); err != nil {
+					log.Error("failed to release idempotency key", sl.Err(err))
+				}
+			}()
+
+			body := &fingerprintBody{ReadCloser: r.Body, fingerprint: newFingerprint(r)}
+			r.Body = body
+
+			rec := &recorder{ResponseWriter: w, limit: cfg.MaxResponseSize}
+			next.ServeHTTP(rec, r)
+
+			status := rec.statusCode()
+			if status >= http.StatusInternalServerError || status == http.StatusTooManyRequests || rec.overflow {
+				return
+			}
+			if !body.drain() {
+				log.Debug("request body left unread, response not kept")
+				return
+			}
+
+			err = store.SaveIdempotentResponse(context.WithoutCancel(r.Context()), apiKey.ID, models.IdempotentRequest{
+				Key:         key,
+				Fingerprint: body.fingerprint.sum(),
+				StatusCode:  status,
+				Header:      rec.headers(),
+				Body:        rec.body.Bytes(),
+			})
+			if err != nil {
+				log.Error("failed to save idempotent response", sl.Err(err))
+				return
+			}
+			kept = true
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// replay answers r with the response kept for the request that used its
+// key first, if r is the same request.
+func replay(w http.ResponseWriter, r *http.Request, log *slog.Logger, previous *models.IdempotentRequest) {
+	if !previous.Completed {
+		log.Warn("request with the same idempotency key in progress")
+		render.Status(r, http.StatusConflict)
+		render.JSON(w, r, response.Error("request with the same "+HeaderKey+" in progress"))
+		return
+	}
+
+	fp := newFingerprint(r)
+	if _, err := io.Copy(fp, r.Body); err != nil {
+		log.Error("failed to read request body", sl.Err(err))
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, response.Error("failed to read request"))
+		return
+	}
+
+	if fp.sum() != previous.Fingerprint {
+		log.Warn("idempotency key reused for a different request")
+		render.Status(r, http.StatusUnprocessableEntity)
+		render.JSON(w, r, response.Error(HeaderKey+" reused for a different request"))
+		return
+	}
+
+	log.Info("replaying response", slog.Int("status", previous.StatusCode))
+
+	for name, values := range previous.Header {
+		w.Header()[name] = values
+	}
+	w.Header().Set(HeaderReplayed, "true")
+	w.WriteHeader(previous.StatusCode)
+	_, _ = w.Write(previous.Body)
+}
+
+func valid(key string) bool {
+	if len(key) > maxKeyLen {
+		return false
+	}
+
+	for _, c := range []byte(key) {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+
+	return true
+}
+
+// fingerprint hashes what tells requests apart: the method, path, query,
+// media type and body. The boundary of a multipart body is left out, as
+// clients draw a new one when they retry.
+type fingerprint struct {
+	hash hash.Hash
+	body io.Writer
+	skip *skipWriter
+}
+
+func newFingerprint(r *http.Request) *fingerprint {
+	fp := &fingerprint{hash: sha256.New()}
+	fp.body = fp.hash
+
+	mediaType, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	_, _ = fmt.Fprintf(fp.hash, "%s %s?%s\n%s\n", r.Method, r.URL.Path, r.URL.RawQuery, mediaType)
+
+	if boundary := params["boundary"]; boundary != "" && strings.HasPrefix(mediaType, "multipart/") {
+		fp.skip = &skipWriter{w: fp.hash, sep: []byte(boundary)}
+		fp.body = fp.skip
+	}
+
+	return fp
+}
+
+func (f *fingerprint) Write(p []byte) (int, error) {
+	return f.body.Write(p)
+}
+
+func (f *fingerprint) sum() string {
+	if f.skip != nil {
+		f.skip.flush()
+	}
+
+	return hex.EncodeToString(f.hash.Sum(nil))
+}
+
+// skipWriter writes everything but the occurrences of sep through to w.
+type skipWriter struct {
+	w   io.Writer
+	sep []byte
+	buf []byte
+}
+
+func (s *skipWriter) Write(p []byte) (int, error) {
+	s.buf = append(s.buf, p...)
+
+	for {
+		i := bytes.Index(s.buf, s.sep)
+		if i < 0 {
+			break
+		}
+		_, _ = s.w.Write(s.buf[:i])
+		s.buf = s.buf[i+len(s.sep):]
+	}
+
+	// What may be the start of a separator split across writes is held
+	// back.
+	if keep := len(s.sep) - 1; len(s.buf) > keep {
+		_, _ = s.w.Write(s.buf[:len(s.buf)-keep])
+		s.buf = append([]byte(nil), s.buf[len(s.buf)-keep:]...)
+	}
+
+	return len(p), nil
+}
+
+func (s *skipWriter) flush() {
+	_, _ = s.w.Write(s.buf)
+	s.buf = nil
+}
+
+// fingerprintBody takes the fingerprint of a request body as the handler
+// reads it.
+type fingerprintBody struct {
+	io.ReadCloser
+	fingerprint *fingerprint
+	eof         bool
+}
+
+func (b *fingerprintBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	_, _ = b.fingerprint.Write(p[:n])
+	if err == io.EOF {
+		b.eof = true
+	}
+
+	return n, err
+}
+
+// drain reads what little the handler may have left of the body, such as
+// the end of a multipart form, and reports whether the whole body went into
+// the fingerprint.
+func (b *fingerprintBody) drain() bool {
+	if !b.eof {
+		_, _ = io.CopyN(io.Discard, b, maxDrain)
+	}
+
+	return b.eof
+}
+
+// recorder keeps a copy of the response it passes through, up to limit
+// bytes of body.
+type recorder struct {
+	http.ResponseWriter
+	status   int
+	header   http.Header
+	body     bytes.Buffer
+	limit    int
+	overflow bool
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+		rec.header = rec.ResponseWriter.Header().Clone()
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *recorder) Write(p []byte) (int, error) {
+	if rec.status == 0 {
+		rec.WriteHeader(http.StatusOK)
+	}
+
+	if !rec.overflow {
+		if rec.body.Len()+len(p) > rec.limit {
+			rec.overflow = true
+			rec.body = bytes.Buffer{}
+		} else {
+			rec.body.Write(p)
+		}
+	}
+
+	return rec.ResponseWriter.Write(p)
+}
+
+// Unwrap lets http.ResponseController reach the connection.
+func (rec *recorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+func (rec *recorder) statusCode() int {
+	if rec.status == 0 {
+		return http.StatusOK
+	}
+
+	return rec.status
+}
+
+func (rec *recorder) headers() http.Header {
+	if rec.header == nil {
+		return rec.ResponseWriter.Header().Clone()
+	}
+
+	return rec.header
+}
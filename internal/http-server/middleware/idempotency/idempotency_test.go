@@ -0,0 +1,260 @@
+package idempotency_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"imageProcessor/internal/config"
+	"imageProcessor/internal/http-server/middleware/idempotency"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/lib/logger/handlers/slogdiscard"
+	"imageProcessor/internal/models"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type memStore struct {
+	mu       sync.Mutex
+	requests map[string]*models.IdempotentRequest
+}
+
+func newMemStore() *memStore {
+	return &memStore{requests: make(map[string]*models.IdempotentRequest)}
+}
+
+func (s *memStore) ClaimIdempotencyKey(_ context.Context, keyID uuid.UUID, key string, _, _ time.Duration) (*models.IdempotentRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if request, ok := s.requests[keyID.String()+key]; ok {
+		copied := *request
+		return &copied, nil
+	}
+	s.requests[keyID.String()+key] = &models.IdempotentRequest{Key: key}
+
+	return nil, nil
+}
+
+func (s *memStore) SaveIdempotentResponse(_ context.Context, keyID uuid.UUID, request models.IdempotentRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	request.Completed = true
+	s.requests[keyID.String()+request.Key] = &request
+
+	return nil
+}
+
+func (s *memStore) ReleaseIdempotencyKey(_ context.Context, keyID uuid.UUID, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if request, ok := s.requests[keyID.String()+key]; ok && !request.Completed {
+		delete(s.requests, keyID.String()+key)
+	}
+
+	return nil
+}
+
+func multipartBody(t *testing.T, content string) (string, []byte) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	part, err := mw.CreateFormFile("image", "cat.png")
+	require.NoError(t, err)
+	_, err = part.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, mw.Close())
+
+	return mw.FormDataContentType(), buf.Bytes()
+}
+
+func TestIdempotency(t *testing.T) {
+	cfg := &config.Idempotency{TTL: time.Hour, LockTimeout: time.Minute, MaxResponseSize: 1024}
+
+	type request struct {
+		method      string
+		key         string
+		contentType string
+		body        []byte
+	}
+
+	jsonRequest := func(key, body string) request {
+		return request{method: http.MethodPost, key: key, contentType: "application/json", body: []byte(body)}
+	}
+
+	firstType, firstBody := multipartBody(t, "png bytes")
+	secondType, secondBody := multipartBody(t, "png bytes")
+	otherType, otherBody := multipartBody(t, "other png bytes")
+
+	tests := []struct {
+		name           string
+		status         int
+		inProgress     string
+		requests       []request
+		expectedStatus []int
+		expectedCalls  int
+		replayed       bool
+	}{
+		{
+			name:           "Replay",
+			status:         http.StatusCreated,
+			requests:       []request{jsonRequest("k1", `{"a":1}`), jsonRequest("k1", `{"a":1}`)},
+			expectedStatus: []int{http.StatusCreated, http.StatusCreated},
+			expectedCalls:  1,
+			replayed:       true,
+		},
+		{
+			name:           "Different Payload",
+			status:         http.StatusCreated,
+			requests:       []request{jsonRequest("k1", `{"a":1}`), jsonRequest("k1", `{"a":2}`)},
+			expectedStatus: []int{http.StatusCreated, http.StatusUnprocessableEntity},
+			expectedCalls:  1,
+		},
+		{
+			name:           "Different Keys",
+			status:         http.StatusCreated,
+			requests:       []request{jsonRequest("k1", `{"a":1}`), jsonRequest("k2", `{"a":1}`)},
+			expectedStatus: []int{http.StatusCreated, http.StatusCreated},
+			expectedCalls:  2,
+		},
+		{
+			name:   "Multipart With New Boundary",
+			status: http.StatusAccepted,
+			requests: []request{
+				{method: http.MethodPost, key: "k1", contentType: firstType, body: firstBody},
+				{method: http.MethodPost, key: "k1", contentType: secondType, body: secondBody},
+			},
+			expectedStatus: []int{http.StatusAccepted, http.StatusAccepted},
+			expectedCalls:  1,
+			replayed:       true,
+		},
+		{
+			name:   "Multipart With Different File",
+			status: http.StatusAccepted,
+			requests: []request{
+				{method: http.MethodPost, key: "k1", contentType: firstType, body: firstBody},
+				{method: http.MethodPost, key: "k1", contentType: otherType, body: otherBody},
+			},
+			expectedStatus: []int{http.StatusAccepted, http.StatusUnprocessableEntity},
+			expectedCalls:  1,
+		},
+		{
+			name:           "In Progress",
+			status:         http.StatusCreated,
+			inProgress:     "k1",
+			requests:       []request{jsonRequest("k1", `{"a":1}`)},
+			expectedStatus: []int{http.StatusConflict},
+			expectedCalls:  0,
+		},
+		{
+			name:           "Server Error Not Kept",
+			status:         http.StatusInternalServerError,
+			requests:       []request{jsonRequest("k1", `{"a":1}`), jsonRequest("k1", `{"a":1}`)},
+			expectedStatus: []int{http.StatusInternalServerError, http.StatusInternalServerError},
+			expectedCalls:  2,
+		},
+		{
+			name:           "Client Error Kept",
+			status:         http.StatusBadRequest,
+			requests:       []request{jsonRequest("k1", `{"a":1}`), jsonRequest("k1", `{"a":1}`)},
+			expectedStatus: []int{http.StatusBadRequest, http.StatusBadRequest},
+			expectedCalls:  1,
+			replayed:       true,
+		},
+		{
+			name:   "Delete Replay",
+			status: http.StatusOK,
+			requests: []request{
+				{method: http.MethodDelete, key: "k1"},
+				{method: http.MethodDelete, key: "k1"},
+			},
+			expectedStatus: []int{http.StatusOK, http.StatusOK},
+			expectedCalls:  1,
+			replayed:       true,
+		},
+		{
+			name:   "Other Methods Ignored",
+			status: http.StatusOK,
+			requests: []request{
+				{method: http.MethodPut, key: "k1"},
+				{method: http.MethodPut, key: "k1"},
+			},
+			expectedStatus: []int{http.StatusOK, http.StatusOK},
+			expectedCalls:  2,
+		},
+		{
+			name:           "No Key",
+			status:         http.StatusCreated,
+			requests:       []request{jsonRequest("", `{"a":1}`), jsonRequest("", `{"a":1}`)},
+			expectedStatus: []int{http.StatusCreated, http.StatusCreated},
+			expectedCalls:  2,
+		},
+		{
+			name:           "Invalid Key",
+			status:         http.StatusCreated,
+			requests:       []request{jsonRequest("bad key", `{"a":1}`)},
+			expectedStatus: []int{http.StatusBadRequest},
+			expectedCalls:  0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := &models.APIKey{ID: uuid.New()}
+
+			store := newMemStore()
+			if tt.inProgress != "" {
+				_, err := store.ClaimIdempotencyKey(context.Background(), key.ID, tt.inProgress, cfg.TTL, cfg.LockTimeout)
+				require.NoError(t, err)
+			}
+
+			calls := 0
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				body, err := io.ReadAll(r.Body)
+				require.NoError(t, err)
+
+				w.Header().Set("X-Call", fmt.Sprint(calls))
+				w.WriteHeader(tt.status)
+				_, _ = fmt.Fprintf(w, `{"call":%d,"size":%d}`, calls, len(body))
+			})
+			handler := idempotency.New(slogdiscard.NewDiscardLogger(), store, cfg)(next)
+
+			var rr *httptest.ResponseRecorder
+			var first []byte
+			for i, req := range tt.requests {
+				r := httptest.NewRequest(req.method, "/upload", bytes.NewReader(req.body))
+				r.Header.Set("Content-Type", req.contentType)
+				if req.key != "" {
+					r.Header.Set(idempotency.HeaderKey, req.key)
+				}
+				r = r.WithContext(apikey.WithKey(r.Context(), key))
+
+				rr = httptest.NewRecorder()
+				handler.ServeHTTP(rr, r)
+
+				require.Equal(t, tt.expectedStatus[i], rr.Code, "request %d", i)
+				if i == 0 {
+					first = rr.Body.Bytes()
+				}
+			}
+
+			require.Equal(t, tt.expectedCalls, calls)
+
+			if tt.replayed {
+				require.Equal(t, "true", rr.Header().Get(idempotency.HeaderReplayed))
+				require.Equal(t, "1", rr.Header().Get("X-Call"))
+				require.Equal(t, string(first), rr.Body.String())
+			} else {
+				require.Empty(t, rr.Header().Get(idempotency.HeaderReplayed))
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package signature
+
+import (
+	"errors"
+	"github.com/go-chi/render"
+	"imageProcessor/internal/lib/api/response"
+	"imageProcessor/internal/lib/logger/sl"
+	"imageProcessor/internal/lib/tenant"
+	"imageProcessor/internal/lib/urlsign"
+	"log/slog"
+	"net/http"
+	"net/url"
+)
+
+type Verifier interface {
+	Verify(path string, query url.Values) error
+}
+
+// New rejects requests whose URL does not carry a valid signature, so media
+// can only be fetched through links handed out by the API. The tenant the
+// link was signed for is stored in the request context.
+func New(log *slog.Logger, verifier Verifier) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		log := log.With(slog.String("component", "middleware/signature"))
+
+		log.Info("signature middleware enabled")
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			err := verifier.Verify(r.URL.Path, r.URL.Query())
+			if err != nil {
+				log.Warn("rejected request with bad signature", slog.String("path", r.URL.Path), sl.Err(err))
+
+				msg := "invalid signature"
+				switch {
+				case errors.Is(err, urlsign.ErrMissingSignature):
+					msg = "missing signature"
+				case errors.Is(err, urlsign.ErrExpired):
+					msg = "signature expired"
+				}
+
+				render.Status(r, http.StatusForbidden)
+				render.JSON(w, r, response.Error(msg))
+				return
+			}
+
+			tenantID := r.URL.Query().Get(tenant.QueryParam)
+			if tenantID == "" {
+				log.Warn("rejected signed link without a tenant", slog.String("path", r.URL.Path))
+				render.Status(r, http.StatusForbidden)
+				render.JSON(w, r, response.Error("missing tenant"))
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(tenant.WithID(r.Context(), tenantID)))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,116 @@
+package signature_test
+
+import (
+	"github.com/stretchr/testify/require"
+	"imageProcessor/internal/config"
+	"imageProcessor/internal/http-server/middleware/signature"
+	"imageProcessor/internal/lib/logger/handlers/slogdiscard"
+	"imageProcessor/internal/lib/tenant"
+	"imageProcessor/internal/lib/urlsign"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignature(t *testing.T) {
+	oldSigner, err := urlsign.New(&config.URLSigning{
+		Keys:      map[string]string{"old": "old-secret"},
+		ActiveKey: "old",
+		TTL:       time.Hour,
+	})
+	require.NoError(t, err)
+
+	signer, err := urlsign.New(&config.URLSigning{
+		Keys:      map[string]string{"old": "old-secret", "new": "new-secret"},
+		ActiveKey: "new",
+		TTL:       time.Hour,
+	})
+	require.NoError(t, err)
+
+	expiredSigner, err := urlsign.New(&config.URLSigning{
+		Keys:      map[string]string{"new": "new-secret"},
+		ActiveKey: "new",
+		TTL:       -time.Minute,
+	})
+	require.NoError(t, err)
+
+	path := "/image/123/transform"
+	params := url.Values{"w": {"320"}, tenant.QueryParam: {"shop"}}
+
+	tests := []struct {
+		name           string
+		target         string
+		expectedStatus int
+		expectedError  string
+	}{
+		{
+			name:           "Valid",
+			target:         signer.Sign(path, params),
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Rotated Key",
+			target:         oldSigner.Sign(path, params),
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Missing Signature",
+			target:         path + "?w=320&tenant=shop",
+			expectedStatus: http.StatusForbidden,
+			expectedError:  "missing signature",
+		},
+		{
+			name:           "Tampered Params",
+			target:         strings.Replace(signer.Sign(path, params), "w=320", "w=4000", 1),
+			expectedStatus: http.StatusForbidden,
+			expectedError:  "invalid signature",
+		},
+		{
+			name:           "Missing Tenant",
+			target:         signer.Sign(path, url.Values{"w": {"320"}}),
+			expectedStatus: http.StatusForbidden,
+			expectedError:  "missing tenant",
+		},
+		{
+			name:           "Tampered Tenant",
+			target:         strings.Replace(signer.Sign(path, params), "tenant=shop", "tenant=cms", 1),
+			expectedStatus: http.StatusForbidden,
+			expectedError:  "invalid signature",
+		},
+		{
+			name:           "Other Path",
+			target:         strings.Replace(signer.Sign(path, params), "/123/", "/456/", 1),
+			expectedStatus: http.StatusForbidden,
+			expectedError:  "invalid signature",
+		},
+		{
+			name:           "Expired",
+			target:         expiredSigner.Sign(path, params),
+			expectedStatus: http.StatusForbidden,
+			expectedError:  "signature expired",
+		},
+	}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenantID, err := tenant.FromContext(r.Context())
+		require.NoError(t, err)
+		require.Equal(t, "shop", tenantID)
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := signature.New(slogdiscard.NewDiscardLogger(), signer)(next)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedError != "" {
+				require.Contains(t, rr.Body.String(), tt.expectedError)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package throttle
+
+import (
+	"context"
+	"github.com/go-chi/render"
+	"imageProcessor/internal/lib/api/response"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/lib/logger/sl"
+	"imageProcessor/internal/lib/ratelimit"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+type Limiter interface {
+	Allow(ctx context.Context, key string, rule ratelimit.Rule) (ratelimit.Result, error)
+}
+
+// New limits the requests every client makes to the route group to rule and
+// reports the client's budget in RateLimit-* headers. Clients are told when
+// to come back with Retry-After once it is spent. A client is its API key
+// when New runs after auth.New and its IP address otherwise.
+//
+// Requests are let through if the limiter fails, so that an outage of the
+// shared counters does not take the API down with it.
+func New(log *slog.Logger, limiter Limiter, route string, rule ratelimit.Rule) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if rule.Limit == 0 {
+			return next
+		}
+
+		log := log.With(slog.String("component", "middleware/throttle"), slog.String("route", route))
+
+		log.Info("rate limit enabled", slog.Int("limit", rule.Limit), slog.Duration("window", rule.Window))
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			client := clientID(r)
+
+			res, err := limiter.Allow(r.Context(), route+":"+client, rule)
+			if err != nil {
+				log.Error("failed to check rate limit", sl.Err(err))
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ratelimit.SetHeaders(w.Header(), res)
+
+			if !res.Allowed {
+				log.Warn("rate limit exceeded", slog.String("client", client))
+				w.Header().Set("Retry-After", strconv.Itoa(ratelimit.Seconds(res.RetryAfter)))
+				render.Status(r, http.StatusTooManyRequests)
+				render.JSON(w, r, response.Error("rate limit exceeded"))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+func clientID(r *http.Request) string {
+	if key, ok := apikey.FromContext(r.Context()); ok {
+		return "key:" + key.ID.String()
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		// RealIP leaves a bare address behind.
+		host = r.RemoteAddr
+	}
+
+	return "ip:" + host
+}
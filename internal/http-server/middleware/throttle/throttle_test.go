@@ -0,0 +1,127 @@
+package throttle_test
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"imageProcessor/internal/http-server/middleware/throttle"
+	"imageProcessor/internal/lib/apikey"
+	"imageProcessor/internal/lib/logger/handlers/slogdiscard"
+	"imageProcessor/internal/lib/ratelimit"
+	"imageProcessor/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type failingLimiter struct{}
+
+func (failingLimiter) Allow(context.Context, string, ratelimit.Rule) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("db is down")
+}
+
+func TestThrottle(t *testing.T) {
+	rule := ratelimit.Rule{Limit: 2, Window: time.Minute}
+	key := &models.APIKey{ID: uuid.New()}
+
+	type request struct {
+		remoteAddr string
+		key        *models.APIKey
+	}
+
+	tests := []struct {
+		name            string
+		limiter         throttle.Limiter
+		rule            ratelimit.Rule
+		requests        []request
+		expectedStatus  []int
+		expectedHeaders map[string]string
+	}{
+		{
+			name:           "Within Limit",
+			rule:           rule,
+			requests:       []request{{remoteAddr: "10.0.0.1:1234"}, {remoteAddr: "10.0.0.1:1234"}},
+			expectedStatus: []int{http.StatusOK, http.StatusOK},
+			expectedHeaders: map[string]string{
+				ratelimit.HeaderLimit:     "2",
+				ratelimit.HeaderRemaining: "0",
+				ratelimit.HeaderReset:     "60",
+				ratelimit.HeaderPolicy:    "2;w=60",
+			},
+		},
+		{
+			name:           "Over Limit",
+			rule:           rule,
+			requests:       []request{{remoteAddr: "10.0.0.1:1234"}, {remoteAddr: "10.0.0.1:1234"}, {remoteAddr: "10.0.0.1:4321"}},
+			expectedStatus: []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+			expectedHeaders: map[string]string{
+				ratelimit.HeaderRemaining: "0",
+				"Retry-After":             "30",
+			},
+		},
+		{
+			name:           "Clients Have Own Buckets",
+			rule:           rule,
+			requests:       []request{{remoteAddr: "10.0.0.1:1234"}, {remoteAddr: "10.0.0.1:1234"}, {remoteAddr: "10.0.0.2:1234"}},
+			expectedStatus: []int{http.StatusOK, http.StatusOK, http.StatusOK},
+		},
+		{
+			name:           "Keyed By API Key",
+			rule:           rule,
+			requests:       []request{{remoteAddr: "10.0.0.1:1234", key: key}, {remoteAddr: "10.0.0.2:1234", key: key}, {remoteAddr: "10.0.0.3:1234", key: key}},
+			expectedStatus: []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name:           "No Rule",
+			requests:       []request{{remoteAddr: "10.0.0.1:1234"}, {remoteAddr: "10.0.0.1:1234"}, {remoteAddr: "10.0.0.1:1234"}},
+			expectedStatus: []int{http.StatusOK, http.StatusOK, http.StatusOK},
+			expectedHeaders: map[string]string{
+				ratelimit.HeaderLimit: "",
+			},
+		},
+		{
+			name:           "Limiter Error",
+			limiter:        failingLimiter{},
+			rule:           rule,
+			requests:       []request{{remoteAddr: "10.0.0.1:1234"}},
+			expectedStatus: []int{http.StatusOK},
+			expectedHeaders: map[string]string{
+				ratelimit.HeaderLimit: "",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := tt.limiter
+			if limiter == nil {
+				limiter = ratelimit.NewMemory()
+			}
+
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+			handler := throttle.New(slogdiscard.NewDiscardLogger(), limiter, "read", tt.rule)(next)
+
+			var rr *httptest.ResponseRecorder
+			for i, req := range tt.requests {
+				r := httptest.NewRequest(http.MethodGet, "/image/123", nil)
+				r.RemoteAddr = req.remoteAddr
+				if req.key != nil {
+					r = r.WithContext(apikey.WithKey(r.Context(), req.key))
+				}
+
+				rr = httptest.NewRecorder()
+				handler.ServeHTTP(rr, r)
+
+				require.Equal(t, tt.expectedStatus[i], rr.Code, "request %d", i)
+			}
+
+			for k, v := range tt.expectedHeaders {
+				require.Equal(t, v, rr.Header().Get(k), k)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package tusprotocol
+
+import (
+	"github.com/go-chi/render"
+	"imageProcessor/internal/lib/api/response"
+	"imageProcessor/internal/lib/tus"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// New speaks the version negotiation of the tus protocol for the routes it
+// wraps. OPTIONS requests are answered with the capabilities of the server;
+// any other request must declare the supported version in Tus-Resumable.
+func New(log *slog.Logger, maxSize int64) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		log := log.With(slog.String("component", "middleware/tusprotocol"))
+
+		log.Info("tus protocol middleware enabled")
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(tus.HeaderResumable, tus.Version)
+
+			if r.Method == http.MethodOptions {
+				w.Header().Set(tus.HeaderVersion, tus.Version)
+				w.Header().Set(tus.HeaderExtension, tus.Extensions)
+				w.Header().Set(tus.HeaderMaxSize, strconv.FormatInt(maxSize, 10))
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			if r.Header.Get(tus.HeaderResumable) != tus.Version {
+				log.Warn("rejected unsupported tus version", slog.String("version", r.Header.Get(tus.HeaderResumable)))
+				w.Header().Set(tus.HeaderVersion, tus.Version)
+				render.Status(r, http.StatusPreconditionFailed)
+				render.JSON(w, r, response.Error("unsupported tus version"))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
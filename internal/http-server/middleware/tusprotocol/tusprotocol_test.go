@@ -0,0 +1,79 @@
+package tusprotocol_test
+
+import (
+	"github.com/stretchr/testify/require"
+	"imageProcessor/internal/http-server/middleware/tusprotocol"
+	"imageProcessor/internal/lib/logger/handlers/slogdiscard"
+	"imageProcessor/internal/lib/tus"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTusProtocol(t *testing.T) {
+	tests := []struct {
+		name            string
+		method          string
+		version         string
+		expectedStatus  int
+		expectedHeaders map[string]string
+	}{
+		{
+			name:           "Supported Version",
+			method:         http.MethodPatch,
+			version:        "1.0.0",
+			expectedStatus: http.StatusNoContent,
+			expectedHeaders: map[string]string{
+				tus.HeaderResumable: "1.0.0",
+			},
+		},
+		{
+			name:           "Options",
+			method:         http.MethodOptions,
+			expectedStatus: http.StatusNoContent,
+			expectedHeaders: map[string]string{
+				tus.HeaderResumable: "1.0.0",
+				tus.HeaderVersion:   "1.0.0",
+				tus.HeaderExtension: "creation,termination,expiration",
+				tus.HeaderMaxSize:   "1024",
+			},
+		},
+		{
+			name:           "Unsupported Version",
+			method:         http.MethodPost,
+			version:        "0.2.2",
+			expectedStatus: http.StatusPreconditionFailed,
+			expectedHeaders: map[string]string{
+				tus.HeaderVersion: "1.0.0",
+			},
+		},
+		{
+			name:           "Missing Version",
+			method:         http.MethodHead,
+			expectedStatus: http.StatusPreconditionFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			})
+
+			handler := tusprotocol.New(slogdiscard.NewDiscardLogger(), 1024)(next)
+
+			req := httptest.NewRequest(tt.method, "/uploads/tus", nil)
+			if tt.version != "" {
+				req.Header.Set(tus.HeaderResumable, tt.version)
+			}
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+			for k, v := range tt.expectedHeaders {
+				require.Equal(t, v, rr.Header().Get(k), k)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package imagehash
+
+import (
+	"github.com/disintegration/imaging"
+	"image"
+	"math"
+	"math/bits"
+	"slices"
+)
+
+// Hashes are 64-bit perceptual hashes of an image: resized, recompressed
+// or slightly retouched copies of it hash to values a few bits apart.
+type Hashes struct {
+	// Average compares an 8x8 thumbnail with its mean brightness.
+	Average uint64
+	// Difference compares neighbouring pixels of a 9x8 thumbnail.
+	Difference uint64
+	// Perceptual compares the low frequencies of a 32x32 thumbnail's DCT
+	// with their median, which makes it the most robust of the three.
+	Perceptual uint64
+}
+
+func Compute(img image.Image) Hashes {
+	return Hashes{
+		Average:    Average(img),
+		Difference: Difference(img),
+		Perceptual: Perceptual(img),
+	}
+}
+
+func Average(img image.Image) uint64 {
+	pixels := gray(img, 8, 8)
+
+	var sum float64
+	for _, p := range pixels {
+		sum += p
+	}
+	mean := sum / float64(len(pixels))
+
+	var hash uint64
+	for _, p := range pixels {
+		hash <<= 1
+		if p > mean {
+			hash |= 1
+		}
+	}
+
+	return hash
+}
+
+func Difference(img image.Image) uint64 {
+	pixels := gray(img, 9, 8)
+
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if pixels[y*9+x] < pixels[y*9+x+1] {
+				hash |= 1
+			}
+		}
+	}
+
+	return hash
+}
+
+const (
+	dctSize  = 32
+	dctLower = 8
+)
+
+// dctCos holds the DCT-II basis: dctCos[u][x] = cos((2x+1)uπ/2N).
+var dctCos = func() [dctLower][dctSize]float64 {
+	var table [dctLower][dctSize]float64
+	for u := range table {
+		for x := range table[u] {
+			table[u][x] = math.Cos(float64(2*x+1) * float64(u) * math.Pi / (2 * dctSize))
+		}
+	}
+
+	return table
+}()
+
+func Perceptual(img image.Image) uint64 {
+	pixels := gray(img, dctSize, dctSize)
+
+	// Only the lowest 8x8 frequencies are needed, so the transform of the
+	// rows is cut to them before the columns are transformed.
+	var rows [dctSize][dctLower]float64
+	for y := 0; y < dctSize; y++ {
+		for u := 0; u < dctLower; u++ {
+			var sum float64
+			for x := 0; x < dctSize; x++ {
+				sum += pixels[y*dctSize+x] * dctCos[u][x]
+			}
+			rows[y][u] = sum
+		}
+	}
+
+	var coefficients [dctLower * dctLower]float64
+	for v := 0; v < dctLower; v++ {
+		for u := 0; u < dctLower; u++ {
+			var sum float64
+			for y := 0; y < dctSize; y++ {
+				sum += rows[y][u] * dctCos[v][y]
+			}
+			coefficients[v*dctLower+u] = sum
+		}
+	}
+
+	// The DC term is the mean brightness and would skew the median.
+	sorted := slices.Clone(coefficients[1:])
+	slices.Sort(sorted)
+	median := sorted[len(sorted)/2]
+
+	var hash uint64
+	for _, c := range coefficients {
+		hash <<= 1
+		if c > median {
+			hash |= 1
+		}
+	}
+
+	return hash
+}
+
+// Distance is the number of bits two hashes differ in.
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// gray scales img down to width x height and returns the brightness of its
+// pixels, row by row.
+func gray(img image.Image, width, height int) []float64 {
+	small := imaging.Grayscale(imaging.Resize(img, width, height, imaging.Box))
+
+	pixels := make([]float64, 0, width*height)
+	for i := 0; i < len(small.Pix); i += 4 {
+		pixels = append(pixels, float64(small.Pix[i]))
+	}
+
+	return pixels
+}
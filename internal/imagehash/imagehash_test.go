@@ -0,0 +1,127 @@
+package imagehash_test
+
+import (
+	"bytes"
+	"github.com/disintegration/imaging"
+	"github.com/stretchr/testify/require"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"imageProcessor/internal/imagehash"
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+// photo draws a smooth scene with a few shapes, a stand-in for a photo.
+func photo(width, height int, seed float64) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			fx, fy := float64(x)/float64(width), float64(y)/float64(height)
+			v := 128 + 60*math.Sin(6*fx+seed) + 50*math.Cos(5*fy*seed)
+			if math.Hypot(fx-0.3, fy-0.6) < 0.15 {
+				v = 240
+			}
+			if fx > 0.6 && fx < 0.85 && fy > 0.1 && fy < 0.4 {
+				v = 20
+			}
+			img.Set(x, y, color.NRGBA{R: uint8(v), G: uint8(v * 0.8), B: uint8(255 - v), A: 255})
+		}
+	}
+
+	return img
+}
+
+func recompress(t *testing.T, img image.Image, quality int) image.Image {
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}))
+
+	decoded, err := jpeg.Decode(&buf)
+	require.NoError(t, err)
+
+	return decoded
+}
+
+func TestCompute(t *testing.T) {
+	original := photo(640, 480, 1)
+	hashes := imagehash.Compute(original)
+
+	tests := []struct {
+		name    string
+		img     image.Image
+		similar bool
+	}{
+		{name: "Same", img: original, similar: true},
+		{name: "Resized", img: imaging.Resize(original, 200, 0, imaging.Lanczos), similar: true},
+		{name: "Recompressed", img: recompress(t, original, 30), similar: true},
+		{name: "Resized And Recompressed", img: recompress(t, imaging.Resize(original, 1024, 0, imaging.Linear), 50), similar: true},
+		{name: "Different", img: photo(640, 480, 4), similar: false},
+		{name: "Mirrored", img: imaging.FlipH(original), similar: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := imagehash.Compute(tt.img)
+
+			distance := imagehash.Distance(hashes.Perceptual, got.Perceptual)
+			if tt.similar {
+				require.LessOrEqual(t, distance, 6)
+				require.LessOrEqual(t, imagehash.Distance(hashes.Difference, got.Difference), 10)
+				require.LessOrEqual(t, imagehash.Distance(hashes.Average, got.Average), 10)
+			} else {
+				require.Greater(t, distance, 12)
+			}
+		})
+	}
+}
+
+func TestProbes(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+
+	for _, maxDistance := range []int{0, 3, 4, 10, 12} {
+		for i := 0; i < 200; i++ {
+			hash := rnd.Uint64()
+
+			// Flip up to maxDistance random bits.
+			other := hash
+			for _, bit := range rnd.Perm(64)[:rnd.Intn(maxDistance+1)] {
+				other ^= 1 << bit
+			}
+
+			probes := imagehash.Probes(hash, maxDistance)
+			found := false
+			for chunk, value := range imagehash.Split(other) {
+				found = found || slices.Contains(probes[chunk], value)
+			}
+			require.True(t, found, "distance %d, max %d", imagehash.Distance(hash, other), maxDistance)
+		}
+	}
+}
+
+func TestProbesCount(t *testing.T) {
+	tests := []struct {
+		maxDistance int
+		expected    int
+	}{
+		{maxDistance: 3, expected: 1},
+		{maxDistance: 4, expected: 1 + 16},
+		{maxDistance: 8, expected: 1 + 16 + 120},
+		{maxDistance: 12, expected: 1 + 16 + 120 + 560},
+	}
+
+	for _, tt := range tests {
+		probes := imagehash.Probes(0xdeadbeefcafebabe, tt.maxDistance)
+		for _, values := range probes {
+			require.Len(t, values, tt.expected)
+
+			slices.Sort(values)
+			require.Len(t, slices.Compact(values), tt.expected)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	require.Equal(t, [imagehash.Chunks]uint16{0xdead, 0xbeef, 0xcafe, 0xbabe}, imagehash.Split(0xdeadbeefcafebabe))
+}
@@ -0,0 +1,56 @@
+package imagehash
+
+// Hashes are searched by multi-index hashing: a hash is split into Chunks
+// chunks of 16 bits, each indexed on its own. Two hashes at most d bits
+// apart differ in at most d/Chunks bits in at least one of the chunks, so
+// every match is found by looking up the values each chunk of the hash
+// has within that radius, and only the few rows found need comparing in
+// full.
+
+const (
+	Chunks    = 4
+	chunkBits = 64 / Chunks
+)
+
+// Split returns the chunks of hash, highest bits first.
+func Split(hash uint64) [Chunks]uint16 {
+	var chunks [Chunks]uint16
+	for i := range chunks {
+		chunks[i] = uint16(hash >> (chunkBits * (Chunks - 1 - i)))
+	}
+
+	return chunks
+}
+
+// Probes returns, for each chunk of hash, the values a chunk of a hash at
+// most maxDistance bits from hash may have in the chunk it is closest in.
+func Probes(hash uint64, maxDistance int) [Chunks][]uint16 {
+	radius := maxDistance / Chunks
+
+	var probes [Chunks][]uint16
+	for i, chunk := range Split(hash) {
+		probes[i] = neighbours(chunk, radius)
+	}
+
+	return probes
+}
+
+// neighbours returns the values at most radius bits from v, v included.
+func neighbours(v uint16, radius int) []uint16 {
+	values := []uint16{v}
+
+	var flip func(v uint16, from, left int)
+	flip = func(v uint16, from, left int) {
+		if left == 0 {
+			return
+		}
+		for bit := from; bit < chunkBits; bit++ {
+			flipped := v ^ 1<<bit
+			values = append(values, flipped)
+			flip(flipped, bit+1, left-1)
+		}
+	}
+	flip(v, 0, radius)
+
+	return values
+}
@@ -0,0 +1,275 @@
+package ingest
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"hash"
+	"imageProcessor/internal/kafka/producer"
+	"imageProcessor/internal/lib/imageformat"
+	"imageProcessor/internal/lib/quota"
+	"imageProcessor/internal/lib/tenant"
+	"imageProcessor/internal/models"
+	"imageProcessor/internal/storage"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	uploadDir       = "uploads"
+	statusProcessed = "processed"
+)
+
+var (
+	ErrEmpty           = errors.New("received empty file")
+	ErrUnsupportedType = errors.New("unsupported image type")
+	// ErrRead marks a body that failed before its type could be told.
+	ErrRead = errors.New("failed to read image")
+	// ErrTrailer marks a failure of Source.Trailer.
+	ErrTrailer = errors.New("failed to read trailer")
+	// ErrSave marks a failure to record the image once it is stored.
+	ErrSave = errors.New("failed to save image metadata")
+	// ErrEnqueue is returned when the image has been recorded but its
+	// processing could not be started.
+	ErrEnqueue = errors.New("failed to start image processing")
+)
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=ImageSaver
+type ImageSaver interface {
+	SaveImage(ctx context.Context, upload models.Upload, limits quota.Set) (*models.Image, error)
+	GetUsage(ctx context.Context, keyID *uuid.UUID) (models.Usage, *models.Usage, error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=BlobStorage
+type BlobStorage interface {
+	Put(ctx context.Context, key string, r io.Reader) (*storage.BlobInfo, error)
+	Delete(ctx context.Context, key string) error
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.51.1 --name=QuotaResolver
+type QuotaResolver interface {
+	For(tenantID string, keyID *uuid.UUID) quota.Set
+}
+
+// Ingester turns uploads into images the way every upload route does: it
+// tells their type, checks the quotas, stores the original, records the
+// image and queues it for processing.
+type Ingester struct {
+	imageSaver    ImageSaver
+	blobStorage   BlobStorage
+	quotas        QuotaResolver
+	kafkaProducer producer.ProducerIface
+}
+
+func New(imageSaver ImageSaver, blobStorage BlobStorage, quotas QuotaResolver, kafkaProducer producer.ProducerIface) *Ingester {
+	return &Ingester{
+		imageSaver:    imageSaver,
+		blobStorage:   blobStorage,
+		quotas:        quotas,
+		kafkaProducer: kafkaProducer,
+	}
+}
+
+// Source is an image as it is received.
+type Source struct {
+	TenantID   string
+	OwnerKeyID *uuid.UUID
+	Filename   string
+	// Size is announced before the body is read, and is -1 when it isn't.
+	// Quotas are checked against it up front and against the stored size
+	// once the image is recorded.
+	Size        int64
+	Body        io.Reader
+	CallbackURL string
+	// Trailer, if set, reads what the client sent after the body once it
+	// is stored, which may still name the callback URL.
+	Trailer func() (callbackURL string, err error)
+}
+
+// Result is what became of an upload.
+type Result struct {
+	// Image is set once the upload has been recorded, or is the image it
+	// duplicates.
+	Image  *models.Image
+	Format imageformat.Format
+	// Duplicate is set instead of recording an upload whose content the
+	// uploader has as Image already.
+	Duplicate *storage.DuplicateError
+	// Scopes are the quotas the upload counts against, as of Now. They
+	// include the upload once it has been recorded.
+	Scopes []quota.Scope
+	Now    time.Time
+}
+
+// Ingest stores src and queues it for processing. The result is returned
+// even if that fails, for the quotas to be reported. Errors of the body
+// while it is stored are passed on as they are, so are those of the quota
+// checks. An upload duplicating an image is not an error; the result tells.
+func (i *Ingester) Ingest(ctx context.Context, src Source) (*Result, error) {
+	const op = "ingest.Ingest"
+
+	res := &Result{Now: time.Now()}
+
+	// The body is read once, straight into storage, so its leading bytes
+	// are peeked at rather than consumed.
+	body := bufio.NewReaderSize(src.Body, imageformat.SniffLen)
+	header, err := body.Peek(imageformat.SniffLen)
+	if len(header) == 0 {
+		if errors.Is(err, io.EOF) {
+			return res, fmt.Errorf("%s: %w", op, ErrEmpty)
+		}
+		return res, fmt.Errorf("%s: %w: %w", op, ErrRead, err)
+	}
+
+	format, ok := imageformat.Sniff(header)
+	if !ok {
+		return res, fmt.Errorf("%s: %w", op, ErrUnsupportedType)
+	}
+	res.Format = format
+
+	limits := i.quotas.For(src.TenantID, src.OwnerKeyID)
+
+	tenantUsage, keyUsage, err := i.imageSaver.GetUsage(ctx, src.OwnerKeyID)
+	if err != nil {
+		return res, fmt.Errorf("%s: %w", op, err)
+	}
+
+	res.Scopes = []quota.Scope{{Limits: limits.Tenant, Usage: tenantUsage}}
+	if keyUsage != nil {
+		res.Scopes = append(res.Scopes, quota.Scope{Limits: limits.Key, Usage: *keyUsage})
+	}
+
+	// Reject early so that an upload over quota is never written. The
+	// storage checks again under lock when the image is recorded.
+	if err = quota.Check(res.Scopes, max(src.Size, 0), res.Now); err != nil {
+		return res, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// The client's file name is kept as metadata only; the blob gets a
+	// fresh name so uploads can neither collide nor escape the tenant.
+	key := tenant.BlobKey(src.TenantID, uploadDir, uuid.NewString()+strings.ToLower(filepath.Ext(src.Filename)))
+
+	// Without a size announced, the stream itself is held to the bytes left.
+	var stored io.Reader = body
+	if st := quota.NewStatus(res.Scopes, res.Now); st.BytesLimit > 0 {
+		stored = &cappedReader{r: stored, remaining: st.BytesRemaining}
+	}
+	digest := newDigestReader(stored)
+
+	info, err := i.blobStorage.Put(ctx, key, digest)
+	if err != nil {
+		return res, fmt.Errorf("%s: %w", op, err)
+	}
+
+	callbackURL := src.CallbackURL
+	if src.Trailer != nil {
+		if callbackURL, err = src.Trailer(); err != nil {
+			i.remove(ctx, info.Key)
+			return res, fmt.Errorf("%s: %w: %w", op, ErrTrailer, err)
+		}
+	}
+
+	image, err := i.imageSaver.SaveImage(ctx, models.Upload{
+		Filename:     src.Filename,
+		OriginalPath: info.Key,
+		Size:         digest.size,
+		OwnerKeyID:   src.OwnerKeyID,
+		CallbackURL:  callbackURL,
+		SHA256:       digest.sum(),
+	}, limits)
+	if errors.As(err, &res.Duplicate) {
+		i.remove(ctx, info.Key)
+		res.Image = res.Duplicate.Image
+		return res, nil
+	}
+	if errors.Is(err, quota.ErrStorageExceeded) || errors.Is(err, quota.ErrRateExceeded) {
+		i.remove(ctx, info.Key)
+		return res, fmt.Errorf("%s: %w", op, err)
+	}
+	if err != nil {
+		i.remove(ctx, info.Key)
+		return res, fmt.Errorf("%s: %w: %w", op, ErrSave, err)
+	}
+	res.Image = image
+
+	// The image shares the original of an identical upload, and if that
+	// one is processed, its variants too.
+	if image.OriginalPath != info.Key {
+		i.remove(ctx, info.Key)
+	}
+
+	for n := range res.Scopes {
+		res.Scopes[n] = res.Scopes[n].Add(image.Size, res.Now)
+	}
+
+	if image.Status == statusProcessed {
+		return res, nil
+	}
+
+	message, err := json.Marshal(models.ProcessingJob{
+		ImageID:      image.ID,
+		TenantID:     image.TenantID,
+		OriginalPath: image.OriginalPath,
+	})
+	if err != nil {
+		return res, fmt.Errorf("%s: %w: %w", op, ErrEnqueue, err)
+	}
+
+	if err = i.kafkaProducer.SendMessage(ctx, message); err != nil {
+		return res, fmt.Errorf("%s: %w: %w", op, ErrEnqueue, err)
+	}
+
+	return res, nil
+}
+
+// remove deletes a blob that is not needed after all. One left behind is
+// only wasted space, so failing to is not reported.
+func (i *Ingester) remove(ctx context.Context, key string) {
+	_ = i.blobStorage.Delete(ctx, key)
+}
+
+// cappedReader fails once more than remaining bytes have been read.
+type cappedReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (c *cappedReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.remaining -= int64(n)
+	if c.remaining < 0 {
+		return n, fmt.Errorf("%w: upload exceeds the bytes left", quota.ErrStorageExceeded)
+	}
+
+	return n, err
+}
+
+// digestReader takes the size and SHA-256 of what passes through it.
+type digestReader struct {
+	r    io.Reader
+	hash hash.Hash
+	size int64
+}
+
+func newDigestReader(r io.Reader) *digestReader {
+	return &digestReader{r: r, hash: sha256.New()}
+}
+
+func (d *digestReader) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	d.hash.Write(p[:n])
+	d.size += int64(n)
+
+	return n, err
+}
+
+func (d *digestReader) sum() string {
+	return hex.EncodeToString(d.hash.Sum(nil))
+}
@@ -0,0 +1,212 @@
+package ingest_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"imageProcessor/internal/config"
+	"imageProcessor/internal/ingest"
+	"imageProcessor/internal/ingest/mocks"
+	kafkaMocks "imageProcessor/internal/kafka/producer/mocks"
+	"imageProcessor/internal/lib/quota"
+	"imageProcessor/internal/models"
+	"imageProcessor/internal/storage"
+	"imageProcessor/internal/storage/local"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIngest(t *testing.T) {
+	keyID := uuid.New()
+	imageID := uuid.New()
+	existing := &models.Image{ID: uuid.New(), TenantID: "shop", OriginalPath: "shop/uploads/existing.jpg"}
+	// Uploads are told apart by their leading bytes, a JPEG's here.
+	content := "\xff\xd8\xff\xe0" + strings.Repeat("x", 16)
+	limits := quota.Set{Tenant: quota.Limits{MaxBytes: 100}}
+
+	tests := []struct {
+		name           string
+		body           string
+		limits         quota.Set
+		trailerErr     error
+		mockSaveErr    error
+		mockKafkaErr   error
+		checked        bool
+		saved          bool
+		expectedErr    error
+		expectedImage  *models.Image
+		expectedBlobs  int
+		expectedImages int64
+	}{
+		{
+			name:           "Success",
+			body:           content,
+			limits:         limits,
+			checked:        true,
+			saved:          true,
+			expectedImage:  &models.Image{ID: imageID},
+			expectedBlobs:  1,
+			expectedImages: 1,
+		},
+		{
+			name:        "Empty Body",
+			body:        "",
+			expectedErr: ingest.ErrEmpty,
+		},
+		{
+			name:        "Not an Image",
+			body:        "<html>not an image</html>",
+			expectedErr: ingest.ErrUnsupportedType,
+		},
+		{
+			name:        "Quota Cap Mid-Stream",
+			body:        content,
+			limits:      quota.Set{Tenant: quota.Limits{MaxBytes: 10}},
+			checked:     true,
+			expectedErr: quota.ErrStorageExceeded,
+		},
+		{
+			name:          "Duplicate Returned",
+			body:          content,
+			limits:        limits,
+			checked:       true,
+			mockSaveErr:   fmt.Errorf("storage.postgres.SaveImage: %w", &storage.DuplicateError{Image: existing}),
+			expectedImage: existing,
+		},
+		{
+			name:          "Duplicate Rejected",
+			body:          content,
+			limits:        limits,
+			checked:       true,
+			mockSaveErr:   fmt.Errorf("storage.postgres.SaveImage: %w", &storage.DuplicateError{Image: existing, Rejected: true}),
+			expectedImage: existing,
+		},
+		{
+			name:        "Trailer Failure",
+			body:        content,
+			limits:      limits,
+			trailerErr:  errors.New("multipart: NextPart: EOF"),
+			checked:     true,
+			expectedErr: ingest.ErrTrailer,
+		},
+		{
+			name:        "Save Failure",
+			body:        content,
+			limits:      limits,
+			checked:     true,
+			mockSaveErr: errors.New("db error"),
+			expectedErr: ingest.ErrSave,
+		},
+		{
+			name:           "Enqueue Failure",
+			body:           content,
+			limits:         limits,
+			checked:        true,
+			saved:          true,
+			mockKafkaErr:   errors.New("kafka error"),
+			expectedErr:    ingest.ErrEnqueue,
+			expectedImage:  &models.Image{ID: imageID},
+			expectedBlobs:  1,
+			expectedImages: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			blobs, err := local.New(&config.BlobStorage{Root: root})
+			require.NoError(t, err)
+
+			imageSaverMock := mocks.NewImageSaver(t)
+			quotaResolverMock := mocks.NewQuotaResolver(t)
+			kafkaProducerMock := kafkaMocks.NewProducerIface(t)
+
+			if tt.checked {
+				quotaResolverMock.On("For", "shop", &keyID).Return(tt.limits).Once()
+				imageSaverMock.On("GetUsage", mock.Anything, &keyID).Return(models.Usage{}, nil, nil).Once()
+			}
+			if tt.saved || tt.mockSaveErr != nil {
+				imageSaverMock.On("SaveImage", mock.Anything, mock.Anything, tt.limits).
+					Return(func(_ context.Context, upload models.Upload, _ quota.Set) (*models.Image, error) {
+						require.Equal(t, "cat.jpg", upload.Filename)
+						require.True(t, strings.HasPrefix(upload.OriginalPath, "shop/uploads/"), upload.OriginalPath)
+						require.Equal(t, int64(len(content)), upload.Size)
+						require.Equal(t, "https://cms.example.com/hooks", upload.CallbackURL)
+						if tt.mockSaveErr != nil {
+							return nil, tt.mockSaveErr
+						}
+						return &models.Image{ID: imageID, TenantID: "shop", OriginalPath: upload.OriginalPath, Size: upload.Size}, nil
+					}).Once()
+			}
+			if tt.saved {
+				kafkaProducerMock.On("SendMessage", mock.Anything, mock.MatchedBy(func(message []byte) bool {
+					var job models.ProcessingJob
+					return json.Unmarshal(message, &job) == nil && job.ImageID == imageID && job.TenantID == "shop"
+				})).Return(tt.mockKafkaErr).Once()
+			}
+
+			ingester := ingest.New(imageSaverMock, blobs, quotaResolverMock, kafkaProducerMock)
+			res, err := ingester.Ingest(context.Background(), ingest.Source{
+				TenantID:   "shop",
+				OwnerKeyID: &keyID,
+				Filename:   "cat.jpg",
+				Size:       -1,
+				Body:       strings.NewReader(tt.body),
+				Trailer: func() (string, error) {
+					return "https://cms.example.com/hooks", tt.trailerErr
+				},
+			})
+
+			if tt.expectedErr != nil {
+				require.ErrorIs(t, err, tt.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+			require.NotNil(t, res)
+
+			if tt.expectedImage != nil {
+				require.NotNil(t, res.Image)
+				require.Equal(t, tt.expectedImage.ID, res.Image.ID)
+			} else {
+				require.Nil(t, res.Image)
+			}
+
+			var duplicate *storage.DuplicateError
+			if errors.As(tt.mockSaveErr, &duplicate) {
+				require.Equal(t, duplicate, res.Duplicate)
+			} else {
+				require.Nil(t, res.Duplicate)
+			}
+
+			if tt.checked {
+				require.Equal(t, tt.expectedImages, res.Scopes[0].Usage.Images)
+			}
+
+			// Only a recorded image keeps the blob it was stored in.
+			require.Len(t, files(t, root), tt.expectedBlobs)
+		})
+	}
+}
+
+// files lists the blobs stored under root.
+func files(t *testing.T, root string) []string {
+	var list []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			list = append(list, path)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	return list
+}
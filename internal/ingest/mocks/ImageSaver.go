@@ -0,0 +1,101 @@
+// Code generated by mockery v2.51.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+
+	models "imageProcessor/internal/models"
+
+	quota "imageProcessor/internal/lib/quota"
+
+	uuid "github.com/google/uuid"
+)
+
+// ImageSaver is an autogenerated mock type for the ImageSaver type
+type ImageSaver struct {
+	mock.Mock
+}
+
+// GetUsage provides a mock function with given fields: ctx, keyID
+func (_m *ImageSaver) GetUsage(ctx context.Context, keyID *uuid.UUID) (models.Usage, *models.Usage, error) {
+	ret := _m.Called(ctx, keyID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetUsage")
+	}
+
+	var r0 models.Usage
+	var r1 *models.Usage
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, *uuid.UUID) (models.Usage, *models.Usage, error)); ok {
+		return rf(ctx, keyID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *uuid.UUID) models.Usage); ok {
+		r0 = rf(ctx, keyID)
+	} else {
+		r0 = ret.Get(0).(models.Usage)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *uuid.UUID) *models.Usage); ok {
+		r1 = rf(ctx, keyID)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(*models.Usage)
+		}
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, *uuid.UUID) error); ok {
+		r2 = rf(ctx, keyID)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// SaveImage provides a mock function with given fields: ctx, upload, limits
+func (_m *ImageSaver) SaveImage(ctx context.Context, upload models.Upload, limits quota.Set) (*models.Image, error) {
+	ret := _m.Called(ctx, upload, limits)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SaveImage")
+	}
+
+	var r0 *models.Image
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.Upload, quota.Set) (*models.Image, error)); ok {
+		return rf(ctx, upload, limits)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, models.Upload, quota.Set) *models.Image); ok {
+		r0 = rf(ctx, upload, limits)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.Image)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, models.Upload, quota.Set) error); ok {
+		r1 = rf(ctx, upload, limits)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewImageSaver creates a new instance of ImageSaver. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewImageSaver(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *ImageSaver {
+	mock := &ImageSaver{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,122 @@
+package imageformat
+
+import (
+	"fmt"
+	"github.com/disintegration/imaging"
+	"image"
+	"image/color"
+	"io"
+	"strings"
+)
+
+type Format struct {
+	Name        string
+	Extension   string
+	ContentType string
+	encoding    imaging.Format
+	// opaque formats lose transparency, so transparent sources are flattened
+	// onto a white background before encoding instead of turning black.
+	opaque bool
+}
+
+var (
+	JPEG = Format{Name: "jpeg", Extension: "jpg", ContentType: "image/jpeg", encoding: imaging.JPEG, opaque: true}
+	PNG  = Format{Name: "png", Extension: "png", ContentType: "image/png", encoding: imaging.PNG}
+	GIF  = Format{Name: "gif", Extension: "gif", ContentType: "image/gif", encoding: imaging.GIF}
+	TIFF = Format{Name: "tiff", Extension: "tiff", ContentType: "image/tiff", encoding: imaging.TIFF}
+	BMP  = Format{Name: "bmp", Extension: "bmp", ContentType: "image/bmp", encoding: imaging.BMP}
+)
+
+var formats = []Format{JPEG, PNG, GIF, TIFF, BMP}
+
+// signatures are the leading bytes files of each format start with.
+var signatures = []struct {
+	prefix string
+	format Format
+}{
+	{"\xff\xd8\xff", JPEG},
+	{"\x89PNG\r\n\x1a\n", PNG},
+	{"GIF87a", GIF},
+	{"GIF89a", GIF},
+	{"II*\x00", TIFF},
+	{"MM\x00*", TIFF},
+	{"BM", BMP},
+}
+
+// Parse looks a format up by name. "jpg" and "tif" are accepted as aliases.
+func Parse(name string) (Format, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	switch name {
+	case "jpg":
+		name = JPEG.Name
+	case "tif":
+		name = TIFF.Name
+	}
+
+	for _, f := range formats {
+		if f.Name == name {
+			return f, nil
+		}
+	}
+
+	return Format{}, fmt.Errorf("unsupported image format %q", name)
+}
+
+// ParseList parses a list of format names, dropping duplicates while keeping
+// the order, which is also the server preference order.
+func ParseList(names []string) ([]Format, error) {
+	var list []Format
+	seen := make(map[string]bool)
+
+	for _, name := range names {
+		f, err := Parse(name)
+		if err != nil {
+			return nil, err
+		}
+		if seen[f.Name] {
+			continue
+		}
+		seen[f.Name] = true
+		list = append(list, f)
+	}
+
+	if len(list) == 0 {
+		return nil, fmt.Errorf("no image formats configured")
+	}
+
+	return list, nil
+}
+
+// SniffLen is how many leading bytes Sniff needs at most.
+const SniffLen = 8
+
+// Sniff tells the format of a file from its leading bytes, without trusting
+// its name or the content type it was sent with.
+func Sniff(header []byte) (Format, bool) {
+	for _, sig := range signatures {
+		if strings.HasPrefix(string(header), sig.prefix) {
+			return sig.format, true
+		}
+	}
+
+	return Format{}, false
+}
+
+func Encode(w io.Writer, img image.Image, f Format, opts ...imaging.EncodeOption) error {
+	if f.opaque {
+		img = flatten(img)
+	}
+
+	return imaging.Encode(w, img, f.encoding, opts...)
+}
+
+func flatten(img image.Image) image.Image {
+	if o, ok := img.(interface{ Opaque() bool }); ok && o.Opaque() {
+		return img
+	}
+
+	bg := imaging.New(img.Bounds().Dx(), img.Bounds().Dy(), color.White)
+
+	return imaging.Overlay(bg, img, image.Pt(0, 0), 1.0)
+}
@@ -0,0 +1,94 @@
+package imageformat
+
+import (
+	"strconv"
+	"strings"
+)
+
+type mediaRange struct {
+	typ     string
+	subtype string
+	q       float64
+}
+
+// Negotiate picks the content type from offered that best matches the Accept
+// header. Offers are listed in server preference order, which breaks ties
+// between equally acceptable types. An empty header accepts the first offer.
+// It returns false when none of the offers is acceptable.
+func Negotiate(accept string, offered []string) (string, bool) {
+	if len(offered) == 0 {
+		return "", false
+	}
+
+	if strings.TrimSpace(accept) == "" {
+		return offered[0], true
+	}
+
+	ranges := parseAccept(accept)
+
+	best, bestQ := "", 0.0
+	for _, offer := range offered {
+		if q := quality(ranges, offer); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+
+	return best, bestQ > 0
+}
+
+func parseAccept(accept string) []mediaRange {
+	var ranges []mediaRange
+
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+
+		typ, subtype, ok := strings.Cut(strings.ToLower(strings.TrimSpace(params[0])), "/")
+		if !ok {
+			continue
+		}
+
+		mr := mediaRange{typ: strings.TrimSpace(typ), subtype: strings.TrimSpace(subtype), q: 1}
+		for _, param := range params[1:] {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.ToLower(strings.TrimSpace(key)) != "q" {
+				continue
+			}
+			q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil || q < 0 || q > 1 {
+				q = 0
+			}
+			mr.q = q
+		}
+
+		ranges = append(ranges, mr)
+	}
+
+	return ranges
+}
+
+// quality returns the q-value of the most specific range matching the media
+// type, as required by RFC 9110: "image/png" beats "image/*" beats "*/*".
+func quality(ranges []mediaRange, mediaType string) float64 {
+	typ, subtype, _ := strings.Cut(strings.ToLower(mediaType), "/")
+
+	q, specificity := 0.0, -1
+	for _, mr := range ranges {
+		var s int
+		switch {
+		case mr.typ == typ && mr.subtype == subtype:
+			s = 2
+		case mr.typ == typ && mr.subtype == "*":
+			s = 1
+		case mr.typ == "*" && mr.subtype == "*":
+			s = 0
+		default:
+			continue
+		}
+
+		if s > specificity {
+			q, specificity = mr.q, s
+		}
+	}
+
+	return q
+}
@@ -0,0 +1,104 @@
+package imagezip
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"imageProcessor/internal/models"
+	"imageProcessor/internal/storage"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+const MetadataFile = "metadata.json"
+
+type BlobOpener interface {
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, *storage.BlobInfo, error)
+}
+
+// Metadata is the content of metadata.json.
+type Metadata struct {
+	Image    models.Image     `json:"image"`
+	Variants []models.Variant `json:"variants"`
+}
+
+// Start sends the headers of a ZIP download named filename and returns the
+// writer of its body. It lifts the server's write timeout for the response,
+// since an archive may take longer to send. The writer must be closed to
+// complete the archive.
+func Start(w http.ResponseWriter, filename string) *zip.Writer {
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w.WriteHeader(http.StatusOK)
+
+	return zip.NewWriter(w)
+}
+
+// Add writes the original of image, its variants and a metadata.json into
+// dir of the archive, streaming every file from blobs:
+//
+//	original.jpg
+//	variants/resize.jpg
+//	variants/resize.png
+//	metadata.json
+func Add(ctx context.Context, zw *zip.Writer, blobs BlobOpener, dir string, image *models.Image, variants []models.Variant) error {
+	const op = "lib.imagezip.Add"
+
+	original := path.Join(dir, "original"+strings.ToLower(path.Ext(image.OriginalPath)))
+	if err := addBlob(ctx, zw, blobs, original, image.OriginalPath, image.CreatedAt); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, variant := range variants {
+		name := path.Join(dir, "variants", variant.Name+path.Ext(variant.BlobKey))
+		if err := addBlob(ctx, zw, blobs, name, variant.BlobKey, variant.CreatedAt); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	f, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     path.Join(dir, MetadataFile),
+		Method:   zip.Deflate,
+		Modified: image.UpdatedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err = enc.Encode(Metadata{Image: *image, Variants: variants}); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// addBlob copies a blob into the archive. Images are compressed already, so
+// they are stored as they are.
+func addBlob(ctx context.Context, zw *zip.Writer, blobs BlobOpener, name, key string, modified time.Time) error {
+	blob, _, err := blobs.Open(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer blob.Close()
+
+	f, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Store,
+		Modified: modified,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(f, blob)
+
+	return err
+}
@@ -0,0 +1,102 @@
+package mediaurl
+
+import (
+	"fmt"
+	"imageProcessor/internal/lib/tenant"
+	"imageProcessor/internal/models"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type Signer interface {
+	Sign(path string, params url.Values) string
+	ExpiresAt() time.Time
+}
+
+// URLs are signed links to the image files. They stop working at ExpiresAt.
+type URLs struct {
+	Original string            `json:"original"`
+	Variants map[string]string `json:"variants,omitempty"`
+	// Srcset lists the width variants as the srcset attribute of an img
+	// element, and Sizes suggests its sizes attribute.
+	Srcset    string    `json:"srcset,omitempty"`
+	Sizes     string    `json:"sizes,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// For signs links to the original of image and to each variant processed so
+// far.
+func For(image *models.Image, signer Signer) URLs {
+	// Media links are fetched without an API key, so they carry the tenant.
+	params := url.Values{tenant.QueryParam: {image.TenantID}}
+
+	urls := URLs{
+		Original:  signer.Sign(fmt.Sprintf("/image/%s/original", image.ID), params),
+		ExpiresAt: signer.ExpiresAt(),
+	}
+
+	variants := map[string]*string{
+		"resize":    image.ProcessedPathResize,
+		"thumbnail": image.ProcessedPathThumbnail,
+		"watermark": image.ProcessedPathWatermark,
+	}
+	for name, processedPath := range variants {
+		if processedPath == nil {
+			continue
+		}
+		if urls.Variants == nil {
+			urls.Variants = make(map[string]string)
+		}
+		urls.Variants[name] = signer.Sign(fmt.Sprintf("/image/%s/variants/%s", image.ID, name), params)
+	}
+
+	return urls
+}
+
+// Variant signs a link to the variant name of image, served in format, or
+// in the one the browser accepts best if format is empty.
+func Variant(image *models.Image, name, format string, signer Signer) string {
+	params := url.Values{tenant.QueryParam: {image.TenantID}}
+	if format != "" {
+		params.Set("format", format)
+	}
+
+	return signer.Sign(fmt.Sprintf("/image/%s/variants/%s", image.ID, name), params)
+}
+
+// Srcset signs links to the variants of image at each of widths, served as
+// Variant serves them, as the value of a srcset attribute. Links are
+// prefixed with base.
+func Srcset(image *models.Image, widths []int, format string, signer Signer, base string) string {
+	candidates := make([]string, 0, len(widths))
+	for _, width := range widths {
+		link := Variant(image, models.WidthVariant(width), format, signer)
+		candidates = append(candidates, fmt.Sprintf("%s%s %dw", base, link, width))
+	}
+
+	return strings.Join(candidates, ", ")
+}
+
+// Absolute prefixes every link with base, the public address of the API.
+func (u URLs) Absolute(base string) URLs {
+	abs := URLs{Original: base + u.Original, Sizes: u.Sizes, ExpiresAt: u.ExpiresAt}
+
+	// Signed links escape their query, so candidates can't hold ", ".
+	if u.Srcset != "" {
+		candidates := strings.Split(u.Srcset, ", ")
+		for i, candidate := range candidates {
+			candidates[i] = base + candidate
+		}
+		abs.Srcset = strings.Join(candidates, ", ")
+	}
+
+	for name, link := range u.Variants {
+		if abs.Variants == nil {
+			abs.Variants = make(map[string]string, len(u.Variants))
+		}
+		abs.Variants[name] = base + link
+	}
+
+	return abs
+}
@@ -0,0 +1,88 @@
+package netguard
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+var ErrForbiddenAddress = errors.New("address not allowed")
+
+// internalNetworks are refused on top of what netip classifies as loopback,
+// private, link-local, multicast or unspecified.
+var internalNetworks = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("2002::/16"),
+}
+
+// ParseNetworks parses the CIDRs of networks that are let through even
+// though they are internal.
+func ParseNetworks(networks []string) ([]netip.Prefix, error) {
+	const op = "netguard.ParseNetworks"
+
+	allowed := make([]netip.Prefix, 0, len(networks))
+	for _, network := range networks {
+		prefix, err := netip.ParsePrefix(network)
+		if err != nil {
+			return nil, fmt.Errorf("%s: allowed network %q: %w", op, network, err)
+		}
+		allowed = append(allowed, prefix.Masked())
+	}
+
+	return allowed, nil
+}
+
+// Dialer connects only to permitted addresses. The check runs after the host
+// name has been resolved, on every connection, so that neither DNS nor a
+// redirect can point the service at the networks behind it. Clients using it
+// must not use a proxy, which would make the connections checked.
+func Dialer(timeout time.Duration, allowed []netip.Prefix) *net.Dialer {
+	return &net.Dialer{
+		Timeout: timeout,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return fmt.Errorf("%w: %s", ErrForbiddenAddress, address)
+			}
+			if !Permitted(addrPort.Addr(), allowed) {
+				return fmt.Errorf("%w: %s", ErrForbiddenAddress, addrPort.Addr())
+			}
+			return nil
+		},
+	}
+}
+
+// Permitted reports whether addr is a public address or one of the allowed
+// networks.
+func Permitted(addr netip.Addr, allowed []netip.Prefix) bool {
+	addr = addr.Unmap()
+
+	for _, prefix := range allowed {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return false
+	}
+
+	for _, prefix := range internalNetworks {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,61 @@
+package quota
+
+import (
+	"errors"
+	"github.com/go-chi/render"
+	"imageProcessor/internal/lib/api/response"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	HeaderBytesLimit       = "X-Quota-Bytes-Limit"
+	HeaderBytesRemaining   = "X-Quota-Bytes-Remaining"
+	HeaderImagesLimit      = "X-Quota-Images-Limit"
+	HeaderImagesRemaining  = "X-Quota-Images-Remaining"
+	HeaderUploadsLimit     = "X-Quota-Uploads-Limit"
+	HeaderUploadsRemaining = "X-Quota-Uploads-Remaining"
+	HeaderUploadsReset     = "X-Quota-Uploads-Reset"
+)
+
+// SetHeaders reports st to the client. Only limits that are set are sent;
+// the reset is given in seconds from now.
+func SetHeaders(h http.Header, st Status, now time.Time) {
+	if st.BytesLimit > 0 {
+		h.Set(HeaderBytesLimit, strconv.FormatInt(st.BytesLimit, 10))
+		h.Set(HeaderBytesRemaining, strconv.FormatInt(st.BytesRemaining, 10))
+	}
+	if st.ImagesLimit > 0 {
+		h.Set(HeaderImagesLimit, strconv.FormatInt(st.ImagesLimit, 10))
+		h.Set(HeaderImagesRemaining, strconv.FormatInt(st.ImagesRemaining, 10))
+	}
+	if st.UploadsLimit > 0 {
+		h.Set(HeaderUploadsLimit, strconv.Itoa(st.UploadsLimit))
+		h.Set(HeaderUploadsRemaining, strconv.Itoa(st.UploadsRemaining))
+		h.Set(HeaderUploadsReset, strconv.Itoa(RetryAfter(st, now)))
+	}
+}
+
+// RetryAfter is the number of whole seconds until the rate window resets.
+func RetryAfter(st Status, now time.Time) int {
+	return max(0, int(math.Ceil(st.Reset.Sub(now).Seconds())))
+}
+
+// WriteExceeded answers an upload the quotas reject with err: 429 when the
+// upload rate is used up and 507 when the stored bytes or images are.
+func WriteExceeded(w http.ResponseWriter, r *http.Request, err error, scopes []Scope, now time.Time) {
+	st := NewStatus(scopes, now)
+	SetHeaders(w.Header(), st, now)
+
+	if errors.Is(err, ErrRateExceeded) {
+		w.Header().Set("Retry-After", strconv.Itoa(RetryAfter(st, now)))
+		render.Status(r, http.StatusTooManyRequests)
+		render.JSON(w, r, response.Error("upload rate exceeded"))
+		return
+	}
+
+	render.Status(r, http.StatusInsufficientStorage)
+	render.JSON(w, r, response.Error("storage quota exceeded"))
+}
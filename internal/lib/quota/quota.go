@@ -0,0 +1,183 @@
+package quota
+
+import (
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"imageProcessor/internal/config"
+	"imageProcessor/internal/lib/tenant"
+	"imageProcessor/internal/models"
+	"time"
+)
+
+// Window is the period uploads are rate limited over.
+const Window = time.Hour
+
+var (
+	ErrStorageExceeded = errors.New("storage quota exceeded")
+	ErrRateExceeded    = errors.New("upload rate exceeded")
+)
+
+// Limits bound a single scope. Zero means unlimited.
+type Limits struct {
+	MaxBytes       int64
+	MaxImages      int64
+	UploadsPerHour int
+}
+
+// Set holds the limits that apply to an upload: those of the tenant and
+// those of the API key making it.
+type Set struct {
+	Tenant Limits
+	Key    Limits
+}
+
+// Scope is a set of limits together with the usage measured against them.
+type Scope struct {
+	Limits Limits
+	Usage  models.Usage
+}
+
+// Quotas resolves the limits configured for tenants and API keys.
+type Quotas struct {
+	defaults Limits
+	tenants  map[string]Limits
+	keys     map[uuid.UUID]Limits
+}
+
+func New(cfg *config.Quotas) (*Quotas, error) {
+	const op = "lib.quota.New"
+
+	q := &Quotas{
+		defaults: fromConfig(cfg.Default),
+		tenants:  make(map[string]Limits, len(cfg.Tenants)),
+		keys:     make(map[uuid.UUID]Limits, len(cfg.Keys)),
+	}
+
+	for id, l := range cfg.Tenants {
+		if err := tenant.Validate(id); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		q.tenants[id] = fromConfig(l)
+	}
+
+	for id, l := range cfg.Keys {
+		keyID, err := uuid.Parse(id)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid api key ID %q: %w", op, id, err)
+		}
+		q.keys[keyID] = fromConfig(l)
+	}
+
+	return q, nil
+}
+
+// For returns the limits of the tenant and of the key. A tenant without its
+// own entry gets the defaults; a key without one is only bound by its tenant.
+func (q *Quotas) For(tenantID string, keyID *uuid.UUID) Set {
+	set := Set{Tenant: q.defaults}
+
+	if l, ok := q.tenants[tenantID]; ok {
+		set.Tenant = l
+	}
+	if keyID != nil {
+		set.Key = q.keys[*keyID]
+	}
+
+	return set
+}
+
+// Check reports whether an upload of size bytes fits into every scope.
+func Check(scopes []Scope, size int64, now time.Time) error {
+	for _, s := range scopes {
+		l, u := s.Limits, s.Usage
+
+		if l.MaxBytes > 0 && u.Bytes+size > l.MaxBytes {
+			return fmt.Errorf("%w: %d of %d bytes used", ErrStorageExceeded, u.Bytes, l.MaxBytes)
+		}
+		if l.MaxImages > 0 && u.Images+1 > l.MaxImages {
+			return fmt.Errorf("%w: %d of %d images stored", ErrStorageExceeded, u.Images, l.MaxImages)
+		}
+		if l.UploadsPerHour > 0 && s.Uploads(now) >= l.UploadsPerHour {
+			return fmt.Errorf("%w: %d uploads per hour", ErrRateExceeded, l.UploadsPerHour)
+		}
+	}
+
+	return nil
+}
+
+// Status is the tightest of the limits across scopes and what is left of
+// them. Limits that are not set are reported as zero.
+type Status struct {
+	BytesLimit       int64
+	BytesRemaining   int64
+	ImagesLimit      int64
+	ImagesRemaining  int64
+	UploadsLimit     int
+	UploadsRemaining int
+	// Reset is when the current rate window ends.
+	Reset time.Time
+}
+
+func NewStatus(scopes []Scope, now time.Time) Status {
+	var st Status
+
+	for _, s := range scopes {
+		l, u := s.Limits, s.Usage
+
+		if l.MaxBytes > 0 && (st.BytesLimit == 0 || l.MaxBytes-u.Bytes < st.BytesRemaining) {
+			st.BytesLimit, st.BytesRemaining = l.MaxBytes, max(0, l.MaxBytes-u.Bytes)
+		}
+		if l.MaxImages > 0 && (st.ImagesLimit == 0 || l.MaxImages-u.Images < st.ImagesRemaining) {
+			st.ImagesLimit, st.ImagesRemaining = l.MaxImages, max(0, l.MaxImages-u.Images)
+		}
+		if l.UploadsPerHour > 0 {
+			remaining := max(0, l.UploadsPerHour-s.Uploads(now))
+			if st.UploadsLimit == 0 || remaining < st.UploadsRemaining {
+				st.UploadsLimit, st.UploadsRemaining, st.Reset = l.UploadsPerHour, remaining, s.reset(now)
+			}
+		}
+	}
+
+	return st
+}
+
+// Uploads counts the uploads of the current window; an expired window
+// counts as empty.
+func (s Scope) Uploads(now time.Time) int {
+	if now.Sub(s.Usage.WindowStart) >= Window {
+		return 0
+	}
+
+	return s.Usage.WindowUploads
+}
+
+// Add returns the scope as it is after an upload of size bytes, the same way
+// the storage counts it.
+func (s Scope) Add(size int64, now time.Time) Scope {
+	if now.Sub(s.Usage.WindowStart) >= Window {
+		s.Usage.WindowStart, s.Usage.WindowUploads = now, 0
+	}
+
+	s.Usage.Bytes += size
+	s.Usage.Images++
+	s.Usage.WindowUploads++
+
+	return s
+}
+
+func (s Scope) reset(now time.Time) time.Time {
+	if now.Sub(s.Usage.WindowStart) >= Window {
+		return now.Add(Window)
+	}
+
+	return s.Usage.WindowStart.Add(Window)
+}
+
+func fromConfig(l config.QuotaLimits) Limits {
+	return Limits{
+		MaxBytes:       l.MaxBytes,
+		MaxImages:      l.MaxImages,
+		UploadsPerHour: l.UploadsPerHour,
+	}
+}
@@ -0,0 +1,30 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	HeaderLimit     = "RateLimit-Limit"
+	HeaderRemaining = "RateLimit-Remaining"
+	HeaderReset     = "RateLimit-Reset"
+	HeaderPolicy    = "RateLimit-Policy"
+)
+
+// SetHeaders reports res to the client. Durations are given in whole
+// seconds, rounded up.
+func SetHeaders(h http.Header, res Result) {
+	h.Set(HeaderLimit, strconv.Itoa(res.Limit))
+	h.Set(HeaderRemaining, strconv.Itoa(res.Remaining))
+	h.Set(HeaderReset, strconv.Itoa(Seconds(res.Reset)))
+	h.Set(HeaderPolicy, fmt.Sprintf("%d;w=%d", res.Limit, Seconds(res.Window)))
+}
+
+// Seconds rounds d up to whole seconds.
+func Seconds(d time.Duration) int {
+	return max(0, int(math.Ceil(d.Seconds())))
+}
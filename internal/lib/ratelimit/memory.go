@@ -0,0 +1,59 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often Memory forgets buckets that have refilled.
+const sweepInterval = time.Minute
+
+type entry struct {
+	bucket Bucket
+	// full is when the bucket will have refilled, after which it is no
+	// different from a missing one.
+	full time.Time
+}
+
+// Memory keeps buckets in process memory. Each replica limits on its own.
+type Memory struct {
+	mu      sync.Mutex
+	buckets map[string]entry
+	swept   time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{buckets: make(map[string]entry)}
+}
+
+// Allow takes a token from the bucket stored under key.
+func (m *Memory) Allow(_ context.Context, key string, rule Rule) (Result, error) {
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if now.Sub(m.swept) >= sweepInterval {
+		m.sweep(now)
+	}
+
+	b := Full(rule, now)
+	if e, ok := m.buckets[key]; ok {
+		b = e.bucket
+	}
+
+	b, res := Take(b, rule, now)
+	m.buckets[key] = entry{bucket: b, full: now.Add(res.Reset)}
+
+	return res, nil
+}
+
+func (m *Memory) sweep(now time.Time) {
+	for key, e := range m.buckets {
+		if !now.Before(e.full) {
+			delete(m.buckets, key)
+		}
+	}
+	m.swept = now
+}
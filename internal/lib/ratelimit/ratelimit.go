@@ -0,0 +1,109 @@
+package ratelimit
+
+import (
+	"fmt"
+	"imageProcessor/internal/config"
+	"math"
+	"time"
+)
+
+const (
+	BackendMemory   = "memory"
+	BackendPostgres = "postgres"
+)
+
+// Rule allows bursts of Limit requests, refilled evenly over Window. The
+// zero Rule does not limit anything.
+type Rule struct {
+	Limit  int
+	Window time.Duration
+}
+
+// Bucket is the state of a token bucket.
+type Bucket struct {
+	Tokens    float64
+	UpdatedAt time.Time
+}
+
+// Result is the outcome of taking a token.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	Window    time.Duration
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is the time until the next token, set if not Allowed.
+	RetryAfter time.Duration
+}
+
+// Rules holds the rules configured for each route group.
+type Rules struct {
+	routes map[string]Rule
+}
+
+func New(cfg *config.RateLimit) (*Rules, error) {
+	const op = "lib.ratelimit.New"
+
+	if cfg.Backend != BackendMemory && cfg.Backend != BackendPostgres {
+		return nil, fmt.Errorf("%s: unknown backend %q", op, cfg.Backend)
+	}
+
+	rules := &Rules{routes: make(map[string]Rule, len(cfg.Routes))}
+
+	for route, r := range cfg.Routes {
+		if r.Limit <= 0 || r.Window <= 0 {
+			return nil, fmt.Errorf("%s: route %q needs a positive limit and window", op, route)
+		}
+		rules.routes[route] = Rule{Limit: r.Limit, Window: r.Window}
+	}
+
+	return rules, nil
+}
+
+// For returns the rule of a route group, the zero Rule if it has none.
+func (r *Rules) For(route string) Rule {
+	return r.routes[route]
+}
+
+// MaxWindow is the longest window of any rule. A bucket left alone for that
+// long is full, so it can be forgotten.
+func (r *Rules) MaxWindow() time.Duration {
+	var w time.Duration
+	for _, rule := range r.routes {
+		w = max(w, rule.Window)
+	}
+
+	return w
+}
+
+// Full returns a bucket holding every token of rule.
+func Full(rule Rule, now time.Time) Bucket {
+	return Bucket{Tokens: float64(rule.Limit), UpdatedAt: now}
+}
+
+// Take refills b for the time passed since it was last updated and takes a
+// token from it if there is one.
+func Take(b Bucket, rule Rule, now time.Time) (Bucket, Result) {
+	rate := float64(rule.Limit) / rule.Window.Seconds()
+
+	elapsed := max(0, now.Sub(b.UpdatedAt).Seconds())
+	tokens := math.Min(float64(rule.Limit), b.Tokens+elapsed*rate)
+
+	res := Result{Limit: rule.Limit, Window: rule.Window}
+	if tokens >= 1 {
+		tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - tokens) / rate)
+	}
+
+	res.Remaining = int(tokens)
+	res.Reset = seconds((float64(rule.Limit) - tokens) / rate)
+
+	return Bucket{Tokens: tokens, UpdatedAt: now}, res
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
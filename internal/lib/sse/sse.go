@@ -0,0 +1,58 @@
+package sse
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Stream writes Server-Sent Events to a response.
+type Stream struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+// Start sends the headers of an event stream. It lifts the server's write
+// timeout for the response, which would otherwise cut the stream off.
+func Start(w http.ResponseWriter) *Stream {
+	rc := http.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	// Keep reverse proxies such as nginx from buffering the stream.
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	s := &Stream{w: w, rc: rc}
+	_ = s.rc.Flush()
+
+	return s
+}
+
+// Send writes an event named event carrying data encoded as JSON.
+func (s *Stream) Send(event string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	if _, err = fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+		return err
+	}
+
+	return s.rc.Flush()
+}
+
+// Ping writes a comment, which clients ignore, to keep idle connections
+// from being closed by proxies and to notice clients that went away.
+func (s *Stream) Ping() error {
+	if _, err := fmt.Fprint(s.w, ": ping\n\n"); err != nil {
+		return err
+	}
+
+	return s.rc.Flush()
+}
@@ -0,0 +1,56 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Default is the tenant of everything created before tenants existed.
+const Default = "default"
+
+var ErrNoTenant = errors.New("no tenant in context")
+
+var idPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+type ctxKey struct{}
+
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext returns the tenant the current request or job acts for.
+// Storage refuses to run tenant-scoped queries without one.
+func FromContext(ctx context.Context) (string, error) {
+	id, ok := ctx.Value(ctxKey{}).(string)
+	if !ok || id == "" {
+		return "", ErrNoTenant
+	}
+
+	return id, nil
+}
+
+func Validate(id string) error {
+	if !idPattern.MatchString(id) {
+		return fmt.Errorf("invalid tenant ID %q", id)
+	}
+
+	return nil
+}
+
+// QueryParam carries the tenant in signed media URLs, which are fetched
+// without an API key. The signature keeps it from being tampered with.
+const QueryParam = "tenant"
+
+// BlobKey builds a blob key inside the tenant's namespace.
+func BlobKey(id string, elem ...string) string {
+	return path.Join(append([]string{id}, elem...)...)
+}
+
+// OwnsBlobKey reports whether key lies inside the tenant's namespace.
+func OwnsBlobKey(id, key string) bool {
+	return strings.HasPrefix(path.Clean(key), id+"/")
+}
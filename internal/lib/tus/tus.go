@@ -0,0 +1,79 @@
+package tus
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Version is the only version of the tus protocol spoken.
+const Version = "1.0.0"
+
+// Extensions lists the protocol extensions supported.
+const Extensions = "creation,termination,expiration"
+
+const (
+	HeaderResumable         = "Tus-Resumable"
+	HeaderVersion           = "Tus-Version"
+	HeaderExtension         = "Tus-Extension"
+	HeaderMaxSize           = "Tus-Max-Size"
+	HeaderUploadLength      = "Upload-Length"
+	HeaderUploadDeferLength = "Upload-Defer-Length"
+	HeaderUploadOffset      = "Upload-Offset"
+	HeaderUploadMetadata    = "Upload-Metadata"
+	HeaderUploadExpires     = "Upload-Expires"
+	// HeaderImageID is not part of the protocol. It carries the ID of the
+	// image a completed upload turned into.
+	HeaderImageID = "X-Image-ID"
+)
+
+// ContentTypeChunk is the content type of PATCH requests.
+const ContentTypeChunk = "application/offset+octet-stream"
+
+var ErrInvalidMetadata = errors.New("invalid upload metadata")
+
+// ParseMetadata decodes an Upload-Metadata header: comma-separated pairs of
+// a key and a base64 encoded value, which may be left out.
+func ParseMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" || strings.ContainsAny(key, " \t") {
+			return nil, ErrInvalidMetadata
+		}
+		if _, ok := metadata[key]; ok {
+			return nil, ErrInvalidMetadata
+		}
+
+		value, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, ErrInvalidMetadata
+		}
+		metadata[key] = string(value)
+	}
+
+	return metadata, nil
+}
+
+// ParseSize reads a non-negative Upload-Length or Upload-Offset.
+func ParseSize(header string) (int64, bool) {
+	size, err := strconv.ParseInt(header, 10, 64)
+	if err != nil || size < 0 {
+		return 0, false
+	}
+
+	return size, true
+}
+
+// SetProgress sets the headers describing how far an upload has got.
+func SetProgress(h http.Header, offset int64, expiresAt time.Time) {
+	h.Set(HeaderUploadOffset, strconv.FormatInt(offset, 10))
+	h.Set(HeaderUploadExpires, expiresAt.UTC().Format(http.TimeFormat))
+}
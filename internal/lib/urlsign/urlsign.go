@@ -0,0 +1,131 @@
+package urlsign
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"imageProcessor/internal/config"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	paramExpires   = "exp"
+	paramKeyID     = "kid"
+	paramSignature = "sig"
+)
+
+var (
+	ErrMissingSignature = errors.New("missing signature")
+	ErrUnknownKey       = errors.New("unknown signing key")
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrExpired          = errors.New("signature expired")
+)
+
+// Signer signs URLs with HMAC-SHA256 over the path, the query parameters and
+// the expiry time.
+type Signer struct {
+	keys      map[string][]byte
+	activeKey string
+	ttl       time.Duration
+	now       func() time.Time
+}
+
+func New(cfg *config.URLSigning) (*Signer, error) {
+	const op = "lib.urlsign.New"
+
+	if _, ok := cfg.Keys[cfg.ActiveKey]; !ok {
+		return nil, fmt.Errorf("%s: active key %q is not configured", op, cfg.ActiveKey)
+	}
+
+	keys := make(map[string][]byte, len(cfg.Keys))
+	for id, secret := range cfg.Keys {
+		if secret == "" {
+			return nil, fmt.Errorf("%s: key %q has an empty secret", op, id)
+		}
+		keys[id] = []byte(secret)
+	}
+
+	return &Signer{
+		keys:      keys,
+		activeKey: cfg.ActiveKey,
+		ttl:       cfg.TTL,
+		now:       time.Now,
+	}, nil
+}
+
+// Sign returns path with params plus the expiry, key ID and signature
+// appended, ready to be handed out to a client.
+func (s *Signer) Sign(path string, params url.Values) string {
+	query := url.Values{}
+	for k, v := range params {
+		query[k] = v
+	}
+
+	query.Set(paramExpires, strconv.FormatInt(s.ExpiresAt().Unix(), 10))
+	query.Set(paramKeyID, s.activeKey)
+	query.Set(paramSignature, s.signature(s.keys[s.activeKey], path, query))
+
+	return (&url.URL{Path: path, RawQuery: query.Encode()}).String()
+}
+
+// WithTTL returns a signer with the same keys whose URLs are valid for ttl.
+func (s *Signer) WithTTL(ttl time.Duration) *Signer {
+	signer := *s
+	signer.ttl = ttl
+
+	return &signer
+}
+
+// ExpiresAt reports when URLs signed now stop being valid.
+func (s *Signer) ExpiresAt() time.Time {
+	return s.now().Add(s.ttl).Truncate(time.Second)
+}
+
+// Verify checks that query carries a valid, unexpired signature for path.
+func (s *Signer) Verify(path string, query url.Values) error {
+	sig := query.Get(paramSignature)
+	if sig == "" {
+		return ErrMissingSignature
+	}
+
+	key, ok := s.keys[query.Get(paramKeyID)]
+	if !ok {
+		return ErrUnknownKey
+	}
+
+	if !hmac.Equal([]byte(sig), []byte(s.signature(key, path, query))) {
+		return ErrInvalidSignature
+	}
+
+	expires, err := strconv.ParseInt(query.Get(paramExpires), 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if s.now().After(time.Unix(expires, 0)) {
+		return ErrExpired
+	}
+
+	return nil
+}
+
+// signature covers every query parameter except the signature itself.
+// url.Values.Encode sorts by key, which makes the message canonical.
+func (s *Signer) signature(key []byte, path string, query url.Values) string {
+	signed := url.Values{}
+	for k, v := range query {
+		if k != paramSignature {
+			signed[k] = v
+		}
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(path))
+	mac.Write([]byte{'?'})
+	mac.Write([]byte(signed.Encode()))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
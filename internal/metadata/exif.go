@@ -0,0 +1,225 @@
+package metadata
+
+import (
+	"fmt"
+	"imageProcessor/internal/models"
+	"math"
+	"strconv"
+	"time"
+)
+
+// The tags listed among the EXIF properties. Others, mostly vendor and
+// layout tags, are left out.
+var (
+	ifd0Tags = map[uint16]string{
+		0x010e: "ImageDescription",
+		0x010f: "Make",
+		0x0110: "Model",
+		0x0112: "Orientation",
+		0x011a: "XResolution",
+		0x011b: "YResolution",
+		0x0128: "ResolutionUnit",
+		0x0131: "Software",
+		0x0132: "DateTime",
+		0x013b: "Artist",
+		0x8298: "Copyright",
+	}
+	exifTags = map[uint16]string{
+		0x829a: "ExposureTime",
+		0x829d: "FNumber",
+		0x8822: "ExposureProgram",
+		0x8827: "ISOSpeedRatings",
+		0x9000: "ExifVersion",
+		0x9003: "DateTimeOriginal",
+		0x9004: "DateTimeDigitized",
+		0x9010: "OffsetTime",
+		0x9011: "OffsetTimeOriginal",
+		0x9012: "OffsetTimeDigitized",
+		0x9201: "ShutterSpeedValue",
+		0x9202: "ApertureValue",
+		0x9204: "ExposureBiasValue",
+		0x9207: "MeteringMode",
+		0x9209: "Flash",
+		0x920a: "FocalLength",
+		0x9291: "SubSecTimeOriginal",
+		0xa001: "ColorSpace",
+		0xa002: "PixelXDimension",
+		0xa003: "PixelYDimension",
+		0xa402: "ExposureMode",
+		0xa403: "WhiteBalance",
+		0xa405: "FocalLengthIn35mmFilm",
+		0xa406: "SceneCaptureType",
+		0xa430: "CameraOwnerName",
+		0xa431: "BodySerialNumber",
+		0xa432: "LensSpecification",
+		0xa433: "LensMake",
+		0xa434: "LensModel",
+		0xa435: "LensSerialNumber",
+	}
+	gpsTags = map[uint16]string{
+		0x0000: "GPSVersionID",
+		0x0001: "GPSLatitudeRef",
+		0x0002: "GPSLatitude",
+		0x0003: "GPSLongitudeRef",
+		0x0004: "GPSLongitude",
+		0x0005: "GPSAltitudeRef",
+		0x0006: "GPSAltitude",
+		0x0007: "GPSTimeStamp",
+		0x000c: "GPSSpeedRef",
+		0x000d: "GPSSpeed",
+		0x0010: "GPSImgDirectionRef",
+		0x0011: "GPSImgDirection",
+		0x0012: "GPSMapDatum",
+		0x001d: "GPSDateStamp",
+	}
+)
+
+const exifTimeLayout = "2006:01:02 15:04:05"
+
+// properties lists the named fields of t.
+func (t *tiff) properties() map[string]any {
+	properties := make(map[string]any)
+
+	for _, ifd := range []struct {
+		fields map[uint16]field
+		names  map[uint16]string
+	}{{t.ifd0, ifd0Tags}, {t.exif, exifTags}, {t.gps, gpsTags}} {
+		for tag, f := range ifd.fields {
+			name, ok := ifd.names[tag]
+			if !ok {
+				continue
+			}
+			if v, ok := f.value(); ok {
+				properties[name] = v
+			}
+		}
+	}
+
+	return properties
+}
+
+// apply fills the fields of m that t has.
+func (t *tiff) apply(m *models.ImageMetadata) {
+	str := func(fields map[uint16]field, tag uint16) string {
+		s, _ := fields[tag].string()
+		return s
+	}
+
+	if camera := (models.Camera{Make: str(t.ifd0, 0x010f), Model: str(t.ifd0, 0x0110)}); camera != (models.Camera{}) {
+		m.Camera = &camera
+	}
+	if lens := (models.Lens{Make: str(t.exif, 0xa433), Model: str(t.exif, 0xa434)}); lens != (models.Lens{}) {
+		m.Lens = &lens
+	}
+
+	var exposure models.Exposure
+	if num, den, ok := t.exif[0x829a].rational(0); ok && num > 0 && den > 0 {
+		exposure.Time = exposureTime(num, den)
+	}
+	exposure.FNumber, _ = t.exif[0x829d].float(0)
+	if iso, ok := t.exif[0x8827].uint(0); ok {
+		exposure.ISO = int(iso)
+	}
+	exposure.FocalLength, _ = t.exif[0x920a].float(0)
+	if focal, ok := t.exif[0xa405].uint(0); ok {
+		exposure.FocalLength35mm = int(focal)
+	}
+	if exposure != (models.Exposure{}) {
+		m.Exposure = &exposure
+	}
+
+	// The original date is when the photo was taken; the others are
+	// fallbacks for files that only have those.
+	for _, date := range []struct {
+		fields      map[uint16]field
+		tag, offset uint16
+	}{{t.exif, 0x9003, 0x9011}, {t.exif, 0x9004, 0x9012}, {t.ifd0, 0x0132, 0x9010}} {
+		if takenAt, ok := parseExifTime(str(date.fields, date.tag), str(t.exif, date.offset)); ok {
+			m.TakenAt = &takenAt
+			break
+		}
+	}
+
+	if orientation, ok := t.ifd0[0x0112].uint(0); ok && orientation >= 1 && orientation <= 8 {
+		m.Orientation = int(orientation)
+	}
+
+	if gps, ok := t.gpsPosition(); ok {
+		m.GPS = gps
+	}
+}
+
+func (t *tiff) gpsPosition() (*models.GPS, bool) {
+	coordinate := func(tag, refTag uint16, negative string) (float64, bool) {
+		f := t.gps[tag]
+		if f.count != 3 {
+			return 0, false
+		}
+
+		var dms [3]float64
+		for i := range dms {
+			v, ok := f.float(int64(i))
+			if !ok {
+				return 0, false
+			}
+			dms[i] = v
+		}
+
+		value := dms[0] + dms[1]/60 + dms[2]/3600
+		if ref, _ := t.gps[refTag].string(); ref == negative {
+			value = -value
+		}
+
+		return value, true
+	}
+
+	latitude, ok := coordinate(0x0002, 0x0001, "S")
+	if !ok || math.Abs(latitude) > 90 {
+		return nil, false
+	}
+	longitude, ok := coordinate(0x0004, 0x0003, "W")
+	if !ok || math.Abs(longitude) > 180 {
+		return nil, false
+	}
+
+	gps := &models.GPS{Latitude: latitude, Longitude: longitude}
+	if altitude, ok := t.gps[0x0006].float(0); ok {
+		// Reference 1 is below sea level.
+		if ref, _ := t.gps[0x0005].uint(0); ref == 1 {
+			altitude = -altitude
+		}
+		gps.Altitude = &altitude
+	}
+
+	return gps, true
+}
+
+// exposureTime writes short exposures as the fractions cameras show.
+func exposureTime(num, den uint32) string {
+	if num < den {
+		return fmt.Sprintf("1/%d", int(math.Round(float64(den)/float64(num))))
+	}
+
+	return strconv.FormatFloat(float64(num)/float64(den), 'f', -1, 64)
+}
+
+// parseExifTime reads an EXIF date, which has no zone unless the offset
+// tag gives it; dates without one are taken as UTC.
+func parseExifTime(value, offset string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+
+	if offset != "" {
+		if t, err := time.Parse(exifTimeLayout+"-07:00", value+offset); err == nil {
+			return t, true
+		}
+	}
+
+	t, err := time.Parse(exifTimeLayout, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return t, true
+}
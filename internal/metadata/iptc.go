@@ -0,0 +1,127 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"imageProcessor/internal/models"
+	"strings"
+	"time"
+)
+
+// IPTC IIM is a list of datasets, each a 0x1c marker, a record and dataset
+// number, a 16-bit length and the data. The descriptive datasets are in
+// record 2.
+var iptcDatasets = map[byte]string{
+	5:   "ObjectName",
+	15:  "Category",
+	25:  "Keywords",
+	40:  "SpecialInstructions",
+	55:  "DateCreated",
+	60:  "TimeCreated",
+	80:  "By-line",
+	85:  "By-lineTitle",
+	90:  "City",
+	92:  "Sub-location",
+	95:  "Province-State",
+	100: "Country-PrimaryLocationCode",
+	101: "Country-PrimaryLocationName",
+	103: "OriginalTransmissionReference",
+	105: "Headline",
+	110: "Credit",
+	115: "Source",
+	116: "CopyrightNotice",
+	120: "Caption-Abstract",
+	122: "Writer-Editor",
+}
+
+// maxIPTCValues bounds the values a repeatable dataset such as Keywords
+// may collect.
+const maxIPTCValues = 256
+
+// readIPTC lists the named datasets of record 2 in data.
+func readIPTC(data []byte) map[string][]string {
+	properties := make(map[string][]string)
+
+	for len(data) >= 5 && data[0] == 0x1c {
+		record, dataset := data[1], data[2]
+		length := int(binary.BigEndian.Uint16(data[3:]))
+		// The extended form, for datasets over 32767 bytes, isn't used by
+		// the descriptive datasets.
+		if length&0x8000 != 0 || 5+length > len(data) {
+			break
+		}
+
+		value := data[5 : 5+length]
+		data = data[5+length:]
+
+		name, ok := iptcDatasets[dataset]
+		if record != 2 || !ok || len(properties[name]) >= maxIPTCValues {
+			continue
+		}
+
+		s := strings.TrimSpace(strings.ToValidUTF8(string(value), ""))
+		if s != "" && printable(s) {
+			properties[name] = append(properties[name], s)
+		}
+	}
+
+	return properties
+}
+
+// readPhotoshop returns the IPTC data among the image resources Photoshop
+// stores in the APP13 segment of a JPEG file.
+func readPhotoshop(data []byte) []byte {
+	data, ok := bytes.CutPrefix(data, []byte("Photoshop 3.0\x00"))
+	if !ok {
+		return nil
+	}
+
+	// Each resource is "8BIM", an ID, a padded Pascal name, a length and
+	// the data, padded to an even length.
+	for len(data) >= 12 && string(data[:4]) == "8BIM" {
+		id := binary.BigEndian.Uint16(data[4:])
+
+		nameLength := int(data[6]) + 1
+		nameLength += nameLength % 2
+		if 6+nameLength+4 > len(data) {
+			return nil
+		}
+
+		length := int(binary.BigEndian.Uint32(data[6+nameLength:]))
+		start := 6 + nameLength + 4
+		if length < 0 || start+length > len(data) {
+			return nil
+		}
+
+		if id == 0x0404 {
+			return data[start : start+length]
+		}
+
+		next := start + length + length%2
+		if next > len(data) {
+			return nil
+		}
+		data = data[next:]
+	}
+
+	return nil
+}
+
+// applyIPTC fills the fields of m that IPTC has and m lacks.
+func applyIPTC(properties map[string][]string, m *models.ImageMetadata) {
+	if m.TakenAt != nil || len(properties["DateCreated"]) == 0 {
+		return
+	}
+
+	date := properties["DateCreated"][0]
+	if times := properties["TimeCreated"]; len(times) > 0 {
+		if t, err := time.Parse("20060102150405-0700", date+times[0]); err == nil {
+			m.TakenAt = &t
+			return
+		}
+	}
+
+	if t, err := time.Parse("20060102", date); err == nil {
+		m.TakenAt = &t
+	}
+}
@@ -0,0 +1,252 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"imageProcessor/internal/models"
+	"io"
+)
+
+// packets are the metadata an image file holds, as stored.
+type packets struct {
+	tiff *tiff
+	iptc []byte
+	xmp  []byte
+}
+
+// File is the metadata of an image file as it was stored.
+type File struct {
+	p packets
+}
+
+// Read reads the EXIF, IPTC and XMP metadata of a JPEG, PNG or TIFF file.
+// Other formats, and files without metadata, give empty metadata. Metadata
+// that is partly broken gives what could be read of it; an error is only
+// returned if none could.
+func Read(r io.ReadSeeker) (*File, error) {
+	const op = "metadata.Read"
+
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if _, err = r.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	magic := make([]byte, 8)
+	n, err := io.ReadFull(r, magic)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	magic = magic[:n]
+
+	f := &File{}
+	switch {
+	case bytes.HasPrefix(magic, []byte{0xff, 0xd8}):
+		if _, err = r.Seek(2, io.SeekStart); err == nil {
+			err = f.p.readJPEG(r)
+		}
+	case bytes.HasPrefix(magic, []byte("\x89PNG\r\n\x1a\n")):
+		err = f.p.readPNG(r)
+	case bytes.HasPrefix(magic, []byte("II*\x00")), bytes.HasPrefix(magic, []byte("MM\x00*")):
+		err = f.p.readTIFF(&readerAt{r: r}, size)
+	}
+
+	if err != nil && f.p.tiff == nil && f.p.iptc == nil && f.p.xmp == nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return f, nil
+}
+
+// Extract reads the metadata of a file, as Read does.
+func Extract(r io.ReadSeeker) (*models.ImageMetadata, error) {
+	const op = "metadata.Extract"
+
+	f, err := Read(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return f.Metadata(), nil
+}
+
+// Metadata is what the file tells about itself.
+func (f *File) Metadata() *models.ImageMetadata {
+	p := &f.p
+	m := &models.ImageMetadata{}
+
+	if p.tiff != nil {
+		if properties := p.tiff.properties(); len(properties) > 0 {
+			m.EXIF = properties
+		}
+		p.tiff.apply(m)
+	}
+
+	if p.xmp != nil {
+		if properties, err := readXMP(p.xmp); err == nil && len(properties) > 0 {
+			m.XMP = properties
+			applyXMP(properties, m)
+		}
+	}
+
+	if p.iptc != nil {
+		if properties := readIPTC(p.iptc); len(properties) > 0 {
+			m.IPTC = properties
+			applyIPTC(properties, m)
+		}
+	}
+
+	return m
+}
+
+// readJPEG reads the APP segments that precede the image data: EXIF and XMP
+// are in APP1, IPTC in APP13.
+func (p *packets) readJPEG(r io.Reader) error {
+	header := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return err
+		}
+		if header[0] != 0xff {
+			return errors.New("invalid JPEG marker")
+		}
+
+		marker := header[1]
+		// Markers may be padded with fill bytes.
+		if marker == 0xff {
+			header[0], header[1] = 0xff, header[2]
+			copy(header[2:], header[3:])
+			if _, err := io.ReadFull(r, header[3:]); err != nil {
+				return err
+			}
+			continue
+		}
+		// The image data follows the start of scan, and no metadata does.
+		if marker == 0xda || marker == 0xd9 {
+			return nil
+		}
+
+		length := int(binary.BigEndian.Uint16(header[2:])) - 2
+		if length < 0 {
+			return errors.New("invalid JPEG segment length")
+		}
+
+		if marker != 0xe1 && marker != 0xed {
+			if _, err := io.CopyN(io.Discard, r, int64(length)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		segment := make([]byte, length)
+		if _, err := io.ReadFull(r, segment); err != nil {
+			return err
+		}
+
+		switch {
+		case marker == 0xe1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")):
+			exif := segment[6:]
+			if t, err := readTIFF(bytes.NewReader(exif), int64(len(exif))); err == nil {
+				p.tiff = t
+			}
+		case marker == 0xe1 && bytes.HasPrefix(segment, []byte("http://ns.adobe.com/xap/1.0/\x00")):
+			p.xmp = segment[len("http://ns.adobe.com/xap/1.0/\x00"):]
+		case marker == 0xed:
+			if iptc := readPhotoshop(segment); iptc != nil {
+				p.iptc = iptc
+			}
+		}
+	}
+}
+
+// maxPNGChunk bounds the metadata chunks of a PNG file that are read.
+const maxPNGChunk = 1 << 20
+
+// readPNG reads the eXIf chunk and the iTXt chunk holding XMP. Other chunks
+// are skipped over.
+func (p *packets) readPNG(r io.ReadSeeker) error {
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		length := int64(binary.BigEndian.Uint32(header))
+		chunk := string(header[4:])
+
+		if chunk == "IEND" {
+			return nil
+		}
+		if (chunk != "eXIf" && chunk != "iTXt") || length > maxPNGChunk {
+			// The data is followed by a CRC.
+			if _, err := r.Seek(length+4, io.SeekCurrent); err != nil {
+				return err
+			}
+			continue
+		}
+
+		data := make([]byte, length+4)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return err
+		}
+		data = data[:length]
+
+		switch chunk {
+		case "eXIf":
+			if t, err := readTIFF(bytes.NewReader(data), length); err == nil {
+				p.tiff = t
+			}
+		case "iTXt":
+			// Keyword, compression flag and method, language tag and
+			// translated keyword precede the text. XMP is never
+			// compressed.
+			keyword, rest, ok := bytes.Cut(data, []byte{0})
+			if !ok || string(keyword) != "XML:com.adobe.xmp" || len(rest) < 2 || rest[0] != 0 {
+				continue
+			}
+			parts := bytes.SplitN(rest[2:], []byte{0}, 3)
+			if len(parts) == 3 {
+				p.xmp = parts[2]
+			}
+		}
+	}
+}
+
+// readTIFF reads a TIFF file, which keeps its EXIF in IFD 0 and XMP and
+// IPTC in tags of it.
+func (p *packets) readTIFF(r io.ReaderAt, size int64) error {
+	t, err := readTIFF(r, size)
+	if err != nil {
+		return err
+	}
+
+	p.tiff = t
+	if f, ok := t.ifd0[tagXMP]; ok {
+		p.xmp = f.data
+	}
+	if f, ok := t.ifd0[tagIPTC]; ok {
+		p.iptc = f.data
+	}
+
+	return nil
+}
+
+// readerAt reads at offsets of a file that can only seek.
+type readerAt struct {
+	r io.ReadSeeker
+}
+
+func (ra *readerAt) ReadAt(p []byte, off int64) (int, error) {
+	if _, err := ra.r.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	return io.ReadFull(ra.r, p)
+}
@@ -0,0 +1,402 @@
+package metadata_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"imageProcessor/internal/metadata"
+	"imageProcessor/internal/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// byteOrder writes both into and after the end of a buffer.
+type byteOrder interface {
+	binary.ByteOrder
+	binary.AppendByteOrder
+}
+
+type entry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	value func(order byteOrder) []byte
+}
+
+func ascii(tag uint16, s string) entry {
+	return entry{tag: tag, typ: 2, count: uint32(len(s) + 1), value: func(byteOrder) []byte {
+		return append([]byte(s), 0)
+	}}
+}
+
+func short(tag uint16, values ...uint16) entry {
+	return entry{tag: tag, typ: 3, count: uint32(len(values)), value: func(order byteOrder) []byte {
+		data := make([]byte, 2*len(values))
+		for i, v := range values {
+			order.PutUint16(data[i*2:], v)
+		}
+		return data
+	}}
+}
+
+func byteValue(tag uint16, v byte) entry {
+	return entry{tag: tag, typ: 1, count: 1, value: func(byteOrder) []byte { return []byte{v} }}
+}
+
+// rational takes numerator and denominator pairs.
+func rational(tag uint16, values ...uint32) entry {
+	return entry{tag: tag, typ: 5, count: uint32(len(values) / 2), value: func(order byteOrder) []byte {
+		data := make([]byte, 4*len(values))
+		for i, v := range values {
+			order.PutUint32(data[i*4:], v)
+		}
+		return data
+	}}
+}
+
+func long(tag uint16, v uint32) entry {
+	return entry{tag: tag, typ: 4, count: 1, value: func(order byteOrder) []byte {
+		return order.AppendUint32(nil, v)
+	}}
+}
+
+// buildTIFF lays out a TIFF structure with IFD 0 and, if given, EXIF and
+// GPS IFDs.
+func buildTIFF(order byteOrder, ifd0, exif, gps []entry) []byte {
+	buf := make([]byte, 8)
+	if order == binary.LittleEndian {
+		copy(buf, "II")
+	} else {
+		copy(buf, "MM")
+	}
+	order.PutUint16(buf[2:], 42)
+
+	writeIFD := func(entries []entry) uint32 {
+		offset := uint32(len(buf))
+		data := offset + 2 + uint32(len(entries))*12 + 4
+
+		ifd := order.AppendUint16(nil, uint16(len(entries)))
+		var values []byte
+		for _, e := range entries {
+			ifd = order.AppendUint16(ifd, e.tag)
+			ifd = order.AppendUint16(ifd, e.typ)
+			ifd = order.AppendUint32(ifd, e.count)
+
+			value := e.value(order)
+			if len(value) <= 4 {
+				ifd = append(ifd, append(value, make([]byte, 4-len(value))...)...)
+			} else {
+				ifd = order.AppendUint32(ifd, data+uint32(len(values)))
+				values = append(values, value...)
+			}
+		}
+		ifd = order.AppendUint32(ifd, 0)
+
+		buf = append(buf, ifd...)
+		buf = append(buf, values...)
+
+		return offset
+	}
+
+	if gps != nil {
+		ifd0 = append(ifd0, long(0x8825, writeIFD(gps)))
+	}
+	if exif != nil {
+		ifd0 = append(ifd0, long(0x8769, writeIFD(exif)))
+	}
+	offset := writeIFD(ifd0)
+	order.PutUint32(buf[4:], offset)
+
+	return buf
+}
+
+func segment(marker byte, data []byte) []byte {
+	s := []byte{0xff, marker, 0, 0}
+	binary.BigEndian.PutUint16(s[2:], uint16(len(data)+2))
+
+	return append(s, data...)
+}
+
+// withSegments puts segments right after the SOI marker of a JPEG file.
+func withSegments(t *testing.T, segments ...[]byte) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 8, 8)), nil))
+
+	file := buf.Bytes()[:2:2]
+	for _, s := range segments {
+		file = append(file, s...)
+	}
+
+	return append(file, buf.Bytes()[2:]...)
+}
+
+func chunk(typ string, data []byte) []byte {
+	c := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	c = append(c, typ...)
+	c = append(c, data...)
+
+	return binary.BigEndian.AppendUint32(c, crc32.ChecksumIEEE(c[4:]))
+}
+
+// withChunks puts chunks right after the IHDR chunk of a PNG file.
+func withChunks(t *testing.T, chunks ...[]byte) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, 8, 8))))
+
+	const ihdrEnd = 8 + 25
+	file := append([]byte(nil), buf.Bytes()[:ihdrEnd]...)
+	for _, c := range chunks {
+		file = append(file, c...)
+	}
+
+	return append(file, buf.Bytes()[ihdrEnd:]...)
+}
+
+func photoshop(iptc []byte) []byte {
+	data := []byte("Photoshop 3.0\x00")
+	data = append(data, "8BIM"...)
+	data = binary.BigEndian.AppendUint16(data, 0x0404)
+	data = append(data, 0, 0) // empty name, padded
+	data = binary.BigEndian.AppendUint32(data, uint32(len(iptc)))
+	data = append(data, iptc...)
+	if len(iptc)%2 == 1 {
+		data = append(data, 0)
+	}
+
+	return data
+}
+
+func dataset(number byte, value string) []byte {
+	d := []byte{0x1c, 2, number}
+	d = binary.BigEndian.AppendUint16(d, uint16(len(value)))
+
+	return append(d, value...)
+}
+
+const xmpPacket = `<?xpacket begin="" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about=""
+    xmlns:tiff="http://ns.adobe.com/tiff/1.0/"
+    xmlns:exif="http://ns.adobe.com/exif/1.0/"
+    xmlns:dc="http://purl.org/dc/elements/1.1/"
+    tiff:Make="NIKON CORPORATION"
+    tiff:Model="NIKON D850"
+    exif:DateTimeOriginal="2019-03-02T10:11:12+01:00"
+    exif:GPSLatitude="48,51.396N"
+    exif:GPSLongitude="2,17.4E">
+   <dc:subject>
+    <rdf:Bag>
+     <rdf:li>paris</rdf:li>
+     <rdf:li>tower</rdf:li>
+    </rdf:Bag>
+   </dc:subject>
+   <dc:creator><rdf:Seq><rdf:li>Jane Roe</rdf:li></rdf:Seq></dc:creator>
+  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>`
+
+func fullEXIF(order byteOrder) []byte {
+	return buildTIFF(order,
+		[]entry{
+			ascii(0x010f, "Canon"),
+			ascii(0x0110, "Canon EOS 5D Mark IV"),
+			short(0x0112, 6),
+			ascii(0x0132, "2021:06:16 09:00:00"),
+		},
+		[]entry{
+			rational(0x829a, 1, 250),
+			rational(0x829d, 28, 10),
+			short(0x8827, 400),
+			ascii(0x9003, "2021:06:15 14:30:00"),
+			ascii(0x9011, "+02:00"),
+			rational(0x920a, 50, 1),
+			short(0xa405, 50),
+			ascii(0xa434, "EF50mm f/1.8 STM"),
+		},
+		[]entry{
+			ascii(0x0001, "N"),
+			rational(0x0002, 55, 1, 45, 1, 216, 10),
+			ascii(0x0003, "W"),
+			rational(0x0004, 37, 1, 37, 1, 12, 10),
+			byteValue(0x0005, 0),
+			rational(0x0006, 150, 1),
+		},
+	)
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func TestExtract(t *testing.T) {
+	takenAt := time.Date(2021, 6, 15, 14, 30, 0, 0, time.FixedZone("", 2*60*60))
+	exifMetadata := func() *models.ImageMetadata {
+		return &models.ImageMetadata{
+			Camera:      &models.Camera{Make: "Canon", Model: "Canon EOS 5D Mark IV"},
+			Lens:        &models.Lens{Model: "EF50mm f/1.8 STM"},
+			Exposure:    &models.Exposure{Time: "1/250", FNumber: 2.8, ISO: 400, FocalLength: 50, FocalLength35mm: 50},
+			TakenAt:     &takenAt,
+			Orientation: 6,
+			GPS:         &models.GPS{Latitude: 55.756, Longitude: -37.617, Altitude: ptr(150.0)},
+		}
+	}
+
+	var gifFile bytes.Buffer
+	require.NoError(t, gif.Encode(&gifFile, image.NewGray(image.Rect(0, 0, 8, 8)), nil))
+
+	// IFD 0 is right after the header, and its EXIF IFD pointer points
+	// back at it.
+	loop := buildTIFF(binary.LittleEndian, []entry{ascii(0x010f, "Canon"), long(0x8769, 8)}, nil, nil)
+
+	tests := []struct {
+		name     string
+		file     []byte
+		expected *models.ImageMetadata
+		exif     map[string]any
+		iptc     map[string][]string
+		xmp      map[string][]string
+		err      bool
+	}{
+		{
+			name:     "JPEG EXIF",
+			file:     withSegments(t, segment(0xe0, []byte("JFIF\x00\x01\x01\x00\x00\x01\x00\x01\x00\x00")), segment(0xe1, append([]byte("Exif\x00\x00"), fullEXIF(binary.LittleEndian)...))),
+			expected: exifMetadata(),
+			exif: map[string]any{
+				"Make":               "Canon",
+				"Orientation":        float64(6),
+				"ExposureTime":       0.004,
+				"ISOSpeedRatings":    float64(400),
+				"OffsetTimeOriginal": "+02:00",
+				"GPSLatitude":        []float64{55, 45, 21.6},
+				"GPSLongitudeRef":    "W",
+			},
+		},
+		{
+			name:     "PNG EXIF Big Endian",
+			file:     withChunks(t, chunk("eXIf", fullEXIF(binary.BigEndian))),
+			expected: exifMetadata(),
+		},
+		{
+			name:     "TIFF",
+			file:     fullEXIF(binary.BigEndian),
+			expected: exifMetadata(),
+		},
+		{
+			name: "JPEG XMP",
+			file: withSegments(t, segment(0xe1, append([]byte("http://ns.adobe.com/xap/1.0/\x00"), xmpPacket...))),
+			expected: &models.ImageMetadata{
+				Camera:  &models.Camera{Make: "NIKON CORPORATION", Model: "NIKON D850"},
+				TakenAt: ptr(time.Date(2019, 3, 2, 10, 11, 12, 0, time.FixedZone("", 60*60))),
+				GPS:     &models.GPS{Latitude: 48.8566, Longitude: 2.29},
+			},
+			xmp: map[string][]string{
+				"tiff:Make":  {"NIKON CORPORATION"},
+				"dc:subject": {"paris", "tower"},
+				"dc:creator": {"Jane Roe"},
+			},
+		},
+		{
+			name: "PNG XMP",
+			file: withChunks(t, chunk("iTXt", append([]byte("XML:com.adobe.xmp\x00\x00\x00\x00\x00"), xmpPacket...))),
+			expected: &models.ImageMetadata{
+				Camera:  &models.Camera{Make: "NIKON CORPORATION", Model: "NIKON D850"},
+				TakenAt: ptr(time.Date(2019, 3, 2, 10, 11, 12, 0, time.FixedZone("", 60*60))),
+				GPS:     &models.GPS{Latitude: 48.8566, Longitude: 2.29},
+			},
+		},
+		{
+			name: "JPEG IPTC",
+			file: withSegments(t, segment(0xed, photoshop(bytes.Join([][]byte{
+				dataset(25, "cat"),
+				dataset(25, "sofa"),
+				dataset(55, "20200102"),
+				dataset(60, "030405+0300"),
+				dataset(80, "John Doe"),
+			}, nil)))),
+			expected: &models.ImageMetadata{
+				TakenAt: ptr(time.Date(2020, 1, 2, 3, 4, 5, 0, time.FixedZone("", 3*60*60))),
+			},
+			iptc: map[string][]string{
+				"Keywords":    {"cat", "sofa"},
+				"DateCreated": {"20200102"},
+				"By-line":     {"John Doe"},
+			},
+		},
+		{
+			name: "EXIF Comes First",
+			file: withSegments(t,
+				segment(0xe1, append([]byte("Exif\x00\x00"), fullEXIF(binary.LittleEndian)...)),
+				segment(0xe1, append([]byte("http://ns.adobe.com/xap/1.0/\x00"), xmpPacket...)),
+			),
+			expected: exifMetadata(),
+		},
+		{
+			name:     "No Metadata",
+			file:     withSegments(t),
+			expected: &models.ImageMetadata{},
+		},
+		{
+			name:     "GIF",
+			file:     gifFile.Bytes(),
+			expected: &models.ImageMetadata{},
+		},
+		{
+			name:     "IFD Loop",
+			file:     withSegments(t, segment(0xe1, append([]byte("Exif\x00\x00"), loop...))),
+			expected: &models.ImageMetadata{Camera: &models.Camera{Make: "Canon"}},
+		},
+		{
+			name: "Truncated",
+			file: withSegments(t, segment(0xe1, append([]byte("Exif\x00\x00"), fullEXIF(binary.LittleEndian)...)))[:40],
+			err:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := metadata.Extract(bytes.NewReader(tt.file))
+			if tt.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			require.Equal(t, tt.expected.Camera, m.Camera)
+			require.Equal(t, tt.expected.Lens, m.Lens)
+			require.Equal(t, tt.expected.Exposure, m.Exposure)
+			require.Equal(t, tt.expected.Orientation, m.Orientation)
+
+			if tt.expected.TakenAt == nil {
+				require.Nil(t, m.TakenAt)
+			} else {
+				require.NotNil(t, m.TakenAt)
+				require.True(t, tt.expected.TakenAt.Equal(*m.TakenAt), "taken at %s", m.TakenAt)
+			}
+
+			if tt.expected.GPS == nil {
+				require.Nil(t, m.GPS)
+			} else {
+				require.NotNil(t, m.GPS)
+				require.InDelta(t, tt.expected.GPS.Latitude, m.GPS.Latitude, 0.001)
+				require.InDelta(t, tt.expected.GPS.Longitude, m.GPS.Longitude, 0.001)
+				require.Equal(t, tt.expected.GPS.Altitude, m.GPS.Altitude)
+			}
+
+			for k, v := range tt.exif {
+				require.Equal(t, v, m.EXIF[k], k)
+			}
+			for k, v := range tt.iptc {
+				require.Equal(t, v, m.IPTC[k], k)
+			}
+			for k, v := range tt.xmp {
+				require.Equal(t, v, m.XMP[k], k)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package metadata
+
+import (
+	"github.com/disintegration/imaging"
+	"image"
+)
+
+// Orient turns img, decoded as stored, upright according to its EXIF
+// orientation. Orientation 1 and values outside 1-8 leave it as it is.
+func Orient(img image.Image, orientation int) image.Image {
+	// The values say where the stored rows and columns start: 2, 4, 5 and
+	// 7 are mirrored, 3, 6 and 8 only rotated.
+	switch orientation {
+	case 2:
+		return imaging.FlipH(img)
+	case 3:
+		return imaging.Rotate180(img)
+	case 4:
+		return imaging.FlipV(img)
+	case 5:
+		return imaging.Transpose(img)
+	case 6:
+		return imaging.Rotate270(img)
+	case 7:
+		return imaging.Transverse(img)
+	case 8:
+		return imaging.Rotate90(img)
+	}
+
+	return img
+}
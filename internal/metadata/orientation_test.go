@@ -0,0 +1,71 @@
+package metadata_test
+
+import (
+	"fmt"
+	"github.com/disintegration/imaging"
+	"github.com/stretchr/testify/require"
+	"image"
+	"image/color"
+	"imageProcessor/internal/metadata"
+	"io"
+	"os"
+	"testing"
+)
+
+// testdata/orientation/N.jpg holds upright.png as a camera stores it when
+// it writes orientation N: the pixels as the sensor read them, and the tag
+// telling how to turn them.
+func TestOrient(t *testing.T) {
+	golden, err := imaging.Open("testdata/orientation/upright.png")
+	require.NoError(t, err)
+
+	for orientation := 1; orientation <= 8; orientation++ {
+		t.Run(fmt.Sprintf("Orientation %d", orientation), func(t *testing.T) {
+			f, err := os.Open(fmt.Sprintf("testdata/orientation/%d.jpg", orientation))
+			require.NoError(t, err)
+			defer f.Close()
+
+			m, err := metadata.Extract(f)
+			require.NoError(t, err)
+			require.Equal(t, orientation, m.Orientation)
+
+			_, err = f.Seek(0, io.SeekStart)
+			require.NoError(t, err)
+			stored, err := imaging.Decode(f)
+			require.NoError(t, err)
+
+			requireSimilar(t, golden, metadata.Orient(stored, m.Orientation))
+		})
+	}
+}
+
+func TestOrientUnknown(t *testing.T) {
+	img := imaging.New(4, 2, image.Black)
+
+	for _, orientation := range []int{0, 1, 9, -1} {
+		require.Same(t, img, metadata.Orient(img, orientation))
+	}
+}
+
+// requireSimilar compares images pixel by pixel, leaving room for JPEG
+// compression.
+func requireSimilar(t *testing.T, expected, actual image.Image) {
+	t.Helper()
+
+	require.Equal(t, expected.Bounds().Size(), actual.Bounds().Size())
+
+	const tolerance = 24
+	near := func(a, b uint8) bool {
+		return int(a)-int(b) <= tolerance && int(b)-int(a) <= tolerance
+	}
+
+	for y := 0; y < expected.Bounds().Dy(); y++ {
+		for x := 0; x < expected.Bounds().Dx(); x++ {
+			e := color.NRGBAModel.Convert(expected.At(expected.Bounds().Min.X+x, expected.Bounds().Min.Y+y)).(color.NRGBA)
+			a := color.NRGBAModel.Convert(actual.At(actual.Bounds().Min.X+x, actual.Bounds().Min.Y+y)).(color.NRGBA)
+			if !near(e.R, a.R) || !near(e.G, a.G) || !near(e.B, a.B) {
+				require.Failf(t, "pixels differ", "at %d,%d: expected %v, got %v", x, y, e, a)
+			}
+		}
+	}
+}
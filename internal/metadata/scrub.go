@@ -0,0 +1,239 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"maps"
+	"regexp"
+	"slices"
+)
+
+// Policy is what metadata of the original the images published from it
+// carry.
+type Policy string
+
+const (
+	// PolicyStrip keeps no metadata.
+	PolicyStrip Policy = "strip"
+	// PolicyCopyright keeps the author and copyright notice only.
+	PolicyCopyright Policy = "copyright"
+	// PolicyKeep keeps the descriptive metadata, the GPS position
+	// included.
+	PolicyKeep Policy = "keep"
+)
+
+func ParsePolicy(s string) (Policy, error) {
+	switch p := Policy(s); p {
+	case PolicyStrip, PolicyCopyright, PolicyKeep:
+		return p, nil
+	}
+
+	return "", fmt.Errorf("unknown metadata policy %q", s)
+}
+
+// Packets are the metadata to write into a file, as stored in JPEG and PNG
+// files. Empty ones are left out.
+type Packets struct {
+	EXIF []byte
+	XMP  []byte
+	IPTC []byte
+}
+
+// Tags describing how the pixels of a TIFF file are laid out, or pointing
+// into it, which mean nothing once the image is encoded anew. Maker notes
+// are left out too: they are vendor data that often points into the file.
+var (
+	layoutTags = []uint16{
+		0x00fe, 0x00ff, 0x0100, 0x0101, 0x0102, 0x0103, 0x0106, 0x0107, 0x0108, 0x0109, 0x010a,
+		0x0111, 0x0115, 0x0116, 0x0117, 0x0118, 0x0119, 0x011c, 0x0122, 0x0123, 0x013d, 0x0140,
+		0x0142, 0x0143, 0x0144, 0x0145, 0x014a, 0x0152, 0x0153, 0x0201, 0x0202, 0x0211, 0x0212,
+		0x0213, 0x0214, tagXMP, tagIPTC, 0x8773,
+	}
+	exifLayoutTags = []uint16{0x927c, 0xa002, 0xa003, 0xa005}
+)
+
+var xmpOrientation = regexp.MustCompile(`(tiff:Orientation\s*=\s*["']|<tiff:Orientation>\s*)[0-9]`)
+
+// Packets returns the metadata of the file that policy lets through. If
+// upright is set, the pixels are written turned by the orientation, and the
+// orientation kept says so.
+func (f *File) Packets(policy Policy, upright bool) Packets {
+	switch policy {
+	case PolicyCopyright:
+		return f.copyright()
+	case PolicyKeep:
+		return f.keep(upright)
+	}
+
+	return Packets{}
+}
+
+func (f *File) keep(upright bool) Packets {
+	var packets Packets
+
+	if t := f.p.tiff; t != nil {
+		ifd0 := maps.Clone(t.ifd0)
+		maps.DeleteFunc(ifd0, func(tag uint16, _ field) bool { return slices.Contains(layoutTags, tag) })
+		exif := maps.Clone(t.exif)
+		maps.DeleteFunc(exif, func(tag uint16, _ field) bool { return slices.Contains(exifLayoutTags, tag) })
+
+		if _, ok := ifd0[0x0112]; ok && upright {
+			ifd0[0x0112] = field{typ: typeShort, count: 1, data: t.order.AppendUint16(nil, 1), order: t.order}
+		}
+
+		if len(ifd0) > 0 || len(exif) > 0 || len(t.gps) > 0 {
+			packets.EXIF = writeTIFF(t.order, ifd0, exif, t.gps)
+		}
+	}
+
+	if f.p.xmp != nil {
+		packets.XMP = f.p.xmp
+		if upright {
+			packets.XMP = xmpOrientation.ReplaceAll(f.p.xmp, []byte("${1}1"))
+		}
+	}
+
+	packets.IPTC = f.p.iptc
+
+	return packets
+}
+
+// copyright writes the author and copyright notice, from wherever the file
+// has them, as EXIF Artist and Copyright.
+func (f *File) copyright() Packets {
+	m := f.Metadata()
+
+	first := func(values ...string) string {
+		for _, v := range values {
+			if v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+	exifValue := func(name string) string {
+		s, _ := m.EXIF[name].(string)
+		return s
+	}
+	listValue := func(properties map[string][]string, name string) string {
+		if values := properties[name]; len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+
+	artist := first(exifValue("Artist"), listValue(m.XMP, "dc:creator"), listValue(m.IPTC, "By-line"))
+	copyright := first(exifValue("Copyright"), listValue(m.XMP, "dc:rights"), listValue(m.IPTC, "CopyrightNotice"))
+
+	ifd0 := make(map[uint16]field)
+	for tag, value := range map[uint16]string{0x013b: artist, 0x8298: copyright} {
+		if value != "" {
+			ifd0[tag] = field{typ: typeASCII, count: int64(len(value) + 1), data: append([]byte(value), 0), order: binary.BigEndian}
+		}
+	}
+	if len(ifd0) == 0 {
+		return Packets{}
+	}
+
+	return Packets{EXIF: writeTIFF(binary.BigEndian, ifd0, nil, nil)}
+}
+
+// maxSegment is the most data a JPEG segment holds.
+const maxSegment = 0xffff - 2
+
+// Embed writes packets into an encoded JPEG or PNG file. Other formats, and
+// packets a JPEG segment can't hold, are left out: the file is returned as
+// it is.
+func Embed(data []byte, packets Packets) []byte {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xff, 0xd8}):
+		return embedJPEG(data, packets)
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return embedPNG(data, packets)
+	}
+
+	return data
+}
+
+func embedJPEG(data []byte, packets Packets) []byte {
+	var segments []byte
+	add := func(marker byte, prefix string, payload []byte) {
+		if len(payload) == 0 || len(prefix)+len(payload) > maxSegment {
+			return
+		}
+		segments = append(segments, 0xff, marker)
+		segments = binary.BigEndian.AppendUint16(segments, uint16(len(prefix)+len(payload)+2))
+		segments = append(segments, prefix...)
+		segments = append(segments, payload...)
+	}
+
+	add(0xe1, "Exif\x00\x00", packets.EXIF)
+	add(0xe1, "http://ns.adobe.com/xap/1.0/\x00", packets.XMP)
+	if len(packets.IPTC) > 0 {
+		add(0xed, "", photoshopResource(packets.IPTC))
+	}
+	if segments == nil {
+		return data
+	}
+
+	// A JFIF header has to stay the first segment.
+	at := 2
+	if len(data) >= 6 && data[2] == 0xff && data[3] == 0xe0 {
+		at = 4 + int(binary.BigEndian.Uint16(data[4:]))
+		if at > len(data) {
+			return data
+		}
+	}
+
+	file := make([]byte, 0, len(data)+len(segments))
+	file = append(file, data[:at]...)
+	file = append(file, segments...)
+
+	return append(file, data[at:]...)
+}
+
+// photoshopResource wraps IPTC data the way Photoshop stores it in APP13.
+func photoshopResource(iptc []byte) []byte {
+	data := []byte("Photoshop 3.0\x008BIM\x04\x04\x00\x00")
+	data = binary.BigEndian.AppendUint32(data, uint32(len(iptc)))
+	data = append(data, iptc...)
+	if len(iptc)%2 == 1 {
+		data = append(data, 0)
+	}
+
+	return data
+}
+
+// embedPNG writes EXIF and XMP after the IHDR chunk. PNG has no place for
+// IPTC.
+func embedPNG(data []byte, packets Packets) []byte {
+	const ihdrEnd = 8 + 8 + 13 + 4
+
+	var chunks []byte
+	add := func(typ string, payload []byte) {
+		chunks = binary.BigEndian.AppendUint32(chunks, uint32(len(payload)))
+		start := len(chunks)
+		chunks = append(chunks, typ...)
+		chunks = append(chunks, payload...)
+		chunks = binary.BigEndian.AppendUint32(chunks, crc32.ChecksumIEEE(chunks[start:]))
+	}
+
+	if len(packets.EXIF) > 0 {
+		add("eXIf", packets.EXIF)
+	}
+	if len(packets.XMP) > 0 {
+		// Keyword, no compression, no language tag nor translated keyword.
+		add("iTXt", append([]byte("XML:com.adobe.xmp\x00\x00\x00\x00\x00"), packets.XMP...))
+	}
+	if chunks == nil || len(data) < ihdrEnd {
+		return data
+	}
+
+	file := make([]byte, 0, len(data)+len(chunks))
+	file = append(file, data[:ihdrEnd]...)
+	file = append(file, chunks...)
+
+	return append(file, data[ihdrEnd:]...)
+}
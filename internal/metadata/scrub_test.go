@@ -0,0 +1,164 @@
+package metadata_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"imageProcessor/internal/metadata"
+	"imageProcessor/internal/models"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackets(t *testing.T) {
+	withArtist := buildTIFF(binary.LittleEndian,
+		[]entry{
+			short(0x0100, 4000), // ImageWidth, of the stored pixels
+			ascii(0x010f, "Canon"),
+			short(0x0112, 6),
+			ascii(0x013b, "Jane Roe"),
+			ascii(0x8298, "(c) Jane Roe"),
+		},
+		[]entry{ascii(0x9003, "2021:06:15 14:30:00"), short(0xa002, 4000)},
+		[]entry{ascii(0x0001, "N"), rational(0x0002, 55, 1, 45, 1, 216, 10), ascii(0x0003, "E"), rational(0x0004, 37, 1, 37, 1, 12, 10)},
+	)
+	exifJPEG := withSegments(t,
+		segment(0xe1, append([]byte("Exif\x00\x00"), withArtist...)),
+		segment(0xe1, append([]byte("http://ns.adobe.com/xap/1.0/\x00"), `<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"><rdf:Description xmlns:tiff="http://ns.adobe.com/tiff/1.0/" tiff:Orientation="6"/></rdf:RDF></x:xmpmeta>`...)),
+		segment(0xed, photoshop(dataset(25, "cat"))),
+	)
+	xmpJPEG := withSegments(t, segment(0xe1, append([]byte("http://ns.adobe.com/xap/1.0/\x00"), xmpPacket...)))
+
+	tests := []struct {
+		name    string
+		file    []byte
+		policy  metadata.Policy
+		upright bool
+		target  []byte
+		check   func(t *testing.T, m *models.ImageMetadata)
+	}{
+		{
+			name:   "Strip",
+			file:   exifJPEG,
+			policy: metadata.PolicyStrip,
+			target: withSegments(t),
+			check: func(t *testing.T, m *models.ImageMetadata) {
+				require.Equal(t, &models.ImageMetadata{}, m)
+			},
+		},
+		{
+			name:   "Copyright",
+			file:   exifJPEG,
+			policy: metadata.PolicyCopyright,
+			target: withSegments(t),
+			check: func(t *testing.T, m *models.ImageMetadata) {
+				require.Equal(t, map[string]any{"Artist": "Jane Roe", "Copyright": "(c) Jane Roe"}, m.EXIF)
+				require.Nil(t, m.GPS)
+				require.Nil(t, m.XMP)
+				require.Nil(t, m.IPTC)
+			},
+		},
+		{
+			name:   "Copyright From XMP",
+			file:   withSegments(t, segment(0xe1, append([]byte("http://ns.adobe.com/xap/1.0/\x00"), xmpPacket...))),
+			policy: metadata.PolicyCopyright,
+			target: withChunks(t),
+			check: func(t *testing.T, m *models.ImageMetadata) {
+				require.Equal(t, map[string]any{"Artist": "Jane Roe"}, m.EXIF)
+			},
+		},
+		{
+			name:    "Keep Upright",
+			file:    exifJPEG,
+			policy:  metadata.PolicyKeep,
+			upright: true,
+			target:  withSegments(t),
+			check: func(t *testing.T, m *models.ImageMetadata) {
+				require.Equal(t, 1, m.Orientation)
+				require.Equal(t, &models.Camera{Make: "Canon"}, m.Camera)
+				require.NotNil(t, m.TakenAt)
+				require.NotNil(t, m.GPS)
+				require.InDelta(t, 37.617, m.GPS.Longitude, 0.001)
+				require.NotContains(t, m.EXIF, "PixelXDimension")
+				require.Equal(t, []string{"1"}, m.XMP["tiff:Orientation"])
+				require.Equal(t, []string{"cat"}, m.IPTC["Keywords"])
+			},
+		},
+		{
+			name:   "Keep As Stored",
+			file:   exifJPEG,
+			policy: metadata.PolicyKeep,
+			target: withSegments(t),
+			check: func(t *testing.T, m *models.ImageMetadata) {
+				require.Equal(t, 6, m.Orientation)
+				require.Equal(t, []string{"6"}, m.XMP["tiff:Orientation"])
+			},
+		},
+		{
+			name:    "Keep In PNG",
+			file:    exifJPEG,
+			policy:  metadata.PolicyKeep,
+			upright: true,
+			target:  withChunks(t),
+			check: func(t *testing.T, m *models.ImageMetadata) {
+				require.Equal(t, 1, m.Orientation)
+				require.NotNil(t, m.GPS)
+				require.NotNil(t, m.XMP)
+				require.Nil(t, m.IPTC)
+			},
+		},
+		{
+			name:   "Keep From TIFF",
+			file:   withArtist,
+			policy: metadata.PolicyKeep,
+			target: withSegments(t),
+			check: func(t *testing.T, m *models.ImageMetadata) {
+				require.NotContains(t, m.EXIF, "ImageWidth")
+				require.Equal(t, "Jane Roe", m.EXIF["Artist"])
+			},
+		},
+		{
+			name:   "Keep XMP",
+			file:   xmpJPEG,
+			policy: metadata.PolicyKeep,
+			target: withSegments(t),
+			check: func(t *testing.T, m *models.ImageMetadata) {
+				require.Equal(t, []string{"paris", "tower"}, m.XMP["dc:subject"])
+				require.Nil(t, m.EXIF)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := metadata.Read(bytes.NewReader(tt.file))
+			require.NoError(t, err)
+
+			embedded := metadata.Embed(tt.target, f.Packets(tt.policy, tt.upright))
+			_, _, err = image.Decode(bytes.NewReader(embedded))
+			require.NoError(t, err)
+
+			m, err := metadata.Extract(bytes.NewReader(embedded))
+			require.NoError(t, err)
+			tt.check(t, m)
+		})
+	}
+}
+
+func TestEmbedOtherFormats(t *testing.T) {
+	gif := []byte("GIF89a...")
+
+	require.Equal(t, gif, metadata.Embed(gif, metadata.Packets{EXIF: []byte("II*\x00")}))
+}
+
+func TestParsePolicy(t *testing.T) {
+	for _, s := range []string{"strip", "copyright", "keep"} {
+		p, err := metadata.ParsePolicy(s)
+		require.NoError(t, err)
+		require.Equal(t, metadata.Policy(s), p)
+	}
+
+	_, err := metadata.ParsePolicy("all")
+	require.Error(t, err)
+}
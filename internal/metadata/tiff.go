@@ -0,0 +1,399 @@
+package metadata
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+)
+
+// EXIF is stored as a TIFF structure: a header and chains of IFDs, lists of
+// tagged values, with the EXIF and GPS tags in IFDs of their own that IFD 0
+// points to.
+
+const (
+	tagExifIFD = 0x8769
+	tagGPSIFD  = 0x8825
+	tagXMP     = 0x02bc
+	tagIPTC    = 0x83bb
+
+	// maxEntries and maxValueSize bound what a corrupt or hostile IFD can
+	// make the reader allocate. Larger values, such as maker notes and
+	// thumbnails, are skipped.
+	maxEntries   = 1024
+	maxValueSize = 64 << 10
+	// maxPacketSize bounds the XMP and IPTC packets a TIFF file embeds.
+	maxPacketSize = 1 << 20
+)
+
+var errNotTIFF = errors.New("not a TIFF structure")
+
+// Field types.
+const (
+	typeByte      = 1
+	typeASCII     = 2
+	typeShort     = 3
+	typeLong      = 4
+	typeRational  = 5
+	typeSByte     = 6
+	typeUndefined = 7
+	typeSShort    = 8
+	typeSLong     = 9
+	typeSRational = 10
+	typeFloat     = 11
+	typeDouble    = 12
+)
+
+var typeSizes = map[uint16]int64{
+	typeByte: 1, typeASCII: 1, typeShort: 2, typeLong: 4, typeRational: 8, typeSByte: 1,
+	typeUndefined: 1, typeSShort: 2, typeSLong: 4, typeSRational: 8, typeFloat: 4, typeDouble: 8,
+}
+
+// byteOrder reads and writes the integers of a TIFF structure.
+type byteOrder interface {
+	binary.ByteOrder
+	binary.AppendByteOrder
+}
+
+type field struct {
+	typ   uint16
+	count int64
+	data  []byte
+	order byteOrder
+}
+
+// tiff holds the fields of IFD 0 and of the EXIF and GPS IFDs, by tag.
+type tiff struct {
+	order byteOrder
+	ifd0  map[uint16]field
+	exif  map[uint16]field
+	gps   map[uint16]field
+}
+
+// readTIFF reads the IFDs of the TIFF structure of size bytes in r.
+func readTIFF(r io.ReaderAt, size int64) (*tiff, error) {
+	header := make([]byte, 8)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, errNotTIFF
+	}
+
+	var order byteOrder
+	switch string(header[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, errNotTIFF
+	}
+	if order.Uint16(header[2:]) != 42 {
+		return nil, errNotTIFF
+	}
+
+	reader := &ifdReader{r: r, size: size, order: order, seen: make(map[int64]bool)}
+
+	t := &tiff{order: order}
+	var err error
+	if t.ifd0, err = reader.read(int64(order.Uint32(header[4:])), true); err != nil {
+		return nil, err
+	}
+
+	// A broken EXIF or GPS IFD doesn't spoil what IFD 0 holds.
+	if offset, ok := t.ifd0[tagExifIFD].uint(0); ok {
+		t.exif, _ = reader.read(int64(offset), false)
+	}
+	if offset, ok := t.ifd0[tagGPSIFD].uint(0); ok {
+		t.gps, _ = reader.read(int64(offset), false)
+	}
+
+	return t, nil
+}
+
+type ifdReader struct {
+	r     io.ReaderAt
+	size  int64
+	order byteOrder
+	// seen keeps IFDs pointing at each other from being read forever.
+	seen map[int64]bool
+}
+
+// read returns the fields of the IFD at offset. The tags listing embedded
+// XMP and IPTC packets may be larger than other values if packets is set.
+func (ir *ifdReader) read(offset int64, packets bool) (map[uint16]field, error) {
+	if offset < 8 || offset+2 > ir.size || ir.seen[offset] {
+		return nil, fmt.Errorf("invalid IFD offset %d", offset)
+	}
+	ir.seen[offset] = true
+
+	buf := make([]byte, 2)
+	if _, err := ir.r.ReadAt(buf, offset); err != nil {
+		return nil, err
+	}
+
+	n := int64(ir.order.Uint16(buf))
+	if n > maxEntries || offset+2+n*12 > ir.size {
+		return nil, fmt.Errorf("invalid IFD of %d entries", n)
+	}
+
+	entries := make([]byte, n*12)
+	if _, err := ir.r.ReadAt(entries, offset+2); err != nil {
+		return nil, err
+	}
+
+	fields := make(map[uint16]field, n)
+	for i := int64(0); i < n; i++ {
+		entry := entries[i*12 : i*12+12]
+
+		tag := ir.order.Uint16(entry)
+		f := field{typ: ir.order.Uint16(entry[2:]), count: int64(ir.order.Uint32(entry[4:])), order: ir.order}
+
+		typeSize, ok := typeSizes[f.typ]
+		if !ok {
+			continue
+		}
+
+		limit := int64(maxValueSize)
+		if packets && (tag == tagXMP || tag == tagIPTC) {
+			limit = maxPacketSize
+		}
+
+		length := f.count * typeSize
+		if length > limit {
+			continue
+		}
+
+		if length <= 4 {
+			f.data = entry[8 : 8+length]
+		} else {
+			at := int64(ir.order.Uint32(entry[8:]))
+			if at+length > ir.size {
+				continue
+			}
+			f.data = make([]byte, length)
+			if _, err := ir.r.ReadAt(f.data, at); err != nil {
+				continue
+			}
+		}
+
+		fields[tag] = f
+	}
+
+	return fields, nil
+}
+
+// uint returns the i-th value of an integer field.
+func (f field) uint(i int64) (uint64, bool) {
+	if i >= f.count {
+		return 0, false
+	}
+
+	switch f.typ {
+	case typeByte, typeUndefined:
+		return uint64(f.data[i]), true
+	case typeShort:
+		return uint64(f.order.Uint16(f.data[i*2:])), true
+	case typeLong:
+		return uint64(f.order.Uint32(f.data[i*4:])), true
+	}
+
+	return 0, false
+}
+
+// float returns the i-th value of a numeric field.
+func (f field) float(i int64) (float64, bool) {
+	if i >= f.count {
+		return 0, false
+	}
+
+	switch f.typ {
+	case typeRational:
+		num, den := f.order.Uint32(f.data[i*8:]), f.order.Uint32(f.data[i*8+4:])
+		if den == 0 {
+			return 0, false
+		}
+		return float64(num) / float64(den), true
+	case typeSRational:
+		num, den := int32(f.order.Uint32(f.data[i*8:])), int32(f.order.Uint32(f.data[i*8+4:]))
+		if den == 0 {
+			return 0, false
+		}
+		return float64(num) / float64(den), true
+	case typeSByte:
+		return float64(int8(f.data[i])), true
+	case typeSShort:
+		return float64(int16(f.order.Uint16(f.data[i*2:]))), true
+	case typeSLong:
+		return float64(int32(f.order.Uint32(f.data[i*4:]))), true
+	case typeFloat, typeDouble:
+		var v float64
+		if f.typ == typeFloat {
+			v = float64(math.Float32frombits(f.order.Uint32(f.data[i*4:])))
+		} else {
+			v = math.Float64frombits(f.order.Uint64(f.data[i*8:]))
+		}
+		// Neither can be stored as JSON.
+		return v, !math.IsNaN(v) && !math.IsInf(v, 0)
+	}
+
+	v, ok := f.uint(i)
+
+	return float64(v), ok
+}
+
+// rational returns the i-th value of a rational field as a fraction.
+func (f field) rational(i int64) (num, den uint32, ok bool) {
+	if f.typ != typeRational || i >= f.count {
+		return 0, 0, false
+	}
+
+	return f.order.Uint32(f.data[i*8:]), f.order.Uint32(f.data[i*8+4:]), true
+}
+
+// string returns the text of an ASCII field, or of an UNDEFINED one that
+// holds text.
+func (f field) string() (string, bool) {
+	if f.typ != typeASCII && f.typ != typeUndefined {
+		return "", false
+	}
+
+	s := strings.TrimSpace(strings.ToValidUTF8(strings.TrimRight(string(f.data), "\x00"), ""))
+	if s == "" || !printable(s) {
+		return "", false
+	}
+
+	return s, true
+}
+
+// value returns the field as it is listed among the EXIF properties: text
+// for text, a number for a single number and a list for several.
+func (f field) value() (any, bool) {
+	if f.typ == typeASCII || f.typ == typeUndefined {
+		s, ok := f.string()
+		return s, ok
+	}
+
+	if f.count == 1 {
+		return f.float(0)
+	}
+
+	values := make([]float64, 0, f.count)
+	for i := int64(0); i < f.count; i++ {
+		v, ok := f.float(i)
+		if !ok {
+			return nil, false
+		}
+		values = append(values, v)
+	}
+
+	return values, len(values) > 0
+}
+
+func printable(s string) bool {
+	for _, r := range s {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// writeTIFF lays out a TIFF structure with IFD 0 and, if they have fields,
+// the EXIF and GPS IFDs. The data of the fields has to be in order.
+func writeTIFF(order byteOrder, ifd0, exif, gps map[uint16]field) []byte {
+	ifd0 = maps.Clone(ifd0)
+	delete(ifd0, tagExifIFD)
+	delete(ifd0, tagGPSIFD)
+
+	// The pointers to the EXIF and GPS IFDs are fields of IFD 0 too, so
+	// they are given their place before the offsets are known.
+	pointer := field{typ: typeLong, count: 1, data: make([]byte, 4), order: order}
+	if len(exif) > 0 {
+		ifd0[tagExifIFD] = pointer
+	}
+	if len(gps) > 0 {
+		ifd0[tagGPSIFD] = pointer
+	}
+
+	exifOffset := 8 + ifdSize(ifd0)
+	gpsOffset := exifOffset + ifdSize(exif)
+	if len(exif) > 0 {
+		ifd0[tagExifIFD] = field{typ: typeLong, count: 1, data: order.AppendUint32(nil, uint32(exifOffset)), order: order}
+	}
+	if len(gps) > 0 {
+		ifd0[tagGPSIFD] = field{typ: typeLong, count: 1, data: order.AppendUint32(nil, uint32(gpsOffset)), order: order}
+	}
+
+	buf := make([]byte, 8, gpsOffset+ifdSize(gps))
+	if order == binary.LittleEndian {
+		copy(buf, "II")
+	} else {
+		copy(buf, "MM")
+	}
+	order.PutUint16(buf[2:], 42)
+	order.PutUint32(buf[4:], 8)
+
+	buf = appendIFD(buf, order, ifd0)
+	if len(exif) > 0 {
+		buf = appendIFD(buf, order, exif)
+	}
+	if len(gps) > 0 {
+		buf = appendIFD(buf, order, gps)
+	}
+
+	return buf
+}
+
+// ifdSize is the size of an IFD written by appendIFD, with its values.
+func ifdSize(fields map[uint16]field) int {
+	if len(fields) == 0 {
+		return 0
+	}
+
+	size := 2 + len(fields)*12 + 4
+	for _, f := range fields {
+		if len(f.data) > 4 {
+			size += len(f.data) + len(f.data)%2
+		}
+	}
+
+	return size
+}
+
+// appendIFD writes an IFD at the end of buf, followed by the values that
+// don't fit in its entries, each starting at an even offset.
+func appendIFD(buf []byte, order byteOrder, fields map[uint16]field) []byte {
+	tags := slices.Sorted(maps.Keys(fields))
+
+	offset := len(buf) + 2 + len(tags)*12 + 4
+	var values []byte
+
+	buf = order.AppendUint16(buf, uint16(len(tags)))
+	for _, tag := range tags {
+		f := fields[tag]
+
+		buf = order.AppendUint16(buf, tag)
+		buf = order.AppendUint16(buf, f.typ)
+		buf = order.AppendUint32(buf, uint32(f.count))
+
+		if len(f.data) <= 4 {
+			value := make([]byte, 4)
+			copy(value, f.data)
+			buf = append(buf, value...)
+			continue
+		}
+
+		buf = order.AppendUint32(buf, uint32(offset+len(values)))
+		values = append(values, f.data...)
+		if len(f.data)%2 == 1 {
+			values = append(values, 0)
+		}
+	}
+	buf = order.AppendUint32(buf, 0)
+
+	return append(buf, values...)
+}
@@ -0,0 +1,215 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"imageProcessor/internal/models"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// XMP is RDF/XML: properties are the attributes and child elements of
+// rdf:Description elements, and the values of lists are rdf:li elements.
+
+const (
+	rdfNS = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	xmlNS = "http://www.w3.org/XML/1998/namespace"
+
+	// maxXMPProperties and maxXMPValues bound what an XMP packet may fill
+	// the metadata with; edit histories can run long.
+	maxXMPProperties = 512
+	maxXMPValues     = 256
+)
+
+// xmpPrefixes names the properties of well-known namespaces by their usual
+// prefix, whatever the packet calls them.
+var xmpPrefixes = map[string]string{
+	"http://purl.org/dc/elements/1.1/":             "dc",
+	"http://ns.adobe.com/xap/1.0/":                 "xmp",
+	"http://ns.adobe.com/xap/1.0/rights/":          "xmpRights",
+	"http://ns.adobe.com/xap/1.0/mm/":              "xmpMM",
+	"http://ns.adobe.com/photoshop/1.0/":           "photoshop",
+	"http://ns.adobe.com/exif/1.0/":                "exif",
+	"http://cipa.jp/exif/1.0/":                     "exifEX",
+	"http://ns.adobe.com/tiff/1.0/":                "tiff",
+	"http://ns.adobe.com/exif/1.0/aux/":            "aux",
+	"http://iptc.org/std/Iptc4xmpCore/1.0/xmlns/":  "Iptc4xmpCore",
+	"http://iptc.org/std/Iptc4xmpExt/2008-02-29/":  "Iptc4xmpExt",
+	"http://ns.adobe.com/lightroom/1.0/":           "lr",
+	"http://ns.adobe.com/camera-raw-settings/1.0/": "crs",
+}
+
+func xmpName(name xml.Name) string {
+	prefix, ok := xmpPrefixes[name.Space]
+	if !ok {
+		return name.Local
+	}
+
+	return prefix + ":" + name.Local
+}
+
+// readXMP lists the properties of an XMP packet. Fields of structures are
+// listed under the property holding them.
+func readXMP(packet []byte) (map[string][]string, error) {
+	properties := make(map[string][]string)
+	add := func(name, value string) {
+		value = strings.TrimSpace(value)
+		if value == "" || len(properties[name]) >= maxXMPValues {
+			return
+		}
+		if _, ok := properties[name]; !ok && len(properties) >= maxXMPProperties {
+			return
+		}
+		properties[name] = append(properties[name], value)
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(packet))
+	decoder.Strict = false
+
+	// The property an element belongs to is the child of the outermost
+	// rdf:Description it is in.
+	var stack []xml.Name
+	property := func() (string, bool) {
+		for i, name := range stack {
+			if name.Space == rdfNS && name.Local == "Description" {
+				if i+1 < len(stack) {
+					return xmpName(stack[i+1]), true
+				}
+				return "", true
+			}
+		}
+		return "", false
+	}
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			// A packet cut short still gives what came before.
+			if errors.Is(err, io.EOF) || len(properties) > 0 {
+				return properties, nil
+			}
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			stack = append(stack, t.Name)
+
+			name, ok := property()
+			if !ok {
+				continue
+			}
+			for _, attr := range t.Attr {
+				if attr.Name.Space == "" || attr.Name.Space == rdfNS || attr.Name.Space == "xmlns" || attr.Name.Space == xmlNS {
+					continue
+				}
+				// Attributes of a description are properties themselves.
+				if name == "" {
+					add(xmpName(attr.Name), attr.Value)
+				} else {
+					add(name, attr.Value)
+				}
+			}
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			if name, _ := property(); name != "" {
+				add(name, string(t))
+			}
+		}
+	}
+}
+
+// applyXMP fills the fields of m that XMP has and m lacks.
+func applyXMP(properties map[string][]string, m *models.ImageMetadata) {
+	first := func(names ...string) string {
+		for _, name := range names {
+			if values := properties[name]; len(values) > 0 {
+				return values[0]
+			}
+		}
+		return ""
+	}
+
+	if m.Camera == nil {
+		if camera := (models.Camera{Make: first("tiff:Make"), Model: first("tiff:Model")}); camera != (models.Camera{}) {
+			m.Camera = &camera
+		}
+	}
+	if m.Lens == nil {
+		if lens := (models.Lens{Make: first("exifEX:LensMake"), Model: first("exifEX:LensModel", "aux:Lens")}); lens != (models.Lens{}) {
+			m.Lens = &lens
+		}
+	}
+
+	if m.TakenAt == nil {
+		if t, ok := parseXMPTime(first("exif:DateTimeOriginal", "photoshop:DateCreated", "xmp:CreateDate")); ok {
+			m.TakenAt = &t
+		}
+	}
+
+	if m.Orientation == 0 {
+		if orientation, err := strconv.Atoi(first("tiff:Orientation")); err == nil && orientation >= 1 && orientation <= 8 {
+			m.Orientation = orientation
+		}
+	}
+
+	if m.GPS == nil {
+		latitude, okLat := parseXMPCoordinate(first("exif:GPSLatitude"), 'S')
+		longitude, okLon := parseXMPCoordinate(first("exif:GPSLongitude"), 'W')
+		if okLat && okLon && math.Abs(latitude) <= 90 && math.Abs(longitude) <= 180 {
+			m.GPS = &models.GPS{Latitude: latitude, Longitude: longitude}
+		}
+	}
+}
+
+// parseXMPTime reads an XMP date, which may leave out the zone, the seconds
+// or even the time.
+func parseXMPTime(value string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999", "2006-01-02T15:04Z07:00", "2006-01-02T15:04", "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// parseXMPCoordinate reads a GPS coordinate of XMP, "DDD,MM,SSk" or
+// "DDD,MM.mmk", where k is the direction.
+func parseXMPCoordinate(value string, negative byte) (float64, bool) {
+	if len(value) < 2 {
+		return 0, false
+	}
+
+	direction := value[len(value)-1]
+	parts := strings.Split(value[:len(value)-1], ",")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, false
+	}
+
+	var coordinate float64
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return 0, false
+		}
+		coordinate += v / math.Pow(60, float64(i))
+	}
+
+	if direction == negative {
+		coordinate = -coordinate
+	}
+
+	return coordinate, true
+}
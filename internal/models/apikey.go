@@ -0,0 +1,16 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type APIKey struct {
+	ID        uuid.UUID  `db:"id" json:"ID"`
+	TenantID  string     `db:"tenant_id" json:"TenantID"`
+	Name      string     `db:"name" json:"Name"`
+	Prefix    string     `db:"key_prefix" json:"Prefix"`
+	Scopes    []string   `db:"scopes" json:"Scopes"`
+	CreatedAt time.Time  `db:"created_at" json:"CreatedAt"`
+	RevokedAt *time.Time `db:"revoked_at" json:"RevokedAt"`
+}
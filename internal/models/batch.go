@@ -0,0 +1,21 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// Batch groups the images uploaded in one batch request.
+type Batch struct {
+	ID         uuid.UUID  `db:"id" json:"ID"`
+	TenantID   string     `db:"tenant_id" json:"TenantID"`
+	OwnerKeyID *uuid.UUID `db:"owner_key_id" json:"OwnerKeyID"`
+	CreatedAt  time.Time  `db:"created_at" json:"CreatedAt"`
+}
+
+// BatchImage is the state of one image of a batch.
+type BatchImage struct {
+	ID       uuid.UUID `json:"ID"`
+	Filename string    `json:"Filename"`
+	Status   string    `json:"Status"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	ImageEventStatus  = "status"
+	ImageEventVariant = "variant"
+)
+
+// ImageEvent announces progress of an image: a change of its status, or a
+// variant stored in one format.
+type ImageEvent struct {
+	Type       string     `json:"type"`
+	TenantID   string     `json:"tenant_id"`
+	ImageID    uuid.UUID  `json:"image_id"`
+	OwnerKeyID *uuid.UUID `json:"owner_key_id,omitempty"`
+	Status     string     `json:"status,omitempty"`
+	Variant    string     `json:"variant,omitempty"`
+	Format     string     `json:"format,omitempty"`
+	At         time.Time  `json:"at"`
+}
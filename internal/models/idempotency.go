@@ -0,0 +1,21 @@
+package models
+
+import (
+	"net/http"
+	"time"
+)
+
+// IdempotentRequest is a request made with an Idempotency-Key and, once it
+// has been answered, the response to replay when the key comes again.
+type IdempotentRequest struct {
+	Key string
+	// Fingerprint identifies the request the key was first used for.
+	Fingerprint string
+	// Completed is unset while the first request is still in progress.
+	Completed  bool
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+}
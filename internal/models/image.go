@@ -6,13 +6,52 @@ import (
 )
 
 type Image struct {
-	ID                     uuid.UUID `db:"id" json:"ID"`
-	Filename               string    `db:"filename" json:"Filename"`
-	Status                 string    `db:"status" json:"Status"`
-	OriginalPath           string    `db:"original_path" json:"OriginalPath"`
-	ProcessedPathResize    *string   `db:"processed_path_resize" json:"ProcessedPathResize"`       // <-- Изменили
-	ProcessedPathThumbnail *string   `db:"processed_path_thumbnail" json:"ProcessedPathThumbnail"` // <-- Изменили
-	ProcessedPathWatermark *string   `db:"processed_path_watermark" json:"ProcessedPathWatermark"` // <-- Изменили
-	CreatedAt              time.Time `db:"created_at" json:"CreatedAt"`
-	UpdatedAt              time.Time `db:"updated_at" json:"UpdatedAt"`
+	ID                     uuid.UUID  `db:"id" json:"ID"`
+	TenantID               string     `db:"tenant_id" json:"TenantID"`
+	Filename               string     `db:"filename" json:"Filename"`
+	Status                 string     `db:"status" json:"Status"`
+	OriginalPath           string     `db:"original_path" json:"OriginalPath"`
+	Size                   int64      `db:"size" json:"Size"`
+	ProcessedPathResize    *string    `db:"processed_path_resize" json:"ProcessedPathResize"`       // <-- Изменили
+	ProcessedPathThumbnail *string    `db:"processed_path_thumbnail" json:"ProcessedPathThumbnail"` // <-- Изменили
+	ProcessedPathWatermark *string    `db:"processed_path_watermark" json:"ProcessedPathWatermark"` // <-- Изменили
+	OwnerKeyID             *uuid.UUID `db:"owner_key_id" json:"OwnerKeyID"`
+	CallbackURL            *string    `db:"callback_url" json:"CallbackURL"`
+	BatchID                *uuid.UUID `db:"batch_id" json:"BatchID"`
+	SHA256                 *string    `db:"sha256" json:"SHA256"`
+	UploadExpiresAt        *time.Time `db:"upload_expires_at" json:"UploadExpiresAt"`
+	CreatedAt              time.Time  `db:"created_at" json:"CreatedAt"`
+	UpdatedAt              time.Time  `db:"updated_at" json:"UpdatedAt"`
+}
+
+// Upload describes a stored original to be recorded as a new image.
+type Upload struct {
+	Filename     string
+	OriginalPath string
+	Size         int64
+	OwnerKeyID   *uuid.UUID
+	CallbackURL  string
+	BatchID      *uuid.UUID
+	SHA256       string
+	// ExpiresAt, if set, records a presigned upload whose original is yet
+	// to be sent: the image is pending_upload until then.
+	ExpiresAt *time.Time
+}
+
+// ImageFilter selects images. Unset fields match every image.
+type ImageFilter struct {
+	IDs           []uuid.UUID
+	BatchID       *uuid.UUID
+	Status        string
+	OwnerKeyID    *uuid.UUID
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	// TakenAfter, TakenBefore, CameraMake and CameraModel match the
+	// metadata of the original; camera names ignore case.
+	TakenAfter  *time.Time
+	TakenBefore *time.Time
+	CameraMake  string
+	CameraModel string
+	// Limit caps the number of images returned when positive.
+	Limit int
 }
@@ -0,0 +1,11 @@
+package models
+
+import "github.com/google/uuid"
+
+// ProcessingJob is the Kafka message that asks the processor to build the
+// variants of an uploaded image.
+type ProcessingJob struct {
+	ImageID      uuid.UUID `json:"image_id"`
+	TenantID     string    `json:"tenant_id"`
+	OriginalPath string    `json:"original_path"`
+}
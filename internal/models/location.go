@@ -0,0 +1,27 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"imageProcessor/internal/geo"
+)
+
+// LocatedImage is an image found by where it was taken.
+type LocatedImage struct {
+	Image     Image   `json:"image"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	// DistanceKm is how far from the center of the search the image was
+	// taken.
+	DistanceKm float64 `json:"distance_km"`
+}
+
+// LocationFilter selects the images taken within Box and, if RadiusKm is
+// set, at most RadiusKm from Center. Distances are measured from Center.
+type LocationFilter struct {
+	Box        geo.Box
+	Center     geo.Point
+	RadiusKm   float64
+	OwnerKeyID *uuid.UUID
+	Limit      int
+	Offset     int
+}
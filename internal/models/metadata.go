@@ -0,0 +1,50 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// ImageMetadata is what the original of an image tells about itself in its
+// EXIF, IPTC and XMP metadata. The leading fields are read from whichever
+// of them has them, EXIF first.
+type ImageMetadata struct {
+	ImageID     uuid.UUID  `json:"image_id"`
+	Camera      *Camera    `json:"camera,omitempty"`
+	Lens        *Lens      `json:"lens,omitempty"`
+	Exposure    *Exposure  `json:"exposure,omitempty"`
+	TakenAt     *time.Time `json:"taken_at,omitempty"`
+	Orientation int        `json:"orientation,omitempty"`
+	GPS         *GPS       `json:"gps,omitempty"`
+	// EXIF, IPTC and XMP hold every property read, by name.
+	EXIF        map[string]any      `json:"exif,omitempty"`
+	IPTC        map[string][]string `json:"iptc,omitempty"`
+	XMP         map[string][]string `json:"xmp,omitempty"`
+	ExtractedAt time.Time           `json:"extracted_at"`
+}
+
+type Camera struct {
+	Make  string `json:"make,omitempty"`
+	Model string `json:"model,omitempty"`
+}
+
+type Lens struct {
+	Make  string `json:"make,omitempty"`
+	Model string `json:"model,omitempty"`
+}
+
+type Exposure struct {
+	// Time is in seconds, as a fraction for short exposures: "1/250".
+	Time            string  `json:"time,omitempty"`
+	FNumber         float64 `json:"f_number,omitempty"`
+	ISO             int     `json:"iso,omitempty"`
+	FocalLength     float64 `json:"focal_length,omitempty"`
+	FocalLength35mm int     `json:"focal_length_35mm,omitempty"`
+}
+
+type GPS struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	// Altitude is in metres above sea level.
+	Altitude *float64 `json:"altitude,omitempty"`
+}
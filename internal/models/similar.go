@@ -0,0 +1,18 @@
+package models
+
+import "github.com/google/uuid"
+
+// SimilarImage is an image found to look like another one.
+type SimilarImage struct {
+	Image Image `json:"image"`
+	// Distance is the number of bits the perceptual hashes of the images
+	// differ in.
+	Distance int `json:"distance"`
+}
+
+// SimilarFilter selects the images similar to an image.
+type SimilarFilter struct {
+	MaxDistance int
+	OwnerKeyID  *uuid.UUID
+	Limit       int
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// TusUpload is a resumable upload. The bytes received so far are kept as
+// chunks, one blob per PATCH request, until the last one arrives and they
+// are joined into the original of a new image.
+type TusUpload struct {
+	ID         uuid.UUID
+	TenantID   string
+	OwnerKeyID *uuid.UUID
+	Length     int64
+	Offset     int64
+	// Metadata is the Upload-Metadata header the upload was created with.
+	Metadata    string
+	Filename    string
+	CallbackURL string
+	Chunks      []string
+	// ImageID is set once the upload is complete.
+	ImageID   *uuid.UUID
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// Usage is what a tenant or an API key currently stores, plus the uploads it
+// made in the rate window that started at WindowStart.
+type Usage struct {
+	Bytes         int64     `db:"bytes" json:"Bytes"`
+	Images        int64     `db:"images" json:"Images"`
+	WindowStart   time.Time `db:"window_start" json:"WindowStart"`
+	WindowUploads int       `db:"window_uploads" json:"WindowUploads"`
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OriginalVariant is the variant holding the original encoded anew without
+// its metadata, published in place of the upload when originals are
+// sanitized.
+const OriginalVariant = "original"
+
+type Variant struct {
+	ImageID     uuid.UUID `db:"image_id" json:"ImageID"`
+	Name        string    `db:"name" json:"Name"`
+	Format      string    `db:"format" json:"Format"`
+	BlobKey     string    `db:"blob_key" json:"BlobKey"`
+	ContentType string    `db:"content_type" json:"ContentType"`
+	Size        int64     `db:"size" json:"Size"`
+	Checksum    string    `db:"checksum" json:"Checksum"`
+	CreatedAt   time.Time `db:"created_at" json:"CreatedAt"`
+}
+
+// WidthVariant names the variant rendered at width pixels across for
+// srcset attributes.
+func WidthVariant(width int) string {
+	return "w" + strconv.Itoa(width)
+}
+
+// VariantWidth returns the width of a variant named by WidthVariant.
+func VariantWidth(name string) (int, bool) {
+	digits, ok := strings.CutPrefix(name, "w")
+	if !ok {
+		return 0, false
+	}
+
+	width, err := strconv.Atoi(digits)
+	if err != nil || width <= 0 || WidthVariant(width) != name {
+		return 0, false
+	}
+
+	return width, true
+}
+
+// Widths returns the widths of the width variants among variants, narrowest
+// first.
+func Widths(variants []Variant) []int {
+	var widths []int
+	for _, v := range variants {
+		if width, ok := VariantWidth(v.Name); ok && !slices.Contains(widths, width) {
+			widths = append(widths, width)
+		}
+	}
+	slices.Sort(widths)
+
+	return widths
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+	"time"
+)
+
+type Webhook struct {
+	ID        uuid.UUID `db:"id" json:"ID"`
+	TenantID  string    `db:"tenant_id" json:"TenantID"`
+	APIKeyID  uuid.UUID `db:"api_key_id" json:"APIKeyID"`
+	URL       string    `db:"url" json:"URL"`
+	CreatedAt time.Time `db:"created_at" json:"CreatedAt"`
+}
+
+// WebhookDelivery is an event on its way to one URL. WebhookID is nil for
+// the callback URL given with an upload.
+type WebhookDelivery struct {
+	ID             uuid.UUID       `db:"id" json:"ID"`
+	TenantID       string          `db:"tenant_id" json:"TenantID"`
+	APIKeyID       uuid.UUID       `db:"api_key_id" json:"APIKeyID"`
+	WebhookID      *uuid.UUID      `db:"webhook_id" json:"WebhookID"`
+	ImageID        uuid.UUID       `db:"image_id" json:"ImageID"`
+	Event          string          `db:"event" json:"Event"`
+	URL            string          `db:"url" json:"URL"`
+	Payload        json.RawMessage `db:"payload" json:"Payload" swaggertype:"object"`
+	Status         string          `db:"status" json:"Status"`
+	Attempts       int             `db:"attempts" json:"Attempts"`
+	NextAttemptAt  time.Time       `db:"next_attempt_at" json:"NextAttemptAt"`
+	LastStatusCode *int            `db:"last_status_code" json:"LastStatusCode"`
+	LastError      *string         `db:"last_error" json:"LastError"`
+	CreatedAt      time.Time       `db:"created_at" json:"CreatedAt"`
+	DeliveredAt    *time.Time      `db:"delivered_at" json:"DeliveredAt"`
+}
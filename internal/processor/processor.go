@@ -1,73 +1,192 @@
 package processor
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/disintegration/imaging"
 	"github.com/google/uuid"
 	"image"
+	"imageProcessor/internal/config"
+	"imageProcessor/internal/imagehash"
+	"imageProcessor/internal/lib/imageformat"
 	"imageProcessor/internal/lib/logger/sl"
+	"imageProcessor/internal/lib/tenant"
+	"imageProcessor/internal/metadata"
+	"imageProcessor/internal/models"
+	"imageProcessor/internal/storage"
 	"imageProcessor/internal/storage/postgres"
+	"io"
 	"log/slog"
-	"os"
-	"path/filepath"
+	"slices"
 )
 
+const outputDir = "processed"
+
+// Variants are the names of the variants every image is rendered in.
+var Variants = []string{"resize", "thumbnail", "watermark"}
+
+type BlobStorage interface {
+	Put(ctx context.Context, key string, r io.Reader) (*storage.BlobInfo, error)
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, *storage.BlobInfo, error)
+}
+
+// Notifier tells the owner of an image that its processing has finished.
+type Notifier interface {
+	Notify(ctx context.Context, image *models.Image) error
+}
+
 type ImageProcessor struct {
-	storage *postgres.Storage
-	log     *slog.Logger
+	storage  *postgres.Storage
+	blobs    BlobStorage
+	formats  []imageformat.Format
+	notifier Notifier
+	log      *slog.Logger
+	// keepOrientation holds the variants rendered without turning the
+	// original upright.
+	keepOrientation map[string]bool
+	// policy is what metadata of the original the variants carry.
+	policy           metadata.Policy
+	sanitizeOriginal bool
+	// widths is the srcset ladder, narrowest first.
+	widths []int
 }
 
-func NewImageProcessor(log *slog.Logger, storage *postgres.Storage) *ImageProcessor {
-	return &ImageProcessor{
-		log:     log,
-		storage: storage,
+func NewImageProcessor(log *slog.Logger, storage *postgres.Storage, blobs BlobStorage, formats []imageformat.Format, cfg *config.Processing, privacy *config.Privacy, notifier Notifier) (*ImageProcessor, error) {
+	const op = "processor.NewImageProcessor"
+
+	keep := make(map[string]bool, len(cfg.KeepOrientation))
+	for _, name := range cfg.KeepOrientation {
+		if !slices.Contains(Variants, name) {
+			return nil, fmt.Errorf("%s: unknown variant %q", op, name)
+		}
+		keep[name] = true
 	}
+
+	policy, err := metadata.ParsePolicy(privacy.Metadata)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	widths := slices.Clone(cfg.Widths)
+	for _, width := range widths {
+		if width <= 0 {
+			return nil, fmt.Errorf("%s: invalid srcset width %d", op, width)
+		}
+	}
+	slices.Sort(widths)
+	widths = slices.Compact(widths)
+
+	return &ImageProcessor{
+		log:              log,
+		storage:          storage,
+		blobs:            blobs,
+		formats:          formats,
+		notifier:         notifier,
+		keepOrientation:  keep,
+		policy:           policy,
+		sanitizeOriginal: privacy.SanitizeOriginal,
+		widths:           widths,
+	}, nil
 }
 
 func (p *ImageProcessor) ProcessMessage(ctx context.Context, message []byte) error {
 	const op = "processor.ProcessMessage"
 
-	var kafkaMessage struct {
-		ImageID      uuid.UUID `json:"image_id"`
-		OriginalPath string    `json:"original_path"`
-	}
+	var job models.ProcessingJob
 
-	if err := json.Unmarshal(message, &kafkaMessage); err != nil {
+	if err := json.Unmarshal(message, &job); err != nil {
 		p.log.Error("failed to unmarshal kafka message", slog.String("op", op), slog.String("error", err.Error()))
 		return err
 	}
 
-	p.log.Info("processing image", slog.String("op", op), slog.String("image_id", kafkaMessage.ImageID.String()))
+	if err := tenant.Validate(job.TenantID); err != nil {
+		p.log.Error("rejected message without a valid tenant", slog.String("op", op), slog.String("image_id", job.ImageID.String()), sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	ctx = tenant.WithID(ctx, job.TenantID)
+
+	p.log.Info("processing image", slog.String("op", op), slog.String("image_id", job.ImageID.String()), slog.String("tenant_id", job.TenantID))
 
-	src, err := imaging.Open(kafkaMessage.OriginalPath)
+	// The message is only trusted as far as the database agrees with it: the
+	// image has to belong to the tenant and its original has to lie in the
+	// tenant's namespace.
+	img, err := p.storage.GetImage(ctx, job.ImageID)
 	if err != nil {
-		p.log.Error("failed to open image", slog.String("op", op), slog.String("path", kafkaMessage.OriginalPath), slog.String("error", err.Error()))
+		p.log.Error("failed to get image of tenant", slog.String("op", op), slog.String("image_id", job.ImageID.String()), sl.Err(err))
 		return err
 	}
+	if img.OriginalPath != job.OriginalPath || !tenant.OwnsBlobKey(job.TenantID, img.OriginalPath) {
+		p.log.Error("rejected message with a foreign original", slog.String("op", op), slog.String("image_id", job.ImageID.String()), slog.String("path", job.OriginalPath))
+		return fmt.Errorf("%s: original %q does not belong to tenant %s", op, job.OriginalPath, job.TenantID)
+	}
 
-	processedPaths := make(map[string]string)
-	outputDir := "./processed"
-	if _, err = os.Stat(outputDir); os.IsNotExist(err) {
-		err = os.Mkdir(outputDir, os.ModePerm)
-		if err != nil {
-			return err
+	if err = p.process(ctx, img); err != nil {
+		// Kafka does not redeliver the message, so the image is given up on.
+		if err := p.storage.UpdateImageStatus(ctx, img.ID, "failed", nil); err != nil {
+			p.log.Error("failed to mark image as failed", slog.String("op", op), slog.String("image_id", img.ID.String()), sl.Err(err))
+		}
+		p.notify(ctx, img.ID)
+		return err
+	}
+
+	p.log.Info("image processed successfully and status updated", slog.String("op", op), slog.String("image_id", job.ImageID.String()))
+
+	p.notify(ctx, img.ID)
+
+	return nil
+}
+
+// process renders the variants of img and marks it as processed.
+func (p *ImageProcessor) process(ctx context.Context, img *models.Image) error {
+	const op = "processor.process"
+
+	raw, format, file, err := p.openOriginal(ctx, img.OriginalPath)
+	if err != nil {
+		p.log.Error("failed to open image", slog.String("op", op), slog.String("path", img.OriginalPath), slog.String("error", err.Error()))
+		return err
+	}
+
+	var meta *models.ImageMetadata
+	if file != nil {
+		meta = file.Metadata()
+	}
+
+	// Photos are turned upright before anything else is done with them,
+	// unless a variant asks for the original as stored.
+	src := raw
+	if meta != nil {
+		src = metadata.Orient(raw, meta.Orientation)
+	}
+	source := func(variant string) image.Image {
+		if p.keepOrientation[variant] {
+			return raw
+		}
+		return src
+	}
+	// Variants carry what the privacy policy lets through of the metadata
+	// of the original, and nothing if it couldn't be read.
+	packets := func(variant string) metadata.Packets {
+		if file == nil {
+			return metadata.Packets{}
 		}
+		return file.Packets(p.policy, !p.keepOrientation[variant])
 	}
 
-	resizedImage := imaging.Resize(src, 800, 0, imaging.Lanczos)
-	resizedPath := filepath.Join(outputDir, fmt.Sprintf("%s_resized.jpg", kafkaMessage.ImageID))
-	err = imaging.Save(resizedImage, resizedPath)
+	processedPaths := make(map[string]string)
+
+	resizedImage := imaging.Resize(source("resize"), 800, 0, imaging.Lanczos)
+	resizedPath, err := p.saveVariant(ctx, img, "resize", "resized", resizedImage, p.formats, packets("resize"))
 	if err != nil {
 		p.log.Error("failed to save resized image", slog.String("op", op), sl.Err(err))
 		return err
 	}
 	processedPaths["resize"] = resizedPath
 
-	thumbnailImage := imaging.Thumbnail(src, 150, 150, imaging.CatmullRom)
-	thumbnailPath := filepath.Join(outputDir, fmt.Sprintf("%s_thumbnail.jpg", kafkaMessage.ImageID))
-	err = imaging.Save(thumbnailImage, thumbnailPath)
+	thumbnailImage := imaging.Thumbnail(source("thumbnail"), 150, 150, imaging.CatmullRom)
+	thumbnailPath, err := p.saveVariant(ctx, img, "thumbnail", "thumbnail", thumbnailImage, p.formats, packets("thumbnail"))
 	if err != nil {
 		p.log.Error("failed to save thumbnail image", slog.String("op", op), sl.Err(err))
 		return err
@@ -76,15 +195,15 @@ func (p *ImageProcessor) ProcessMessage(ctx context.Context, message []byte) err
 
 	watermark, err := imaging.Open("watermark.png")
 	if err == nil {
-		bounds := src.Bounds()
+		base := source("watermark")
+		bounds := base.Bounds()
 		watermarkBounds := watermark.Bounds()
 
 		x := bounds.Dx()/2 - watermarkBounds.Dx()/2
 		y := bounds.Dy()/2 - watermarkBounds.Dy()/2
 
-		watermarkedImage := imaging.Overlay(src, watermark, image.Pt(x, y), 1.0)
-		watermarkedPath := filepath.Join(outputDir, fmt.Sprintf("%s_watermarked.jpg", kafkaMessage.ImageID))
-		err = imaging.Save(watermarkedImage, watermarkedPath)
+		watermarkedImage := imaging.Overlay(base, watermark, image.Pt(x, y), 1.0)
+		watermarkedPath, err := p.saveVariant(ctx, img, "watermark", "watermarked", watermarkedImage, p.formats, packets("watermark"))
 		if err != nil {
 			p.log.Error("failed to save watermarked image", slog.String("op", op), sl.Err(err))
 			return err
@@ -94,13 +213,156 @@ func (p *ImageProcessor) ProcessMessage(ctx context.Context, message []byte) err
 		p.log.Warn("watermark file not found, skipping watermark processing", slog.String("op", op), sl.Err(err))
 	}
 
-	err = p.storage.UpdateImageStatus(ctx, kafkaMessage.ImageID, "processed", processedPaths)
-	if err != nil {
-		p.log.Error("failed to update image status in storage", slog.String("op", op), slog.String("image_id", kafkaMessage.ImageID.String()), slog.String("error", err.Error()))
+	for _, width := range ladder(p.widths, src.Bounds().Dx()) {
+		name := models.WidthVariant(width)
+		if _, err = p.saveVariant(ctx, img, name, name, imaging.Resize(src, width, 0, imaging.Lanczos), p.formats, packets(name)); err != nil {
+			p.log.Error("failed to save srcset variant", slog.String("op", op), slog.Int("width", width), sl.Err(err))
+			return err
+		}
+	}
+
+	// The published original is the upload encoded anew, in its own
+	// format, upright and without any metadata.
+	if p.sanitizeOriginal {
+		if _, err = p.saveVariant(ctx, img, models.OriginalVariant, "public", src, []imageformat.Format{format}, metadata.Packets{}); err != nil {
+			p.log.Error("failed to save sanitized original", slog.String("op", op), sl.Err(err))
+			return err
+		}
+	}
+
+	// Hashes are taken of the original, as near-duplicates are looked for
+	// among the uploads rather than among their variants.
+	if err = p.storage.SaveImageHashes(ctx, img.ID, imagehash.Compute(src)); err != nil {
+		p.log.Error("failed to save image hashes", slog.String("op", op), slog.String("image_id", img.ID.String()), sl.Err(err))
 		return err
 	}
 
-	p.log.Info("image processed successfully and status updated", slog.String("op", op), slog.String("image_id", kafkaMessage.ImageID.String()))
+	if meta != nil {
+		if err = p.storage.SaveImageMetadata(ctx, img.ID, meta); err != nil {
+			p.log.Error("failed to save image metadata", slog.String("op", op), slog.String("image_id", img.ID.String()), sl.Err(err))
+			return err
+		}
+	}
+
+	err = p.storage.UpdateImageStatus(ctx, img.ID, "processed", processedPaths)
+	if err != nil {
+		p.log.Error("failed to update image status in storage", slog.String("op", op), slog.String("image_id", img.ID.String()), slog.String("error", err.Error()))
+		return err
+	}
 
 	return nil
 }
+
+// notify reports the final state of the image to its owner. A failure is
+// only logged: the image itself is done either way.
+func (p *ImageProcessor) notify(ctx context.Context, id uuid.UUID) {
+	const op = "processor.notify"
+
+	img, err := p.storage.GetImage(ctx, id)
+	if err != nil {
+		p.log.Error("failed to get image to notify about", slog.String("op", op), slog.String("image_id", id.String()), sl.Err(err))
+		return
+	}
+
+	if err = p.notifier.Notify(ctx, img); err != nil {
+		p.log.Error("failed to schedule webhook event", slog.String("op", op), slog.String("image_id", id.String()), sl.Err(err))
+	}
+}
+
+// saveVariant encodes img in each of formats with packets written into it,
+// stores the results in the blob storage and records each of them together
+// with its checksum. It returns the blob key of the default (first) format.
+func (p *ImageProcessor) saveVariant(ctx context.Context, original *models.Image, name, suffix string, img image.Image, formats []imageformat.Format, packets metadata.Packets) (string, error) {
+	const op = "processor.saveVariant"
+
+	var defaultKey string
+
+	for _, format := range formats {
+		var buf bytes.Buffer
+		if err := imageformat.Encode(&buf, img, format); err != nil {
+			return "", fmt.Errorf("%s: %s: %w", op, format.Name, err)
+		}
+
+		key := tenant.BlobKey(original.TenantID, outputDir, fmt.Sprintf("%s_%s.%s", original.ID, suffix, format.Extension))
+
+		info, err := p.blobs.Put(ctx, key, bytes.NewReader(metadata.Embed(buf.Bytes(), packets)))
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", op, err)
+		}
+
+		err = p.storage.SaveVariant(ctx, &models.Variant{
+			ImageID:     original.ID,
+			Name:        name,
+			Format:      format.Name,
+			BlobKey:     info.Key,
+			ContentType: format.ContentType,
+			Size:        info.Size,
+			Checksum:    info.Checksum,
+		})
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", op, err)
+		}
+
+		if defaultKey == "" {
+			defaultKey = info.Key
+		}
+	}
+
+	return defaultKey, nil
+}
+
+// ladder returns the rungs of widths an image sourceWidth pixels across
+// is rendered at. Images are never scaled up: the widths beyond the source's
+// are replaced with the source's own.
+func ladder(widths []int, sourceWidth int) []int {
+	var fit []int
+	for _, width := range widths {
+		if width >= sourceWidth {
+			return append(fit, sourceWidth)
+		}
+		fit = append(fit, width)
+	}
+
+	return fit
+}
+
+// openOriginal decodes the original stored under key, tells its format and
+// reads its metadata. Metadata that can't be read is only logged, and nil is
+// returned for it. Originals of a format that can't be told are taken to be
+// in the default format.
+func (p *ImageProcessor) openOriginal(ctx context.Context, key string) (image.Image, imageformat.Format, *metadata.File, error) {
+	const op = "processor.openOriginal"
+
+	content, _, err := p.blobs.Open(ctx, key)
+	if err != nil {
+		return nil, imageformat.Format{}, nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer content.Close()
+
+	file, err := metadata.Read(content)
+	if err != nil {
+		p.log.Warn("failed to read image metadata", slog.String("op", op), slog.String("path", key), sl.Err(err))
+	}
+
+	if _, err = content.Seek(0, io.SeekStart); err != nil {
+		return nil, imageformat.Format{}, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	header := make([]byte, imageformat.SniffLen)
+	n, _ := io.ReadFull(content, header)
+	format, ok := imageformat.Sniff(header[:n])
+	if !ok {
+		format = p.formats[0]
+	}
+
+	if _, err = content.Seek(0, io.SeekStart); err != nil {
+		return nil, imageformat.Format{}, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	src, err := imaging.Decode(content)
+	if err != nil {
+		return nil, imageformat.Format{}, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return src, format, file, nil
+}
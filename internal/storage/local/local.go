@@ -0,0 +1,189 @@
+package local
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"imageProcessor/internal/config"
+	"imageProcessor/internal/storage"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Storage keeps blobs as plain files under a root directory. Keys are
+// slash-separated paths relative to that root.
+type Storage struct {
+	root string
+}
+
+func New(cfg *config.BlobStorage) (*Storage, error) {
+	const op = "storage.local.New"
+
+	if err := os.MkdirAll(cfg.Root, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Storage{root: cfg.Root}, nil
+}
+
+func (s *Storage) Put(ctx context.Context, key string, r io.Reader) (*storage.BlobInfo, error) {
+	const op = "storage.local.Put"
+
+	path, err := s.path(key)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), r)
+	if err != nil {
+		tmp.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tmp.Close(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &storage.BlobInfo{
+		Key:      key,
+		Size:     size,
+		Checksum: hex.EncodeToString(hash.Sum(nil)),
+		ModTime:  stat.ModTime(),
+	}, nil
+}
+
+func (s *Storage) Open(ctx context.Context, key string) (io.ReadSeekCloser, *storage.BlobInfo, error) {
+	const op = "storage.local.Open"
+
+	path, err := s.path(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil, fmt.Errorf("%s: %w", op, storage.ErrBlobNotFound)
+		}
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if stat.IsDir() {
+		file.Close()
+		return nil, nil, fmt.Errorf("%s: %w", op, storage.ErrBlobNotFound)
+	}
+
+	return file, &storage.BlobInfo{
+		Key:     key,
+		Size:    stat.Size(),
+		ModTime: stat.ModTime(),
+	}, nil
+}
+
+func (s *Storage) Delete(ctx context.Context, key string) error {
+	const op = "storage.local.Delete"
+
+	path, err := s.path(key)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// DeletePrefix removes every blob whose key lies under prefix.
+func (s *Storage) DeletePrefix(ctx context.Context, prefix string) error {
+	const op = "storage.local.DeletePrefix"
+
+	path, err := s.path(prefix)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = os.RemoveAll(path); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// PrefixSize adds up the sizes of the blobs whose keys lie under prefix.
+func (s *Storage) PrefixSize(ctx context.Context, prefix string) (int64, error) {
+	const op = "storage.local.PrefixSize"
+
+	path, err := s.path(prefix)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var size int64
+	err = filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		// Blobs deleted meanwhile, or no blobs at all, don't count.
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err == nil {
+			size += info.Size()
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return size, nil
+}
+
+// path maps a blob key onto the filesystem, refusing keys that would
+// escape the storage root.
+func (s *Storage) path(key string) (string, error) {
+	rel := filepath.FromSlash(key)
+	if !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+
+	return filepath.Join(s.root, rel), nil
+}
@@ -0,0 +1,54 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"imageProcessor/internal/lib/tenant"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// BlobPath is where the API serves the presigned uploads of the local
+// backend. The key of the blob follows it.
+const BlobPath = "/blobs/"
+
+// ParamSize carries the size a presigned upload must have.
+const ParamSize = "size"
+
+type Signer interface {
+	Sign(path string, params url.Values) string
+	ExpiresAt() time.Time
+}
+
+// Presigner hands out signed URLs that PUT a blob of the local backend.
+// Object stores would sign their own URLs; the local backend relies on the
+// API to accept them at BlobPath.
+type Presigner struct {
+	signer    Signer
+	publicURL string
+}
+
+func NewPresigner(signer Signer, publicURL string) *Presigner {
+	return &Presigner{signer: signer, publicURL: publicURL}
+}
+
+// PresignPut returns a URL that stores exactly size bytes under key, and
+// when it stops working.
+func (p *Presigner) PresignPut(ctx context.Context, key string, size int64) (string, time.Time, error) {
+	const op = "storage.local.PresignPut"
+
+	tenantID, err := tenant.FromContext(ctx)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	params := url.Values{
+		tenant.QueryParam: {tenantID},
+		ParamSize:         {strconv.FormatInt(size, 10)},
+	}
+
+	expiresAt := p.signer.ExpiresAt()
+
+	return p.publicURL + p.signer.Sign(BlobPath+key, params), expiresAt, nil
+}
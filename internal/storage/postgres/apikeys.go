@@ -0,0 +1,161 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"imageProcessor/internal/lib/tenant"
+	"imageProcessor/internal/models"
+)
+
+// CreateAPIKey creates a key in the given tenant, which operators may set to
+// a tenant other than their own.
+func (s *Storage) CreateAPIKey(ctx context.Context, tenantID, name, prefix, hash string, scopes []string) (*models.APIKey, error) {
+	const op = "storage.postgres.CreateAPIKey"
+
+	query := `
+        INSERT INTO api_keys (id, tenant_id, name, key_prefix, key_hash, scopes)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING id, tenant_id, name, key_prefix, scopes, created_at`
+
+	var key models.APIKey
+
+	err := s.DB.QueryRowContext(ctx, query, uuid.New(), tenantID, name, prefix, hash, pq.Array(scopes)).Scan(
+		&key.ID,
+		&key.TenantID,
+		&key.Name,
+		&key.Prefix,
+		pq.Array(&key.Scopes),
+		&key.CreatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &key, nil
+}
+
+// EnsureAPIKey creates the key with the given hash unless it already exists.
+// It is used to bootstrap the first admin key from the configuration.
+func (s *Storage) EnsureAPIKey(ctx context.Context, tenantID, name, prefix, hash string, scopes []string) error {
+	const op = "storage.postgres.EnsureAPIKey"
+
+	query := `
+        INSERT INTO api_keys (id, tenant_id, name, key_prefix, key_hash, scopes)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        ON CONFLICT (key_hash) DO NOTHING`
+
+	_, err := s.DB.ExecContext(ctx, query, uuid.New(), tenantID, name, prefix, hash, pq.Array(scopes))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// GetAPIKeyByHash returns the active key with the given hash. Revoked keys
+// are reported as not found. It is the one lookup that is not scoped by
+// tenant, since the key is what tells the tenant of a request.
+func (s *Storage) GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	const op = "storage.postgres.GetAPIKeyByHash"
+
+	query := `
+        SELECT id, tenant_id, name, key_prefix, scopes, created_at, revoked_at
+        FROM api_keys
+        WHERE key_hash = $1 AND revoked_at IS NULL`
+
+	var key models.APIKey
+
+	err := s.DB.QueryRowContext(ctx, query, hash).Scan(
+		&key.ID,
+		&key.TenantID,
+		&key.Name,
+		&key.Prefix,
+		pq.Array(&key.Scopes),
+		&key.CreatedAt,
+		&key.RevokedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%s: api key not found: %w", op, sql.ErrNoRows)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &key, nil
+}
+
+func (s *Storage) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	const op = "storage.postgres.ListAPIKeys"
+
+	tenantID, err := tenant.FromContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := `
+        SELECT id, tenant_id, name, key_prefix, scopes, created_at, revoked_at
+        FROM api_keys
+        WHERE tenant_id = $1
+        ORDER BY created_at`
+
+	rows, err := s.DB.QueryContext(ctx, query, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		var key models.APIKey
+		err = rows.Scan(
+			&key.ID,
+			&key.TenantID,
+			&key.Name,
+			&key.Prefix,
+			pq.Array(&key.Scopes),
+			&key.CreatedAt,
+			&key.RevokedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		keys = append(keys, key)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return keys, nil
+}
+
+func (s *Storage) RevokeAPIKey(ctx context.Context, id uuid.UUID) error {
+	const op = "storage.postgres.RevokeAPIKey"
+
+	tenantID, err := tenant.FromContext(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := `
+        UPDATE api_keys
+        SET revoked_at = NOW()
+        WHERE id = $1 AND tenant_id = $2 AND revoked_at IS NULL`
+
+	result, err := s.DB.ExecContext(ctx, query, id, tenantID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: active api key with ID %s not found: %w", op, id, sql.ErrNoRows)
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/google/uuid"
+	"imageProcessor/internal/lib/tenant"
+	"imageProcessor/internal/models"
+)
+
+func (s *Storage) CreateBatch(ctx context.Context, ownerKeyID *uuid.UUID) (*models.Batch, error) {
+	const op = "storage.postgres.CreateBatch"
+
+	tenantID, err := tenant.FromContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := `
+        INSERT INTO batches (id, tenant_id, owner_key_id)
+        VALUES ($1, $2, $3)
+        RETURNING id, tenant_id, created_at`
+
+	batch := models.Batch{OwnerKeyID: ownerKeyID}
+
+	err = s.DB.QueryRowContext(ctx, query, uuid.New(), tenantID, ownerKeyID).Scan(
+		&batch.ID,
+		&batch.TenantID,
+		&batch.CreatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &batch, nil
+}
+
+// GetBatch returns a batch together with the images of it that still exist.
+func (s *Storage) GetBatch(ctx context.Context, id uuid.UUID) (*models.Batch, []models.BatchImage, error) {
+	const op = "storage.postgres.GetBatch"
+
+	tenantID, err := tenant.FromContext(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := `
+        SELECT id, tenant_id, owner_key_id, created_at
+        FROM batches
+        WHERE id = $1 AND tenant_id = $2`
+
+	var batch models.Batch
+	var ownerKeyID uuid.NullUUID
+
+	err = s.DB.QueryRowContext(ctx, query, id, tenantID).Scan(
+		&batch.ID,
+		&batch.TenantID,
+		&ownerKeyID,
+		&batch.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil, fmt.Errorf("%s: batch with ID %s not found: %w", op, id, sql.ErrNoRows)
+		}
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if ownerKeyID.Valid {
+		batch.OwnerKeyID = &ownerKeyID.UUID
+	}
+
+	query = `
+        SELECT id, filename, status
+        FROM images
+        WHERE batch_id = $1 AND tenant_id = $2
+        ORDER BY created_at, id`
+
+	rows, err := s.DB.QueryContext(ctx, query, id, tenantID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	images := []models.BatchImage{}
+	for rows.Next() {
+		var image models.BatchImage
+		if err = rows.Scan(&image.ID, &image.Filename, &image.Status); err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", op, err)
+		}
+		images = append(images, image)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &batch, images, nil
+}
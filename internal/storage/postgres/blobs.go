@@ -0,0 +1,228 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	"imageProcessor/internal/models"
+	"imageProcessor/internal/storage"
+)
+
+// Blobs are counted by the image and variant rows referring to them, so
+// that identical uploads can share them and they are only deleted along
+// with the last row.
+
+// claimBlob registers key as the tenant's blob holding the content with
+// SHA-256 sum and returns it. If another blob holds that content already,
+// its key is returned instead, locked, and key is not registered.
+func claimBlob(ctx context.Context, tx *sql.Tx, tenantID, key, sum string) (string, error) {
+	for {
+		res, err := tx.ExecContext(ctx, `
+            INSERT INTO blobs (key, tenant_id, sha256)
+            VALUES ($1, $2, $3)
+            ON CONFLICT (tenant_id, sha256) DO NOTHING`, key, tenantID, sum)
+		if err != nil {
+			return "", err
+		}
+
+		n, err := res.RowsAffected()
+		if err != nil {
+			return "", err
+		}
+		if n == 1 {
+			return key, nil
+		}
+
+		var existing string
+		err = tx.QueryRowContext(ctx, `
+            SELECT key
+            FROM blobs
+            WHERE tenant_id = $1 AND sha256 = $2
+            FOR UPDATE`, tenantID, sum).Scan(&existing)
+		// The blob may lose its last reference in between, in which case
+		// key can be registered after all.
+		if err == sql.ErrNoRows {
+			continue
+		}
+
+		return existing, err
+	}
+}
+
+// retainBlobs adds a reference to each of keys.
+func retainBlobs(ctx context.Context, tx *sql.Tx, tenantID string, keys ...string) error {
+	for _, key := range keys {
+		_, err := tx.ExecContext(ctx, `
+            INSERT INTO blobs (key, tenant_id)
+            VALUES ($1, $2)
+            ON CONFLICT (key) DO UPDATE SET refs = blobs.refs + 1`, key, tenantID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// releaseBlobs drops a reference to each of keys and returns the keys that
+// are left without any, which are no longer counted and may be deleted.
+func releaseBlobs(ctx context.Context, tx *sql.Tx, keys []string) ([]string, error) {
+	var unused []string
+
+	for _, key := range keys {
+		var refs int
+		err := tx.QueryRowContext(ctx, `UPDATE blobs SET refs = refs - 1 WHERE key = $1 RETURNING refs`, key).Scan(&refs)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+		if err == nil && refs > 0 {
+			continue
+		}
+
+		if _, err = tx.ExecContext(ctx, `DELETE FROM blobs WHERE key = $1`, key); err != nil {
+			return nil, err
+		}
+		unused = append(unused, key)
+	}
+
+	return unused, nil
+}
+
+// dedupOriginal registers the original stored under key as holding the
+// content with SHA-256 sum and returns key, unless the tenant stores that
+// content already. The key of the blob holding it is returned then, for
+// the new image to share, or a *storage.DuplicateError if the dedup mode
+// has the owner's image of it stand for the upload.
+func (s *Storage) dedupOriginal(ctx context.Context, tx *sql.Tx, tenantID string, ownerKeyID *uuid.UUID, key, sum string) (string, error) {
+	shared, err := claimBlob(ctx, tx, tenantID, key, sum)
+	if err != nil || shared == key {
+		return shared, err
+	}
+
+	if s.dedup != storage.DedupShare {
+		// Only the owner's own images are given away; an upload
+		// duplicating an image of another key shares its blobs instead.
+		query := `
+            SELECT ` + imageColumns + `
+            FROM images
+            WHERE tenant_id = $1 AND original_path = $2 AND owner_key_id IS NOT DISTINCT FROM $3
+              AND status NOT IN ('pending_upload', 'failed')
+            ORDER BY created_at
+            LIMIT 1`
+
+		existing, err := scanImage(tx.QueryRowContext(ctx, query, tenantID, shared, ownerKeyID))
+		if err == nil {
+			return "", &storage.DuplicateError{Image: existing, Rejected: s.dedup == storage.DedupReject}
+		}
+		if err != sql.ErrNoRows {
+			return "", err
+		}
+	}
+
+	if err = retainBlobs(ctx, tx, tenantID, shared); err != nil {
+		return "", err
+	}
+
+	return shared, nil
+}
+
+// shareProcessing gives image, which shares its original with others, the
+// variants of one of them that is processed already, so that it needn't
+// be processed again. It reports whether there was one.
+func shareProcessing(ctx context.Context, tx *sql.Tx, image *models.Image) (bool, error) {
+	var sourceID uuid.UUID
+	var resize, thumbnail, watermark sql.NullString
+
+	err := tx.QueryRowContext(ctx, `
+        SELECT id, processed_path_resize, processed_path_thumbnail, processed_path_watermark
+        FROM images
+        WHERE tenant_id = $1 AND original_path = $2 AND status = 'processed' AND id <> $3
+        ORDER BY updated_at DESC
+        LIMIT 1`, image.TenantID, image.OriginalPath, image.ID).Scan(&sourceID, &resize, &thumbnail, &watermark)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+        INSERT INTO image_variants (image_id, tenant_id, name, format, blob_key, content_type, size, checksum)
+        SELECT $1, tenant_id, name, format, blob_key, content_type, size, checksum
+        FROM image_variants
+        WHERE image_id = $2
+        RETURNING blob_key, size`, image.ID, sourceID)
+	if err != nil {
+		return false, err
+	}
+
+	var keys []string
+	var size int64
+	for rows.Next() {
+		var key string
+		var variantSize int64
+		if err = rows.Scan(&key, &variantSize); err != nil {
+			rows.Close()
+			return false, err
+		}
+		keys = append(keys, key)
+		size += variantSize
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return false, err
+	}
+
+	if err = retainBlobs(ctx, tx, image.TenantID, keys...); err != nil {
+		return false, err
+	}
+
+	if err = copyImageHashes(ctx, tx, image.ID, sourceID); err != nil {
+		return false, err
+	}
+	if err = copyImageMetadata(ctx, tx, image.ID, sourceID); err != nil {
+		return false, err
+	}
+
+	err = tx.QueryRowContext(ctx, `
+        UPDATE images
+        SET status = 'processed', processed_path_resize = $1, processed_path_thumbnail = $2, processed_path_watermark = $3, updated_at = NOW()
+        WHERE id = $4
+        RETURNING status, updated_at`, resize, thumbnail, watermark, image.ID).Scan(&image.Status, &image.UpdatedAt)
+	if err != nil {
+		return false, err
+	}
+
+	if resize.Valid {
+		image.ProcessedPathResize = &resize.String
+	}
+	if thumbnail.Valid {
+		image.ProcessedPathThumbnail = &thumbnail.String
+	}
+	if watermark.Valid {
+		image.ProcessedPathWatermark = &watermark.String
+	}
+
+	ownerKeyID := uuid.NullUUID{}
+	event := models.ImageEvent{
+		Type:     models.ImageEventStatus,
+		TenantID: image.TenantID,
+		ImageID:  image.ID,
+		Status:   image.Status,
+		At:       image.UpdatedAt,
+	}
+	if image.OwnerKeyID != nil {
+		ownerKeyID = uuid.NullUUID{UUID: *image.OwnerKeyID, Valid: true}
+		event.OwnerKeyID = image.OwnerKeyID
+	}
+
+	if err = adjustUsage(ctx, tx, image.TenantID, ownerKeyID, size, 0); err != nil {
+		return false, err
+	}
+
+	if err = notifyImageEvent(ctx, tx, event); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
@@ -0,0 +1,70 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/lib/pq"
+	"imageProcessor/internal/models"
+	"time"
+)
+
+const (
+	imageEventsChannel = "image_events"
+	// listenerPingInterval keeps an idle LISTEN connection from being
+	// dropped unnoticed.
+	listenerPingInterval = 90 * time.Second
+)
+
+// notifyImageEvent announces event to every instance listening. Postgres
+// only delivers it once tx commits, so nobody hears of a change that was
+// rolled back.
+func notifyImageEvent(ctx context.Context, tx *sql.Tx, event models.ImageEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `SELECT pg_notify($1, $2)`, imageEventsChannel, string(payload))
+
+	return err
+}
+
+// ListenImageEvents passes the image events announced by any instance to
+// handle until ctx is done. Events announced while the connection is being
+// re-established are lost.
+func (s *Storage) ListenImageEvents(ctx context.Context, handle func(models.ImageEvent)) error {
+	const op = "storage.postgres.ListenImageEvents"
+
+	listener := pq.NewListener(s.connStr, time.Second, time.Minute, nil)
+	defer listener.Close()
+
+	if err := listener.Listen(imageEventsChannel); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	ticker := time.NewTicker(listenerPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			go listener.Ping()
+		case n := <-listener.Notify:
+			// A nil notification only says that the connection was
+			// re-established.
+			if n == nil {
+				continue
+			}
+
+			var event models.ImageEvent
+			if err := json.Unmarshal([]byte(n.Extra), &event); err != nil {
+				continue
+			}
+			handle(event)
+		}
+	}
+}
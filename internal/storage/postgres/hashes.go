@@ -0,0 +1,157 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"imageProcessor/internal/imagehash"
+	"imageProcessor/internal/lib/tenant"
+	"imageProcessor/internal/models"
+	"imageProcessor/internal/storage"
+)
+
+// SaveImageHashes records the perceptual hashes of an image, replacing
+// those it had.
+func (s *Storage) SaveImageHashes(ctx context.Context, imageID uuid.UUID, hashes imagehash.Hashes) error {
+	const op = "storage.postgres.SaveImageHashes"
+
+	tenantID, err := tenant.FromContext(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	chunks := imagehash.Split(hashes.Perceptual)
+
+	query := `
+        INSERT INTO image_hashes (image_id, tenant_id, ahash, dhash, phash, phash_0, phash_1, phash_2, phash_3)
+        SELECT id, tenant_id, $3, $4, $5, $6, $7, $8, $9
+        FROM images
+        WHERE id = $1 AND tenant_id = $2
+        ON CONFLICT (image_id) DO UPDATE
+        SET ahash = EXCLUDED.ahash, dhash = EXCLUDED.dhash, phash = EXCLUDED.phash,
+            phash_0 = EXCLUDED.phash_0, phash_1 = EXCLUDED.phash_1, phash_2 = EXCLUDED.phash_2, phash_3 = EXCLUDED.phash_3,
+            created_at = NOW()`
+
+	res, err := s.DB.ExecContext(ctx, query, imageID, tenantID,
+		int64(hashes.Average), int64(hashes.Difference), int64(hashes.Perceptual),
+		chunks[0], chunks[1], chunks[2], chunks[3],
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: image with ID %s not found: %w", op, imageID, sql.ErrNoRows)
+	}
+
+	return nil
+}
+
+// FindSimilarImages returns the images of the tenant whose perceptual hash
+// is at most filter.MaxDistance bits from that of the image id, closest
+// first. The image itself is left out, but not other images sharing its
+// original.
+func (s *Storage) FindSimilarImages(ctx context.Context, id uuid.UUID, filter models.SimilarFilter) ([]models.SimilarImage, error) {
+	const op = "storage.postgres.FindSimilarImages"
+
+	tenantID, err := tenant.FromContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var phash int64
+	err = s.DB.QueryRowContext(ctx, `SELECT phash FROM image_hashes WHERE image_id = $1 AND tenant_id = $2`, id, tenantID).Scan(&phash)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%s: %w", op, storage.ErrNotHashed)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Candidates are looked up by the chunk indexes and only they are
+	// compared in full.
+	probes := imagehash.Probes(uint64(phash), filter.MaxDistance)
+	chunks := make([]any, len(probes))
+	for i, values := range probes {
+		ints := make([]int64, len(values))
+		for j, v := range values {
+			ints[j] = int64(v)
+		}
+		chunks[i] = pq.Array(ints)
+	}
+
+	args := []any{tenantID, id, phash, filter.MaxDistance}
+	args = append(args, chunks...)
+
+	owner := ""
+	if filter.OwnerKeyID != nil {
+		args = append(args, *filter.OwnerKeyID)
+		owner = fmt.Sprintf("AND images.owner_key_id = $%d", len(args))
+	}
+
+	query := `
+        SELECT ` + imageColumns + `, similar.distance
+        FROM (
+            SELECT image_id, bit_count((phash # $3)::bit(64)) AS distance
+            FROM image_hashes
+            WHERE tenant_id = $1 AND image_id <> $2
+              AND (phash_0 = ANY($5) OR phash_1 = ANY($6) OR phash_2 = ANY($7) OR phash_3 = ANY($8))
+        ) similar
+        JOIN images ON images.id = similar.image_id
+        WHERE similar.distance <= $4 ` + owner + `
+        ORDER BY similar.distance, images.created_at, images.id`
+
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", filter.Limit)
+	}
+
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	similar := []models.SimilarImage{}
+	for rows.Next() {
+		var distance int
+		image, err := scanImage(distanceScanner{rows, &distance})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		similar = append(similar, models.SimilarImage{Image: *image, Distance: distance})
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return similar, nil
+}
+
+// distanceScanner reads a row of imageColumns followed by a distance.
+type distanceScanner struct {
+	row      rowScanner
+	distance *int
+}
+
+func (d distanceScanner) Scan(dest ...any) error {
+	return d.row.Scan(append(dest, d.distance)...)
+}
+
+// copyImageHashes gives image the perceptual hashes of source, whose
+// original it shares.
+func copyImageHashes(ctx context.Context, tx *sql.Tx, image, source uuid.UUID) error {
+	_, err := tx.ExecContext(ctx, `
+        INSERT INTO image_hashes (image_id, tenant_id, ahash, dhash, phash, phash_0, phash_1, phash_2, phash_3)
+        SELECT $1, tenant_id, ahash, dhash, phash, phash_0, phash_1, phash_2, phash_3
+        FROM image_hashes
+        WHERE image_id = $2
+        ON CONFLICT (image_id) DO NOTHING`, image, source)
+
+	return err
+}
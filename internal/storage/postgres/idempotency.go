@@ -0,0 +1,154 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"imageProcessor/internal/lib/tenant"
+	"imageProcessor/internal/models"
+	"time"
+)
+
+// ClaimIdempotencyKey reserves key for a request of the API key keyID
+// until ttl has passed, and returns nil if it did. If the key is taken, the
+// request it was taken by is returned instead. Keys whose request is still
+// in progress after lockTimeout are taken to have been abandoned and are
+// reserved again.
+func (s *Storage) ClaimIdempotencyKey(ctx context.Context, keyID uuid.UUID, key string, ttl, lockTimeout time.Duration) (*models.IdempotentRequest, error) {
+	const op = "storage.postgres.ClaimIdempotencyKey"
+
+	tenantID, err := tenant.FromContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	claim := `
+        INSERT INTO idempotency_keys (tenant_id, api_key_id, key, expires_at)
+        VALUES ($1, $2, $3, NOW() + make_interval(secs => $4))
+        ON CONFLICT (tenant_id, api_key_id, key) DO UPDATE
+        SET fingerprint = NULL, status_code = NULL, header = NULL, body = NULL, created_at = NOW(), expires_at = EXCLUDED.expires_at
+        WHERE idempotency_keys.expires_at <= NOW()
+           OR idempotency_keys.status_code IS NULL AND idempotency_keys.created_at <= NOW() - make_interval(secs => $5)`
+
+	query := `
+        SELECT key, fingerprint, status_code, header, body, created_at, expires_at
+        FROM idempotency_keys
+        WHERE tenant_id = $1 AND api_key_id = $2 AND key = $3`
+
+	for {
+		res, err := s.DB.ExecContext(ctx, claim, tenantID, keyID, key, ttl.Seconds(), lockTimeout.Seconds())
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		n, err := res.RowsAffected()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if n == 1 {
+			return nil, nil
+		}
+
+		var request models.IdempotentRequest
+		var fingerprint sql.NullString
+		var statusCode sql.NullInt64
+		var header []byte
+
+		err = s.DB.QueryRowContext(ctx, query, tenantID, keyID, key).Scan(
+			&request.Key,
+			&fingerprint,
+			&statusCode,
+			&header,
+			&request.Body,
+			&request.CreatedAt,
+			&request.ExpiresAt,
+		)
+		// The request may have been released in between.
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		if statusCode.Valid {
+			request.Completed = true
+			request.Fingerprint = fingerprint.String
+			request.StatusCode = int(statusCode.Int64)
+			if err = json.Unmarshal(header, &request.Header); err != nil {
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+		}
+
+		return &request, nil
+	}
+}
+
+// SaveIdempotentResponse records the response to the request that claimed
+// key, for it to be replayed.
+func (s *Storage) SaveIdempotentResponse(ctx context.Context, keyID uuid.UUID, request models.IdempotentRequest) error {
+	const op = "storage.postgres.SaveIdempotentResponse"
+
+	tenantID, err := tenant.FromContext(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	header, err := json.Marshal(request.Header)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := `
+        UPDATE idempotency_keys
+        SET fingerprint = $1, status_code = $2, header = $3, body = $4
+        WHERE tenant_id = $5 AND api_key_id = $6 AND key = $7 AND status_code IS NULL`
+
+	_, err = s.DB.ExecContext(ctx, query, request.Fingerprint, request.StatusCode, header, request.Body, tenantID, keyID, request.Key)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ReleaseIdempotencyKey frees a key claimed by a request whose response is
+// not to be replayed, so that the request may be retried.
+func (s *Storage) ReleaseIdempotencyKey(ctx context.Context, keyID uuid.UUID, key string) error {
+	const op = "storage.postgres.ReleaseIdempotencyKey"
+
+	tenantID, err := tenant.FromContext(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := `
+        DELETE FROM idempotency_keys
+        WHERE tenant_id = $1 AND api_key_id = $2 AND key = $3 AND status_code IS NULL`
+
+	if _, err = s.DB.ExecContext(ctx, query, tenantID, keyID, key); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// PruneIdempotencyKeys deletes the keys of every tenant that have expired
+// and returns how many were deleted.
+func (s *Storage) PruneIdempotencyKeys(ctx context.Context) (int64, error) {
+	const op = "storage.postgres.PruneIdempotencyKeys"
+
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at <= NOW()`)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return n, nil
+}
@@ -0,0 +1,212 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"imageProcessor/internal/geo"
+	"imageProcessor/internal/lib/tenant"
+	"imageProcessor/internal/models"
+	"imageProcessor/internal/storage"
+	"strings"
+)
+
+// SaveImageMetadata records the metadata read from the original of an
+// image, replacing what it had.
+func (s *Storage) SaveImageMetadata(ctx context.Context, imageID uuid.UUID, metadata *models.ImageMetadata) error {
+	const op = "storage.postgres.SaveImageMetadata"
+
+	tenantID, err := tenant.FromContext(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var cameraMake, cameraModel, lensModel sql.NullString
+	if metadata.Camera != nil {
+		cameraMake = sql.NullString{String: metadata.Camera.Make, Valid: metadata.Camera.Make != ""}
+		cameraModel = sql.NullString{String: metadata.Camera.Model, Valid: metadata.Camera.Model != ""}
+	}
+	if metadata.Lens != nil {
+		lensModel = sql.NullString{String: metadata.Lens.Model, Valid: metadata.Lens.Model != ""}
+	}
+
+	var orientation sql.NullInt16
+	if metadata.Orientation != 0 {
+		orientation = sql.NullInt16{Int16: int16(metadata.Orientation), Valid: true}
+	}
+
+	var latitude, longitude sql.NullFloat64
+	if metadata.GPS != nil {
+		latitude = sql.NullFloat64{Float64: metadata.GPS.Latitude, Valid: true}
+		longitude = sql.NullFloat64{Float64: metadata.GPS.Longitude, Valid: true}
+	}
+
+	query := `
+        INSERT INTO image_metadata (image_id, tenant_id, taken_at, camera_make, camera_model, lens_model, orientation, latitude, longitude, data)
+        SELECT id, tenant_id, $3, $4, $5, $6, $7, $8, $9, $10
+        FROM images
+        WHERE id = $1 AND tenant_id = $2
+        ON CONFLICT (image_id) DO UPDATE
+        SET taken_at = EXCLUDED.taken_at, camera_make = EXCLUDED.camera_make, camera_model = EXCLUDED.camera_model,
+            lens_model = EXCLUDED.lens_model, orientation = EXCLUDED.orientation,
+            latitude = EXCLUDED.latitude, longitude = EXCLUDED.longitude, data = EXCLUDED.data,
+            extracted_at = NOW()`
+
+	res, err := s.DB.ExecContext(ctx, query, imageID, tenantID, metadata.TakenAt,
+		cameraMake, cameraModel, lensModel, orientation, latitude, longitude, data,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: image with ID %s not found: %w", op, imageID, sql.ErrNoRows)
+	}
+
+	return nil
+}
+
+// GetImageMetadata returns the metadata read from the original of an image.
+func (s *Storage) GetImageMetadata(ctx context.Context, imageID uuid.UUID) (*models.ImageMetadata, error) {
+	const op = "storage.postgres.GetImageMetadata"
+
+	tenantID, err := tenant.FromContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var data []byte
+	metadata := &models.ImageMetadata{}
+	err = s.DB.QueryRowContext(ctx, `
+        SELECT data, extracted_at
+        FROM image_metadata
+        WHERE image_id = $1 AND tenant_id = $2`, imageID, tenantID).Scan(&data, &metadata.ExtractedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%s: %w", op, storage.ErrNoMetadata)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	extractedAt := metadata.ExtractedAt
+	if err = json.Unmarshal(data, metadata); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	// Images sharing an original share its metadata too.
+	metadata.ImageID = imageID
+	metadata.ExtractedAt = extractedAt
+
+	return metadata, nil
+}
+
+// copyImageMetadata gives image the metadata of source, whose original it
+// shares.
+func copyImageMetadata(ctx context.Context, tx *sql.Tx, image, source uuid.UUID) error {
+	_, err := tx.ExecContext(ctx, `
+        INSERT INTO image_metadata (image_id, tenant_id, taken_at, camera_make, camera_model, lens_model, orientation, latitude, longitude, data)
+        SELECT $1, tenant_id, taken_at, camera_make, camera_model, lens_model, orientation, latitude, longitude, data
+        FROM image_metadata
+        WHERE image_id = $2
+        ON CONFLICT (image_id) DO NOTHING`, image, source)
+
+	return err
+}
+
+// FindImagesByLocation returns the images of the tenant taken where filter
+// says, closest to its center first.
+func (s *Storage) FindImagesByLocation(ctx context.Context, filter models.LocationFilter) ([]models.LocatedImage, error) {
+	const op = "storage.postgres.FindImagesByLocation"
+
+	tenantID, err := tenant.FromContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	args := []any{tenantID, filter.Box.MinLat, filter.Box.MaxLat, filter.Box.MinLon, filter.Box.MaxLon, filter.Center.Lat, filter.Center.Lon}
+
+	longitude := "longitude BETWEEN $4 AND $5"
+	if filter.Box.CrossesAntimeridian() {
+		longitude = "(longitude >= $4 OR longitude <= $5)"
+	}
+
+	conditions := []string{"images.tenant_id = $1"}
+	where := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.RadiusKm > 0 {
+		where("located.distance <= $%d", filter.RadiusKm)
+	}
+	if filter.OwnerKeyID != nil {
+		where("images.owner_key_id = $%d", *filter.OwnerKeyID)
+	}
+
+	// The box is looked up by the location index and only the images in it
+	// are measured, by the haversine formula.
+	query := fmt.Sprintf(`
+        SELECT `+imageColumns+`, located.latitude, located.longitude, located.distance
+        FROM (
+            SELECT image_id, latitude, longitude,
+                   2 * %[1]g * asin(sqrt(least(1,
+                       sin(radians(latitude - $6) / 2) ^ 2 +
+                       cos(radians($6)) * cos(radians(latitude)) * sin(radians(longitude - $7) / 2) ^ 2
+                   ))) AS distance
+            FROM image_metadata
+            WHERE tenant_id = $1 AND latitude BETWEEN $2 AND $3 AND %[2]s
+        ) located
+        JOIN images ON images.id = located.image_id
+        WHERE %[3]s
+        ORDER BY located.distance, images.id`, geo.EarthRadiusKm, longitude, strings.Join(conditions, " AND "))
+
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", filter.Limit)
+	}
+	if filter.Offset > 0 {
+		query += fmt.Sprintf(" OFFSET %d", filter.Offset)
+	}
+
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	located := []models.LocatedImage{}
+	for rows.Next() {
+		var l models.LocatedImage
+		image, err := scanImage(locationScanner{rows, &l})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		l.Image = *image
+		located = append(located, l)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return located, nil
+}
+
+// locationScanner reads a row of imageColumns followed by where the image
+// was taken and how far from the center of the search.
+type locationScanner struct {
+	row     rowScanner
+	located *models.LocatedImage
+}
+
+func (l locationScanner) Scan(dest ...any) error {
+	return l.row.Scan(append(dest, &l.located.Latitude, &l.located.Longitude, &l.located.DistanceKm)...)
+}
@@ -5,17 +5,30 @@ import (
 	"database/sql"
 	"fmt"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"imageProcessor/internal/config"
+	"imageProcessor/internal/lib/quota"
+	"imageProcessor/internal/lib/tenant"
 	"imageProcessor/internal/models"
-
-	_ "github.com/lib/pq"
+	"imageProcessor/internal/storage"
+	"strings"
+	"time"
 )
 
+// Storage scopes every query on tenant data to the tenant found in the
+// context and fails when there is none.
 type Storage struct {
-	DB *sql.DB
+	DB      *sql.DB
+	connStr string
+	dedup   storage.DedupMode
 }
 
-func InitDB(dbCfg *config.Database) (*Storage, error) {
+func InitDB(dbCfg *config.Database, dedupCfg *config.Dedup) (*Storage, error) {
+	dedup, err := storage.ParseDedupMode(dedupCfg.Mode)
+	if err != nil {
+		return nil, fmt.Errorf("invalid dedup config: %w", err)
+	}
+
 	connStr := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
 		dbCfg.Host,
 		dbCfg.Port,
@@ -34,26 +47,100 @@ func InitDB(dbCfg *config.Database) (*Storage, error) {
 		return nil, fmt.Errorf("failed to connect to the database: %w", err)
 	}
 
-	return &Storage{DB: db}, nil
+	return &Storage{DB: db, connStr: connStr, dedup: dedup}, nil
 }
 
-func (s *Storage) SaveImage(ctx context.Context, filename string, originalPath string) (*models.Image, error) {
+// SaveImage records an upload and counts its size against the usage of the
+// tenant and of the uploading key. The quota is checked under a lock
+// on the counters, so concurrent uploads cannot overshoot it together.
+//
+// An original with a known SHA-256 is deduplicated against the tenant's
+// others as the dedup mode says: the image may share the blobs of an
+// identical one, taking its variants if it is processed, or a
+// *storage.DuplicateError is returned.
+func (s *Storage) SaveImage(ctx context.Context, upload models.Upload, limits quota.Set) (*models.Image, error) {
 	const op = "storage.postgres.SaveImage"
 
+	tenantID, err := tenant.FromContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	// The tenant's counters are always locked first to keep the lock order
+	// the same for every upload.
+	scopes := []usageScope{{keyID: tenantScope, limits: limits.Tenant}}
+	if upload.OwnerKeyID != nil {
+		scopes = append(scopes, usageScope{keyID: *upload.OwnerKeyID, limits: limits.Key})
+	}
+
+	checks := make([]quota.Scope, 0, len(scopes))
+	var now time.Time
+	for _, scope := range scopes {
+		var usage models.Usage
+		usage, now, err = lockUsage(ctx, tx, tenantID, scope.keyID)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		checks = append(checks, quota.Scope{Limits: scope.limits, Usage: usage})
+	}
+
+	// Duplicates are looked for before the quota is checked, as an existing
+	// image returned for the upload doesn't count against it. A presigned
+	// original is only deduplicated once it has been sent and its declared
+	// checksum verified.
+	originalPath := upload.OriginalPath
+	if upload.SHA256 == "" || upload.ExpiresAt != nil {
+		err = retainBlobs(ctx, tx, tenantID, originalPath)
+	} else {
+		originalPath, err = s.dedupOriginal(ctx, tx, tenantID, upload.OwnerKeyID, originalPath, upload.SHA256)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, check := range checks {
+		if err = quota.Check([]quota.Scope{check}, upload.Size, now); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
 	imageID := uuid.New()
 
 	query := `
-        INSERT INTO images (id, filename, status, original_path)
-        VALUES ($1, $2, $3, $4)
-        RETURNING id, filename, status, original_path, created_at, updated_at`
+        INSERT INTO images (id, tenant_id, filename, status, original_path, size, owner_key_id, callback_url, batch_id, sha256, upload_expires_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+        RETURNING id, tenant_id, filename, status, original_path, size, created_at, updated_at`
+
+	image := models.Image{OwnerKeyID: upload.OwnerKeyID, BatchID: upload.BatchID, UploadExpiresAt: upload.ExpiresAt}
 
-	var image models.Image
+	callback := sql.NullString{String: upload.CallbackURL, Valid: upload.CallbackURL != ""}
+	if callback.Valid {
+		image.CallbackURL = &callback.String
+	}
+
+	checksum := sql.NullString{String: upload.SHA256, Valid: upload.SHA256 != ""}
+	if checksum.Valid {
+		image.SHA256 = &checksum.String
+	}
 
-	err := s.DB.QueryRowContext(ctx, query, imageID, filename, "pending", originalPath).Scan(
+	status := "pending"
+	if upload.ExpiresAt != nil {
+		status = "pending_upload"
+	}
+
+	err = tx.QueryRowContext(ctx, query, imageID, tenantID, upload.Filename, status, originalPath, upload.Size, upload.OwnerKeyID, callback, upload.BatchID, checksum, upload.ExpiresAt).Scan(
 		&image.ID,
+		&image.TenantID,
 		&image.Filename,
 		&image.Status,
 		&image.OriginalPath,
+		&image.Size,
 		&image.CreatedAt,
 		&image.UpdatedAt,
 	)
@@ -61,40 +148,169 @@ func (s *Storage) SaveImage(ctx context.Context, filename string, originalPath s
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
+	for _, scope := range scopes {
+		if err = addUpload(ctx, tx, tenantID, scope.keyID, upload.Size); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if originalPath != upload.OriginalPath {
+		if _, err = shareProcessing(ctx, tx, &image); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
 	return &image, nil
 }
 
 func (s *Storage) GetImage(ctx context.Context, id uuid.UUID) (*models.Image, error) {
 	const op = "storage.postgres.GetImage"
 
+	tenantID, err := tenant.FromContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := `
+        SELECT ` + imageColumns + `
+        FROM images
+        WHERE id = $1 AND tenant_id = $2`
+
+	image, err := scanImage(s.DB.QueryRowContext(ctx, query, id, tenantID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%s: image with ID %s not found: %w", op, id, sql.ErrNoRows)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return image, nil
+}
+
+// ListImages returns the images of the tenant matching filter, oldest first.
+func (s *Storage) ListImages(ctx context.Context, filter models.ImageFilter) ([]models.Image, error) {
+	const op = "storage.postgres.ListImages"
+
+	tenantID, err := tenant.FromContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	conditions := []string{"tenant_id = $1"}
+	args := []any{tenantID}
+
+	where := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.IDs != nil {
+		where("id = ANY($%d)", pq.Array(filter.IDs))
+	}
+	if filter.BatchID != nil {
+		where("batch_id = $%d", *filter.BatchID)
+	}
+	if filter.Status != "" {
+		where("status = $%d", filter.Status)
+	}
+	if filter.OwnerKeyID != nil {
+		where("owner_key_id = $%d", *filter.OwnerKeyID)
+	}
+	if filter.CreatedAfter != nil {
+		where("created_at >= $%d", *filter.CreatedAfter)
+	}
+	if filter.CreatedBefore != nil {
+		where("created_at < $%d", *filter.CreatedBefore)
+	}
+	// Metadata is matched by the indexed columns of image_metadata;
+	// images without metadata match none of these.
+	if filter.TakenAfter != nil {
+		where("id IN (SELECT image_id FROM image_metadata WHERE tenant_id = $1 AND taken_at >= $%d)", *filter.TakenAfter)
+	}
+	if filter.TakenBefore != nil {
+		where("id IN (SELECT image_id FROM image_metadata WHERE tenant_id = $1 AND taken_at < $%d)", *filter.TakenBefore)
+	}
+	if filter.CameraMake != "" {
+		where("id IN (SELECT image_id FROM image_metadata WHERE tenant_id = $1 AND lower(camera_make) = lower($%d))", filter.CameraMake)
+	}
+	if filter.CameraModel != "" {
+		where("id IN (SELECT image_id FROM image_metadata WHERE tenant_id = $1 AND lower(camera_model) = lower($%d))", filter.CameraModel)
+	}
+
 	query := `
-        SELECT id, filename, status, original_path, processed_path_resize, processed_path_thumbnail, processed_path_watermark, created_at, updated_at
+        SELECT ` + imageColumns + `
         FROM images
-        WHERE id = $1`
+        WHERE ` + strings.Join(conditions, " AND ") + `
+        ORDER BY created_at, id`
+
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", filter.Limit)
+	}
+
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
 
+	images := []models.Image{}
+	for rows.Next() {
+		image, err := scanImage(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		images = append(images, *image)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return images, nil
+}
+
+const imageColumns = `id, tenant_id, filename, status, original_path, size, processed_path_resize, processed_path_thumbnail, processed_path_watermark, owner_key_id, callback_url, batch_id, sha256, upload_expires_at, created_at, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanImage reads a row of imageColumns.
+func scanImage(row rowScanner) (*models.Image, error) {
 	var processedPathResize sql.NullString
 	var processedPathThumbnail sql.NullString
 	var processedPathWatermark sql.NullString
+	var ownerKeyID uuid.NullUUID
+	var callbackURL sql.NullString
+	var batchID uuid.NullUUID
+	var sha256 sql.NullString
+	var uploadExpiresAt sql.NullTime
 
 	image := &models.Image{}
 
-	err := s.DB.QueryRowContext(ctx, query, id).Scan(
+	err := row.Scan(
 		&image.ID,
+		&image.TenantID,
 		&image.Filename,
 		&image.Status,
 		&image.OriginalPath,
+		&image.Size,
 		&processedPathResize,
 		&processedPathThumbnail,
 		&processedPathWatermark,
+		&ownerKeyID,
+		&callbackURL,
+		&batchID,
+		&sha256,
+		&uploadExpiresAt,
 		&image.CreatedAt,
 		&image.UpdatedAt,
 	)
-
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("%s: image with ID %s not found: %w", op, id, sql.ErrNoRows)
-		}
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return nil, err
 	}
 
 	if processedPathResize.Valid {
@@ -106,6 +322,21 @@ func (s *Storage) GetImage(ctx context.Context, id uuid.UUID) (*models.Image, er
 	if processedPathWatermark.Valid {
 		image.ProcessedPathWatermark = &processedPathWatermark.String
 	}
+	if ownerKeyID.Valid {
+		image.OwnerKeyID = &ownerKeyID.UUID
+	}
+	if callbackURL.Valid {
+		image.CallbackURL = &callbackURL.String
+	}
+	if batchID.Valid {
+		image.BatchID = &batchID.UUID
+	}
+	if sha256.Valid {
+		image.SHA256 = &sha256.String
+	}
+	if uploadExpiresAt.Valid {
+		image.UploadExpiresAt = &uploadExpiresAt.Time
+	}
 
 	return image, nil
 }
@@ -113,46 +344,331 @@ func (s *Storage) GetImage(ctx context.Context, id uuid.UUID) (*models.Image, er
 func (s *Storage) UpdateImageStatus(ctx context.Context, id uuid.UUID, status string, processedPaths map[string]string) error {
 	const op = "storage.postgres.UpdateImageStatus"
 
+	tenantID, err := tenant.FromContext(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
 	query := `
         UPDATE images
         SET status = $1, processed_path_resize = $2, processed_path_thumbnail = $3, processed_path_watermark = $4, updated_at = NOW()
-        WHERE id = $5`
+        WHERE id = $5 AND tenant_id = $6
+        RETURNING owner_key_id, updated_at`
 
 	resizePath := sql.NullString{String: processedPaths["resize"], Valid: processedPaths["resize"] != ""}
 	thumbnailPath := sql.NullString{String: processedPaths["thumbnail"], Valid: processedPaths["thumbnail"] != ""}
 	watermarkPath := sql.NullString{String: processedPaths["watermark"], Valid: processedPaths["watermark"] != ""}
 
-	_, err := s.DB.ExecContext(ctx, query, status, resizePath, thumbnailPath, watermarkPath, id)
+	var ownerKeyID uuid.NullUUID
+	var updatedAt time.Time
+
+	err = tx.QueryRowContext(ctx, query, status, resizePath, thumbnailPath, watermarkPath, id, tenantID).Scan(&ownerKeyID, &updatedAt)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("%s: image with ID %s not found: %w", op, id, sql.ErrNoRows)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	event := models.ImageEvent{
+		Type:     models.ImageEventStatus,
+		TenantID: tenantID,
+		ImageID:  id,
+		Status:   status,
+		At:       updatedAt,
+	}
+	if ownerKeyID.Valid {
+		event.OwnerKeyID = &ownerKeyID.UUID
+	}
+
+	if err = notifyImageEvent(ctx, tx, event); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
 	return nil
 }
 
-func (s *Storage) DeleteImage(ctx context.Context, id uuid.UUID) error {
+// DeleteImage removes the image with its variants, releases their bytes
+// from the usage counters and returns the keys of the blobs that are no
+// longer referenced.
+func (s *Storage) DeleteImage(ctx context.Context, id uuid.UUID) ([]string, error) {
 	const op = "storage.postgres.DeleteImage"
 
+	tenantID, err := tenant.FromContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	query := `
+        SELECT original_path, size, owner_key_id
+        FROM images
+        WHERE id = $1 AND tenant_id = $2
+        FOR UPDATE`
+
+	var originalPath string
+	var size int64
+	var ownerKeyID uuid.NullUUID
+
+	err = tx.QueryRowContext(ctx, query, id, tenantID).Scan(&originalPath, &size, &ownerKeyID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%s: image with ID %s not found: %w", op, id, sql.ErrNoRows)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	keys := []string{originalPath}
+
+	rows, err := tx.QueryContext(ctx, `
+        DELETE FROM image_variants
+        WHERE image_id = $1
+        RETURNING blob_key, size`, id)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	for rows.Next() {
+		var key string
+		var variantSize int64
+		if err = rows.Scan(&key, &variantSize); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		keys = append(keys, key)
+		size += variantSize
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err = tx.ExecContext(ctx, `DELETE FROM images WHERE id = $1`, id); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = adjustUsage(ctx, tx, tenantID, ownerKeyID, -size, -1); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if keys, err = releaseBlobs(ctx, tx, keys); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return keys, nil
+}
+
+// SaveVariant stores or replaces a variant and counts its bytes against the
+// usage of the image's tenant and owner.
+func (s *Storage) SaveVariant(ctx context.Context, variant *models.Variant) error {
+	const op = "storage.postgres.SaveVariant"
+
+	tenantID, err := tenant.FromContext(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	// The variant is only written if its image belongs to the same tenant.
 	query := `
-        DELETE FROM images
-        WHERE id = $1`
+        SELECT i.owner_key_id, COALESCE(v.size, 0), v.blob_key
+        FROM images i
+                 LEFT JOIN image_variants v ON v.image_id = i.id AND v.name = $3 AND v.format = $4
+        WHERE i.id = $1 AND i.tenant_id = $2
+        FOR UPDATE OF i`
 
-	result, err := s.DB.ExecContext(ctx, query, id)
+	var ownerKeyID uuid.NullUUID
+	var previousSize int64
+	var previousKey sql.NullString
+
+	err = tx.QueryRowContext(ctx, query, variant.ImageID, tenantID, variant.Name, variant.Format).Scan(&ownerKeyID, &previousSize, &previousKey)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("%s: image with ID %s not found: %w", op, variant.ImageID, sql.ErrNoRows)
+		}
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	query = `
+        INSERT INTO image_variants (image_id, tenant_id, name, format, blob_key, content_type, size, checksum)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        ON CONFLICT (image_id, name, format) DO UPDATE
+        SET blob_key = EXCLUDED.blob_key, content_type = EXCLUDED.content_type, size = EXCLUDED.size, checksum = EXCLUDED.checksum, created_at = NOW()`
+
+	_, err = tx.ExecContext(ctx, query,
+		variant.ImageID,
+		tenantID,
+		variant.Name,
+		variant.Format,
+		variant.BlobKey,
+		variant.ContentType,
+		variant.Size,
+		variant.Checksum,
+	)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
-	if rowsAffected == 0 {
-		return fmt.Errorf("%s: image with ID %s not found", op, id)
+
+	// Variants are written under keys of their own image, so a key is only
+	// replaced when the image had shared the variants of another.
+	if previousKey.String != variant.BlobKey {
+		if err = retainBlobs(ctx, tx, tenantID, variant.BlobKey); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if previousKey.Valid {
+			if _, err = releaseBlobs(ctx, tx, []string{previousKey.String}); err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+		}
+	}
+
+	if err = adjustUsage(ctx, tx, tenantID, ownerKeyID, variant.Size-previousSize, 0); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	event := models.ImageEvent{
+		Type:     models.ImageEventVariant,
+		TenantID: tenantID,
+		ImageID:  variant.ImageID,
+		Variant:  variant.Name,
+		Format:   variant.Format,
+		At:       time.Now(),
+	}
+	if ownerKeyID.Valid {
+		event.OwnerKeyID = &ownerKeyID.UUID
+	}
+
+	if err = notifyImageEvent(ctx, tx, event); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
 	}
 
 	return nil
 }
 
+// GetVariants returns every stored format of the named variant, in the order
+// the formats were produced.
+func (s *Storage) GetVariants(ctx context.Context, imageID uuid.UUID, name string) ([]models.Variant, error) {
+	const op = "storage.postgres.GetVariants"
+
+	tenantID, err := tenant.FromContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := `
+        SELECT image_id, name, format, blob_key, content_type, size, checksum, created_at
+        FROM image_variants
+        WHERE image_id = $1 AND name = $2 AND tenant_id = $3
+        ORDER BY created_at, format`
+
+	rows, err := s.DB.QueryContext(ctx, query, imageID, name, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var variants []models.Variant
+	for rows.Next() {
+		var variant models.Variant
+		err = rows.Scan(
+			&variant.ImageID,
+			&variant.Name,
+			&variant.Format,
+			&variant.BlobKey,
+			&variant.ContentType,
+			&variant.Size,
+			&variant.Checksum,
+			&variant.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		variants = append(variants, variant)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if len(variants) == 0 {
+		return nil, fmt.Errorf("%s: variant %s of image %s not found: %w", op, name, imageID, sql.ErrNoRows)
+	}
+
+	return variants, nil
+}
+
+// ListVariants returns every stored variant of the given images.
+func (s *Storage) ListVariants(ctx context.Context, imageIDs []uuid.UUID) ([]models.Variant, error) {
+	const op = "storage.postgres.ListVariants"
+
+	tenantID, err := tenant.FromContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := `
+        SELECT image_id, name, format, blob_key, content_type, size, checksum, created_at
+        FROM image_variants
+        WHERE image_id = ANY($1) AND tenant_id = $2
+        ORDER BY image_id, name, format`
+
+	rows, err := s.DB.QueryContext(ctx, query, pq.Array(imageIDs), tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	variants := []models.Variant{}
+	for rows.Next() {
+		var variant models.Variant
+		err = rows.Scan(
+			&variant.ImageID,
+			&variant.Name,
+			&variant.Format,
+			&variant.BlobKey,
+			&variant.ContentType,
+			&variant.Size,
+			&variant.Checksum,
+			&variant.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		variants = append(variants, variant)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return variants, nil
+}
+
 func (s *Storage) Close() error {
 	return s.DB.Close()
 }
@@ -0,0 +1,173 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"imageProcessor/internal/lib/tenant"
+	"imageProcessor/internal/models"
+	"imageProcessor/internal/storage"
+)
+
+// CompletePendingUpload turns a presigned upload whose original has been
+// verified into an image waiting for processing, deduplicated by its
+// declared SHA-256 as SaveImage would. It fails with
+// storage.ErrNotPendingUpload if the upload was completed already, and
+// with a *storage.DuplicateError, having removed the image, if an image of
+// the owner stands for it.
+func (s *Storage) CompletePendingUpload(ctx context.Context, id uuid.UUID) (*models.Image, error) {
+	const op = "storage.postgres.CompletePendingUpload"
+
+	tenantID, err := tenant.FromContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	query := `
+        SELECT original_path, size, owner_key_id, sha256
+        FROM images
+        WHERE id = $1 AND tenant_id = $2 AND status = 'pending_upload'
+        FOR UPDATE`
+
+	var originalPath, sum string
+	var size int64
+	var ownerKeyID uuid.NullUUID
+
+	err = tx.QueryRowContext(ctx, query, id, tenantID).Scan(&originalPath, &size, &ownerKeyID, &sum)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%s: %w", op, storage.ErrNotPendingUpload)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var owner *uuid.UUID
+	if ownerKeyID.Valid {
+		owner = &ownerKeyID.UUID
+	}
+
+	// The original was counted when the upload was presigned, before its
+	// content was known, and is claimed again now that it is.
+	if _, err = tx.ExecContext(ctx, `DELETE FROM blobs WHERE key = $1`, originalPath); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	sharedPath, err := s.dedupOriginal(ctx, tx, tenantID, owner, originalPath, sum)
+	var duplicate *storage.DuplicateError
+	if errors.As(err, &duplicate) {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM images WHERE id = $1`, id); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if err := adjustUsage(ctx, tx, tenantID, ownerKeyID, -size, -1); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		return nil, fmt.Errorf("%s: %w", op, duplicate)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	query = `
+        UPDATE images
+        SET status = 'pending', original_path = $1, upload_expires_at = NULL, updated_at = NOW()
+        WHERE id = $2
+        RETURNING ` + imageColumns
+
+	image, err := scanImage(tx.QueryRowContext(ctx, query, sharedPath, id))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if sharedPath != originalPath {
+		if _, err = shareProcessing(ctx, tx, image); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return image, nil
+}
+
+// DeleteExpiredPendingUploads removes up to limit presigned uploads of any
+// tenant that were not completed in time, releases the quota they reserved
+// and returns the keys their originals were to be stored under that are no
+// longer referenced.
+func (s *Storage) DeleteExpiredPendingUploads(ctx context.Context, limit int) ([]string, error) {
+	const op = "storage.postgres.DeleteExpiredPendingUploads"
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	query := `
+        DELETE FROM images
+        WHERE id IN (
+            SELECT id
+            FROM images
+            WHERE status = 'pending_upload' AND upload_expires_at <= NOW()
+            ORDER BY upload_expires_at
+            LIMIT $1
+            FOR UPDATE SKIP LOCKED
+        )
+        RETURNING tenant_id, original_path, size, owner_key_id`
+
+	rows, err := tx.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	type expired struct {
+		tenantID   string
+		size       int64
+		ownerKeyID uuid.NullUUID
+	}
+
+	var keys []string
+	var uploads []expired
+	for rows.Next() {
+		var key string
+		var upload expired
+		if err = rows.Scan(&upload.tenantID, &key, &upload.size, &upload.ownerKeyID); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		keys = append(keys, key)
+		uploads = append(uploads, upload)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, upload := range uploads {
+		if err = adjustUsage(ctx, tx, upload.tenantID, upload.ownerKeyID, -upload.size, -1); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if keys, err = releaseBlobs(ctx, tx, keys); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,66 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"imageProcessor/internal/lib/ratelimit"
+	"time"
+)
+
+// Allow takes a token from the bucket stored under key. The row stays locked
+// until the token is taken, so replicas sharing the database draw from one
+// bucket, and their clocks are replaced by the database's.
+func (s *Storage) Allow(ctx context.Context, key string, rule ratelimit.Rule) (ratelimit.Result, error) {
+	const op = "storage.postgres.Allow"
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return ratelimit.Result{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	query := `
+        INSERT INTO rate_limit_buckets (key, tokens, updated_at)
+        VALUES ($1, $2, NOW())
+        ON CONFLICT (key) DO UPDATE SET key = EXCLUDED.key
+        RETURNING tokens, updated_at, NOW()`
+
+	var b ratelimit.Bucket
+	var now time.Time
+
+	err = tx.QueryRowContext(ctx, query, key, rule.Limit).Scan(&b.Tokens, &b.UpdatedAt, &now)
+	if err != nil {
+		return ratelimit.Result{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	b, res := ratelimit.Take(b, rule, now)
+
+	_, err = tx.ExecContext(ctx, `UPDATE rate_limit_buckets SET tokens = $2, updated_at = $3 WHERE key = $1`, key, b.Tokens, b.UpdatedAt)
+	if err != nil {
+		return ratelimit.Result{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return ratelimit.Result{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return res, nil
+}
+
+// PruneRateLimits deletes the buckets nobody has drawn from for idle and
+// returns how many were deleted.
+func (s *Storage) PruneRateLimits(ctx context.Context, idle time.Duration) (int64, error) {
+	const op = "storage.postgres.PruneRateLimits"
+
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM rate_limit_buckets WHERE updated_at < NOW() - make_interval(secs => $1)`, idle.Seconds())
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return n, nil
+}
@@ -0,0 +1,240 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"imageProcessor/internal/lib/tenant"
+	"imageProcessor/internal/models"
+	"imageProcessor/internal/storage"
+	"time"
+)
+
+const tusUploadColumns = `id, tenant_id, owner_key_id, length, upload_offset, metadata, filename, callback_url, chunks, image_id, expires_at, created_at`
+
+func scanTusUpload(row rowScanner) (*models.TusUpload, error) {
+	var upload models.TusUpload
+	var ownerKeyID, imageID uuid.NullUUID
+
+	err := row.Scan(
+		&upload.ID,
+		&upload.TenantID,
+		&ownerKeyID,
+		&upload.Length,
+		&upload.Offset,
+		&upload.Metadata,
+		&upload.Filename,
+		&upload.CallbackURL,
+		pq.Array(&upload.Chunks),
+		&imageID,
+		&upload.ExpiresAt,
+		&upload.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if ownerKeyID.Valid {
+		upload.OwnerKeyID = &ownerKeyID.UUID
+	}
+	if imageID.Valid {
+		upload.ImageID = &imageID.UUID
+	}
+
+	return &upload, nil
+}
+
+func (s *Storage) CreateTusUpload(ctx context.Context, upload models.TusUpload) (*models.TusUpload, error) {
+	const op = "storage.postgres.CreateTusUpload"
+
+	tenantID, err := tenant.FromContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := `
+        INSERT INTO tus_uploads (id, tenant_id, owner_key_id, length, metadata, filename, callback_url, expires_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        RETURNING ` + tusUploadColumns
+
+	created, err := scanTusUpload(s.DB.QueryRowContext(ctx, query,
+		uuid.New(),
+		tenantID,
+		upload.OwnerKeyID,
+		upload.Length,
+		upload.Metadata,
+		upload.Filename,
+		upload.CallbackURL,
+		upload.ExpiresAt,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return created, nil
+}
+
+// GetTusUpload returns an upload that hasn't expired yet.
+func (s *Storage) GetTusUpload(ctx context.Context, id uuid.UUID) (*models.TusUpload, error) {
+	const op = "storage.postgres.GetTusUpload"
+
+	tenantID, err := tenant.FromContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := `
+        SELECT ` + tusUploadColumns + `
+        FROM tus_uploads
+        WHERE id = $1 AND tenant_id = $2 AND expires_at > NOW()`
+
+	upload, err := scanTusUpload(s.DB.QueryRowContext(ctx, query, id, tenantID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s: upload with ID %s not found: %w", op, id, sql.ErrNoRows)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return upload, nil
+}
+
+// AppendTusChunk records a chunk of size bytes received at offset and
+// extends the upload's expiry. It fails with storage.ErrOffsetMismatch if
+// another chunk got there first.
+func (s *Storage) AppendTusChunk(ctx context.Context, id uuid.UUID, offset int64, chunk string, size int64, expiresAt time.Time) (*models.TusUpload, error) {
+	const op = "storage.postgres.AppendTusChunk"
+
+	tenantID, err := tenant.FromContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := `
+        UPDATE tus_uploads
+        SET upload_offset = upload_offset + $4, chunks = array_append(chunks, $3), expires_at = $5
+        WHERE id = $1 AND tenant_id = $6 AND upload_offset = $2 AND image_id IS NULL AND expires_at > NOW()
+        RETURNING ` + tusUploadColumns
+
+	upload, err := scanTusUpload(s.DB.QueryRowContext(ctx, query, id, offset, chunk, size, expiresAt, tenantID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, storage.ErrOffsetMismatch)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return upload, nil
+}
+
+// RevertTusChunk undoes AppendTusChunk for a last chunk that could not be
+// turned into an image, so that the client may send it again.
+func (s *Storage) RevertTusChunk(ctx context.Context, id uuid.UUID, chunk string, size int64) error {
+	const op = "storage.postgres.RevertTusChunk"
+
+	tenantID, err := tenant.FromContext(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := `
+        UPDATE tus_uploads
+        SET upload_offset = upload_offset - $3, chunks = array_remove(chunks, $2)
+        WHERE id = $1 AND tenant_id = $4 AND $2 = ANY(chunks) AND image_id IS NULL`
+
+	if _, err = s.DB.ExecContext(ctx, query, id, chunk, size, tenantID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// CompleteTusUpload links an upload to the image made of it. The chunks are
+// forgotten, the caller removes their blobs.
+func (s *Storage) CompleteTusUpload(ctx context.Context, id uuid.UUID, imageID uuid.UUID) error {
+	const op = "storage.postgres.CompleteTusUpload"
+
+	tenantID, err := tenant.FromContext(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := `
+        UPDATE tus_uploads
+        SET image_id = $2, chunks = '{}'
+        WHERE id = $1 AND tenant_id = $3`
+
+	if _, err = s.DB.ExecContext(ctx, query, id, imageID, tenantID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// DeleteTusUpload removes an upload and returns it, so that the caller can
+// remove its chunks.
+func (s *Storage) DeleteTusUpload(ctx context.Context, id uuid.UUID) (*models.TusUpload, error) {
+	const op = "storage.postgres.DeleteTusUpload"
+
+	tenantID, err := tenant.FromContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := `
+        DELETE FROM tus_uploads
+        WHERE id = $1 AND tenant_id = $2
+        RETURNING ` + tusUploadColumns
+
+	upload, err := scanTusUpload(s.DB.QueryRowContext(ctx, query, id, tenantID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s: upload with ID %s not found: %w", op, id, sql.ErrNoRows)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return upload, nil
+}
+
+// DeleteExpiredTusUploads removes up to limit expired uploads of any tenant
+// and returns them, so that the caller can remove their chunks.
+func (s *Storage) DeleteExpiredTusUploads(ctx context.Context, limit int) ([]models.TusUpload, error) {
+	const op = "storage.postgres.DeleteExpiredTusUploads"
+
+	query := `
+        DELETE FROM tus_uploads
+        WHERE id IN (
+            SELECT id
+            FROM tus_uploads
+            WHERE expires_at <= NOW()
+            ORDER BY expires_at
+            LIMIT $1
+            FOR UPDATE SKIP LOCKED
+        )
+        RETURNING ` + tusUploadColumns
+
+	rows, err := s.DB.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var uploads []models.TusUpload
+	for rows.Next() {
+		upload, err := scanTusUpload(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		uploads = append(uploads, *upload)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return uploads, nil
+}
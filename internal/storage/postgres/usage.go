@@ -0,0 +1,133 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/google/uuid"
+	"imageProcessor/internal/lib/quota"
+	"imageProcessor/internal/lib/tenant"
+	"imageProcessor/internal/models"
+	"time"
+)
+
+// tenantScope is the api_key_id of the counters that cover a whole tenant.
+var tenantScope = uuid.Nil
+
+type usageScope struct {
+	keyID  uuid.UUID
+	limits quota.Limits
+}
+
+// GetUsage returns the usage of the tenant in the context and, if keyID is
+// set, of that key.
+func (s *Storage) GetUsage(ctx context.Context, keyID *uuid.UUID) (tenantUsage models.Usage, keyUsage *models.Usage, err error) {
+	const op = "storage.postgres.GetUsage"
+
+	tenantID, err := tenant.FromContext(ctx)
+	if err != nil {
+		return models.Usage{}, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tenantUsage, err = getUsage(ctx, s.DB, tenantID, tenantScope)
+	if err != nil {
+		return models.Usage{}, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if keyID != nil {
+		usage, err := getUsage(ctx, s.DB, tenantID, *keyID)
+		if err != nil {
+			return models.Usage{}, nil, fmt.Errorf("%s: %w", op, err)
+		}
+		keyUsage = &usage
+	}
+
+	return tenantUsage, keyUsage, nil
+}
+
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func getUsage(ctx context.Context, q queryer, tenantID string, keyID uuid.UUID) (models.Usage, error) {
+	query := `
+        SELECT bytes, images, window_start, window_uploads
+        FROM usage_counters
+        WHERE tenant_id = $1 AND api_key_id = $2`
+
+	var usage models.Usage
+
+	err := q.QueryRowContext(ctx, query, tenantID, keyID).Scan(
+		&usage.Bytes,
+		&usage.Images,
+		&usage.WindowStart,
+		&usage.WindowUploads,
+	)
+	if err == sql.ErrNoRows {
+		return models.Usage{}, nil
+	}
+
+	return usage, err
+}
+
+// lockUsage creates the counters if needed and locks them until the end of
+// tx, so that concurrent uploads of one tenant are checked one at a time. It
+// also returns the database time the counters are to be compared against.
+func lockUsage(ctx context.Context, tx *sql.Tx, tenantID string, keyID uuid.UUID) (models.Usage, time.Time, error) {
+	query := `
+        INSERT INTO usage_counters (tenant_id, api_key_id)
+        VALUES ($1, $2)
+        ON CONFLICT (tenant_id, api_key_id) DO UPDATE SET tenant_id = EXCLUDED.tenant_id
+        RETURNING bytes, images, window_start, window_uploads, NOW()`
+
+	var usage models.Usage
+	var now time.Time
+
+	err := tx.QueryRowContext(ctx, query, tenantID, keyID).Scan(
+		&usage.Bytes,
+		&usage.Images,
+		&usage.WindowStart,
+		&usage.WindowUploads,
+		&now,
+	)
+
+	return usage, now, err
+}
+
+// addUpload counts an upload of size bytes, starting a new rate window if
+// the current one is over.
+func addUpload(ctx context.Context, tx *sql.Tx, tenantID string, keyID uuid.UUID, size int64) error {
+	query := `
+        UPDATE usage_counters
+        SET bytes          = bytes + $3,
+            images         = images + 1,
+            window_uploads = CASE WHEN window_start <= NOW() - INTERVAL '1 hour' THEN 1 ELSE window_uploads + 1 END,
+            window_start   = CASE WHEN window_start <= NOW() - INTERVAL '1 hour' THEN NOW() ELSE window_start END
+        WHERE tenant_id = $1 AND api_key_id = $2`
+
+	_, err := tx.ExecContext(ctx, query, tenantID, keyID, size)
+
+	return err
+}
+
+// adjustUsage adds the given amounts, which may be negative, to the
+// counters of the tenant and of the owning key.
+func adjustUsage(ctx context.Context, tx *sql.Tx, tenantID string, ownerKeyID uuid.NullUUID, bytes, images int64) error {
+	query := `
+        INSERT INTO usage_counters (tenant_id, api_key_id, bytes, images)
+        VALUES ($1, $2, $3, $4)
+        ON CONFLICT (tenant_id, api_key_id) DO UPDATE
+        SET bytes = usage_counters.bytes + EXCLUDED.bytes, images = usage_counters.images + EXCLUDED.images`
+
+	if _, err := tx.ExecContext(ctx, query, tenantID, tenantScope, bytes, images); err != nil {
+		return err
+	}
+
+	if ownerKeyID.Valid {
+		if _, err := tx.ExecContext(ctx, query, tenantID, ownerKeyID.UUID, bytes, images); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
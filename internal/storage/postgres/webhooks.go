@@ -0,0 +1,354 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/google/uuid"
+	"imageProcessor/internal/lib/tenant"
+	"imageProcessor/internal/models"
+	"time"
+)
+
+const deliveryColumns = `id, tenant_id, api_key_id, webhook_id, image_id, event, url, payload, status, attempts, next_attempt_at, last_status_code, last_error, created_at, delivered_at`
+
+func (s *Storage) CreateWebhook(ctx context.Context, keyID uuid.UUID, url string) (*models.Webhook, error) {
+	const op = "storage.postgres.CreateWebhook"
+
+	tenantID, err := tenant.FromContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := `
+        INSERT INTO webhooks (id, tenant_id, api_key_id, url)
+        VALUES ($1, $2, $3, $4)
+        RETURNING id, tenant_id, api_key_id, url, created_at`
+
+	var webhook models.Webhook
+
+	err = s.DB.QueryRowContext(ctx, query, uuid.New(), tenantID, keyID, url).Scan(
+		&webhook.ID,
+		&webhook.TenantID,
+		&webhook.APIKeyID,
+		&webhook.URL,
+		&webhook.CreatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &webhook, nil
+}
+
+// ListWebhooks returns the webhooks registered by the key.
+func (s *Storage) ListWebhooks(ctx context.Context, keyID uuid.UUID) ([]models.Webhook, error) {
+	const op = "storage.postgres.ListWebhooks"
+
+	tenantID, err := tenant.FromContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := `
+        SELECT id, tenant_id, api_key_id, url, created_at
+        FROM webhooks
+        WHERE tenant_id = $1 AND api_key_id = $2
+        ORDER BY created_at`
+
+	rows, err := s.DB.QueryContext(ctx, query, tenantID, keyID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	webhooks := []models.Webhook{}
+	for rows.Next() {
+		var webhook models.Webhook
+		err = rows.Scan(
+			&webhook.ID,
+			&webhook.TenantID,
+			&webhook.APIKeyID,
+			&webhook.URL,
+			&webhook.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return webhooks, nil
+}
+
+func (s *Storage) DeleteWebhook(ctx context.Context, keyID uuid.UUID, id uuid.UUID) error {
+	const op = "storage.postgres.DeleteWebhook"
+
+	tenantID, err := tenant.FromContext(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	result, err := s.DB.ExecContext(ctx, `DELETE FROM webhooks WHERE id = $1 AND tenant_id = $2 AND api_key_id = $3`, id, tenantID, keyID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: webhook with ID %s not found: %w", op, id, sql.ErrNoRows)
+	}
+
+	return nil
+}
+
+// EnqueueDeliveries schedules an event about image for every webhook of the
+// key that uploaded it and for the callback URL given with the upload. It
+// returns the number of deliveries scheduled.
+func (s *Storage) EnqueueDeliveries(ctx context.Context, image *models.Image, event string, payload []byte) (int64, error) {
+	const op = "storage.postgres.EnqueueDeliveries"
+
+	tenantID, err := tenant.FromContext(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Events are signed with a secret of the uploading key, so there is
+	// nobody to address them to without one.
+	if image.OwnerKeyID == nil {
+		return 0, nil
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	query := `
+        INSERT INTO webhook_deliveries (id, tenant_id, api_key_id, webhook_id, image_id, event, url, payload)
+        SELECT gen_random_uuid(), tenant_id, api_key_id, id, $3, $4, url, $5
+        FROM webhooks
+        WHERE tenant_id = $1 AND api_key_id = $2`
+
+	result, err := tx.ExecContext(ctx, query, tenantID, *image.OwnerKeyID, image.ID, event, string(payload))
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if image.CallbackURL != nil {
+		query = `
+            INSERT INTO webhook_deliveries (id, tenant_id, api_key_id, image_id, event, url, payload)
+            VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+		_, err = tx.ExecContext(ctx, query, uuid.New(), tenantID, *image.OwnerKeyID, image.ID, event, *image.CallbackURL, string(payload))
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		count++
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return count, nil
+}
+
+// ListDeliveries returns the latest deliveries of events to the key, newest
+// first.
+func (s *Storage) ListDeliveries(ctx context.Context, keyID uuid.UUID, limit int) ([]models.WebhookDelivery, error) {
+	const op = "storage.postgres.ListDeliveries"
+
+	tenantID, err := tenant.FromContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := `
+        SELECT ` + deliveryColumns + `
+        FROM webhook_deliveries
+        WHERE tenant_id = $1 AND api_key_id = $2
+        ORDER BY created_at DESC
+        LIMIT $3`
+
+	rows, err := s.DB.QueryContext(ctx, query, tenantID, keyID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	deliveries, err := scanDeliveries(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deliveries, nil
+}
+
+// RedeliverWebhook schedules a delivery of the key to be attempted again
+// right away, with a fresh budget of attempts.
+func (s *Storage) RedeliverWebhook(ctx context.Context, keyID uuid.UUID, id uuid.UUID) error {
+	const op = "storage.postgres.RedeliverWebhook"
+
+	tenantID, err := tenant.FromContext(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := `
+        UPDATE webhook_deliveries
+        SET status = 'pending', attempts = 0, next_attempt_at = NOW()
+        WHERE id = $1 AND tenant_id = $2 AND api_key_id = $3`
+
+	result, err := s.DB.ExecContext(ctx, query, id, tenantID, keyID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: delivery with ID %s not found: %w", op, id, sql.ErrNoRows)
+	}
+
+	return nil
+}
+
+// ClaimDeliveries picks up to limit deliveries that are due and hides them
+// from other workers for lease. A delivery whose worker dies before
+// recording the attempt becomes due again once the lease runs out. Like the
+// API key lookup, it is not scoped by tenant: the worker serves all of them.
+func (s *Storage) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	const op = "storage.postgres.ClaimDeliveries"
+
+	query := `
+        UPDATE webhook_deliveries
+        SET next_attempt_at = NOW() + make_interval(secs => $2)
+        WHERE id IN (
+            SELECT id
+            FROM webhook_deliveries
+            WHERE status = 'pending' AND next_attempt_at <= NOW()
+            ORDER BY next_attempt_at
+            LIMIT $1
+            FOR UPDATE SKIP LOCKED
+        )
+        RETURNING ` + deliveryColumns
+
+	rows, err := s.DB.QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	deliveries, err := scanDeliveries(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deliveries, nil
+}
+
+// RecordDelivery records an attempt at a delivery. A successful attempt
+// completes it; a failed one is retried after retryIn, or marks the delivery
+// failed when retryIn is zero.
+func (s *Storage) RecordDelivery(ctx context.Context, id uuid.UUID, statusCode int, attemptErr error, retryIn time.Duration) error {
+	const op = "storage.postgres.RecordDelivery"
+
+	code := sql.NullInt32{Int32: int32(statusCode), Valid: statusCode != 0}
+
+	var query string
+	var args []any
+
+	switch {
+	case attemptErr == nil:
+		query = `
+            UPDATE webhook_deliveries
+            SET status = 'delivered', attempts = attempts + 1, last_status_code = $2, last_error = NULL, delivered_at = NOW()
+            WHERE id = $1`
+		args = []any{id, code}
+	case retryIn > 0:
+		query = `
+            UPDATE webhook_deliveries
+            SET attempts = attempts + 1, last_status_code = $2, last_error = $3, next_attempt_at = NOW() + make_interval(secs => $4)
+            WHERE id = $1`
+		args = []any{id, code, attemptErr.Error(), retryIn.Seconds()}
+	default:
+		query = `
+            UPDATE webhook_deliveries
+            SET status = 'failed', attempts = attempts + 1, last_status_code = $2, last_error = $3
+            WHERE id = $1`
+		args = []any{id, code, attemptErr.Error()}
+	}
+
+	if _, err := s.DB.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func scanDeliveries(rows *sql.Rows) ([]models.WebhookDelivery, error) {
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		var d models.WebhookDelivery
+		var webhookID uuid.NullUUID
+		var lastStatusCode sql.NullInt32
+		var lastError sql.NullString
+		var deliveredAt sql.NullTime
+		var payload []byte
+
+		err := rows.Scan(
+			&d.ID,
+			&d.TenantID,
+			&d.APIKeyID,
+			&webhookID,
+			&d.ImageID,
+			&d.Event,
+			&d.URL,
+			&payload,
+			&d.Status,
+			&d.Attempts,
+			&d.NextAttemptAt,
+			&lastStatusCode,
+			&lastError,
+			&d.CreatedAt,
+			&deliveredAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		d.Payload = payload
+		if webhookID.Valid {
+			d.WebhookID = &webhookID.UUID
+		}
+		if lastStatusCode.Valid {
+			code := int(lastStatusCode.Int32)
+			d.LastStatusCode = &code
+		}
+		if lastError.Valid {
+			d.LastError = &lastError.String
+		}
+		if deliveredAt.Valid {
+			d.DeliveredAt = &deliveredAt.Time
+		}
+
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, rows.Err()
+}
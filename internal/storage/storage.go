@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"imageProcessor/internal/models"
+	"time"
+)
+
+var (
+	ErrBlobNotFound = errors.New("blob not found")
+	// ErrOffsetMismatch is returned when a chunk doesn't start where a
+	// resumable upload currently ends.
+	ErrOffsetMismatch = errors.New("upload offset mismatch")
+	// ErrNotPendingUpload is returned when completing an image whose
+	// presigned upload was completed already.
+	ErrNotPendingUpload = errors.New("image is not pending upload")
+	// ErrNotHashed is returned when searching for images similar to one
+	// whose perceptual hash is yet to be computed.
+	ErrNotHashed = errors.New("image is not hashed")
+	// ErrNoMetadata is returned when getting the metadata of an image
+	// whose original is yet to be read.
+	ErrNoMetadata = errors.New("image metadata is not extracted")
+)
+
+type BlobInfo struct {
+	Key      string
+	Size     int64
+	Checksum string
+	ModTime  time.Time
+}
+
+// DedupMode says what becomes of an upload whose content the tenant has
+// stored already.
+type DedupMode string
+
+const (
+	// DedupExisting answers the upload with the uploader's image of the
+	// same content.
+	DedupExisting DedupMode = "existing"
+	// DedupShare records a new image that shares the stored blobs.
+	DedupShare DedupMode = "share"
+	// DedupReject refuses the upload.
+	DedupReject DedupMode = "reject"
+)
+
+func ParseDedupMode(s string) (DedupMode, error) {
+	switch mode := DedupMode(s); mode {
+	case DedupExisting, DedupShare, DedupReject:
+		return mode, nil
+	}
+
+	return "", fmt.Errorf("unknown dedup mode %q", s)
+}
+
+// DuplicateError is returned instead of recording an upload whose content
+// the uploader has as Image already, unless the mode is DedupShare.
+// Uploads duplicating images of other keys share their blobs instead.
+type DuplicateError struct {
+	Image *models.Image
+	// Rejected is set in DedupReject mode. Otherwise Image stands for the
+	// upload.
+	Rejected bool
+}
+
+func (e *DuplicateError) Error() string {
+	return fmt.Sprintf("content already stored as image %s", e.Image.ID)
+}
@@ -0,0 +1,172 @@
+package transformer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/disintegration/imaging"
+	"github.com/google/uuid"
+	"imageProcessor/internal/lib/imageformat"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidParams  = errors.New("invalid transform parameters")
+	ErrSizeNotAllowed = errors.New("size is not allowed")
+)
+
+const (
+	FitCover   = "cover"
+	FitContain = "contain"
+	FitFill    = "fill"
+	FitInside  = "inside"
+)
+
+var gravities = map[string]imaging.Anchor{
+	"center":    imaging.Center,
+	"north":     imaging.Top,
+	"south":     imaging.Bottom,
+	"east":      imaging.Right,
+	"west":      imaging.Left,
+	"northeast": imaging.TopRight,
+	"northwest": imaging.TopLeft,
+	"southeast": imaging.BottomRight,
+	"southwest": imaging.BottomLeft,
+}
+
+// Params describe a single rendition of an original. They are always kept in
+// normalized form so that equivalent requests share one cache entry.
+type Params struct {
+	Width   int
+	Height  int
+	Fit     string
+	Gravity string
+	Format  imageformat.Format
+	Quality int
+}
+
+// renderVersion is part of the cache key, so that renditions are rendered
+// anew when the way they are rendered changes.
+const renderVersion = 2
+
+// Key identifies the rendition of the given image in the result cache.
+func (p Params) Key(imageID uuid.UUID) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s?%s&v=%d", imageID, p, renderVersion)))
+
+	return hex.EncodeToString(sum[:])
+}
+
+func (p Params) String() string {
+	return fmt.Sprintf("w=%d&h=%d&fit=%s&gravity=%s&format=%s&q=%d", p.Width, p.Height, p.Fit, p.Gravity, p.Format.Name, p.Quality)
+}
+
+func (p Params) anchor() imaging.Anchor {
+	return gravities[p.Gravity]
+}
+
+// parseParams reads the transform query string and normalizes it: options
+// that cannot affect the output are dropped and defaults are filled in.
+func parseParams(query url.Values, defaultFormat imageformat.Format, defaultQuality int) (Params, error) {
+	var p Params
+	var err error
+
+	if p.Width, err = parseDimension(query.Get("w")); err != nil {
+		return Params{}, fmt.Errorf("%w: w: %v", ErrInvalidParams, err)
+	}
+	if p.Height, err = parseDimension(query.Get("h")); err != nil {
+		return Params{}, fmt.Errorf("%w: h: %v", ErrInvalidParams, err)
+	}
+	if p.Width == 0 && p.Height == 0 {
+		return Params{}, fmt.Errorf("%w: at least one of w and h is required", ErrInvalidParams)
+	}
+
+	p.Fit = strings.ToLower(query.Get("fit"))
+	switch p.Fit {
+	case "":
+		p.Fit = FitCover
+	case FitCover, FitContain, FitFill, FitInside:
+	default:
+		return Params{}, fmt.Errorf("%w: unknown fit %q", ErrInvalidParams, p.Fit)
+	}
+
+	p.Gravity = strings.ToLower(query.Get("gravity"))
+	if p.Gravity == "" {
+		p.Gravity = "center"
+	}
+	if _, ok := gravities[p.Gravity]; !ok {
+		return Params{}, fmt.Errorf("%w: unknown gravity %q", ErrInvalidParams, p.Gravity)
+	}
+
+	p.Format = defaultFormat
+	if name := query.Get("format"); name != "" {
+		if p.Format, err = imageformat.Parse(name); err != nil {
+			return Params{}, fmt.Errorf("%w: %v", ErrInvalidParams, err)
+		}
+	}
+
+	p.Quality = defaultQuality
+	if q := query.Get("q"); q != "" {
+		if p.Quality, err = strconv.Atoi(q); err != nil || p.Quality < 1 || p.Quality > 100 {
+			return Params{}, fmt.Errorf("%w: q must be between 1 and 100", ErrInvalidParams)
+		}
+	}
+
+	// With a single side given the image is simply scaled, so fit and
+	// gravity make no difference.
+	if p.Width == 0 || p.Height == 0 {
+		p.Fit = ""
+	}
+	if p.Fit != FitCover && p.Fit != FitContain {
+		p.Gravity = ""
+	}
+	if p.Format != imageformat.JPEG {
+		p.Quality = 0
+	}
+
+	return p, nil
+}
+
+func parseDimension(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("must be a non-negative integer")
+	}
+
+	return n, nil
+}
+
+type size struct {
+	width  int
+	height int
+}
+
+func parseSizes(list []string) (map[size]bool, error) {
+	sizes := make(map[size]bool, len(list))
+
+	for _, s := range list {
+		w, h, ok := strings.Cut(strings.ToLower(strings.TrimSpace(s)), "x")
+		if !ok {
+			return nil, fmt.Errorf("invalid size %q, expected WxH", s)
+		}
+
+		width, err := parseDimension(w)
+		if err != nil {
+			return nil, fmt.Errorf("invalid size %q: %v", s, err)
+		}
+		height, err := parseDimension(h)
+		if err != nil {
+			return nil, fmt.Errorf("invalid size %q: %v", s, err)
+		}
+
+		sizes[size{width: width, height: height}] = true
+	}
+
+	return sizes, nil
+}
@@ -0,0 +1,241 @@
+package transformer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/disintegration/imaging"
+	"github.com/google/uuid"
+	"golang.org/x/sync/singleflight"
+	"image"
+	"image/color"
+	"imageProcessor/internal/config"
+	"imageProcessor/internal/lib/imageformat"
+	"imageProcessor/internal/lib/tenant"
+	"imageProcessor/internal/metadata"
+	"imageProcessor/internal/models"
+	"imageProcessor/internal/storage"
+	"io"
+	"log/slog"
+	"net/url"
+	"time"
+)
+
+const cacheDir = "transforms"
+
+type BlobStorage interface {
+	Put(ctx context.Context, key string, r io.Reader) (*storage.BlobInfo, error)
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, *storage.BlobInfo, error)
+	PrefixSize(ctx context.Context, prefix string) (int64, error)
+}
+
+// Transformer renders on-the-fly renditions of originals and caches the
+// results in the blob storage, up to a size per image.
+type Transformer struct {
+	blobs          BlobStorage
+	log            *slog.Logger
+	sizes          map[size]bool
+	maxDimension   int
+	defaultFormat  imageformat.Format
+	defaultQuality int
+	maxCacheSize   int64
+	group          singleflight.Group
+}
+
+func New(log *slog.Logger, blobs BlobStorage, cfg *config.Transform, defaultFormat imageformat.Format) (*Transformer, error) {
+	const op = "transformer.New"
+
+	sizes, err := parseSizes(cfg.AllowedSizes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Transformer{
+		blobs:          blobs,
+		log:            log,
+		sizes:          sizes,
+		maxDimension:   cfg.MaxDimension,
+		defaultFormat:  defaultFormat,
+		defaultQuality: cfg.DefaultQuality,
+		maxCacheSize:   cfg.MaxCacheSize,
+	}, nil
+}
+
+// CachePrefix is the blob key prefix of the renditions of an image, which
+// go when the image does.
+func CachePrefix(tenantID string, imageID uuid.UUID) string {
+	return tenant.BlobKey(tenantID, cacheDir, imageID.String())
+}
+
+// Parse validates the query of a transform request against the configured
+// limits and returns normalized parameters.
+func (t *Transformer) Parse(query url.Values) (Params, error) {
+	p, err := parseParams(query, t.defaultFormat, t.defaultQuality)
+	if err != nil {
+		return Params{}, err
+	}
+
+	if p.Width > t.maxDimension || p.Height > t.maxDimension {
+		return Params{}, fmt.Errorf("%w: %dx%d exceeds %d pixels", ErrSizeNotAllowed, p.Width, p.Height, t.maxDimension)
+	}
+
+	if len(t.sizes) > 0 && !t.sizes[size{width: p.Width, height: p.Height}] {
+		return Params{}, fmt.Errorf("%w: %dx%d", ErrSizeNotAllowed, p.Width, p.Height)
+	}
+
+	return p, nil
+}
+
+// Transform returns the rendition of img described by p, rendering it from
+// the original on a cache miss. Concurrent requests for the same rendition
+// share a single render.
+func (t *Transformer) Transform(ctx context.Context, img *models.Image, p Params) (io.ReadSeekCloser, *storage.BlobInfo, error) {
+	const op = "transformer.Transform"
+
+	key := tenant.BlobKey(CachePrefix(img.TenantID, img.ID), p.Key(img.ID)+"."+p.Format.Extension)
+
+	content, info, err := t.blobs.Open(ctx, key)
+	if err == nil {
+		return content, info, nil
+	}
+	if !errors.Is(err, storage.ErrBlobNotFound) {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// The render must not be cut short when the request that started it
+	// goes away, since other callers may be waiting for the same result.
+	v, err, shared := t.group.Do(key, func() (interface{}, error) {
+		return t.render(context.WithoutCancel(ctx), img, p, key)
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	t.log.Debug("rendered transform", slog.String("op", op), slog.String("key", key), slog.Bool("shared", shared))
+
+	if uncached := v.([]byte); uncached != nil {
+		return nopCloser{bytes.NewReader(uncached)}, &storage.BlobInfo{
+			Key:     key,
+			Size:    int64(len(uncached)),
+			ModTime: time.Now(),
+		}, nil
+	}
+
+	content, info, err = t.blobs.Open(ctx, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return content, info, nil
+}
+
+// render stores the rendition under key, unless the renditions cached for
+// img would outgrow the limit. It returns the rendition in that case only.
+func (t *Transformer) render(ctx context.Context, img *models.Image, p Params, key string) ([]byte, error) {
+	original, _, err := t.blobs.Open(ctx, img.OriginalPath)
+	if err != nil {
+		return nil, err
+	}
+	defer original.Close()
+
+	// Metadata that can't be read leaves the original as stored.
+	orientation := 0
+	if m, err := metadata.Extract(original); err == nil {
+		orientation = m.Orientation
+	}
+	if _, err = original.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	src, err := imaging.Decode(original)
+	if err != nil {
+		return nil, err
+	}
+	src = metadata.Orient(src, orientation)
+
+	var opts []imaging.EncodeOption
+	if p.Quality > 0 {
+		opts = append(opts, imaging.JPEGQuality(p.Quality))
+	}
+
+	var buf bytes.Buffer
+	if err = imageformat.Encode(&buf, apply(src, p), p.Format, opts...); err != nil {
+		return nil, err
+	}
+
+	// Renders of other sizes running meanwhile may overshoot the limit by
+	// their own size at most.
+	cached, err := t.blobs.PrefixSize(ctx, CachePrefix(img.TenantID, img.ID))
+	if err != nil {
+		return nil, err
+	}
+	if cached+int64(buf.Len()) > t.maxCacheSize {
+		t.log.Debug("transform cache of image is full", slog.String("image_id", img.ID.String()), slog.Int64("cached", cached))
+		return buf.Bytes(), nil
+	}
+
+	if _, err = t.blobs.Put(ctx, key, &buf); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+type nopCloser struct {
+	io.ReadSeeker
+}
+
+func (nopCloser) Close() error { return nil }
+
+func apply(src image.Image, p Params) image.Image {
+	if p.Width == 0 || p.Height == 0 {
+		return imaging.Resize(src, p.Width, p.Height, imaging.Lanczos)
+	}
+
+	switch p.Fit {
+	case FitFill:
+		return imaging.Resize(src, p.Width, p.Height, imaging.Lanczos)
+	case FitInside:
+		return imaging.Fit(src, p.Width, p.Height, imaging.Lanczos)
+	case FitContain:
+		return contain(src, p.Width, p.Height, p.anchor())
+	default:
+		return imaging.Fill(src, p.Width, p.Height, p.anchor(), imaging.Lanczos)
+	}
+}
+
+// contain scales src to fit the box and pads the rest with transparency,
+// placing the image according to the anchor.
+func contain(src image.Image, width, height int, anchor imaging.Anchor) image.Image {
+	b := src.Bounds()
+
+	scale := min(float64(width)/float64(b.Dx()), float64(height)/float64(b.Dy()))
+	w := max(1, int(float64(b.Dx())*scale+0.5))
+	h := max(1, int(float64(b.Dy())*scale+0.5))
+
+	scaled := imaging.Resize(src, w, h, imaging.Lanczos)
+	canvas := imaging.New(width, height, color.Transparent)
+
+	return imaging.Paste(canvas, scaled, anchorPoint(width, height, w, h, anchor))
+}
+
+func anchorPoint(width, height, w, h int, anchor imaging.Anchor) image.Point {
+	x, y := (width-w)/2, (height-h)/2
+
+	switch anchor {
+	case imaging.TopLeft, imaging.Left, imaging.BottomLeft:
+		x = 0
+	case imaging.TopRight, imaging.Right, imaging.BottomRight:
+		x = width - w
+	}
+
+	switch anchor {
+	case imaging.TopLeft, imaging.Top, imaging.TopRight:
+		y = 0
+	case imaging.BottomLeft, imaging.Bottom, imaging.BottomRight:
+		y = height - h
+	}
+
+	return image.Pt(x, y)
+}
@@ -0,0 +1,248 @@
+package transformer_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/disintegration/imaging"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"image"
+	"image/color"
+	"imageProcessor/internal/config"
+	"imageProcessor/internal/lib/imageformat"
+	"imageProcessor/internal/lib/logger/handlers/slogdiscard"
+	"imageProcessor/internal/models"
+	"imageProcessor/internal/storage"
+	"imageProcessor/internal/transformer"
+	"io"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type nopSeekCloser struct {
+	io.ReadSeeker
+}
+
+func (nopSeekCloser) Close() error { return nil }
+
+// memoryBlobs is an in-memory blob storage that counts writes and can hold
+// reads of the original back to let concurrent requests pile up.
+type memoryBlobs struct {
+	mu      sync.Mutex
+	blobs   map[string][]byte
+	puts    atomic.Int32
+	release chan struct{}
+}
+
+func (m *memoryBlobs) Put(_ context.Context, key string, r io.Reader) (*storage.BlobInfo, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	m.puts.Add(1)
+	m.mu.Lock()
+	m.blobs[key] = data
+	m.mu.Unlock()
+
+	return &storage.BlobInfo{Key: key, Size: int64(len(data))}, nil
+}
+
+func (m *memoryBlobs) Open(_ context.Context, key string) (io.ReadSeekCloser, *storage.BlobInfo, error) {
+	if key == "shop/uploads/original.png" && m.release != nil {
+		<-m.release
+	}
+
+	m.mu.Lock()
+	data, ok := m.blobs[key]
+	m.mu.Unlock()
+	if !ok {
+		return nil, nil, storage.ErrBlobNotFound
+	}
+
+	return nopSeekCloser{bytes.NewReader(data)}, &storage.BlobInfo{Key: key, Size: int64(len(data)), ModTime: time.Now()}, nil
+}
+
+func (m *memoryBlobs) PrefixSize(_ context.Context, prefix string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var size int64
+	for key, data := range m.blobs {
+		if strings.HasPrefix(key, prefix+"/") {
+			size += int64(len(data))
+		}
+	}
+
+	return size, nil
+}
+
+func newBlobs(t *testing.T) *memoryBlobs {
+	var buf bytes.Buffer
+	src := imaging.New(400, 200, color.NRGBA{R: 200, A: 255})
+	require.NoError(t, imaging.Encode(&buf, src, imaging.PNG))
+
+	return &memoryBlobs{blobs: map[string][]byte{"shop/uploads/original.png": buf.Bytes()}}
+}
+
+func TestParse(t *testing.T) {
+	tr, err := transformer.New(slogdiscard.NewDiscardLogger(), newBlobs(t), &config.Transform{
+		AllowedSizes:   []string{"100x100", "200x0"},
+		MaxDimension:   1000,
+		DefaultQuality: 85,
+	}, imageformat.JPEG)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		query    string
+		expected transformer.Params
+		err      error
+	}{
+		{
+			name:     "Defaults",
+			query:    "w=100&h=100",
+			expected: transformer.Params{Width: 100, Height: 100, Fit: "cover", Gravity: "center", Format: imageformat.JPEG, Quality: 85},
+		},
+		{
+			name:     "Single Side Drops Fit",
+			query:    "w=200&fit=contain&gravity=north&format=png&q=10",
+			expected: transformer.Params{Width: 200, Format: imageformat.PNG},
+		},
+		{name: "Unknown Fit", query: "w=100&h=100&fit=zoom", err: transformer.ErrInvalidParams},
+		{name: "Bad Quality", query: "w=100&h=100&q=0", err: transformer.ErrInvalidParams},
+		{name: "No Size", query: "fit=cover", err: transformer.ErrInvalidParams},
+		{name: "Not Allowed", query: "w=101&h=100", err: transformer.ErrSizeNotAllowed},
+		{name: "Too Large", query: "w=2000", err: transformer.ErrSizeNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, _ := url.ParseQuery(tt.query)
+
+			params, err := tr.Parse(query)
+			if tt.err != nil {
+				require.True(t, errors.Is(err, tt.err), "unexpected error: %v", err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, params)
+		})
+	}
+}
+
+func TestTransformCoalescesAndCaches(t *testing.T) {
+	blobs := newBlobs(t)
+	blobs.release = make(chan struct{})
+
+	tr, err := transformer.New(slogdiscard.NewDiscardLogger(), blobs, &config.Transform{MaxDimension: 1000, DefaultQuality: 85, MaxCacheSize: 1 << 20}, imageformat.JPEG)
+	require.NoError(t, err)
+
+	img := &models.Image{ID: uuid.New(), TenantID: "shop", OriginalPath: "shop/uploads/original.png"}
+	params, err := tr.Parse(url.Values{"w": {"100"}, "h": {"100"}, "fit": {"contain"}, "format": {"png"}})
+	require.NoError(t, err)
+
+	const callers = 8
+
+	var wg sync.WaitGroup
+	results := make([][]byte, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			content, _, err := tr.Transform(context.Background(), img, params)
+			require.NoError(t, err)
+			defer content.Close()
+
+			results[i], err = io.ReadAll(content)
+			require.NoError(t, err)
+		}(i)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(blobs.release)
+	wg.Wait()
+
+	require.Equal(t, int32(1), blobs.puts.Load())
+	require.Contains(t, blobs.blobs, "shop/transforms/"+img.ID.String()+"/"+params.Key(img.ID)+".png")
+	for _, r := range results {
+		require.Equal(t, results[0], r)
+	}
+
+	rendered, _, err := image.Decode(bytes.NewReader(results[0]))
+	require.NoError(t, err)
+	require.Equal(t, image.Rect(0, 0, 100, 100), rendered.Bounds())
+
+	// The letterbox above and below the scaled image stays transparent.
+	_, _, _, a := rendered.At(50, 5).RGBA()
+	require.Zero(t, a)
+
+	_, _, err = tr.Transform(context.Background(), img, params)
+	require.NoError(t, err)
+	require.Equal(t, int32(1), blobs.puts.Load())
+}
+
+func TestTransformCacheLimit(t *testing.T) {
+	blobs := newBlobs(t)
+
+	tr, err := transformer.New(slogdiscard.NewDiscardLogger(), blobs, &config.Transform{MaxDimension: 1000, DefaultQuality: 85, MaxCacheSize: 1}, imageformat.PNG)
+	require.NoError(t, err)
+
+	img := &models.Image{ID: uuid.New(), TenantID: "shop", OriginalPath: "shop/uploads/original.png"}
+	params, err := tr.Parse(url.Values{"w": {"40"}})
+	require.NoError(t, err)
+
+	// A rendition that doesn't fit is served without being stored, on
+	// every request.
+	for range 2 {
+		content, info, err := tr.Transform(context.Background(), img, params)
+		require.NoError(t, err)
+
+		rendered, _, err := image.Decode(content)
+		require.NoError(t, err)
+		require.Equal(t, image.Rect(0, 0, 40, 20), rendered.Bounds())
+		require.NoError(t, content.Close())
+		require.NotZero(t, info.Size)
+	}
+
+	require.Zero(t, blobs.puts.Load())
+	require.Equal(t, "shop/transforms/"+img.ID.String(), transformer.CachePrefix(img.TenantID, img.ID))
+}
+
+func TestTransformOrients(t *testing.T) {
+	// A landscape JPEG tagged with orientation 6, as a phone held upright
+	// stores it.
+	var buf bytes.Buffer
+	require.NoError(t, imaging.Encode(&buf, imaging.New(80, 40, color.NRGBA{G: 200, A: 255}), imaging.JPEG))
+
+	exif := []byte("Exif\x00\x00MM\x00*\x00\x00\x00\x08\x00\x01")
+	exif = append(exif, 0x01, 0x12, 0, 3, 0, 0, 0, 1, 0, 6, 0, 0)
+	exif = append(exif, 0, 0, 0, 0)
+	original := []byte{0xff, 0xd8, 0xff, 0xe1, 0, byte(len(exif) + 2)}
+	original = append(original, exif...)
+	original = append(original, buf.Bytes()[2:]...)
+
+	blobs := &memoryBlobs{blobs: map[string][]byte{"shop/uploads/original.jpg": original}}
+
+	tr, err := transformer.New(slogdiscard.NewDiscardLogger(), blobs, &config.Transform{MaxDimension: 1000, DefaultQuality: 85}, imageformat.PNG)
+	require.NoError(t, err)
+
+	img := &models.Image{ID: uuid.New(), TenantID: "shop", OriginalPath: "shop/uploads/original.jpg"}
+	params, err := tr.Parse(url.Values{"w": {"20"}})
+	require.NoError(t, err)
+
+	content, _, err := tr.Transform(context.Background(), img, params)
+	require.NoError(t, err)
+	defer content.Close()
+
+	rendered, _, err := image.Decode(content)
+	require.NoError(t, err)
+	require.Equal(t, image.Rect(0, 0, 20, 40), rendered.Bounds())
+}
@@ -0,0 +1,53 @@
+package webhook
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"imageProcessor/internal/lib/mediaurl"
+	"imageProcessor/internal/lib/netguard"
+	"net/netip"
+	"net/url"
+	"time"
+)
+
+const (
+	EventProcessed = "image.processed"
+	EventFailed    = "image.failed"
+)
+
+var (
+	ErrInvalidURL       = errors.New("webhook url must be an absolute http or https url")
+	ErrForbiddenAddress = errors.New("webhook url must not point to an internal address")
+)
+
+// Event is the JSON body POSTed to webhooks.
+type Event struct {
+	ID        uuid.UUID  `json:"id"`
+	Type      string     `json:"type"`
+	CreatedAt time.Time  `json:"created_at"`
+	Image     EventImage `json:"image"`
+}
+
+type EventImage struct {
+	ID     uuid.UUID `json:"id"`
+	Status string    `json:"status"`
+	// URLs expire like any signed link. A delivery that is retried later
+	// than that still names the image to fetch fresh ones for.
+	URLs mediaurl.URLs `json:"urls"`
+}
+
+// ValidateURL checks that raw can be delivered to. A host given as an IP
+// address has to be a public one; host names are checked when they are
+// resolved for delivery, against the allowed networks as well.
+func ValidateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return ErrInvalidURL
+	}
+
+	if addr, err := netip.ParseAddr(u.Hostname()); err == nil && !netguard.Permitted(addr, nil) {
+		return ErrForbiddenAddress
+	}
+
+	return nil
+}
@@ -0,0 +1,233 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"imageProcessor/internal/config"
+	"imageProcessor/internal/lib/logger/sl"
+	"imageProcessor/internal/lib/mediaurl"
+	"imageProcessor/internal/lib/netguard"
+	"imageProcessor/internal/models"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	HeaderSignature = "X-Webhook-Signature"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+)
+
+type Storage interface {
+	EnqueueDeliveries(ctx context.Context, image *models.Image, event string, payload []byte) (int64, error)
+	ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error)
+	RecordDelivery(ctx context.Context, id uuid.UUID, statusCode int, attemptErr error, retryIn time.Duration) error
+}
+
+// Dispatcher turns the outcome of processing into events and delivers them,
+// retrying failed deliveries with exponential backoff. Deliveries live in
+// the storage, so any replica may pick them up and none is lost on restart.
+type Dispatcher struct {
+	log            *slog.Logger
+	storage        Storage
+	signer         *Signer
+	urlSigner      mediaurl.Signer
+	client         *http.Client
+	publicURL      string
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	pollInterval   time.Duration
+	batchSize      int
+}
+
+func New(log *slog.Logger, storage Storage, signer *Signer, urlSigner mediaurl.Signer, cfg *config.Webhooks) (*Dispatcher, error) {
+	const op = "webhook.New"
+
+	allowed, err := netguard.ParseNetworks(cfg.AllowedNetworks)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Dispatcher{
+		log:       log.With(slog.String("component", "webhook")),
+		storage:   storage,
+		signer:    signer,
+		urlSigner: urlSigner,
+		// Receivers are chosen by clients, so the worker must not be made
+		// to POST into the networks behind it. No Proxy: a proxy would make
+		// the connections the dialer checks. Redirects are not followed,
+		// the receiver has to answer itself.
+		client: &http.Client{
+			Timeout: cfg.Timeout,
+			Transport: &http.Transport{
+				DialContext:           netguard.Dialer(10*time.Second, allowed).DialContext,
+				ForceAttemptHTTP2:     true,
+				MaxIdleConns:          10,
+				IdleConnTimeout:       90 * time.Second,
+				TLSHandshakeTimeout:   10 * time.Second,
+				ResponseHeaderTimeout: cfg.Timeout,
+			},
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		publicURL:      cfg.PublicURL,
+		maxAttempts:    cfg.MaxAttempts,
+		initialBackoff: cfg.InitialBackoff,
+		maxBackoff:     cfg.MaxBackoff,
+		pollInterval:   cfg.PollInterval,
+		batchSize:      cfg.BatchSize,
+	}, nil
+}
+
+// Notify schedules an event about image, which has just been processed or
+// has failed, for delivery to the webhooks of its owner.
+func (d *Dispatcher) Notify(ctx context.Context, image *models.Image) error {
+	const op = "webhook.Notify"
+
+	event := Event{
+		ID:        uuid.New(),
+		Type:      EventProcessed,
+		CreatedAt: time.Now().UTC(),
+		Image: EventImage{
+			ID:     image.ID,
+			Status: image.Status,
+			URLs:   mediaurl.For(image, d.urlSigner).Absolute(d.publicURL),
+		},
+	}
+	if image.Status == "failed" {
+		event.Type = EventFailed
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	n, err := d.storage.EnqueueDeliveries(ctx, image, event.Type, payload)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	d.log.Debug("event scheduled", slog.String("image_id", image.ID.String()), slog.String("event", event.Type), slog.Int64("deliveries", n))
+
+	return nil
+}
+
+// Run delivers due events until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context) {
+	d.log.Info("webhook dispatcher started")
+
+	ticker := time.NewTicker(d.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// Keep going while full batches come in to drain a backlog quickly.
+		for {
+			n, err := d.Dispatch(ctx)
+			if err != nil {
+				d.log.Error("failed to dispatch webhooks", sl.Err(err))
+				break
+			}
+			if n < d.batchSize {
+				break
+			}
+		}
+	}
+}
+
+// Dispatch attempts one batch of due deliveries and returns its size.
+func (d *Dispatcher) Dispatch(ctx context.Context) (int, error) {
+	const op = "webhook.Dispatch"
+
+	// A delivery stays claimed for long enough to be attempted.
+	deliveries, err := d.storage.ClaimDeliveries(ctx, d.batchSize, 2*d.client.Timeout)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.deliver(ctx, delivery)
+		}()
+	}
+	wg.Wait()
+
+	return len(deliveries), nil
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, delivery models.WebhookDelivery) {
+	log := d.log.With(slog.String("delivery_id", delivery.ID.String()), slog.String("image_id", delivery.ImageID.String()))
+
+	statusCode, err := d.send(ctx, delivery)
+
+	var retryIn time.Duration
+	if err != nil {
+		attempt := delivery.Attempts + 1
+		if attempt < d.maxAttempts {
+			retryIn = d.backoff(attempt)
+		}
+		log.Warn("webhook delivery failed", slog.Int("attempt", attempt), slog.Duration("retry_in", retryIn), sl.Err(err))
+	} else {
+		log.Info("webhook delivered", slog.Int("status", statusCode))
+	}
+
+	if err := d.storage.RecordDelivery(ctx, delivery.ID, statusCode, err, retryIn); err != nil {
+		log.Error("failed to record webhook delivery", sl.Err(err))
+	}
+}
+
+// send POSTs the event and reports the status code the receiver answered
+// with, zero if it could not be reached.
+func (d *Dispatcher) send(ctx context.Context, delivery models.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderDelivery, delivery.ID.String())
+	req.Header.Set(HeaderSignature, Sign(d.signer.Secret(delivery.APIKeyID), time.Now(), delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// Drain a little of the body so the connection can be reused.
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver answered %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// backoff is the wait after the given failed attempt, counted from one.
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	wait := d.initialBackoff
+	for i := 1; i < attempt && wait < d.maxBackoff; i++ {
+		wait *= 2
+	}
+
+	return min(wait, d.maxBackoff)
+}
@@ -0,0 +1,373 @@
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"imageProcessor/internal/config"
+	"imageProcessor/internal/lib/logger/handlers/slogdiscard"
+	"imageProcessor/internal/lib/netguard"
+	"imageProcessor/internal/models"
+	"imageProcessor/internal/webhook"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+type attempt struct {
+	statusCode int
+	err        error
+	retryIn    time.Duration
+}
+
+// memoryStorage keeps deliveries in memory. Every enqueued delivery goes to
+// the same URL and is due right away.
+type memoryStorage struct {
+	mu         sync.Mutex
+	url        string
+	deliveries []models.WebhookDelivery
+	attempts   map[uuid.UUID][]attempt
+}
+
+func (m *memoryStorage) EnqueueDeliveries(_ context.Context, image *models.Image, event string, payload []byte) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.deliveries = append(m.deliveries, models.WebhookDelivery{
+		ID:       uuid.New(),
+		TenantID: image.TenantID,
+		APIKeyID: *image.OwnerKeyID,
+		ImageID:  image.ID,
+		Event:    event,
+		URL:      m.url,
+		Payload:  payload,
+		Status:   "pending",
+	})
+
+	return 1, nil
+}
+
+func (m *memoryStorage) ClaimDeliveries(_ context.Context, limit int, _ time.Duration) ([]models.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var due []models.WebhookDelivery
+	for _, d := range m.deliveries {
+		if d.Status == "pending" && len(due) < limit {
+			due = append(due, d)
+		}
+	}
+
+	return due, nil
+}
+
+func (m *memoryStorage) RecordDelivery(_ context.Context, id uuid.UUID, statusCode int, attemptErr error, retryIn time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.attempts[id] = append(m.attempts[id], attempt{statusCode: statusCode, err: attemptErr, retryIn: retryIn})
+
+	for i := range m.deliveries {
+		d := &m.deliveries[i]
+		if d.ID != id {
+			continue
+		}
+		d.Attempts++
+		switch {
+		case attemptErr == nil:
+			d.Status = "delivered"
+		case retryIn == 0:
+			d.Status = "failed"
+		}
+	}
+
+	return nil
+}
+
+type urlSigner struct{}
+
+func (urlSigner) Sign(path string, params url.Values) string {
+	return path + "?" + params.Encode() + "&sig=x"
+}
+
+func (urlSigner) ExpiresAt() time.Time {
+	return time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+func TestDispatcher(t *testing.T) {
+	signer, err := webhook.NewSigner("test-signing-key")
+	require.NoError(t, err)
+
+	cfg := &config.Webhooks{
+		PublicURL:      "https://img.example.com",
+		Timeout:        time.Second,
+		MaxAttempts:    3,
+		InitialBackoff: 10 * time.Second,
+		MaxBackoff:     15 * time.Second,
+		BatchSize:      10,
+		// The receivers below listen on loopback.
+		AllowedNetworks: []string{"127.0.0.0/8"},
+	}
+
+	keyID := uuid.New()
+	resizePath := "shop/processed/resized.jpg"
+
+	tests := []struct {
+		name             string
+		image            models.Image
+		responses        []int
+		expectedEvent    string
+		expectedStatus   string
+		expectedAttempts []attempt
+	}{
+		{
+			name:             "Processed",
+			image:            models.Image{Status: "processed", ProcessedPathResize: &resizePath},
+			responses:        []int{http.StatusNoContent},
+			expectedEvent:    webhook.EventProcessed,
+			expectedStatus:   "delivered",
+			expectedAttempts: []attempt{{statusCode: http.StatusNoContent}},
+		},
+		{
+			name:           "Failed Image",
+			image:          models.Image{Status: "failed"},
+			responses:      []int{http.StatusOK},
+			expectedEvent:  webhook.EventFailed,
+			expectedStatus: "delivered",
+			expectedAttempts: []attempt{
+				{statusCode: http.StatusOK},
+			},
+		},
+		{
+			name:           "Retried With Backoff",
+			image:          models.Image{Status: "processed"},
+			responses:      []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusOK},
+			expectedEvent:  webhook.EventProcessed,
+			expectedStatus: "delivered",
+			expectedAttempts: []attempt{
+				{statusCode: http.StatusInternalServerError, retryIn: 10 * time.Second},
+				{statusCode: http.StatusBadGateway, retryIn: 15 * time.Second},
+				{statusCode: http.StatusOK},
+			},
+		},
+		{
+			name:           "Gives Up",
+			image:          models.Image{Status: "processed"},
+			responses:      []int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError},
+			expectedEvent:  webhook.EventProcessed,
+			expectedStatus: "failed",
+			expectedAttempts: []attempt{
+				{statusCode: http.StatusInternalServerError, retryIn: 10 * time.Second},
+				{statusCode: http.StatusInternalServerError, retryIn: 15 * time.Second},
+				{statusCode: http.StatusInternalServerError},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var bodies [][]byte
+			responses := tt.responses
+
+			receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				require.NoError(t, err)
+
+				require.Equal(t, tt.expectedEvent, r.Header.Get(webhook.HeaderEvent))
+				require.NotEmpty(t, r.Header.Get(webhook.HeaderDelivery))
+				require.NoError(t, webhook.Verify(signer.Secret(keyID), r.Header.Get(webhook.HeaderSignature), body, time.Minute, time.Now()))
+
+				mu.Lock()
+				bodies = append(bodies, body)
+				status := responses[0]
+				responses = responses[1:]
+				mu.Unlock()
+
+				w.WriteHeader(status)
+			}))
+			defer receiver.Close()
+
+			storage := &memoryStorage{url: receiver.URL, attempts: make(map[uuid.UUID][]attempt)}
+			dispatcher, err := webhook.New(slogdiscard.NewDiscardLogger(), storage, signer, urlSigner{}, cfg)
+			require.NoError(t, err)
+
+			image := tt.image
+			image.ID = uuid.New()
+			image.TenantID = "shop"
+			image.OwnerKeyID = &keyID
+
+			require.NoError(t, dispatcher.Notify(context.Background(), &image))
+
+			for range tt.responses {
+				n, err := dispatcher.Dispatch(context.Background())
+				require.NoError(t, err)
+				require.Equal(t, 1, n)
+			}
+
+			n, err := dispatcher.Dispatch(context.Background())
+			require.NoError(t, err)
+			require.Zero(t, n)
+
+			delivery := storage.deliveries[0]
+			require.Equal(t, tt.expectedStatus, delivery.Status)
+
+			attempts := storage.attempts[delivery.ID]
+			require.Len(t, attempts, len(tt.expectedAttempts))
+			for i, a := range attempts {
+				require.Equal(t, tt.expectedAttempts[i].statusCode, a.statusCode)
+				require.Equal(t, tt.expectedAttempts[i].retryIn, a.retryIn)
+				require.Equal(t, tt.expectedAttempts[i].statusCode/100 != 2, a.err != nil)
+			}
+
+			var event webhook.Event
+			require.NoError(t, json.Unmarshal(bodies[0], &event))
+			require.Equal(t, tt.expectedEvent, event.Type)
+			require.Equal(t, image.ID, event.Image.ID)
+			require.Equal(t, image.Status, event.Image.Status)
+			require.Equal(t, "https://img.example.com/image/"+image.ID.String()+"/original?tenant=shop&sig=x", event.Image.URLs.Original)
+			if image.ProcessedPathResize != nil {
+				require.Equal(t, "https://img.example.com/image/"+image.ID.String()+"/variants/resize?tenant=shop&sig=x", event.Image.URLs.Variants["resize"])
+			}
+		})
+	}
+}
+
+func TestDispatcherGuard(t *testing.T) {
+	signer, err := webhook.NewSigner("test-signing-key")
+	require.NoError(t, err)
+
+	var followed bool
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hook", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/target", http.StatusTemporaryRedirect)
+	})
+	mux.HandleFunc("/target", func(w http.ResponseWriter, r *http.Request) {
+		followed = true
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	receiver := httptest.NewServer(mux)
+	defer receiver.Close()
+
+	tests := []struct {
+		name            string
+		path            string
+		allowedNetworks []string
+		expectedStatus  int
+		expectedErr     error
+	}{
+		{
+			name:            "Allowed Network",
+			path:            "/hook",
+			allowedNetworks: []string{"127.0.0.0/8"},
+			expectedStatus:  http.StatusNoContent,
+		},
+		{
+			name:        "Loopback Not Allowed",
+			path:        "/hook",
+			expectedErr: netguard.ErrForbiddenAddress,
+		},
+		{
+			name:            "Redirect Not Followed",
+			path:            "/moved",
+			allowedNetworks: []string{"127.0.0.0/8"},
+			expectedStatus:  http.StatusTemporaryRedirect,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Webhooks{
+				Timeout:         time.Second,
+				MaxAttempts:     1,
+				BatchSize:       10,
+				AllowedNetworks: tt.allowedNetworks,
+			}
+
+			storage := &memoryStorage{url: receiver.URL + tt.path, attempts: make(map[uuid.UUID][]attempt)}
+			dispatcher, err := webhook.New(slogdiscard.NewDiscardLogger(), storage, signer, urlSigner{}, cfg)
+			require.NoError(t, err)
+
+			keyID := uuid.New()
+			image := models.Image{ID: uuid.New(), TenantID: "shop", Status: "processed", OwnerKeyID: &keyID}
+			require.NoError(t, dispatcher.Notify(context.Background(), &image))
+
+			_, err = dispatcher.Dispatch(context.Background())
+			require.NoError(t, err)
+
+			attempts := storage.attempts[storage.deliveries[0].ID]
+			require.Len(t, attempts, 1)
+			require.Equal(t, tt.expectedStatus, attempts[0].statusCode)
+			if tt.expectedErr != nil {
+				require.ErrorIs(t, attempts[0].err, tt.expectedErr)
+			}
+			require.False(t, followed)
+		})
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	tests := []struct {
+		name        string
+		url         string
+		expectedErr error
+	}{
+		{name: "Public Host", url: "https://hooks.example.com/images"},
+		{name: "Public Address", url: "http://203.0.113.10:8080/hook"},
+		{name: "Invalid Scheme", url: "ftp://hooks.example.com", expectedErr: webhook.ErrInvalidURL},
+		{name: "Missing Host", url: "https:///hook", expectedErr: webhook.ErrInvalidURL},
+		{name: "Loopback", url: "http://127.0.0.1:8080/hook", expectedErr: webhook.ErrForbiddenAddress},
+		{name: "Private", url: "http://10.0.0.5/hook", expectedErr: webhook.ErrForbiddenAddress},
+		{name: "Metadata Endpoint", url: "http://169.254.169.254/latest/meta-data/", expectedErr: webhook.ErrForbiddenAddress},
+		{name: "IPv6 Loopback", url: "http://[::1]/hook", expectedErr: webhook.ErrForbiddenAddress},
+		{name: "Mapped IPv4", url: "http://[::ffff:192.168.0.1]/hook", expectedErr: webhook.ErrForbiddenAddress},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := webhook.ValidateURL(tt.url)
+			if tt.expectedErr == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, tt.expectedErr)
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"type":"image.processed"}`)
+	now := time.Now()
+
+	tests := []struct {
+		name        string
+		header      string
+		expectedErr error
+	}{
+		{name: "Valid", header: webhook.Sign("secret", now, body)},
+		{name: "Wrong Secret", header: webhook.Sign("other", now, body), expectedErr: webhook.ErrInvalidSignature},
+		{name: "Too Old", header: webhook.Sign("secret", now.Add(-10*time.Minute), body), expectedErr: webhook.ErrInvalidSignature},
+		{name: "Malformed", header: "v1=abc", expectedErr: webhook.ErrInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := webhook.Verify("secret", tt.header, body, 5*time.Minute, now)
+			if tt.expectedErr == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.True(t, errors.Is(err, tt.expectedErr))
+		})
+	}
+}
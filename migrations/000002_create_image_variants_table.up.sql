@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS image_variants
+(
+    image_id     UUID        NOT NULL REFERENCES images (id) ON DELETE CASCADE,
+    name         VARCHAR(50) NOT NULL,
+    blob_key     TEXT        NOT NULL,
+    content_type TEXT        NOT NULL,
+    size         BIGINT      NOT NULL,
+    checksum     CHAR(64)    NOT NULL,
+    created_at   TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (image_id, name)
+);
\ No newline at end of file
@@ -0,0 +1,11 @@
+DELETE FROM image_variants
+WHERE format <> 'jpeg';
+
+ALTER TABLE image_variants
+    DROP CONSTRAINT IF EXISTS image_variants_pkey;
+
+ALTER TABLE image_variants
+    ADD PRIMARY KEY (image_id, name);
+
+ALTER TABLE image_variants
+    DROP COLUMN IF EXISTS format;
\ No newline at end of file
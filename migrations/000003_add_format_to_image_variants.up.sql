@@ -0,0 +1,8 @@
+ALTER TABLE image_variants
+    ADD COLUMN IF NOT EXISTS format VARCHAR(10) NOT NULL DEFAULT 'jpeg';
+
+ALTER TABLE image_variants
+    DROP CONSTRAINT IF EXISTS image_variants_pkey;
+
+ALTER TABLE image_variants
+    ADD PRIMARY KEY (image_id, name, format);
\ No newline at end of file
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS images_owner_key_id_idx;
+
+ALTER TABLE images
+    DROP COLUMN IF EXISTS owner_key_id;
+
+DROP TABLE IF EXISTS api_keys;
\ No newline at end of file
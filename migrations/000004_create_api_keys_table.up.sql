@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS api_keys
+(
+    id         UUID PRIMARY KEY,
+    name       TEXT        NOT NULL,
+    key_prefix VARCHAR(16) NOT NULL,
+    key_hash   CHAR(64)    NOT NULL UNIQUE,
+    scopes     TEXT[]      NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMP WITH TIME ZONE
+);
+
+ALTER TABLE images
+    ADD COLUMN IF NOT EXISTS owner_key_id UUID REFERENCES api_keys (id);
+
+CREATE INDEX IF NOT EXISTS images_owner_key_id_idx ON images (owner_key_id);
\ No newline at end of file
@@ -0,0 +1,20 @@
+DROP INDEX IF EXISTS api_keys_tenant_id_idx;
+DROP INDEX IF EXISTS images_tenant_id_id_idx;
+
+UPDATE image_variants
+SET blob_key = substr(blob_key, length(tenant_id) + 2);
+
+UPDATE images
+SET original_path            = substr(original_path, length(tenant_id) + 2),
+    processed_path_resize    = substr(processed_path_resize, length(tenant_id) + 2),
+    processed_path_thumbnail = substr(processed_path_thumbnail, length(tenant_id) + 2),
+    processed_path_watermark = substr(processed_path_watermark, length(tenant_id) + 2);
+
+ALTER TABLE image_variants
+    DROP COLUMN IF EXISTS tenant_id;
+
+ALTER TABLE images
+    DROP COLUMN IF EXISTS tenant_id;
+
+ALTER TABLE api_keys
+    DROP COLUMN IF EXISTS tenant_id;
\ No newline at end of file
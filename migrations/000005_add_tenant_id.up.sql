@@ -0,0 +1,30 @@
+ALTER TABLE api_keys
+    ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(63) NOT NULL DEFAULT 'default';
+
+ALTER TABLE images
+    ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(63) NOT NULL DEFAULT 'default';
+
+ALTER TABLE image_variants
+    ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(63) NOT NULL DEFAULT 'default';
+
+ALTER TABLE api_keys
+    ALTER COLUMN tenant_id DROP DEFAULT;
+
+ALTER TABLE images
+    ALTER COLUMN tenant_id DROP DEFAULT;
+
+ALTER TABLE image_variants
+    ALTER COLUMN tenant_id DROP DEFAULT;
+
+-- Existing blobs move into the namespace of the default tenant.
+UPDATE images
+SET original_path            = 'default/' || regexp_replace(original_path, '^(\./)+', ''),
+    processed_path_resize    = 'default/' || processed_path_resize,
+    processed_path_thumbnail = 'default/' || processed_path_thumbnail,
+    processed_path_watermark = 'default/' || processed_path_watermark;
+
+UPDATE image_variants
+SET blob_key = 'default/' || blob_key;
+
+CREATE INDEX IF NOT EXISTS images_tenant_id_id_idx ON images (tenant_id, id);
+CREATE INDEX IF NOT EXISTS api_keys_tenant_id_idx ON api_keys (tenant_id);
\ No newline at end of file
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS usage_counters;
+
+ALTER TABLE images
+    DROP COLUMN IF EXISTS size;
\ No newline at end of file
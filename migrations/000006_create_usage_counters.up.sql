@@ -0,0 +1,29 @@
+ALTER TABLE images
+    ADD COLUMN IF NOT EXISTS size BIGINT NOT NULL DEFAULT 0;
+
+-- One row per tenant (api_key_id is the nil UUID) and one per API key.
+CREATE TABLE IF NOT EXISTS usage_counters
+(
+    tenant_id      VARCHAR(63) NOT NULL,
+    api_key_id     UUID        NOT NULL,
+    bytes          BIGINT      NOT NULL DEFAULT 0,
+    images         BIGINT      NOT NULL DEFAULT 0,
+    window_start   TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    window_uploads INTEGER     NOT NULL DEFAULT 0,
+    PRIMARY KEY (tenant_id, api_key_id)
+);
+
+INSERT INTO usage_counters (tenant_id, api_key_id, bytes, images)
+SELECT i.tenant_id, '00000000-0000-0000-0000-000000000000', COALESCE(SUM(v.size), 0), COUNT(DISTINCT i.id)
+FROM images i
+         LEFT JOIN image_variants v ON v.image_id = i.id
+GROUP BY i.tenant_id
+ON CONFLICT DO NOTHING;
+
+INSERT INTO usage_counters (tenant_id, api_key_id, bytes, images)
+SELECT i.tenant_id, i.owner_key_id, COALESCE(SUM(v.size), 0), COUNT(DISTINCT i.id)
+FROM images i
+         LEFT JOIN image_variants v ON v.image_id = i.id
+WHERE i.owner_key_id IS NOT NULL
+GROUP BY i.tenant_id, i.owner_key_id
+ON CONFLICT DO NOTHING;
\ No newline at end of file
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS webhook_deliveries;
+
+DROP TABLE IF EXISTS webhooks;
+
+ALTER TABLE images
+    DROP COLUMN IF EXISTS callback_url;
\ No newline at end of file
@@ -0,0 +1,35 @@
+ALTER TABLE images
+    ADD COLUMN IF NOT EXISTS callback_url TEXT;
+
+CREATE TABLE IF NOT EXISTS webhooks
+(
+    id         UUID PRIMARY KEY,
+    tenant_id  VARCHAR(63) NOT NULL,
+    api_key_id UUID        NOT NULL REFERENCES api_keys (id) ON DELETE CASCADE,
+    url        TEXT        NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS webhooks_tenant_id_api_key_id_idx ON webhooks (tenant_id, api_key_id);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries
+(
+    id               UUID PRIMARY KEY,
+    tenant_id        VARCHAR(63)              NOT NULL,
+    api_key_id       UUID                     NOT NULL,
+    webhook_id       UUID REFERENCES webhooks (id) ON DELETE SET NULL,
+    image_id         UUID                     NOT NULL,
+    event            VARCHAR(50)              NOT NULL,
+    url              TEXT                     NOT NULL,
+    payload          JSONB                    NOT NULL,
+    status           VARCHAR(50)              NOT NULL DEFAULT 'pending',
+    attempts         INT                      NOT NULL DEFAULT 0,
+    next_attempt_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_status_code INT,
+    last_error       TEXT,
+    created_at       TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    delivered_at     TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_pending_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS webhook_deliveries_tenant_id_api_key_id_idx ON webhook_deliveries (tenant_id, api_key_id, created_at);
\ No newline at end of file
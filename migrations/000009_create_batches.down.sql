@@ -0,0 +1,4 @@
+ALTER TABLE images
+    DROP COLUMN IF EXISTS batch_id;
+
+DROP TABLE IF EXISTS batches;
\ No newline at end of file
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS batches
+(
+    id           UUID PRIMARY KEY,
+    tenant_id    VARCHAR(63) NOT NULL,
+    owner_key_id UUID,
+    created_at   TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE images
+    ADD COLUMN IF NOT EXISTS batch_id UUID REFERENCES batches (id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS images_batch_id_idx ON images (batch_id) WHERE batch_id IS NOT NULL;
\ No newline at end of file
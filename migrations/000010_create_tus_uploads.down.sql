@@ -0,0 +1 @@
+DROP TABLE IF EXISTS tus_uploads;
\ No newline at end of file
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS tus_uploads
+(
+    id            UUID PRIMARY KEY,
+    tenant_id     VARCHAR(63)              NOT NULL,
+    owner_key_id  UUID,
+    length        BIGINT                   NOT NULL,
+    upload_offset BIGINT                   NOT NULL DEFAULT 0,
+    metadata      TEXT                     NOT NULL DEFAULT '',
+    filename      TEXT                     NOT NULL,
+    callback_url  TEXT                     NOT NULL DEFAULT '',
+    chunks        TEXT[]                   NOT NULL DEFAULT '{}',
+    image_id      UUID,
+    expires_at    TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at    TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS tus_uploads_expires_at_idx ON tus_uploads (expires_at);
\ No newline at end of file
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS images_upload_expires_at_idx;
+
+ALTER TABLE images
+    DROP COLUMN IF EXISTS upload_expires_at;
+
+ALTER TABLE images
+    DROP COLUMN IF EXISTS sha256;
\ No newline at end of file
@@ -0,0 +1,7 @@
+ALTER TABLE images
+    ADD COLUMN IF NOT EXISTS sha256 CHAR(64);
+
+ALTER TABLE images
+    ADD COLUMN IF NOT EXISTS upload_expires_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS images_upload_expires_at_idx ON images (upload_expires_at) WHERE status = 'pending_upload';
\ No newline at end of file
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS images_original_path_idx;
+
+DROP TABLE IF EXISTS blobs;
\ No newline at end of file
@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS blobs
+(
+    key        TEXT PRIMARY KEY,
+    tenant_id  VARCHAR(63) NOT NULL,
+    sha256     CHAR(64),
+    refs       INT         NOT NULL DEFAULT 1,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS blobs_tenant_id_sha256_idx ON blobs (tenant_id, sha256);
+
+CREATE INDEX IF NOT EXISTS images_original_path_idx ON images (original_path);
+
+INSERT INTO blobs (key, tenant_id, refs)
+SELECT original_path, tenant_id, COUNT(*)
+FROM images
+GROUP BY original_path, tenant_id
+ON CONFLICT (key) DO NOTHING;
+
+INSERT INTO blobs (key, tenant_id, refs)
+SELECT blob_key, tenant_id, COUNT(*)
+FROM image_variants
+GROUP BY blob_key, tenant_id
+ON CONFLICT (key) DO UPDATE SET refs = blobs.refs + EXCLUDED.refs;
\ No newline at end of file
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS idempotency_keys
+(
+    tenant_id   VARCHAR(63)              NOT NULL,
+    api_key_id  UUID                     NOT NULL,
+    key         VARCHAR(255)             NOT NULL,
+    fingerprint CHAR(64),
+    status_code INT,
+    header      JSONB,
+    body        BYTEA,
+    created_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at  TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (tenant_id, api_key_id, key)
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS image_hashes;
\ No newline at end of file
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS image_hashes
+(
+    image_id   UUID PRIMARY KEY REFERENCES images (id) ON DELETE CASCADE,
+    tenant_id  VARCHAR(63) NOT NULL,
+    ahash      BIGINT      NOT NULL,
+    dhash      BIGINT      NOT NULL,
+    phash      BIGINT      NOT NULL,
+    -- The 16-bit chunks of phash, highest first, each indexed for
+    -- multi-index hashing.
+    phash_0    INT         NOT NULL,
+    phash_1    INT         NOT NULL,
+    phash_2    INT         NOT NULL,
+    phash_3    INT         NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS image_hashes_phash_0_idx ON image_hashes (tenant_id, phash_0);
+CREATE INDEX IF NOT EXISTS image_hashes_phash_1_idx ON image_hashes (tenant_id, phash_1);
+CREATE INDEX IF NOT EXISTS image_hashes_phash_2_idx ON image_hashes (tenant_id, phash_2);
+CREATE INDEX IF NOT EXISTS image_hashes_phash_3_idx ON image_hashes (tenant_id, phash_3);
\ No newline at end of file
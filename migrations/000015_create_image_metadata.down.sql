@@ -0,0 +1 @@
+DROP TABLE IF EXISTS image_metadata;
\ No newline at end of file
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS image_metadata
+(
+    image_id     UUID PRIMARY KEY REFERENCES images (id) ON DELETE CASCADE,
+    tenant_id    VARCHAR(63) NOT NULL,
+    -- The fields searched by, out of data.
+    taken_at     TIMESTAMP WITH TIME ZONE,
+    camera_make  TEXT,
+    camera_model TEXT,
+    lens_model   TEXT,
+    orientation  SMALLINT,
+    latitude     DOUBLE PRECISION,
+    longitude    DOUBLE PRECISION,
+    data         JSONB       NOT NULL,
+    extracted_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS image_metadata_taken_at_idx ON image_metadata (tenant_id, taken_at);
+CREATE INDEX IF NOT EXISTS image_metadata_camera_idx ON image_metadata (tenant_id, lower(camera_make), lower(camera_model));
\ No newline at end of file
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS image_metadata_location_idx;
\ No newline at end of file
@@ -0,0 +1,3 @@
+-- Latitudes are searched by range and longitudes checked within the index.
+CREATE INDEX IF NOT EXISTS image_metadata_location_idx ON image_metadata (tenant_id, latitude, longitude)
+    WHERE latitude IS NOT NULL AND longitude IS NOT NULL;
\ No newline at end of file
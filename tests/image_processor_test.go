@@ -1,26 +1,46 @@
 package tests
 
 import (
+	"archive/zip"
 	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"github.com/disintegration/imaging"
 	"github.com/gavv/httpexpect/v2"
 	"github.com/stretchr/testify/require"
+	"image"
 	"io"
+	"math"
+	"math/rand"
 	"mime/multipart"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
 
 const (
 	host = "0.0.0.0:8082"
+	// apiKey is the bootstrap admin key from config/test.yml.
+	apiKey = "test-admin-key"
 )
 
-func TestFullImageProcessingCycle(t *testing.T) {
+func newExpect(t *testing.T) *httpexpect.Expect {
 	u := url.URL{Scheme: "http", Host: host}
-	e := httpexpect.Default(t, u.String())
+
+	return httpexpect.Default(t, u.String()).Builder(func(req *httpexpect.Request) {
+		req.WithHeader("X-API-Key", apiKey)
+	})
+}
+
+func TestFullImageProcessingCycle(t *testing.T) {
+	e := newExpect(t)
 
 	t.Run("Upload Image", func(t *testing.T) {
 		filePath := "test_image.jpg"
@@ -48,9 +68,8 @@ func TestFullImageProcessingCycle(t *testing.T) {
 		imageID := resp.Raw()
 
 		t.Run("Get Image", func(t *testing.T) {
-			time.Sleep(5 * time.Second)
-
-			resp := e.GET("/image/" + imageID).
+			resp := e.GET("/image/"+imageID).
+				WithQuery("wait", "30s").
 				Expect().
 				Status(http.StatusOK).
 				JSON().Object()
@@ -59,13 +78,70 @@ func TestFullImageProcessingCycle(t *testing.T) {
 				Value("ID").String().IsEqual(imageID)
 			resp.Value("image").Object().
 				Value("Status").String().IsEqual("processed")
+			resp.Value("image").Object().
+				Value("TenantID").String().IsEqual("default")
+
+			e.GET("/image/" + imageID + "/variants/resize").
+				Expect().
+				Status(http.StatusForbidden)
+
+			resizeURL := resp.Value("urls").Object().Value("variants").Object().Value("resize").String().Raw()
+			e.GET(resizeURL).
+				Expect().
+				Status(http.StatusOK).
+				Header("ETag").NotEmpty()
 
-			processedPath := resp.Value("image").Object().Value("ProcessedPathResize").Object().Value("String").String().Raw()
-			e.GET("/" + processedPath).
+			originalURL := resp.Value("urls").Object().Value("original").String().Raw()
+			e.GET(originalURL).
 				Expect().
 				Status(http.StatusOK)
 		})
 
+		t.Run("Download Archive", func(t *testing.T) {
+			archive := e.GET("/image/" + imageID + "/archive").
+				Expect().
+				Status(http.StatusOK).
+				Body().Raw()
+
+			zr, err := zip.NewReader(strings.NewReader(archive), int64(len(archive)))
+			require.NoError(t, err)
+
+			var names []string
+			for _, f := range zr.File {
+				names = append(names, f.Name)
+			}
+			require.Contains(t, names, "original.jpg")
+			require.Contains(t, names, "variants/resize.jpg")
+			require.Contains(t, names, "metadata.json")
+
+			e.POST("/images/archive").
+				WithJSON(map[string]interface{}{"ids": []string{imageID}}).
+				Expect().
+				Status(http.StatusOK).
+				ContentType("application/zip")
+		})
+
+		t.Run("Other Tenant", func(t *testing.T) {
+			token := e.POST("/admin/keys").
+				WithJSON(map[string]interface{}{"name": "other", "scopes": []string{"admin"}, "tenant": "other"}).
+				Expect().
+				Status(http.StatusOK).
+				JSON().Object().
+				Value("token").String().Raw()
+
+			u := url.URL{Scheme: "http", Host: host}
+			other := httpexpect.Default(t, u.String()).Builder(func(req *httpexpect.Request) {
+				req.WithHeader("X-API-Key", token)
+			})
+
+			other.GET("/image/" + imageID).
+				Expect().
+				Status(http.StatusNotFound)
+			other.DELETE("/image/" + imageID).
+				Expect().
+				Status(http.StatusNotFound)
+		})
+
 		t.Run("Delete Image", func(t *testing.T) {
 			e.DELETE("/image/" + imageID).
 				Expect().
@@ -81,8 +157,7 @@ func TestFullImageProcessingCycle(t *testing.T) {
 }
 
 func TestInvalidUpload(t *testing.T) {
-	u := url.URL{Scheme: "http", Host: host}
-	e := httpexpect.Default(t, u.String())
+	e := newExpect(t)
 
 	e.POST("/upload").
 		Expect().
@@ -91,10 +166,18 @@ func TestInvalidUpload(t *testing.T) {
 		Value("error").String().Contains("file from request")
 }
 
-func TestGetImageNotFound(t *testing.T) {
+func TestMissingAPIKey(t *testing.T) {
 	u := url.URL{Scheme: "http", Host: host}
 	e := httpexpect.Default(t, u.String())
 
+	e.GET("/image/00000000-0000-0000-0000-000000000000").
+		Expect().
+		Status(http.StatusUnauthorized)
+}
+
+func TestGetImageNotFound(t *testing.T) {
+	e := newExpect(t)
+
 	nonExistentID := "00000000-0000-0000-0000-000000000000"
 
 	e.GET("/image/" + nonExistentID).
@@ -103,3 +186,601 @@ func TestGetImageNotFound(t *testing.T) {
 		JSON().Object().
 		Value("error").String().Contains("not found")
 }
+
+func TestUsage(t *testing.T) {
+	e := newExpect(t)
+
+	resp := e.GET("/usage").
+		Expect().
+		Status(http.StatusOK)
+
+	resp.Header("X-Quota-Bytes-Limit").NotEmpty()
+	resp.JSON().Object().
+		Value("tenant").Object().
+		Value("id").String().IsEqual("default")
+}
+
+func TestWebhooks(t *testing.T) {
+	e := newExpect(t)
+
+	resp := e.POST("/webhooks").
+		WithJSON(map[string]string{"url": "https://hooks.example.com/images"}).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object()
+
+	resp.Value("secret").String().NotEmpty()
+	webhookID := resp.Value("webhook").Object().Value("ID").String().Raw()
+
+	e.GET("/webhooks").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		Value("webhooks").Array().NotEmpty()
+
+	e.DELETE("/webhooks/" + webhookID).
+		Expect().
+		Status(http.StatusOK)
+}
+
+func TestBatchUpload(t *testing.T) {
+	e := newExpect(t)
+
+	image, err := os.ReadFile("test_image.jpg")
+	require.NoError(t, err)
+
+	archive := &bytes.Buffer{}
+	zw := zip.NewWriter(archive)
+	w, err := zw.Create("photos/test_image.jpg")
+	require.NoError(t, err)
+	_, err = w.Write(image)
+	require.NoError(t, err)
+	_, err = zw.Create("../escape.jpg")
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("images", "test_image.jpg")
+	require.NoError(t, err)
+	_, err = part.Write(image)
+	require.NoError(t, err)
+	part, err = writer.CreateFormFile("images", "photos.zip")
+	require.NoError(t, err)
+	_, err = part.Write(archive.Bytes())
+	require.NoError(t, err)
+	writer.Close()
+
+	resp := e.POST("/uploads/batch").
+		WithHeader("Content-Type", writer.FormDataContentType()).
+		WithBytes(body.Bytes()).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object()
+
+	resp.Value("succeeded").Number().IsEqual(2)
+	resp.Value("failed").Number().IsEqual(1)
+	resp.Value("files").Array().Value(2).Object().
+		Value("error").String().IsEqual("invalid file name")
+
+	batchID := resp.Value("batch_id").String().Raw()
+
+	e.GET("/uploads/batch/" + batchID).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		Value("images").Array().Length().IsEqual(2)
+}
+
+func TestTusUpload(t *testing.T) {
+	e := newExpect(t)
+
+	image, err := os.ReadFile("test_image.jpg")
+	require.NoError(t, err)
+
+	location := e.POST("/uploads/tus").
+		WithHeader("Tus-Resumable", "1.0.0").
+		WithHeader("Upload-Length", strconv.Itoa(len(image))).
+		WithHeader("Upload-Metadata", "filename "+base64.StdEncoding.EncodeToString([]byte("test_image.jpg"))).
+		Expect().
+		Status(http.StatusCreated).
+		Header("Location").NotEmpty().Raw()
+
+	half := len(image) / 2
+
+	e.PATCH(location).
+		WithHeader("Tus-Resumable", "1.0.0").
+		WithHeader("Content-Type", "application/offset+octet-stream").
+		WithHeader("Upload-Offset", "0").
+		WithBytes(image[:half]).
+		Expect().
+		Status(http.StatusNoContent).
+		Header("Upload-Offset").IsEqual(strconv.Itoa(half))
+
+	e.HEAD(location).
+		WithHeader("Tus-Resumable", "1.0.0").
+		Expect().
+		Status(http.StatusOK).
+		Header("Upload-Offset").IsEqual(strconv.Itoa(half))
+
+	imageID := e.PATCH(location).
+		WithHeader("Tus-Resumable", "1.0.0").
+		WithHeader("Content-Type", "application/offset+octet-stream").
+		WithHeader("Upload-Offset", strconv.Itoa(half)).
+		WithBytes(image[half:]).
+		Expect().
+		Status(http.StatusNoContent).
+		Header("X-Image-ID").NotEmpty().Raw()
+
+	e.GET("/image/"+imageID).
+		WithQuery("wait", "30s").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		Value("image").Object().
+		Value("Status").String().IsEqual("processed")
+
+	e.DELETE(location).
+		WithHeader("Tus-Resumable", "1.0.0").
+		Expect().
+		Status(http.StatusNoContent)
+}
+
+func TestPresignedUpload(t *testing.T) {
+	e := newExpect(t)
+
+	image, err := os.ReadFile("test_image.jpg")
+	require.NoError(t, err)
+
+	sum := sha256.Sum256(image)
+
+	presign := e.POST("/uploads/presign").
+		WithJSON(map[string]any{
+			"filename": "test_image.jpg",
+			"size":     len(image),
+			"sha256":   hex.EncodeToString(sum[:]),
+		}).
+		Expect().
+		Status(http.StatusCreated).
+		JSON().Object()
+
+	imageID := presign.Value("image_id").String().NotEmpty().Raw()
+	presign.Value("method").String().IsEqual(http.MethodPut)
+
+	// The URL points at the public address of the backend, which the tests
+	// reach on another port.
+	uploadURL, err := url.Parse(presign.Value("upload_url").String().Raw())
+	require.NoError(t, err)
+
+	e.POST("/uploads/" + imageID + "/complete").
+		Expect().
+		Status(http.StatusConflict)
+
+	e.PUT(uploadURL.Path).
+		WithQueryString(uploadURL.RawQuery).
+		WithBytes(image).
+		Expect().
+		Status(http.StatusOK)
+
+	e.POST("/uploads/" + imageID + "/complete").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		Value("image_id").String().IsEqual(imageID)
+
+	e.GET("/image/"+imageID).
+		WithQuery("wait", "30s").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		Value("image").Object().
+		Value("Status").String().IsEqual("processed")
+}
+
+func TestDeduplicatedUpload(t *testing.T) {
+	e := newExpect(t)
+
+	image, err := os.ReadFile("test_image.jpg")
+	require.NoError(t, err)
+
+	upload := func() string {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, err := writer.CreateFormFile("image", "test_image.jpg")
+		require.NoError(t, err)
+		_, err = part.Write(image)
+		require.NoError(t, err)
+		writer.Close()
+
+		return e.POST("/upload").
+			WithHeader("Content-Type", writer.FormDataContentType()).
+			WithBytes(body.Bytes()).
+			Expect().
+			Status(http.StatusOK).
+			JSON().Object().
+			Value("image_id").String().NotEmpty().Raw()
+	}
+
+	// config/test.yml shares the blobs of identical uploads.
+	first := upload()
+	e.GET("/image/"+first).
+		WithQuery("wait", "30s").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		Value("image").Object().
+		Value("Status").String().IsEqual("processed")
+
+	second := upload()
+	require.NotEqual(t, first, second)
+
+	resp := e.GET("/image/"+second).
+		WithQuery("wait", "30s").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object()
+	resp.Value("image").Object().Value("Status").String().IsEqual("processed")
+	resp.Value("image").Object().Value("OriginalPath").IsEqual(
+		e.GET("/image/" + first).Expect().JSON().Object().Value("image").Object().Value("OriginalPath").Raw(),
+	)
+
+	// The shared blobs outlive the first image.
+	e.DELETE("/image/" + first).
+		Expect().
+		Status(http.StatusOK)
+
+	originalURL := resp.Value("urls").Object().Value("original").String().Raw()
+	e.GET(originalURL).
+		Expect().
+		Status(http.StatusOK)
+}
+
+func TestIdempotentUpload(t *testing.T) {
+	e := newExpect(t)
+
+	image, err := os.ReadFile("test_image.jpg")
+	require.NoError(t, err)
+
+	key := "upload-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+
+	// Every call draws a new multipart boundary, as a retrying client would.
+	upload := func(content []byte) *httpexpect.Response {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, err := writer.CreateFormFile("image", "test_image.jpg")
+		require.NoError(t, err)
+		_, err = part.Write(content)
+		require.NoError(t, err)
+		writer.Close()
+
+		return e.POST("/upload").
+			WithHeader("Content-Type", writer.FormDataContentType()).
+			WithHeader("Idempotency-Key", key).
+			WithBytes(body.Bytes()).
+			Expect()
+	}
+
+	first := upload(image)
+	first.Status(http.StatusOK)
+	first.Header("Idempotent-Replayed").IsEmpty()
+	imageID := first.JSON().Object().Value("image_id").String().NotEmpty().Raw()
+
+	replayed := upload(image)
+	replayed.Status(http.StatusOK)
+	replayed.Header("Idempotent-Replayed").IsEqual("true")
+	replayed.JSON().Object().Value("image_id").String().IsEqual(imageID)
+
+	upload(append([]byte(nil), image[:len(image)/2]...)).
+		Status(http.StatusUnprocessableEntity)
+}
+
+func TestSimilarImages(t *testing.T) {
+	e := newExpect(t)
+
+	original, err := imaging.Open("test_image.jpg")
+	require.NoError(t, err)
+
+	// A smaller, recompressed copy has another SHA-256 but looks the same.
+	upload := func(img image.Image, quality int) string {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, err := writer.CreateFormFile("image", "test_image.jpg")
+		require.NoError(t, err)
+		require.NoError(t, imaging.Encode(part, img, imaging.JPEG, imaging.JPEGQuality(quality)))
+		writer.Close()
+
+		imageID := e.POST("/upload").
+			WithHeader("Content-Type", writer.FormDataContentType()).
+			WithBytes(body.Bytes()).
+			Expect().
+			Status(http.StatusOK).
+			JSON().Object().
+			Value("image_id").String().NotEmpty().Raw()
+
+		e.GET("/image/"+imageID).
+			WithQuery("wait", "30s").
+			Expect().
+			Status(http.StatusOK).
+			JSON().Object().
+			Value("image").Object().
+			Value("Status").String().IsEqual("processed")
+
+		return imageID
+	}
+
+	first := upload(original, 95)
+	second := upload(imaging.Resize(original, original.Bounds().Dx()/2, 0, imaging.Lanczos), 60)
+
+	images := e.GET("/image/"+first+"/similar").
+		WithQuery("max_distance", 8).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		Value("images").Array()
+
+	found := false
+	for _, value := range images.Iter() {
+		if value.Object().Value("image").Object().Value("ID").String().Raw() == second {
+			value.Object().Value("distance").Number().Le(8)
+			found = true
+		}
+	}
+	require.True(t, found)
+
+	e.GET("/image/"+first+"/similar").
+		WithQuery("max_distance", 13).
+		Expect().
+		Status(http.StatusBadRequest)
+}
+
+func TestImageMetadata(t *testing.T) {
+	e := newExpect(t)
+
+	original, err := os.ReadFile("test_image.jpg")
+	require.NoError(t, err)
+
+	// A little-endian TIFF structure whose IFD 0 holds Make and DateTime,
+	// put in an APP1 segment right after the SOI marker.
+	exif := []byte("II*\x00\x08\x00\x00\x00")
+	exif = binary.LittleEndian.AppendUint16(exif, 2)
+	exif = append(exif, 0x0f, 0x01, 2, 0, 6, 0, 0, 0, 38, 0, 0, 0)
+	exif = append(exif, 0x32, 0x01, 2, 0, 20, 0, 0, 0, 44, 0, 0, 0)
+	exif = append(exif, 0, 0, 0, 0)
+	exif = append(exif, "Canon\x00"...)
+	exif = append(exif, "2021:06:15 14:30:00\x00"...)
+
+	segment := append([]byte("Exif\x00\x00"), exif...)
+	file := []byte{0xff, 0xd8, 0xff, 0xe1}
+	file = binary.BigEndian.AppendUint16(file, uint16(len(segment)+2))
+	file = append(file, segment...)
+	file = append(file, original[2:]...)
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("image", "exif.jpg")
+	require.NoError(t, err)
+	_, err = part.Write(file)
+	require.NoError(t, err)
+	writer.Close()
+
+	imageID := e.POST("/upload").
+		WithHeader("Content-Type", writer.FormDataContentType()).
+		WithBytes(body.Bytes()).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		Value("image_id").String().NotEmpty().Raw()
+
+	e.GET("/image/"+imageID).
+		WithQuery("wait", "30s").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		Value("image").Object().
+		Value("Status").String().IsEqual("processed")
+
+	metadata := e.GET("/image/" + imageID + "/metadata").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		Value("metadata").Object()
+
+	metadata.Value("image_id").String().IsEqual(imageID)
+	metadata.Value("camera").Object().Value("make").String().IsEqual("Canon")
+	metadata.Value("taken_at").String().IsEqual("2021-06-15T14:30:00Z")
+
+	e.GET("/image/00000000-0000-0000-0000-000000000000/metadata").
+		Expect().
+		Status(http.StatusNotFound)
+}
+
+func TestGeoSearch(t *testing.T) {
+	e := newExpect(t)
+
+	original, err := os.ReadFile("test_image.jpg")
+	require.NoError(t, err)
+
+	// gpsJPEG puts a little-endian TIFF structure whose IFD 0 only points
+	// to a GPS IFD in an APP1 segment right after the SOI marker.
+	gpsJPEG := func(lat, lon float64) []byte {
+		latRef, lonRef := "N", "E"
+		if lat < 0 {
+			latRef, lat = "S", -lat
+		}
+		if lon < 0 {
+			lonRef, lon = "W", -lon
+		}
+		degrees := func(v float64) []byte {
+			b := binary.LittleEndian.AppendUint32(nil, uint32(math.Round(v*1e6)))
+			b = binary.LittleEndian.AppendUint32(b, 1e6)
+			return append(b, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0)
+		}
+
+		exif := []byte("II*\x00\x08\x00\x00\x00")
+		exif = binary.LittleEndian.AppendUint16(exif, 1)
+		exif = append(exif, 0x25, 0x88, 4, 0, 1, 0, 0, 0, 26, 0, 0, 0)
+		exif = append(exif, 0, 0, 0, 0)
+		exif = binary.LittleEndian.AppendUint16(exif, 4)
+		exif = append(exif, 1, 0, 2, 0, 2, 0, 0, 0, latRef[0], 0, 0, 0)
+		exif = append(exif, 2, 0, 5, 0, 3, 0, 0, 0, 80, 0, 0, 0)
+		exif = append(exif, 3, 0, 2, 0, 2, 0, 0, 0, lonRef[0], 0, 0, 0)
+		exif = append(exif, 4, 0, 5, 0, 3, 0, 0, 0, 104, 0, 0, 0)
+		exif = append(exif, 0, 0, 0, 0)
+		exif = append(exif, degrees(lat)...)
+		exif = append(exif, degrees(lon)...)
+
+		segment := append([]byte("Exif\x00\x00"), exif...)
+		file := []byte{0xff, 0xd8, 0xff, 0xe1}
+		file = binary.BigEndian.AppendUint16(file, uint16(len(segment)+2))
+		file = append(file, segment...)
+
+		return append(file, original[2:]...)
+	}
+
+	upload := func(lat, lon float64) string {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, err := writer.CreateFormFile("image", "gps.jpg")
+		require.NoError(t, err)
+		_, err = part.Write(gpsJPEG(lat, lon))
+		require.NoError(t, err)
+		writer.Close()
+
+		imageID := e.POST("/upload").
+			WithHeader("Content-Type", writer.FormDataContentType()).
+			WithBytes(body.Bytes()).
+			Expect().
+			Status(http.StatusOK).
+			JSON().Object().
+			Value("image_id").String().NotEmpty().Raw()
+
+		e.GET("/image/"+imageID).
+			WithQuery("wait", "30s").
+			Expect().
+			Status(http.StatusOK).
+			JSON().Object().
+			Value("image").Object().
+			Value("Status").String().IsEqual("processed")
+
+		return imageID
+	}
+
+	// A place of its own for every run, so that the images of earlier runs
+	// stay out of the way.
+	lat, lon := -50+100*rand.Float64(), -170+340*rand.Float64()
+	near := upload(lat+0.001, lon)
+	far := upload(lat+0.1, lon)
+
+	ids := func(images *httpexpect.Array) []string {
+		var ids []string
+		for _, image := range images.Iter() {
+			ids = append(ids, image.Object().Value("image").Object().Value("ID").String().Raw())
+		}
+		return ids
+	}
+	search := func(query map[string]any) *httpexpect.Object {
+		req := e.GET("/images/search/geo")
+		for name, value := range query {
+			req = req.WithQuery(name, value)
+		}
+		return req.Expect().Status(http.StatusOK).JSON().Object()
+	}
+
+	t.Run("Radius", func(t *testing.T) {
+		resp := search(map[string]any{"lat": lat, "lon": lon, "radius_km": 1})
+		images := resp.Value("images").Array()
+		require.Equal(t, []string{near}, ids(images))
+		images.Value(0).Object().Value("distance_km").Number().InRange(0.1, 0.12)
+		images.Value(0).Object().Value("latitude").Number().InDelta(lat+0.001, 1e-6)
+		resp.NotContainsKey("next_offset")
+
+		require.Equal(t, []string{near, far}, ids(search(map[string]any{"lat": lat, "lon": lon, "radius_km": 20}).Value("images").Array()))
+	})
+
+	t.Run("Pages", func(t *testing.T) {
+		first := search(map[string]any{"lat": lat, "lon": lon, "radius_km": 20, "limit": 1})
+		require.Equal(t, []string{near}, ids(first.Value("images").Array()))
+		first.Value("next_offset").Number().IsEqual(1)
+
+		second := search(map[string]any{"lat": lat, "lon": lon, "radius_km": 20, "limit": 1, "offset": 1})
+		require.Equal(t, []string{far}, ids(second.Value("images").Array()))
+		second.NotContainsKey("next_offset")
+	})
+
+	t.Run("Bounding Box", func(t *testing.T) {
+		bbox := func(minLon, minLat, maxLon, maxLat float64) map[string]any {
+			return map[string]any{"bbox": strings.Join([]string{
+				strconv.FormatFloat(minLon, 'f', -1, 64), strconv.FormatFloat(minLat, 'f', -1, 64),
+				strconv.FormatFloat(maxLon, 'f', -1, 64), strconv.FormatFloat(maxLat, 'f', -1, 64),
+			}, ",")}
+		}
+
+		// Sorted by the distance from the center of the box, nearer far.
+		require.Equal(t, []string{far, near}, ids(search(bbox(lon-0.01, lat, lon+0.01, lat+0.15)).Value("images").Array()))
+		require.Equal(t, []string{near}, ids(search(bbox(lon-0.01, lat, lon+0.01, lat+0.01)).Value("images").Array()))
+	})
+
+	e.GET("/images/search/geo").
+		WithQuery("bbox", "1,2,3").
+		Expect().
+		Status(http.StatusBadRequest)
+}
+
+func TestResponsiveImages(t *testing.T) {
+	e := newExpect(t)
+
+	original, err := os.ReadFile("test_image.jpg")
+	require.NoError(t, err)
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("image", "test_image.jpg")
+	require.NoError(t, err)
+	_, err = part.Write(original)
+	require.NoError(t, err)
+	writer.Close()
+
+	imageID := e.POST("/upload").
+		WithHeader("Content-Type", writer.FormDataContentType()).
+		WithBytes(body.Bytes()).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		Value("image_id").String().NotEmpty().Raw()
+
+	urls := e.GET("/image/"+imageID).
+		WithQuery("wait", "30s").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		Value("urls").Object()
+
+	// test_image.jpg is 720 pixels across: the ladder stops at its width.
+	urls.Value("sizes").String().IsEqual("100vw")
+	srcset := urls.Value("srcset").String().Raw()
+	candidates := strings.Split(srcset, ", ")
+	require.Len(t, candidates, 3)
+	for i, width := range []string{"320w", "640w", "720w"} {
+		link, descriptor, ok := strings.Cut(candidates[i], " ")
+		require.True(t, ok)
+		require.Equal(t, width, descriptor)
+
+		content := e.GET(link).
+			Expect().
+			Status(http.StatusOK).
+			Body().Raw()
+		img, _, err := image.Decode(strings.NewReader(content))
+		require.NoError(t, err)
+		require.Equal(t, strings.TrimSuffix(width, "w"), strconv.Itoa(img.Bounds().Dx()))
+	}
+
+	picture := e.GET("/image/"+imageID+"/picture").
+		WithQuery("alt", "Test").
+		Expect().
+		Status(http.StatusOK).
+		ContentType("text/html", "utf-8").
+		Body()
+	picture.HasPrefix("<picture>")
+	picture.Contains(`<source type="image/png" srcset="http://localhost:8082/image/` + imageID + `/variants/w320?`)
+	picture.Contains(`alt="Test"`)
+}